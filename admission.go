@@ -0,0 +1,44 @@
+package priorityqueue
+
+// An AdmissionPolicy tells producers, through Admit, to shed low priority
+// work once the queue is deep.
+type AdmissionPolicy struct {
+	// Depth is the queue length above which the policy applies
+	Depth int `json:"depth"`
+
+	// Percentile, between 0 and 1, turns away priorities below that
+	// percentile of the queued priorities: 0.5 only admits items at least
+	// as urgent as the median queued item.
+	Percentile float64 `json:"percentile,omitempty"`
+
+	// MinPriority, when not nil, turns away priorities below it
+	MinPriority *int `json:"min_priority,omitempty"`
+}
+
+// SetAdmissionPolicies replaces the policies consulted by Admit. No policy,
+// the default, admits everything.
+func (pq *PriorityQueue) SetAdmissionPolicies(policies ...AdmissionPolicy) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.admission = append([]AdmissionPolicy(nil), policies...)
+}
+
+// Admit reports whether an item of the given priority should be pushed
+// under the current depth and priority distribution, so producers can shed
+// low value work at the source. It is advice: Push does not consult it.
+// Every policy applying at the current depth must admit the priority.
+func (pq *PriorityQueue) Admit(priority int) bool {
+	defer pq.lock(OpStats)()
+	for _, p := range pq.admission {
+		if pq.size() <= p.Depth {
+			continue
+		}
+		if p.MinPriority != nil && priority < *p.MinPriority {
+			return false
+		}
+		if p.Percentile > 0 && priority < pq.percentile(p.Percentile) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package priorityqueue
+
+import "testing"
+
+func Test_Admit(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, pq.Admit(-100), true)
+
+	min := 0
+	pq.SetAdmissionPolicies(
+		AdmissionPolicy{Depth: 5, Percentile: 0.5},
+		AdmissionPolicy{Depth: 20, MinPriority: &min},
+	)
+	populateQueue(pq, 5)
+	assertEqual(t, pq.Admit(1), true)
+
+	pq.Push(QItem{ID: "5", Priority: 6})
+	assertEqual(t, pq.Admit(2), false)
+	assertEqual(t, pq.Admit(3), true)
+
+	for n := 0; n < 20; n++ {
+		pq.Push(QItem{Priority: -5})
+	}
+	assertEqual(t, pq.Admit(-1), false)
+	assertEqual(t, pq.Admit(0), true)
+
+	pq.SetAdmissionPolicies()
+	assertEqual(t, pq.Admit(-100), true)
+}
@@ -0,0 +1,85 @@
+package priorityqueue
+
+import "container/heap"
+
+// An ageIndex orders the queued items by PushedAt, the oldest first, then
+// in the order they were queued. It is a heap kept up to date by track and
+// untrack, each item holding its position in age.
+type ageIndex []*QItem
+
+func (a ageIndex) Len() int { return len(a) }
+func (a ageIndex) Less(i, j int) bool {
+	if !a[i].PushedAt.Equal(a[j].PushedAt) {
+		return a[i].PushedAt.Before(a[j].PushedAt)
+	}
+	return a[i].seq < a[j].seq
+}
+func (a ageIndex) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+	a[i].age, a[j].age = i, j
+}
+func (a *ageIndex) Push(x any) {
+	item := x.(*QItem)
+	item.age = len(*a)
+	*a = append(*a, item)
+}
+func (a *ageIndex) Pop() any {
+	old := *a
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.age = -1
+	*a = old[:n-1]
+	return item
+}
+
+// OldestN returns copies of the n queued items that have waited longest,
+// the oldest first, to escalate them. Items pushed at the same time are
+// ordered as they were queued. It returns nil once the queue is destroyed.
+func (pq *PriorityQueue) OldestN(n int) []QItem {
+	defer pq.lock(OpStats)()
+	if pq.destroyed || n <= 0 {
+		return nil
+	}
+	if n > len(pq.byAge) {
+		n = len(pq.byAge)
+	}
+	// Walk the heap from its root, taking the oldest of the items whose
+	// parent was taken
+	oldest := make([]QItem, 0, n)
+	next := ageFrontier{index: pq.byAge}
+	if n > 0 {
+		next.positions = []int{0}
+	}
+	for len(oldest) < n {
+		at := heap.Pop(&next).(int)
+		oldest = append(oldest, *pq.byAge[at])
+		for _, child := range []int{2*at + 1, 2*at + 2} {
+			if child < len(pq.byAge) {
+				heap.Push(&next, child)
+			}
+		}
+	}
+	return oldest
+}
+
+// An ageFrontier is a heap of positions in an ageIndex, the oldest item first
+type ageFrontier struct {
+	index     ageIndex
+	positions []int
+}
+
+func (f ageFrontier) Len() int { return len(f.positions) }
+func (f ageFrontier) Less(i, j int) bool {
+	return f.index.Less(f.positions[i], f.positions[j])
+}
+func (f ageFrontier) Swap(i, j int) {
+	f.positions[i], f.positions[j] = f.positions[j], f.positions[i]
+}
+func (f *ageFrontier) Push(x any) { f.positions = append(f.positions, x.(int)) }
+func (f *ageFrontier) Pop() any {
+	n := len(f.positions)
+	x := f.positions[n-1]
+	f.positions = f.positions[:n-1]
+	return x
+}
@@ -0,0 +1,41 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_OldestN(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, len(pq.OldestN(3)), 0)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for n, id := range []string{"e", "b", "d", "a", "c", "f"} {
+		// a is the oldest, f as old as e but queued after it
+		age := map[string]int{"a": 0, "b": 1, "c": 2, "d": 3, "e": 4, "f": 4}[id]
+		pq.Push(QItem{ID: id, Priority: n, PushedAt: start.Add(time.Duration(age) * time.Minute)})
+	}
+	ids := func(items []QItem) (s string) {
+		for _, i := range items {
+			s += i.ID
+		}
+		return s
+	}
+	assertEqual(t, ids(pq.OldestN(3)), "abc")
+	assertEqual(t, ids(pq.OldestN(10)), "abcdef")
+
+	// Popped and deleted items leave the index
+	pq.Pop()
+	pq.DeleteItemById("a")
+	pq.DeleteItemsByParentId("")
+	assertEqual(t, ids(pq.OldestN(10)), "")
+	pq.Push(QItem{ID: "g", PushedAt: start})
+	pq.Push(QItem{ID: "h"})
+	assertEqual(t, ids(pq.OldestN(10)), "gh")
+	if err := pq.Healthy(); err != nil {
+		t.Errorf("Unhealthy age index: %v", err)
+	}
+
+	pq.Destroy()
+	assertEqual(t, pq.OldestN(3) == nil, true)
+}
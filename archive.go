@@ -0,0 +1,97 @@
+package priorityqueue
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// An Archiver stores the items a queue removes without handing them out,
+// those that expire or are deleted by Clear, a delete method, a flush or a
+// retention rule, so removed work can be analysed later instead of
+// vanishing. Archive is called after the queue lock has been released, on
+// the goroutine that removed the items, possibly concurrently.
+type Archiver interface {
+	Archive(items []ArchivedItem) error
+}
+
+// An ArchiverFunc is an Archiver calling the function
+type ArchiverFunc func(items []ArchivedItem) error
+
+func (f ArchiverFunc) Archive(items []ArchivedItem) error {
+	return f(items)
+}
+
+// An ArchivedItem is an item removed from the queue
+type ArchivedItem struct {
+	Item  QItem
+	State State     // StateExpired or StateDeleted
+	At    time.Time // When the item was removed
+}
+
+// SetArchiver installs a to receive the items removed from the queue, in
+// batches of the items removed by one operation. Items the Archiver fails
+// to store are counted in Stats().ArchiveFailures. Passing nil removes the
+// current Archiver.
+func (pq *PriorityQueue) SetArchiver(a Archiver) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.archiver = a
+}
+
+// archive queues a copy of item, which moved to state, for the Archiver.
+// The queue lock must be held.
+func (pq *PriorityQueue) archive(item *QItem, state State) {
+	if pq.archiver == nil || (state != StateExpired && state != StateDeleted) {
+		return
+	}
+	i := *item
+	i.state, i.index = state, -1
+	pq.archived = append(pq.archived, ArchivedItem{Item: i, State: state, At: pq.now()})
+}
+
+// sendArchived hands items to a, counting the failures. It is called after
+// the queue lock has been released.
+func (pq *PriorityQueue) sendArchived(a Archiver, items []ArchivedItem) {
+	if err := a.Archive(items); err != nil {
+		pq.m.Lock()
+		pq.archiveFailures += len(items)
+		pq.m.Unlock()
+	}
+}
+
+// NewNDJSONArchiver returns an Archiver writing each item to w as one JSON
+// object per line, in the format of ExportNDJSON with the "state" and
+// "archived_at" of the removal added, ready to be shipped to object storage
+// or a warehouse.
+func NewNDJSONArchiver(w io.Writer) Archiver {
+	return &ndjsonArchiver{enc: json.NewEncoder(w)}
+}
+
+type ndjsonArchiver struct {
+	m   sync.Mutex
+	enc *json.Encoder
+}
+
+type archivedRecord struct {
+	itemRecord
+	State      string    `json:"state"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+func (a *ndjsonArchiver) Archive(items []ArchivedItem) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+	for n := range items {
+		rec := archivedRecord{
+			itemRecord: toItemRecord(&items[n].Item),
+			State:      items[n].State.String(),
+			ArchivedAt: items[n].At,
+		}
+		if err := a.enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Archiver(t *testing.T) {
+	pq := NewPriorityQueue()
+	var batches [][]ArchivedItem
+	pq.SetArchiver(ArchiverFunc(func(items []ArchivedItem) error {
+		batches = append(batches, items)
+		return nil
+	}))
+	assertEqual(t, pq.Capabilities().Archive, true)
+	populateQueue(pq, 5)
+	pq.Push(QItem{ID: "expiring", ExpiresAt: time.Now().Add(-time.Second)})
+	pq.Pop()
+
+	pq.DeleteItemById("0")
+	pq.FlushBelowPercentile(0.5, FlushDrop)
+	pq.Len()
+
+	assertEqual(t, len(batches), 3)
+	assertEqual(t, batches[0][0].Item.ID, "expiring")
+	assertEqual(t, batches[0][0].State, StateExpired)
+	assertEqual(t, batches[1][0].Item.ID, "0")
+	assertEqual(t, batches[1][0].State, StateDeleted)
+	assertEqual(t, batches[2][0].Item.ID, "1")
+
+	failed := NewPriorityQueue()
+	failed.SetArchiver(ArchiverFunc(func([]ArchivedItem) error { return errors.New("failed") }))
+	populateQueue(failed, 3)
+	failed.Clear()
+	assertEqual(t, failed.Stats().ArchiveFailures, 3)
+}
+
+func Test_NDJSONArchiver(t *testing.T) {
+	var buf bytes.Buffer
+	pq := NewPriorityQueue()
+	pq.SetArchiver(NewNDJSONArchiver(&buf))
+	pq.Push(QItem{ID: "0", ParentID: "p", Priority: 3})
+	pq.DeleteItemById("0")
+
+	var rec struct {
+		ID       string `json:"id"`
+		Priority int    `json:"priority"`
+		State    string `json:"state"`
+	}
+	assertEqual(t, json.Unmarshal(buf.Bytes(), &rec), nil)
+	assertEqual(t, rec.ID, "0")
+	assertEqual(t, rec.Priority, 3)
+	assertEqual(t, rec.State, "deleted")
+}
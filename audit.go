@@ -0,0 +1,52 @@
+package priorityqueue
+
+import (
+	"time"
+)
+
+// An AuditEntry records a change made to one item of the queue, or to its
+// configuration
+type AuditEntry struct {
+	Time     time.Time
+	Op       Operation
+	ID       string
+	ParentID string
+	Tenant   string
+	Priority int
+	Producer string
+
+	// Detail describes a change not made to an item, such as the
+	// ConfigUpdate applied by UpdateConfig
+	Detail string `json:",omitempty"`
+
+	// Position is the Position of the queue once the change was made
+	Position uint64 `json:",omitempty"`
+}
+
+// SetAuditLog installs fn to be called with an entry for every item pushed,
+// popped, updated or removed, and for every UpdateConfig. Entries are delivered in order, after the
+// queue lock has been released, on the goroutine that made the change.
+// Passing nil removes the current audit log.
+func (pq *PriorityQueue) SetAuditLog(fn func(AuditEntry)) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.auditLog = fn
+}
+
+// audit queues an entry for item; the queue lock must be held
+func (pq *PriorityQueue) audit(op Operation, item *QItem) {
+	pq.position++
+	if !pq.auditing() {
+		return
+	}
+	pq.auditEntries = append(pq.auditEntries, AuditEntry{
+		Time:     time.Now(),
+		Op:       op,
+		ID:       item.ID,
+		ParentID: item.ParentID,
+		Tenant:   item.Tenant,
+		Priority: item.Priority,
+		Producer: item.Producer,
+		Position: pq.position,
+	})
+}
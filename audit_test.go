@@ -0,0 +1,34 @@
+package priorityqueue
+
+import (
+	"testing"
+)
+
+func Test_AuditLog(t *testing.T) {
+	pq := NewPriorityQueue()
+	var entries []AuditEntry
+	pq.SetAuditLog(func(e AuditEntry) {
+		// The queue is unlocked by the time entries are delivered
+		pq.Len()
+		entries = append(entries, e)
+	})
+
+	pq.Push(QItem{ID: "a", ParentID: "p", Priority: 1, Producer: "svc-a"})
+	pq.Push(QItem{ID: "b", ParentID: "p", Priority: 2, Producer: "svc-b"})
+	pq.UpdatePriorityByParentId("p", 5)
+	pq.Pop()
+	pq.DeleteItemById("a")
+
+	ops := []Operation{OpPush, OpPush, OpUpdatePriorityByParentId, OpUpdatePriorityByParentId, OpPop, OpDeleteItemById}
+	assertEqual(t, len(entries), len(ops))
+	for n, op := range ops {
+		assertEqual(t, entries[n].Op, op)
+	}
+	assertEqual(t, entries[0].Producer, "svc-a")
+	assertEqual(t, entries[5].ID, "a")
+	assertEqual(t, entries[5].Priority, 5)
+
+	pq.SetAuditLog(nil)
+	pq.Push(QItem{ID: "c"})
+	assertEqual(t, len(entries), len(ops))
+}
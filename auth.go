@@ -0,0 +1,61 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnauthorized is the error Authorizers are expected to return, or wrap,
+// when they deny an operation.
+var ErrUnauthorized = errors.New("operation not authorized")
+
+// An Authorizer decides whether principal may apply op to item. Returning a
+// non-nil error denies the operation and the error is returned to the
+// caller. The principal is the one attached to the operation's context with
+// WithPrincipal, nil if there is none.
+//
+// Authorizers are consulted for the pushes, priority updates and deletes,
+// Clear, Seed, Reconcile, Split, Reshard, ForceRelease, Steal,
+// SetParentPriority, RedriveDeadLetters and the restores and imports, once
+// per affected item, with the queue locked; they must not call back into
+// the queue. An operation affecting several items applies to none of them
+// if any is denied. Methods without a Ctx variant act on behalf of no
+// principal; so do QueueViews unless given one with WithContext.
+type Authorizer func(principal interface{}, op Operation, item *QItem) error
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying principal, for use with the Ctx
+// variants of the queue methods.
+func WithPrincipal(ctx context.Context, principal interface{}) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal attached with WithPrincipal
+func PrincipalFromContext(ctx context.Context) (interface{}, bool) {
+	p := ctx.Value(principalKey{})
+	return p, p != nil
+}
+
+// SetAuthorizer installs a to vet mutations of the queue. Passing nil
+// removes the current authorizer.
+func (pq *PriorityQueue) SetAuthorizer(a Authorizer) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.authorizer = a
+}
+
+// authorize asks the authorizer whether the principal of ctx may apply op
+// to every one of items. The queue lock must be held.
+func (pq *PriorityQueue) authorize(ctx context.Context, op Operation, items ...*QItem) error {
+	if pq.authorizer == nil {
+		return nil
+	}
+	principal, _ := PrincipalFromContext(ctx)
+	for _, item := range items {
+		if err := pq.authorizer(principal, op, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
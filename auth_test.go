@@ -0,0 +1,93 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// tenantAuthorizer lets principals mutate only their own tenant's items
+func tenantAuthorizer(principal interface{}, op Operation, item *QItem) error {
+	if principal == nil || principal.(string) != item.Tenant {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+func Test_AuthorizerDeniesPush(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetAuthorizer(tenantAuthorizer)
+	alice := WithPrincipal(context.Background(), "alice")
+
+	assertEqual(t, pq.PushCtx(alice, QItem{ID: "1", Tenant: "alice"}), nil)
+	assertEqual(t, pq.PushCtx(alice, QItem{ID: "2", Tenant: "bob"}), ErrUnauthorized)
+	assertEqual(t, pq.Push(QItem{ID: "3", Tenant: "alice"}), ErrUnauthorized)
+	assertEqual(t, pq.Len(), 1)
+}
+
+func Test_AuthorizerDeniesBulkOperations(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "1", ParentID: "p", Tenant: "alice"})
+	pq.Push(QItem{ID: "2", ParentID: "p", Tenant: "bob"})
+	pq.SetAuthorizer(tenantAuthorizer)
+	alice := WithPrincipal(context.Background(), "alice")
+
+	n, err := pq.UpdatePriorityByParentIdCtx(alice, "p", 10)
+	assertEqual(t, n, 0)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+	assertEqual(t, pq.UpdatePriorityByParentId("p", 10), 0)
+
+	n, err = pq.DeleteItemsByParentIdCtx(alice, "p")
+	assertEqual(t, n, 0)
+	assertEqual(t, err, ErrUnauthorized)
+	assertEqual(t, pq.Len(), 2)
+
+	assertEqual(t, pq.DeleteItemByIdCtx(alice, "2"), ErrUnauthorized)
+	assertEqual(t, pq.DeleteItemByIdCtx(alice, "1"), nil)
+
+	pq.SetAuthorizer(nil)
+	n, _ = pq.DeleteItemsByParentId("p")
+	assertEqual(t, n, 1)
+}
+
+func Test_AuthorizerDeniesAdministration(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "1", ParentID: "p", Tenant: "alice"})
+	pq.Push(QItem{ID: "2", ParentID: "p", Tenant: "bob"})
+	var snapshot bytes.Buffer
+	pq.Snapshot(&snapshot)
+	pq.SetAuthorizer(tenantAuthorizer)
+	alice := WithPrincipal(context.Background(), "alice")
+
+	assertEqual(t, pq.ClearCtx(alice), ErrUnauthorized)
+	pq.Clear()
+	assertEqual(t, pq.Len(), 2)
+	assertEqual(t, pq.SetParentPriority("p", 10), 0)
+	first, _ := pq.Split(func(QItem) bool { return true })
+	assertEqual(t, first.Len(), 0)
+	assertEqual(t, pq.Restore(bytes.NewReader(snapshot.Bytes())), ErrUnauthorized)
+	_, err := pq.Seed(alice, func(context.Context) ([]QItem, error) {
+		return []QItem{{ID: "3", Tenant: "bob"}}, nil
+	}, SeedSkip)
+	assertEqual(t, err, ErrUnauthorized)
+	_, err = pq.Reconcile(alice, nil, ReconcileOptions{})
+	assertEqual(t, err, ErrUnauthorized)
+	assertEqual(t, pq.Len(), 2)
+
+	// Views act on behalf of no principal unless given one
+	pq.Scoped("alice").Clear()
+	assertEqual(t, pq.Len(), 2)
+	pq.Scoped("alice").WithContext(alice).Clear()
+	assertEqual(t, pq.Len(), 1)
+}
+
+func Test_PrincipalFromContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assertEqual(t, ok, false)
+	p, ok := PrincipalFromContext(WithPrincipal(context.Background(), "svc"))
+	assertEqual(t, ok, true)
+	assertEqual(t, p, "svc")
+}
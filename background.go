@@ -0,0 +1,147 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Go runs fn on a goroutine owned by the queue, passing it a context that
+// is canceled by Stop or Destroy, so the workers, exporters and sweeps
+// serving a queue stop with it:
+//
+//	pq.Go(NewDispatcher(pq, "jobs", 4, handle).Run)
+//
+// An error other than the cancellation of its context is reported by Stop.
+// Go does nothing on a queue that is not Running.
+func (pq *PriorityQueue) Go(fn func(ctx context.Context) error) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.spawn(pq.background(), fn)
+}
+
+// background returns the context of the goroutines owned by the queue. The
+// queue lock must be held.
+func (pq *PriorityQueue) background() context.Context {
+	if pq.bgCtx == nil {
+		pq.bgCtx, pq.bgCancel = context.WithCancel(context.Background())
+	}
+	return pq.bgCtx
+}
+
+// spawn runs fn on a goroutine owned by the queue. The queue lock must be
+// held.
+func (pq *PriorityQueue) spawn(ctx context.Context, fn func(ctx context.Context) error) {
+	if pq.stopped {
+		return
+	}
+	pq.bg.Add(1)
+	go func() {
+		defer pq.bg.Done()
+		if err := fn(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			pq.m.Lock()
+			if pq.bgErr == nil {
+				pq.bgErr = err
+			}
+			pq.m.Unlock()
+		}
+	}()
+}
+
+// Stop cancels the goroutines owned by the queue, waits for them to return
+// until ctx is done, then flushes the recording started by Record and
+// writes the files configured by WithSnapshotFile and WithRecordingFile,
+// and runs the finalizers, see AddFinalizer. It returns the first error of
+// a goroutine, of the persistence or of a finalizer. The queue keeps
+// serving calls but no longer runs anything in the background.
+func (pq *PriorityQueue) Stop(ctx context.Context) error {
+	pq.m.Lock()
+	pq.halt()
+	pq.m.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		pq.bg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	pq.m.Lock()
+	err := pq.bgErr
+	if r := pq.recorder; r != nil {
+		if ferr := r.w.Flush(); r.err == nil {
+			r.err = ferr
+		}
+		if err == nil {
+			err = r.err
+		}
+	}
+	hooks := pq.atStop
+	pq.atStop = nil
+	pq.m.Unlock()
+
+	for _, fn := range hooks {
+		if herr := fn(); err == nil {
+			err = herr
+		}
+	}
+	if ferr := pq.finalize(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// halt cancels the goroutines owned by the queue for good. The queue lock
+// must be held.
+func (pq *PriorityQueue) halt() {
+	pq.stopped = true
+	if pq.bgCancel != nil {
+		pq.bgCancel()
+	}
+}
+
+// Running reports whether the queue is neither stopped nor destroyed
+func (pq *PriorityQueue) Running() bool {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	return !pq.stopped
+}
+
+// SetSweepInterval makes a goroutine owned by the queue act every interval
+// on the delayed items that fell due, the expired items and the expired
+// leases, which otherwise waits for the next call to the queue. Zero, the
+// default, stops sweeping.
+func (pq *PriorityQueue) SetSweepInterval(interval time.Duration) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.setSweepInterval(interval)
+}
+
+// setSweepInterval is SetSweepInterval; the queue lock must be held
+func (pq *PriorityQueue) setSweepInterval(interval time.Duration) {
+	if pq.stopSweep != nil {
+		pq.stopSweep()
+		pq.stopSweep = nil
+	}
+	if interval <= 0 || pq.stopped {
+		return
+	}
+	ctx, cancel := context.WithCancel(pq.background())
+	pq.stopSweep = cancel
+	pq.spawn(ctx, func(ctx context.Context) error {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				pq.lock(OpSweep)()
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+}
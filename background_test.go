@@ -0,0 +1,82 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Stop(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, pq.Running(), true)
+
+	var buf bytes.Buffer
+	pq.Record(&buf)
+	populateQueue(pq, 2)
+	popped := make(chan *QItem, 2)
+	pq.Go(NewDispatcher(pq, "test", 2, func(ctx context.Context, item *QItem) error {
+		popped <- item
+		return nil
+	}).Run)
+	<-popped
+	<-popped
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pq.Stop(ctx); err != nil {
+		t.Errorf("Error stopping the queue: %v", err)
+	}
+	assertEqual(t, pq.Running(), false)
+	if buf.Len() == 0 {
+		t.Errorf("Error, Stop did not flush the recording")
+	}
+
+	// Nothing runs once stopped
+	ran := false
+	pq.Go(func(context.Context) error { ran = true; return nil })
+	pq.Stop(ctx)
+	assertEqual(t, ran, false)
+}
+
+func Test_StopErrors(t *testing.T) {
+	pq := NewPriorityQueue()
+	failed := errors.New("failed")
+	pq.Go(func(context.Context) error { return failed })
+	pq.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := pq.Stop(context.Background()); err != failed {
+		t.Errorf("Error stopping the queue: %v", err)
+	}
+
+	stuck := NewPriorityQueue()
+	release := make(chan struct{})
+	defer close(release)
+	stuck.Go(func(context.Context) error { <-release; return nil })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := stuck.Stop(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Error stopping a stuck queue: %v", err)
+	}
+}
+
+func Test_SweepInterval(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "0", ExpiresAt: time.Now().Add(10 * time.Millisecond)})
+	expired := make(chan ParentProgress, 1)
+	pq.OnParentDone(func(p ParentProgress) { expired <- p })
+	pq.SetSweepInterval(5 * time.Millisecond)
+
+	select {
+	case p := <-expired:
+		assertEqual(t, p.Dropped, 1)
+	case <-time.After(time.Second):
+		t.Errorf("Error, the sweep did not drop the expired item")
+	}
+	pq.Destroy()
+	assertEqual(t, pq.Running(), false)
+	pq.Stop(context.Background())
+}
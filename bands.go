@@ -0,0 +1,162 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Band is a range of priorities served by a queue of its own
+type Band struct {
+	Name string
+	Min  int // Lowest priority of the band; the band ends where the next starts
+
+	// Weight is the share of the pops of the BandedQueue served by the band
+	// while it has items, see BandedQueue.Pop.
+	Weight int
+}
+
+// A BandedQueue splits the items pushed to it by priority band into one
+// PriorityQueue per band, for example bulk from 0, normal from 10 and
+// urgent from 100. Each band queue is consumed, rate limited and monitored
+// independently; get it with Band.
+type BandedQueue struct {
+	bands  []Band
+	queues []*PriorityQueue
+
+	m   sync.Mutex // Guards rnd
+	rnd *rand.Rand
+}
+
+// NewBandedQueue returns a BandedQueue with the given bands, in any order.
+// Items below the lowest band's Min go to the lowest band.
+func NewBandedQueue(bands ...Band) (*BandedQueue, error) {
+	if len(bands) == 0 {
+		return nil, fmt.Errorf("at least one band is needed")
+	}
+	bands = append([]Band(nil), bands...)
+	sort.Slice(bands, func(i, j int) bool { return bands[i].Min < bands[j].Min })
+	bq := &BandedQueue{bands: bands, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	names := make(map[string]bool)
+	for n, b := range bands {
+		if names[b.Name] {
+			return nil, fmt.Errorf("duplicate band name: [%s]", b.Name)
+		}
+		if b.Weight < 0 {
+			return nil, fmt.Errorf("band [%s] has a negative weight", b.Name)
+		}
+		if n > 0 && b.Min == bands[n-1].Min {
+			return nil, fmt.Errorf("bands [%s] and [%s] start at the same priority", bands[n-1].Name, b.Name)
+		}
+		names[b.Name] = true
+		bq.queues = append(bq.queues, NewPriorityQueue())
+	}
+	return bq, nil
+}
+
+// band returns the index of the band of priority
+func (bq *BandedQueue) band(priority int) int {
+	n := sort.Search(len(bq.bands), func(n int) bool { return bq.bands[n].Min > priority }) - 1
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Push adds an item to the queue of its priority band
+func (bq *BandedQueue) Push(i QItem) error {
+	return bq.queues[bq.band(i.Priority)].Push(i)
+}
+
+// Band returns the queue of the band called name, nil if there is none
+func (bq *BandedQueue) Band(name string) *PriorityQueue {
+	for n, b := range bq.bands {
+		if b.Name == name {
+			return bq.queues[n]
+		}
+	}
+	return nil
+}
+
+// BandOf returns the name of the band serving priority
+func (bq *BandedQueue) BandOf(priority int) string {
+	return bq.bands[bq.band(priority)].Name
+}
+
+// Bands returns the bands, lowest first
+func (bq *BandedQueue) Bands() []Band {
+	return append([]Band(nil), bq.bands...)
+}
+
+// Len returns the number of items queued in all bands
+func (bq *BandedQueue) Len() int {
+	n := 0
+	for _, pq := range bq.queues {
+		n += pq.Len()
+	}
+	return n
+}
+
+// Pop pops an item from one of the bands holding items. Without weights the
+// highest band is served first. Once bands have a Weight, Pop picks among
+// the weighted bands holding items at random in proportion to their
+// weights, so that with urgent 80, normal 15 and bulk 5 the bulk band still
+// gets one pop in twenty while urgent work keeps arriving; bands without a
+// weight are only served, highest first, when the weighted ones are empty.
+func (bq *BandedQueue) Pop() (*QItem, error) {
+	skip := make([]bool, len(bq.queues))
+	err := ErrEmptyQueue
+	for {
+		n := bq.pick(skip)
+		if n < 0 {
+			return nil, err
+		}
+		item, perr := bq.queues[n].Pop()
+		if perr != ErrEmptyQueue && perr != ErrFrozen {
+			return item, perr
+		}
+		// Emptied by another consumer meanwhile, or frozen
+		if perr == ErrFrozen {
+			err = perr
+		}
+		skip[n] = true
+	}
+}
+
+// pick returns the index of the band Pop serves next, -1 if every band that
+// is not skipped is empty.
+func (bq *BandedQueue) pick(skip []bool) int {
+	total, strict := 0, -1
+	weights := make([]int, len(bq.queues))
+	for n, q := range bq.queues {
+		if skip[n] || q.Len() == 0 {
+			continue
+		}
+		weights[n] = bq.bands[n].Weight
+		total += weights[n]
+		strict = n
+	}
+	if total == 0 {
+		return strict
+	}
+	bq.m.Lock()
+	r := bq.rnd.Intn(total)
+	bq.m.Unlock()
+	for n, w := range weights {
+		if r < w {
+			return n
+		}
+		r -= w
+	}
+	return strict
+}
+
+// SetRandSource sets the source of the random band selection of Pop, to
+// make it reproducible in tests and simulations.
+func (bq *BandedQueue) SetRandSource(src rand.Source) {
+	bq.m.Lock()
+	defer bq.m.Unlock()
+	bq.rnd = rand.New(src)
+}
@@ -0,0 +1,107 @@
+package priorityqueue
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func Test_BandedQueue(t *testing.T) {
+	bq, err := NewBandedQueue(
+		Band{Name: "urgent", Min: 100},
+		Band{Name: "bulk", Min: 0},
+		Band{Name: "normal", Min: 10},
+	)
+	if err != nil {
+		t.Errorf("Error creating banded queue: %v", err)
+		return
+	}
+	for _, p := range []int{-5, 0, 9, 10, 99, 100, 1000} {
+		bq.Push(QItem{ID: "x", Priority: p})
+	}
+
+	assertEqual(t, bq.Band("bulk").Len(), 3)
+	assertEqual(t, bq.Band("normal").Len(), 2)
+	assertEqual(t, bq.Band("urgent").Len(), 2)
+	assertEqual(t, bq.Len(), 7)
+	assertEqual(t, bq.BandOf(50), "normal")
+	assertEqual(t, bq.Bands()[0].Name, "bulk")
+	if bq.Band("missing") != nil {
+		t.Errorf("Error looking up a missing band")
+	}
+}
+
+func Test_BandedQueueLimits(t *testing.T) {
+	bq, _ := NewBandedQueue(Band{Name: "bulk"}, Band{Name: "urgent", Min: 100})
+	bq.Band("bulk").SetProducerLimit("", ProducerLimit{Quota: 1})
+
+	if err := bq.Push(QItem{ID: "1"}); err != nil {
+		t.Errorf("Error pushing item: %v", err)
+	}
+	if err := bq.Push(QItem{ID: "2"}); err == nil {
+		t.Errorf("Error: the bulk band quota was not applied")
+	}
+	if err := bq.Push(QItem{ID: "3", Priority: 100}); err != nil {
+		t.Errorf("Error pushing urgent item: %v", err)
+	}
+}
+
+func Test_BandedQueueInvalid(t *testing.T) {
+	if _, err := NewBandedQueue(); err == nil {
+		t.Errorf("Error: no bands accepted")
+	}
+	if _, err := NewBandedQueue(Band{Name: "a"}, Band{Name: "a", Min: 1}); err == nil {
+		t.Errorf("Error: duplicate names accepted")
+	}
+	if _, err := NewBandedQueue(Band{Name: "a"}, Band{Name: "b"}); err == nil {
+		t.Errorf("Error: overlapping bands accepted")
+	}
+}
+
+func Test_BandedQueuePop(t *testing.T) {
+	bq, _ := NewBandedQueue(Band{Name: "bulk"}, Band{Name: "urgent", Min: 100})
+	bq.Push(QItem{ID: "b", Priority: 1})
+	bq.Push(QItem{ID: "u", Priority: 100})
+	item, _ := bq.Pop()
+	assertEqual(t, item.ID, "u")
+	item, _ = bq.Pop()
+	assertEqual(t, item.ID, "b")
+	_, err := bq.Pop()
+	assertEqual(t, err, ErrEmptyQueue)
+}
+
+func Test_BandedQueueWeightedPop(t *testing.T) {
+	bq, _ := NewBandedQueue(
+		Band{Name: "bulk", Weight: 5},
+		Band{Name: "normal", Min: 10, Weight: 15},
+		Band{Name: "urgent", Min: 100, Weight: 80},
+		Band{Name: "spare", Min: 1000},
+	)
+	bq.SetRandSource(rand.NewSource(1))
+	for n := 0; n < 1000; n++ {
+		for _, p := range []int{1, 10, 100} {
+			bq.Push(QItem{ID: strconv.Itoa(n), Priority: p})
+		}
+	}
+	bq.Push(QItem{ID: "spare", Priority: 1000})
+
+	served := make(map[string]int)
+	for n := 0; n < 1000; n++ {
+		item, _ := bq.Pop()
+		served[bq.BandOf(item.Priority)]++
+	}
+	// Every band gets pops, roughly in proportion to its weight
+	if served["bulk"] < 25 || served["bulk"] > 75 || served["urgent"] < 750 || served["urgent"] > 850 {
+		t.Errorf("Unexpected pops per band: %v", served)
+	}
+	assertEqual(t, served["spare"], 0)
+
+	for bq.Band("bulk").Len()+bq.Band("normal").Len()+bq.Band("urgent").Len() > 0 {
+		bq.Pop()
+	}
+	item, _ := bq.Pop()
+	assertEqual(t, item.ID, "spare")
+
+	_, err := NewBandedQueue(Band{Name: "a", Weight: -1})
+	assertEqual(t, err != nil, true)
+}
@@ -0,0 +1,104 @@
+package priorityqueue
+
+// barriers tracks the barriers pushed for one ParentID. Every item of the
+// parent belongs to the phase current when it was pushed, and only the
+// oldest phase with live items can be popped.
+type barriers struct {
+	phase int         // Number of barriers pushed so far
+	live  map[int]int // Items queued, delayed or in flight per phase
+}
+
+// lowest returns the oldest phase still holding items
+func (b *barriers) lowest() int {
+	low := b.phase
+	for phase, n := range b.live {
+		if n > 0 && phase < low {
+			low = phase
+		}
+	}
+	return low
+}
+
+// PushBarrier pushes a barrier for parentID: items of the parent pushed
+// afterwards cannot be popped until every item of the parent pushed before
+// it has been acked, or otherwise left the queue. Barriers can be stacked to
+// run a pipeline in phases.
+func (pq *PriorityQueue) PushBarrier(parentID string) {
+	defer pq.lock(OpPushBarrier)()
+	key := pq.idKey(parentID)
+	b := pq.barriers[key]
+	if b == nil {
+		if pq.barriers == nil {
+			pq.barriers = make(map[string]*barriers)
+		}
+		b = &barriers{live: make(map[int]int)}
+		pq.barriers[key] = b
+		// Items pushed before the first barrier are in phase 0
+		n := len(pq.byParent[key]) + len(pq.parentLeases(parentID))
+		for _, t := range pq.delayed {
+			if pq.sameID(t.v.ParentID, parentID) {
+				n++
+			}
+		}
+		for _, i := range pq.coalescing {
+			if pq.sameID(i.ParentID, parentID) {
+				n++
+			}
+		}
+		if n > 0 {
+			b.live[0] = n
+		}
+	}
+	b.phase++
+}
+
+// parentLeases returns the leases of items of parentID. The queue lock must
+// be held.
+func (pq *PriorityQueue) parentLeases(parentID string) []*lease {
+	var leases []*lease
+	for _, l := range pq.leases {
+		if pq.sameID(l.item.ParentID, parentID) {
+			leases = append(leases, l)
+		}
+	}
+	return leases
+}
+
+// assignPhase puts an item being pushed in the current phase of its parent.
+// The queue lock must be held.
+func (pq *PriorityQueue) assignPhase(i *QItem) {
+	i.phase = 0
+	if b := pq.barriers[pq.idKey(i.ParentID)]; b != nil {
+		i.phase = b.phase
+	}
+}
+
+// blocked reports whether a barrier holds item back. The queue lock must be
+// held.
+func (pq *PriorityQueue) blocked(item *QItem) bool {
+	b := pq.barriers[pq.idKey(item.ParentID)]
+	return b != nil && item.phase > b.lowest()
+}
+
+// barrierProgress counts the live items of each phase for the transition of
+// item from one state to another. The queue lock must be held.
+func (pq *PriorityQueue) barrierProgress(item *QItem, from, to State) {
+	b := pq.barriers[pq.idKey(item.ParentID)]
+	if b == nil || from.live() == to.live() {
+		return
+	}
+	if to.live() {
+		b.live[item.phase]++
+		return
+	}
+	b.live[item.phase]--
+	if b.live[item.phase] > 0 {
+		return
+	}
+	delete(b.live, item.phase)
+	if len(b.live) == 0 {
+		// Nothing left behind any barrier, phases can start over
+		delete(pq.barriers, item.ParentID)
+	}
+	pq.wake()
+}
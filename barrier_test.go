@@ -0,0 +1,74 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Barrier(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "a1", ParentID: "job", Priority: 1})
+	pq.Push(QItem{ID: "a2", ParentID: "job", Priority: 2})
+	pq.PushBarrier("job")
+	pq.Push(QItem{ID: "b1", ParentID: "job", Priority: 10})
+	pq.Push(QItem{ID: "x", ParentID: "other", Priority: 5})
+
+	item, r1, _ := pq.Lease(time.Minute)
+	assertEqual(t, item.ID, "x")
+	item, r2, _ := pq.Lease(time.Minute)
+	assertEqual(t, item.ID, "a2")
+	item, r3, _ := pq.Lease(time.Minute)
+	assertEqual(t, item.ID, "a1")
+	if _, _, err := pq.Lease(time.Minute); err != ErrEmptyQueue {
+		t.Errorf("Error leasing behind a barrier: %v", err)
+	}
+
+	pq.Ack(r1)
+	pq.Ack(r2)
+	if _, err := pq.Peek(); err != ErrEmptyQueue {
+		t.Errorf("Error peeking behind a barrier: %v", err)
+	}
+	pq.Ack(r3)
+	item, _ = pq.Peek()
+	assertEqual(t, item.ID, "b1")
+}
+
+func Test_StackedBarriers(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.PushBarrier("job") // nothing before it
+	pq.Push(QItem{ID: "a", ParentID: "job", Priority: 1})
+	pq.PushBarrier("job")
+	pq.Push(QItem{ID: "b", ParentID: "job", Priority: 2})
+	pq.PushBarrier("job")
+	pq.Push(QItem{ID: "c", ParentID: "job", Priority: 3})
+
+	var ids []string
+	for {
+		_, r, err := pq.Lease(time.Minute)
+		if err != nil {
+			break
+		}
+		ids = append(ids, r.ID)
+		pq.Ack(r)
+	}
+	assertEqual(t, len(ids), 3)
+	assertEqual(t, ids[0]+ids[1]+ids[2], "abc")
+	assertEqual(t, len(pq.barriers), 0)
+}
+
+func Test_BarrierReleasedByNack(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetMaxAttempts(1)
+	pq.Push(QItem{ID: "a", ParentID: "job"})
+	pq.PushBarrier("job")
+	pq.Push(QItem{ID: "b", ParentID: "job"})
+
+	_, r, _ := pq.Lease(time.Minute)
+	pq.Nack(r, 0) // dead-lettered after its only attempt
+	item, err := pq.Pop()
+	if err != nil {
+		t.Errorf("Error popping after the barrier cleared: %v", err)
+		return
+	}
+	assertEqual(t, item.ID, "b")
+}
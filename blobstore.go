@@ -0,0 +1,209 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A BlobStore is an ObjectStore that can also delete objects, keeping the
+// Values offloaded by SetBlobStore, see the FileStore and pqs3.Store.
+type BlobStore interface {
+	ObjectStore
+
+	// Delete removes the object stored under key, if any
+	Delete(ctx context.Context, key string) error
+}
+
+// blobPrefix starts the references to offloaded Values stored in their
+// place, followed by 'b' for a []byte or 's' for a string and the key.
+// Unlike a struct they survive snapshots, which write Values as JSON.
+const blobPrefix = "\x00blob:"
+
+// SetBlobStore makes Push write the Values of type []byte or string longer
+// than threshold bytes, once transformed, to store, keeping only a
+// reference to them in the queue, so occasional huge payloads do not bloat
+// the heap. Pop, Lease and Reserve hand the items out with their Value read
+// back; Peek, the views and snapshots show the reference. The Value is
+// deleted from store once the queue is done with the item, unless it is
+// kept in the recycle bin. A nil store, the default, keeps Values in the
+// queue.
+func (pq *PriorityQueue) SetBlobStore(store BlobStore, threshold int) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.blobStore, pq.blobThreshold = store, threshold
+}
+
+// WithBlobStore is SetBlobStore
+func WithBlobStore(store BlobStore, threshold int) Option {
+	return func(pq *PriorityQueue) error {
+		if threshold < 0 {
+			return fmt.Errorf("blob threshold %d is negative", threshold)
+		}
+		pq.SetBlobStore(store, threshold)
+		return nil
+	}
+}
+
+// offload writes the Value of an item being pushed to the blob store if it
+// is too long, replacing it with a reference. The queue lock must be held.
+func (pq *PriorityQueue) offload(i *QItem) error {
+	if pq.blobStore == nil {
+		return nil
+	}
+	var b []byte
+	kind := "b"
+	switch v := i.Value.(type) {
+	case []byte:
+		b = v
+	case string:
+		if strings.HasPrefix(v, blobPrefix) {
+			return nil
+		}
+		b, kind = []byte(v), "s"
+	default:
+		return nil
+	}
+	if len(b) <= pq.blobThreshold {
+		return nil
+	}
+	key := NewULID()
+	if err := pq.blobStore.Put(context.Background(), key, bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("offloading the value of [%s]: %w", i.ID, err)
+	}
+	i.Value = blobPrefix + kind + key
+	return nil
+}
+
+// rehydrate reads back the offloaded Value of an item being handed out.
+// The queue lock must be held.
+func (pq *PriorityQueue) rehydrate(i *QItem) error {
+	kind, key, ok := blobRef(i.Value)
+	if !ok || pq.blobStore == nil {
+		return nil
+	}
+	r, err := pq.blobStore.Get(context.Background(), key)
+	if err != nil {
+		return fmt.Errorf("reading the value of [%s]: %w", i.ID, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading the value of [%s]: %w", i.ID, err)
+	}
+	if kind == 's' {
+		i.Value = string(b)
+	} else {
+		i.Value = b
+	}
+	return nil
+}
+
+// dropBlob deletes the offloaded Value v, if it is one, once the queue lock
+// is released. The queue lock must be held.
+func (pq *PriorityQueue) dropBlob(v interface{}) {
+	_, key, ok := blobRef(v)
+	if !ok || pq.blobStore == nil {
+		return
+	}
+	store := pq.blobStore
+	pq.deferred = append(pq.deferred, func() {
+		store.Delete(context.Background(), key)
+	})
+}
+
+// blobRef returns the kind and key of a reference to an offloaded Value
+func blobRef(v interface{}) (byte, string, bool) {
+	s, ok := v.(string)
+	if !ok || !strings.HasPrefix(s, blobPrefix) || len(s) < len(blobPrefix)+2 {
+		return 0, "", false
+	}
+	return s[len(blobPrefix)], s[len(blobPrefix)+1:], true
+}
+
+// A FileStore is a BlobStore, and an ObjectStore for snapshots, keeping
+// objects as files in a directory. Keys name files relative to it.
+type FileStore struct {
+	dir string
+}
+
+var _ BlobStore = (*FileStore)(nil)
+
+// NewFileStore returns a FileStore keeping its objects in dir, which is
+// created if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the file of the object key
+func (s *FileStore) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid object key [%s]", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes what r reads to a temporary file renamed to the file of key
+// once complete, so readers never see a partial object
+func (s *FileStore) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: [%s]", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func Test_BlobStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pq, _ := New(WithBlobStore(store, 8))
+	big := strings.Repeat("payload ", 10)
+
+	pq.Push(QItem{ID: "string", Value: big, Priority: 4})
+	pq.Push(QItem{ID: "bytes", Value: []byte(big), Priority: 3})
+	pq.Push(QItem{ID: "small", Value: "tiny", Priority: 2})
+	pq.Push(QItem{ID: "leased", Value: big, Priority: 1})
+	assertEqual(t, countFiles(t, dir), 3)
+
+	item, _ := pq.Peek()
+	if _, _, ok := blobRef(item.Value); !ok {
+		t.Errorf("queued value %q is not a reference", item.Value)
+	}
+	item, err = pq.Pop()
+	assertEqual(t, err, nil)
+	assertEqual(t, item.Value, big)
+	item, _ = pq.Pop()
+	if !bytes.Equal(item.Value.([]byte), []byte(big)) {
+		t.Errorf("popped %v", item.Value)
+	}
+	item, _ = pq.Pop()
+	assertEqual(t, item.Value, "tiny")
+	assertEqual(t, countFiles(t, dir), 1)
+
+	item, r, err := pq.Lease(time.Minute)
+	assertEqual(t, err, nil)
+	assertEqual(t, item.Value, big)
+	assertEqual(t, countFiles(t, dir), 1)
+	pq.Ack(r)
+	assertEqual(t, countFiles(t, dir), 0)
+}
+
+func Test_BlobStoreDeletes(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	now := time.Now()
+	pq, _ := New(WithClock(func() time.Time { return now }), WithBlobStore(store, 0), WithRecycleWindow(time.Minute))
+	pq.Push(QItem{ID: "a", Value: "value", Priority: 2})
+	pq.Push(QItem{ID: "b", Value: "value"})
+
+	pq.DeleteItemById("a")
+	assertEqual(t, countFiles(t, dir), 2)
+	assertEqual(t, pq.RestoreDeleted("a"), nil)
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "a")
+	assertEqual(t, item.Value, "value")
+	assertEqual(t, countFiles(t, dir), 1)
+
+	pq.DeleteItemById("b")
+	now = now.Add(2 * time.Minute)
+	pq.Recycled()
+	assertEqual(t, countFiles(t, dir), 0)
+}
+
+func Test_FileStore(t *testing.T) {
+	store, _ := NewFileStore(t.TempDir())
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("getting a missing object: %v", err)
+	}
+	if err := store.Put(ctx, "../escape", strings.NewReader("x")); err == nil {
+		t.Error("stored outside the directory")
+	}
+
+	pq := NewPriorityQueue()
+	populateQueue(pq, 5)
+	if err := pq.SnapshotTo(ctx, store, "snapshots/latest"); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewPriorityQueue()
+	if err := restored.RestoreFrom(ctx, store, "snapshots/latest"); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, restored.Len(), 5)
+
+	r, _ := store.Get(ctx, "snapshots/latest")
+	b, _ := io.ReadAll(r)
+	r.Close()
+	if len(b) == 0 {
+		t.Error("empty object")
+	}
+	assertEqual(t, store.Delete(ctx, "snapshots/latest"), nil)
+	assertEqual(t, store.Delete(ctx, "snapshots/latest"), nil)
+}
@@ -0,0 +1,62 @@
+package priorityqueue
+
+// Capabilities lists the optional subsystems a queue supports or has
+// enabled, so generic tooling can adapt to the queue it is handed.
+type Capabilities struct {
+	Delay    bool // PushDelayed and item expiry
+	Ack      bool // Lease, Ack, Nack and Reserve
+	Snapshot bool // Snapshot and Restore
+
+	// Persistence is set when the queue is written to a snapshot file or a
+	// recording, see WithSnapshotFile and Record.
+	Persistence bool
+
+	// Fairness is set when producer limits are in force
+	Fairness bool
+
+	DeadLetters   bool // SetMaxAttempts dead-letters items
+	Dedupe        bool // SetDedupeWindow suppresses repeated items
+	Admission     bool // SetAdmissionPolicies configures Admit
+	Authorization bool // an Authorizer checks every operation
+	Audit         bool // an audit log is installed
+	Watchdog      bool // a Watchdog measures lock hold times
+	Sweep         bool // SetSweepInterval acts on idle queues
+	Retention     bool // SetRetentionPolicy sweeps the queue
+	Escalation    bool // SetEscalationRules sweeps the queue
+	Rescore       bool // SetScorer rescores the queue
+	Webhooks      bool // SetWebhooks posts lifecycle events
+	Archive       bool // an Archiver receives the removed items
+}
+
+// CapabilitiesOf returns the capabilities of q, none for a queue
+// implementation that does not report them.
+func CapabilitiesOf(q Queue) Capabilities {
+	if c, ok := q.(interface{ Capabilities() Capabilities }); ok {
+		return c.Capabilities()
+	}
+	return Capabilities{}
+}
+
+// Capabilities returns the subsystems enabled on the queue
+func (pq *PriorityQueue) Capabilities() Capabilities {
+	defer pq.lock(OpStats)()
+	return Capabilities{
+		Delay:         true,
+		Ack:           true,
+		Snapshot:      true,
+		Persistence:   pq.snapshotPath != "" || pq.recorder != nil,
+		Fairness:      len(pq.limits) > 0,
+		DeadLetters:   pq.maxAttempts > 0,
+		Dedupe:        pq.dedupeWindow > 0,
+		Admission:     len(pq.admission) > 0,
+		Authorization: pq.authorizer != nil,
+		Audit:         pq.auditLog != nil,
+		Watchdog:      pq.watchdog != nil,
+		Sweep:         pq.stopSweep != nil,
+		Retention:     pq.stopRetention != nil,
+		Escalation:    pq.stopEscalation != nil,
+		Rescore:       pq.stopRescore != nil,
+		Webhooks:      pq.webhooks != nil,
+		Archive:       pq.archiver != nil,
+	}
+}
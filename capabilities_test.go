@@ -0,0 +1,24 @@
+package priorityqueue
+
+import (
+	"io"
+	"testing"
+)
+
+func Test_Capabilities(t *testing.T) {
+	pq := NewPriorityQueue()
+	c := CapabilitiesOf(pq)
+	assertEqual(t, c.Ack, true)
+	assertEqual(t, c.Fairness, false)
+	assertEqual(t, c.Persistence, false)
+
+	pq.SetProducerLimit("bulk", ProducerLimit{Quota: 1})
+	pq.SetMaxAttempts(3)
+	pq.Record(io.Discard)
+	c = CapabilitiesOf(NewShadow(pq, NewSortedQueue()))
+	assertEqual(t, c.Fairness, true)
+	assertEqual(t, c.DeadLetters, true)
+	assertEqual(t, c.Persistence, true)
+
+	assertEqual(t, CapabilitiesOf(NewSortedQueue()), Capabilities{})
+}
@@ -0,0 +1,136 @@
+package priorityqueue
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is the error of the recording writes failed by chaos mode,
+// see SetChaos
+var ErrInjected = errors.New("fault injected by chaos mode")
+
+// Chaos sets the faults SetChaos injects, each with its probability
+// between 0 and 1, so services built on a queue can test their retry and
+// recovery logic against a misbehaving one. It is meant for tests.
+type Chaos struct {
+	// LockDelay delays an operation by up to MaxLockDelay before it takes
+	// the queue lock
+	LockDelay    float64
+	MaxLockDelay time.Duration
+
+	// Empty makes Pop, Lease and Reserve fail with ErrEmptyQueue although
+	// an item is queued
+	Empty float64
+
+	// DropAck makes Ack and AckBatch return nil without acknowledging
+	// anything, so the leases expire and the items are delivered again
+	DropAck float64
+
+	// RecordFailure fails the write of an operation to the recording,
+	// which then stops with ErrInjected as after a disk error, see Record
+	RecordFailure float64
+
+	// Seed seeds the random choice of the faults, for reproducible runs.
+	// Zero seeds it from the time.
+	Seed int64
+}
+
+// ChaosStats counts the faults injected since chaos mode was set
+type ChaosStats struct {
+	LockDelays     int
+	Empties        int
+	DroppedAcks    int
+	RecordFailures int
+}
+
+// chaos is the chaos mode of a queue. Its own lock guards it, as lock
+// delays are injected before the queue lock is taken.
+type chaos struct {
+	m     sync.Mutex
+	cfg   Chaos
+	rand  *rand.Rand
+	stats ChaosStats
+}
+
+// SetChaos turns on chaos mode with the faults of c, replacing the faults
+// set before and restarting their counts. Nil turns chaos mode off, the
+// default.
+func (pq *PriorityQueue) SetChaos(c *Chaos) {
+	if c == nil {
+		pq.chaos.Store(nil)
+		return
+	}
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	pq.chaos.Store(&chaos{cfg: *c, rand: rand.New(rand.NewSource(seed))})
+}
+
+// WithChaos is SetChaos
+func WithChaos(c Chaos) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetChaos(&c)
+		return nil
+	}
+}
+
+// ChaosStats returns the number of faults of each kind injected since
+// chaos mode was set
+func (pq *PriorityQueue) ChaosStats() ChaosStats {
+	c := pq.chaos.Load()
+	if c == nil {
+		return ChaosStats{}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.stats
+}
+
+// inject reports whether to inject a fault of probability p, counting it
+// in count
+func (c *chaos) inject(p float64, count *int) bool {
+	if p <= 0 {
+		return false
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.rand.Float64() >= p {
+		return false
+	}
+	*count++
+	return true
+}
+
+// chaosDelay sleeps before an operation takes the queue lock, if chaos
+// mode picks a lock delay
+func (pq *PriorityQueue) chaosDelay() {
+	c := pq.chaos.Load()
+	if c == nil || c.cfg.MaxLockDelay <= 0 || !c.inject(c.cfg.LockDelay, &c.stats.LockDelays) {
+		return
+	}
+	c.m.Lock()
+	d := time.Duration(c.rand.Int63n(int64(c.cfg.MaxLockDelay)) + 1)
+	c.m.Unlock()
+	time.Sleep(d)
+}
+
+// chaosEmpty reports whether to fail a pop with ErrEmptyQueue
+func (pq *PriorityQueue) chaosEmpty() bool {
+	c := pq.chaos.Load()
+	return c != nil && c.inject(c.cfg.Empty, &c.stats.Empties)
+}
+
+// chaosDropAck reports whether to drop an ack
+func (pq *PriorityQueue) chaosDropAck() bool {
+	c := pq.chaos.Load()
+	return c != nil && c.inject(c.cfg.DropAck, &c.stats.DroppedAcks)
+}
+
+// chaosRecordFailure reports whether to fail a write to the recording
+func (pq *PriorityQueue) chaosRecordFailure() bool {
+	c := pq.chaos.Load()
+	return c != nil && c.inject(c.cfg.RecordFailure, &c.stats.RecordFailures)
+}
@@ -0,0 +1,72 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Chaos(t *testing.T) {
+	pq, _ := New(WithChaos(Chaos{Empty: 1}))
+	populateQueue(pq, 3)
+
+	_, err := pq.Pop()
+	assertEqual(t, err, ErrEmptyQueue)
+	_, _, err = pq.Lease(time.Minute)
+	assertEqual(t, err, ErrEmptyQueue)
+	assertEqual(t, pq.Len(), 3)
+
+	pq.SetChaos(&Chaos{DropAck: 1})
+	item, r, _ := pq.Lease(time.Minute)
+	assertEqual(t, pq.Ack(r), nil)
+	assertEqual(t, pq.State(item.ID), StateInFlight)
+	assertEqual(t, pq.ChaosStats(), ChaosStats{DroppedAcks: 1})
+
+	pq.SetChaos(nil)
+	assertEqual(t, pq.Ack(r), nil)
+	assertEqual(t, pq.State(item.ID), StateAcked)
+	assertEqual(t, pq.ChaosStats(), ChaosStats{})
+}
+
+func Test_ChaosLockDelay(t *testing.T) {
+	pq, _ := New(WithChaos(Chaos{LockDelay: 1, MaxLockDelay: time.Millisecond}))
+	pq.Push(QItem{ID: "a"})
+	pq.Len()
+	assertEqual(t, pq.ChaosStats().LockDelays, 2)
+}
+
+func Test_ChaosRecordFailure(t *testing.T) {
+	pq, _ := New(WithChaos(Chaos{RecordFailure: 1}))
+	var buf bytes.Buffer
+	stop := pq.Record(&buf)
+	pq.Push(QItem{ID: "a"})
+	if err := stop(); !errors.Is(err, ErrInjected) {
+		t.Errorf("recording error %v", err)
+	}
+	assertEqual(t, pq.Len(), 1)
+}
+
+func Test_ChaosSeed(t *testing.T) {
+	run := func() []bool {
+		pq, _ := New(WithChaos(Chaos{Empty: 0.5, Seed: 7}))
+		populateQueue(pq, 20)
+		var popped []bool
+		for n := 0; n < 20; n++ {
+			_, err := pq.Pop()
+			popped = append(popped, err == nil)
+		}
+		return popped
+	}
+	first, second := run(), run()
+	faults := 0
+	for n := range first {
+		assertEqual(t, first[n], second[n])
+		if !first[n] {
+			faults++
+		}
+	}
+	if faults == 0 || faults == len(first) {
+		t.Errorf("%d faults in %d pops", faults, len(first))
+	}
+}
@@ -0,0 +1,238 @@
+package priorityqueue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkpointMagic starts the header line of every checkpoint
+const checkpointMagic = "pqcheckpoint v1"
+
+// CheckpointFreezeWait is how long Checkpoint waits to freeze each queue
+// before giving up
+const CheckpointFreezeWait = 10 * time.Second
+
+// ErrNotCheckpoint is returned when a stream does not start with a
+// checkpoint header
+var ErrNotCheckpoint = errors.New("not a queue manager checkpoint")
+
+// Checkpoint writes a snapshot of every queue of the manager to w as one
+// archive, along with the shard counts set by Reshard. The queues are
+// frozen together, in FreezeBlock mode, before the first is copied and
+// thawed after the last, so the archive is a consistent cut: an item moved
+// from one queue to another, by Reshard or by a divert, is in exactly one
+// of them. Queues frozen already are left frozen. Mutations wait meanwhile.
+// A draining queue is frozen before the queue it diverts to, which it calls
+// with its lock held; should freezing a queue still wait until ctx is done
+// or for CheckpointFreezeWait, such as for queues diverting to each other,
+// the checkpoint gives up with an error and thaws the queues. Queues
+// created once the checkpoint started are left out. See Snapshot for what
+// each snapshot holds.
+func (mgr *Manager) Checkpoint(ctx context.Context, w io.Writer) error {
+	mgr.resharding.Lock()
+	defer mgr.resharding.Unlock()
+
+	mgr.m.Lock()
+	queues := make(map[string]*PriorityQueue, len(mgr.queues))
+	for name, pq := range mgr.queues {
+		queues[name] = pq
+	}
+	shards := make(map[string]int, len(mgr.shards))
+	for name, n := range mgr.shards {
+		shards[name] = n
+	}
+	mgr.m.Unlock()
+
+	names, err := freezeOrder(ctx, queues)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		wait, cancel := context.WithTimeout(ctx, CheckpointFreezeWait)
+		frozen, err := queues[name].freezeFor(wait)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("freezing [%s]: %w", name, err)
+		}
+		if !frozen {
+			defer queues[name].Thaw()
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\n", checkpointMagic)
+	for _, name := range sortedKeys(shards) {
+		fmt.Fprintf(bw, "shards %s %d\n", strconv.Quote(name), shards[name])
+	}
+	var snapshot bytes.Buffer
+	for _, name := range names {
+		snapshot.Reset()
+		if err := queues[name].Snapshot(&snapshot); err != nil {
+			return fmt.Errorf("checkpointing [%s]: %w", name, err)
+		}
+		fmt.Fprintf(bw, "queue %s %d\n", strconv.Quote(name), snapshot.Len())
+		bw.Write(snapshot.Bytes())
+	}
+	fmt.Fprintf(bw, "end %d\n", len(names))
+	return bw.Flush()
+}
+
+// freezeOrder returns the names of queues, sorted, but for the draining
+// queues coming before the queue they divert to, so none is frozen while
+// one diverting to it may still be pushing to it. It gives up once ctx is
+// done.
+func freezeOrder(ctx context.Context, queues map[string]*PriorityQueue) ([]string, error) {
+	names := sortedKeys(queues)
+	byQueue := make(map[*PriorityQueue]string, len(queues))
+	for name, pq := range queues {
+		byQueue[pq] = name
+	}
+	target := make(map[string]string)
+	for _, name := range names {
+		pq := queues[name]
+		wait, cancel := context.WithTimeout(ctx, CheckpointFreezeWait)
+		err := pq.lockMutex(wait)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("freezing [%s]: %w", name, err)
+		}
+		if d := pq.drainFloor; d != nil {
+			if to, ok := d.divert.(*PriorityQueue); ok && byQueue[to] != "" {
+				target[name] = byQueue[to]
+			}
+		}
+		pq.m.Unlock()
+	}
+
+	// Targets are listed after their sources by reversing the order in
+	// which the visits finish
+	order := make([]string, 0, len(names))
+	visited := make(map[string]bool, len(names))
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		if to, ok := target[name]; ok {
+			visit(to)
+		}
+		order = append(order, name)
+	}
+	for n := len(names) - 1; n >= 0; n-- {
+		visit(names[n])
+	}
+	slices.Reverse(order)
+	return order, nil
+}
+
+// freezeFor freezes the queue in FreezeBlock mode unless it is frozen
+// already, which it reports. It gives up once ctx is done.
+func (pq *PriorityQueue) freezeFor(ctx context.Context) (bool, error) {
+	if err := pq.lockMutex(ctx); err != nil {
+		return false, err
+	}
+	defer pq.m.Unlock()
+	if pq.freeze != nil {
+		return true, nil
+	}
+	pq.freeze = &freezeState{mode: FreezeBlock, thawed: make(chan struct{})}
+	pq.post(WebhookPayload{Event: EventPaused})
+	return false, nil
+}
+
+// RestoreCheckpoint reads a checkpoint written by Checkpoint and adds the
+// items of each queue to the queue of the same name, creating it as Queue
+// does, and sets the shard counts it holds. The whole checkpoint is read
+// before any queue changes, so a damaged or truncated one restores nothing;
+// a queue failing to take its items, such as a destroyed one, fails the
+// restore with the queues before it restored.
+func (mgr *Manager) RestoreCheckpoint(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if line, err := br.ReadString('\n'); strings.TrimSpace(line) != checkpointMagic {
+		if err != nil && err != io.EOF {
+			return err
+		}
+		return ErrNotCheckpoint
+	}
+
+	shards := make(map[string]int)
+	var names []string
+	queues := make(map[string][]QItem)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("reading checkpoint: %w", io.ErrUnexpectedEOF)
+		}
+		kind, rest, _ := strings.Cut(strings.TrimSuffix(line, "\n"), " ")
+		if kind == "end" {
+			if n, err := strconv.Atoi(rest); err != nil || n != len(names) {
+				return fmt.Errorf("checkpoint ends after %d queues, expected %s", len(names), rest)
+			}
+			break
+		}
+		sep := strings.LastIndexByte(rest, ' ')
+		if sep < 0 {
+			return fmt.Errorf("malformed checkpoint line %q", line)
+		}
+		name, err := strconv.Unquote(rest[:sep])
+		if err != nil {
+			return fmt.Errorf("malformed checkpoint line %q", line)
+		}
+		n, err := strconv.Atoi(rest[sep+1:])
+		if err != nil || n < 0 {
+			return fmt.Errorf("malformed checkpoint line %q", line)
+		}
+		switch kind {
+		case "shards":
+			shards[name] = n
+		case "queue":
+			b := make([]byte, n)
+			if _, err := io.ReadFull(br, b); err != nil {
+				return fmt.Errorf("reading checkpoint of [%s]: %w", name, io.ErrUnexpectedEOF)
+			}
+			items, err := decodeSnapshot(bytes.NewReader(b), nil)
+			if err != nil {
+				return fmt.Errorf("reading checkpoint of [%s]: %w", name, err)
+			}
+			names = append(names, name)
+			queues[name] = items
+		default:
+			return fmt.Errorf("malformed checkpoint line %q", line)
+		}
+	}
+
+	mgr.m.Lock()
+	if mgr.shards == nil && len(shards) > 0 {
+		mgr.shards = make(map[string]int)
+	}
+	for name, n := range shards {
+		mgr.shards[name] = n
+	}
+	mgr.m.Unlock()
+	for _, name := range names {
+		if err := mgr.Queue(name).pushAll(OpRestore, queues[name]); err != nil {
+			return fmt.Errorf("restoring [%s]: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,104 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Checkpoint(t *testing.T) {
+	mgr := NewManager()
+	populateQueue(mgr.Queue("jobs"), 5)
+	mgr.Queue("mail").Push(QItem{ID: "m", Priority: 3})
+	mgr.Queue("idle")
+	mgr.Reshard("users", 2)
+	mgr.Shard("users", "u1").Push(QItem{ID: "u", ParentID: "u1"})
+
+	frozen := mgr.Queue("mail")
+	frozen.Freeze(FreezeReject)
+	var b bytes.Buffer
+	if err := mgr.Checkpoint(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+	// Queues are thawed after the checkpoint unless frozen before
+	assertEqual(t, mgr.Queue("jobs").Frozen(), false)
+	assertEqual(t, frozen.Frozen(), true)
+
+	restored := NewManager()
+	if err := restored.RestoreCheckpoint(bytes.NewReader(b.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(restored.Names()), len(mgr.Names()))
+	assertEqual(t, restored.Queue("jobs").Len(), 5)
+	item, _ := restored.Queue("jobs").Pop()
+	assertEqual(t, item.ID, "4")
+	item, _ = restored.Queue("mail").Pop()
+	assertEqual(t, item.ID, "m")
+	assertEqual(t, restored.Shards("users"), 2)
+	item, _ = restored.Shard("users", "u1").Pop()
+	assertEqual(t, item.ID, "u")
+
+	// A truncated checkpoint restores nothing
+	truncated := NewManager()
+	err := truncated.RestoreCheckpoint(bytes.NewReader(b.Bytes()[:b.Len()-10]))
+	assertEqual(t, err == nil, false)
+	assertEqual(t, len(truncated.Names()), 0)
+
+	err = truncated.RestoreCheckpoint(bytes.NewReader([]byte("pqsnapshot v2\n")))
+	assertEqual(t, errors.Is(err, ErrNotCheckpoint), true)
+}
+
+func Test_CheckpointGivesUp(t *testing.T) {
+	mgr := NewManager()
+	mgr.Queue("a")
+	b := mgr.Queue("b")
+	// b is busy, a already frozen is thawed when the checkpoint gives up
+	b.m.Lock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := mgr.Checkpoint(ctx, &bytes.Buffer{})
+	b.m.Unlock()
+	assertEqual(t, errors.Is(err, context.Canceled), true)
+	assertEqual(t, mgr.Queue("a").Frozen(), false)
+}
+
+func Test_CheckpointDrainingQueue(t *testing.T) {
+	mgr := NewManager()
+	// b diverts into a, which comes first by name
+	a, b := mgr.Queue("a"), mgr.Queue("b")
+	b.StartDraining(5, a)
+
+	// The divert holds the lock of b while a checkpoint starts; it pushes
+	// once a is frozen, or after a while
+	diverting := make(chan struct{})
+	var once sync.Once
+	a.sched = func(op Operation, point schedPoint) {
+		if op != OpPush || point != schedAcquire {
+			return
+		}
+		once.Do(func() {
+			close(diverting)
+			for wait := time.Now().Add(200 * time.Millisecond); time.Now().Before(wait) && !a.Frozen(); {
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+	go b.Push(QItem{ID: "low", Priority: 1})
+	<-diverting
+
+	done := make(chan error)
+	var buf bytes.Buffer
+	go func() { done <- mgr.Checkpoint(context.Background(), &buf) }()
+	select {
+	case err := <-done:
+		assertEqual(t, err, nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Checkpoint hangs on a queue diverting into a frozen one")
+	}
+	restored := NewManager()
+	restored.RestoreCheckpoint(&buf)
+	assertEqual(t, restored.Queue("a").Len()+restored.Queue("b").Len(), 1)
+}
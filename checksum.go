@@ -0,0 +1,134 @@
+package priorityqueue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// snapshotSegmentItems is the number of items in each checksummed segment
+// of a snapshot
+const snapshotSegmentItems = 1000
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// A CorruptionError reports a damaged part of a snapshot
+type CorruptionError struct {
+	Offset  int64  // Byte offset of the damaged segment in the snapshot
+	Segment int    // Number of the segment, counting from 0
+	Reason  string // What is wrong with the segment
+
+	// Items is the number of items lost with the segment, as far as they
+	// could be counted: a truncated segment may have held more.
+	Items int
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("snapshot corrupted at offset %d, segment %d: %s", e.Offset, e.Segment, e.Reason)
+}
+
+// decodeSnapshotV2 reads segments of JSON encoded items, one per line, each
+// segment headed by its length and CRC-32C checksum, up to the end line
+// counting the segments. A damaged segment can be skipped; a damaged
+// segment header or a truncated snapshot ends the decoding.
+func decodeSnapshotV2(r *bufio.Reader, offset int64, skip func(*CorruptionError) bool) ([]QItem, error) {
+	var items []QItem
+	corrupt := func(at int64, segment int, lost []byte, format string, args ...interface{}) error {
+		err := &CorruptionError{Offset: at, Segment: segment, Reason: fmt.Sprintf(format, args...), Items: bytes.Count(lost, []byte("\n"))}
+		if skip != nil && skip(err) {
+			return nil
+		}
+		return err
+	}
+
+	for segment := 0; ; segment++ {
+		at := offset
+		line, err := r.ReadString('\n')
+		offset += int64(len(line))
+		if err == io.EOF && line == "" {
+			return items, corrupt(at, segment, nil, "truncated before the end of the snapshot")
+		}
+		if err != nil && err != io.EOF {
+			return items, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "end" {
+			if fields[1] != strconv.Itoa(segment) {
+				return items, corrupt(at, segment, nil, "end counts %s segments, read %d", fields[1], segment)
+			}
+			return items, nil
+		}
+		var n int
+		var sum uint64
+		if len(fields) == 4 && fields[0] == "segment" && fields[1] == strconv.Itoa(segment) {
+			n, err = strconv.Atoi(fields[2])
+			if err == nil {
+				sum, err = strconv.ParseUint(fields[3], 16, 32)
+			}
+		}
+		if len(fields) != 4 || err != nil || n < 0 {
+			return items, corrupt(at, segment, nil, "invalid segment header %q", strings.TrimSpace(line))
+		}
+
+		data := make([]byte, n)
+		read, err := io.ReadFull(r, data)
+		offset += int64(read)
+		if err != nil {
+			return items, corrupt(at, segment, data[:read], "truncated segment")
+		}
+		if crc32.Checksum(data, castagnoli) != uint32(sum) {
+			if err := corrupt(at, segment, data, "checksum mismatch"); err != nil {
+				return items, err
+			}
+			continue
+		}
+		decoded, err := decodeSegment(data)
+		if err != nil {
+			if err := corrupt(at, segment, data, "%v", err); err != nil {
+				return items, err
+			}
+			continue
+		}
+		items = append(items, decoded...)
+	}
+}
+
+// decodeSegment decodes the items of a segment whose checksum matched
+func decodeSegment(data []byte) ([]QItem, error) {
+	var items []QItem
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var s itemRecord
+		err := dec.Decode(&s)
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding segment item %d: %w", len(items)+1, err)
+		}
+		items = append(items, s.qItem())
+	}
+}
+
+// RestoreBestEffort is Restore salvaging what it can of a damaged snapshot:
+// segments failing their checksum are skipped, and a truncated snapshot or
+// a damaged segment header ends the restore with the items read so far.
+// It returns the corruption found, along with an error if the snapshot
+// cannot be read at all. Snapshots written before version 2 carry no
+// checksums and are restored as Restore does.
+func (pq *PriorityQueue) RestoreBestEffort(r io.Reader) ([]CorruptionError, error) {
+	var corrupted []CorruptionError
+	items, err := decodeSnapshot(r, func(e *CorruptionError) bool {
+		corrupted = append(corrupted, *e)
+		return true
+	})
+	if err != nil {
+		return corrupted, err
+	}
+	return corrupted, pq.pushAll(OpRestore, items)
+}
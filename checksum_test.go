@@ -0,0 +1,54 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_RestoreDetectsCorruption(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 2500)
+	var buf bytes.Buffer
+	pq.Snapshot(&buf)
+	snapshot := buf.Bytes()
+
+	// Damage an item of the second segment
+	second := bytes.Index(snapshot, []byte("segment 1 "))
+	damaged := append([]byte(nil), snapshot...)
+	at := second + bytes.IndexByte(snapshot[second:], '\n') + 10
+	damaged[at] ^= 0xff
+
+	err := NewPriorityQueue().Restore(bytes.NewReader(damaged))
+	var cerr *CorruptionError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Expected a CorruptionError, got %v", err)
+	}
+	assertEqual(t, cerr.Segment, 1)
+	assertEqual(t, cerr.Offset, int64(second))
+
+	restored := NewPriorityQueue()
+	corrupted, err := restored.RestoreBestEffort(bytes.NewReader(damaged))
+	assertEqual(t, err, nil)
+	assertEqual(t, len(corrupted), 1)
+	assertEqual(t, restored.Len(), 1500)
+
+	// A truncated snapshot keeps the complete segments
+	truncated := NewPriorityQueue()
+	corrupted, _ = truncated.RestoreBestEffort(bytes.NewReader(snapshot[:second+100]))
+	assertEqual(t, len(corrupted), 1)
+	assertEqual(t, corrupted[0].Reason, "truncated segment")
+	assertEqual(t, truncated.Len(), 1000)
+
+	err = NewPriorityQueue().Restore(bytes.NewReader(snapshot[:second]))
+	assertEqual(t, errors.As(err, &cerr), true)
+}
+
+func Test_RestoreBestEffortV1(t *testing.T) {
+	pq := NewPriorityQueue()
+	corrupted, err := pq.RestoreBestEffort(strings.NewReader("pqsnapshot v1\n{\"id\":\"a\"}\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, len(corrupted), 0)
+	assertEqual(t, pq.Len(), 1)
+}
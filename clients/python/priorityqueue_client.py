@@ -0,0 +1,179 @@
+# Code generated by pqclients from the httppq OpenAPI document. DO NOT EDIT.
+"""Client of a priority queue served by the Go httppq package.
+
+Items are dicts in the JSON format of priorityqueue.QItem, such as
+{"id": "job-1", "parent_id": "batch-7", "priority": 10, "value": {...}}.
+Failed requests raise Error. Reads present the latest consistency token
+received, so they observe the client's own pushes even on a follower.
+"""
+
+import json
+import urllib.error
+import urllib.parse
+import urllib.request
+
+__all__ = ["Client", "Error"]
+
+
+class Error(Exception):
+    """A request the server failed, with its HTTP status."""
+
+    def __init__(self, status, message):
+        super().__init__(f"{status}: {message}")
+        self.status = status
+        self.message = message
+
+
+def _quote(s):
+    return urllib.parse.quote(str(s), safe="")
+
+
+class Client:
+    """Calls the API served at base_url, such as "http://queue:8080".
+
+    token, if set, is sent as a bearer token; pass an ssl.SSLContext as
+    context for client certificates.
+    """
+
+    def __init__(self, base_url, token=None, timeout=10.0, context=None):
+        self.base_url = base_url.rstrip("/")
+        self.token = token
+        self.timeout = timeout
+        self.context = context
+        self.consistency_token = None
+
+    def _request(self, method, path, body=None, headers=None):
+        req = urllib.request.Request(self.base_url + path, method=method)
+        for name, value in (headers or {}).items():
+            if value is not None:
+                req.add_header(name, str(value))
+        if self.token:
+            req.add_header("Authorization", "Bearer " + self.token)
+        data = None
+        if body is not None:
+            data = json.dumps(body).encode()
+            req.add_header("Content-Type", "application/json")
+        try:
+            with urllib.request.urlopen(req, data, self.timeout, context=self.context) as resp:
+                self._observe(resp.headers.get("Consistency-Token"))
+                payload = resp.read()
+                return json.loads(payload) if payload else None
+        except urllib.error.HTTPError as e:
+            payload = e.read()
+            try:
+                message = json.loads(payload)["error"]
+            except (ValueError, KeyError, TypeError):
+                message = payload.decode(errors="replace") or e.reason
+            raise Error(e.code, message) from None
+
+    def _observe(self, token):
+        if token and (self.consistency_token is None or int(token) > int(self.consistency_token)):
+            self.consistency_token = token
+
+    def clear(self):
+        """Delete every item."""
+        return self._request(
+            "DELETE",
+            "/items",
+        )
+
+    def delete_item(self, id):
+        """Delete an item."""
+        return self._request(
+            "DELETE",
+            f"/items/{_quote(id)}",
+        )
+
+    def delete_parent(self, parent_id):
+        """Delete the items of a parent."""
+        return self._request(
+            "DELETE",
+            f"/parents/{_quote(parent_id)}",
+        )
+
+    def get_item(self, id, consistency_token=None):
+        """Get the state of an item."""
+        return self._request(
+            "GET",
+            f"/items/{_quote(id)}",
+            headers={
+                "Consistency-Token": consistency_token if consistency_token is not None else self.consistency_token,
+            },
+        )
+
+    def healthz(self):
+        """Check the queue is healthy."""
+        return self._request(
+            "GET",
+            "/healthz",
+        )
+
+    def len(self, consistency_token=None):
+        """Count the queued items."""
+        return self._request(
+            "GET",
+            "/len",
+            headers={
+                "Consistency-Token": consistency_token if consistency_token is not None else self.consistency_token,
+            },
+        )
+
+    def openapi(self):
+        """Get this document."""
+        return self._request(
+            "GET",
+            "/openapi.json",
+        )
+
+    def peek(self, consistency_token=None):
+        """Get the highest priority item."""
+        return self._request(
+            "GET",
+            "/peek",
+            headers={
+                "Consistency-Token": consistency_token if consistency_token is not None else self.consistency_token,
+            },
+        )
+
+    def pop(self):
+        """Pop the highest priority item."""
+        return self._request(
+            "POST",
+            "/pop",
+        )
+
+    def push(self, item, idempotency_key=None):
+        """Push an item."""
+        return self._request(
+            "POST",
+            "/items",
+            body=item,
+            headers={
+                "Idempotency-Key": idempotency_key,
+            },
+        )
+
+    def readyz(self):
+        """Check the queue is ready to serve."""
+        return self._request(
+            "GET",
+            "/readyz",
+        )
+
+    def stats(self, consistency_token=None):
+        """Describe the contents of the queue."""
+        return self._request(
+            "GET",
+            "/stats",
+            headers={
+                "Consistency-Token": consistency_token if consistency_token is not None else self.consistency_token,
+            },
+        )
+
+    def update_priority(self, parent_id, priority):
+        """Update the priority of the items of a parent."""
+        return self._request(
+            "PUT",
+            f"/parents/{_quote(parent_id)}/priority",
+            body={"priority": priority},
+        )
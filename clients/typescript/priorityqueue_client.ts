@@ -0,0 +1,194 @@
+// Code generated by pqclients from the httppq OpenAPI document. DO NOT EDIT.
+
+// Client of a priority queue served by the Go httppq package. Failed
+// requests throw QueueError. Reads present the latest consistency token
+// received, so they observe the client's own pushes even on a follower.
+
+export interface Error {
+  error: string;
+}
+
+export interface Health {
+  error?: string;
+  status: "ok" | "unavailable";
+}
+
+export interface Item {
+  attempts?: number;
+  cost?: number;
+  expires_at?: string;
+  id: string;
+  idempotency_key?: string;
+  level?: string;
+  merged?: number;
+  parent_id?: string;
+  priority: number;
+  producer?: string;
+  pushed_at?: string;
+  sync_token?: string;
+  tenant?: string;
+  value?: unknown;
+}
+
+export interface ItemState {
+  id: string;
+  state: string;
+}
+
+export interface Stats {
+  ArchiveFailures?: number;
+  ByLevel?: Record<string, number> | null;
+  ByProducer?: Record<string, number> | null;
+  Clamped?: number;
+  Coalesced?: number;
+  Deduplicated?: number;
+  Diverted?: number;
+  Len?: number;
+  Merged?: number;
+  Priorities?: { Bounds?: number[] | null; Counts?: number[] | null };
+  PriorityChangesDeferred?: number;
+  Rejected?: Record<string, { QuotaExceeded?: number; RateLimited?: number }> | null;
+  Slab?: { Allocated?: number; Free?: number; InUse?: number; Reused?: number; Slabs?: number };
+}
+
+export class QueueError extends globalThis.Error {
+  constructor(
+    readonly status: number,
+    message: string,
+  ) {
+    super(`${status}: ${message}`);
+  }
+}
+
+export interface ClientOptions {
+  // Sent as a bearer token unless empty
+  token?: string;
+  // Replaces the global fetch, such as to send client certificates
+  fetch?: typeof fetch;
+}
+
+export class Client {
+  private readonly baseUrl: string;
+  consistencyToken?: string;
+
+  // Calls the API served at baseUrl, such as "http://queue:8080"
+  constructor(
+    baseUrl: string,
+    private readonly options: ClientOptions = {},
+  ) {
+    this.baseUrl = baseUrl.replace(/\/+$/, "");
+  }
+
+  private async request<T>(method: string, path: string, body?: unknown, headers: Record<string, string | undefined> = {}): Promise<T | undefined> {
+    const h: Record<string, string> = {};
+    for (const [name, value] of Object.entries(headers)) {
+      if (value !== undefined) {
+        h[name] = value;
+      }
+    }
+    if (this.options.token) {
+      h["Authorization"] = "Bearer " + this.options.token;
+    }
+    if (body !== undefined) {
+      h["Content-Type"] = "application/json";
+    }
+    const resp = await (this.options.fetch ?? fetch)(this.baseUrl + path, {
+      method,
+      headers: h,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const text = await resp.text();
+    if (!resp.ok) {
+      let message = text || resp.statusText;
+      try {
+        message = JSON.parse(text).error ?? message;
+      } catch {
+        // not JSON
+      }
+      throw new QueueError(resp.status, message);
+    }
+    this.observe(resp.headers.get("Consistency-Token"));
+    return text ? (JSON.parse(text) as T) : undefined;
+  }
+
+  private observe(token: string | null) {
+    if (token && (this.consistencyToken === undefined || BigInt(token) > BigInt(this.consistencyToken))) {
+      this.consistencyToken = token;
+    }
+  }
+
+  // Delete every item
+  async clear(): Promise<void> {
+    await this.request<void>("DELETE", `/items`);
+  }
+
+  // Delete an item
+  async deleteItem(id: string): Promise<void> {
+    await this.request<void>("DELETE", `/items/${encodeURIComponent(id)}`);
+  }
+
+  // Delete the items of a parent
+  async deleteParent(parentID: string): Promise<{ deleted: number } | undefined> {
+    return this.request<{ deleted: number }>("DELETE", `/parents/${encodeURIComponent(parentID)}`);
+  }
+
+  // Get the state of an item
+  async getItem(id: string, headers: { consistencyToken?: string } = {}): Promise<ItemState | undefined> {
+    return this.request<ItemState>("GET", `/items/${encodeURIComponent(id)}`, undefined, {
+      "Consistency-Token": headers.consistencyToken ?? this.consistencyToken,
+    });
+  }
+
+  // Check the queue is healthy
+  async healthz(): Promise<Health | undefined> {
+    return this.request<Health>("GET", `/healthz`);
+  }
+
+  // Count the queued items
+  async len(headers: { consistencyToken?: string } = {}): Promise<{ len: number } | undefined> {
+    return this.request<{ len: number }>("GET", `/len`, undefined, {
+      "Consistency-Token": headers.consistencyToken ?? this.consistencyToken,
+    });
+  }
+
+  // Get this document
+  async openapi(): Promise<unknown | undefined> {
+    return this.request<unknown>("GET", `/openapi.json`);
+  }
+
+  // Get the highest priority item
+  async peek(headers: { consistencyToken?: string } = {}): Promise<Item | undefined> {
+    return this.request<Item>("GET", `/peek`, undefined, {
+      "Consistency-Token": headers.consistencyToken ?? this.consistencyToken,
+    });
+  }
+
+  // Pop the highest priority item
+  async pop(): Promise<Item | undefined> {
+    return this.request<Item>("POST", `/pop`);
+  }
+
+  // Push an item
+  async push(item: Item, headers: { idempotencyKey?: string } = {}): Promise<void> {
+    await this.request<void>("POST", `/items`, item, {
+      "Idempotency-Key": headers.idempotencyKey,
+    });
+  }
+
+  // Check the queue is ready to serve
+  async readyz(): Promise<Health | undefined> {
+    return this.request<Health>("GET", `/readyz`);
+  }
+
+  // Describe the contents of the queue
+  async stats(headers: { consistencyToken?: string } = {}): Promise<Stats | undefined> {
+    return this.request<Stats>("GET", `/stats`, undefined, {
+      "Consistency-Token": headers.consistencyToken ?? this.consistencyToken,
+    });
+  }
+
+  // Update the priority of the items of a parent
+  async updatePriority(parentID: string, body: { priority: number }): Promise<{ updated: number } | undefined> {
+    return this.request<{ updated: number }>("PUT", `/parents/${encodeURIComponent(parentID)}/priority`, body);
+  }
+}
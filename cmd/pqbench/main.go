@@ -0,0 +1,55 @@
+// Command pqbench runs a configurable workload against the in-memory
+// priority queue and prints throughput and latency percentiles.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	pq "PriorityQueue"
+	"PriorityQueue/pqbench"
+)
+
+func main() {
+	var w pqbench.Workload
+	flag.IntVar(&w.Producers, "producers", 4, "number of pushing goroutines")
+	flag.IntVar(&w.Consumers, "consumers", 4, "number of popping goroutines")
+	flag.IntVar(&w.Items, "items", 100000, "total number of items to push")
+	flag.IntVar(&w.Parents, "parents", 100, "number of distinct ParentIDs")
+	flag.Float64Var(&w.Churn, "churn", 0, "fraction of pushes followed by an update or delete by ParentID")
+	flag.Int64Var(&w.Seed, "seed", time.Now().UnixNano(), "random seed")
+	dist := flag.String("dist", "uniform", "priority distribution: uniform, zipf or bands")
+	max := flag.Int("max-priority", 100, "largest priority generated")
+	flag.Parse()
+
+	switch *dist {
+	case "uniform":
+		w.Priorities = pqbench.Uniform(*max)
+	case "zipf":
+		w.Priorities = pqbench.Zipf(1.5, *max)
+	case "bands":
+		w.Priorities = pqbench.Bands(0, *max/10, *max)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown distribution: %s\n", *dist)
+		os.Exit(2)
+	}
+
+	r := pqbench.Run(pq.NewPriorityQueue(), w)
+
+	fmt.Printf("duration:   %v\n", r.Duration)
+	fmt.Printf("pushes:     %d\n", r.Pushes)
+	fmt.Printf("rejected:   %d\n", r.Rejected)
+	fmt.Printf("pops:       %d\n", r.Pops)
+	fmt.Printf("updates:    %d\n", r.Updates)
+	fmt.Printf("deletes:    %d\n", r.Deletes)
+	fmt.Printf("throughput: %.0f ops/s\n", r.Throughput)
+	printLatency("push", r.PushLatency)
+	printLatency("pop", r.PopLatency)
+	printLatency("churn", r.ChurnLatency)
+}
+
+func printLatency(name string, p pqbench.Percentiles) {
+	fmt.Printf("%-6s p50=%v p90=%v p99=%v max=%v\n", name, p.P50, p.P90, p.P99, p.Max)
+}
@@ -0,0 +1,369 @@
+// Command pqclients generates the clients of the httppq API for other
+// languages from its OpenAPI document, so non-Go producers can push work to
+// a service embedding the queue:
+//
+//	go run ./cmd/pqclients -out clients
+//
+// writes clients/openapi.json, the Python client
+// clients/python/priorityqueue_client.py and the TypeScript client
+// clients/typescript/priorityqueue_client.ts. The clients have no
+// dependencies beyond the standard library of their language, fetch for
+// TypeScript. They are checked in; run go generate ./httppq after changing
+// the API.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"PriorityQueue/httppq"
+)
+
+// The parts of an OpenAPI document the generator reads
+type (
+	document struct {
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Parameters map[string]parameter
+			Schemas    map[string]*schema
+		}
+	}
+
+	operation struct {
+		OperationID string
+		Summary     string
+		Parameters  []parameter
+		RequestBody *body
+		Responses   map[string]body
+	}
+
+	body struct {
+		Content map[string]struct{ Schema *schema }
+	}
+
+	parameter struct {
+		Ref      string `json:"$ref"`
+		Name     string
+		In       string
+		Required bool
+	}
+
+	schema struct {
+		Ref                  string `json:"$ref"`
+		Type                 string
+		Description          string
+		Properties           map[string]*schema
+		Required             []string
+		Items                *schema
+		AdditionalProperties *schema
+		Nullable             bool
+		Enum                 []string
+	}
+)
+
+// A method is an operation of the API as the clients call it
+type method struct {
+	Name       string   // The operationId, such as updatePriority
+	Summary    string   // What the operation does
+	HTTPMethod string   // Such as PUT
+	Path       string   // Such as /parents/{parentID}/priority
+	PathParams []string // Such as parentID
+	Body       string   // The argument sent as the body, such as item
+	BodyFields []string // The arguments making up the body, such as priority
+	BodySchema *schema  // The schema of the body, nil without one
+	Headers    []string // The optional header arguments, such as Idempotency-Key
+	Result     *schema  // The schema of the 200 response, nil without one
+}
+
+// A model is what the client templates render
+type model struct {
+	Methods           []method
+	Schemas           map[string]*schema
+	ConsistencyHeader string
+}
+
+func main() {
+	out := flag.String("out", "clients", "directory the clients are written to")
+	flag.Parse()
+	if err := generate(*out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// generate writes the OpenAPI document and the clients generated from it
+// under dir
+func generate(dir string) error {
+	files, err := render(httppq.OpenAPI())
+	if err != nil {
+		return err
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render returns the content of each generated file by its path
+func render(doc []byte) (map[string][]byte, error) {
+	m, err := parse(doc)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{"openapi.json": append(bytes.TrimSpace(doc), '\n')}
+	for name, tmpl := range map[string]*template.Template{
+		"python/priorityqueue_client.py":     pythonClient,
+		"typescript/priorityqueue_client.ts": typescriptClient,
+	} {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, m); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		files[name] = b.Bytes()
+	}
+	return files, nil
+}
+
+// parse reads the methods and schemas of an OpenAPI document
+func parse(b []byte) (model, error) {
+	var doc document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return model{}, err
+	}
+	m := model{Schemas: doc.Components.Schemas, ConsistencyHeader: httppq.ConsistencyHeader}
+	for path, item := range doc.Paths {
+		var shared []parameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return model{}, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		for verb, raw := range item {
+			if verb == "parameters" {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return model{}, fmt.Errorf("%s %s: %w", verb, path, err)
+			}
+			md := method{Name: op.OperationID, Summary: op.Summary, HTTPMethod: strings.ToUpper(verb), Path: path}
+			for _, p := range append(shared, op.Parameters...) {
+				if p.Ref != "" {
+					p = doc.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+				}
+				switch p.In {
+				case "path":
+					md.PathParams = append(md.PathParams, p.Name)
+				case "header":
+					md.Headers = append(md.Headers, p.Name)
+				}
+			}
+			md.Result = op.Responses["200"].Content["application/json"].Schema
+			if op.RequestBody != nil {
+				s := op.RequestBody.Content["application/json"].Schema
+				md.BodySchema = s
+				switch {
+				case s == nil:
+				case s.Ref != "":
+					md.Body = lowerFirst(refName(s.Ref))
+				default:
+					for name := range s.Properties {
+						md.BodyFields = append(md.BodyFields, name)
+					}
+					sort.Strings(md.BodyFields)
+				}
+			}
+			m.Methods = append(m.Methods, md)
+		}
+	}
+	sort.Slice(m.Methods, func(i, j int) bool { return m.Methods[i].Name < m.Methods[j].Name })
+	return m, nil
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// snake turns names such as parentID or Idempotency-Key into parent_id and
+// idempotency_key
+func snake(s string) string {
+	var b strings.Builder
+	rs := []rune(s)
+	for n, r := range rs {
+		switch {
+		case r == '-':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			if n > 0 && rs[n-1] != '-' && !unicode.IsUpper(rs[n-1]) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// camel turns names such as Idempotency-Key or parent_id into
+// idempotencyKey and parentId
+func camel(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' })
+	for n, w := range words {
+		if n == 0 {
+			words[n] = lowerFirst(w)
+		} else {
+			words[n] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// tsType returns the TypeScript type of the values described by s
+func tsType(s *schema) string {
+	if s == nil {
+		return "unknown"
+	}
+	t := tsBaseType(s)
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
+
+func tsBaseType(s *schema) string {
+	switch {
+	case s.Ref != "":
+		return refName(s.Ref)
+	case len(s.Enum) > 0:
+		quoted := make([]string, len(s.Enum))
+		for n, e := range s.Enum {
+			quoted[n] = fmt.Sprintf("%q", e)
+		}
+		return strings.Join(quoted, " | ")
+	}
+	switch s.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		t := tsType(s.Items)
+		if strings.Contains(t, " ") {
+			t = "(" + t + ")"
+		}
+		return t + "[]"
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "Record<string, " + tsType(s.AdditionalProperties) + ">"
+		}
+		if s.Properties != nil {
+			return "{ " + tsFields(s, "; ") + " }"
+		}
+	}
+	return "unknown"
+}
+
+// tsFields returns the fields of the object described by s, joined by sep
+func tsFields(s *schema, sep string) string {
+	required := make(map[string]bool)
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]string, len(names))
+	for n, name := range names {
+		optional := "?"
+		if required[name] {
+			optional = ""
+		}
+		fields[n] = fmt.Sprintf("%s%s: %s", name, optional, tsType(s.Properties[name]))
+	}
+	return strings.Join(fields, sep)
+}
+
+// pyPath and tsPath turn a path template into an expression of the
+// language building the path from the arguments
+func pyPath(m method) string {
+	if len(m.PathParams) == 0 {
+		return fmt.Sprintf("%q", m.Path)
+	}
+	path := m.Path
+	for _, p := range m.PathParams {
+		path = strings.ReplaceAll(path, "{"+p+"}", "{_quote("+snake(p)+")}")
+	}
+	return "f" + fmt.Sprintf("%q", path)
+}
+
+// tsParams returns the parameters of the TypeScript method calling m
+func tsParams(m method) string {
+	var params []string
+	for _, p := range m.PathParams {
+		params = append(params, camel(p)+": string")
+	}
+	switch {
+	case m.Body != "":
+		params = append(params, m.Body+": "+tsType(m.BodySchema))
+	case m.BodyFields != nil:
+		params = append(params, "body: "+tsType(m.BodySchema))
+	}
+	if m.Headers != nil {
+		headers := make([]string, len(m.Headers))
+		for n, h := range m.Headers {
+			headers[n] = camel(h) + "?: string"
+		}
+		params = append(params, "headers: { "+strings.Join(headers, "; ")+" } = {}")
+	}
+	return strings.Join(params, ", ")
+}
+
+// tsResult returns the type of the value a TypeScript method resolves to
+func tsResult(m method) string {
+	if m.Result == nil {
+		return "void"
+	}
+	return tsType(m.Result) + " | undefined"
+}
+
+func tsPath(m method) string {
+	path := m.Path
+	for _, p := range m.PathParams {
+		path = strings.ReplaceAll(path, "{"+p+"}", "${encodeURIComponent("+camel(p)+")}")
+	}
+	return "`" + path + "`"
+}
+
+var funcs = template.FuncMap{
+	"snake":    snake,
+	"camel":    camel,
+	"tsType":   tsType,
+	"tsFields": tsFields,
+	"pyPath":   pyPath,
+	"tsPath":   tsPath,
+	"tsParams": tsParams,
+	"tsResult": tsResult,
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"PriorityQueue/httppq"
+)
+
+// The checked in clients must be generated from the current API
+func Test_ClientsUpToDate(t *testing.T) {
+	files, err := render(httppq.OpenAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join("..", "..", "clients", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("clients/%s is out of date, run go generate ./httppq", name)
+		}
+	}
+}
+
+func Test_Names(t *testing.T) {
+	for in, want := range map[string]string{"parentID": "parent_id", "Idempotency-Key": "idempotency_key", "updatePriority": "update_priority"} {
+		if got := snake(in); got != want {
+			t.Errorf("snake(%q) = %q, expected %q", in, got, want)
+		}
+	}
+	for in, want := range map[string]string{"parent_id": "parentId", "Idempotency-Key": "idempotencyKey", "parentID": "parentID"} {
+		if got := camel(in); got != want {
+			t.Errorf("camel(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
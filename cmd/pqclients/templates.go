@@ -0,0 +1,194 @@
+package main
+
+import "text/template"
+
+var pythonClient = template.Must(template.New("python").Funcs(funcs).Parse(`# Code generated by pqclients from the httppq OpenAPI document. DO NOT EDIT.
+"""Client of a priority queue served by the Go httppq package.
+
+Items are dicts in the JSON format of priorityqueue.QItem, such as
+{"id": "job-1", "parent_id": "batch-7", "priority": 10, "value": {...}}.
+Failed requests raise Error. Reads present the latest consistency token
+received, so they observe the client's own pushes even on a follower.
+"""
+
+import json
+import urllib.error
+import urllib.parse
+import urllib.request
+
+__all__ = ["Client", "Error"]
+
+
+class Error(Exception):
+    """A request the server failed, with its HTTP status."""
+
+    def __init__(self, status, message):
+        super().__init__(f"{status}: {message}")
+        self.status = status
+        self.message = message
+
+
+def _quote(s):
+    return urllib.parse.quote(str(s), safe="")
+
+
+class Client:
+    """Calls the API served at base_url, such as "http://queue:8080".
+
+    token, if set, is sent as a bearer token; pass an ssl.SSLContext as
+    context for client certificates.
+    """
+
+    def __init__(self, base_url, token=None, timeout=10.0, context=None):
+        self.base_url = base_url.rstrip("/")
+        self.token = token
+        self.timeout = timeout
+        self.context = context
+        self.consistency_token = None
+
+    def _request(self, method, path, body=None, headers=None):
+        req = urllib.request.Request(self.base_url + path, method=method)
+        for name, value in (headers or {}).items():
+            if value is not None:
+                req.add_header(name, str(value))
+        if self.token:
+            req.add_header("Authorization", "Bearer " + self.token)
+        data = None
+        if body is not None:
+            data = json.dumps(body).encode()
+            req.add_header("Content-Type", "application/json")
+        try:
+            with urllib.request.urlopen(req, data, self.timeout, context=self.context) as resp:
+                self._observe(resp.headers.get("{{.ConsistencyHeader}}"))
+                payload = resp.read()
+                return json.loads(payload) if payload else None
+        except urllib.error.HTTPError as e:
+            payload = e.read()
+            try:
+                message = json.loads(payload)["error"]
+            except (ValueError, KeyError, TypeError):
+                message = payload.decode(errors="replace") or e.reason
+            raise Error(e.code, message) from None
+
+    def _observe(self, token):
+        if token and (self.consistency_token is None or int(token) > int(self.consistency_token)):
+            self.consistency_token = token
+{{range .Methods}}
+    def {{snake .Name}}(self{{range .PathParams}}, {{snake .}}{{end}}{{if .Body}}, {{snake .Body}}{{end}}{{range .BodyFields}}, {{snake .}}{{end}}{{range .Headers}}, {{snake .}}=None{{end}}):
+        """{{.Summary}}."""
+        return self._request(
+            "{{.HTTPMethod}}",
+            {{pyPath .}},
+{{- if .Body}}
+            body={{snake .Body}},
+{{- else if .BodyFields}}
+            body={ {{- range $n, $f := .BodyFields}}{{if $n}}, {{end}}"{{$f}}": {{snake $f}}{{end -}} },
+{{- end}}
+{{- if .Headers}}
+            headers={
+{{- range .Headers}}
+{{- if eq . $.ConsistencyHeader}}
+                "{{.}}": {{snake .}} if {{snake .}} is not None else self.consistency_token,
+{{- else}}
+                "{{.}}": {{snake .}},
+{{- end}}
+{{- end}}
+            },
+{{- end}}
+        )
+{{end -}}
+`))
+
+var typescriptClient = template.Must(template.New("typescript").Funcs(funcs).Parse(`// Code generated by pqclients from the httppq OpenAPI document. DO NOT EDIT.
+
+// Client of a priority queue served by the Go httppq package. Failed
+// requests throw QueueError. Reads present the latest consistency token
+// received, so they observe the client's own pushes even on a follower.
+{{range $name, $s := .Schemas}}
+export interface {{$name}} {
+  {{tsFields $s ";\n  "}};
+}
+{{end}}
+export class QueueError extends globalThis.Error {
+  constructor(
+    readonly status: number,
+    message: string,
+  ) {
+    super(` + "`${status}: ${message}`" + `);
+  }
+}
+
+export interface ClientOptions {
+  // Sent as a bearer token unless empty
+  token?: string;
+  // Replaces the global fetch, such as to send client certificates
+  fetch?: typeof fetch;
+}
+
+export class Client {
+  private readonly baseUrl: string;
+  consistencyToken?: string;
+
+  // Calls the API served at baseUrl, such as "http://queue:8080"
+  constructor(
+    baseUrl: string,
+    private readonly options: ClientOptions = {},
+  ) {
+    this.baseUrl = baseUrl.replace(/\/+$/, "");
+  }
+
+  private async request<T>(method: string, path: string, body?: unknown, headers: Record<string, string | undefined> = {}): Promise<T | undefined> {
+    const h: Record<string, string> = {};
+    for (const [name, value] of Object.entries(headers)) {
+      if (value !== undefined) {
+        h[name] = value;
+      }
+    }
+    if (this.options.token) {
+      h["Authorization"] = "Bearer " + this.options.token;
+    }
+    if (body !== undefined) {
+      h["Content-Type"] = "application/json";
+    }
+    const resp = await (this.options.fetch ?? fetch)(this.baseUrl + path, {
+      method,
+      headers: h,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const text = await resp.text();
+    if (!resp.ok) {
+      let message = text || resp.statusText;
+      try {
+        message = JSON.parse(text).error ?? message;
+      } catch {
+        // not JSON
+      }
+      throw new QueueError(resp.status, message);
+    }
+    this.observe(resp.headers.get("{{.ConsistencyHeader}}"));
+    return text ? (JSON.parse(text) as T) : undefined;
+  }
+
+  private observe(token: string | null) {
+    if (token && (this.consistencyToken === undefined || BigInt(token) > BigInt(this.consistencyToken))) {
+      this.consistencyToken = token;
+    }
+  }
+{{range .Methods}}
+  // {{.Summary}}
+  async {{camel .Name}}({{tsParams .}}): Promise<{{tsResult .}}> {
+    {{if .Result}}return {{else}}await {{end}}this.request<{{if .Result}}{{tsType .Result}}{{else}}void{{end}}>("{{.HTTPMethod}}", {{tsPath .}}
+{{- if .Body}}, {{.Body}}{{else if .BodyFields}}, body{{else if .Headers}}, undefined{{end}}
+{{- if .Headers}}, {
+{{- range .Headers}}
+{{- if eq . $.ConsistencyHeader}}
+      "{{.}}": headers.{{camel .}} ?? this.consistencyToken,
+{{- else}}
+      "{{.}}": headers.{{camel .}},
+{{- end}}
+{{- end}}
+    }{{end}});
+  }
+{{end -}}
+}
+`))
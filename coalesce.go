@@ -0,0 +1,59 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// SetCoalesceDelay makes Push hold each item back for d, merging the later
+// pushes of the same ID into it meanwhile: the held item keeps the highest
+// of their priorities and the Value of the latest push. Once d has passed
+// since the first push the merged item is queued, so an ID pushed hundreds
+// of times a second is handed out once per delay. Held items are reported
+// as StateDelayed and not counted by Len. Zero, the default, queues every
+// push; items held when coalescing is turned off are queued on time.
+func (pq *PriorityQueue) SetCoalesceDelay(d time.Duration) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.coalesceDelay = d
+}
+
+// coalesce holds i back, or merges it into the held item of its ID. The
+// queue lock must be held.
+func (pq *PriorityQueue) coalesce(i QItem) {
+	key := pq.itemKey(&i)
+	if held, ok := pq.coalescing[key]; ok {
+		pq.dropBlob(held.Value)
+		held.Value = i.Value
+		if i.Priority > held.Priority {
+			held.Priority = i.Priority
+		}
+		pq.coalesced++
+		pq.audit(OpCoalesce, held)
+		return
+	}
+	if pq.coalescing == nil {
+		pq.coalescing = make(map[string]*QItem)
+	}
+	held := &i
+	pq.transition(held, StateDelayed)
+	pq.coalescing[key] = held
+	heap.Push(&pq.coalesceTimers, timer[string]{at: pq.now().Add(pq.coalesceDelay), v: key})
+	pq.audit(OpPush, held)
+}
+
+// flushCoalesced queues the held items whose delay is over. The queue lock
+// must be held.
+func (pq *PriorityQueue) flushCoalesced(now time.Time) {
+	for len(pq.coalesceTimers) > 0 && !now.Before(pq.coalesceTimers[0].at) {
+		id := heap.Pop(&pq.coalesceTimers).(timer[string]).v
+		i := pq.coalescing[id]
+		delete(pq.coalescing, id)
+		if expired(i, now) {
+			pq.transition(i, StateExpired)
+			pq.audit(OpExpire, i)
+			continue
+		}
+		pq.audit(OpPromote, pq.insert(*i))
+	}
+}
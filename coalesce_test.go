@@ -0,0 +1,49 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_CoalesceMergesPushes(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	pq.SetCoalesceDelay(time.Second)
+
+	res, _ := pq.PushInfo(QItem{ID: "1", Value: "a", Priority: 2})
+	assertEqual(t, res.Coalesced, true)
+	pq.Push(QItem{ID: "1", Value: "b", Priority: 5})
+	pq.Push(QItem{ID: "1", Value: "c", Priority: 1})
+	pq.Push(QItem{ID: "2", Value: "d", Priority: 3})
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, pq.State("1"), StateDelayed)
+	assertEqual(t, pq.Counts().Delayed, 2)
+	assertEqual(t, pq.Stats().Coalesced, 2)
+
+	advance(time.Second)
+	assertEqual(t, pq.Len(), 2)
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "1")
+	assertEqual(t, item.Value, "c")
+	assertEqual(t, item.Priority, 5)
+
+	pq.Push(QItem{ID: "1", Value: "e"})
+	assertEqual(t, pq.Len(), 1)
+}
+
+func Test_CoalesceTurnedOff(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	pq.SetCoalesceDelay(time.Second)
+	pq.Push(QItem{ID: "1", Priority: 1})
+	pq.SetCoalesceDelay(0)
+
+	pq.Push(QItem{ID: "1", Priority: 4})
+	pq.Push(QItem{ID: "2"})
+	assertEqual(t, pq.Len(), 1)
+
+	advance(time.Second)
+	assertEqual(t, pq.Len(), 2)
+	item, _ := pq.Pop()
+	assertEqual(t, item.Priority, 4)
+}
@@ -0,0 +1,206 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CompactQueue is a Queue for multi-million item queues that keeps its heap
+// free of pointers. The heap orders fixed-size headers holding the priority,
+// a push sequence number and the offset of the item in a side table, so
+// reordering touches a flat slice the garbage collector never scans item by
+// item. Items of equal priority are popped in push order.
+//
+// Only the Queue methods are supported: leases, delays, parent hierarchies,
+// tenants and the rest of the PriorityQueue features are not. Updates and
+// deletes by ParentID and deletes by ID scan the headers, so they are O(n).
+type CompactQueue struct {
+	m       sync.Mutex
+	heap    []compactHeader
+	items   []QItem // The side table, indexed by compactHeader.slot
+	pos     []int32 // The heap index of the header of each slot, -1 when free
+	free    []uint32
+	nextSeq uint64
+}
+
+// compactHeader is the heap entry of an item, with no pointers in it
+type compactHeader struct {
+	priority int
+	seq      uint64
+	slot     uint32
+}
+
+var _ Queue = (*CompactQueue)(nil)
+
+func NewCompactQueue() *CompactQueue {
+	return &CompactQueue{}
+}
+
+func (cq *CompactQueue) less(i, j int) bool {
+	a, b := &cq.heap[i], &cq.heap[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (cq *CompactQueue) swap(i, j int) {
+	cq.heap[i], cq.heap[j] = cq.heap[j], cq.heap[i]
+	cq.pos[cq.heap[i].slot] = int32(i)
+	cq.pos[cq.heap[j].slot] = int32(j)
+}
+
+func (cq *CompactQueue) up(j int) {
+	for j > 0 {
+		i := (j - 1) / 2
+		if !cq.less(j, i) {
+			break
+		}
+		cq.swap(i, j)
+		j = i
+	}
+}
+
+func (cq *CompactQueue) down(i int) bool {
+	start, n := i, len(cq.heap)
+	for {
+		j := 2*i + 1
+		if j >= n {
+			break
+		}
+		if r := j + 1; r < n && cq.less(r, j) {
+			j = r
+		}
+		if !cq.less(j, i) {
+			break
+		}
+		cq.swap(i, j)
+		i = j
+	}
+	return i > start
+}
+
+// fix restores the heap order after the header at i changed
+func (cq *CompactQueue) fix(i int) {
+	if !cq.down(i) {
+		cq.up(i)
+	}
+}
+
+// remove takes the item whose header is at heap index i out of the queue
+func (cq *CompactQueue) remove(i int) QItem {
+	slot := cq.heap[i].slot
+	last := len(cq.heap) - 1
+	if i != last {
+		cq.swap(i, last)
+	}
+	cq.heap = cq.heap[:last]
+	if i != last {
+		cq.fix(i)
+	}
+	item := cq.items[slot]
+	cq.items[slot] = QItem{}
+	cq.pos[slot] = -1
+	cq.free = append(cq.free, slot)
+	item.index = -1
+	return item
+}
+
+func (cq *CompactQueue) Push(i QItem) error {
+	cq.m.Lock()
+	defer cq.m.Unlock()
+	stamp(&i)
+	i.index = 0
+	var slot uint32
+	if n := len(cq.free); n > 0 {
+		slot = cq.free[n-1]
+		cq.free = cq.free[:n-1]
+		cq.items[slot] = i
+	} else {
+		slot = uint32(len(cq.items))
+		cq.items = append(cq.items, i)
+		cq.pos = append(cq.pos, 0)
+	}
+	cq.nextSeq++
+	cq.pos[slot] = int32(len(cq.heap))
+	cq.heap = append(cq.heap, compactHeader{priority: i.Priority, seq: cq.nextSeq, slot: slot})
+	cq.up(len(cq.heap) - 1)
+	return nil
+}
+
+func (cq *CompactQueue) Pop() (*QItem, error) {
+	cq.m.Lock()
+	defer cq.m.Unlock()
+	if len(cq.heap) == 0 {
+		return nil, ErrEmptyQueue
+	}
+	item := cq.remove(0)
+	return &item, nil
+}
+
+func (cq *CompactQueue) Peek() (*QItem, error) {
+	cq.m.Lock()
+	defer cq.m.Unlock()
+	if len(cq.heap) == 0 {
+		return nil, ErrEmptyQueue
+	}
+	item := cq.items[cq.heap[0].slot]
+	return &item, nil
+}
+
+func (cq *CompactQueue) Len() int {
+	cq.m.Lock()
+	defer cq.m.Unlock()
+	return len(cq.heap)
+}
+
+func (cq *CompactQueue) Clear() {
+	cq.m.Lock()
+	defer cq.m.Unlock()
+	cq.heap, cq.items, cq.pos, cq.free = nil, nil, nil, nil
+}
+
+// UpdatePriorityByParentId sets the priority of every item with a matching
+// ParentID. Updated items keep their place in push order among the items of
+// their new priority.
+func (cq *CompactQueue) UpdatePriorityByParentId(parentID string, priority int) int {
+	cq.m.Lock()
+	defer cq.m.Unlock()
+	updated := 0
+	for slot := range cq.items {
+		i := cq.pos[slot]
+		if i < 0 || cq.items[slot].ParentID != parentID {
+			continue
+		}
+		cq.items[slot].Priority = priority
+		cq.heap[i].priority = priority
+		cq.fix(int(i))
+		updated++
+	}
+	return updated
+}
+
+func (cq *CompactQueue) DeleteItemById(id string) error {
+	cq.m.Lock()
+	defer cq.m.Unlock()
+	for slot := range cq.items {
+		if i := cq.pos[slot]; i >= 0 && cq.items[slot].ID == id {
+			cq.remove(int(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: [%s]", ErrNotFound, id)
+}
+
+func (cq *CompactQueue) DeleteItemsByParentId(parentID string) (int, error) {
+	cq.m.Lock()
+	defer cq.m.Unlock()
+	deleted := 0
+	for slot := range cq.items {
+		if i := cq.pos[slot]; i >= 0 && cq.items[slot].ParentID == parentID {
+			cq.remove(int(i))
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,71 @@
+package priorityqueue
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func Test_CompactQueueOrdering(t *testing.T) {
+	cq := NewCompactQueue()
+	cq.Push(QItem{ID: "a", Priority: 1})
+	cq.Push(QItem{ID: "b", Priority: 5, Value: "payload"})
+	cq.Push(QItem{ID: "c", Priority: 1})
+	cq.Push(QItem{ID: "d", Priority: 5})
+
+	peeked, _ := cq.Peek()
+	assertEqual(t, peeked.ID, "b")
+	var ids string
+	for cq.Len() > 0 {
+		item, _ := cq.Pop()
+		ids += item.ID
+	}
+	assertEqual(t, ids, "bdac")
+	_, err := cq.Pop()
+	assertEqual(t, err, ErrEmptyQueue)
+}
+
+// Test_CompactQueueModel applies random operations to a CompactQueue and to
+// a SortedQueue and compares the popped priorities and the counts.
+func Test_CompactQueueModel(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	cq, ref := NewCompactQueue(), NewSortedQueue()
+	pushed := 0
+	for n := 0; n < 5000; n++ {
+		parentID := strconv.Itoa(r.Intn(8))
+		switch r.Intn(10) {
+		case 0, 1, 2, 3:
+			item := QItem{ID: strconv.Itoa(pushed), ParentID: parentID, Priority: r.Intn(32)}
+			pushed++
+			cq.Push(item)
+			ref.Push(item)
+		case 4, 5:
+			x, err := cq.Pop()
+			y, rerr := ref.Pop()
+			assertEqual(t, err, rerr)
+			if err == nil {
+				assertEqual(t, x.Priority, y.Priority)
+				if x.ID != y.ID {
+					ref.Push(*y)
+					ref.DeleteItemById(x.ID)
+				}
+			}
+		case 6:
+			p := r.Intn(32)
+			assertEqual(t, cq.UpdatePriorityByParentId(parentID, p), ref.UpdatePriorityByParentId(parentID, p))
+		case 7:
+			a, _ := cq.DeleteItemsByParentId(parentID)
+			b, _ := ref.DeleteItemsByParentId(parentID)
+			assertEqual(t, a, b)
+		case 8:
+			id := strconv.Itoa(r.Intn(pushed + 1))
+			assertEqual(t, cq.DeleteItemById(id) == nil, ref.DeleteItemById(id) == nil)
+		case 9:
+			if r.Intn(20) == 0 {
+				cq.Clear()
+				ref.Clear()
+			}
+		}
+		assertEqual(t, cq.Len(), ref.Len())
+	}
+}
@@ -0,0 +1,273 @@
+package priorityqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A Duration is a time.Duration written in configurations as a string
+// such as "1m30s", or a number of nanoseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// A Config describes a queue in a form that can be stored next to the
+// service running it, so it can be tuned without recompiling. It is read
+// from JSON by LoadConfig. YAML is not supported directly, so the module
+// needs nothing beyond the standard library; convert YAML files to JSON
+// first, the field names are the same. Zero fields keep the defaults.
+type Config struct {
+	Capacity        int                      `json:"capacity,omitempty"`
+	MaxInFlight     int                      `json:"max_in_flight,omitempty"`
+	MaxAttempts     int                      `json:"max_attempts,omitempty"`
+	RedeliveryBoost RedeliveryBoost          `json:"redelivery_boost"`
+	DedupeWindow    Duration                 `json:"dedupe_window,omitempty"`
+	CoalesceDelay   Duration                 `json:"coalesce_delay,omitempty"`
+	StateRetention  int                      `json:"state_retention,omitempty"`
+	SweepInterval   Duration                 `json:"sweep_interval,omitempty"`
+	PriorityBuckets []int                    `json:"priority_buckets,omitempty"`
+	ProducerLimits  map[string]ProducerLimit `json:"producer_limits,omitempty"`
+	ParentCeilings  map[string]int           `json:"parent_ceilings,omitempty"`
+	PriorityLevels  []PriorityLevel          `json:"priority_levels,omitempty"`
+	Admission       []AdmissionPolicy        `json:"admission,omitempty"`
+
+	// CaseInsensitiveIDs compares IDs and ParentIDs with FoldCase, see
+	// WithIDNormalizer
+	CaseInsensitiveIDs bool `json:"case_insensitive_ids,omitempty"`
+
+	// Retention and RetentionInterval configure WithRetentionPolicy
+	Retention         []RetentionRule `json:"retention,omitempty"`
+	RetentionInterval Duration        `json:"retention_interval,omitempty"`
+
+	// Escalation and EscalationInterval configure WithEscalationRules
+	Escalation         []EscalationRule `json:"escalation,omitempty"`
+	EscalationInterval Duration         `json:"escalation_interval,omitempty"`
+
+	// Webhooks configures WithWebhooks
+	Webhooks *WebhookConfig `json:"webhooks,omitempty"`
+
+	// SnapshotPath and RecordingPath configure WithSnapshotFile and
+	// WithRecordingFile.
+	SnapshotPath  string `json:"snapshot_path,omitempty"`
+	RecordingPath string `json:"recording_path,omitempty"`
+}
+
+// LoadConfig reads a Config from JSON, rejecting unknown fields so typos
+// do not go unnoticed.
+func LoadConfig(r io.Reader) (Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("reading the queue configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// Options returns the options configuring a queue as cfg says
+func (cfg Config) Options() []Option {
+	var opts []Option
+	if cfg.CaseInsensitiveIDs {
+		opts = append(opts, WithIDNormalizer(FoldCase))
+	}
+	if cfg.Capacity != 0 {
+		opts = append(opts, WithCapacity(cfg.Capacity))
+	}
+	if cfg.MaxInFlight != 0 {
+		opts = append(opts, WithMaxInFlight(cfg.MaxInFlight))
+	}
+	if cfg.MaxAttempts != 0 {
+		opts = append(opts, WithMaxAttempts(cfg.MaxAttempts))
+	}
+	if cfg.RedeliveryBoost != (RedeliveryBoost{}) {
+		opts = append(opts, WithRedeliveryBoost(cfg.RedeliveryBoost))
+	}
+	if cfg.DedupeWindow != 0 {
+		opts = append(opts, WithDedupeWindow(time.Duration(cfg.DedupeWindow)))
+	}
+	if cfg.CoalesceDelay != 0 {
+		opts = append(opts, WithCoalesceDelay(time.Duration(cfg.CoalesceDelay)))
+	}
+	if cfg.StateRetention != 0 {
+		opts = append(opts, WithStateRetention(cfg.StateRetention))
+	}
+	if cfg.PriorityBuckets != nil {
+		opts = append(opts, WithPriorityBuckets(cfg.PriorityBuckets...))
+	}
+	for producer, limit := range cfg.ProducerLimits {
+		opts = append(opts, WithProducerLimit(producer, limit))
+	}
+	for parentID, ceiling := range cfg.ParentCeilings {
+		opts = append(opts, WithParentCeiling(parentID, ceiling))
+	}
+	if cfg.PriorityLevels != nil {
+		opts = append(opts, WithPriorityLevels(cfg.PriorityLevels...))
+	}
+	if cfg.Admission != nil {
+		opts = append(opts, WithAdmissionPolicies(cfg.Admission...))
+	}
+	if cfg.SnapshotPath != "" {
+		opts = append(opts, WithSnapshotFile(cfg.SnapshotPath))
+	}
+	if cfg.RecordingPath != "" {
+		opts = append(opts, WithRecordingFile(cfg.RecordingPath))
+	}
+	if cfg.SweepInterval != 0 {
+		opts = append(opts, WithSweepInterval(time.Duration(cfg.SweepInterval)))
+	}
+	if cfg.Retention != nil {
+		opts = append(opts, WithRetentionPolicy(time.Duration(cfg.RetentionInterval), cfg.Retention...))
+	}
+	if cfg.Escalation != nil {
+		opts = append(opts, WithEscalationRules(time.Duration(cfg.EscalationInterval), cfg.Escalation...))
+	}
+	if cfg.Webhooks != nil {
+		opts = append(opts, WithWebhooks(*cfg.Webhooks))
+	}
+	return opts
+}
+
+// NewFromConfig returns an empty queue, or the one restored from
+// cfg.SnapshotPath, configured as cfg says.
+func NewFromConfig(cfg Config) (*PriorityQueue, error) {
+	return New(cfg.Options()...)
+}
+
+// A ConfigUpdate lists the settings UpdateConfig changes on a live queue.
+// Nil fields are left as they are.
+type ConfigUpdate struct {
+	// ProducerLimits sets the limits of the listed producers, a null limit
+	// lifting them.
+	ProducerLimits map[string]*ProducerLimit `json:"producer_limits,omitempty"`
+
+	// ParentCeilings sets the priority ceilings of the listed ParentIDs, a
+	// null ceiling lifting it.
+	ParentCeilings map[string]*int `json:"parent_ceilings,omitempty"`
+
+	MaxInFlight     *int               `json:"max_in_flight,omitempty"`
+	MaxAttempts     *int               `json:"max_attempts,omitempty"`
+	RedeliveryBoost *RedeliveryBoost   `json:"redelivery_boost,omitempty"`
+	DedupeWindow    *Duration          `json:"dedupe_window,omitempty"`
+	SweepInterval   *Duration          `json:"sweep_interval,omitempty"`
+	Admission       *[]AdmissionPolicy `json:"admission,omitempty"`
+
+	// Pause and Resume list ParentIDs to pass to PauseParent and
+	// ResumeParent, in that order.
+	Pause  []string `json:"pause,omitempty"`
+	Resume []string `json:"resume,omitempty"`
+}
+
+func (u ConfigUpdate) validate() error {
+	for producer, limit := range u.ProducerLimits {
+		if limit != nil && (limit.Rate < 0 || limit.Burst < 0 || limit.Quota < 0) {
+			return fmt.Errorf("limit of producer [%s] has a negative field: %+v", producer, *limit)
+		}
+	}
+	switch {
+	case u.MaxInFlight != nil && *u.MaxInFlight < 0:
+		return fmt.Errorf("max in flight %d is negative", *u.MaxInFlight)
+	case u.MaxAttempts != nil && *u.MaxAttempts < 0:
+		return fmt.Errorf("max attempts %d is negative", *u.MaxAttempts)
+	case u.RedeliveryBoost != nil && u.RedeliveryBoost.Max < 0:
+		return fmt.Errorf("redelivery boost cap %d is negative", u.RedeliveryBoost.Max)
+	case u.DedupeWindow != nil && *u.DedupeWindow < 0:
+		return fmt.Errorf("dedupe window %v is negative", time.Duration(*u.DedupeWindow))
+	case u.SweepInterval != nil && *u.SweepInterval < 0:
+		return fmt.Errorf("sweep interval %v is negative", time.Duration(*u.SweepInterval))
+	}
+	if u.Admission != nil {
+		for _, p := range *u.Admission {
+			if p.Percentile < 0 || p.Percentile > 1 {
+				return fmt.Errorf("admission percentile %v is not between 0 and 1", p.Percentile)
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateConfig changes the settings of a live queue, all of them at once or
+// none if any is invalid, and records the update in the audit log as an
+// OpUpdateConfig entry with the update as its JSON Detail.
+func (pq *PriorityQueue) UpdateConfig(u ConfigUpdate) error {
+	if err := u.validate(); err != nil {
+		return fmt.Errorf("updating the queue configuration: %w", err)
+	}
+	defer pq.lock(OpUpdateConfig)()
+	for producer, limit := range u.ProducerLimits {
+		if limit == nil {
+			delete(pq.limits, producer)
+		} else {
+			pq.setProducerLimit(producer, *limit)
+		}
+	}
+	for parentID, ceiling := range u.ParentCeilings {
+		if ceiling == nil {
+			delete(pq.parentCeiling, parentID)
+		} else {
+			pq.setParentCeiling(parentID, *ceiling)
+		}
+	}
+	if u.MaxInFlight != nil {
+		pq.maxInFlight = *u.MaxInFlight
+	}
+	if u.MaxAttempts != nil {
+		pq.maxAttempts = *u.MaxAttempts
+	}
+	if u.RedeliveryBoost != nil {
+		pq.redeliveryBoost = *u.RedeliveryBoost
+	}
+	if u.DedupeWindow != nil {
+		pq.setDedupeWindow(time.Duration(*u.DedupeWindow))
+	}
+	if u.SweepInterval != nil {
+		pq.setSweepInterval(time.Duration(*u.SweepInterval))
+	}
+	if u.Admission != nil {
+		pq.admission = append([]AdmissionPolicy(nil), *u.Admission...)
+	}
+	for _, parentID := range u.Pause {
+		if pq.pausedParents == nil {
+			pq.pausedParents = make(map[string]bool)
+		}
+		pq.pausedParents[pq.idKey(parentID)] = true
+	}
+	for _, parentID := range u.Resume {
+		delete(pq.pausedParents, pq.idKey(parentID))
+	}
+	pq.wake()
+
+	if pq.auditing() {
+		detail, _ := json.Marshal(u)
+		pq.auditEntries = append(pq.auditEntries, AuditEntry{
+			Time:     time.Now(),
+			Op:       OpUpdateConfig,
+			Detail:   string(detail),
+			Position: pq.position,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package priorityqueue
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_LoadConfig(t *testing.T) {
+	cfg, err := LoadConfig(strings.NewReader(`{
+		"capacity": 64,
+		"max_in_flight": 2,
+		"dedupe_window": "5m",
+		"sweep_interval": 1000000000,
+		"producer_limits": {"bulk": {"quota": 10}},
+		"admission": [{"depth": 100, "percentile": 0.5}]
+	}`))
+	if err != nil {
+		t.Errorf("Error loading the configuration: %v", err)
+		return
+	}
+	assertEqual(t, cfg.Capacity, 64)
+	assertEqual(t, time.Duration(cfg.DedupeWindow), 5*time.Minute)
+	assertEqual(t, time.Duration(cfg.SweepInterval), time.Second)
+	assertEqual(t, cfg.ProducerLimits["bulk"].Quota, 10)
+	assertEqual(t, cfg.Admission[0].Percentile, 0.5)
+
+	if _, err := LoadConfig(strings.NewReader(`{"capasity": 64}`)); err == nil {
+		t.Errorf("Error, an unknown field was accepted")
+	}
+	if _, err := LoadConfig(strings.NewReader(`{"dedupe_window": "soon"}`)); err == nil {
+		t.Errorf("Error, an invalid duration was accepted")
+	}
+}
+
+func Test_NewFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		MaxInFlight:   1,
+		SnapshotPath:  filepath.Join(dir, "queue.snapshot"),
+		RecordingPath: filepath.Join(dir, "queue.recording"),
+	}
+	pq, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Errorf("Error creating the queue: %v", err)
+		return
+	}
+	populateQueue(pq, 3)
+	if err := pq.Stop(context.Background()); err != nil {
+		t.Errorf("Error stopping the queue: %v", err)
+	}
+
+	restored, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Errorf("Error restoring the queue: %v", err)
+		return
+	}
+	assertEqual(t, restored.Len(), 3)
+	restored.Lease(time.Minute)
+	if _, _, err := restored.Lease(time.Minute); err != ErrTooManyInFlight {
+		t.Errorf("Error leasing over the configured cap: %v", err)
+	}
+	restored.Stop(context.Background())
+
+	if _, err := NewFromConfig(Config{MaxAttempts: -1}); err == nil {
+		t.Errorf("Error, an invalid configuration was accepted")
+	}
+}
+
+func Test_UpdateConfig(t *testing.T) {
+	pq := NewPriorityQueue(WithProducerLimit("bulk", ProducerLimit{Quota: 1}))
+	var entries []AuditEntry
+	pq.SetAuditLog(func(e AuditEntry) { entries = append(entries, e) })
+	populateQueue(pq, 2)
+
+	var u ConfigUpdate
+	if err := json.Unmarshal([]byte(`{
+		"producer_limits": {"bulk": null, "web": {"quota": 1}},
+		"max_in_flight": 1,
+		"pause": ["12345"]
+	}`), &u); err != nil {
+		t.Errorf("Error decoding the update: %v", err)
+		return
+	}
+	if err := pq.UpdateConfig(u); err != nil {
+		t.Errorf("Error updating the configuration: %v", err)
+	}
+	if _, err := pq.Pop(); err != ErrEmptyQueue {
+		t.Errorf("Error popping a paused parent: %v", err)
+	}
+	pq.Push(QItem{ID: "b1", Producer: "bulk"})
+	if err := pq.Push(QItem{ID: "b2", Producer: "bulk"}); err != nil {
+		t.Errorf("Error pushing after lifting the limit: %v", err)
+	}
+	pq.Push(QItem{ID: "w1", Producer: "web"})
+	if err := pq.Push(QItem{ID: "w2", Producer: "web"}); err == nil {
+		t.Errorf("Error, the new limit was not applied")
+	}
+	pq.Lease(time.Minute)
+	if _, _, err := pq.Lease(time.Minute); err != ErrTooManyInFlight {
+		t.Errorf("Error leasing over the updated cap: %v", err)
+	}
+
+	var update AuditEntry
+	for _, e := range entries {
+		if e.Op == OpUpdateConfig {
+			update = e
+		}
+	}
+	if !strings.Contains(update.Detail, `"max_in_flight":1`) {
+		t.Errorf("Error, the audit entry lacks the update: %s", update.Detail)
+	}
+
+	n := -1
+	if err := pq.UpdateConfig(ConfigUpdate{MaxInFlight: &n}); err == nil {
+		t.Errorf("Error, an invalid update was accepted")
+	}
+}
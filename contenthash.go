@@ -0,0 +1,82 @@
+package priorityqueue
+
+import "time"
+
+// hashedItem is the queued item a content hash was last pushed as
+type hashedItem struct {
+	item *QItem
+	seq  uint64 // Sequence number of item, telling it from a reused one
+	at   time.Time
+}
+
+// SetContentHash merges the pushes of near-duplicate items, such as jobs
+// triggered repeatedly by a burst of events: an item whose Value, as
+// pushed before the Transformers and the BlobStore apply, hashes like an
+// item still queued is not queued again if the queued item was
+// pushed, or last merged into, less than ttl ago. Instead the queued item
+// counts the push in Merged and takes its priority if it is higher; its
+// Value and ID are kept. An empty hash leaves the item alone. A nil hash,
+// the default, disables the merging.
+func (pq *PriorityQueue) SetContentHash(hash func(value interface{}) string, ttl time.Duration) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.contentHash, pq.contentTTL = hash, ttl
+	pq.hashes = nil
+}
+
+// mergeContent merges i into the queued item with the same content hash,
+// reporting whether it found one. Otherwise it returns the hash to remember
+// once i is queued. The hash is that of raw, the Value of i as pushed, before
+// the Transformers and the BlobStore changed it. The queue lock must be
+// held.
+func (pq *PriorityQueue) mergeContent(i *QItem, raw interface{}) (string, bool) {
+	if pq.contentHash == nil {
+		return "", false
+	}
+	hash := pq.contentHash(raw)
+	if hash == "" {
+		return "", false
+	}
+	now := pq.now()
+	h, ok := pq.hashes[hash]
+	if !ok || !pq.visible(h, now) {
+		return hash, false
+	}
+	if i.Priority > h.item.Priority {
+		pq.reprioritize(h.item, i.Priority)
+	}
+	h.item.Merged++
+	h.at = now
+	pq.hashes[hash] = h
+	pq.merged++
+	pq.audit(OpMerge, h.item)
+	return "", true
+}
+
+// visible reports whether pushes may still be merged into the item of h
+func (pq *PriorityQueue) visible(h hashedItem, now time.Time) bool {
+	return h.item.seq == h.seq && h.item.state == StateQueued && h.item.tombstone == "" &&
+		now.Sub(h.at) < pq.contentTTL
+}
+
+// rememberContent remembers the hash of a queued item, dropping the hashes
+// no longer visible whenever their number has doubled. The queue lock must
+// be held.
+func (pq *PriorityQueue) rememberContent(hash string, item *QItem) {
+	if hash == "" {
+		return
+	}
+	now := pq.now()
+	if pq.hashes == nil {
+		pq.hashes = make(map[string]hashedItem)
+	}
+	if len(pq.hashes) >= 2*pq.hashesKept {
+		for k, h := range pq.hashes {
+			if !pq.visible(h, now) {
+				delete(pq.hashes, k)
+			}
+		}
+		pq.hashesKept = len(pq.hashes) + 1
+	}
+	pq.hashes[hash] = hashedItem{item: item, seq: item.seq, at: now}
+}
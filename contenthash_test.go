@@ -0,0 +1,66 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_ContentHashMergesPushes(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	pq.SetContentHash(func(v interface{}) string { return fmt.Sprint(v) }, time.Minute)
+
+	pq.Push(QItem{ID: "1", Value: "rebuild", Priority: 1})
+	res, _ := pq.PushInfo(QItem{ID: "2", Value: "rebuild", Priority: 3})
+	assertEqual(t, res.Merged, true)
+	pq.Push(QItem{ID: "3", Value: "reindex", Priority: 2})
+	assertEqual(t, pq.Len(), 2)
+	assertEqual(t, pq.Stats().Merged, 1)
+
+	// Each merge slides the window
+	advance(50 * time.Second)
+	pq.Push(QItem{ID: "4", Value: "rebuild"})
+	advance(50 * time.Second)
+	pq.Push(QItem{ID: "5", Value: "rebuild"})
+	assertEqual(t, pq.Len(), 2)
+
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "1")
+	assertEqual(t, item.Priority, 3)
+	assertEqual(t, item.Merged, 3)
+
+	// The popped item no longer absorbs pushes
+	pq.Push(QItem{ID: "6", Value: "rebuild"})
+	assertEqual(t, pq.Len(), 2)
+}
+
+func Test_ContentHashTTL(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	pq.SetContentHash(func(v interface{}) string { return fmt.Sprint(v) }, time.Minute)
+
+	pq.Push(QItem{ID: "1", Value: "rebuild"})
+	advance(time.Minute)
+	pq.Push(QItem{ID: "2", Value: "rebuild"})
+	pq.Push(QItem{ID: "3", Value: "rebuild"})
+	assertEqual(t, pq.Len(), 2)
+	assertEqual(t, pq.Stats().Merged, 1)
+}
+
+func Test_ContentHashOffloadedValues(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	pq, _ := New(WithBlobStore(store, 0))
+	pq.SetContentHash(func(v interface{}) string { return fmt.Sprint(v) }, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		pq.Push(QItem{ID: fmt.Sprint(i), Value: "rebuild"})
+	}
+	assertEqual(t, pq.Len(), 1)
+	// Merged pushes store no blob
+	assertEqual(t, countFiles(t, dir), 1)
+	item, _ := pq.Pop()
+	assertEqual(t, item.Value, "rebuild")
+	assertEqual(t, item.Merged, 2)
+}
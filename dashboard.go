@@ -0,0 +1,155 @@
+package priorityqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DashboardRows is the number of parents and items the dashboard lists
+const DashboardRows = 10
+
+// dashboardData is rendered by the dashboard, as HTML or JSON
+type dashboardData struct {
+	Time         time.Time
+	Stats        Stats
+	Depth        []DepthSample
+	TopParents   []ParentCount
+	Oldest       []QItem
+	States       map[string]int
+	Dead         []DeadLetter
+	Capabilities Capabilities
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"age": func(now, t time.Time) string {
+		return now.Sub(t).Truncate(time.Millisecond).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>Priority queue</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border-bottom: 1px solid #ddd; padding: 0.3em 1em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Priority queue</h1>
+<p>{{.Stats.Len}} items queued at {{.Time.Format "2006-01-02 15:04:05 MST"}}</p>
+{{with .Capabilities}}<p>Enabled:{{if .Persistence}} persistence{{end}}{{if .Fairness}} fairness{{end}}{{if .DeadLetters}} dead letters{{end}}{{if .Dedupe}} dedupe{{end}}{{if .Admission}} admission{{end}}{{if .Authorization}} authorization{{end}}{{if .Audit}} audit{{end}}{{if .Sweep}} sweep{{end}}</p>{{end}}
+
+<h2>Depth</h2>
+{{with .Sparkline}}<svg width="600" height="100" viewBox="0 0 600 100"><polyline fill="none" stroke="#36c" stroke-width="2" points="{{.}}"/></svg>{{else}}<p>No samples yet</p>{{end}}
+
+<h2>Top parents</h2>
+<table>
+<tr><th>ParentID</th><th>Items</th></tr>
+{{range .TopParents}}<tr><td>{{.ParentID}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+
+<h2>Oldest items</h2>
+<table>
+<tr><th>ID</th><th>ParentID</th><th>Priority</th><th>Producer</th><th>Age</th></tr>
+{{$now := .Time}}{{range .Oldest}}<tr><td>{{.ID}}</td><td>{{.ParentID}}</td><td>{{.Priority}}</td><td>{{.Producer}}</td><td>{{age $now .PushedAt}}</td></tr>
+{{end}}</table>
+
+<h2>Item states</h2>
+<table>
+<tr><th>State</th><th>Items</th></tr>
+{{range $state, $n := .States}}<tr><td>{{$state}}</td><td>{{$n}}</td></tr>
+{{end}}</table>
+
+{{with .Dead}}<h2>Dead letters</h2>
+<table>
+<tr><th>ID</th><th>ParentID</th><th>Priority</th><th>Reason</th><th>Age</th></tr>
+{{range .}}<tr><td>{{.Item.ID}}</td><td>{{.Item.ParentID}}</td><td>{{.Item.Priority}}</td><td>{{.Reason}}</td><td>{{age $now .At}}</td></tr>
+{{end}}</table>{{end}}
+
+{{with .Stats.Rejected}}<h2>Rejected pushes</h2>
+<table>
+<tr><th>Producer</th><th>Rate limited</th><th>Over quota</th></tr>
+{{range $producer, $r := .}}<tr><td>{{$producer}}</td><td>{{$r.RateLimited}}</td><td>{{$r.QuotaExceeded}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+// Dashboard returns a handler rendering a minimal HTML dashboard of the queue:
+// depth over time, the parents with the most items, the oldest items, the
+// number of items in each state and the latest dead letters.
+// Requesting it with ?format=json returns the same data as JSON. Depth is
+// sampled every 10 seconds for the last hour unless RecordDepth was called.
+//
+//	mux.Handle("/pq/", pq.Dashboard())
+func (pq *PriorityQueue) Dashboard() http.Handler {
+	pq.m.Lock()
+	if pq.depth == nil {
+		pq.depth = &depthHistory{interval: 10 * time.Second, samples: make([]DepthSample, 360)}
+	}
+	pq.m.Unlock()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := dashboardData{
+			Time:       time.Now(),
+			Stats:      pq.Stats(),
+			Depth:      pq.DepthHistory(),
+			TopParents: pq.TopParents(DashboardRows),
+			Oldest:     pq.OldestN(DashboardRows),
+			States:     make(map[string]int),
+			Dead:       pq.DeadLetters(),
+
+			Capabilities: pq.Capabilities(),
+		}
+		for s, n := range pq.StateCounts() {
+			data.States[s.String()] = n
+		}
+		if len(data.Dead) > DashboardRows {
+			data.Dead = data.Dead[len(data.Dead)-DashboardRows:]
+		}
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(data)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := dashboardTemplate.Execute(w, struct {
+			dashboardData
+			Sparkline string
+		}{data, sparkline(data.Depth, 600, 100)})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// sparkline returns SVG polyline points plotting the samples in a box of
+// the given size.
+func sparkline(samples []DepthSample, width, height int) string {
+	if len(samples) < 2 {
+		return ""
+	}
+	max := 1
+	for _, s := range samples {
+		if s.Len > max {
+			max = s.Len
+		}
+	}
+	start, span := samples[0].Time, samples[len(samples)-1].Time.Sub(samples[0].Time)
+	if span <= 0 {
+		span = 1
+	}
+	points := make([]string, len(samples))
+	for n, s := range samples {
+		x := float64(width) * float64(s.Time.Sub(start)) / float64(span)
+		y := float64(height) - float64(height)*float64(s.Len)/float64(max)
+		points[n] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return strings.Join(points, " ")
+}
@@ -0,0 +1,51 @@
+package priorityqueue
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Dashboard(t *testing.T) {
+	pq := NewPriorityQueue()
+	h := pq.Dashboard()
+	pq.RecordDepth(0, 10)
+	populateQueue(pq, 5)
+	pq.Push(QItem{ID: "<script>", ParentID: "other", PushedAt: time.Now().Add(-time.Hour)})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/pq/", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"6 items queued", "<polyline", "<td>12345</td><td>5</td>", "&lt;script&gt;"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Dashboard is missing %q", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/pq/?format=json", nil))
+	var data dashboardData
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatalf("Error decoding dashboard JSON: %v", err)
+	}
+	assertEqual(t, data.Stats.Len, 6)
+	assertEqual(t, data.Oldest[0].ID, "<script>")
+	assertEqual(t, data.TopParents[0].ParentID, "12345")
+}
+
+func Test_DepthHistory(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, len(pq.DepthHistory()), 0)
+	pq.RecordDepth(0, 3)
+	populateQueue(pq, 5)
+
+	h := pq.DepthHistory()
+	assertEqual(t, len(h), 3)
+	assertEqual(t, h[2].Len, 5)
+
+	pq.RecordDepth(time.Hour, 3)
+	populateQueue(pq, 5)
+	assertEqual(t, len(pq.DepthHistory()), 1)
+}
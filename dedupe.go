@@ -0,0 +1,75 @@
+package priorityqueue
+
+import "time"
+
+// completion records when the item with an idempotency key was acked
+type completion struct {
+	key string
+	at  time.Time
+}
+
+// SetDedupeWindow makes the queue remember, for d, the IdempotencyKey of
+// every item acked or committed. While a key is remembered, pushes of items
+// with the same key are suppressed and queued duplicates are dropped instead
+// of being handed out, so retried and redelivered items are processed once.
+// Items without a key are never deduplicated. Zero, the default, disables
+// deduplication.
+func (pq *PriorityQueue) SetDedupeWindow(d time.Duration) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.setDedupeWindow(d)
+}
+
+// setDedupeWindow is SetDedupeWindow; the queue lock must be held
+func (pq *PriorityQueue) setDedupeWindow(d time.Duration) {
+	pq.dedupeWindow = d
+	if d == 0 {
+		pq.completed, pq.completions = nil, nil
+	}
+}
+
+// complete remembers the idempotency key of an acked item. The queue lock
+// must be held.
+func (pq *PriorityQueue) complete(item *QItem) {
+	if pq.dedupeWindow == 0 || item.IdempotencyKey == "" {
+		return
+	}
+	if pq.completed == nil {
+		pq.completed = make(map[string]time.Time)
+	}
+	now := pq.now()
+	pq.completed[item.IdempotencyKey] = now
+	pq.completions = append(pq.completions, completion{key: item.IdempotencyKey, at: now})
+}
+
+// isDuplicate reports whether an item with the same idempotency key was
+// acked within the dedupe window. The queue lock must be held.
+func (pq *PriorityQueue) isDuplicate(item *QItem) bool {
+	if len(pq.completed) == 0 || item.IdempotencyKey == "" {
+		return false
+	}
+	cutoff := pq.now().Add(-pq.dedupeWindow)
+	for len(pq.completions) > 0 && !pq.completions[0].at.After(cutoff) {
+		c := pq.completions[0]
+		pq.completions = pq.completions[1:]
+		if !pq.completed[c.key].After(cutoff) {
+			delete(pq.completed, c.key)
+		}
+	}
+	_, ok := pq.completed[item.IdempotencyKey]
+	return ok
+}
+
+// suppress counts a duplicate that was not pushed. The queue lock must be held.
+func (pq *PriorityQueue) suppress(item *QItem) {
+	pq.deduplicated++
+	pq.audit(OpDedupe, item)
+}
+
+// dropDuplicates removes the queued duplicates of completed items from the
+// top of the queue. The queue lock must be held.
+func (pq *PriorityQueue) dropDuplicates() {
+	for pq.purgeHead(); len(pq.data) > 0 && pq.isDuplicate(pq.data[0]); pq.purgeHead() {
+		pq.suppress(pq.remove(0, StateDeleted))
+	}
+}
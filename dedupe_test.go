@@ -0,0 +1,52 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_DedupeSuppressesPush(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	pq.SetDedupeWindow(time.Minute)
+
+	pq.Push(QItem{ID: "1", IdempotencyKey: "order-1"})
+	_, r, _ := pq.Lease(time.Minute)
+	pq.Ack(r)
+
+	if err := pq.Push(QItem{ID: "2", IdempotencyKey: "order-1"}); err != nil {
+		t.Errorf("Error pushing a duplicate: %v", err)
+	}
+	pq.Push(QItem{ID: "3"})
+	assertEqual(t, pq.Len(), 1)
+	assertEqual(t, pq.Stats().Deduplicated, 1)
+
+	advance(time.Minute)
+	pq.Push(QItem{ID: "4", IdempotencyKey: "order-1"})
+	assertEqual(t, pq.Len(), 2)
+}
+
+func Test_DedupeDropsQueuedDuplicates(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetDedupeWindow(time.Minute)
+	pq.Push(QItem{ID: "1", Priority: 3, IdempotencyKey: "order-1"})
+	pq.Push(QItem{ID: "2", Priority: 2, IdempotencyKey: "order-1"})
+	pq.Push(QItem{ID: "3", Priority: 1})
+
+	r, _ := pq.Reserve()
+	r.Commit()
+
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "3")
+	assertEqual(t, pq.State("2"), StateDeleted)
+	assertEqual(t, pq.Stats().Deduplicated, 1)
+}
+
+func Test_DedupeDisabled(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "1", IdempotencyKey: "order-1"})
+	_, r, _ := pq.Lease(time.Minute)
+	pq.Ack(r)
+	pq.Push(QItem{ID: "2", IdempotencyKey: "order-1"})
+	assertEqual(t, pq.Len(), 1)
+}
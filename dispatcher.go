@@ -0,0 +1,310 @@
+package priorityqueue
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Handler processes one item popped by a Dispatcher
+type Handler func(ctx context.Context, item *QItem) error
+
+// A Dispatcher pops items from a queue with a pool of worker goroutines and
+// hands each one to a Handler.
+//
+// Workers carry the pprof labels "queue" and "worker", and each item is
+// handled with an additional "parent_id" label inside a runtime/trace region
+// named "priorityqueue.handle", so CPU profiles and execution traces
+// attribute time to queues and parents.
+//
+// The context of a handler is done once the ExpiresAt of its item passes,
+// and items popped past their ExpiresAt are not handled at all but handed
+// to OnExpired.
+type Dispatcher struct {
+	pq      *PriorityQueue
+	name    string
+	workers int
+	handler Handler
+
+	// OnError, if set, is called with every item whose handler failed
+	OnError func(item *QItem, err error)
+
+	// OnExpired, if set, is called with every item skipped because its
+	// ExpiresAt passed before a worker could handle it
+	OnExpired func(item *QItem)
+
+	// LeaseTimeout, if set, makes the workers lease the items rather than
+	// pop them, as "<name>/<worker>" so InFlight tells which worker holds
+	// an item: an item is acked once handled, and nacked with the error of
+	// its handler if it failed or expired.
+	LeaseTimeout time.Duration
+
+	// Budget, if set, caps the total Cost of the items handled at once, so
+	// a costly item takes the place of several cheap ones. A worker waits
+	// with its item until enough of the budget is free; an item costing
+	// more than the whole budget runs alone.
+	Budget int
+
+	// SpeculateAfter, if set, is the percentile of the latencies of the
+	// handler, such as 0.95, past which a second run of the handler is
+	// started for the same item. Whichever run succeeds first completes the
+	// item and the context of the other one is canceled, so the item is
+	// acked once; a handler that must not run twice at once should not be
+	// speculated on. Speculation starts once SpeculationSamples items have
+	// been handled.
+	SpeculateAfter float64
+
+	budget     *costBudget
+	latencies  *latencies
+	speculated int64
+}
+
+// SpeculationSamples is the number of latest latencies of its handler from
+// which a Dispatcher computes the SpeculateAfter percentile
+const SpeculationSamples = 100
+
+// NewDispatcher returns a Dispatcher running handler on items popped from pq
+// by the given number of workers. The name identifies the queue in
+// profiles and traces.
+func NewDispatcher(pq *PriorityQueue, name string, workers int, handler Handler) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Dispatcher{
+		pq:      pq,
+		name:    name,
+		workers: workers,
+		handler: handler,
+	}
+}
+
+// Run processes items until ctx is done, then waits for the handlers in
+// progress to return.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	if d.Budget > 0 {
+		d.budget = newCostBudget(d.Budget)
+	}
+	if d.SpeculateAfter > 0 {
+		d.latencies = &latencies{samples: make([]time.Duration, 0, SpeculationSamples)}
+	}
+	var wg sync.WaitGroup
+	for n := 0; n < d.workers; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			worker := strconv.Itoa(n)
+			labels := pprof.Labels("queue", d.name, "worker", worker)
+			pprof.Do(ctx, labels, func(ctx context.Context) {
+				d.work(ctx, d.name+"/"+worker)
+			})
+		}(n)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (d *Dispatcher) work(ctx context.Context, worker string) {
+	for {
+		if d.LeaseTimeout <= 0 {
+			item, err := d.pq.PopWait(ctx)
+			if err != nil {
+				return
+			}
+			d.handle(ctx, item)
+			continue
+		}
+		item, r, err := d.pq.LeaseWaitAs(ctx, worker, d.LeaseTimeout)
+		if err != nil {
+			return
+		}
+		if err := d.handle(ctx, item); err != nil {
+			d.pq.NackError(r, 0, err)
+		} else {
+			d.pq.Ack(r)
+		}
+	}
+}
+
+func (d *Dispatcher) handle(ctx context.Context, item *QItem) (err error) {
+	if !item.ExpiresAt.IsZero() {
+		left := item.ExpiresAt.Sub(d.pq.now())
+		if left <= 0 {
+			if d.OnExpired != nil {
+				d.OnExpired(item)
+			}
+			return context.DeadlineExceeded
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, left)
+		defer cancel()
+	}
+	release := func() {}
+	if d.budget != nil {
+		cost := d.budget.take(item.Cost)
+		release = func() { d.budget.give(cost) }
+	}
+	pprof.Do(ctx, pprof.Labels("parent_id", item.ParentID), func(ctx context.Context) {
+		trace.WithRegion(ctx, "priorityqueue.handle", func() {
+			err = d.run(ctx, item, release)
+		})
+		if err != nil && d.OnError != nil {
+			d.OnError(item, err)
+		}
+	})
+	return err
+}
+
+// A costBudget is the Budget of a running Dispatcher
+type costBudget struct {
+	m     sync.Mutex
+	freed *sync.Cond
+	total int
+	left  int
+}
+
+func newCostBudget(total int) *costBudget {
+	b := &costBudget{total: total, left: total}
+	b.freed = sync.NewCond(&b.m)
+	return b
+}
+
+// take waits until cost is free in the budget and takes it, counting a
+// cost below 1 as 1 and one above the budget as the whole budget. It
+// returns the cost taken, to give back. Only the handlers in progress hold
+// the budget, so it is always given back eventually.
+func (b *costBudget) take(cost int) int {
+	if cost < 1 {
+		cost = 1
+	}
+	if cost > b.total {
+		cost = b.total
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	for b.left < cost {
+		b.freed.Wait()
+	}
+	b.left -= cost
+	return cost
+}
+
+// tryTake is take failing rather than waiting if cost is not free
+func (b *costBudget) tryTake(cost int) (int, bool) {
+	cost = max(min(cost, b.total), 1)
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.left < cost {
+		return 0, false
+	}
+	b.left -= cost
+	return cost, true
+}
+
+func (b *costBudget) give(cost int) {
+	b.m.Lock()
+	b.left += cost
+	b.m.Unlock()
+	b.freed.Broadcast()
+}
+
+// run runs the handler on item, speculating on a second run if the first
+// one takes longer than the SpeculateAfter percentile and the Budget has
+// room for it. Each run gives its share of the budget back once its handler
+// returned, release for the first one, so a run outliving the other one
+// still counts.
+func (d *Dispatcher) run(ctx context.Context, item *QItem, release func()) error {
+	if d.latencies == nil {
+		defer release()
+		return d.handler(ctx, item)
+	}
+	start := time.Now()
+	after := d.latencies.percentile(d.SpeculateAfter)
+	if after <= 0 {
+		defer release()
+		err := d.handler(ctx, item)
+		d.latencies.observe(time.Since(start))
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan error, 2)
+	launch := func(release func()) {
+		i := *item
+		go func() {
+			defer release()
+			results <- d.handler(ctx, &i)
+		}()
+	}
+	launch(release)
+	speculate := time.NewTimer(after)
+	defer speculate.Stop()
+	running := 1
+	for {
+		select {
+		case err := <-results:
+			// A failed run leaves the item to the other one
+			if running--; err == nil || running == 0 {
+				d.latencies.observe(time.Since(start))
+				return err
+			}
+		case <-speculate.C:
+			release := func() {}
+			if d.budget != nil {
+				cost, ok := d.budget.tryTake(item.Cost)
+				if !ok {
+					continue // No room for a second run
+				}
+				release = func() { d.budget.give(cost) }
+			}
+			atomic.AddInt64(&d.speculated, 1)
+			running++
+			launch(release)
+		}
+	}
+}
+
+// Speculated returns the number of second runs of the handler started, see
+// SpeculateAfter
+func (d *Dispatcher) Speculated() int {
+	return int(atomic.LoadInt64(&d.speculated))
+}
+
+// latencies keeps the latest latencies of a handler
+type latencies struct {
+	m       sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (l *latencies) observe(d time.Duration) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if len(l.samples) < cap(l.samples) {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % len(l.samples)
+}
+
+// percentile returns the latency below which the fraction p of the samples
+// falls, zero until SpeculationSamples latencies are known
+func (l *latencies) percentile(p float64) time.Duration {
+	l.m.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.m.Unlock()
+	if len(sorted) < SpeculationSamples {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if p > 1 {
+		p = 1
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
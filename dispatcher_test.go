@@ -0,0 +1,241 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_PopWait(t *testing.T) {
+	pq := NewPriorityQueue()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		pq.Push(QItem{ID: "late"})
+	}()
+	x, err := pq.PopWait(context.Background())
+	if err != nil {
+		t.Fatalf("Error waiting for an item: %v", err)
+	}
+	assertEqual(t, x.ID, "late")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pq.PopWait(ctx)
+	assertEqual(t, err, context.DeadlineExceeded)
+}
+
+func Test_Dispatcher(t *testing.T) {
+	pq := NewPriorityQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var m sync.Mutex
+	handled := make(map[string]string)
+	var failed []string
+	done := make(chan struct{})
+	d := NewDispatcher(pq, "jobs", 3, func(ctx context.Context, item *QItem) error {
+		queue, _ := pprof.Label(ctx, "queue")
+		parent, _ := pprof.Label(ctx, "parent_id")
+		m.Lock()
+		defer m.Unlock()
+		handled[item.ID] = queue + "/" + parent
+		if len(handled) == 10 {
+			close(done)
+		}
+		if item.ID == "3" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	d.OnError = func(item *QItem, err error) {
+		m.Lock()
+		defer m.Unlock()
+		failed = append(failed, item.ID)
+	}
+
+	result := make(chan error)
+	go func() { result <- d.Run(ctx) }()
+	populateQueue(pq, 10)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Dispatcher did not handle every item")
+	}
+	cancel()
+	assertEqual(t, <-result, context.Canceled)
+
+	m.Lock()
+	defer m.Unlock()
+	assertEqual(t, handled["0"], "jobs/12345")
+	assertEqual(t, len(failed), 1)
+}
+
+func Test_DispatcherLeases(t *testing.T) {
+	pq := NewPriorityQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	claims := make(chan []Claim, 1)
+	d := NewDispatcher(pq, "jobs", 1, func(ctx context.Context, item *QItem) error {
+		if item.ID == "fail" {
+			claims <- pq.InFlight()
+			return errors.New("boom")
+		}
+		return nil
+	})
+	d.LeaseTimeout = time.Minute
+	pq.SetMaxAttempts(1)
+	pq.Push(QItem{ID: "ok", Priority: 2})
+	pq.Push(QItem{ID: "fail", Priority: 1})
+	go d.Run(ctx)
+
+	c := <-claims
+	assertEqual(t, len(c), 1)
+	assertEqual(t, c[0].Worker, "jobs/0")
+	for pq.StateCounts()[StateDeadLettered] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(t, pq.State("ok"), StateAcked)
+	assertEqual(t, pq.DeadLetters()[0].Reason, "boom after 1 attempts")
+}
+
+func Test_DispatcherDeadlines(t *testing.T) {
+	now := time.Now()
+	pq, _ := New(WithClock(func() time.Time { return now }))
+	var deadline time.Time
+	var handled, expired []string
+	d := NewDispatcher(pq, "jobs", 1, func(ctx context.Context, item *QItem) error {
+		handled = append(handled, item.ID)
+		deadline, _ = ctx.Deadline()
+		return nil
+	})
+	d.OnExpired = func(item *QItem) { expired = append(expired, item.ID) }
+
+	ctx := context.Background()
+	d.handle(ctx, &QItem{ID: "doomed", ExpiresAt: now.Add(-time.Second)})
+	d.handle(ctx, &QItem{ID: "due", ExpiresAt: now.Add(time.Minute)})
+	assertEqual(t, len(handled), 1)
+	assertEqual(t, handled[0], "due")
+	assertEqual(t, len(expired), 1)
+	assertEqual(t, expired[0], "doomed")
+	if left := time.Until(deadline); left <= 0 || left > time.Minute {
+		t.Errorf("Handler got %v before its deadline, expected up to a minute", left)
+	}
+
+	d.handle(ctx, &QItem{ID: "open"})
+	assertEqual(t, deadline.IsZero(), true)
+}
+
+func Test_DispatcherBudget(t *testing.T) {
+	pq := NewPriorityQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The huge item counts as the whole budget, so it runs alone
+	var m sync.Mutex
+	running, peak, handled := 0, 0, 0
+	done := make(chan struct{})
+	d := NewDispatcher(pq, "jobs", 4, func(ctx context.Context, item *QItem) error {
+		cost := item.Cost
+		if cost > 4 {
+			cost = 4
+		}
+		m.Lock()
+		if running += cost; running > peak {
+			peak = running
+		}
+		m.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		m.Lock()
+		defer m.Unlock()
+		running -= cost
+		if handled++; handled == 6 {
+			close(done)
+		}
+		return nil
+	})
+	d.Budget = 4
+	for n := 0; n < 5; n++ {
+		pq.Push(QItem{ID: strconv.Itoa(n), Cost: 2})
+	}
+	pq.Push(QItem{ID: "huge", Cost: 10, Priority: 1})
+	go d.Run(ctx)
+	<-done
+	m.Lock()
+	defer m.Unlock()
+	assertEqual(t, peak, 4)
+}
+
+func Test_DispatcherSpeculation(t *testing.T) {
+	pq := NewPriorityQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first run of the straggler hangs until canceled
+	var m sync.Mutex
+	runs := 0
+	canceled := make(chan struct{})
+	done := make(chan struct{})
+	d := NewDispatcher(pq, "jobs", 1, func(ctx context.Context, item *QItem) error {
+		if item.ID != "straggler" {
+			time.Sleep(time.Millisecond)
+			return nil
+		}
+		m.Lock()
+		runs++
+		first := runs == 1
+		m.Unlock()
+		if first {
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		}
+		close(done)
+		return nil
+	})
+	d.SpeculateAfter = 0.9
+	for n := 0; n < SpeculationSamples; n++ {
+		pq.Push(QItem{ID: strconv.Itoa(n), Priority: 1})
+	}
+	pq.Push(QItem{ID: "straggler"})
+	go d.Run(ctx)
+
+	<-done
+	<-canceled
+	if d.Speculated() < 1 {
+		t.Errorf("Speculated %d runs, expected at least 1", d.Speculated())
+	}
+}
+
+func Test_DispatcherSpeculationBudget(t *testing.T) {
+	pq := NewPriorityQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The budget only fits one run, so the straggler is not speculated on
+	done := make(chan struct{})
+	d := NewDispatcher(pq, "jobs", 1, func(ctx context.Context, item *QItem) error {
+		if item.ID != "straggler" {
+			time.Sleep(time.Millisecond)
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(done)
+		return nil
+	})
+	d.Budget = 1
+	d.SpeculateAfter = 0.9
+	for n := 0; n < SpeculationSamples; n++ {
+		pq.Push(QItem{ID: strconv.Itoa(n), Priority: 1})
+	}
+	pq.Push(QItem{ID: "straggler"})
+	go d.Run(ctx)
+
+	<-done
+	assertEqual(t, d.Speculated(), 0)
+}
@@ -0,0 +1,115 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDraining is returned by the pushes of items below the priority floor
+// of a draining queue, see StartDraining
+var ErrDraining = errors.New("queue is draining")
+
+// errDiverted reports an item pushed to the divert queue of a draining
+// queue rather than queued
+var errDiverted = errors.New("diverted")
+
+// drainFloor is the priority floor set by StartDraining
+type drainFloor struct {
+	floor  int
+	divert Queue
+}
+
+// StartDraining stops the queue accepting items of a priority below floor,
+// such as to prepare a node for shutdown so only critical work lands on it,
+// while it keeps serving the items it holds. Pushes below the floor fail
+// with ErrDraining, or go to divert unless it is nil, such as the queue of
+// another node: Push then returns what divert returns and PushInfo reports
+// the item Diverted. Delayed pushes are diverted with Push, the item due at
+// once. Seed diverts the items below the floor likewise, or skips them;
+// the other bulk loads, such as ImportNDJSON, are not affected. Divert is
+// called with the queue lock held and must not be the queue itself.
+func (pq *PriorityQueue) StartDraining(floor int, divert Queue) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.drainFloor = &drainFloor{floor: floor, divert: divert}
+}
+
+// StopDraining lets the queue accept every priority again
+func (pq *PriorityQueue) StopDraining() {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.drainFloor = nil
+}
+
+// Draining returns the priority floor set by StartDraining, and whether the
+// queue is draining
+func (pq *PriorityQueue) Draining() (int, bool) {
+	defer pq.lock(OpStats)()
+	if pq.drainFloor == nil {
+		return 0, false
+	}
+	return pq.drainFloor.floor, true
+}
+
+// admitFloor turns away i if it is below the floor of a draining queue,
+// returning ErrDraining, or errDiverted once it was pushed to the divert
+// queue. The queue lock must be held.
+func (pq *PriorityQueue) admitFloor(i *QItem) error {
+	d := pq.drainFloor
+	if d == nil || i.Priority >= d.floor {
+		return nil
+	}
+	if d.divert == nil {
+		return fmt.Errorf("%w: priority %d is below the floor %d", ErrDraining, i.Priority, d.floor)
+	}
+	if err := d.divert.Push(*i); err != nil {
+		return fmt.Errorf("diverting [%s]: %w", i.ID, err)
+	}
+	pq.diverted++
+	return errDiverted
+}
+
+// undiverted is err unless it reports a diverted item, which is no failure
+func undiverted(err error) error {
+	if err == errDiverted {
+		return nil
+	}
+	return err
+}
+
+// A DrainReport summarizes a DrainUntil run
+type DrainReport struct {
+	Processed int           // Items whose handler returned nil
+	Failed    int           // Items whose handler returned an error
+	Remaining int           // Items left in the queue when the run ended
+	Elapsed   time.Duration // How long the run took
+}
+
+// DrainUntil pops items in priority order and hands them to fn until the
+// queue is empty, frozen or the deadline has passed, then reports what was
+// done and what remains. The context passed to fn carries the deadline, so
+// a handler can stop early. Failed items are not queued again. The error is
+// that of ctx if it was done before the deadline.
+func (pq *PriorityQueue) DrainUntil(ctx context.Context, deadline time.Time, fn Handler) (DrainReport, error) {
+	var report DrainReport
+	start := time.Now()
+	dctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	for dctx.Err() == nil {
+		item, err := pq.Pop()
+		if err != nil {
+			break
+		}
+		if err := fn(dctx, item); err != nil {
+			report.Failed++
+		} else {
+			report.Processed++
+		}
+	}
+	report.Remaining = pq.Len()
+	report.Elapsed = time.Since(start)
+	return report, ctx.Err()
+}
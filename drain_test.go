@@ -0,0 +1,95 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_DrainUntilEmpty(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+
+	var order []int
+	report, err := pq.DrainUntil(context.Background(), time.Now().Add(time.Minute), func(ctx context.Context, item *QItem) error {
+		order = append(order, item.Priority)
+		if item.Priority == 5 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Error draining queue: %v", err)
+	}
+	assertEqual(t, report.Processed, 9)
+	assertEqual(t, report.Failed, 1)
+	assertEqual(t, report.Remaining, 0)
+	assertEqual(t, order[0], 10)
+}
+
+func Test_DrainUntilDeadline(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+
+	report, err := pq.DrainUntil(context.Background(), time.Now().Add(30*time.Millisecond), func(ctx context.Context, item *QItem) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Errorf("Error draining queue: %v", err)
+	}
+	assertEqual(t, report.Failed, 1)
+	assertEqual(t, report.Remaining, 9)
+}
+
+func Test_DrainUntilCanceled(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	report, err := pq.DrainUntil(ctx, time.Now().Add(time.Minute), func(ctx context.Context, item *QItem) error {
+		cancel()
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("Error draining with a canceled context: %v", err)
+	}
+	assertEqual(t, report.Processed, 1)
+	assertEqual(t, report.Remaining, 9)
+}
+
+func Test_DrainingFloor(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	pq.StartDraining(10, nil)
+	floor, ok := pq.Draining()
+	assertEqual(t, floor, 10)
+	assertEqual(t, ok, true)
+
+	// Low priority work is turned away, the queued work still served
+	assertEqual(t, errors.Is(pq.Push(QItem{ID: "low", Priority: 9}), ErrDraining), true)
+	assertEqual(t, pq.Push(QItem{ID: "high", Priority: 10}), nil)
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "high")
+	item, _ = pq.Pop()
+	assertEqual(t, item.ID, "2")
+
+	// Or diverted to another queue
+	other := NewPriorityQueue()
+	pq.StartDraining(10, other)
+	res, err := pq.PushInfo(QItem{Priority: 1})
+	assertEqual(t, err, nil)
+	assertEqual(t, res.Diverted, true)
+	assertEqual(t, pq.PushDelayed(QItem{ID: "later", Priority: 1}, time.Now().Add(time.Hour)), nil)
+	assertEqual(t, other.Len(), 2)
+	item, _ = other.Peek()
+	assertEqual(t, item.ID == res.ID || item.ID == "later", true)
+	assertEqual(t, pq.Stats().Diverted, 2)
+	assertEqual(t, pq.Len(), 2)
+
+	pq.StopDraining()
+	_, ok = pq.Draining()
+	assertEqual(t, ok, false)
+	assertEqual(t, pq.Push(QItem{ID: "low", Priority: 1}), nil)
+}
@@ -0,0 +1,124 @@
+package priorityqueue
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+)
+
+// An EscalationRule raises the priority of the items left waiting too long,
+// replacing the external jobs scanning the queue to reprioritize it.
+type EscalationRule struct {
+	Name string `json:"name,omitempty"` // Identifies the rule in errors
+
+	// ParentID selects the items of the matching ParentIDs, in the syntax
+	// of path.Match, compared as normalized by WithIDNormalizer; empty
+	// matches every item. Match, when set, further selects the items, for
+	// instance on their Value.
+	ParentID string  `json:"parent_id,omitempty"`
+	Match    Matcher `json:"-"`
+
+	// After is how long an item must have waited since it was pushed
+	After Duration `json:"after"`
+
+	// Priority is the priority the items are raised to. Items already at
+	// or above it are left alone, so each item is escalated once.
+	Priority int `json:"priority"`
+
+	// Callback, when set, is called with a copy of each item the rule
+	// escalates, after the queue lock has been released.
+	Callback func(QItem) `json:"-"`
+}
+
+func (r EscalationRule) validate() error {
+	if r.After < 0 {
+		return fmt.Errorf("escalation rule %q waits a negative %v", r.Name, time.Duration(r.After))
+	}
+	if _, err := path.Match(r.ParentID, ""); err != nil {
+		return fmt.Errorf("escalation rule %q: %w", r.Name, err)
+	}
+	return nil
+}
+
+// matches reports whether rule escalates item at now, comparing ParentIDs
+// by their key
+func (r EscalationRule) matches(item *QItem, now time.Time, key func(string) string) bool {
+	if item.Priority >= r.Priority || now.Sub(item.PushedAt) < time.Duration(r.After) {
+		return false
+	}
+	if r.ParentID != "" {
+		if ok, _ := path.Match(key(r.ParentID), key(item.ParentID)); !ok {
+			return false
+		}
+	}
+	return r.Match == nil || r.Match(item)
+}
+
+// SetEscalationRules replaces the escalation rules and makes a goroutine
+// owned by the queue apply them every interval. It may be called at any
+// time to change the rules. A zero interval keeps the rules for Escalate
+// but does not sweep; no rules, the default, escalate nothing.
+func (pq *PriorityQueue) SetEscalationRules(interval time.Duration, rules ...EscalationRule) error {
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.escalation = append([]EscalationRule(nil), rules...)
+	if pq.stopEscalation != nil {
+		pq.stopEscalation()
+		pq.stopEscalation = nil
+	}
+	if interval <= 0 || len(rules) == 0 || pq.stopped {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(pq.background())
+	pq.stopEscalation = cancel
+	pq.spawn(ctx, func(ctx context.Context) error {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				pq.Escalate()
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	return nil
+}
+
+// Escalate raises the priority of the queued items matching the escalation
+// rules, each rule in turn, and returns the number of escalations. Each one
+// is recorded in the audit log as an OpEscalate entry.
+func (pq *PriorityQueue) Escalate() (int, error) {
+	unlock := pq.lock(OpEscalate)
+	if err := pq.mutable(); err != nil {
+		unlock()
+		return 0, err
+	}
+	var callbacks []func()
+	escalated := 0
+	now := pq.now()
+	for _, rule := range pq.escalation {
+		for _, item := range pq.collect(func(i *QItem) bool { return rule.matches(i, now, pq.idKey) }) {
+			pq.reprioritize(item, rule.Priority)
+			pq.audit(OpEscalate, item)
+			escalated++
+			if fn := rule.Callback; fn != nil {
+				i := *item
+				callbacks = append(callbacks, func() { fn(i) })
+			}
+		}
+	}
+	unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
+	return escalated, nil
+}
@@ -0,0 +1,72 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Escalate(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	var escalated []string
+	err := pq.SetEscalationRules(0, EscalationRule{
+		Name:     "refunds",
+		ParentID: "billing/*",
+		Match:    func(i *QItem) bool { return i.Value == "refund" },
+		After:    Duration(10 * time.Minute),
+		Priority: 900,
+		Callback: func(i QItem) { escalated = append(escalated, i.ID) },
+	})
+	if err != nil {
+		t.Fatalf("Error setting the rules: %v", err)
+	}
+	now := pq.now()
+	pq.Push(QItem{ID: "1", ParentID: "billing/acme", Value: "refund", PushedAt: now})
+	pq.Push(QItem{ID: "2", ParentID: "billing/acme", Value: "invoice", PushedAt: now})
+	pq.Push(QItem{ID: "3", ParentID: "shipping", Value: "refund", PushedAt: now})
+	pq.Push(QItem{ID: "4", ParentID: "billing/acme", Value: "refund", PushedAt: now, Priority: 950})
+
+	n, _ := pq.Escalate()
+	assertEqual(t, n, 0)
+
+	advance(10 * time.Minute)
+	pq.Push(QItem{ID: "5", ParentID: "billing/other", Value: "refund", PushedAt: pq.now()})
+	n, _ = pq.Escalate()
+	assertEqual(t, n, 1)
+	assertEqual(t, len(escalated), 1)
+	assertEqual(t, escalated[0], "1")
+
+	items, _ := pq.sortedItems(OpStats, false)
+	assertEqual(t, items[1].ID, "1")
+	assertEqual(t, items[1].Priority, 900)
+
+	// Escalated items are left alone
+	n, _ = pq.Escalate()
+	assertEqual(t, n, 0)
+
+	assertEqual(t, pq.SetEscalationRules(0, EscalationRule{ParentID: "["}) != nil, true)
+}
+
+func Test_EscalationSweep(t *testing.T) {
+	pq := NewPriorityQueue(WithEscalationRules(5*time.Millisecond, EscalationRule{Priority: 100}))
+	assertEqual(t, pq.Capabilities().Escalation, true)
+	pq.Push(QItem{ID: "1"})
+
+	deadline := time.Now().Add(time.Second)
+	for item, _ := pq.Peek(); item.Priority != 100 && time.Now().Before(deadline); item, _ = pq.Peek() {
+		time.Sleep(time.Millisecond)
+	}
+	item, _ := pq.Peek()
+	assertEqual(t, item.Priority, 100)
+	pq.Destroy()
+}
+
+func Test_EscalateNormalizedParents(t *testing.T) {
+	pq := NewPriorityQueue(WithIDNormalizer(FoldCase))
+	advance := fakeClock(pq)
+	pq.SetEscalationRules(0, EscalationRule{ParentID: "Billing/*", After: Duration(time.Minute), Priority: 900})
+	pq.Push(QItem{ID: "1", ParentID: "BILLING/acme", PushedAt: pq.now()})
+	advance(time.Minute)
+	n, _ := pq.Escalate()
+	assertEqual(t, n, 1)
+}
@@ -0,0 +1,315 @@
+package priorityqueue
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// csvHeader names the columns written by ExportCSV
+var csvHeader = []string{"id", "parent_id", "priority", "value", "tenant", "producer", "pushed_at", "sync_token"}
+
+// sortedItems returns copies of the queued items, and of the items in flight
+// if inFlight is set, highest priority first. The queued items are copied
+// in chunks, see ForEach.
+func (pq *PriorityQueue) sortedItems(op Operation, inFlight bool) ([]*QItem, error) {
+	var items []*QItem
+	err := pq.forEach(op, func(item QItem) bool {
+		items = append(items, &item)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if inFlight {
+		// An item leased once its chunk was copied is in flight now: keep
+		// the lease only, the seq of a lease being that of its queued item
+		unlock := pq.lock(op)
+		leased := make(map[uint64]bool, len(pq.leases))
+		for _, l := range pq.leases {
+			c := l.item
+			leased[c.seq] = true
+			items = append(items, &c)
+		}
+		unlock()
+		kept := items[:0]
+		for _, item := range items {
+			if item.state != StateInFlight && leased[item.seq] {
+				continue
+			}
+			kept = append(kept, item)
+		}
+		items = kept
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return outranks(items[i], items[j], pq.tieBreak)
+	})
+	return items, nil
+}
+
+// An ImportMode says how ImportNDJSON and ImportCSV store the items they
+// read, see SetImportMode
+type ImportMode int
+
+const (
+	// ImportStored queues the items as they are read, their Values being in
+	// stored form, such as those written by ExportNDJSON
+	ImportStored ImportMode = iota
+
+	// ImportPushed admits the items as pushes are: their level is resolved
+	// and the Scorer, the ID generator, the Transformers and the BlobStore
+	// apply. Producer limits, deduplication and the drain floor do not.
+	ImportPushed
+)
+
+// SetImportMode sets how ImportNDJSON and ImportCSV store the items they
+// read. ImportStored, the default, suits files exported from a queue with
+// the same transformers; use ImportPushed for files from outside the queue,
+// so their Values meet the same policy as pushed ones. Restores always take
+// the Values stored as they are.
+func (pq *PriorityQueue) SetImportMode(mode ImportMode) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.importMode = mode
+}
+
+// pushAll adds items to the queue under a single lock, re-heapifying once.
+// Producer limits do not apply to bulk loads. Nothing is added unless the
+// Authorizer allows op on every item.
+func (pq *PriorityQueue) pushAll(op Operation, items []QItem) error {
+	defer pq.lock(op)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	if op == OpImport && pq.importMode == ImportPushed {
+		admitted, err := pq.admitAll(items)
+		if err != nil {
+			return err
+		}
+		items = admitted
+	}
+	if err := pq.authorize(context.Background(), op, ptrs(items)...); err != nil {
+		if op == OpImport && pq.importMode == ImportPushed {
+			pq.dropBlobs(items)
+		}
+		return err
+	}
+	pq.insertAll(op, items)
+	return nil
+}
+
+// admitAll returns copies of items admitted as ImportPushed says. Should
+// any fail to be admitted, it drops the Values offloaded for the others and
+// returns the error. The queue lock must be held.
+func (pq *PriorityQueue) admitAll(items []QItem) ([]QItem, error) {
+	admitted := make([]QItem, 0, len(items))
+	for _, item := range items {
+		err := pq.resolveLevel(&item)
+		if err == nil {
+			pq.score(&item)
+			pq.assignID(&item)
+			err = pq.transform(&item)
+		}
+		if err == nil {
+			err = pq.offload(&item)
+		}
+		if err != nil {
+			pq.dropBlobs(admitted)
+			return nil, err
+		}
+		pq.inherit(&item)
+		admitted = append(admitted, item)
+	}
+	return admitted, nil
+}
+
+// dropBlobs drops the Values offloaded for items. The queue lock must be
+// held.
+func (pq *PriorityQueue) dropBlobs(items []QItem) {
+	for _, item := range items {
+		pq.dropBlob(item.Value)
+	}
+}
+
+// ptrs returns pointers to each of items
+func ptrs(items []QItem) []*QItem {
+	p := make([]*QItem, len(items))
+	for n := range items {
+		p[n] = &items[n]
+	}
+	return p
+}
+
+// insertAll is pushAll; the queue lock must be held
+func (pq *PriorityQueue) insertAll(op Operation, items []QItem) {
+	head := pq.head()
+	for _, item := range items {
+		stamp(&item)
+		pq.assignPhase(&item)
+		stored := pq.newItem(item)
+		stored.index = len(pq.data)
+		pq.data = append(pq.data, stored)
+		pq.enqueued(stored)
+		pq.audit(op, stored)
+	}
+	pq.data.init(pq.tieBreak)
+	if len(items) > 0 {
+		pq.headChanged(head)
+		pq.wake()
+	}
+}
+
+// ExportNDJSON writes every queued item to w as one JSON object per line,
+// highest priority first, with the name of its priority level as level
+// when SetPriorityLevels registered levels.
+func (pq *PriorityQueue) ExportNDJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	items, err := pq.sortedItems(OpExport, false)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		rec := toItemRecord(item)
+		rec.Level = pq.LevelOf(item.Priority)
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ImportNDJSON reads one JSON object per line, in the format written by
+// ExportNDJSON, and pushes the items. Their Values are taken as stored, not
+// transformed nor offloaded, unless SetImportMode says otherwise. It
+// returns the number of items pushed; nothing is pushed if any line fails
+// to decode.
+func (pq *PriorityQueue) ImportNDJSON(r io.Reader) (int, error) {
+	var items []QItem
+	dec := json.NewDecoder(r)
+	for {
+		var rec itemRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("decoding record %d: %w", len(items)+1, err)
+		}
+		items = append(items, rec.qItem())
+	}
+	if err := pq.pushAll(OpImport, items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// ExportCSV writes every queued item to w as a CSV row with a header,
+// highest priority first. Values are formatted with fmt.Sprint.
+func (pq *PriorityQueue) ExportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	items, err := pq.sortedItems(OpExport, false)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		value := ""
+		if item.Value != nil {
+			value = fmt.Sprint(item.Value)
+		}
+		row := []string{
+			item.ID,
+			item.ParentID,
+			strconv.Itoa(item.Priority),
+			value,
+			item.Tenant,
+			item.Producer,
+			item.PushedAt.Format(time.RFC3339Nano),
+			item.SyncToken,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportCSV reads CSV rows and pushes them as items. The first row must be a
+// header naming the columns; id and priority are required, parent_id, value,
+// tenant, producer, pushed_at (RFC 3339) and sync_token are optional and
+// other columns are ignored. Values are imported as strings, an empty value
+// as nil, and taken as stored unless SetImportMode says otherwise, see
+// ImportNDJSON. It returns the number of items pushed; nothing is pushed if
+// any row is invalid.
+func (pq *PriorityQueue) ImportCSV(r io.Reader) (int, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return 0, fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for n, name := range header {
+		columns[name] = n
+	}
+	for _, name := range []string{"id", "priority"} {
+		if _, ok := columns[name]; !ok {
+			return 0, fmt.Errorf("CSV header is missing the %s column", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if n, ok := columns[name]; ok {
+			return row[n]
+		}
+		return ""
+	}
+
+	var items []QItem
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		priority, err := strconv.Atoi(field(row, "priority"))
+		if err != nil {
+			line, _ := cr.FieldPos(columns["priority"])
+			return 0, fmt.Errorf("line %d: invalid priority: %w", line, err)
+		}
+		item := QItem{
+			ID:       field(row, "id"),
+			ParentID: field(row, "parent_id"),
+			Priority: priority,
+			Tenant:   field(row, "tenant"),
+			Producer: field(row, "producer"),
+
+			SyncToken: field(row, "sync_token"),
+		}
+		if v := field(row, "pushed_at"); v != "" {
+			item.PushedAt, err = time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				line, _ := cr.FieldPos(columns["pushed_at"])
+				return 0, fmt.Errorf("line %d: invalid pushed_at: %w", line, err)
+			}
+		}
+		if v := field(row, "value"); v != "" {
+			item.Value = v
+		}
+		items = append(items, item)
+	}
+	if err := pq.pushAll(OpImport, items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
@@ -0,0 +1,114 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_NDJSONRoundTrip(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+
+	var buf bytes.Buffer
+	if err := pq.ExportNDJSON(&buf); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assertEqual(t, len(lines), 10)
+	if !strings.HasPrefix(lines[0], `{"id":"9","parent_id":"12345","value":"test","priority":10,"pushed_at":`) {
+		t.Errorf("Unexpected first record: %s", lines[0])
+	}
+
+	imported := NewPriorityQueue()
+	n, err := imported.ImportNDJSON(&buf)
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	assertEqual(t, n, 10)
+	x, _ := imported.Pop()
+	assertEqual(t, x.ID, "9")
+}
+
+func Test_ImportNDJSONInvalid(t *testing.T) {
+	pq := NewPriorityQueue()
+	_, err := pq.ImportNDJSON(strings.NewReader("{\"id\":\"a\",\"priority\":1}\nnot json\n"))
+	if err == nil {
+		t.Errorf("Importing invalid NDJSON should fail")
+	}
+	assertEqual(t, pq.Len(), 0)
+}
+
+func Test_CSVRoundTrip(t *testing.T) {
+	pq := NewPriorityQueue()
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	pq.Push(QItem{ID: "a", ParentID: "p", Priority: 1, Value: 42, Producer: "billing", PushedAt: at, SyncToken: "v7"})
+	pq.Push(QItem{ID: "b", Priority: 5, PushedAt: at})
+
+	var buf bytes.Buffer
+	if err := pq.ExportCSV(&buf); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	assertEqual(t, buf.String(), "id,parent_id,priority,value,tenant,producer,pushed_at,sync_token\n"+
+		"b,,5,,,,2020-01-02T03:04:05Z,\n"+
+		"a,p,1,42,,billing,2020-01-02T03:04:05Z,v7\n")
+
+	imported := NewPriorityQueue()
+	n, err := imported.ImportCSV(&buf)
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	assertEqual(t, n, 2)
+	imported.Pop()
+	x, _ := imported.Pop()
+	assertEqual(t, x.ParentID, "p")
+	assertEqual(t, x.Value, "42")
+	assertEqual(t, x.Producer, "billing")
+	assertEqual(t, x.PushedAt, at)
+	assertEqual(t, x.SyncToken, "v7")
+}
+
+func Test_ImportCSVInvalid(t *testing.T) {
+	pq := NewPriorityQueue()
+	if _, err := pq.ImportCSV(strings.NewReader("id,value\na,1\n")); err == nil {
+		t.Errorf("Importing CSV without a priority column should fail")
+	}
+	if _, err := pq.ImportCSV(strings.NewReader("priority,id\n1,a\nhigh,b\n")); err == nil {
+		t.Errorf("Importing CSV with an invalid priority should fail")
+	}
+	assertEqual(t, pq.Len(), 0)
+}
+
+func Test_ImportPushed(t *testing.T) {
+	pq, _ := New(WithTransformers(Transformer{
+		Name: "redact",
+		Push: func(item QItem) (interface{}, error) {
+			if item.Value == "bad" {
+				return nil, errors.New("unredactable")
+			}
+			return "redacted", nil
+		},
+	}))
+	const file = `{"id":"a","value":"secret","priority":1}` + "\n"
+
+	// Imported Values are taken as stored by default
+	pq.ImportNDJSON(strings.NewReader(file))
+	x, _ := pq.Peek()
+	assertEqual(t, x.Value, "secret")
+	pq.Clear()
+
+	pq.SetImportMode(ImportPushed)
+	n, err := pq.ImportNDJSON(strings.NewReader(file + `{"value":"secret","priority":2}` + "\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, n, 2)
+	for _, item := range pq.SortedView() {
+		assertEqual(t, item.Value, "redacted")
+		assertEqual(t, item.ID == "", false)
+	}
+
+	_, err = pq.ImportCSV(strings.NewReader("id,priority,value\nb,1,secret\nc,1,bad\n"))
+	assertEqual(t, errors.Is(err, ErrTransform), true)
+	assertEqual(t, pq.Len(), 2)
+}
@@ -0,0 +1,100 @@
+package priorityqueue
+
+import (
+	"context"
+	"sync"
+)
+
+// consumerPriority lets consumers waiting for the queue lock go ahead of
+// the producers arriving meanwhile, see SetConsumerPriority. Its own lock
+// guards it, as it is used before the queue lock is taken.
+type consumerPriority struct {
+	m       sync.Mutex
+	waiting int           // Consumers waiting for the queue lock
+	gate    chan struct{} // Closed once no consumer waits
+}
+
+// SetConsumerPriority makes the producers, Push, Import and PushBarrier,
+// wait for the consumers waiting for the queue lock, Pop, Lease and
+// Reserve, to get it before they contend for it, so Pop latency stays
+// bounded by the operations already contending however many pushes arrive
+// per second. Producers are held back for as long as consumers keep
+// waiting. Off by default, when the queue lock is granted in no particular
+// order.
+func (pq *PriorityQueue) SetConsumerPriority(on bool) {
+	if !on {
+		pq.consumerPriority.Store(nil)
+		return
+	}
+	if pq.consumerPriority.Load() == nil {
+		pq.consumerPriority.CompareAndSwap(nil, &consumerPriority{})
+	}
+}
+
+// WithConsumerPriority is SetConsumerPriority(true)
+func WithConsumerPriority() Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetConsumerPriority(true)
+		return nil
+	}
+}
+
+func noop() {}
+
+// yield is called before op contends for the queue lock. A consumer is
+// counted as waiting until it calls the function returned, once it holds
+// the lock; a producer waits for the waiting consumers first, until ctx is
+// done.
+func (pq *PriorityQueue) yield(ctx context.Context, op Operation) (func(), error) {
+	c := pq.consumerPriority.Load()
+	if c == nil {
+		return noop, nil
+	}
+	switch op {
+	case OpPop, OpLease, OpReserve:
+		c.m.Lock()
+		if c.waiting == 0 {
+			c.gate = make(chan struct{})
+		}
+		c.waiting++
+		c.m.Unlock()
+		return c.leave, nil
+	case OpPush, OpImport, OpPushBarrier:
+		for {
+			c.m.Lock()
+			gate, waiting := c.gate, c.waiting
+			c.m.Unlock()
+			if waiting == 0 {
+				return noop, nil
+			}
+			select {
+			case <-gate:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+	return noop, nil
+}
+
+// leave counts a consumer out of the waiting ones, letting the producers
+// go once none waits
+func (c *consumerPriority) leave() {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.waiting--; c.waiting == 0 {
+		close(c.gate)
+	}
+}
+
+// ConsumersWaiting returns the number of consumers waiting for the queue
+// lock ahead of the producers, see SetConsumerPriority
+func (pq *PriorityQueue) ConsumersWaiting() int {
+	c := pq.consumerPriority.Load()
+	if c == nil {
+		return 0
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.waiting
+}
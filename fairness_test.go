@@ -0,0 +1,41 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_ConsumerPriority(t *testing.T) {
+	pq, _ := New(WithConsumerPriority())
+	populateQueue(pq, 5)
+
+	pq.m.Lock()
+	popped := make(chan *QItem)
+	go func() {
+		item, _ := pq.Pop()
+		popped <- item
+	}()
+	for pq.ConsumersWaiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Producers wait for the consumer to get the lock first
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assertEqual(t, pq.PushCtx(ctx, QItem{ID: "timeout", Priority: 100}), context.DeadlineExceeded)
+	pushed := make(chan error)
+	go func() { pushed <- pq.Push(QItem{ID: "late", Priority: 100}) }()
+	time.Sleep(10 * time.Millisecond)
+	pq.m.Unlock()
+
+	assertEqual(t, (<-popped).ID, "4")
+	assertEqual(t, <-pushed, nil)
+	assertEqual(t, pq.ConsumersWaiting(), 0)
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "late")
+
+	pq.SetConsumerPriority(false)
+	assertEqual(t, pq.ConsumersWaiting(), 0)
+	assertEqual(t, pq.Push(QItem{ID: "off"}), nil)
+}
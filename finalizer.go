@@ -0,0 +1,48 @@
+package priorityqueue
+
+import "fmt"
+
+// AddFinalizer registers fn to run once the queue is done with, to flush
+// metrics, close a log or emit final stats: the first Stop to return in
+// time, or Destroy, runs the finalizers exactly once, the last registered
+// first as deferred calls do, and after the files of WithSnapshotFile and
+// WithRecordingFile are written. A finalizer that panics is reported as an
+// error and the others still run. Registered on a queue already finalized,
+// fn runs right away and AddFinalizer returns its error.
+func (pq *PriorityQueue) AddFinalizer(fn func() error) error {
+	pq.m.Lock()
+	if !pq.finalized {
+		pq.finalizers = append(pq.finalizers, fn)
+		pq.m.Unlock()
+		return nil
+	}
+	pq.m.Unlock()
+	return runFinalizer(fn)
+}
+
+// finalize runs the finalizers unless they ran already, returning the first
+// error
+func (pq *PriorityQueue) finalize() error {
+	pq.m.Lock()
+	fns := pq.finalizers
+	pq.finalizers, pq.finalized = nil, true
+	pq.m.Unlock()
+
+	var err error
+	for n := len(fns) - 1; n >= 0; n-- {
+		if ferr := runFinalizer(fns[n]); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// runFinalizer runs fn, turning a panic into an error
+func runFinalizer(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("finalizer panicked: %v", r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,42 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_Finalizers(t *testing.T) {
+	pq := NewPriorityQueue()
+	var ran []string
+	pq.AddFinalizer(func() error { ran = append(ran, "first"); return nil })
+	pq.AddFinalizer(func() error { ran = append(ran, "panics"); panic("boom") })
+	pq.AddFinalizer(func() error { ran = append(ran, "last"); return nil })
+
+	err := pq.Stop(context.Background())
+	if err == nil || err.Error() != "finalizer panicked: boom" {
+		t.Errorf("Error reporting the panic of a finalizer: %v", err)
+	}
+	if !reflect.DeepEqual(ran, []string{"last", "panics", "first"}) {
+		t.Errorf("Error running the finalizers in reverse order: %v", ran)
+	}
+
+	pq.Destroy()
+	assertEqual(t, pq.Stop(context.Background()), nil)
+	assertEqual(t, len(ran), 3)
+
+	boom := errors.New("late")
+	assertEqual(t, pq.AddFinalizer(func() error { ran = append(ran, "late"); return boom }), boom)
+	assertEqual(t, ran[3], "late")
+}
+
+func Test_FinalizersOnDestroy(t *testing.T) {
+	pq := NewPriorityQueue()
+	runs := 0
+	pq.AddFinalizer(func() error { runs++; return nil })
+	pq.Destroy()
+	pq.Destroy()
+	pq.Stop(context.Background())
+	assertEqual(t, runs, 1)
+}
@@ -0,0 +1,111 @@
+package priorityqueue
+
+import (
+	"math/bits"
+	"time"
+)
+
+// stagedPriority is a priority update waiting for the batch to be applied
+type stagedPriority struct {
+	seq      uint64 // Sequence number of the item, telling it from a reused one
+	priority int
+}
+
+// SetPriorityBatching batches the priority updates of
+// UpdatePriorityByParentId, UpdatePriorityByIds and their tree and pattern
+// variants for up to window: rather than reordering the heap at every
+// update, the new priorities are staged, the latest update of an item
+// replacing the earlier ones, and applied together once the window has
+// passed, at the next operation, or before an operation reading the order
+// or the priorities of the items, such as a Pop, a Lease, a Peek, a view or
+// a snapshot, or changing them otherwise. Applying k updates costs
+// O(k log n), or O(n) rebuilding the heap when cheaper, instead of the
+// O(log n) of every update, which pays off for scorers updating the same
+// items over and over. Pushes, acks and the counters, such as Len, State and
+// Stats, leave the batch alone: until it is applied Stats and MaxPriority
+// report the former priorities. Zero, the default, applies every update at
+// once.
+func (pq *PriorityQueue) SetPriorityBatching(window time.Duration) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.batchWindow = window
+	if window <= 0 {
+		pq.applyStaged()
+	}
+}
+
+// WithPriorityBatching is SetPriorityBatching
+func WithPriorityBatching(window time.Duration) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetPriorityBatching(window)
+		return nil
+	}
+}
+
+// batches reports whether op stages its priority updates rather than
+// applying the staged ones first
+func batches(op Operation) bool {
+	switch op {
+	case OpUpdatePriorityByParentId, OpUpdatePriorityByIds, OpUpdatePriorityByParentTree, OpUpdatePriorityByParentPattern:
+		return true
+	}
+	return false
+}
+
+// stage sets the priority of item once the batch is applied, or at once
+// without batching. The queue lock must be held.
+func (pq *PriorityQueue) stage(item *QItem, priority int) {
+	if pq.batchWindow <= 0 {
+		pq.reprioritize(item, priority)
+		return
+	}
+	if pq.staged == nil {
+		pq.staged = make(map[*QItem]stagedPriority)
+		pq.stagedDue = pq.now().Add(pq.batchWindow)
+	}
+	pq.staged[item] = stagedPriority{seq: item.seq, priority: priority}
+}
+
+// leavesBatch reports whether op neither reads nor changes the priorities
+// of the items, so the staged updates can wait
+func leavesBatch(op Operation) bool {
+	switch op {
+	case OpPush, OpLen, OpStats, OpState, OpHealthy, OpAck, OpExtendLease, OpCommit:
+		return true
+	}
+	return false
+}
+
+// applyStagedBefore applies the staged priority updates unless op takes
+// part in the batch or leaves it alone, and the window has not passed. The
+// queue lock must be held.
+func (pq *PriorityQueue) applyStagedBefore(op Operation) {
+	if pq.staged != nil && (!(batches(op) || leavesBatch(op)) || !pq.now().Before(pq.stagedDue)) {
+		pq.applyStaged()
+	}
+}
+
+// applyStaged applies the staged priority updates of the items still
+// queued, rebuilding the heap when that is cheaper than fixing each item.
+// The queue lock must be held.
+func (pq *PriorityQueue) applyStaged() {
+	staged := pq.staged
+	pq.staged = nil
+	n := len(pq.data)
+	if len(staged)*bits.Len(uint(n)) < n {
+		for item, s := range staged {
+			if pq.holds(item) && item.seq == s.seq && item.Priority != s.priority {
+				pq.reprioritize(item, s.priority)
+			}
+		}
+		return
+	}
+	head := pq.head()
+	for item, s := range staged {
+		if pq.holds(item) && item.seq == s.seq && item.Priority != s.priority {
+			pq.setPriority(item, s.priority)
+		}
+	}
+	pq.data.init(pq.tieBreak)
+	pq.headChanged(head)
+}
@@ -0,0 +1,60 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_PriorityBatching(t *testing.T) {
+	now := time.Now()
+	pq, _ := New(WithClock(func() time.Time { return now }), WithPriorityBatching(time.Minute))
+	populateQueue(pq, 10)
+
+	for p := 100; p < 110; p++ {
+		n, _ := pq.UpdatePriorityByIds([]string{"0", "1"}, p)
+		assertEqual(t, n, 2)
+	}
+	pq.UpdatePriorityByParentId("12345", 50)
+	pq.UpdatePriorityByIds([]string{"2"}, 200)
+	assertEqual(t, len(pq.staged), 10)
+	assertEqual(t, pq.data[0].ID, "9")
+
+	// Pushes and counters leave the batch alone
+	pq.Push(QItem{ID: "new", Priority: 1})
+	assertEqual(t, pq.Len(), 11)
+	max, _ := pq.MaxPriority()
+	assertEqual(t, max, 10)
+	assertEqual(t, len(pq.staged), 10)
+
+	// Reads of the order apply the batch first
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "2")
+	assertEqual(t, item.Priority, 200)
+	assertEqual(t, len(pq.staged), 0)
+	assertEqual(t, pq.Healthy(), nil)
+	max, _ = pq.MaxPriority()
+	assertEqual(t, max, 200)
+
+	// As does an update once the window has passed
+	pq.UpdatePriorityByIds([]string{"3"}, 300)
+	now = now.Add(time.Minute)
+	pq.UpdatePriorityByIds([]string{"4"}, 400)
+	assertEqual(t, len(pq.staged), 1)
+	assertEqual(t, pq.data[0].ID, "3")
+
+	// Without batching updates apply at once
+	pq.SetPriorityBatching(0)
+	assertEqual(t, len(pq.staged), 0)
+	pq.UpdatePriorityByIds([]string{"5"}, 500)
+	assertEqual(t, pq.data[0].ID, "5")
+
+	// A staged update of a slot now holding another item is dropped
+	pq.SetPriorityBatching(time.Minute)
+	pq.UpdatePriorityByIds([]string{"6"}, 600)
+	for item, s := range pq.staged {
+		pq.staged[item] = stagedPriority{seq: item.seq + 1, priority: s.priority}
+	}
+	item, _ = pq.Peek()
+	assertEqual(t, item.ID, "5")
+	assertEqual(t, pq.Healthy(), nil)
+}
@@ -0,0 +1,136 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// A PriorityChangeLimit limits how often and how far the priority of each
+// queued item may change, so a scorer oscillating between two priorities
+// does not thrash the heap order and starve the stable items. The changes
+// exceeding the limit are deferred: the latest one of each item is applied
+// once the limit allows it. Zero fields disable their limit.
+type PriorityChangeLimit struct {
+	// MinInterval is the least time between two changes of an item
+	MinInterval time.Duration `json:"min_interval,omitempty"`
+
+	// MaxDeltaPerMinute is how far the priority of an item may move in a
+	// minute, up and down added up; a change going further is cut short
+	// and completed the next minute.
+	MaxDeltaPerMinute int `json:"max_delta_per_minute,omitempty"`
+}
+
+// flapState tracks the priority changes of an item under the change limit
+type flapState struct {
+	seq     uint64    // Sequence number of the item, telling it from a reused one
+	last    time.Time // When the priority last changed
+	since   time.Time // Start of the minute counted by moved
+	moved   int       // Distance moved since since
+	pending bool      // Whether a change is deferred
+	target  int       // The priority the deferred change sets
+	due     time.Time // When the deferred change is tried again
+}
+
+// SetPriorityChangeLimit limits the priority changes made by
+// UpdatePriorityByParentId, UpdatePriorityByIds and their tree and pattern
+// variants. Stats().PriorityChangesDeferred counts the changes deferred.
+// The zero limit, the default, applies every change at once; setting it
+// forgets the changes deferred.
+func (pq *PriorityQueue) SetPriorityChangeLimit(l PriorityChangeLimit) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.changeLimit = l
+	pq.flaps, pq.flapTimers, pq.flapsKept = nil, nil, 0
+}
+
+// WithPriorityChangeLimit is SetPriorityChangeLimit
+func WithPriorityChangeLimit(l PriorityChangeLimit) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetPriorityChangeLimit(l)
+		return nil
+	}
+}
+
+// limitChange returns the priority item may take now on its way to target,
+// deferring the rest of the change. The queue lock must be held.
+func (pq *PriorityQueue) limitChange(item *QItem, target int, now time.Time) int {
+	l := pq.changeLimit
+	if l == (PriorityChangeLimit{}) {
+		return target
+	}
+	st := pq.flapState(item, now)
+	st.pending = false
+	next, due := target, time.Time{}
+	if l.MinInterval > 0 && !st.last.IsZero() && now.Sub(st.last) < l.MinInterval {
+		next, due = item.Priority, st.last.Add(l.MinInterval)
+	} else if l.MaxDeltaPerMinute > 0 {
+		if now.Sub(st.since) >= time.Minute {
+			st.since, st.moved = now, 0
+		}
+		left := l.MaxDeltaPerMinute - st.moved
+		switch d := target - item.Priority; {
+		case d > left:
+			next, due = item.Priority+left, st.since.Add(time.Minute)
+		case -d > left:
+			next, due = item.Priority-left, st.since.Add(time.Minute)
+		}
+		st.moved += abs(next - item.Priority)
+	}
+	if next != item.Priority {
+		st.last = now
+	}
+	if next != target {
+		st.pending, st.target, st.due = true, target, due
+		heap.Push(&pq.flapTimers, timer[*QItem]{at: due, v: item})
+		pq.changesDeferred++
+	}
+	return next
+}
+
+// flapState returns the change tracking of item, forgetting the items
+// whose limits have lapsed whenever their number has doubled. The queue
+// lock must be held.
+func (pq *PriorityQueue) flapState(item *QItem, now time.Time) *flapState {
+	if pq.flaps == nil {
+		pq.flaps = make(map[*QItem]*flapState)
+	}
+	if len(pq.flaps) >= 2*pq.flapsKept {
+		for i, st := range pq.flaps {
+			lapsed := now.Sub(st.last) >= pq.changeLimit.MinInterval && now.Sub(st.since) >= time.Minute
+			if !st.pending && (lapsed || i.seq != st.seq || !pq.holds(i)) {
+				delete(pq.flaps, i)
+			}
+		}
+		pq.flapsKept = len(pq.flaps) + 1
+	}
+	st, ok := pq.flaps[item]
+	if !ok || st.seq != item.seq {
+		st = &flapState{seq: item.seq}
+		pq.flaps[item] = st
+	}
+	return st
+}
+
+// applyDeferredChanges applies the deferred priority changes that fell
+// due, as far as the limit allows. The queue lock must be held.
+func (pq *PriorityQueue) applyDeferredChanges(now time.Time) {
+	for len(pq.flapTimers) > 0 && !now.Before(pq.flapTimers[0].at) {
+		t := heap.Pop(&pq.flapTimers).(timer[*QItem])
+		item := t.v
+		st, ok := pq.flaps[item]
+		if !ok || st.seq != item.seq || !st.pending || !st.due.Equal(t.at) || !pq.holds(item) {
+			continue // superseded, or no longer queued
+		}
+		if p := pq.limitChange(item, st.target, now); p != item.Priority {
+			pq.reprioritize(item, p)
+			pq.audit(OpDeferredPriority, item)
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
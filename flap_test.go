@@ -0,0 +1,73 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_PriorityChangeLimit(t *testing.T) {
+	now := time.Now()
+	pq, _ := New(WithClock(func() time.Time { return now }),
+		WithPriorityChangeLimit(PriorityChangeLimit{MinInterval: time.Second, MaxDeltaPerMinute: 10}))
+	populateQueue(pq, 5)
+
+	// The first change goes as far as the minute allows
+	pq.UpdatePriorityByIds([]string{"0"}, 50)
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "0")
+	assertEqual(t, item.Priority, 11)
+
+	// An oscillating scorer only moves the item once the interval passed,
+	// to its latest priority
+	now = now.Add(100 * time.Millisecond)
+	pq.UpdatePriorityByIds([]string{"0"}, 1)
+	pq.UpdatePriorityByIds([]string{"0"}, 50)
+	pq.UpdatePriorityByIds([]string{"0"}, 1)
+	item, _ = pq.Peek()
+	assertEqual(t, item.Priority, 11)
+	assertEqual(t, pq.Stats().PriorityChangesDeferred, 4)
+
+	// Nothing is left of the minute's budget
+	now = now.Add(time.Second)
+	item, _ = pq.Peek()
+	assertEqual(t, item.ID, "0")
+	assertEqual(t, item.Priority, 11)
+
+	// The next minute completes the change
+	now = now.Add(time.Minute)
+	item, _ = pq.Peek()
+	assertEqual(t, item.ID, "4")
+	assertEqual(t, pq.Healthy(), nil)
+	items := pq.SortedView()
+	assertEqual(t, items[4].ID, "0")
+	assertEqual(t, items[4].Priority, 1)
+
+	// Without a limit changes apply at once
+	pq.SetPriorityChangeLimit(PriorityChangeLimit{})
+	pq.UpdatePriorityByIds([]string{"1"}, 100)
+	pq.UpdatePriorityByIds([]string{"1"}, 1)
+	item, _ = pq.Peek()
+	assertEqual(t, item.ID, "4")
+}
+
+func Test_PriorityChangeLimitWakes(t *testing.T) {
+	now := time.Now()
+	pq, _ := New(WithClock(func() time.Time { return now }),
+		WithPriorityChangeLimit(PriorityChangeLimit{MinInterval: time.Second}))
+	populateQueue(pq, 2)
+
+	// The deferred change is the only deadline the waiters wake up for
+	pq.UpdatePriorityByIds([]string{"0"}, 5)
+	pq.UpdatePriorityByIds([]string{"0"}, 50)
+	pq.m.Lock()
+	due := pq.nextDue()
+	pq.m.Unlock()
+	assertEqual(t, due.Equal(now.Add(time.Second)), true)
+
+	now = now.Add(time.Second)
+	item, _ := pq.Peek()
+	assertEqual(t, item.Priority, 50)
+	pq.m.Lock()
+	assertEqual(t, pq.nextDue().IsZero(), true)
+	pq.m.Unlock()
+}
@@ -0,0 +1,86 @@
+package priorityqueue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A FlushAction says what becomes of the items removed to shed load
+type FlushAction int
+
+const (
+	FlushDrop       FlushAction = iota // Delete the items
+	FlushDeadLetter                    // Move the items to the dead letters
+)
+
+func (a FlushAction) String() string {
+	switch a {
+	case FlushDrop:
+		return "drop"
+	case FlushDeadLetter:
+		return "dead-letter"
+	}
+	return fmt.Sprintf("FlushAction(%d)", int(a))
+}
+
+// FlushBelowPercentile removes the lowest priority fraction p, between 0
+// and 1, of the queued items at once, to shed load during an overload:
+// 0.2 removes the bottom fifth. Among items of equal priority the most
+// recently pushed go first. The items are deleted or dead-lettered as
+// action says, and copies of them are returned for logging.
+func (pq *PriorityQueue) FlushBelowPercentile(p float64, action FlushAction) ([]QItem, error) {
+	if p < 0 || p > 1 {
+		return nil, fmt.Errorf("flush fraction %v is not between 0 and 1", p)
+	}
+	if action != FlushDrop && action != FlushDeadLetter {
+		return nil, fmt.Errorf("unknown flush action %v", action)
+	}
+	defer pq.lock(OpFlush)()
+	if err := pq.mutable(); err != nil {
+		return nil, err
+	}
+	pq.record(recorded{Op: OpFlush, Fraction: p, Action: action})
+
+	items := lowestFirst(pq.collect(func(*QItem) bool { return true }))
+	items = items[:int(p*float64(len(items)))]
+	if err := pq.authorize(context.Background(), OpFlush, items...); err != nil {
+		return nil, err
+	}
+
+	flushed := make([]QItem, len(items))
+	now := pq.now()
+	for n, item := range items {
+		flushed[n] = *pq.shed(OpFlush, item, action, "flushed", now)
+	}
+	return flushed, nil
+}
+
+// lowestFirst sorts items in the order load is shed: lowest priority first
+// and, among items of equal priority, the most recently pushed first.
+func lowestFirst(items []*QItem) []*QItem {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Priority != items[j].Priority {
+			return items[i].Priority < items[j].Priority
+		}
+		return items[i].seq > items[j].seq
+	})
+	return items
+}
+
+// shed removes a queued item on behalf of op, deleting or dead-lettering it
+// with reason as action says, and returns the removed item. The queue lock
+// must be held.
+func (pq *PriorityQueue) shed(op Operation, item *QItem, action FlushAction, reason string, now time.Time) *QItem {
+	if action == FlushDeadLetter {
+		item = pq.remove(item.index, StateDeadLettered)
+		pq.deadLetters = append(pq.deadLetters, DeadLetter{Item: *item, Reason: reason, At: now})
+		i := *item
+		pq.post(WebhookPayload{Event: EventDeadLettered, Item: &i, Reason: reason})
+	} else {
+		item = pq.remove(item.index, StateDeleted)
+	}
+	pq.audit(op, item)
+	return item
+}
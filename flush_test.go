@@ -0,0 +1,48 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_FlushBelowPercentile(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+	pq.Push(QItem{ID: "late", ParentID: "12345", Priority: 1})
+
+	flushed, err := pq.FlushBelowPercentile(0.2, FlushDrop)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(flushed), 2)
+	// The latest of the two items of priority 1 goes first
+	assertEqual(t, flushed[0].ID, "late")
+	assertEqual(t, flushed[1].ID, "0")
+	assertEqual(t, pq.Len(), 9)
+	assertEqual(t, pq.State("0"), StateDeleted)
+	min, _ := pq.MinPriority()
+	assertEqual(t, min, 2)
+
+	flushed, _ = pq.FlushBelowPercentile(0.5, FlushDeadLetter)
+	assertEqual(t, len(flushed), 4)
+	assertEqual(t, len(pq.DeadLetters()), 4)
+	assertEqual(t, pq.State("1"), StateDeadLettered)
+	assertEqual(t, pq.Len(), 5)
+	assertEqual(t, pq.Healthy(), nil)
+
+	_, err = pq.FlushBelowPercentile(1.5, FlushDrop)
+	assertEqual(t, err != nil, true)
+	assertEqual(t, FlushDeadLetter.String(), "dead-letter")
+}
+
+func Test_FlushReplay(t *testing.T) {
+	pq := NewPriorityQueue()
+	var rec bytes.Buffer
+	stop := pq.Record(&rec)
+	populateQueue(pq, 10)
+	pq.FlushBelowPercentile(0.3, FlushDeadLetter)
+	stop()
+
+	replayed, err := Replay(&rec, 0)
+	assertEqual(t, err, nil)
+	assertEqual(t, replayed.Len(), 7)
+	assertEqual(t, len(replayed.DeadLetters()), 3)
+}
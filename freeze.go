@@ -0,0 +1,197 @@
+package priorityqueue
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFrozen is returned by Pop and Lease while a freeze window holds back
+// the highest priority item, and by mutations of a queue frozen with
+// FreezeReject.
+var ErrFrozen = errors.New("queue is frozen")
+
+// A FreezeMode says what mutations of a queue frozen by Freeze do
+type FreezeMode int
+
+const (
+	// FreezeBlock makes mutations wait for Thaw
+	FreezeBlock FreezeMode = iota
+
+	// FreezeReject makes mutations that return an error fail with
+	// ErrFrozen. The others, such as Clear, wait for Thaw.
+	FreezeReject
+)
+
+// blocking lists the mutations, which wait for Thaw in FreezeBlock mode, and
+// whether they also wait in FreezeReject mode for lack of an error to return.
+var blocking = map[Operation]bool{
+	OpPush:                       false,
+	OpPop:                        false,
+	OpLease:                      false,
+	OpReserve:                    false,
+	OpAck:                        false,
+	OpNack:                       false,
+	OpExtendLease:                false,
+	OpDeadLetter:                 false,
+	OpCommit:                     false,
+	OpRelease:                    false,
+	OpUpdatePriorityByParentId:   false,
+	OpUpdatePriorityByParentTree: false,
+	OpUpdatePriorityByIds:        false,
+	OpDeleteItemById:             false,
+	OpDeleteItemsByParentId:      false,
+	OpDeleteItemsByParentTree:    false,
+	OpDeleteWhere:                false,
+	OpPurge:                      false,
+	OpFlush:                      false,
+	OpRetention:                  false,
+	OpEscalate:                   false,
+	OpRestore:                    false,
+	OpImport:                     false,
+	OpClear:                      true,
+	OpRedrive:                    true,
+	OpPauseParent:                true,
+	OpResumeParent:               true,
+	OpSetParentPriority:          true,
+	OpPushBarrier:                true,
+
+	OpUpdatePriorityByParentPattern: false,
+	OpDeleteItemsByParentPattern:    false,
+	OpForceRelease:                  false,
+	OpSteal:                         false,
+	OpSeed:                          false,
+	OpReconcile:                     false,
+	OpRestoreDeleted:                false,
+	OpRescore:                       false,
+	OpSplit:                         true,
+	OpReshard:                       false,
+}
+
+// freezeState is the freeze set by Freeze, thawed is closed by Thaw
+type freezeState struct {
+	mode   FreezeMode
+	thawed chan struct{}
+}
+
+// blocks reports whether op must wait for Thaw
+func (f *freezeState) blocks(op Operation) bool {
+	always, mutation := blocking[op]
+	return mutation && (always || f.mode == FreezeBlock)
+}
+
+// Freeze stops every change to the queue until Thaw, so backup tooling can
+// copy the persisted files or take a Snapshot of a consistent queue. Reads
+// such as Len, Peek, Stats and Snapshot carry on; mutations wait or fail as
+// mode says. Delayed items, expiring items and leases are not acted upon
+// while frozen, they are once thawed. Freezing a frozen queue changes its
+// mode.
+func (pq *PriorityQueue) Freeze(mode FreezeMode) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	if pq.freeze == nil {
+		pq.freeze = &freezeState{thawed: make(chan struct{})}
+		pq.post(WebhookPayload{Event: EventPaused})
+	}
+	pq.freeze.mode = mode
+}
+
+// Thaw lets the mutations held back by Freeze proceed
+func (pq *PriorityQueue) Thaw() {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	if pq.freeze == nil {
+		return
+	}
+	close(pq.freeze.thawed)
+	pq.freeze = nil
+	pq.wake()
+}
+
+// Frozen reports whether the queue is frozen by Freeze
+func (pq *PriorityQueue) Frozen() bool {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	return pq.freeze != nil
+}
+
+// mutable returns ErrQueueDestroyed if the queue is destroyed and ErrFrozen
+// if it is frozen. Mutations that can fail call it once holding the lock,
+// which in FreezeBlock mode waits for Thaw so only FreezeReject gets this far.
+func (pq *PriorityQueue) mutable() error {
+	if pq.destroyed {
+		return ErrQueueDestroyed
+	}
+	if pq.freeze != nil {
+		return ErrFrozen
+	}
+	return nil
+}
+
+// freezePoll is how often PopWait checks whether a freeze window has ended
+const freezePoll = time.Second
+
+// A FreezeWindow pauses Pop while it is active, entirely or for the items
+// below a priority threshold.
+type FreezeWindow struct {
+	// Active reports whether the window applies at t, see Between and Daily
+	Active func(t time.Time) bool
+
+	// MinPriority, when not nil, lets items with at least this priority be
+	// popped during the window. A nil MinPriority pauses Pop entirely.
+	MinPriority *int
+}
+
+// Between returns an Active function for the window from from until to
+func Between(from, to time.Time) func(time.Time) bool {
+	return func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+}
+
+// Daily returns an Active function for a window recurring every day from
+// start until end, both offsets from midnight in loc. A window whose end is
+// before its start spans midnight.
+func Daily(start, end time.Duration, loc *time.Location) func(time.Time) bool {
+	return func(t time.Time) bool {
+		t = t.In(loc)
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		offset := t.Sub(midnight)
+		if end < start {
+			return offset >= start || offset < end
+		}
+		return offset >= start && offset < end
+	}
+}
+
+// SetFreezeWindow adds the freeze window called name, replacing any window
+// of that name. Windows can be changed at any time while the queue is used.
+func (pq *PriorityQueue) SetFreezeWindow(name string, w FreezeWindow) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	if pq.freezeWindows == nil {
+		pq.freezeWindows = make(map[string]FreezeWindow)
+	}
+	pq.freezeWindows[name] = w
+}
+
+// RemoveFreezeWindow removes the freeze window called name
+func (pq *PriorityQueue) RemoveFreezeWindow(name string) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	delete(pq.freezeWindows, name)
+}
+
+// frozen reports whether an active freeze window holds item back. The
+// queue lock must be held.
+func (pq *PriorityQueue) frozen(item *QItem) bool {
+	if len(pq.freezeWindows) == 0 {
+		return false
+	}
+	now := pq.now()
+	for _, w := range pq.freezeWindows {
+		if w.Active(now) && (w.MinPriority == nil || item.Priority < *w.MinPriority) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_FreezeWindowPause(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	populateQueue(pq, 3)
+	pq.SetFreezeWindow("maintenance", FreezeWindow{
+		Active: Between(pq.now(), pq.now().Add(time.Hour)),
+	})
+
+	if _, err := pq.Pop(); err != ErrFrozen {
+		t.Errorf("Error popping from a frozen queue: %v", err)
+	}
+	if _, _, err := pq.Lease(time.Minute); err != ErrFrozen {
+		t.Errorf("Error leasing from a frozen queue: %v", err)
+	}
+	assertEqual(t, pq.Len(), 3)
+
+	advance(time.Hour)
+	if _, err := pq.Pop(); err != nil {
+		t.Errorf("Error popping after the window: %v", err)
+	}
+}
+
+func Test_FreezeWindowMinPriority(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	min := 3
+	pq.SetFreezeWindow("urgent only", FreezeWindow{
+		Active:      func(time.Time) bool { return true },
+		MinPriority: &min,
+	})
+
+	item, err := pq.Pop()
+	if err != nil {
+		t.Errorf("Error popping an urgent item: %v", err)
+		return
+	}
+	assertEqual(t, item.Priority, 3)
+	if _, err := pq.Pop(); err != ErrFrozen {
+		t.Errorf("Error popping below the threshold: %v", err)
+	}
+
+	pq.RemoveFreezeWindow("urgent only")
+	if _, err := pq.Pop(); err != nil {
+		t.Errorf("Error popping after removing the window: %v", err)
+	}
+}
+
+func Test_Daily(t *testing.T) {
+	nightly := Daily(23*time.Hour, 2*time.Hour, time.UTC)
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assertEqual(t, nightly(day.Add(23*time.Hour+30*time.Minute)), true)
+	assertEqual(t, nightly(day.Add(time.Hour)), true)
+	assertEqual(t, nightly(day.Add(2*time.Hour)), false)
+	assertEqual(t, nightly(day.Add(12*time.Hour)), false)
+
+	lunch := Daily(12*time.Hour, 13*time.Hour, time.UTC)
+	assertEqual(t, lunch(day.Add(12*time.Hour)), true)
+	assertEqual(t, lunch(day.Add(13*time.Hour)), false)
+}
+
+func Test_FreezeReject(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	pq.Freeze(FreezeReject)
+	assertEqual(t, pq.Frozen(), true)
+
+	if err := pq.Push(QItem{ID: "3", Priority: 4}); err != ErrFrozen {
+		t.Errorf("Error pushing to a frozen queue: %v", err)
+	}
+	if _, err := pq.Pop(); err != ErrFrozen {
+		t.Errorf("Error popping a frozen queue: %v", err)
+	}
+	if err := pq.DeleteItemById("0"); err != ErrFrozen {
+		t.Errorf("Error deleting from a frozen queue: %v", err)
+	}
+	assertEqual(t, pq.UpdatePriorityByParentId("12345", 10), 0)
+
+	// Reads carry on
+	assertEqual(t, pq.Len(), 3)
+	if item, err := pq.Peek(); err != nil || item.Priority != 3 {
+		t.Errorf("Error peeking a frozen queue: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := pq.Snapshot(&buf); err != nil {
+		t.Errorf("Error taking a snapshot of a frozen queue: %v", err)
+	}
+
+	pq.Thaw()
+	assertEqual(t, pq.Frozen(), false)
+	if err := pq.Push(QItem{ID: "3", Priority: 4}); err != nil {
+		t.Errorf("Error pushing after Thaw: %v", err)
+	}
+}
+
+func Test_FreezeBlock(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Freeze(FreezeBlock)
+
+	pushed := make(chan error)
+	go func() {
+		pushed <- pq.Push(QItem{ID: "0", Priority: 1})
+	}()
+	select {
+	case err := <-pushed:
+		t.Errorf("Error, push returned while frozen: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	assertEqual(t, pq.Len(), 0)
+
+	pq.Thaw()
+	if err := <-pushed; err != nil {
+		t.Errorf("Error pushing after Thaw: %v", err)
+	}
+	assertEqual(t, pq.Len(), 1)
+}
+
+func Test_FreezeHoldsTimers(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	pq.PushDelayed(QItem{ID: "0", Priority: 1}, pq.now().Add(time.Minute))
+	pq.Freeze(FreezeReject)
+
+	advance(time.Hour)
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, pq.State("0"), StateDelayed)
+
+	pq.Thaw()
+	assertEqual(t, pq.Len(), 1)
+}
+
+func Test_FreezeThawWakesPopWait(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 1)
+	pq.Freeze(FreezeReject)
+
+	popped := make(chan *QItem)
+	go func() {
+		item, _ := pq.PopWait(context.Background())
+		popped <- item
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pq.Thaw()
+	select {
+	case item := <-popped:
+		assertEqual(t, item.ID, "0")
+	case <-time.After(freezePoll / 2):
+		t.Errorf("Error, PopWait did not wake up on Thaw")
+	}
+}
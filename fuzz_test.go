@@ -0,0 +1,114 @@
+package priorityqueue
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// sortedPriorities returns the priorities held by sq in ascending order
+func sortedPriorities(sq *SortedQueue) []int {
+	p := make([]int, len(sq.items))
+	for n, item := range sq.items {
+		p[n] = item.Priority
+	}
+	sort.Ints(p)
+	return p
+}
+
+func queuePriorities(pq *PriorityQueue) []int {
+	p := make([]int, len(pq.data))
+	for n, item := range pq.data {
+		p[n] = item.Priority
+	}
+	sort.Ints(p)
+	return p
+}
+
+// FuzzOperations applies a random sequence of operations, two bytes per
+// operation, to a PriorityQueue and to a SortedQueue and fails as soon as their
+// observable behavior differs. Items with equal priorities may be popped in
+// any order so only priorities, counts and errors are compared.
+func FuzzOperations(f *testing.F) {
+	f.Add([]byte{0, 5, 0, 9, 1, 3, 2, 0, 2, 0})
+	f.Add([]byte{0, 1, 0, 5, 0, 9, 0, 13, 5, 1, 2, 0, 2, 0})
+	f.Add([]byte{0, 1, 1, 5, 0, 6, 4, 1, 3, 0, 6, 1, 7, 0, 2, 0})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		pq := NewPriorityQueue()
+		ref := NewSortedQueue()
+		pushed := 0
+
+		for n := 0; n+1 < len(ops); n += 2 {
+			op, arg := ops[n]%8, int(ops[n+1])
+			parentID := strconv.Itoa(arg % 4)
+
+			switch op {
+			case 0, 1:
+				item := QItem{ID: strconv.Itoa(pushed), ParentID: parentID, Priority: arg % 16}
+				pushed++
+				pq.Push(item)
+				ref.Push(item)
+			case 2:
+				x, err := pq.Pop()
+				y, rerr := ref.Pop()
+				if err != rerr {
+					t.Fatalf("op %d: Pop returned %v, expected %v", n/2, err, rerr)
+				}
+				if err == nil && x.Priority != y.Priority {
+					t.Fatalf("op %d: Pop returned priority %d, expected %d", n/2, x.Priority, y.Priority)
+				}
+				if err == nil && x.ID != y.ID {
+					// Tie between equal priorities, keep the queues in step
+					ref.Push(*y)
+					ref.DeleteItemById(x.ID)
+				}
+			case 3:
+				x, err := pq.Peek()
+				y, rerr := ref.Peek()
+				if err != rerr {
+					t.Fatalf("op %d: Peek returned %v, expected %v", n/2, err, rerr)
+				}
+				if err == nil && x.Priority != y.Priority {
+					t.Fatalf("op %d: Peek returned priority %d, expected %d", n/2, x.Priority, y.Priority)
+				}
+			case 4:
+				priority := arg % 16
+				a := pq.UpdatePriorityByParentId(parentID, priority)
+				b := ref.UpdatePriorityByParentId(parentID, priority)
+				if a != b {
+					t.Fatalf("op %d: UpdatePriorityByParentId updated %d items, expected %d", n/2, a, b)
+				}
+			case 5:
+				a, err := pq.DeleteItemsByParentId(parentID)
+				b, _ := ref.DeleteItemsByParentId(parentID)
+				if err != nil || a != b {
+					t.Fatalf("op %d: DeleteItemsByParentId deleted %d items (%v), expected %d", n/2, a, err, b)
+				}
+			case 6:
+				if pushed == 0 {
+					continue
+				}
+				id := strconv.Itoa(arg % pushed)
+				err := pq.DeleteItemById(id)
+				rerr := ref.DeleteItemById(id)
+				if (err == nil) != (rerr == nil) {
+					t.Fatalf("op %d: DeleteItemById returned %v, expected %v", n/2, err, rerr)
+				}
+			case 7:
+				pq.Clear()
+				ref.Clear()
+			}
+
+			if pq.Len() != ref.Len() {
+				t.Fatalf("op %d: Len is %d, expected %d", n/2, pq.Len(), ref.Len())
+			}
+			a, b := queuePriorities(pq), sortedPriorities(ref)
+			for i := range a {
+				if a[i] != b[i] {
+					t.Fatalf("op %d: queued priorities %v, expected %v", n/2, a, b)
+				}
+			}
+		}
+	})
+}
@@ -1,3 +1,3 @@
 module PriorityQueue
 
-go 1.14
+go 1.23
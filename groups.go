@@ -0,0 +1,104 @@
+package priorityqueue
+
+import "context"
+
+// ParentProgress counts what happened to the items of one ParentID since
+// the parent last had no items outstanding.
+type ParentProgress struct {
+	ParentID    string
+	Pushed      int // Items that entered the queue, redriven dead letters included
+	Acked       int // Items acked or committed
+	Popped      int // Items handed out by Pop, which takes no acknowledgement
+	Dropped     int // Items dead-lettered, expired or deleted
+	Outstanding int // Items queued, delayed or in flight
+}
+
+type group struct {
+	ParentProgress
+	done chan struct{} // closed when Outstanding drops to zero
+}
+
+// live reports whether the queue still holds an item in state s
+func (s State) live() bool {
+	return s == StateQueued || s == StateDelayed || s == StateInFlight
+}
+
+// progress updates the group of item for its transition from one state to
+// another. The queue lock must be held.
+func (pq *PriorityQueue) progress(item *QItem, from, to State) {
+	if from.live() == to.live() {
+		return
+	}
+	key := pq.idKey(item.ParentID)
+	g := pq.groups[key]
+	if g == nil {
+		if !to.live() {
+			return
+		}
+		if pq.groups == nil {
+			pq.groups = make(map[string]*group)
+		}
+		g = &group{ParentProgress: ParentProgress{ParentID: item.ParentID}, done: make(chan struct{})}
+		pq.groups[key] = g
+	}
+	p := &g.ParentProgress
+	if to.live() {
+		p.Outstanding++
+		p.Pushed++
+		return
+	}
+	p.Outstanding--
+	switch to {
+	case StateAcked:
+		p.Acked++
+	case StatePopped:
+		p.Popped++
+	default:
+		p.Dropped++
+	}
+	if p.Outstanding == 0 {
+		delete(pq.groups, key)
+		close(g.done)
+		if fn := pq.onParentDone; fn != nil {
+			final := *p
+			pq.deferred = append(pq.deferred, func() { fn(final) })
+		}
+	}
+}
+
+// OnParentDone installs fn to be called when the last outstanding item of a
+// ParentID is acked, popped or dropped, with the parent's final progress.
+// It is called after the queue lock has been released, on the goroutine
+// that completed the item. Passing nil removes the current callback.
+func (pq *PriorityQueue) OnParentDone(fn func(ParentProgress)) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.onParentDone = fn
+}
+
+// Progress returns the progress of parentID. A parent without outstanding
+// items reports only its ParentID.
+func (pq *PriorityQueue) Progress(parentID string) ParentProgress {
+	defer pq.lock(OpStats)()
+	if g := pq.groups[pq.idKey(parentID)]; g != nil {
+		return g.ParentProgress
+	}
+	return ParentProgress{ParentID: parentID}
+}
+
+// WaitParent waits until parentID has no outstanding items or ctx is done.
+// It returns at once if the parent has no outstanding items.
+func (pq *PriorityQueue) WaitParent(ctx context.Context, parentID string) error {
+	unlock := pq.lock(OpStats)
+	g := pq.groups[pq.idKey(parentID)]
+	unlock()
+	if g == nil {
+		return nil
+	}
+	select {
+	case <-g.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
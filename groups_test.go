@@ -0,0 +1,64 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_ParentDone(t *testing.T) {
+	pq := NewPriorityQueue()
+	var done []ParentProgress
+	pq.OnParentDone(func(p ParentProgress) {
+		done = append(done, p)
+	})
+	populateQueue(pq, 3)
+	pq.Push(QItem{ID: "x", ParentID: "other"})
+
+	_, r, _ := pq.Lease(time.Minute)
+	pq.Ack(r)
+	pq.Pop()
+	p := pq.Progress("12345")
+	assertEqual(t, p.Pushed, 3)
+	assertEqual(t, p.Outstanding, 1)
+	assertEqual(t, len(done), 0)
+
+	pq.DeleteItemById("0")
+	assertEqual(t, len(done), 1)
+	assertEqual(t, done[0], ParentProgress{ParentID: "12345", Pushed: 3, Acked: 1, Popped: 1, Dropped: 1})
+	assertEqual(t, pq.Progress("12345").Pushed, 0)
+}
+
+func Test_ParentDoneAfterRedrive(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 1)
+	_, r, _ := pq.Lease(time.Minute)
+	pq.DeadLetter(r, "failed")
+	assertEqual(t, pq.Progress("12345").Outstanding, 0)
+
+	pq.Push(QItem{ID: "1", ParentID: "12345"})
+	pq.RedriveDeadLetters(nil, nil)
+	p := pq.Progress("12345")
+	assertEqual(t, p.Pushed, 2)
+	assertEqual(t, p.Outstanding, 2)
+	assertEqual(t, p.Dropped, 0)
+}
+
+func Test_WaitParent(t *testing.T) {
+	pq := NewPriorityQueue()
+	if err := pq.WaitParent(context.Background(), "12345"); err != nil {
+		t.Errorf("Error waiting for a parent without items: %v", err)
+	}
+
+	populateQueue(pq, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pq.WaitParent(ctx, "12345"); err != context.DeadlineExceeded {
+		t.Errorf("Error waiting for a busy parent: %v", err)
+	}
+
+	go pq.Clear()
+	if err := pq.WaitParent(context.Background(), "12345"); err != nil {
+		t.Errorf("Error waiting for a parent: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package priorityqueue
+
+// SetHeadNotify makes the queue send a copy of an item to ch whenever the
+// item becomes the new head of the queue on being pushed, promoted from the
+// delayed items, redelivered or raised by a priority update, so a consumer of urgent work can wake up at
+// once without being woken for every push. The send never blocks: give ch a
+// buffer, a notification finding it full is dropped. Passing nil stops the
+// notifications.
+func (pq *PriorityQueue) SetHeadNotify(ch chan<- QItem) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.headNotify = ch
+}
+
+// WithHeadNotify is SetHeadNotify
+func WithHeadNotify(ch chan<- QItem) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetHeadNotify(ch)
+		return nil
+	}
+}
+
+// newHead notifies SetHeadNotify's channel of the item that just became the
+// head. The queue lock must be held.
+func (pq *PriorityQueue) newHead(item *QItem) {
+	select {
+	case pq.headNotify <- *item:
+	default:
+	}
+}
+
+// head returns the item at the head of the heap, nil if it is empty. The
+// queue lock must be held.
+func (pq *PriorityQueue) head() *QItem {
+	if len(pq.data) == 0 {
+		return nil
+	}
+	return pq.data[0]
+}
+
+// headChanged notifies SetHeadNotify's channel of the head of the heap
+// unless it is still head, the head before the heap changed. The queue lock must be held.
+func (pq *PriorityQueue) headChanged(head *QItem) {
+	if pq.headNotify != nil && len(pq.data) > 0 && pq.data[0] != head {
+		pq.newHead(pq.data[0])
+	}
+}
@@ -0,0 +1,35 @@
+package priorityqueue
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_HeadNotify(t *testing.T) {
+	heads := make(chan QItem, 10)
+	pq := NewPriorityQueue(WithHeadNotify(heads))
+	advance := fakeClock(pq)
+
+	pq.Push(QItem{ID: "a", Priority: 5})
+	pq.Push(QItem{ID: "b", Priority: 1})
+	pq.Push(QItem{ID: "c", Priority: 9})
+	pq.PushDelayed(QItem{ID: "d", Priority: 20}, pq.now().Add(time.Minute))
+	advance(time.Minute)
+	pq.Len()
+	pq.ImportNDJSON(strings.NewReader(`{"id":"e","priority":30}` + "\n" + `{"id":"f","priority":2}` + "\n"))
+
+	var ids string
+	for len(heads) > 0 {
+		ids += (<-heads).ID
+	}
+	assertEqual(t, ids, "acde")
+
+	// A full channel drops the notification rather than blocking
+	full := make(chan QItem)
+	pq.SetHeadNotify(full)
+	pq.Push(QItem{ID: "g", Priority: 99})
+	pq.SetHeadNotify(nil)
+	pq.Push(QItem{ID: "h", Priority: 100})
+	assertEqual(t, pq.Len(), 8)
+}
@@ -0,0 +1,143 @@
+package priorityqueue
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A HealthCheck reports a problem with something the queue depends on, such
+// as a persistence backend, by returning an error.
+type HealthCheck func() error
+
+// AddHealthCheck registers check under name to be run by Healthy. Adding a
+// check under an existing name replaces it.
+func (pq *PriorityQueue) AddHealthCheck(name string, check HealthCheck) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	if pq.healthChecks == nil {
+		pq.healthChecks = make(map[string]HealthCheck)
+	}
+	pq.healthChecks[name] = check
+}
+
+// RemoveHealthCheck unregisters the check added under name
+func (pq *PriorityQueue) RemoveHealthCheck(name string) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	delete(pq.healthChecks, name)
+}
+
+// Healthy verifies the queue's internal invariants, then runs the checks
+// registered with AddHealthCheck in name order, and returns the first
+// problem found. Checking the invariants visits every item with the queue
+// locked.
+func (pq *PriorityQueue) Healthy() error {
+	unlock := pq.lock(OpHealthy)
+	err := pq.checkInvariants()
+	checks := make(map[string]HealthCheck, len(pq.healthChecks))
+	for name, check := range pq.healthChecks {
+		checks[name] = check
+	}
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			return fmt.Errorf("health check %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkInvariants verifies the heap order, the item indexes, the age index
+// and the item counts. The queue lock must be held.
+func (pq *PriorityQueue) checkInvariants() error {
+	byProducer := make(map[string]int)
+	byTenant := make(map[string]int)
+	byParent := make(map[string]int)
+	tombstones := 0
+	for n, item := range pq.data {
+		if item == nil {
+			return fmt.Errorf("invariant: nil item at index %d", n)
+		}
+		if item.index != n {
+			return fmt.Errorf("invariant: item [%s] at index %d records index %d", item.ID, n, item.index)
+		}
+		if n > 0 && pq.data.less(n, (n-1)/2, pq.tieBreak) {
+			return fmt.Errorf("invariant: item [%s] at index %d outranks its heap parent", item.ID, n)
+		}
+		if item.tombstone != "" {
+			tombstones++
+			continue
+		}
+		byProducer[item.Producer]++
+		byTenant[item.Tenant]++
+		byParent[pq.idKey(item.ParentID)]++
+	}
+	if tombstones != pq.tombstones {
+		return fmt.Errorf("invariant: %d tombstones in the heap, %d counted", tombstones, pq.tombstones)
+	}
+	if err := compareCounts("producer", byProducer, pq.byProducer); err != nil {
+		return err
+	}
+	if err := compareCounts("tenant", byTenant, pq.byTenant); err != nil {
+		return err
+	}
+	if len(pq.byAge) != pq.size() {
+		return fmt.Errorf("invariant: %d items in the age index, %d queued", len(pq.byAge), pq.size())
+	}
+	for n, item := range pq.byAge {
+		if item.age != n || !pq.holds(item) {
+			return fmt.Errorf("invariant: item [%s] at age index %d is not queued there", item.ID, n)
+		}
+	}
+	parentCounts := make(map[string]int, len(pq.byParent))
+	for parentID, s := range pq.byParent {
+		parentCounts[parentID] = len(s)
+		for item := range s {
+			if pq.idKey(item.ParentID) != parentID || !pq.holds(item) {
+				return fmt.Errorf("invariant: item [%s] indexed under parent [%s] is not queued", item.ID, parentID)
+			}
+		}
+	}
+	return compareCounts("parent", byParent, parentCounts)
+}
+
+func compareCounts(kind string, actual, tracked map[string]int) error {
+	if len(actual) != len(tracked) {
+		return fmt.Errorf("invariant: %d %s counts tracked for %d %ss", len(tracked), kind, len(actual), kind)
+	}
+	for key, n := range actual {
+		if tracked[key] != n {
+			return fmt.Errorf("invariant: %d items tracked for %s [%s], %d queued", tracked[key], kind, key, n)
+		}
+	}
+	return nil
+}
+
+// Healthy runs Healthy on every queue of the manager in the order of their
+// names and returns the problems found, each naming its queue, joined.
+func (mgr *Manager) Healthy() error {
+	mgr.m.Lock()
+	queues := make(map[string]*PriorityQueue, len(mgr.queues))
+	for name, pq := range mgr.queues {
+		queues[name] = pq
+	}
+	mgr.m.Unlock()
+
+	var errs []error
+	for _, name := range sortedKeys(queues) {
+		if err := queues[name].Healthy(); err != nil {
+			errs = append(errs, fmt.Errorf("queue [%s]: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
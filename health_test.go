@@ -0,0 +1,49 @@
+package priorityqueue
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_Healthy(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 20)
+	pq.DeleteItemsByParentId("12345")
+	populateQueue(pq, 20)
+	pq.Pop()
+	assertEqual(t, pq.Healthy(), nil)
+
+	// Corrupt the heap order
+	pq.data[len(pq.data)-1].Priority = 1000
+	err := pq.Healthy()
+	if err == nil || !strings.Contains(err.Error(), "outranks") {
+		t.Errorf("Expected a heap order violation, got %v", err)
+	}
+}
+
+func Test_HealthChecks(t *testing.T) {
+	pq := NewPriorityQueue()
+	down := errors.New("connection refused")
+	pq.AddHealthCheck("store", func() error { return down })
+	if err := pq.Healthy(); !errors.Is(err, down) {
+		t.Errorf("Expected the failing check's error, got %v", err)
+	}
+	pq.RemoveHealthCheck("store")
+	assertEqual(t, pq.Healthy(), nil)
+}
+
+func Test_ManagerHealthy(t *testing.T) {
+	mgr := NewManager()
+	populateQueue(mgr.Queue("a"), 3)
+	mgr.Queue("b")
+	assertEqual(t, mgr.Healthy(), nil)
+
+	down := errors.New("connection refused")
+	mgr.Queue("b").AddHealthCheck("store", func() error { return down })
+	mgr.Queue("c").AddHealthCheck("store", func() error { return down })
+	err := mgr.Healthy()
+	if !errors.Is(err, down) || !strings.Contains(err.Error(), "queue [b]") || !strings.Contains(err.Error(), "queue [c]") {
+		t.Errorf("Expected both failing queues, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package priorityqueue
+
+import (
+	"context"
+	"strings"
+)
+
+// ParentSeparator separates the levels of hierarchical ParentIDs such as
+// "org/project/job". The ...ParentTree methods and PauseParent act on a
+// parent and all of its descendants.
+const ParentSeparator = "/"
+
+// underParent reports whether parentID is ancestor or one of its descendants
+func underParent(parentID, ancestor string) bool {
+	return parentID == ancestor || strings.HasPrefix(parentID, ancestor+ParentSeparator)
+}
+
+// parentTree returns the queued ParentIDs at or below ancestor. It looks at
+// every distinct ParentID rather than every item. The queue lock must be held.
+func (pq *PriorityQueue) parentTree(ancestor string) []string {
+	ancestor = pq.idKey(ancestor)
+	var parentIDs []string
+	for parentID := range pq.byParent {
+		if underParent(parentID, ancestor) {
+			parentIDs = append(parentIDs, parentID)
+		}
+	}
+	return parentIDs
+}
+
+// UpdatePriorityByParentTree sets the priority of the items of parentID and
+// of all its descendants. If an Authorizer denies the update nothing is
+// updated and 0 is returned.
+func (pq *PriorityQueue) UpdatePriorityByParentTree(parentID string, priority int) int {
+	n, _ := pq.UpdatePriorityByParentTreeCtx(context.Background(), parentID, priority)
+	return n
+}
+
+// UpdatePriorityByParentTreeCtx is UpdatePriorityByParentTree on behalf of
+// the principal carried by ctx.
+func (pq *PriorityQueue) UpdatePriorityByParentTreeCtx(ctx context.Context, parentID string, priority int) (int, error) {
+	unlock, err := pq.lockCtx(ctx, OpUpdatePriorityByParentTree)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	if err := pq.mutable(); err != nil {
+		return 0, err
+	}
+	pq.record(recorded{Op: OpUpdatePriorityByParentTree, ParentID: parentID, Priority: priority})
+	items := pq.parentItems(pq.parentTree(parentID)...)
+	if err := pq.authorize(ctx, OpUpdatePriorityByParentTree, items...); err != nil {
+		return 0, err
+	}
+	return pq.updatePriorities(OpUpdatePriorityByParentTree, items, priority), nil
+}
+
+// DeleteItemsByParentTree deletes the items of parentID and of all its
+// descendants.
+func (pq *PriorityQueue) DeleteItemsByParentTree(parentID string) (int, error) {
+	return pq.DeleteItemsByParentTreeCtx(context.Background(), parentID)
+}
+
+// DeleteItemsByParentTreeCtx is DeleteItemsByParentTree on behalf of the
+// principal carried by ctx.
+func (pq *PriorityQueue) DeleteItemsByParentTreeCtx(ctx context.Context, parentID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return pq.deleteChunked(OpDeleteItemsByParentTree, nil, func() ([]*QItem, error) {
+		pq.record(recorded{Op: OpDeleteItemsByParentTree, ParentID: parentID})
+		items := pq.parentItems(pq.parentTree(parentID)...)
+		return items, pq.authorize(ctx, OpDeleteItemsByParentTree, items...)
+	})
+}
+
+// PauseParent holds back the items of parentID and of all its descendants,
+// including items pushed later, until ResumeParent is called with the same
+// parentID. Paused items stay queued and counted by Len but Pop, Peek and
+// Lease pass over them, which costs a scan of the queue while a paused item
+// has the highest priority.
+func (pq *PriorityQueue) PauseParent(parentID string) {
+	defer pq.lock(OpPauseParent)()
+	if pq.pausedParents == nil {
+		pq.pausedParents = make(map[string]bool)
+	}
+	pq.pausedParents[pq.idKey(parentID)] = true
+	pq.post(WebhookPayload{Event: EventPaused, ParentID: parentID})
+}
+
+// ResumeParent releases the items paused by PauseParent(parentID). Items of
+// a descendant stay paused if the descendant itself, or another ancestor,
+// was paused too.
+func (pq *PriorityQueue) ResumeParent(parentID string) {
+	defer pq.lock(OpResumeParent)()
+	delete(pq.pausedParents, pq.idKey(parentID))
+	pq.wake()
+}
+
+// PausedParents returns the ParentIDs passed to PauseParent and the
+// patterns passed to PauseParentPattern, and not resumed
+func (pq *PriorityQueue) PausedParents() []string {
+	defer pq.lock(OpStats)()
+	parentIDs := make([]string, 0, len(pq.pausedParents)+len(pq.pausedMatch))
+	for parentID := range pq.pausedParents {
+		parentIDs = append(parentIDs, parentID)
+	}
+	for pattern := range pq.pausedMatch {
+		parentIDs = append(parentIDs, pattern)
+	}
+	return parentIDs
+}
+
+// paused reports whether item or one of its ancestors is paused. The queue
+// lock must be held.
+func (pq *PriorityQueue) paused(item *QItem) bool {
+	if len(pq.pausedParents) == 0 && len(pq.pausedMatch) == 0 {
+		return false
+	}
+	parentID := pq.idKey(item.ParentID)
+	for {
+		if pq.pausedParents[parentID] {
+			return true
+		}
+		for _, match := range pq.pausedMatch {
+			if match(parentID) {
+				return true
+			}
+		}
+		n := strings.LastIndex(parentID, ParentSeparator)
+		if n == -1 {
+			return false
+		}
+		parentID = parentID[:n]
+	}
+}
+
+// held reports whether item is tombstoned, paused or behind a barrier. The
+// queue lock must be held.
+func (pq *PriorityQueue) held(item *QItem) bool {
+	return item.tombstone != "" || pq.paused(item) || pq.blocked(item)
+}
+
+// next returns the index of the highest priority item that is not held
+// back, -1 if there is none. The queue lock must be held.
+func (pq *PriorityQueue) next() int {
+	pq.purgeHead()
+	if len(pq.data) == 0 {
+		return -1
+	}
+	if !pq.held(pq.data[0]) {
+		return 0
+	}
+	best := -1
+	for n, item := range pq.data {
+		if !pq.held(item) && (best == -1 || pq.data.less(n, best, pq.tieBreak)) {
+			best = n
+		}
+	}
+	return best
+}
@@ -0,0 +1,66 @@
+package priorityqueue
+
+import (
+	"testing"
+)
+
+func pushTree(pq *PriorityQueue) {
+	pq.Push(QItem{ID: "1", ParentID: "acme", Priority: 1})
+	pq.Push(QItem{ID: "2", ParentID: "acme/web", Priority: 2})
+	pq.Push(QItem{ID: "3", ParentID: "acme/web/build", Priority: 3})
+	pq.Push(QItem{ID: "4", ParentID: "acme/webshop", Priority: 4})
+	pq.Push(QItem{ID: "5", ParentID: "globex", Priority: 5})
+}
+
+func Test_UpdatePriorityByParentTree(t *testing.T) {
+	pq := NewPriorityQueue()
+	pushTree(pq)
+
+	assertEqual(t, pq.UpdatePriorityByParentTree("acme/web", 10), 2)
+	item, _ := pq.Pop()
+	assertEqual(t, item.Priority, 10)
+	item, _ = pq.Pop()
+	assertEqual(t, item.Priority, 10)
+	item, _ = pq.Pop()
+	assertEqual(t, item.ID, "5")
+}
+
+func Test_DeleteItemsByParentTree(t *testing.T) {
+	pq := NewPriorityQueue()
+	pushTree(pq)
+
+	n, err := pq.DeleteItemsByParentTree("acme")
+	if err != nil {
+		t.Errorf("Error deleting parent tree: %v", err)
+	}
+	assertEqual(t, n, 4)
+	assertEqual(t, pq.Len(), 1)
+	if err := pq.Healthy(); err != nil {
+		t.Errorf("Error checking invariants: %v", err)
+	}
+}
+
+func Test_PauseParent(t *testing.T) {
+	pq := NewPriorityQueue()
+	pushTree(pq)
+	pq.PauseParent("acme/web")
+	pq.PauseParent("globex")
+
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "4")
+	var ids []string
+	for {
+		item, err := pq.Pop()
+		if err != nil {
+			break
+		}
+		ids = append(ids, item.ID)
+	}
+	assertEqual(t, len(ids), 2)
+	assertEqual(t, pq.Len(), 3)
+
+	pq.ResumeParent("acme/web")
+	item, _ = pq.Pop()
+	assertEqual(t, item.ID, "3")
+	assertEqual(t, len(pq.PausedParents()), 1)
+}
@@ -0,0 +1,165 @@
+package priorityqueue
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultPriorityBounds are the upper bounds of the priority buckets counted
+// unless SetPriorityBuckets was called. Priorities above the last bound fall
+// into an overflow bucket.
+var DefaultPriorityBounds = []int{0, 1, 2, 5, 10, 20, 50, 100}
+
+// A PriorityHistogram counts queued items by priority. Counts has one entry
+// per bound, counting the priorities up to that bound and above the previous
+// one, plus a final overflow entry.
+type PriorityHistogram struct {
+	Bounds []int
+	Counts []int
+}
+
+func newPriorityHistogram(bounds []int) *PriorityHistogram {
+	return &PriorityHistogram{
+		Bounds: bounds,
+		Counts: make([]int, len(bounds)+1),
+	}
+}
+
+func (h *PriorityHistogram) add(priority, n int) {
+	h.Counts[sort.SearchInts(h.Bounds, priority)] += n
+}
+
+func (h *PriorityHistogram) copy() PriorityHistogram {
+	c := *h
+	c.Bounds = append([]int(nil), h.Bounds...)
+	c.Counts = append([]int(nil), h.Counts...)
+	return c
+}
+
+// SetPriorityBuckets sets the upper bounds of the buckets of the priority
+// histogram reported by Stats, in increasing order, and recounts the queued
+// items into them.
+func (pq *PriorityQueue) SetPriorityBuckets(bounds ...int) {
+	bounds = append([]int(nil), bounds...)
+	sort.Ints(bounds)
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.priorities = newPriorityHistogram(bounds)
+	for p, n := range pq.byPriority {
+		pq.priorities.add(p, n)
+	}
+}
+
+// reprioritize sets the priority of a queued item. The queue lock must be held.
+func (pq *PriorityQueue) reprioritize(item *QItem, priority int) {
+	head := pq.head()
+	pq.setPriority(item, priority)
+	pq.data.fix(item.index, pq.tieBreak)
+	pq.headChanged(head)
+}
+
+// setPriority sets the priority of a queued item and updates the counts and
+// turns kept by priority, leaving the heap order for the caller to restore
+// by fixing the item or rebuilding the heap, then to call headChanged. The
+// queue lock must be held.
+func (pq *PriorityQueue) setPriority(item *QItem, priority int) {
+	old := item.Priority
+	pq.countPriority(old, -1)
+	pq.countPriority(priority, 1)
+	item.Priority = priority
+	pq.profileFixup(item)
+	pq.forgetTurns(old)
+}
+
+// countPriority adds n queued items of the given priority to the histogram
+// and the exact counts. The queue lock must be held.
+func (pq *PriorityQueue) countPriority(priority, n int) {
+	if pq.priorities == nil {
+		pq.priorities = newPriorityHistogram(DefaultPriorityBounds)
+	}
+	pq.priorities.add(priority, n)
+	if pq.byPriority == nil {
+		pq.byPriority = make(map[int]int)
+	}
+	pq.byPriority[priority] += n
+	switch {
+	case pq.byPriority[priority] == 0:
+		delete(pq.byPriority, priority)
+		if priority == pq.minPriority {
+			pq.minStale = true
+		}
+	case len(pq.byPriority) == 1 || priority < pq.minPriority:
+		pq.minPriority, pq.minStale = priority, false
+	}
+}
+
+// MaxPriority returns the priority of the item at the head of the queue
+func (pq *PriorityQueue) MaxPriority() (int, error) {
+	defer pq.lock(OpStats)()
+	if pq.destroyed {
+		return 0, ErrQueueDestroyed
+	}
+	pq.purgeHead()
+	if len(pq.data) == 0 {
+		return 0, ErrEmptyQueue
+	}
+	return pq.data[0].Priority, nil
+}
+
+// MinPriority returns the lowest priority of the queued items. It is tracked
+// as items come and go, finding the next lowest costs a pass over the
+// distinct priorities once the last item of the lowest one leaves.
+func (pq *PriorityQueue) MinPriority() (int, error) {
+	defer pq.lock(OpStats)()
+	if pq.destroyed {
+		return 0, ErrQueueDestroyed
+	}
+	if len(pq.byPriority) == 0 {
+		return 0, ErrEmptyQueue
+	}
+	if pq.minStale {
+		first := true
+		for p := range pq.byPriority {
+			if first || p < pq.minPriority {
+				pq.minPriority, first = p, false
+			}
+		}
+		pq.minStale = false
+	}
+	return pq.minPriority, nil
+}
+
+// PriorityPercentile returns the priority below or at which q, between 0
+// and 1, of the queued items are: 0.5 is the median priority and 1 the
+// highest. Its cost grows with the number of distinct priorities queued.
+func (pq *PriorityQueue) PriorityPercentile(q float64) (int, error) {
+	defer pq.lock(OpStats)()
+	if pq.destroyed {
+		return 0, ErrQueueDestroyed
+	}
+	if len(pq.byPriority) == 0 {
+		return 0, ErrEmptyQueue
+	}
+	return pq.percentile(q), nil
+}
+
+// percentile is PriorityPercentile for a queue that is not empty. The queue
+// lock must be held.
+func (pq *PriorityQueue) percentile(q float64) int {
+	priorities := make([]int, 0, len(pq.byPriority))
+	total := 0
+	for p, n := range pq.byPriority {
+		priorities = append(priorities, p)
+		total += n
+	}
+	sort.Ints(priorities)
+	rank := int(math.Ceil(q * float64(total)))
+	seen := 0
+	for _, p := range priorities {
+		seen += pq.byPriority[p]
+		if seen >= rank {
+			return p
+		}
+	}
+	return priorities[len(priorities)-1]
+}
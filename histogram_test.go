@@ -0,0 +1,62 @@
+package priorityqueue
+
+import "testing"
+
+func Test_PriorityHistogram(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetPriorityBuckets(10, 1, 5)
+	populateQueue(pq, 12)
+
+	h := pq.Stats().Priorities
+	assertEqual(t, len(h.Counts), 4)
+	assertEqual(t, h.Bounds[0], 1)
+	assertEqual(t, h.Counts[0], 1)
+	assertEqual(t, h.Counts[1], 4)
+	assertEqual(t, h.Counts[2], 5)
+	assertEqual(t, h.Counts[3], 2)
+
+	pq.UpdatePriorityByParentId("12345", 3)
+	h = pq.Stats().Priorities
+	assertEqual(t, h.Counts[1], 12)
+	assertEqual(t, h.Counts[3], 0)
+
+	pq.Pop()
+	assertEqual(t, pq.Stats().Priorities.Counts[1], 11)
+}
+
+func Test_MinMaxPriority(t *testing.T) {
+	pq := NewPriorityQueue()
+	if _, err := pq.MinPriority(); err != ErrEmptyQueue {
+		t.Errorf("Error getting the min priority of an empty queue: %v", err)
+	}
+	populateQueue(pq, 5)
+
+	max, _ := pq.MaxPriority()
+	assertEqual(t, max, 5)
+	min, _ := pq.MinPriority()
+	assertEqual(t, min, 1)
+
+	pq.DeleteItemById("0")
+	min, _ = pq.MinPriority()
+	assertEqual(t, min, 2)
+	pq.Push(QItem{ID: "low", Priority: -3})
+	min, _ = pq.MinPriority()
+	assertEqual(t, min, -3)
+	pq.Clear()
+	if _, err := pq.MaxPriority(); err != ErrEmptyQueue {
+		t.Errorf("Error getting the max priority of an empty queue: %v", err)
+	}
+}
+
+func Test_PriorityPercentile(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+
+	for q, want := range map[float64]int{0: 1, 0.5: 5, 0.9: 9, 0.95: 10, 1: 10} {
+		p, err := pq.PriorityPercentile(q)
+		if err != nil {
+			t.Errorf("Error getting the percentile %v: %v", q, err)
+		}
+		assertEqual(t, p, want)
+	}
+}
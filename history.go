@@ -0,0 +1,54 @@
+package priorityqueue
+
+import "time"
+
+// An Attempt is one lease of an item, see History
+type Attempt struct {
+	Worker string    // Who leased the item, see LeaseAs
+	Leased time.Time // When the item was leased
+
+	// Ended is when the item was nacked or its lease expired, zero while
+	// the item is in flight; Error says why, the error given to NackError
+	// or "nacked" or "lease expired".
+	Ended time.Time
+	Error string
+}
+
+// History returns the leases of the item with the given ID, oldest first,
+// to tell why it took several attempts. The history is kept while the item
+// is queued or in flight again after a failed attempt, and forgotten once
+// it is acked, dead-lettered or leaves the queue otherwise. It returns nil
+// for an item never leased.
+func (pq *PriorityQueue) History(id string) []Attempt {
+	defer pq.lock(OpStats)()
+	return append([]Attempt(nil), pq.history[pq.lookupKey(id)]...)
+}
+
+// attempt records the lease of item by worker. The queue lock must be held.
+func (pq *PriorityQueue) attempt(item *QItem, worker string) {
+	if pq.history == nil {
+		pq.history = make(map[string][]Attempt)
+	}
+	key := pq.itemKey(item)
+	pq.history[key] = append(pq.history[key], Attempt{Worker: worker, Leased: pq.now()})
+}
+
+// endAttempt records the end of the latest lease of item, which failed
+// because of reason. The queue lock must be held.
+func (pq *PriorityQueue) endAttempt(item *QItem, reason string, now time.Time) {
+	h := pq.history[pq.itemKey(item)]
+	if len(h) > 0 {
+		h[len(h)-1].Ended, h[len(h)-1].Error = now, reason
+	}
+}
+
+// unattempt forgets the latest lease of item, which did not count as an
+// attempt. The queue lock must be held.
+func (pq *PriorityQueue) unattempt(item *QItem) {
+	key := pq.itemKey(item)
+	if h := pq.history[key]; len(h) > 1 {
+		pq.history[key] = h[:len(h)-1]
+	} else {
+		delete(pq.history, key)
+	}
+}
@@ -0,0 +1,53 @@
+package priorityqueue
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_History(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pq, _ := New(WithClock(func() time.Time { return now }))
+	pq.Push(QItem{ID: "a", Priority: 1})
+	assertEqual(t, len(pq.History("a")), 0)
+
+	_, r, _ := pq.LeaseAs("worker-1", time.Minute)
+	now = now.Add(time.Second)
+	pq.NackError(r, 0, errors.New("connection refused"))
+	_, _, _ = pq.LeaseAs("worker-2", time.Minute)
+	now = now.Add(2 * time.Minute)
+	pq.Len() // the lease expires
+	_, r, _ = pq.Lease(time.Minute)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := pq.History("a")
+	expected := []Attempt{
+		{Worker: "worker-1", Leased: start, Ended: start.Add(time.Second), Error: "connection refused"},
+		{Worker: "worker-2", Leased: start.Add(time.Second), Ended: start.Add(121 * time.Second), Error: "lease expired"},
+		{Leased: start.Add(121 * time.Second)},
+	}
+	if !reflect.DeepEqual(h, expected) {
+		t.Errorf("History returned %+v, expected %+v", h, expected)
+	}
+
+	// Acked items forget their history
+	pq.Ack(r)
+	assertEqual(t, len(pq.History("a")), 0)
+
+	// as do dead letters
+	pq.SetMaxAttempts(1)
+	pq.Push(QItem{ID: "b"})
+	_, r, _ = pq.Lease(time.Minute)
+	pq.NackError(r, 0, errors.New("bad input"))
+	assertEqual(t, len(pq.History("b")), 0)
+	assertEqual(t, pq.DeadLetters()[0].Reason, "bad input after 1 attempts")
+
+	// A released reservation was no attempt
+	pq.SetMaxAttempts(0)
+	pq.Push(QItem{ID: "c"})
+	res, _ := pq.Reserve()
+	res.Release()
+	assertEqual(t, len(pq.History("c")), 0)
+}
@@ -0,0 +1,346 @@
+package httppq
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+// ErrCircuitOpen is returned by a Client without contacting the server
+// while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ClientOptions configure a Client. Zero fields take the defaults.
+type ClientOptions struct {
+	// Token is sent as a bearer token unless empty
+	Token string
+
+	// HTTPClient sends the requests. The default one keeps MaxIdleConns
+	// connections to the server open for reuse; set a client of your own
+	// for client certificates.
+	HTTPClient   *http.Client
+	MaxIdleConns int
+
+	// Retries is the number of times a failed request is retried, with
+	// Backoff doubling between attempts. Pushes are retried with an
+	// Idempotency-Key, so the server pushes them once; pops only when the
+	// connection could not be made, as the server may have popped the item.
+	// Negative disables retries.
+	Retries int
+	Backoff time.Duration
+
+	// BreakerFailures consecutive failed requests open the circuit breaker:
+	// requests then fail with ErrCircuitOpen for BreakerCooldown, after
+	// which one trial request decides whether to close it again. Negative
+	// disables the breaker.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+// Defaults of the ClientOptions
+const (
+	DefaultMaxIdleConns    = 16
+	DefaultRetries         = 2
+	DefaultBackoff         = 100 * time.Millisecond
+	DefaultBreakerFailures = 5
+	DefaultBreakerCooldown = 10 * time.Second
+)
+
+// A Client is a priorityqueue.Queue served by a remote Handler, so remote
+// and local queues are interchangeable, such as the nodes of a
+// priorityqueue.Partitioned queue. It retries failed requests and stops
+// calling a failing server for a while, see ClientOptions. Len, Clear and
+// UpdatePriorityByParentId cannot return errors: Len and
+// UpdatePriorityByParentId return 0 when the request fails, and Err returns
+// the error of the latest failed request.
+//
+// The Client presents the latest consistency token it got with its reads,
+// so they observe its own pushes even when served by a follower, see the
+// package documentation.
+type Client struct {
+	base string
+	opts ClientOptions
+
+	m        sync.Mutex
+	lastErr  error
+	failures int       // Consecutive failed requests
+	openTill time.Time // When the open breaker lets a trial request through
+	trial    bool      // Whether a trial request is on its way
+	token    uint64    // Latest consistency token received
+}
+
+var _ pq.Queue = (*Client)(nil)
+
+// NewClient returns a Client of the API at baseURL, such as
+// "https://queue-1:8443"
+func NewClient(baseURL string, opts ClientOptions) *Client {
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if opts.HTTPClient == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.MaxIdleConns = opts.MaxIdleConns
+		t.MaxIdleConnsPerHost = opts.MaxIdleConns
+		opts.HTTPClient = &http.Client{Transport: t}
+	}
+	if opts.Retries == 0 {
+		opts.Retries = DefaultRetries
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.BreakerFailures == 0 {
+		opts.BreakerFailures = DefaultBreakerFailures
+	}
+	if opts.BreakerCooldown == 0 {
+		opts.BreakerCooldown = DefaultBreakerCooldown
+	}
+	return &Client{base: strings.TrimSuffix(baseURL, "/"), opts: opts}
+}
+
+// ConsistencyToken returns the latest consistency token the Client got,
+// empty if none, for another Client to read what this one saw
+func (c *Client) ConsistencyToken() string {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.token == 0 {
+		return ""
+	}
+	return strconv.FormatUint(c.token, 10)
+}
+
+// SetConsistencyToken makes the reads of the Client observe the writes the
+// token was returned for, such as a token got by another Client. Tokens
+// older than the latest one received are ignored.
+func (c *Client) SetConsistencyToken(token string) error {
+	n, err := strconv.ParseUint(token, 10, 64)
+	if err != nil {
+		return err
+	}
+	c.observe(n)
+	return nil
+}
+
+// observe keeps the consistency token n if it is the latest
+func (c *Client) observe(n uint64) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if n > c.token {
+		c.token = n
+	}
+}
+
+// Err returns the error of the latest failed request, nil if none failed
+func (c *Client) Err() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.lastErr
+}
+
+// allow reports whether the breaker lets a request through
+func (c *Client) allow() bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.opts.BreakerFailures < 0 || c.failures < c.opts.BreakerFailures {
+		return true
+	}
+	if c.trial || time.Now().Before(c.openTill) {
+		return false
+	}
+	c.trial = true
+	return true
+}
+
+// record counts the outcome of a request for the breaker. Errors of the
+// caller, such as a missing item, do not count as failures.
+func (c *Client) record(err error, failed bool) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.trial = false
+	if err != nil {
+		c.lastErr = err
+	}
+	if !failed {
+		c.failures = 0
+		return err
+	}
+	c.failures++
+	if c.opts.BreakerFailures > 0 && c.failures >= c.opts.BreakerFailures {
+		c.openTill = time.Now().Add(c.opts.BreakerCooldown)
+	}
+	return err
+}
+
+// do sends a request, retrying it as the options say, and decodes the JSON
+// response into out unless it is nil. The error statuses are mapped back to
+// the errors of the queue.
+func (c *Client) do(method, path, key string, body, out interface{}) (int, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+	backoff := c.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		if !c.allow() {
+			c.m.Lock()
+			c.lastErr = ErrCircuitOpen
+			c.m.Unlock()
+			return 0, ErrCircuitOpen
+		}
+		status, err, retry := c.send(method, path, key, payload, out)
+		if err == nil || !retry {
+			return status, c.record(err, retry)
+		}
+		c.record(err, true)
+		if attempt >= c.opts.Retries || (method == http.MethodPost && path == "/pop" && !dialFailed(err)) {
+			return status, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send makes one attempt of a request. It reports whether the request
+// failed in a way worth retrying: the server could not be reached or
+// failed itself.
+func (c *Client) send(method, path, key string, payload []byte, out interface{}) (int, error, bool) {
+	var r io.Reader
+	if payload != nil {
+		r = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, c.base+path, r)
+	if err != nil {
+		return 0, err, false
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	}
+	if token := c.ConsistencyToken(); token != "" {
+		req.Header.Set(ConsistencyHeader, token)
+	}
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err, true
+	}
+	defer resp.Body.Close()
+	if n, err := strconv.ParseUint(resp.Header.Get(ConsistencyHeader), 10, 64); err == nil {
+		c.observe(n)
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return resp.StatusCode, statusError(resp.StatusCode, e.Error), resp.StatusCode >= 500
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err, false
+		}
+	}
+	return resp.StatusCode, nil, false
+}
+
+// dialFailed reports whether err is a failure to connect, before the
+// server saw the request
+func dialFailed(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+// statusError maps an error status back to the error of the queue, the
+// reverse of writeQueueError
+func statusError(status int, msg string) error {
+	var kind error
+	switch status {
+	case http.StatusForbidden:
+		kind = pq.ErrUnauthorized
+	case http.StatusTooManyRequests:
+		kind = pq.ErrRateLimited
+	case http.StatusNotFound:
+		kind = pq.ErrNotFound
+	case http.StatusConflict:
+		kind = pq.ErrDraining
+	default:
+		return fmt.Errorf("queue server returned %d: %s", status, msg)
+	}
+	return fmt.Errorf("%w (%s)", kind, msg)
+}
+
+func (c *Client) Push(i pq.QItem) error {
+	_, err := c.do(http.MethodPost, "/items", pq.NewULID(), i, nil)
+	return err
+}
+
+func (c *Client) pop(method, path string) (*pq.QItem, error) {
+	var item pq.QItem
+	status, err := c.do(method, path, "", nil, &item)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNoContent {
+		return nil, pq.ErrEmptyQueue
+	}
+	return &item, nil
+}
+
+func (c *Client) Pop() (*pq.QItem, error) {
+	return c.pop(http.MethodPost, "/pop")
+}
+
+func (c *Client) Peek() (*pq.QItem, error) {
+	return c.pop(http.MethodGet, "/peek")
+}
+
+func (c *Client) Len() int {
+	var body struct {
+		Len int `json:"len"`
+	}
+	c.do(http.MethodGet, "/len", "", nil, &body)
+	return body.Len
+}
+
+func (c *Client) Clear() {
+	c.do(http.MethodDelete, "/items", "", nil, nil)
+}
+
+func (c *Client) UpdatePriorityByParentId(parentID string, priority int) int {
+	var body struct {
+		Updated int `json:"updated"`
+	}
+	c.do(http.MethodPut, "/parents/"+url.PathEscape(parentID)+"/priority", "", map[string]int{"priority": priority}, &body)
+	return body.Updated
+}
+
+func (c *Client) DeleteItemById(id string) error {
+	_, err := c.do(http.MethodDelete, "/items/"+url.PathEscape(id), "", nil, nil)
+	return err
+}
+
+func (c *Client) DeleteItemsByParentId(parentID string) (int, error) {
+	var body struct {
+		Deleted int `json:"deleted"`
+	}
+	_, err := c.do(http.MethodDelete, "/parents/"+url.PathEscape(parentID), "", nil, &body)
+	return body.Deleted, err
+}
@@ -0,0 +1,168 @@
+package httppq
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+func Test_Client(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	srv := httptest.NewServer(NewHandler(q, Options{Tokens: map[string]string{"secret": "app"}}))
+	defer srv.Close()
+	c := NewClient(srv.URL, ClientOptions{Token: "secret", HTTPClient: srv.Client()})
+
+	if _, err := c.Pop(); err != pq.ErrEmptyQueue {
+		t.Errorf("Pop of an empty queue returned %v", err)
+	}
+	c.Push(pq.QItem{ID: "a", ParentID: "job/1", Priority: 1})
+	c.Push(pq.QItem{ID: "b", ParentID: "job/1", Priority: 2})
+	c.Push(pq.QItem{ID: "c", ParentID: "other", Priority: 3})
+	if n := c.Len(); n != 3 {
+		t.Errorf("Len returned %d, expected 3", n)
+	}
+	if n := c.UpdatePriorityByParentId("job/1", 10); n != 2 {
+		t.Errorf("UpdatePriorityByParentId returned %d, expected 2", n)
+	}
+	item, err := c.Peek()
+	if err != nil || item.ParentID != "job/1" || item.Priority != 10 {
+		t.Errorf("Peek returned %+v, %v", item, err)
+	}
+	if err := c.DeleteItemById("nope"); !errors.Is(err, pq.ErrNotFound) {
+		t.Errorf("Deleting a missing item returned %v", err)
+	}
+	if n, err := c.DeleteItemsByParentId("job/1"); n != 2 || err != nil {
+		t.Errorf("DeleteItemsByParentId returned %d, %v", n, err)
+	}
+	item, _ = c.Pop()
+	if item == nil || item.ID != "c" {
+		t.Errorf("Pop returned %+v, expected c", item)
+	}
+	c.Push(pq.QItem{ID: "d"})
+	c.Clear()
+	if q.Len() != 0 {
+		t.Errorf("Clear left %d items", q.Len())
+	}
+
+	bad := NewClient(srv.URL, ClientOptions{Token: "wrong", HTTPClient: srv.Client()})
+	if bad.Len() != 0 || bad.Err() == nil {
+		t.Errorf("Unauthenticated Len did not fail")
+	}
+}
+
+func Test_ClientRetries(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	h := NewHandler(q, Options{Tokens: map[string]string{"secret": "app"}})
+	// The first push is queued but its response lost, the first pop fails
+	// before reaching the queue
+	var pushes, pops int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items":
+			if atomic.AddInt32(&pushes, 1) == 1 {
+				h.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		case "/pop":
+			if atomic.AddInt32(&pops, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, ClientOptions{Token: "secret", HTTPClient: srv.Client(), Backoff: time.Millisecond})
+
+	if err := c.Push(pq.QItem{ID: "a", Priority: 1}); err != nil {
+		t.Fatalf("Retried Push returned %v", err)
+	}
+	if n := atomic.LoadInt32(&pushes); n != 2 || q.Len() != 1 {
+		t.Errorf("%d pushes queued %d items, expected 2 pushes queueing 1", n, q.Len())
+	}
+
+	// A pop failing with a response is not retried: the server may have
+	// popped the item
+	if _, err := c.Pop(); err == nil {
+		t.Errorf("Pop failing with 503 returned no error")
+	}
+	item, err := c.Pop()
+	if err != nil || item.ID != "a" {
+		t.Errorf("Pop returned %+v, %v", item, err)
+	}
+	if n := atomic.LoadInt32(&pops); n != 2 {
+		t.Errorf("Made %d pop requests, expected 2", n)
+	}
+}
+
+func Test_ClientCircuitBreaker(t *testing.T) {
+	var calls int32
+	failing := int32(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"len": 7}`))
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, ClientOptions{HTTPClient: srv.Client(), Retries: -1,
+		BreakerFailures: 3, BreakerCooldown: 20 * time.Millisecond})
+
+	for i := 0; i < 5; i++ {
+		c.Len()
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Made %d requests, expected 3 before opening", n)
+	}
+	if !errors.Is(c.Err(), ErrCircuitOpen) {
+		t.Errorf("Err returned %v, expected ErrCircuitOpen", c.Err())
+	}
+
+	// After the cooldown a failing trial keeps the breaker open
+	time.Sleep(30 * time.Millisecond)
+	c.Len()
+	c.Len()
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("Made %d requests, expected 1 trial after the cooldown", n-3)
+	}
+
+	// and a successful one closes it
+	atomic.StoreInt32(&failing, 0)
+	time.Sleep(30 * time.Millisecond)
+	if a, b := c.Len(), c.Len(); a != 7 || b != 7 {
+		t.Errorf("Len returned %d and %d once closed, expected 7", a, b)
+	}
+	if n := atomic.LoadInt32(&calls); n != 6 {
+		t.Errorf("Made %d requests, expected 6", n)
+	}
+}
+
+func Test_ClientDraining(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	q.StartDraining(10, nil)
+	srv := httptest.NewServer(NewHandler(q, Options{Tokens: map[string]string{"secret": "app"}}))
+	defer srv.Close()
+	c := NewClient(srv.URL, ClientOptions{Token: "secret", HTTPClient: srv.Client(), Retries: 2,
+		Backoff: time.Millisecond, BreakerFailures: 2, BreakerCooldown: time.Minute})
+
+	// Rejections below the floor are neither retried nor open the breaker
+	for i := 0; i < 3; i++ {
+		if err := c.Push(pq.QItem{ID: "low", Priority: 1}); !errors.Is(err, pq.ErrDraining) {
+			t.Errorf("Push below the floor returned %v, expected ErrDraining", err)
+		}
+	}
+	if err := c.Push(pq.QItem{ID: "high", Priority: 20}); err != nil {
+		t.Errorf("Push above the floor returned %v", err)
+	}
+	if q.Len() != 1 {
+		t.Errorf("Queue holds %d items, expected 1", q.Len())
+	}
+}
@@ -0,0 +1,536 @@
+// Package httppq exposes a priority queue over HTTP with a small JSON API,
+// optionally secured with TLS, bearer tokens, client certificates and per
+// route authorization.
+//
+// Routes:
+//
+//	POST   /items                       push the JSON item in the body
+//	POST   /pop                         pop the highest priority item, 204 when empty
+//	GET    /peek                        the highest priority item, 204 when empty
+//	GET    /len                         {"len": n}
+//	GET    /items/{id}                  {"id": id, "state": s}, 404 for an unknown ID
+//	GET    /stats                       the priorityqueue.Stats of the queue
+//	DELETE /items                       delete every item
+//	DELETE /items/{id}                  delete an item by ID
+//	DELETE /parents/{parentID}          delete the items of a parent, {"deleted": n}
+//	PUT    /parents/{parentID}/priority update the priority of a parent's items
+//	                                    from {"priority": n}, {"updated": n}
+//	GET    /healthz                     200 when the queue is healthy, 503 otherwise
+//	GET    /readyz                      200 when the queue is ready to serve, 503 otherwise
+//	GET    /openapi.json                the OpenAPI 3 document describing the API
+//
+// The health routes do not require authentication so they can be used as
+// Kubernetes probes, nor does the OpenAPI document, so clients can be
+// generated from it.
+//
+// A push carrying an Idempotency-Key header is pushed once: repeating it
+// with the same key within Options.IdempotencyWindow returns 201 again
+// without pushing, so clients can retry pushes whose response was lost.
+//
+// Pushes answer with a Consistency-Token header, the position of the queue
+// once the item was pushed. A read presenting that token in the same header
+// observes the push: on a handler serving a follower, see Options.Applied,
+// the read waits up to Options.ConsistencyWait for the follower to catch up
+// and fails with 503 if it does not. Reads answer with the position they
+// were served at, so a client presenting the latest token it got never reads
+// older data than it saw.
+//
+// A push below the priority floor of a draining queue fails with 409, see
+// priorityqueue.StartDraining. Request bodies larger than
+// Options.MaxBodyBytes fail with 413.
+//
+// Items use the JSON format of priorityqueue.QItem.
+package httppq
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+// A Route names one endpoint of the API for authorization
+type Route string
+
+const (
+	RoutePush           Route = "push"
+	RoutePop            Route = "pop"
+	RoutePeek           Route = "peek"
+	RouteLen            Route = "len"
+	RouteGetItem        Route = "get-item"
+	RouteStats          Route = "stats"
+	RouteClear          Route = "clear"
+	RouteDeleteItem     Route = "delete-item"
+	RouteDeleteParent   Route = "delete-parent"
+	RouteUpdatePriority Route = "update-priority"
+	RouteHealthz        Route = "healthz"
+	RouteReadyz         Route = "readyz"
+	RouteOpenAPI        Route = "openapi"
+)
+
+// Options configure a Handler
+type Options struct {
+	// Tokens maps bearer tokens, sent as "Authorization: Bearer <token>",
+	// to the principal they authenticate. When a request carries no token
+	// the common name of its verified client certificate, if any, is used
+	// as the principal.
+	Tokens map[string]string
+
+	// Anonymous allows requests that authenticate neither with a token nor
+	// a client certificate; their principal is the empty string.
+	Anonymous bool
+
+	// Authorize, if set, decides whether principal may call route. The
+	// principal is also passed on to the queue's Authorizer.
+	Authorize func(principal string, route Route) bool
+
+	// TLSConfig is used by NewServer
+	TLSConfig *tls.Config
+
+	// Ready, if set, reports whether the service embedding the queue is
+	// ready to serve; /readyz requires both Ready and the queue's Healthy
+	// to return nil.
+	Ready func() error
+
+	// IdempotencyWindow is how long the Idempotency-Key of a push is
+	// remembered, DefaultIdempotencyWindow if zero
+	IdempotencyWindow time.Duration
+
+	// Applied, for a handler serving a follower of another queue, reports
+	// the position of that queue the follower has applied, such as the
+	// Position of the latest journal entry replicated. Consistency tokens
+	// are checked against the queue's own Position if nil.
+	Applied func() uint64
+
+	// ConsistencyWait is how long a read waits for the position of its
+	// Consistency-Token, DefaultConsistencyWait if zero
+	ConsistencyWait time.Duration
+
+	// MaxBodyBytes is the size of the largest request body read,
+	// DefaultMaxBodyBytes if zero; larger bodies fail with 413
+	MaxBodyBytes int64
+}
+
+// DefaultIdempotencyWindow is the Options.IdempotencyWindow used when zero
+const DefaultIdempotencyWindow = 10 * time.Minute
+
+// DefaultConsistencyWait is the Options.ConsistencyWait used when zero
+const DefaultConsistencyWait = time.Second
+
+// DefaultMaxBodyBytes is the Options.MaxBodyBytes used when zero
+const DefaultMaxBodyBytes = 1 << 20
+
+// ConsistencyHeader carries consistency tokens, see the package
+// documentation
+const ConsistencyHeader = "Consistency-Token"
+
+// consistencyPoll is how often a read waiting for its consistency token
+// checks the position of the queue
+const consistencyPoll = 5 * time.Millisecond
+
+// ErrBehind is returned, with 503, by the reads of a follower that has not
+// caught up with their consistency token within Options.ConsistencyWait
+var ErrBehind = errors.New("follower has not caught up with the consistency token")
+
+// AllowRoutes returns an Options.Authorize function granting each principal
+// the routes listed for it, and nothing else.
+func AllowRoutes(grants map[string][]Route) func(principal string, route Route) bool {
+	return func(principal string, route Route) bool {
+		for _, r := range grants[principal] {
+			if r == route {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MutualTLSConfig returns a TLS configuration serving cert and requiring
+// clients to present a certificate signed by one of clientCAs.
+func MutualTLSConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// Handler serves the API for one queue
+type Handler struct {
+	q    *pq.PriorityQueue
+	opts Options
+
+	// The Idempotency-Keys of recent pushes, by principal and key, and in
+	// the order they were pushed for expiry
+	m      sync.Mutex
+	pushed map[string]*keyedPush
+	keys   []*keyedPush
+}
+
+// A keyedPush is a push made with an Idempotency-Key. Its error is set
+// before done is closed; a failed push is forgotten so it can be retried.
+type keyedPush struct {
+	id   string
+	at   time.Time
+	done chan struct{}
+	err  error
+}
+
+// NewHandler returns a Handler serving q
+func NewHandler(q *pq.PriorityQueue, opts Options) *Handler {
+	return &Handler{q: q, opts: opts}
+}
+
+// NewServer returns an http.Server serving q on addr with opts.TLSConfig.
+// When the configuration holds certificates start it with
+// ListenAndServeTLS("", "").
+func NewServer(addr string, q *pq.PriorityQueue, opts Options) *http.Server {
+	return &http.Server{
+		Addr:      addr,
+		Handler:   NewHandler(q, opts),
+		TLSConfig: opts.TLSConfig,
+	}
+}
+
+var errUnauthenticated = errors.New("unauthenticated")
+
+// principal authenticates r
+func (h *Handler) principal(r *http.Request) (string, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if p, ok := h.opts.Tokens[token]; ok && token != auth {
+			return p, nil
+		}
+		return "", errUnauthenticated
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+	}
+	if h.opts.Anonymous {
+		return "", nil
+	}
+	return "", errUnauthenticated
+}
+
+// route matches r against the API, returning the route and its path
+// parameter.
+func route(r *http.Request) (Route, string, bool) {
+	path := strings.Trim(r.URL.EscapedPath(), "/")
+	parts := strings.Split(path, "/")
+	param := func(n int) string {
+		s, err := url.PathUnescape(parts[n])
+		if err != nil {
+			return parts[n]
+		}
+		return s
+	}
+	switch {
+	case r.Method == http.MethodPost && path == "items":
+		return RoutePush, "", true
+	case r.Method == http.MethodPost && path == "pop":
+		return RoutePop, "", true
+	case r.Method == http.MethodGet && path == "peek":
+		return RoutePeek, "", true
+	case r.Method == http.MethodGet && path == "len":
+		return RouteLen, "", true
+	case r.Method == http.MethodGet && path == "stats":
+		return RouteStats, "", true
+	case r.Method == http.MethodDelete && path == "items":
+		return RouteClear, "", true
+	case r.Method == http.MethodGet && path == "healthz":
+		return RouteHealthz, "", true
+	case r.Method == http.MethodGet && path == "readyz":
+		return RouteReadyz, "", true
+	case r.Method == http.MethodGet && path == "openapi.json":
+		return RouteOpenAPI, "", true
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "items":
+		return RouteGetItem, param(1), true
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "items":
+		return RouteDeleteItem, param(1), true
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "parents":
+		return RouteDeleteParent, param(1), true
+	case r.Method == http.MethodPut && len(parts) == 3 && parts[0] == "parents" && parts[2] == "priority":
+		return RouteUpdatePriority, param(1), true
+	}
+	return "", "", false
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt, param, ok := route(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if rt == RouteHealthz || rt == RouteReadyz {
+		h.serveHealth(w, rt)
+		return
+	}
+	if rt == RouteOpenAPI {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(OpenAPI())
+		return
+	}
+	principal, err := h.principal(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if h.opts.Authorize != nil && !h.opts.Authorize(principal, rt) {
+		writeError(w, http.StatusForbidden, pq.ErrUnauthorized)
+		return
+	}
+	ctx := pq.WithPrincipal(r.Context(), principal)
+	if rt == RoutePeek || rt == RouteLen || rt == RouteGetItem || rt == RouteStats {
+		if err := h.catchUp(w, r); err != nil {
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, strconv.ErrSyntax) || errors.Is(err, strconv.ErrRange) {
+				status = http.StatusBadRequest
+			}
+			writeError(w, status, err)
+			return
+		}
+	}
+
+	switch rt {
+	case RoutePush:
+		var item pq.QItem
+		if err := h.decode(w, r, &item); err != nil {
+			writeError(w, bodyStatus(err), err)
+			return
+		}
+		if err := h.push(ctx, principal, r.Header.Get("Idempotency-Key"), item); err != nil {
+			writeQueueError(w, err)
+			return
+		}
+		w.Header().Set(ConsistencyHeader, strconv.FormatUint(h.q.Position(), 10))
+		w.WriteHeader(http.StatusCreated)
+	case RoutePop, RoutePeek:
+		pop := h.q.PopCtx
+		if rt == RoutePeek {
+			pop = h.q.PeekCtx
+		}
+		item, err := pop(ctx)
+		if err == pq.ErrEmptyQueue {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err != nil {
+			writeQueueError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	case RouteLen:
+		writeJSON(w, http.StatusOK, map[string]int{"len": h.q.Len()})
+	case RouteGetItem:
+		state := h.q.State(param)
+		if state == pq.StateUnknown {
+			writeError(w, http.StatusNotFound, pq.ErrNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"id": param, "state": state.String()})
+	case RouteStats:
+		writeJSON(w, http.StatusOK, h.q.Stats())
+	case RouteClear:
+		if err := h.q.ClearCtx(ctx); err != nil {
+			writeQueueError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case RouteDeleteItem:
+		if err := h.q.DeleteItemByIdCtx(ctx, param); err != nil {
+			writeQueueError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case RouteDeleteParent:
+		n, err := h.q.DeleteItemsByParentIdCtx(ctx, param)
+		if err != nil {
+			writeQueueError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"deleted": n})
+	case RouteUpdatePriority:
+		var body struct {
+			Priority *int `json:"priority"`
+		}
+		if err := h.decode(w, r, &body); err != nil || body.Priority == nil {
+			writeError(w, bodyStatus(err), errors.New("body must be {\"priority\": n}"))
+			return
+		}
+		n, err := h.q.UpdatePriorityByParentIdCtx(ctx, param, *body.Priority)
+		if err != nil {
+			writeQueueError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"updated": n})
+	}
+}
+
+// decode decodes the JSON body of r into v, reading at most
+// Options.MaxBodyBytes
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	limit := h.opts.MaxBodyBytes
+	if limit == 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(v)
+}
+
+// bodyStatus is the status answering a request whose body failed to
+// decode with err
+func bodyStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// push pushes item unless a push with the same idempotency key was made by
+// principal within the idempotency window. A retry arriving while the push
+// is under way waits for its outcome rather than pushing again.
+func (h *Handler) push(ctx context.Context, principal, key string, item pq.QItem) error {
+	if key == "" {
+		return h.q.PushCtx(ctx, item)
+	}
+	window := h.opts.IdempotencyWindow
+	if window == 0 {
+		window = DefaultIdempotencyWindow
+	}
+	id := principal + "\x00" + key
+	var p *keyedPush
+	for p == nil {
+		h.m.Lock()
+		now := time.Now()
+		for len(h.keys) > 0 && now.Sub(h.keys[0].at) >= window {
+			if h.pushed[h.keys[0].id] == h.keys[0] {
+				delete(h.pushed, h.keys[0].id)
+			}
+			h.keys = h.keys[1:]
+		}
+		prev, ok := h.pushed[id]
+		if !ok {
+			if h.pushed == nil {
+				h.pushed = make(map[string]*keyedPush)
+			}
+			p = &keyedPush{id: id, at: now, done: make(chan struct{})}
+			h.pushed[id] = p
+			h.keys = append(h.keys, p)
+		}
+		h.m.Unlock()
+		if ok {
+			select {
+			case <-prev.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if prev.err == nil {
+				return nil
+			}
+		}
+	}
+
+	err := h.q.PushCtx(ctx, item)
+	h.m.Lock()
+	p.err = err
+	if err != nil && h.pushed[id] == p {
+		delete(h.pushed, id)
+	}
+	h.m.Unlock()
+	close(p.done)
+	return err
+}
+
+// position returns the position reached by the queue served, or by the
+// queue it follows
+func (h *Handler) position() uint64 {
+	if h.opts.Applied != nil {
+		return h.opts.Applied()
+	}
+	return h.q.Position()
+}
+
+// catchUp waits for the queue served to reach the position of the
+// consistency token of r, if any, and answers with the position reached
+func (h *Handler) catchUp(w http.ResponseWriter, r *http.Request) error {
+	var want uint64
+	if token := r.Header.Get(ConsistencyHeader); token != "" {
+		var err error
+		if want, err = strconv.ParseUint(token, 10, 64); err != nil {
+			return err
+		}
+	}
+	wait := h.opts.ConsistencyWait
+	if wait == 0 {
+		wait = DefaultConsistencyWait
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		at := h.position()
+		if at >= want {
+			w.Header().Set(ConsistencyHeader, strconv.FormatUint(at, 10))
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return ErrBehind
+		}
+		select {
+		case <-time.After(consistencyPoll):
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+}
+
+func (h *Handler) serveHealth(w http.ResponseWriter, rt Route) {
+	err := h.q.Healthy()
+	if err == nil && rt == RouteReadyz && h.opts.Ready != nil {
+		err = h.opts.Ready()
+	}
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeQueueError maps errors returned by the queue to HTTP statuses
+func writeQueueError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pq.ErrUnauthorized):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, pq.ErrRateLimited), errors.Is(err, pq.ErrQuotaExceeded):
+		writeError(w, http.StatusTooManyRequests, err)
+	case errors.Is(err, pq.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, pq.ErrDraining):
+		// A policy answer rather than a failure of the server, which clients
+		// must not retry
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, pq.ErrFrozen), errors.Is(err, pq.ErrQueueDestroyed):
+		writeError(w, http.StatusServiceUnavailable, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
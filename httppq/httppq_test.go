@@ -0,0 +1,318 @@
+package httppq
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+func request(t *testing.T, c *http.Client, method, url, token, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	return resp
+}
+
+func Test_TokenAuthAndRoutes(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	srv := httptest.NewServer(NewHandler(q, Options{
+		Tokens: map[string]string{"secret-p": "producer", "secret-c": "consumer"},
+		Authorize: AllowRoutes(map[string][]Route{
+			"producer": {RoutePush, RouteLen},
+			"consumer": {RoutePop, RouteLen},
+		}),
+	}))
+	defer srv.Close()
+	c := srv.Client()
+
+	resp := request(t, c, "POST", srv.URL+"/items", "", `{"id":"a","priority":1}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Anonymous push returned %d, expected 401", resp.StatusCode)
+	}
+	resp = request(t, c, "POST", srv.URL+"/items", "wrong", `{"id":"a","priority":1}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Push with an unknown token returned %d, expected 401", resp.StatusCode)
+	}
+	resp = request(t, c, "POST", srv.URL+"/items", "secret-p", `{"id":"a","priority":1}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Push returned %d, expected 201", resp.StatusCode)
+	}
+	resp = request(t, c, "POST", srv.URL+"/pop", "secret-p", "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Producer pop returned %d, expected 403", resp.StatusCode)
+	}
+
+	resp = request(t, c, "POST", srv.URL+"/pop", "secret-c", "")
+	var item pq.QItem
+	json.NewDecoder(resp.Body).Decode(&item)
+	if resp.StatusCode != http.StatusOK || item.ID != "a" {
+		t.Errorf("Pop returned %d %+v, expected item a", resp.StatusCode, item)
+	}
+	resp = request(t, c, "POST", srv.URL+"/pop", "secret-c", "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Pop on an empty queue returned %d, expected 204", resp.StatusCode)
+	}
+}
+
+func Test_ParentRoutesAndQueueAuthorizer(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	q.Push(pq.QItem{ID: "a", ParentID: "job/1", Tenant: "alice"})
+	q.Push(pq.QItem{ID: "b", ParentID: "job/1", Tenant: "alice"})
+	q.Push(pq.QItem{ID: "c", ParentID: "job/2", Tenant: "bob"})
+	q.SetAuthorizer(func(principal interface{}, op pq.Operation, item *pq.QItem) error {
+		if principal != item.Tenant {
+			return pq.ErrUnauthorized
+		}
+		return nil
+	})
+	srv := httptest.NewServer(NewHandler(q, Options{Tokens: map[string]string{"t": "alice"}}))
+	defer srv.Close()
+	c := srv.Client()
+
+	resp := request(t, c, "PUT", srv.URL+"/parents/job%2F1/priority", "t", `{"priority":7}`)
+	var updated map[string]int
+	json.NewDecoder(resp.Body).Decode(&updated)
+	if resp.StatusCode != http.StatusOK || updated["updated"] != 2 {
+		t.Errorf("Update returned %d %v, expected 2 items updated", resp.StatusCode, updated)
+	}
+	resp = request(t, c, "DELETE", srv.URL+"/parents/job%2F2", "t", "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Deleting another tenant's items returned %d, expected 403", resp.StatusCode)
+	}
+	resp = request(t, c, "DELETE", srv.URL+"/items/missing", "t", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Deleting a missing item returned %d, expected 404", resp.StatusCode)
+	}
+	resp = request(t, c, "DELETE", srv.URL+"/items/a", "t", "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Delete returned %d, expected 204", resp.StatusCode)
+	}
+	if q.Len() != 2 {
+		t.Errorf("Queue holds %d items, expected 2", q.Len())
+	}
+}
+
+// certificate returns a certificate for name signed by parent, self signed
+// when parent is nil.
+func certificate(t *testing.T, name string, parent *tls.Certificate) tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	signer, signerKey := tmpl, interface{}(key)
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer = parent.Leaf
+		signerKey = parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Creating certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func Test_MutualTLS(t *testing.T) {
+	ca := certificate(t, "test-ca", nil)
+	server := certificate(t, "server", &ca)
+	client := certificate(t, "worker-7", &ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	q := pq.NewPriorityQueue()
+	var principals []interface{}
+	q.SetAuthorizer(func(principal interface{}, op pq.Operation, item *pq.QItem) error {
+		principals = append(principals, principal)
+		return nil
+	})
+	srv := httptest.NewUnstartedServer(NewHandler(q, Options{}))
+	srv.TLS = MutualTLSConfig(server, pool)
+	srv.StartTLS()
+	defer srv.Close()
+
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{client},
+	}}}
+	resp := request(t, c, "POST", srv.URL+"/items", "", `{"id":"a","priority":1}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Push with a client certificate returned %d, expected 201", resp.StatusCode)
+	}
+	if len(principals) != 1 || principals[0] != "worker-7" {
+		t.Errorf("Queue authorizer saw principals %v, expected the certificate name", principals)
+	}
+
+	anon := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if _, err := anon.Get(srv.URL + "/len"); err == nil {
+		t.Errorf("Connecting without a client certificate should fail")
+	}
+}
+
+func Test_HealthRoutes(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	var notReady error
+	srv := httptest.NewServer(NewHandler(q, Options{Ready: func() error { return notReady }}))
+	defer srv.Close()
+	c := srv.Client()
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		resp := request(t, c, "GET", srv.URL+path, "", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s returned %d, expected 200", path, resp.StatusCode)
+		}
+	}
+	notReady = errors.New("warming up")
+	resp := request(t, c, "GET", srv.URL+"/readyz", "", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("/readyz returned %d, expected 503", resp.StatusCode)
+	}
+	resp = request(t, c, "GET", srv.URL+"/healthz", "", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/healthz returned %d, expected 200", resp.StatusCode)
+	}
+}
+
+func Test_ConsistencyTokens(t *testing.T) {
+	primary, follower := pq.NewPriorityQueue(), pq.NewPriorityQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The follower applies the journal of the primary once released
+	var applied atomic.Uint64
+	release := make(chan struct{})
+	journal := primary.Tail(ctx, 16)
+	go func() {
+		<-release
+		for e := range journal {
+			if e.Op == pq.OpPush {
+				follower.Push(pq.QItem{ID: e.ID, Priority: e.Priority})
+			}
+			applied.Store(e.Position)
+		}
+	}()
+
+	tokens := map[string]string{"secret": "app"}
+	p := httptest.NewServer(NewHandler(primary, Options{Tokens: tokens}))
+	defer p.Close()
+	f := httptest.NewServer(NewHandler(follower, Options{Tokens: tokens, Applied: applied.Load, ConsistencyWait: 200 * time.Millisecond}))
+	defer f.Close()
+
+	writer := NewClient(p.URL, ClientOptions{Token: "secret", HTTPClient: p.Client()})
+	writer.Push(pq.QItem{ID: "a", Priority: 1})
+	token := writer.ConsistencyToken()
+	if token != strconv.FormatUint(primary.Position(), 10) {
+		t.Errorf("Push returned the token %q, expected the position %d", token, primary.Position())
+	}
+
+	reader := NewClient(f.URL, ClientOptions{Token: "secret", HTTPClient: f.Client(), Retries: -1, BreakerFailures: -1})
+	if n := reader.Len(); n != 0 {
+		t.Errorf("Len without a token returned %d, expected 0", n)
+	}
+	reader.SetConsistencyToken(token)
+	if reader.Len() != 0 || reader.Err() == nil || !strings.Contains(reader.Err().Error(), ErrBehind.Error()) {
+		t.Errorf("Len of a follower behind the token returned %v", reader.Err())
+	}
+
+	close(release)
+	if n := reader.Len(); n != 1 {
+		t.Errorf("Len of a follower caught up returned %d, expected 1: %v", n, reader.Err())
+	}
+
+	resp := request(t, p.Client(), "GET", p.URL+"/items/a", "secret", "")
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusOK || body["state"] != "queued" {
+		t.Errorf("Getting item a returned %d %v", resp.StatusCode, body)
+	}
+	resp = request(t, p.Client(), "GET", p.URL+"/items/b", "secret", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Getting a missing item returned %d, expected 404", resp.StatusCode)
+	}
+	resp = request(t, p.Client(), "GET", p.URL+"/stats", "secret", "")
+	var stats pq.Stats
+	json.NewDecoder(resp.Body).Decode(&stats)
+	if resp.StatusCode != http.StatusOK || stats.Len != 1 {
+		t.Errorf("Stats returned %d %+v", resp.StatusCode, stats)
+	}
+}
+
+func Test_BodyLimitAndKeyedPushes(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	srv := httptest.NewServer(NewHandler(q, Options{Anonymous: true, MaxBodyBytes: 64}))
+	defer srv.Close()
+	c := srv.Client()
+
+	resp := request(t, c, "POST", srv.URL+"/items", "", `{"id":"a","value":"`+strings.Repeat("x", 100)+`"}`)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Pushing a large body returned %d, expected 413", resp.StatusCode)
+	}
+
+	// Concurrent retries push once
+	push := func(id string) int {
+		req, _ := http.NewRequest("POST", srv.URL+"/items", strings.NewReader(`{"id":"`+id+`","priority":1}`))
+		req.Header.Set("Idempotency-Key", "k-"+id)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Pushing: %v", err)
+		}
+		return resp.StatusCode
+	}
+	var created atomic.Int32
+	done := make(chan struct{})
+	for n := 0; n < 10; n++ {
+		go func() {
+			if push("b") == http.StatusCreated {
+				created.Add(1)
+			}
+			done <- struct{}{}
+		}()
+	}
+	for n := 0; n < 10; n++ {
+		<-done
+	}
+	if created.Load() != 10 || q.Len() != 1 {
+		t.Errorf("Retries returned 201 %d times and queued %d items, expected 10 and 1", created.Load(), q.Len())
+	}
+
+	// A failed push is not remembered
+	q.StartDraining(5, nil)
+	if status := push("c"); status != http.StatusConflict {
+		t.Errorf("Push below the floor returned %d, expected 409", status)
+	}
+	q.StopDraining()
+	if status := push("c"); status != http.StatusCreated || q.Len() != 2 {
+		t.Errorf("Retried push returned %d and queued %d items, expected 201 and 2", status, q.Len())
+	}
+}
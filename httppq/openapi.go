@@ -0,0 +1,275 @@
+package httppq
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+//go:generate go run ../cmd/pqclients -out ../clients
+
+// object is a JSON object of the OpenAPI document
+type object = map[string]interface{}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+// OpenAPI returns the OpenAPI 3 document describing the API in JSON, from
+// which clients can be generated in other languages. Handlers serve it on
+// GET /openapi.json.
+func OpenAPI() []byte {
+	openAPIOnce.Do(func() {
+		openAPIDoc, _ = json.MarshalIndent(openAPI(), "", "  ")
+	})
+	return openAPIDoc
+}
+
+func openAPI() object {
+	ref := func(name string) object { return object{"$ref": "#/components/schemas/" + name} }
+	content := func(schema object) object {
+		return object{"content": object{"application/json": object{"schema": schema}}}
+	}
+	reply := func(description string, schema object) object {
+		r := object{"description": description}
+		if schema != nil {
+			for k, v := range content(schema) {
+				r[k] = v
+			}
+		}
+		return r
+	}
+	count := func(name string) object {
+		return object{"type": "object", "required": []string{name}, "properties": object{name: object{"type": "integer"}}}
+	}
+	errorReply := reply("Error", ref("Error"))
+	consistency := object{"$ref": "#/components/parameters/ConsistencyToken"}
+	consistent := object{
+		ConsistencyHeader: object{"description": "Position the request was served at", "schema": object{"type": "string"}},
+	}
+	withConsistency := func(r object) object {
+		r["headers"] = consistent
+		return r
+	}
+	param := func(name, description string) object {
+		return object{"name": name, "in": "path", "required": true, "description": description, "schema": object{"type": "string"}}
+	}
+	op := func(rt Route, summary string, responses object, more object) object {
+		o := object{"operationId": operationID(rt), "summary": summary, "responses": responses}
+		for k, v := range more {
+			o[k] = v
+		}
+		return o
+	}
+	public := []object{}
+	health := func(rt Route, summary string) object {
+		return object{"get": op(rt, summary, object{
+			"200": reply("Healthy", ref("Health")),
+			"503": reply("Unhealthy", ref("Health")),
+		}, object{"security": public})}
+	}
+	denied := object{"401": errorReply, "403": errorReply, "429": errorReply, "503": errorReply}
+	responses := func(rs object) object {
+		for k, v := range denied {
+			if _, ok := rs[k]; !ok {
+				rs[k] = v
+			}
+		}
+		rs["default"] = errorReply
+		return rs
+	}
+
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":       "Priority queue",
+			"version":     "1",
+			"description": "Priority queue served by httppq. Requests authenticate with a bearer token or a client certificate, see the package documentation.",
+		},
+		"security": []object{{"bearerAuth": []string{}}},
+		"paths": object{
+			"/items": object{
+				"post": op(RoutePush, "Push an item", responses(object{
+					"201": object{"description": "Pushed", "headers": object{
+						ConsistencyHeader: object{"description": "Position of the queue once the item was pushed", "schema": object{"type": "string"}},
+					}},
+					"400": errorReply,
+					"409": reply("The queue is draining and the item is below its priority floor", ref("Error")),
+					"413": reply("The body is larger than the server accepts", ref("Error")),
+				}), object{
+					"parameters": []object{{
+						"name": "Idempotency-Key", "in": "header",
+						"description": "Pushes the item once however often the request is repeated within the idempotency window",
+						"schema":      object{"type": "string"},
+					}},
+					"requestBody": func() object { b := content(ref("Item")); b["required"] = true; return b }(),
+				}),
+				"delete": op(RouteClear, "Delete every item", responses(object{
+					"204": object{"description": "Deleted"},
+				}), nil),
+			},
+			"/pop": object{"post": op(RoutePop, "Pop the highest priority item", responses(object{
+				"200": reply("The item popped", ref("Item")),
+				"204": object{"description": "The queue is empty"},
+			}), nil)},
+			"/peek": object{"get": op(RoutePeek, "Get the highest priority item", responses(object{
+				"200": withConsistency(reply("The highest priority item", ref("Item"))),
+				"204": object{"description": "The queue is empty"},
+				"400": errorReply,
+			}), object{"parameters": []object{consistency}})},
+			"/len": object{"get": op(RouteLen, "Count the queued items", responses(object{
+				"200": withConsistency(reply("The number of queued items", count("len"))),
+				"400": errorReply,
+			}), object{"parameters": []object{consistency}})},
+			"/stats": object{"get": op(RouteStats, "Describe the contents of the queue", responses(object{
+				"200": withConsistency(reply("The statistics of the queue", ref("Stats"))),
+				"400": errorReply,
+			}), object{"parameters": []object{consistency}})},
+			"/items/{id}": object{
+				"parameters": []object{param("id", "ID of the item")},
+				"get": op(RouteGetItem, "Get the state of an item", responses(object{
+					"200": withConsistency(reply("The state of the item", ref("ItemState"))),
+					"400": errorReply,
+					"404": errorReply,
+				}), object{"parameters": []object{consistency}}),
+				"delete": op(RouteDeleteItem, "Delete an item", responses(object{
+					"204": object{"description": "Deleted"},
+					"404": errorReply,
+				}), nil),
+			},
+			"/parents/{parentID}": object{
+				"parameters": []object{param("parentID", "ParentID of the items")},
+				"delete": op(RouteDeleteParent, "Delete the items of a parent", responses(object{
+					"200": reply("The number of items deleted", count("deleted")),
+				}), nil),
+			},
+			"/parents/{parentID}/priority": object{
+				"parameters": []object{param("parentID", "ParentID of the items")},
+				"put": op(RouteUpdatePriority, "Update the priority of the items of a parent", responses(object{
+					"200": reply("The number of items updated", count("updated")),
+					"400": errorReply,
+					"413": reply("The body is larger than the server accepts", ref("Error")),
+				}), object{"requestBody": func() object { b := content(count("priority")); b["required"] = true; return b }()}),
+			},
+			"/healthz": health(RouteHealthz, "Check the queue is healthy"),
+			"/readyz":  health(RouteReadyz, "Check the queue is ready to serve"),
+			"/openapi.json": object{"get": op(RouteOpenAPI, "Get this document", object{
+				"200": reply("The OpenAPI document", object{"type": "object"}),
+			}, object{"security": public})},
+		},
+		"components": object{
+			"securitySchemes": object{
+				"bearerAuth": object{"type": "http", "scheme": "bearer"},
+			},
+			"parameters": object{
+				"ConsistencyToken": object{
+					"name": ConsistencyHeader, "in": "header",
+					"description": "Position the read must observe, as returned by a push or an earlier read",
+					"schema":      object{"type": "string"},
+				},
+			},
+			"schemas": object{
+				"Item":  itemSchema(),
+				"Stats": schemaOf(reflect.TypeOf(pq.Stats{})),
+				"ItemState": object{"type": "object", "required": []string{"id", "state"}, "properties": object{
+					"id":    object{"type": "string"},
+					"state": object{"type": "string"},
+				}},
+				"Health": object{"type": "object", "required": []string{"status"}, "properties": object{
+					"status": object{"type": "string", "enum": []string{"ok", "unavailable"}},
+					"error":  object{"type": "string"},
+				}},
+				"Error": object{"type": "object", "required": []string{"error"}, "properties": object{
+					"error": object{"type": "string"},
+				}},
+			},
+		},
+	}
+}
+
+// operationID turns a route into the identifier of its operation, such as
+// updatePriority for RouteUpdatePriority
+func operationID(rt Route) string {
+	words := strings.Split(string(rt), "-")
+	for n := 1; n < len(words); n++ {
+		words[n] = strings.ToUpper(words[n][:1]) + words[n][1:]
+	}
+	return strings.Join(words, "")
+}
+
+// itemSchema describes the JSON format of priorityqueue.QItem
+func itemSchema() object {
+	str := object{"type": "string"}
+	integer := object{"type": "integer"}
+	at := object{"type": "string", "format": "date-time"}
+	return object{
+		"type":     "object",
+		"required": []string{"id", "priority"},
+		"properties": object{
+			"id":              str,
+			"parent_id":       str,
+			"value":           object{"description": "Any JSON value"},
+			"priority":        integer,
+			"tenant":          str,
+			"producer":        str,
+			"pushed_at":       at,
+			"expires_at":      at,
+			"attempts":        integer,
+			"cost":            integer,
+			"idempotency_key": str,
+			"merged":          integer,
+			"sync_token":      str,
+			"level":           str,
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf describes the JSON encoding of values of type t
+func schemaOf(t reflect.Type) object {
+	if t == timeType {
+		return object{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return object{"type": "number"}
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Pointer:
+		s := schemaOf(t.Elem())
+		s["nullable"] = true
+		return s
+	case reflect.Array:
+		return object{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Slice:
+		return object{"type": "array", "items": schemaOf(t.Elem()), "nullable": true}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": schemaOf(t.Elem()), "nullable": true}
+	case reflect.Struct:
+		props := object{}
+		for n := 0; n < t.NumField(); n++ {
+			f := t.Field(n)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = schemaOf(f.Type)
+		}
+		return object{"type": "object", "properties": props}
+	}
+	return object{}
+}
@@ -0,0 +1,65 @@
+package httppq
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pq "PriorityQueue"
+)
+
+func Test_OpenAPI(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(pq.NewPriorityQueue(), Options{}))
+	defer srv.Close()
+
+	// Served without authentication
+	resp := request(t, srv.Client(), "GET", srv.URL+"/openapi.json", "", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /openapi.json returned %d, expected 200", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage        `json:"paths"`
+		Comps   struct{ Schemas map[string]json.RawMessage } `json:"components"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Decoding the document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Document version is %q", doc.OpenAPI)
+	}
+
+	// Every route is documented
+	for path, methods := range map[string][]string{
+		"/items":                       {"post", "delete"},
+		"/pop":                         {"post"},
+		"/peek":                        {"get"},
+		"/len":                         {"get"},
+		"/stats":                       {"get"},
+		"/items/{id}":                  {"get", "delete"},
+		"/parents/{parentID}":          {"delete"},
+		"/parents/{parentID}/priority": {"put"},
+		"/healthz":                     {"get"},
+		"/readyz":                      {"get"},
+		"/openapi.json":                {"get"},
+	} {
+		for _, m := range methods {
+			if _, ok := doc.Paths[path][m]; !ok {
+				t.Errorf("%s %s is not documented", m, path)
+			}
+		}
+	}
+	var stats struct {
+		Properties map[string]json.RawMessage
+	}
+	json.Unmarshal(doc.Comps.Schemas["Stats"], &stats)
+	if _, ok := stats.Properties["ByProducer"]; !ok {
+		t.Errorf("Stats schema lacks ByProducer: %s", doc.Comps.Schemas["Stats"])
+	}
+	if operationID(RouteUpdatePriority) != "updatePriority" {
+		t.Errorf("Operation ID is %q", operationID(RouteUpdatePriority))
+	}
+}
@@ -0,0 +1,82 @@
+package priorityqueue
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SetIDGenerator sets the function generating the IDs of items pushed with
+// an empty ID, which PushInfo reports in PushResult.ID. Nil, the default,
+// generates ULIDs with NewULID.
+func (pq *PriorityQueue) SetIDGenerator(gen func() string) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.idGen = gen
+}
+
+// assignID gives i a generated ID if it has none. The queue lock must be
+// held.
+func (pq *PriorityQueue) assignID(i *QItem) {
+	if i.ID != "" {
+		return
+	}
+	if pq.idGen != nil {
+		i.ID = pq.idGen()
+		return
+	}
+	i.ID = NewULID()
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulids keeps the ULIDs generated in the same millisecond increasing
+var ulids struct {
+	sync.Mutex
+	ms     uint64
+	hi     uint16 // Top 16 of the 80 random bits
+	lo     uint64 // Bottom 64 of the 80 random bits
+	random [10]byte
+}
+
+// NewULID returns a ULID, a 26 character ID sorting by the millisecond it
+// was generated in. IDs generated in the same millisecond sort in the
+// order generated.
+func NewULID() string {
+	ulids.Lock()
+	ms := uint64(time.Now().UnixMilli())
+	if ms > ulids.ms {
+		ulids.ms = ms
+		rand.Read(ulids.random[:])
+		ulids.hi = binary.BigEndian.Uint16(ulids.random[:2])
+		ulids.lo = binary.BigEndian.Uint64(ulids.random[2:])
+	} else if ulids.lo++; ulids.lo == 0 {
+		ulids.hi++
+	}
+	ms, hi, lo := ulids.ms, ulids.hi, ulids.lo
+	ulids.Unlock()
+
+	var id [26]byte
+	for n := 9; n >= 0; n-- {
+		id[n] = crockford[ms&31]
+		ms >>= 5
+	}
+	for n := 25; n >= 10; n-- {
+		id[n] = crockford[lo&31]
+		lo = lo>>5 | uint64(hi&31)<<59
+		hi >>= 5
+	}
+	return string(id[:])
+}
+
+// NewUUID returns a random, version 4 UUID, for SetIDGenerator
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
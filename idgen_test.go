@@ -0,0 +1,40 @@
+package priorityqueue
+
+import (
+	"regexp"
+	"testing"
+)
+
+func Test_GeneratedIDs(t *testing.T) {
+	pq := NewPriorityQueue()
+	first, err := pq.PushInfo(QItem{Priority: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := pq.PushInfo(QItem{Priority: 2})
+	assertEqual(t, len(first.ID), 26)
+	assertEqual(t, first.ID < second.ID, true)
+
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, second.ID)
+	assertEqual(t, pq.DeleteItemById(first.ID), nil)
+
+	kept, _ := pq.PushInfo(QItem{ID: "mine"})
+	assertEqual(t, kept.ID, "")
+
+	pq.SetIDGenerator(NewUUID)
+	res, _ := pq.PushInfo(QItem{})
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	assertEqual(t, uuid.MatchString(res.ID), true)
+}
+
+func Test_ULIDsIncrease(t *testing.T) {
+	last := NewULID()
+	for n := 0; n < 1000; n++ {
+		id := NewULID()
+		if id <= last {
+			t.Fatalf("ULID %s after %s", id, last)
+		}
+		last = id
+	}
+}
@@ -0,0 +1,102 @@
+package priorityqueue
+
+import (
+	"errors"
+	"strings"
+)
+
+// FoldCase is an ID normalizer for WithIDNormalizer making IDs and
+// ParentIDs case insensitive
+func FoldCase(id string) string {
+	return strings.ToLower(id)
+}
+
+// WithIDNormalizer makes the queue compare IDs and ParentIDs by their
+// normalized form, such as FoldCase for IDs arriving with inconsistent
+// casing: the lookups, updates and deletes by ID, ParentID, parent tree or
+// pattern, State, Progress, pausing, barriers, parent priorities and
+// ceilings, purges and escalation rules all treat IDs normalizing alike as
+// equal. Items keep the IDs they were pushed with; TopParents and the
+// parent patterns see the normalized ParentIDs. normalize must return its
+// result unchanged when applied to it again. As the indexes are keyed by
+// the normalized IDs the option must come before any option queuing items.
+func WithIDNormalizer(normalize func(id string) string) Option {
+	return func(pq *PriorityQueue) error {
+		if len(pq.data) > 0 || len(pq.states) > 0 {
+			return errors.New("the ID normalizer must be set before items are queued")
+		}
+		pq.normalizeID = normalize
+		return nil
+	}
+}
+
+// idKey returns the normalized form of an ID or ParentID, which the indexes
+// are keyed by
+func (pq *PriorityQueue) idKey(id string) string {
+	if pq.normalizeID == nil {
+		return id
+	}
+	return pq.normalizeID(id)
+}
+
+// sameID reports whether two IDs or ParentIDs are equal once normalized
+func (pq *PriorityQueue) sameID(a, b string) bool {
+	return a == b || pq.idKey(a) == pq.idKey(b)
+}
+
+// KeySeparator separates the ParentID from the ID in the keys made by Key
+const KeySeparator = "\x00"
+
+// Key returns the key of the item with the given ParentID and ID, to pass
+// for an ID to a queue with composite keys, see WithCompositeKeys
+func Key(parentID, id string) string {
+	return parentID + KeySeparator + id
+}
+
+// WithCompositeKeys identifies items by their ParentID and ID together
+// rather than by their ID alone, for IDs unique only within their parent.
+// The lookups, updates and deletes by ID, State, History, RestoreDeleted,
+// ForceRelease and Steal then take the Key of an item, a bare ID naming
+// the item of that ID without a ParentID; coalescing, Seed, Reconcile and
+// Recover tell duplicates apart by key too. As the indexes are keyed by
+// the keys the option must come before any option queuing items.
+func WithCompositeKeys() Option {
+	return func(pq *PriorityQueue) error {
+		if len(pq.data) > 0 || len(pq.states) > 0 {
+			return errors.New("composite keys must be set before items are queued")
+		}
+		pq.compositeKeys = true
+		return nil
+	}
+}
+
+// itemKey returns the normalized key identifying an item, its ID or its
+// ParentID and ID with composite keys
+func (pq *PriorityQueue) itemKey(i *QItem) string {
+	if !pq.compositeKeys {
+		return pq.idKey(i.ID)
+	}
+	return pq.idKey(i.ParentID) + KeySeparator + pq.idKey(i.ID)
+}
+
+// lookupKey returns the normalized key named by an ID passed to the queue,
+// a Key with composite keys
+func (pq *PriorityQueue) lookupKey(id string) string {
+	if !pq.compositeKeys {
+		return pq.idKey(id)
+	}
+	parentID, id, ok := strings.Cut(id, KeySeparator)
+	if !ok {
+		parentID, id = "", parentID
+	}
+	return pq.idKey(parentID) + KeySeparator + pq.idKey(id)
+}
+
+// isKey reports whether the item is the one named by an ID passed to the
+// queue
+func (pq *PriorityQueue) isKey(i *QItem, id string) bool {
+	if !pq.compositeKeys && i.ID == id {
+		return true
+	}
+	return pq.itemKey(i) == pq.lookupKey(id)
+}
@@ -0,0 +1,81 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_IDNormalizer(t *testing.T) {
+	pq, err := New(WithIDNormalizer(FoldCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pq.Push(QItem{ID: "Order-1", ParentID: "Acme/Jobs", Priority: 1})
+	pq.Push(QItem{ID: "order-2", ParentID: "acme/jobs", Priority: 2})
+	pq.Push(QItem{ID: "ORDER-3", ParentID: "ACME", Priority: 3})
+
+	assertEqual(t, pq.State("order-1"), StateQueued)
+	assertEqual(t, pq.UpdatePriorityByParentId("ACME/JOBS", 5), 2)
+	n, _ := pq.UpdatePriorityByIds([]string{"order-3"}, 10)
+	assertEqual(t, n, 1)
+	assertEqual(t, len(pq.TopParents(10)), 2)
+	assertEqual(t, pq.Progress("acme").Outstanding, 1)
+
+	pq.PauseParent("acme")
+	_, err = pq.Pop()
+	assertEqual(t, err, ErrEmptyQueue)
+	pq.ResumeParent("Acme")
+
+	assertEqual(t, pq.DeleteItemById("ORDER-1"), nil)
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "ORDER-3")
+	n, _ = pq.DeleteItemsByParentTree("Acme")
+	assertEqual(t, n, 1)
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, pq.checkInvariants(), nil)
+}
+
+func Test_IDNormalizerAfterItems(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "1"})
+	assertEqual(t, WithIDNormalizer(FoldCase)(pq) != nil, true)
+}
+
+func Test_CompositeKeys(t *testing.T) {
+	pq, err := New(WithCompositeKeys(), WithIDNormalizer(FoldCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pq.Push(QItem{ID: "1", ParentID: "a", Priority: 1})
+	pq.Push(QItem{ID: "1", ParentID: "b", Priority: 2})
+	pq.Push(QItem{ID: "1", Priority: 3})
+
+	assertEqual(t, pq.State(Key("A", "1")), StateQueued)
+	n, _ := pq.UpdatePriorityByIds([]string{Key("a", "1")}, 10)
+	assertEqual(t, n, 1)
+	item, _ := pq.Peek()
+	assertEqual(t, item.ParentID, "a")
+
+	assertEqual(t, pq.DeleteItemById("1"), nil)
+	assertEqual(t, pq.State("1"), StateDeleted)
+	assertEqual(t, pq.State(Key("b", "1")), StateQueued)
+	assertEqual(t, pq.DeleteItemById(Key("b", "1")), nil)
+	assertEqual(t, pq.DeleteItemById(Key("b", "1")) != nil, true)
+
+	item, r, _ := pq.Lease(time.Minute)
+	assertEqual(t, item.ParentID, "a")
+	assertEqual(t, len(pq.History(Key("a", "1"))), 1)
+	assertEqual(t, len(pq.History("1")), 0)
+	pq.Ack(r)
+
+	report, _ := pq.Seed(context.Background(), func(context.Context) ([]QItem, error) {
+		return []QItem{{ID: "2", ParentID: "a"}, {ID: "2", ParentID: "b"}}, nil
+	}, SeedSkip)
+	assertEqual(t, report.Pushed, 2)
+	assertEqual(t, pq.Len(), 2)
+
+	pq = NewPriorityQueue()
+	pq.Push(QItem{ID: "1"})
+	assertEqual(t, WithCompositeKeys()(pq) != nil, true)
+}
@@ -0,0 +1,95 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrTooManyInFlight is returned by Lease and Reserve while as many items as
+// set by SetMaxInFlight are leased and not yet acked.
+var ErrTooManyInFlight = errors.New("too many items in flight")
+
+// SetMaxInFlight caps the number of items leased at once, by Lease or
+// Reserve, so consumers cannot take more work than they can finish before
+// their leases expire. Once the cap is hit Lease fails with
+// ErrTooManyInFlight and LeaseWait waits for an item to be acked, nacked or
+// its lease to expire. Zero, the default, means no cap.
+func (pq *PriorityQueue) SetMaxInFlight(n int) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.maxInFlight = n
+	pq.wake()
+}
+
+// LeaseWait is Lease waiting, until ctx is done, for an item to be pushed if
+// the queue is empty or frozen, or for a lease to end if too many items are
+// in flight.
+func (pq *PriorityQueue) LeaseWait(ctx context.Context, timeout time.Duration) (*QItem, Receipt, error) {
+	return pq.LeaseWaitAs(ctx, "", timeout)
+}
+
+// LeaseWaitAs is LeaseWait on behalf of worker, see LeaseAs
+func (pq *PriorityQueue) LeaseWaitAs(ctx context.Context, worker string, timeout time.Duration) (*QItem, Receipt, error) {
+	for {
+		unlock := pq.lock(OpLease)
+		item, r, err := pq.lease(OpLease, worker, timeout)
+		if err != ErrEmptyQueue && err != ErrFrozen && err != ErrTooManyInFlight {
+			unlock()
+			return item, r, err
+		}
+		pushed := pq.waitPushed()
+		var due *time.Timer
+		if err == ErrFrozen {
+			due = time.NewTimer(freezePoll)
+		} else if next := pq.nextDue(); !next.IsZero() {
+			due = time.NewTimer(next.Sub(pq.now()))
+		} else {
+			due = time.NewTimer(0)
+			due.Stop()
+		}
+		unlock()
+
+		select {
+		case <-pushed:
+		case <-due.C:
+		case <-ctx.Done():
+			due.Stop()
+			return nil, Receipt{}, ctx.Err()
+		}
+		due.Stop()
+	}
+}
+
+// inFlightFull reports whether the cap set by SetMaxInFlight is hit. The
+// queue lock must be held.
+func (pq *PriorityQueue) inFlightFull() bool {
+	return pq.maxInFlight > 0 && len(pq.leases) >= pq.maxInFlight
+}
+
+// A Claim is an item in flight and the worker holding it, see InFlight
+type Claim struct {
+	Item     QItem
+	Worker   string    // Who leased the item, see LeaseAs
+	Leased   time.Time // When the item was leased
+	Deadline time.Time // When the lease expires, zero for a reservation
+}
+
+// InFlight returns the items leased or reserved and not yet acked, nacked
+// or expired, in the order they were leased, so workers stuck on an item
+// can be found by their Deadline passing or their Leased time.
+func (pq *PriorityQueue) InFlight() []Claim {
+	defer pq.lock(OpStats)()
+	seqs := make([]uint64, 0, len(pq.leases))
+	for seq := range pq.leases {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	claims := make([]Claim, len(seqs))
+	for n, seq := range seqs {
+		l := pq.leases[seq]
+		claims[n] = Claim{Item: l.item, Worker: l.worker, Leased: l.leased, Deadline: l.deadline}
+	}
+	return claims
+}
@@ -0,0 +1,97 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_MaxInFlight(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	pq.SetMaxInFlight(2)
+
+	_, r, err := pq.Lease(time.Minute)
+	if err != nil {
+		t.Errorf("Error leasing: %v", err)
+		return
+	}
+	if _, err := pq.Reserve(); err != nil {
+		t.Errorf("Error reserving: %v", err)
+	}
+	if _, _, err := pq.Lease(time.Minute); err != ErrTooManyInFlight {
+		t.Errorf("Error leasing over the cap: %v", err)
+	}
+	assertEqual(t, pq.Len(), 1)
+
+	if err := pq.Ack(r); err != nil {
+		t.Errorf("Error acking: %v", err)
+	}
+	if _, _, err := pq.Lease(time.Minute); err != nil {
+		t.Errorf("Error leasing after an ack: %v", err)
+	}
+}
+
+func Test_LeaseWaitInFlight(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 2)
+	pq.SetMaxInFlight(1)
+	_, r, _ := pq.Lease(time.Minute)
+
+	leased := make(chan *QItem)
+	go func() {
+		item, _, _ := pq.LeaseWait(context.Background(), time.Minute)
+		leased <- item
+	}()
+	select {
+	case <-leased:
+		t.Errorf("Error, LeaseWait returned over the cap")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	pq.Nack(r, time.Hour)
+	select {
+	case item := <-leased:
+		assertEqual(t, item.ID, "0")
+	case <-time.After(time.Second):
+		t.Errorf("Error, LeaseWait did not wake up on Nack")
+	}
+}
+
+func Test_LeaseWaitContext(t *testing.T) {
+	pq := NewPriorityQueue()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := pq.LeaseWait(ctx, time.Minute); err != context.DeadlineExceeded {
+		t.Errorf("Error waiting on an empty queue: %v", err)
+	}
+}
+
+func Test_InFlight(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pq, _ := New(WithClock(func() time.Time { return now }))
+	populateQueue(pq, 3)
+	assertEqual(t, len(pq.InFlight()), 0)
+
+	pq.LeaseAs("pod-a", time.Minute)
+	now = now.Add(time.Second)
+	_, r, _ := pq.LeaseAs("pod-b", 2*time.Minute)
+	pq.Reserve()
+
+	claims := pq.InFlight()
+	assertEqual(t, len(claims), 3)
+	assertEqual(t, claims[0].Item.ID, "2")
+	assertEqual(t, claims[0].Worker, "pod-a")
+	assertEqual(t, claims[0].Deadline, now.Add(-time.Second).Add(time.Minute))
+	assertEqual(t, claims[1].Worker, "pod-b")
+	assertEqual(t, claims[1].Leased, now)
+	assertEqual(t, claims[2].Item.ID, "0")
+	assertEqual(t, claims[2].Deadline.IsZero(), true)
+
+	// Expired and acked leases are no longer listed
+	pq.Ack(r)
+	now = now.Add(time.Minute)
+	claims = pq.InFlight()
+	assertEqual(t, len(claims), 1)
+	assertEqual(t, claims[0].Item.ID, "0")
+}
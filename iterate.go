@@ -0,0 +1,119 @@
+package priorityqueue
+
+import (
+	"iter"
+	"sort"
+	"time"
+)
+
+// DefaultChunkSize is the number of items ForEach, Snapshot and the exports
+// copy per lock acquisition unless WithChunkSize says otherwise.
+const DefaultChunkSize = 1024
+
+// ForEach calls fn with a copy of every queued item, in no particular order,
+// until fn returns false. The items are copied in chunks, releasing the lock
+// in between, so iterating over millions of items does not stall the other
+// callers. The iteration is not a point-in-time view: items pushed after
+// ForEach started are not visited, items removed before their chunk was
+// copied are skipped and each copy shows its item as it was when its chunk
+// was copied. Freeze the queue for a consistent view. fn is called without
+// the lock held and may use the queue.
+func (pq *PriorityQueue) ForEach(fn func(item QItem) bool) error {
+	return pq.forEach(OpExport, fn)
+}
+
+func (pq *PriorityQueue) forEach(op Operation, fn func(item QItem) bool) error {
+	unlock := pq.lock(op)
+	if pq.destroyed {
+		unlock()
+		return ErrQueueDestroyed
+	}
+	// Copying the pointers is cheap, the chunks copy the items they point to
+	items := append([]*QItem(nil), pq.data...)
+	chunk := pq.chunkSize
+	unlock()
+	if chunk <= 0 {
+		chunk = DefaultChunkSize
+	}
+
+	copies := make([]QItem, 0, chunk)
+	for start := 0; start < len(items); start += chunk {
+		end := start + chunk
+		if end > len(items) {
+			end = len(items)
+		}
+		copies = copies[:0]
+		unlock := pq.lock(op)
+		for _, item := range items[start:end] {
+			if pq.holds(item) {
+				copies = append(copies, *item)
+			}
+		}
+		unlock()
+		for _, c := range copies {
+			if !fn(c) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// SortedView returns copies of the queued items, highest priority first,
+// for reporting code using the sort, slices and iter packages. It returns
+// nil once the queue is destroyed. Like ForEach it is not a point-in-time
+// view unless the queue is frozen.
+func (pq *PriorityQueue) SortedView() []QItem {
+	items, err := pq.sortedItems(OpExport, false)
+	if err != nil {
+		return nil
+	}
+	view := make([]QItem, len(items))
+	for n, item := range items {
+		view[n] = *item
+	}
+	return view
+}
+
+// All returns an iterator over copies of the queued items, highest priority
+// first, as listed by SortedView when the loop starts:
+//
+//	for item := range pq.All() { ... }
+func (pq *PriorityQueue) All() iter.Seq[QItem] {
+	return func(yield func(QItem) bool) {
+		for _, item := range pq.SortedView() {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// PushedBetween returns copies of the queued items pushed from from until
+// before to, in the order they were queued, such as the items enqueued
+// during a bad deploy; Purge deletes them. It returns nil once the queue is
+// destroyed.
+func (pq *PriorityQueue) PushedBetween(from, to time.Time) []QItem {
+	defer pq.lock(OpExport)()
+	if pq.destroyed {
+		return nil
+	}
+	items := pq.collect(func(i *QItem) bool {
+		return !i.PushedAt.Before(from) && i.PushedAt.Before(to)
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].seq < items[j].seq })
+	view := make([]QItem, len(items))
+	for n, item := range items {
+		view[n] = *item
+	}
+	return view
+}
+
+// ByPriority implements sort.Interface over items, ordering them as the
+// queue pops them: highest priority first. Use sort.Stable to keep items of
+// equal priority in their current order.
+type ByPriority []QItem
+
+func (s ByPriority) Len() int           { return len(s) }
+func (s ByPriority) Less(i, j int) bool { return s[i].Priority > s[j].Priority }
+func (s ByPriority) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,111 @@
+package priorityqueue
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ForEach(t *testing.T) {
+	pq := NewPriorityQueue(WithChunkSize(3))
+	populateQueue(pq, 10)
+
+	seen := make(map[string]bool)
+	pq.ForEach(func(item QItem) bool {
+		seen[item.ID] = true
+		return true
+	})
+	assertEqual(t, len(seen), 10)
+
+	n := 0
+	pq.ForEach(func(QItem) bool {
+		n++
+		return n < 4
+	})
+	assertEqual(t, n, 4)
+}
+
+func Test_ForEachConcurrentChanges(t *testing.T) {
+	pq := NewPriorityQueue(WithChunkSize(2))
+	populateQueue(pq, 10)
+
+	visited := make(map[string]int)
+	pq.ForEach(func(item QItem) bool {
+		visited[item.ID]++
+		if len(visited) == 1 {
+			// Removed and pushed items are neither visited nor duplicated
+			pq.Clear()
+			populateQueue(pq, 10)
+		}
+		return true
+	})
+	assertEqual(t, len(visited), 2)
+	for id, n := range visited {
+		if n != 1 {
+			t.Errorf("Error, item %s visited %d times", id, n)
+		}
+	}
+}
+
+func Test_ForEachDestroyed(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Destroy()
+	if err := pq.ForEach(func(QItem) bool { return true }); err != ErrQueueDestroyed {
+		t.Errorf("Error iterating over a destroyed queue: %v", err)
+	}
+}
+
+func Test_SortedView(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 5)
+
+	view := pq.SortedView()
+	assertEqual(t, len(view), 5)
+	assertEqual(t, view[0].Priority, 5)
+	assertEqual(t, view[4].Priority, 1)
+	assertEqual(t, pq.Len(), 5)
+
+	var ids []string
+	for item := range pq.All() {
+		ids = append(ids, item.ID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	assertEqual(t, strings.Join(ids, ","), "4,3")
+
+	items := slices.Collect(pq.All())
+	slices.Reverse(items)
+	assertEqual(t, sort.IsSorted(ByPriority(items)), false)
+	sort.Stable(ByPriority(items))
+	assertEqual(t, slices.EqualFunc(items, view, func(a, b QItem) bool { return a.ID == b.ID }), true)
+
+	pq.Destroy()
+	assertEqual(t, pq.SortedView() == nil, true)
+}
+
+func Test_PushedBetween(t *testing.T) {
+	pq := NewPriorityQueue()
+	deploy := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for n, at := range []time.Duration{-time.Hour, 0, 10 * time.Minute, 30 * time.Minute, time.Hour} {
+		pq.Push(QItem{ID: strconv.Itoa(n), Priority: 5 - n, PushedAt: deploy.Add(at)})
+	}
+
+	items := pq.PushedBetween(deploy, deploy.Add(30*time.Minute))
+	assertEqual(t, len(items), 2)
+	assertEqual(t, items[0].ID, "1")
+	assertEqual(t, items[1].ID, "2")
+	assertEqual(t, items[0].Seq() < items[1].Seq(), true)
+
+	bad := make(map[string]bool)
+	for _, item := range items {
+		bad[item.ID] = true
+	}
+	n, _ := pq.DeleteWhere(func(item QItem) bool { return bad[item.ID] })
+	assertEqual(t, n, 2)
+	assertEqual(t, pq.Len(), 3)
+	assertEqual(t, QItem{}.Seq(), uint64(0))
+}
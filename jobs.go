@@ -0,0 +1,98 @@
+package priorityqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A JobID identifies a deletion started by DeleteItemsByParentIdAsync
+type JobID uint64
+
+// JobRetention is the number of finished jobs whose status JobStatus
+// still reports.
+const JobRetention = 100
+
+// JobStatus is the progress of an asynchronous deletion. Total is known once
+// the matching items are collected and removed from the queue's counts,
+// Deleted then grows chunk by chunk until the job is Done.
+type JobStatus struct {
+	ID       JobID
+	Op       Operation
+	ParentID string
+
+	Total   int // Items matched
+	Deleted int // Items removed from the heap so far
+	Done    bool
+	Err     error // Why the job failed, such as ErrFrozen or a denial
+
+	Started  time.Time
+	Finished time.Time // Zero until Done
+}
+
+// DeleteItemsByParentIdAsync starts deleting the items of parentID on a
+// goroutine and returns at once. Follow the deletion with JobStatus; Stop
+// waits for the jobs still running. As soon as the items are collected they
+// no longer count as queued and are never popped, even while their removal
+// from the heap is in progress. Once the queue is stopped the deletion runs
+// before returning instead.
+func (pq *PriorityQueue) DeleteItemsByParentIdAsync(parentID string) JobID {
+	return pq.DeleteItemsByParentIdAsyncCtx(context.Background(), parentID)
+}
+
+// DeleteItemsByParentIdAsyncCtx is DeleteItemsByParentIdAsync on behalf of
+// the principal carried by ctx. The job keeps the values of ctx but not its
+// cancelation, so it outlives the call that started it.
+func (pq *PriorityQueue) DeleteItemsByParentIdAsyncCtx(ctx context.Context, parentID string) JobID {
+	ctx = context.WithoutCancel(ctx)
+	pq.m.Lock()
+	pq.jobSeq++
+	job := &JobStatus{ID: pq.jobSeq, Op: OpDeleteItemsByParentId, ParentID: parentID, Started: pq.now()}
+	if pq.jobs == nil {
+		pq.jobs = make(map[JobID]*JobStatus)
+	}
+	pq.jobs[job.ID] = job
+
+	run := func() {
+		_, err := pq.deleteByParent(ctx, parentID, job)
+		pq.m.Lock()
+		defer pq.m.Unlock()
+		job.Err = err
+		job.Done = true
+		job.Finished = pq.now()
+		pq.jobsDone = append(pq.jobsDone, job.ID)
+		for len(pq.jobsDone) > JobRetention {
+			delete(pq.jobs, pq.jobsDone[0])
+			pq.jobsDone = pq.jobsDone[1:]
+		}
+	}
+	// A stopped queue may be waiting on bg already, which must not grow
+	// then
+	if pq.stopped {
+		pq.m.Unlock()
+		run()
+		return job.ID
+	}
+	// The job is not canceled by Stop: abandoning it would leave its
+	// tombstones in the heap
+	pq.bg.Add(1)
+	go func() {
+		defer pq.bg.Done()
+		run()
+	}()
+	pq.m.Unlock()
+	return job.ID
+}
+
+// JobStatus returns the progress of a job started by
+// DeleteItemsByParentIdAsync. It returns an error wrapping ErrNotFound for
+// an unknown job or one finished longer ago than the last JobRetention.
+func (pq *PriorityQueue) JobStatus(id JobID) (JobStatus, error) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	job, ok := pq.jobs[id]
+	if !ok {
+		return JobStatus{}, fmt.Errorf("%w: job %d", ErrNotFound, id)
+	}
+	return *job, nil
+}
@@ -0,0 +1,89 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func Test_DeleteItemsByParentIdAsync(t *testing.T) {
+	pq := NewPriorityQueue(WithChunkSize(10))
+	for n := 0; n < 100; n++ {
+		pq.Push(QItem{ID: strconv.Itoa(n), ParentID: "purge", Priority: n})
+	}
+	pq.Push(QItem{ID: "keep", ParentID: "keep"})
+
+	id := pq.DeleteItemsByParentIdAsync("purge")
+	assertEqual(t, pq.Stop(context.Background()), nil)
+
+	s, err := pq.JobStatus(id)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.ID, id)
+	assertEqual(t, s.ParentID, "purge")
+	assertEqual(t, s.Done, true)
+	assertEqual(t, s.Err, nil)
+	assertEqual(t, s.Total, 100)
+	assertEqual(t, s.Deleted, 100)
+	assertEqual(t, s.Finished.IsZero(), false)
+	assertEqual(t, pq.Len(), 1)
+	assertEqual(t, pq.Healthy(), nil)
+
+	_, err = pq.JobStatus(id + 1)
+	assertEqual(t, errors.Is(err, ErrNotFound), true)
+}
+
+func Test_DeleteJobFailure(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	pq.Freeze(FreezeReject)
+
+	id := pq.DeleteItemsByParentIdAsync("12345")
+	pq.Stop(context.Background())
+	s, _ := pq.JobStatus(id)
+	assertEqual(t, s.Done, true)
+	assertEqual(t, s.Err, ErrFrozen)
+	assertEqual(t, s.Deleted, 0)
+	assertEqual(t, pq.Len(), 3)
+}
+
+func Test_JobRetention(t *testing.T) {
+	pq := NewPriorityQueue()
+	first := pq.DeleteItemsByParentIdAsync("none")
+	pq.Stop(context.Background())
+	for n := 0; n < JobRetention; n++ {
+		pq.DeleteItemsByParentIdAsync("none")
+	}
+	pq.Stop(context.Background())
+	_, err := pq.JobStatus(first)
+	assertEqual(t, errors.Is(err, ErrNotFound), true)
+	_, err = pq.JobStatus(first + 1)
+	assertEqual(t, err, nil)
+}
+
+func Test_DeleteJobPrincipal(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 2)
+	var seen []interface{}
+	pq.SetAuthorizer(func(principal interface{}, op Operation, item *QItem) error {
+		seen = append(seen, principal)
+		return nil
+	})
+
+	// The job outlives the request that started it
+	ctx, cancel := context.WithCancel(WithPrincipal(context.Background(), "ops"))
+	id := pq.DeleteItemsByParentIdAsyncCtx(ctx, "12345")
+	cancel()
+	pq.Stop(context.Background())
+	s, _ := pq.JobStatus(id)
+	assertEqual(t, s.Err, nil)
+	assertEqual(t, s.Deleted, 2)
+	assertEqual(t, len(seen), 2)
+	assertEqual(t, seen[0], interface{}("ops"))
+
+	// Once stopped the job runs before returning
+	pq.Push(QItem{ID: "late", ParentID: "late"})
+	s, _ = pq.JobStatus(pq.DeleteItemsByParentIdAsync("late"))
+	assertEqual(t, s.Done, true)
+	assertEqual(t, pq.Len(), 0)
+}
@@ -0,0 +1,82 @@
+package priorityqueue
+
+import "context"
+
+// A tail is a subscriber to the journal of the queue, see Tail
+type tail struct {
+	ch chan AuditEntry
+}
+
+// Tail streams the journal of the queue: an audit entry for every change
+// made from now on, as SetAuditLog receives them, in the order the changes
+// were made. The channel is closed once ctx is done or the queue destroyed,
+// or early if the subscriber falls more than buffer entries behind, so that
+// it never misses entries unknowingly: it can tell it was cut off by ctx
+// not being done, and resynchronize. Tailing does not need an audit log.
+func (pq *PriorityQueue) Tail(ctx context.Context, buffer int) <-chan AuditEntry {
+	t := &tail{ch: make(chan AuditEntry, buffer)}
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	if pq.destroyed {
+		close(t.ch)
+		return t.ch
+	}
+	if pq.tails == nil {
+		pq.tails = make(map[*tail]struct{})
+	}
+	pq.tails[t] = struct{}{}
+	go func() {
+		<-ctx.Done()
+		pq.m.Lock()
+		defer pq.m.Unlock()
+		pq.untail(t)
+	}()
+	return t.ch
+}
+
+// Position returns the number of changes made to the items of the queue so
+// far, each one journaled with the position it brought the queue to. A
+// follower replicating the journal is caught up with a write once it has
+// applied the entry of that position, see AuditEntry.Position.
+func (pq *PriorityQueue) Position() uint64 {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	return pq.position
+}
+
+// untail closes the channel of t unless it was closed already. The queue
+// lock must be held.
+func (pq *PriorityQueue) untail(t *tail) {
+	if _, ok := pq.tails[t]; ok {
+		delete(pq.tails, t)
+		close(t.ch)
+	}
+}
+
+// auditing reports whether changes are audited, for the audit log or the
+// journal. The queue lock must be held.
+func (pq *PriorityQueue) auditing() bool {
+	return pq.auditLog != nil || len(pq.tails) > 0
+}
+
+// journal sends the entries of an operation to the tails, cutting off the
+// ones whose buffer is full. Sending with the lock held keeps the entries
+// in order. The queue lock must be held.
+func (pq *PriorityQueue) journal(entries []AuditEntry) {
+	for t := range pq.tails {
+		for _, e := range entries {
+			select {
+			case t.ch <- e:
+				continue
+			default:
+			}
+			pq.untail(t)
+			break
+		}
+	}
+	if pq.destroyed {
+		for t := range pq.tails {
+			pq.untail(t)
+		}
+	}
+}
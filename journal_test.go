@@ -0,0 +1,57 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Tail(t *testing.T) {
+	pq := NewPriorityQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	events := pq.Tail(ctx, 10)
+	populateQueue(pq, 2)
+	pq.Pop()
+
+	for _, op := range []Operation{OpPush, OpPush, OpPop} {
+		e := <-events
+		assertEqual(t, e.Op, op)
+	}
+	assertEqual(t, pq.Capabilities().Audit, false)
+
+	cancel()
+	for range events {
+	}
+	populateQueue(pq, 1)
+}
+
+func Test_TailCutOff(t *testing.T) {
+	pq := NewPriorityQueue()
+	slow := pq.Tail(context.Background(), 1)
+	fast := pq.Tail(context.Background(), 10)
+	populateQueue(pq, 2)
+
+	<-slow
+	if _, ok := <-slow; ok {
+		t.Errorf("Error, the slow subscriber was not cut off")
+	}
+	assertEqual(t, len(fast), 2)
+
+	pq.Destroy()
+	for range fast {
+	}
+	if _, ok := <-pq.Tail(context.Background(), 1); ok {
+		t.Errorf("Error, tailing a destroyed queue")
+	}
+}
+
+func Test_TailPositions(t *testing.T) {
+	pq := NewPriorityQueue()
+	events := pq.Tail(context.Background(), 10)
+	populateQueue(pq, 2)
+	pq.Pop()
+
+	for want := uint64(1); want <= 3; want++ {
+		assertEqual(t, (<-events).Position, want)
+	}
+	assertEqual(t, pq.Position(), uint64(3))
+}
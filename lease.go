@@ -0,0 +1,416 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidReceipt is wrapped by the errors returned when a receipt does not
+// match an item in flight, because it was acked already or its lease expired.
+var ErrInvalidReceipt = errors.New("receipt does not match a leased item")
+
+// A Receipt identifies one lease of an item, see Lease
+type Receipt struct {
+	ID  string // ID of the leased item
+	seq uint64
+}
+
+type lease struct {
+	item     QItem
+	deadline time.Time
+	timeout  time.Duration
+	worker   string
+	leased   time.Time
+}
+
+// A DeadLetter is an item taken out of circulation by DeadLetter, or after
+// exhausting the attempts set by SetMaxAttempts.
+type DeadLetter struct {
+	Item   QItem
+	Reason string
+	At     time.Time
+}
+
+// Lease pops the highest priority item on the condition that it is
+// acknowledged with Ack before timeout elapses. An item that is neither
+// acked nor nacked in time is queued again. The item's Attempts counts its
+// leases, including this one.
+func (pq *PriorityQueue) Lease(timeout time.Duration) (*QItem, Receipt, error) {
+	return pq.LeaseAs("", timeout)
+}
+
+// LeaseAs is Lease on behalf of worker, which History records
+func (pq *PriorityQueue) LeaseAs(worker string, timeout time.Duration) (*QItem, Receipt, error) {
+	defer pq.lock(OpLease)()
+	if pq.chaosEmpty() {
+		return nil, Receipt{}, ErrEmptyQueue
+	}
+	return pq.lease(OpLease, worker, timeout)
+}
+
+// lease leases the highest priority item to worker, until Ack if timeout
+// is zero. The queue lock must be held.
+func (pq *PriorityQueue) lease(op Operation, worker string, timeout time.Duration) (*QItem, Receipt, error) {
+	if err := pq.mutable(); err != nil {
+		return nil, Receipt{}, err
+	}
+	if pq.inFlightFull() {
+		return nil, Receipt{}, ErrTooManyInFlight
+	}
+	pq.dropDuplicates()
+	n := pq.next()
+	if n == -1 {
+		return nil, Receipt{}, ErrEmptyQueue
+	}
+	if pq.frozen(pq.data[n]) {
+		return nil, Receipt{}, ErrFrozen
+	}
+	item := pq.remove(n, StateInFlight)
+	item.Attempts++
+	pq.attempt(item, worker)
+	pq.audit(op, item)
+
+	if pq.leases == nil {
+		pq.leases = make(map[uint64]*lease)
+	}
+	pq.leaseSeq++
+	l := &lease{item: *item, timeout: timeout, worker: worker, leased: pq.now()}
+	if timeout > 0 {
+		l.deadline = pq.now().Add(timeout)
+		heap.Push(&pq.leaseTimers, timer[uint64]{at: l.deadline, v: pq.leaseSeq})
+	}
+	pq.leases[pq.leaseSeq] = l
+	pq.record(recorded{Op: op, Lease: pq.leaseSeq, Duration: timeout, Worker: worker})
+
+	c := *item
+	out, err := pq.untransform(&c)
+	return out, Receipt{ID: item.ID, seq: pq.leaseSeq}, err
+}
+
+// takeLease ends the lease identified by r. The queue lock must be held.
+func (pq *PriorityQueue) takeLease(r Receipt) (*lease, error) {
+	l, ok := pq.leases[r.seq]
+	if !ok || l.item.ID != r.ID {
+		return nil, fmt.Errorf("%w: [%s]", ErrInvalidReceipt, r.ID)
+	}
+	delete(pq.leases, r.seq)
+	pq.meterLease(l, pq.now())
+	if pq.maxInFlight > 0 {
+		pq.wake() // LeaseWait may go on
+	}
+	return l, nil
+}
+
+// takeLeases ends the leases identified by receipts, all of them or none if
+// any receipt is invalid. The queue lock must be held.
+func (pq *PriorityQueue) takeLeases(receipts []Receipt) ([]*lease, error) {
+	seen := make(map[uint64]bool, len(receipts))
+	for _, r := range receipts {
+		l, ok := pq.leases[r.seq]
+		if !ok || l.item.ID != r.ID || seen[r.seq] {
+			return nil, fmt.Errorf("%w: [%s]", ErrInvalidReceipt, r.ID)
+		}
+		seen[r.seq] = true
+	}
+	leases := make([]*lease, len(receipts))
+	for n, r := range receipts {
+		leases[n], _ = pq.takeLease(r)
+	}
+	return leases, nil
+}
+
+// Ack acknowledges a leased item, which is then done with
+func (pq *PriorityQueue) Ack(r Receipt) error {
+	return pq.AckBatch([]Receipt{r})
+}
+
+// AckBatch acknowledges several leased items at once. Either all of them are
+// acked or, if any receipt is invalid, none is.
+func (pq *PriorityQueue) AckBatch(receipts []Receipt) error {
+	defer pq.lock(OpAck)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	if pq.chaosDropAck() {
+		return nil
+	}
+	pq.record(recorded{Op: OpAck, Leases: leaseSeqs(receipts)})
+	leases, err := pq.takeLeases(receipts)
+	if err != nil {
+		return err
+	}
+	for _, l := range leases {
+		pq.transition(&l.item, StateAcked)
+		pq.complete(&l.item)
+		pq.audit(OpAck, &l.item)
+	}
+	return nil
+}
+
+// Nack returns a leased item to the queue, after delay if it is positive.
+// The item is dead-lettered instead once it has used up its attempts.
+func (pq *PriorityQueue) Nack(r Receipt, delay time.Duration) error {
+	return pq.NackBatch([]Receipt{r}, delay)
+}
+
+// NackError is Nack recording the error that failed the attempt, which
+// History reports and the dead letter gives as its reason.
+func (pq *PriorityQueue) NackError(r Receipt, delay time.Duration, err error) error {
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	return pq.nack([]Receipt{r}, delay, reason)
+}
+
+// NackBatch returns several leased items to the queue at once, as Nack does.
+// Either all of them are nacked or, if any receipt is invalid, none is.
+func (pq *PriorityQueue) NackBatch(receipts []Receipt, delay time.Duration) error {
+	return pq.nack(receipts, delay, "")
+}
+
+// nack is NackBatch failing the attempts because of reason, "nacked" if
+// empty
+func (pq *PriorityQueue) nack(receipts []Receipt, delay time.Duration, reason string) error {
+	defer pq.lock(OpNack)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpNack, Leases: leaseSeqs(receipts), Duration: delay, Reason: reason})
+	leases, err := pq.takeLeases(receipts)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		reason = "nacked"
+	}
+	now := pq.now()
+	for _, l := range leases {
+		pq.audit(OpNack, &l.item)
+		pq.endAttempt(&l.item, reason, now)
+		pq.redeliver(l.item, delay, reason, now)
+	}
+	return nil
+}
+
+// ExtendLease pushes back the deadline of a lease by extra, so a consumer
+// working on a long job can keep the item from being redelivered. A lease
+// that already expired cannot be extended.
+func (pq *PriorityQueue) ExtendLease(r Receipt, extra time.Duration) error {
+	defer pq.lock(OpExtendLease)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpExtendLease, Lease: r.seq, Duration: extra})
+	l, ok := pq.leases[r.seq]
+	if !ok || l.item.ID != r.ID {
+		return fmt.Errorf("%w: [%s]", ErrInvalidReceipt, r.ID)
+	}
+	if l.deadline.IsZero() {
+		return nil // a reservation, which does not expire
+	}
+	l.deadline = l.deadline.Add(extra)
+	heap.Push(&pq.leaseTimers, timer[uint64]{at: l.deadline, v: r.seq})
+	return nil
+}
+
+// DeadLetter moves a leased item to the dead letters, recording reason
+func (pq *PriorityQueue) DeadLetter(r Receipt, reason string) error {
+	defer pq.lock(OpDeadLetter)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpDeadLetter, Lease: r.seq, Reason: reason})
+	l, err := pq.takeLease(r)
+	if err != nil {
+		return err
+	}
+	pq.deadLetter(l.item, reason, pq.now())
+	return nil
+}
+
+// claimOf returns the sequence number of the oldest lease of the item with
+// the given ID. The queue lock must be held.
+func (pq *PriorityQueue) claimOf(id string) (uint64, error) {
+	var oldest uint64
+	for seq, l := range pq.leases {
+		if pq.isKey(&l.item, id) && (oldest == 0 || seq < oldest) {
+			oldest = seq
+		}
+	}
+	if oldest == 0 {
+		return 0, fmt.Errorf("%w: [%s]", ErrNotFound, id)
+	}
+	return oldest, nil
+}
+
+// ForceRelease revokes the lease or reservation of the item in flight with
+// the given ID, such as one held by a worker known to be dead, and queues
+// the item again as if its lease had expired. The receipt of the revoked
+// lease becomes invalid.
+func (pq *PriorityQueue) ForceRelease(id string) error {
+	defer pq.lock(OpForceRelease)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpForceRelease, ID: id})
+	seq, err := pq.claimOf(id)
+	if err != nil {
+		return err
+	}
+	if err := pq.authorize(context.Background(), OpForceRelease, &pq.leases[seq].item); err != nil {
+		return err
+	}
+	l, _ := pq.takeLease(Receipt{ID: pq.leases[seq].item.ID, seq: seq})
+	now := pq.now()
+	pq.audit(OpForceRelease, &l.item)
+	pq.endAttempt(&l.item, "force released", now)
+	pq.redeliver(l.item, 0, "force released", now)
+	return nil
+}
+
+// Steal revokes the lease or reservation of the item in flight with the
+// given ID and leases the item to newWorker instead, for as long as the
+// revoked lease was taken. The receipt of the revoked lease becomes invalid;
+// the item and the receipt of the new lease are returned.
+func (pq *PriorityQueue) Steal(id, newWorker string) (*QItem, Receipt, error) {
+	defer pq.lock(OpSteal)()
+	if err := pq.mutable(); err != nil {
+		return nil, Receipt{}, err
+	}
+	pq.leaseSeq++
+	pq.record(recorded{Op: OpSteal, ID: id, Worker: newWorker, Lease: pq.leaseSeq})
+	seq, err := pq.claimOf(id)
+	if err != nil {
+		return nil, Receipt{}, err
+	}
+	if err := pq.authorize(context.Background(), OpSteal, &pq.leases[seq].item); err != nil {
+		return nil, Receipt{}, err
+	}
+	old, _ := pq.takeLease(Receipt{ID: pq.leases[seq].item.ID, seq: seq})
+	now := pq.now()
+	pq.endAttempt(&old.item, "stolen by "+newWorker, now)
+
+	l := &lease{item: old.item, timeout: old.timeout, worker: newWorker, leased: now}
+	l.item.Attempts++
+	pq.attempt(&l.item, newWorker)
+	if l.timeout > 0 {
+		l.deadline = now.Add(l.timeout)
+		heap.Push(&pq.leaseTimers, timer[uint64]{at: l.deadline, v: pq.leaseSeq})
+	}
+	pq.leases[pq.leaseSeq] = l
+	pq.audit(OpSteal, &l.item)
+
+	c := l.item
+	return &c, Receipt{ID: l.item.ID, seq: pq.leaseSeq}, nil
+}
+
+// A RedeliveryBoost raises the priority of items queued again after a Nack or
+// an expired lease, so items that keep failing are retried sooner.
+type RedeliveryBoost struct {
+	Step int `json:"step,omitempty"` // Added to the priority at every redelivery
+	Max  int `json:"max,omitempty"`  // Cap on the total boost of an item, zero for none
+}
+
+// boost returns the priority adjustment for the redelivery of an item
+// leased attempts times.
+func (b RedeliveryBoost) boost(attempts int) int {
+	total := func(n int) int {
+		if b.Max > 0 && b.Step*n > b.Max {
+			return b.Max
+		}
+		return b.Step * n
+	}
+	return total(attempts) - total(attempts-1)
+}
+
+// SetRedeliveryBoost sets the priority boost of redelivered items. The zero
+// RedeliveryBoost, the default, leaves priorities alone.
+func (pq *PriorityQueue) SetRedeliveryBoost(b RedeliveryBoost) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.redeliveryBoost = b
+}
+
+// redeliver queues an item whose lease ended without an Ack. The queue lock
+// must be held.
+func (pq *PriorityQueue) redeliver(i QItem, delay time.Duration, reason string, now time.Time) {
+	i.Priority += pq.redeliveryBoost.boost(i.Attempts)
+	switch {
+	case expired(&i, now):
+		pq.transition(&i, StateExpired)
+		pq.audit(OpExpire, &i)
+	case pq.maxAttempts > 0 && i.Attempts >= pq.maxAttempts:
+		pq.deadLetter(i, fmt.Sprintf("%s after %d attempts", reason, i.Attempts), now)
+	case delay > 0:
+		pq.delay(i, now.Add(delay))
+	default:
+		pq.insert(i)
+	}
+}
+
+// deadLetter records a dead letter. The queue lock must be held.
+func (pq *PriorityQueue) deadLetter(i QItem, reason string, now time.Time) {
+	pq.transition(&i, StateDeadLettered)
+	delete(pq.history, pq.itemKey(&i))
+	pq.deadLetters = append(pq.deadLetters, DeadLetter{Item: i, Reason: reason, At: now})
+	pq.audit(OpDeadLetter, &i)
+	pq.post(WebhookPayload{Event: EventDeadLettered, Item: &i, Reason: reason})
+}
+
+// SetMaxAttempts sets the number of leases after which an item that still
+// is not acked is dead-lettered rather than queued again. Zero, the default,
+// retries forever.
+func (pq *PriorityQueue) SetMaxAttempts(n int) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.maxAttempts = n
+}
+
+// DeadLetters returns copies of the dead letters, oldest first
+func (pq *PriorityQueue) DeadLetters() []DeadLetter {
+	defer pq.lock(OpStats)()
+	return append([]DeadLetter(nil), pq.deadLetters...)
+}
+
+// RedriveDeadLetters moves the dead letters matching filter, or all of them
+// if filter is nil, back into the queue with their Attempts reset. If
+// priorityOverride is not nil the items are queued with that priority. It
+// returns the number of items queued, none if the Authorizer denies the
+// redrive of any of them.
+func (pq *PriorityQueue) RedriveDeadLetters(filter func(*QItem) bool, priorityOverride *int) int {
+	defer pq.lock(OpRedrive)()
+	if pq.authorizer != nil {
+		var items []*QItem
+		for n := range pq.deadLetters {
+			if filter == nil || filter(&pq.deadLetters[n].Item) {
+				items = append(items, &pq.deadLetters[n].Item)
+			}
+		}
+		if pq.authorize(context.Background(), OpRedrive, items...) != nil {
+			return 0
+		}
+	}
+	kept := pq.deadLetters[:0]
+	n := 0
+	for _, d := range pq.deadLetters {
+		if filter != nil && !filter(&d.Item) {
+			kept = append(kept, d)
+			continue
+		}
+		d.Item.Attempts = 0
+		if priorityOverride != nil {
+			d.Item.Priority = *priorityOverride
+		}
+		pq.audit(OpRedrive, pq.insert(d.Item))
+		n++
+	}
+	for i := len(kept); i < len(pq.deadLetters); i++ {
+		pq.deadLetters[i] = DeadLetter{}
+	}
+	pq.deadLetters = kept
+	return n
+}
@@ -0,0 +1,259 @@
+package priorityqueue
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_LeaseAck(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+
+	item, r, err := pq.Lease(time.Minute)
+	if err != nil {
+		t.Errorf("Error leasing item: %v", err)
+		return
+	}
+	assertEqual(t, item.ID, "2")
+	assertEqual(t, item.Attempts, 1)
+	assertEqual(t, pq.State("2"), StateInFlight)
+	assertEqual(t, pq.Len(), 2)
+
+	if err := pq.Ack(r); err != nil {
+		t.Errorf("Error acking item: %v", err)
+	}
+	assertEqual(t, pq.State("2"), StateAcked)
+	if err := pq.Ack(r); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("Error acking item twice: %v", err)
+	}
+}
+
+func Test_LeaseEmpty(t *testing.T) {
+	pq := NewPriorityQueue()
+	if _, _, err := pq.Lease(time.Minute); err != ErrEmptyQueue {
+		t.Errorf("Error leasing from an empty queue: %v", err)
+	}
+}
+
+func Test_Nack(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	populateQueue(pq, 3)
+
+	_, r, _ := pq.Lease(time.Minute)
+	pq.Nack(r, 0)
+	assertEqual(t, pq.State("2"), StateQueued)
+
+	item, r, _ := pq.Lease(time.Minute)
+	assertEqual(t, item.ID, "2")
+	assertEqual(t, item.Attempts, 2)
+	pq.Nack(r, time.Second)
+	assertEqual(t, pq.State("2"), StateDelayed)
+	peeked, _ := pq.Peek()
+	assertEqual(t, peeked.ID, "1")
+
+	advance(time.Second)
+	peeked, _ = pq.Peek()
+	assertEqual(t, peeked.ID, "2")
+}
+
+func Test_LeaseExpires(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	populateQueue(pq, 1)
+
+	_, r, _ := pq.Lease(time.Minute)
+	advance(time.Minute)
+	assertEqual(t, pq.State("0"), StateQueued)
+	if err := pq.Ack(r); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("Error acking an expired lease: %v", err)
+	}
+	item, _, _ := pq.Lease(time.Minute)
+	assertEqual(t, item.Attempts, 2)
+}
+
+func Test_MaxAttempts(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetMaxAttempts(2)
+	populateQueue(pq, 1)
+
+	_, r, _ := pq.Lease(time.Minute)
+	pq.Nack(r, 0)
+	_, r, _ = pq.Lease(time.Minute)
+	pq.Nack(r, 0)
+
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, pq.State("0"), StateDeadLettered)
+	dead := pq.DeadLetters()
+	assertEqual(t, len(dead), 1)
+	assertEqual(t, dead[0].Reason, "nacked after 2 attempts")
+}
+
+func Test_DeadLetter(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 2)
+
+	_, r, _ := pq.Lease(time.Minute)
+	if err := pq.DeadLetter(r, "poison"); err != nil {
+		t.Errorf("Error dead-lettering item: %v", err)
+	}
+	dead := pq.DeadLetters()
+	assertEqual(t, len(dead), 1)
+	assertEqual(t, dead[0].Item.ID, "1")
+	assertEqual(t, dead[0].Reason, "poison")
+	assertEqual(t, pq.StateCounts()[StateDeadLettered], 1)
+}
+
+func Test_RedriveDeadLetters(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	for i := 0; i < 3; i++ {
+		_, r, _ := pq.Lease(time.Minute)
+		pq.DeadLetter(r, "outage")
+	}
+
+	priority := 100
+	n := pq.RedriveDeadLetters(func(item *QItem) bool {
+		return item.ID != "0"
+	}, &priority)
+	assertEqual(t, n, 2)
+	assertEqual(t, pq.Len(), 2)
+	assertEqual(t, len(pq.DeadLetters()), 1)
+	assertEqual(t, pq.State("1"), StateQueued)
+
+	item, _ := pq.Pop()
+	assertEqual(t, item.Priority, 100)
+	assertEqual(t, item.Attempts, 0)
+
+	assertEqual(t, pq.RedriveDeadLetters(nil, nil), 1)
+	item, _ = pq.Peek()
+	assertEqual(t, item.Priority, 100)
+	assertEqual(t, pq.Len(), 2)
+}
+
+func Test_AckBatch(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 5)
+	var receipts []Receipt
+	for i := 0; i < 3; i++ {
+		_, r, _ := pq.Lease(time.Minute)
+		receipts = append(receipts, r)
+	}
+
+	if err := pq.AckBatch(append(receipts, Receipt{ID: "bogus"})); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("Error acking a batch with an invalid receipt: %v", err)
+	}
+	assertEqual(t, pq.StateCounts()[StateInFlight], 3)
+
+	if err := pq.AckBatch(receipts); err != nil {
+		t.Errorf("Error acking batch: %v", err)
+	}
+	counts := pq.StateCounts()
+	assertEqual(t, counts[StateInFlight], 0)
+	assertEqual(t, counts[StateAcked], 3)
+}
+
+func Test_NackBatch(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 5)
+	_, r1, _ := pq.Lease(time.Minute)
+	_, r2, _ := pq.Lease(time.Minute)
+
+	if err := pq.NackBatch([]Receipt{r1, r1}, 0); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("Error nacking a receipt twice in a batch: %v", err)
+	}
+	if err := pq.NackBatch([]Receipt{r1, r2}, 0); err != nil {
+		t.Errorf("Error nacking batch: %v", err)
+	}
+	assertEqual(t, pq.Len(), 5)
+}
+
+func Test_RedeliveryBoost(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetRedeliveryBoost(RedeliveryBoost{Step: 10, Max: 25})
+	pq.Push(QItem{ID: "1", Priority: 1})
+
+	var priorities []int
+	for i := 0; i < 4; i++ {
+		_, r, _ := pq.Lease(time.Minute)
+		pq.Nack(r, 0)
+		item, _ := pq.Peek()
+		priorities = append(priorities, item.Priority)
+	}
+	assertEqual(t, fmt.Sprint(priorities), "[11 21 26 26]")
+}
+
+func Test_ExtendLease(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	populateQueue(pq, 1)
+
+	_, r, _ := pq.Lease(time.Minute)
+	advance(50 * time.Second)
+	if err := pq.ExtendLease(r, time.Minute); err != nil {
+		t.Errorf("Error extending lease: %v", err)
+	}
+	advance(50 * time.Second)
+	assertEqual(t, pq.State("0"), StateInFlight)
+
+	advance(20 * time.Second)
+	assertEqual(t, pq.State("0"), StateQueued)
+	if err := pq.ExtendLease(r, time.Minute); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("Error extending an expired lease: %v", err)
+	}
+}
+
+func Test_ForceRelease(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 2)
+	x, r, _ := pq.LeaseAs("dead-pod", time.Hour)
+
+	if err := pq.ForceRelease("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Force releasing an item not in flight returned %v", err)
+	}
+	if err := pq.ForceRelease(x.ID); err != nil {
+		t.Fatalf("Error force releasing: %v", err)
+	}
+	assertEqual(t, pq.Len(), 2)
+	assertEqual(t, len(pq.InFlight()), 0)
+	assertEqual(t, errors.Is(pq.Ack(r), ErrInvalidReceipt), true)
+	assertEqual(t, pq.History(x.ID)[0].Error, "force released")
+
+	y, _, _ := pq.Lease(time.Hour)
+	assertEqual(t, y.ID, x.ID)
+	assertEqual(t, y.Attempts, 2)
+}
+
+func Test_Steal(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pq, _ := New(WithClock(func() time.Time { return now }))
+	populateQueue(pq, 2)
+	x, r, _ := pq.LeaseAs("dead-pod", time.Minute)
+
+	if _, _, err := pq.Steal("nope", "pod-b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stealing an item not in flight returned %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	y, r2, err := pq.Steal(x.ID, "pod-b")
+	if err != nil {
+		t.Fatalf("Error stealing: %v", err)
+	}
+	assertEqual(t, y.ID, x.ID)
+	assertEqual(t, y.Attempts, 2)
+	assertEqual(t, errors.Is(pq.Ack(r), ErrInvalidReceipt), true)
+
+	claims := pq.InFlight()
+	assertEqual(t, len(claims), 1)
+	assertEqual(t, claims[0].Worker, "pod-b")
+	assertEqual(t, claims[0].Deadline, now.Add(time.Minute))
+	assertEqual(t, pq.History(x.ID)[0].Error, "stolen by pod-b")
+
+	// The new lease expires on its own deadline
+	now = now.Add(45 * time.Second)
+	assertEqual(t, pq.Len(), 1)
+	if err := pq.Ack(r2); err != nil {
+		t.Errorf("Error acking the stolen item: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package priorityqueue
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownLevel is wrapped by the errors of pushes naming a Level that is
+// not registered.
+var ErrUnknownLevel = errors.New("unknown priority level")
+
+// A PriorityLevel names a priority, so configurations and dashboards can
+// speak of "High" rather than of 300.
+type PriorityLevel struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+}
+
+// DefaultPriorityLevels is a conventional set of levels for SetPriorityLevels
+var DefaultPriorityLevels = []PriorityLevel{
+	{"Critical", 400},
+	{"High", 300},
+	{"Normal", 200},
+	{"Low", 100},
+	{"Bulk", 0},
+}
+
+// SetPriorityLevels replaces the registered priority levels. Items can then
+// be pushed with a Level rather than a Priority, Stats counts the queued
+// items by level and ExportNDJSON reports the level of each item. A level
+// covers the priorities from its own up to that of the next level, the
+// highest level every priority above it. Names are case insensitive; no
+// two levels may share a name or a priority. No levels, the default,
+// disables the names.
+func (pq *PriorityQueue) SetPriorityLevels(levels ...PriorityLevel) error {
+	sorted := append([]PriorityLevel(nil), levels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Priority > sorted[j].Priority })
+	names := make(map[string]bool, len(sorted))
+	for n, l := range sorted {
+		switch key := strings.ToLower(l.Name); {
+		case key == "":
+			return fmt.Errorf("priority level %d has no name", l.Priority)
+		case names[key]:
+			return fmt.Errorf("priority level %q registered twice", l.Name)
+		case n > 0 && sorted[n-1].Priority == l.Priority:
+			return fmt.Errorf("priority levels %q and %q share priority %d", sorted[n-1].Name, l.Name, l.Priority)
+		default:
+			names[key] = true
+		}
+	}
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.levels = sorted
+	return nil
+}
+
+// PriorityLevels returns the registered levels, highest first
+func (pq *PriorityQueue) PriorityLevels() []PriorityLevel {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	return append([]PriorityLevel(nil), pq.levels...)
+}
+
+// LevelOf returns the name of the level covering priority, the empty
+// string if it is below every level.
+func (pq *PriorityQueue) LevelOf(priority int) string {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	return pq.levelOf(priority)
+}
+
+// levelOf is LevelOf; the queue lock must be held
+func (pq *PriorityQueue) levelOf(priority int) string {
+	n := sort.Search(len(pq.levels), func(n int) bool { return pq.levels[n].Priority <= priority })
+	if n == len(pq.levels) {
+		return ""
+	}
+	return pq.levels[n].Name
+}
+
+// resolveLevel sets the priority of an item pushed with a Level. The queue
+// lock must be held.
+func (pq *PriorityQueue) resolveLevel(i *QItem) error {
+	if i.Level == "" {
+		return nil
+	}
+	for _, l := range pq.levels {
+		if strings.EqualFold(l.Name, i.Level) {
+			i.Priority, i.Level = l.Priority, ""
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownLevel, i.Level)
+}
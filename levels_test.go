@@ -0,0 +1,54 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func Test_PriorityLevels(t *testing.T) {
+	pq := NewPriorityQueue()
+	if err := pq.SetPriorityLevels(DefaultPriorityLevels...); err != nil {
+		t.Fatal(err)
+	}
+	pq.Push(QItem{ID: "1", Level: "low", Priority: 999})
+	pq.Push(QItem{ID: "2", Level: "Critical"})
+	pq.Push(QItem{ID: "3", Priority: 250})
+	pq.Push(QItem{ID: "4", Priority: -5})
+
+	err := pq.Push(QItem{ID: "5", Level: "Urgent"})
+	assertEqual(t, errors.Is(err, ErrUnknownLevel), true)
+
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "2")
+	assertEqual(t, item.Priority, 400)
+	assertEqual(t, item.Level, "")
+
+	assertEqual(t, pq.LevelOf(250), "Normal")
+	assertEqual(t, pq.LevelOf(1000), "Critical")
+	assertEqual(t, pq.LevelOf(-1), "")
+
+	s := pq.Stats()
+	assertEqual(t, s.ByLevel["Critical"], 1)
+	assertEqual(t, s.ByLevel["Normal"], 1)
+	assertEqual(t, s.ByLevel["Low"], 1)
+	assertEqual(t, s.ByLevel[""], 1)
+
+	var buf bytes.Buffer
+	if err := pq.ExportNDJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var rec itemRecord
+	json.NewDecoder(&buf).Decode(&rec)
+	assertEqual(t, rec.Level, "Critical")
+}
+
+func Test_PriorityLevelsInvalid(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, pq.SetPriorityLevels(PriorityLevel{"High", 1}, PriorityLevel{"high", 2}) != nil, true)
+	assertEqual(t, pq.SetPriorityLevels(PriorityLevel{"A", 1}, PriorityLevel{"B", 1}) != nil, true)
+	assertEqual(t, pq.SetPriorityLevels(PriorityLevel{"", 1}) != nil, true)
+	assertEqual(t, len(pq.PriorityLevels()), 0)
+	assertEqual(t, pq.Stats().ByLevel == nil, true)
+}
@@ -0,0 +1,328 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"time"
+)
+
+// A State is a step in the lifecycle of an item
+type State int
+
+const (
+	StateUnknown      State = iota // No record of the ID, or the record was retired
+	StateQueued                    // Waiting in the queue
+	StateDelayed                   // Pushed with PushDelayed or nacked with a delay, not due yet
+	StateInFlight                  // Leased to a consumer that has not acked it yet
+	StateAcked                     // Acknowledged by the consumer that leased it
+	StateDeadLettered              // Moved to the dead letters, see DeadLetters
+	StateExpired                   // Dropped once its ExpiresAt passed
+	StatePopped                    // Handed out by Pop, which takes no acknowledgement
+	StateDeleted                   // Removed by Clear or one of the delete methods
+
+	numStates
+)
+
+var stateNames = [numStates]string{
+	"unknown", "queued", "delayed", "in-flight", "acked", "dead-lettered", "expired", "popped", "deleted",
+}
+
+func (s State) String() string {
+	if s < 0 || s >= numStates {
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+// Terminal reports whether the queue is done with an item in state s
+func (s State) Terminal() bool {
+	switch s {
+	case StateAcked, StateExpired, StatePopped, StateDeleted:
+		return true
+	}
+	return false
+}
+
+// transitions lists the states each state may move to. An item in a
+// terminal state is no longer held by the queue; pushing it again starts
+// a new lifecycle.
+var transitions = [numStates][]State{
+	StateUnknown:      {StateQueued, StateDelayed},
+	StateQueued:       {StateInFlight, StatePopped, StateDeleted, StateExpired, StateDeadLettered},
+	StateDelayed:      {StateQueued, StateExpired, StateDeleted},
+	StateInFlight:     {StateAcked, StateQueued, StateDelayed, StateDeadLettered, StateExpired, StateDeleted},
+	StateDeadLettered: {StateQueued, StateDeleted},
+	StateAcked:        {StateQueued, StateDelayed},
+	StateExpired:      {StateQueued, StateDelayed},
+	StatePopped:       {StateQueued, StateDelayed},
+	StateDeleted:      {StateQueued, StateDelayed},
+}
+
+func (s State) canBecome(to State) bool {
+	for _, t := range transitions[s] {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultStateRetention is the number of items in a terminal state whose
+// state is remembered, see SetStateRetention.
+const DefaultStateRetention = 10000
+
+// transition moves item to state to. An invalid transition is a bug in the
+// queue, not in the caller, and panics. The queue lock must be held.
+func (pq *PriorityQueue) transition(item *QItem, to State) {
+	if !item.state.canBecome(to) {
+		panic(fmt.Sprintf("priorityqueue: invalid transition of item [%s] from %v to %v", item.ID, item.state, to))
+	}
+	pq.progress(item, item.state, to)
+	pq.barrierProgress(item, item.state, to)
+	pq.quiesce(item.state, to)
+	pq.serve(item, item.state, to)
+	item.state = to
+	if pq.states == nil {
+		pq.states = make(map[string]State)
+	}
+	key := pq.itemKey(item)
+	pq.states[key] = to
+	if to.Terminal() {
+		pq.archive(item, to)
+		if to != StateDeleted || pq.recycleWindow <= 0 {
+			pq.dropBlob(item.Value)
+		}
+		pq.stateTotals[to]++
+		pq.retire(key)
+		delete(pq.history, key)
+	}
+}
+
+// retire remembers the terminal state of id, forgetting the oldest ones
+// beyond the retention limit.
+func (pq *PriorityQueue) retire(id string) {
+	pq.retired = append(pq.retired, id)
+	pq.trimRetired()
+}
+
+func (pq *PriorityQueue) trimRetired() {
+	limit := pq.stateRetention
+	if limit == 0 {
+		limit = DefaultStateRetention
+	}
+	for len(pq.retired) > limit {
+		old := pq.retired[0]
+		pq.retired = pq.retired[1:]
+		if pq.states[old].Terminal() {
+			delete(pq.states, old)
+		}
+	}
+}
+
+// State returns the lifecycle state of the item with the given ID. Items
+// held by the queue always report their state; the states of items the
+// queue is done with are remembered up to the retention limit set by
+// SetStateRetention. When several items share an ID the latest transition
+// of any of them is reported.
+func (pq *PriorityQueue) State(id string) State {
+	defer pq.lock(OpState)()
+	return pq.states[pq.lookupKey(id)]
+}
+
+// StateCounts returns the number of items in each state. Counts of the
+// non-terminal states are the items currently in that state; counts of
+// the terminal states are totals since the queue was created.
+func (pq *PriorityQueue) StateCounts() map[State]int {
+	defer pq.lock(OpState)()
+	counts := map[State]int{
+		StateQueued:       pq.size(),
+		StateDelayed:      len(pq.delayed) + len(pq.coalescing),
+		StateInFlight:     len(pq.leases),
+		StateDeadLettered: len(pq.deadLetters),
+	}
+	for s, n := range pq.stateTotals {
+		if State(s).Terminal() {
+			counts[State(s)] = n
+		}
+	}
+	return counts
+}
+
+// Counts are the numbers of items held by a queue in each state, read at
+// once by Counts.
+type Counts struct {
+	Queued       int
+	Delayed      int
+	InFlight     int
+	DeadLettered int
+
+	// ExpiredPending counts the items past their ExpiresAt which the queue
+	// has not dropped yet: delayed items are dropped once due, queued items
+	// right away unless the queue is frozen.
+	ExpiredPending int
+}
+
+// Counts returns the number of items in each state the queue holds items
+// in, under a single lock.
+func (pq *PriorityQueue) Counts() Counts {
+	defer pq.lock(OpLen)()
+	c := Counts{
+		Queued:       pq.size(),
+		Delayed:      len(pq.delayed) + len(pq.coalescing),
+		InFlight:     len(pq.leases),
+		DeadLettered: len(pq.deadLetters),
+	}
+	now := pq.now()
+	for _, t := range pq.expiries {
+		item := t.v
+		if !now.Before(t.at) && pq.holds(item) {
+			c.ExpiredPending++
+		}
+	}
+	for _, t := range pq.delayed {
+		if expired(&t.v, now) {
+			c.ExpiredPending++
+		}
+	}
+	for _, i := range pq.coalescing {
+		if expired(i, now) {
+			c.ExpiredPending++
+		}
+	}
+	return c
+}
+
+// SetStateRetention sets how many items in a terminal state have their state
+// remembered by State, zero meaning DefaultStateRetention.
+func (pq *PriorityQueue) SetStateRetention(n int) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.stateRetention = n
+	pq.trimRetired()
+}
+
+// PushDelayed adds an item that becomes visible to Pop once at has passed.
+// Until then it is reported as StateDelayed and not counted by Len.
+func (pq *PriorityQueue) PushDelayed(i QItem, at time.Time) error {
+	defer pq.lock(OpPush)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpPush, Item: recordItem(&i), At: &at})
+	if ok, err := pq.admit(context.Background(), &i); !ok {
+		return undiverted(err)
+	}
+	if !at.After(pq.now()) {
+		pq.audit(OpPush, pq.insert(i))
+		return nil
+	}
+	pq.delay(i, at)
+	pq.audit(OpPush, &i)
+	return nil
+}
+
+// delay holds an item back until at. The queue lock must be held.
+func (pq *PriorityQueue) delay(i QItem, at time.Time) {
+	pq.transition(&i, StateDelayed)
+	heap.Push(&pq.delayed, timer[QItem]{at: at, v: i})
+}
+
+// now is the queue's clock
+func (pq *PriorityQueue) now() time.Time {
+	if pq.clock != nil {
+		return pq.clock()
+	}
+	return time.Now()
+}
+
+func expired(i *QItem, now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && !now.Before(i.ExpiresAt)
+}
+
+// timersPending reports whether advance has anything to look at. The queue
+// lock must be held.
+func (pq *PriorityQueue) timersPending() bool {
+	return len(pq.delayed) > 0 || len(pq.expiries) > 0 || len(pq.leaseTimers) > 0 || len(pq.coalesceTimers) > 0 ||
+		len(pq.flapTimers) > 0
+}
+
+// nextDue returns when the next delayed item, lease, coalesced push or
+// deferred priority change falls due, the zero time if there is none. The queue lock must be held.
+func (pq *PriorityQueue) nextDue() time.Time {
+	var next time.Time
+	if len(pq.delayed) > 0 {
+		next = pq.delayed[0].at
+	}
+	if len(pq.leaseTimers) > 0 && (next.IsZero() || pq.leaseTimers[0].at.Before(next)) {
+		next = pq.leaseTimers[0].at
+	}
+	if len(pq.coalesceTimers) > 0 && (next.IsZero() || pq.coalesceTimers[0].at.Before(next)) {
+		next = pq.coalesceTimers[0].at
+	}
+	if len(pq.flapTimers) > 0 && (next.IsZero() || pq.flapTimers[0].at.Before(next)) {
+		next = pq.flapTimers[0].at
+	}
+	return next
+}
+
+// advance queues the delayed items that fell due, drops the queued items
+// that expired and redelivers the items whose lease expired. The queue lock
+// must be held.
+func (pq *PriorityQueue) advance(now time.Time) {
+	for len(pq.delayed) > 0 && !now.Before(pq.delayed[0].at) {
+		i := heap.Pop(&pq.delayed).(timer[QItem]).v
+		if expired(&i, now) {
+			pq.transition(&i, StateExpired)
+			pq.audit(OpExpire, &i)
+			continue
+		}
+		pq.audit(OpPromote, pq.insert(i))
+	}
+	pq.flushCoalesced(now)
+	pq.applyDeferredChanges(now)
+	for len(pq.leaseTimers) > 0 && !now.Before(pq.leaseTimers[0].at) {
+		t := heap.Pop(&pq.leaseTimers).(timer[uint64])
+		l, ok := pq.leases[t.v]
+		if !ok || !l.deadline.Equal(t.at) {
+			continue // acked, or the lease was extended
+		}
+		delete(pq.leases, t.v)
+		pq.meterLease(l, l.deadline)
+		pq.audit(OpLeaseExpired, &l.item)
+		pq.endAttempt(&l.item, "lease expired", now)
+		pq.redeliver(l.item, 0, "lease expired", now)
+	}
+	for len(pq.expiries) > 0 && !now.Before(pq.expiries[0].at) {
+		t := heap.Pop(&pq.expiries).(timer[*QItem])
+		item := t.v
+		// A slab slot may hold another item by now
+		if !pq.holds(item) || !item.ExpiresAt.Equal(t.at) {
+			continue // no longer queued
+		}
+		pq.audit(OpExpire, pq.remove(item.index, StateExpired))
+	}
+}
+
+// A timer is a value due at a given time; timers is a heap of them, soonest
+// first.
+type timer[T any] struct {
+	at time.Time
+	v  T
+}
+
+type timers[T any] []timer[T]
+
+func (t timers[T]) Len() int           { return len(t) }
+func (t timers[T]) Less(i, j int) bool { return t[i].at.Before(t[j].at) }
+func (t timers[T]) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t *timers[T]) Push(x any)        { *t = append(*t, x.(timer[T])) }
+func (t *timers[T]) Pop() any {
+	old := *t
+	n := len(old)
+	x := old[n-1]
+	var zero timer[T]
+	old[n-1] = zero
+	*t = old[:n-1]
+	return x
+}
@@ -0,0 +1,144 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a clock for pq that only moves when advanced
+func fakeClock(pq *PriorityQueue) func(time.Duration) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	pq.clock = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func Test_StateTransitions(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, pq.State("1"), StateUnknown)
+
+	populateQueue(pq, 3)
+	assertEqual(t, pq.State("1"), StateQueued)
+
+	item, _ := pq.Pop()
+	assertEqual(t, pq.State(item.ID), StatePopped)
+
+	pq.DeleteItemById("0")
+	assertEqual(t, pq.State("0"), StateDeleted)
+
+	// Pushing a popped item again starts a new lifecycle
+	pq.Push(*item)
+	assertEqual(t, pq.State(item.ID), StateQueued)
+}
+
+func Test_StateInvalidTransitionPanics(t *testing.T) {
+	pq := NewPriorityQueue()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("An invalid transition did not panic")
+		}
+	}()
+	pq.transition(&QItem{ID: "1", state: StateAcked}, StateInFlight)
+}
+
+func Test_StateRetention(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetStateRetention(2)
+	populateQueue(pq, 3)
+	pq.Clear() // highest priority first
+
+	assertEqual(t, pq.State("2"), StateUnknown)
+	assertEqual(t, pq.State("1"), StateDeleted)
+	assertEqual(t, pq.State("0"), StateDeleted)
+}
+
+func Test_PushDelayed(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+
+	err := pq.PushDelayed(QItem{ID: "1", Priority: 5}, pq.now().Add(time.Minute))
+	if err != nil {
+		t.Errorf("Error pushing delayed item: %v", err)
+	}
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, pq.State("1"), StateDelayed)
+	if _, err := pq.Pop(); err != ErrEmptyQueue {
+		t.Errorf("Error popping a delayed item: %v", err)
+	}
+
+	advance(time.Minute)
+	assertEqual(t, pq.Len(), 1)
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "1")
+}
+
+func Test_PopWaitDelayed(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.PushDelayed(QItem{ID: "1"}, time.Now().Add(20*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	item, err := pq.PopWait(ctx)
+	if err != nil {
+		t.Errorf("Error waiting for a delayed item: %v", err)
+		return
+	}
+	assertEqual(t, item.ID, "1")
+}
+
+func Test_Expiry(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	pq.Push(QItem{ID: "1", Priority: 1, ExpiresAt: pq.now().Add(time.Second)})
+	pq.Push(QItem{ID: "2", Priority: 2})
+	pq.PushDelayed(QItem{ID: "3", ExpiresAt: pq.now().Add(time.Second)}, pq.now().Add(time.Minute))
+
+	advance(time.Second)
+	assertEqual(t, pq.Len(), 1)
+	assertEqual(t, pq.State("1"), StateExpired)
+
+	advance(time.Minute)
+	assertEqual(t, pq.Len(), 1)
+	assertEqual(t, pq.State("3"), StateExpired)
+	assertEqual(t, pq.StateCounts()[StateExpired], 2)
+}
+
+func Test_StateCounts(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 5)
+	pq.Pop()
+	pq.PushDelayed(QItem{ID: "d"}, time.Now().Add(time.Hour))
+	pq.Lease(time.Hour)
+	_, r, _ := pq.Lease(time.Hour)
+	pq.Ack(r)
+
+	counts := pq.StateCounts()
+	assertEqual(t, counts[StateQueued], 2)
+	assertEqual(t, counts[StateDelayed], 1)
+	assertEqual(t, counts[StateInFlight], 1)
+	assertEqual(t, counts[StatePopped], 1)
+	assertEqual(t, counts[StateAcked], 1)
+	assertEqual(t, StateDeadLettered.String(), "dead-lettered")
+}
+
+func Test_Counts(t *testing.T) {
+	pq := NewPriorityQueue()
+	advance := fakeClock(pq)
+	populateQueue(pq, 4)
+	pq.PushDelayed(QItem{ID: "later", ExpiresAt: pq.now().Add(time.Minute)}, pq.now().Add(time.Hour))
+	pq.Push(QItem{ID: "soon", ExpiresAt: pq.now().Add(time.Minute)})
+	pq.Lease(time.Hour)
+	_, r, _ := pq.Lease(time.Hour)
+	pq.DeadLetter(r, "poison")
+
+	c := pq.Counts()
+	assertEqual(t, c, Counts{Queued: 3, Delayed: 1, InFlight: 1, DeadLettered: 1})
+
+	pq.Freeze(FreezeReject)
+	advance(2 * time.Minute)
+	assertEqual(t, pq.Counts().ExpiredPending, 2)
+	pq.Thaw()
+	c = pq.Counts()
+	assertEqual(t, c.Queued, 2)
+	assertEqual(t, c.ExpiredPending, 1)
+}
@@ -0,0 +1,137 @@
+package priorityqueue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrRateLimited is wrapped by a ProducerLimitError when a producer
+	// pushes faster than its limit allows.
+	ErrRateLimited = errors.New("producer rate limit exceeded")
+
+	// ErrQuotaExceeded is wrapped by a ProducerLimitError when a producer
+	// already has its maximum number of items queued.
+	ErrQuotaExceeded = errors.New("producer quota exceeded")
+)
+
+// A ProducerLimitError is returned by Push when the item's producer is over
+// one of its limits. It wraps ErrRateLimited or ErrQuotaExceeded.
+type ProducerLimitError struct {
+	Producer string
+	Err      error
+}
+
+func (e *ProducerLimitError) Error() string {
+	return fmt.Sprintf("%v for producer [%s]", e.Err, e.Producer)
+}
+
+func (e *ProducerLimitError) Unwrap() error {
+	return e.Err
+}
+
+// A ProducerLimit bounds how fast, and how much, a producer may push.
+// A zero field leaves that dimension unlimited.
+type ProducerLimit struct {
+	Rate  float64 `json:"rate,omitempty"`  // Average pushes per second
+	Burst int     `json:"burst,omitempty"` // Pushes allowed in a burst above Rate, at least 1
+	Quota int     `json:"quota,omitempty"` // Items from the producer queued at any one time
+}
+
+// Rejections counts the pushes a producer had refused
+type Rejections struct {
+	RateLimited   int
+	QuotaExceeded int
+}
+
+// producerLimiter enforces a ProducerLimit with a token bucket
+type producerLimiter struct {
+	limit  ProducerLimit
+	tokens float64
+	last   time.Time
+}
+
+func (l *producerLimiter) allow(now time.Time) bool {
+	if l.limit.Rate <= 0 {
+		return true
+	}
+	burst := float64(l.limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	l.tokens += now.Sub(l.last).Seconds() * l.limit.Rate
+	if l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// SetProducerLimit limits the pushes of items labeled with producer. Items
+// pushed without a Producer label are limited by the limit set for "".
+func (pq *PriorityQueue) SetProducerLimit(producer string, limit ProducerLimit) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.setProducerLimit(producer, limit)
+}
+
+// setProducerLimit is SetProducerLimit; the queue lock must be held
+func (pq *PriorityQueue) setProducerLimit(producer string, limit ProducerLimit) {
+	if pq.limits == nil {
+		pq.limits = make(map[string]*producerLimiter)
+	}
+	burst := limit.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	pq.limits[producer] = &producerLimiter{
+		limit:  limit,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// RemoveProducerLimit lifts the limits set for producer
+func (pq *PriorityQueue) RemoveProducerLimit(producer string) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	delete(pq.limits, producer)
+}
+
+// admitProducer checks whether producer may push another item, recording
+// the rejection if not. The queue lock must be held.
+func (pq *PriorityQueue) admitProducer(producer string) error {
+	l, ok := pq.limits[producer]
+	if !ok {
+		return nil
+	}
+	var err error
+	if l.limit.Quota > 0 && pq.byProducer[producer] >= l.limit.Quota {
+		err = ErrQuotaExceeded
+	} else if !l.allow(time.Now()) {
+		err = ErrRateLimited
+	}
+	if err == nil {
+		return nil
+	}
+
+	if pq.rejected == nil {
+		pq.rejected = make(map[string]*Rejections)
+	}
+	r, ok := pq.rejected[producer]
+	if !ok {
+		r = &Rejections{}
+		pq.rejected[producer] = r
+	}
+	if err == ErrQuotaExceeded {
+		r.QuotaExceeded++
+	} else {
+		r.RateLimited++
+	}
+	return &ProducerLimitError{Producer: producer, Err: err}
+}
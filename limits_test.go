@@ -0,0 +1,54 @@
+package priorityqueue
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_ProducerQuota(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetProducerLimit("batch", ProducerLimit{Quota: 2})
+
+	for i := 0; i < 2; i++ {
+		if err := pq.Push(QItem{ID: "b", Producer: "batch"}); err != nil {
+			t.Fatalf("Push within quota failed: %v", err)
+		}
+	}
+	err := pq.Push(QItem{ID: "b", Producer: "batch"})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	var lerr *ProducerLimitError
+	if !errors.As(err, &lerr) || lerr.Producer != "batch" {
+		t.Errorf("Expected a ProducerLimitError for batch, got %v", err)
+	}
+
+	// Other producers are not affected and popping frees quota
+	assertEqual(t, pq.Push(QItem{ID: "o", Producer: "other"}), nil)
+	pq.DeleteItemById("b")
+	assertEqual(t, pq.Push(QItem{ID: "b", Producer: "batch"}), nil)
+
+	s := pq.Stats()
+	assertEqual(t, s.ByProducer["batch"], 2)
+	assertEqual(t, s.Rejected["batch"].QuotaExceeded, 1)
+}
+
+func Test_ProducerRateLimit(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetProducerLimit("chatty", ProducerLimit{Rate: 0.001, Burst: 3})
+
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		err := pq.Push(QItem{ID: "c", Producer: "chatty"})
+		if err == nil {
+			accepted++
+		} else if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrRateLimited, got %v", err)
+		}
+	}
+	assertEqual(t, accepted, 3)
+	assertEqual(t, pq.Stats().Rejected["chatty"].RateLimited, 7)
+
+	pq.RemoveProducerLimit("chatty")
+	assertEqual(t, pq.Push(QItem{ID: "c", Producer: "chatty"}), nil)
+}
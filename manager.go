@@ -0,0 +1,126 @@
+package priorityqueue
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// A Manager holds named queues, created on first use
+type Manager struct {
+	m          sync.Mutex
+	queues     map[string]*PriorityQueue
+	finalizers []func(name string, pq *PriorityQueue) error
+
+	// Shard counts of the sharded queues, see Reshard
+	shards     map[string]int
+	resharding sync.Mutex
+
+	// New, if set, creates the queues of the manager instead of
+	// NewPriorityQueue; use it to configure them.
+	New func(name string) *PriorityQueue
+}
+
+// NewManager returns a Manager without queues
+func NewManager() *Manager {
+	return &Manager{queues: make(map[string]*PriorityQueue)}
+}
+
+// Queue returns the queue called name, creating it if needed
+func (mgr *Manager) Queue(name string) *PriorityQueue {
+	mgr.m.Lock()
+	defer mgr.m.Unlock()
+	pq, ok := mgr.queues[name]
+	if !ok {
+		if mgr.New != nil {
+			pq = mgr.New(name)
+		} else {
+			pq = NewPriorityQueue()
+		}
+		pq.AddFinalizer(func() error { return mgr.finalize(name, pq) })
+		mgr.queues[name] = pq
+	}
+	return pq
+}
+
+// Lookup returns the queue called name if it exists
+func (mgr *Manager) Lookup(name string) (*PriorityQueue, bool) {
+	mgr.m.Lock()
+	defer mgr.m.Unlock()
+	pq, ok := mgr.queues[name]
+	return pq, ok
+}
+
+// Remove forgets the queue called name, returning it if it existed. The
+// queue itself is left as it is.
+func (mgr *Manager) Remove(name string) (*PriorityQueue, bool) {
+	mgr.m.Lock()
+	defer mgr.m.Unlock()
+	pq, ok := mgr.queues[name]
+	delete(mgr.queues, name)
+	return pq, ok
+}
+
+// Names returns the names of the queues, sorted
+func (mgr *Manager) Names() []string {
+	mgr.m.Lock()
+	defer mgr.m.Unlock()
+	names := make([]string, 0, len(mgr.queues))
+	for name := range mgr.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddFinalizer registers fn to run once with each queue of the manager
+// when the queue is finalized, see PriorityQueue.AddFinalizer, including the
+// queues created already. The finalizers of the manager run after those of
+// the queue, the last registered first.
+func (mgr *Manager) AddFinalizer(fn func(name string, pq *PriorityQueue) error) {
+	mgr.m.Lock()
+	defer mgr.m.Unlock()
+	mgr.finalizers = append(mgr.finalizers, fn)
+}
+
+// finalize runs the finalizers of the manager for the queue called name,
+// returning the first error
+func (mgr *Manager) finalize(name string, pq *PriorityQueue) error {
+	mgr.m.Lock()
+	fns := append([]func(string, *PriorityQueue) error(nil), mgr.finalizers...)
+	mgr.m.Unlock()
+
+	var err error
+	for n := len(fns) - 1; n >= 0; n-- {
+		if ferr := runFinalizer(func() error { return fns[n](name, pq) }); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// Close forgets every queue of the manager and stops them in the order of
+// their names, running their finalizers. It returns the first error of
+// Stop, and gives up on the queues left once ctx is done.
+func (mgr *Manager) Close(ctx context.Context) error {
+	mgr.m.Lock()
+	queues := mgr.queues
+	mgr.queues = make(map[string]*PriorityQueue)
+	mgr.m.Unlock()
+
+	names := make([]string, 0, len(queues))
+	for name := range queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var err error
+	for _, name := range names {
+		if serr := queues[name].Stop(ctx); err == nil {
+			err = serr
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package priorityqueue
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_Manager(t *testing.T) {
+	mgr := NewManager()
+	a := mgr.Queue("a")
+	assertEqual(t, mgr.Queue("a"), a)
+	mgr.Queue("b")
+
+	if _, ok := mgr.Lookup("c"); ok {
+		t.Errorf("Error looking up a missing queue")
+	}
+	assertEqual(t, len(mgr.Names()), 2)
+
+	removed, _ := mgr.Remove("a")
+	assertEqual(t, removed, a)
+	assertEqual(t, mgr.Names()[0], "b")
+}
+
+func Test_ManagerNew(t *testing.T) {
+	mgr := NewManager()
+	var created []string
+	mgr.New = func(name string) *PriorityQueue {
+		created = append(created, name)
+		return NewPriorityQueue()
+	}
+	mgr.Queue("a")
+	mgr.Queue("a")
+	assertEqual(t, len(created), 1)
+}
+
+func Test_ManagerFinalizers(t *testing.T) {
+	mgr := NewManager()
+	var ran []string
+	a := mgr.Queue("a")
+	mgr.AddFinalizer(func(name string, pq *PriorityQueue) error {
+		ran = append(ran, "manager "+name)
+		return nil
+	})
+	a.AddFinalizer(func() error { ran = append(ran, "queue a"); return nil })
+	mgr.Queue("b")
+
+	assertEqual(t, mgr.Close(context.Background()), nil)
+	assertEqual(t, len(mgr.Names()), 0)
+	if !reflect.DeepEqual(ran, []string{"queue a", "manager a", "manager b"}) {
+		t.Errorf("Error running the finalizers of the manager: %v", ran)
+	}
+}
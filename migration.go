@@ -0,0 +1,267 @@
+package priorityqueue
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotVerified is returned by Cutover before the new backend of a
+// Migration has proven its parity with the old one.
+var ErrNotVerified = errors.New("migration parity not verified")
+
+// A MigrationConfig says when a Migration may cut over to the new backend
+type MigrationConfig struct {
+	// Verify is how long both backends must run side by side
+	Verify time.Duration
+
+	// MinCompared is the number of pops that must have been compared
+	MinCompared int
+
+	// MaxMismatches is the number of compared pops for which the new
+	// backend may have disagreed
+	MaxMismatches int
+
+	// AutoCutover cuts over on the first operation after parity is verified
+	AutoCutover bool
+
+	// Clock tells the time, time.Now if nil
+	Clock func() time.Time
+}
+
+// MigrationStatus describes the progress of a Migration
+type MigrationStatus struct {
+	Started    time.Time
+	CutOver    bool
+	Compared   int // Pops whose item was compared with the new backend
+	Mismatches int // Compared pops for which the new backend disagreed
+
+	// Unmirrored counts the pops of items queued in the old backend before
+	// the migration started, which cannot be compared
+	Unmirrored int
+}
+
+// A Migration is a Queue moving from an old backend to a new one, such as
+// from a PriorityQueue to a persistent implementation. Until it cuts over
+// every change is written to both backends and served by the old one, and
+// each Pop checks that the new backend would have returned the same item,
+// recording the mismatches as a Shadow does. Once the new backend has kept
+// parity as long as the MigrationConfig requires, Cutover moves the items
+// left in the old backend over and serves from the new backend alone.
+//
+// Items queued in the old backend before the migration are not copied to
+// the new one until the cutover; pops of those items are not compared.
+type Migration struct {
+	m        sync.Mutex
+	old, new Queue
+	cfg      MigrationConfig
+	status   MigrationStatus
+	mirrored map[string]int // Items written to both backends, by ID
+	diffs    []ShadowDiff
+}
+
+var _ Queue = (*Migration)(nil)
+
+// NewMigration starts a migration from old to new
+func NewMigration(old, new Queue, cfg MigrationConfig) *Migration {
+	m := &Migration{old: old, new: new, cfg: cfg, mirrored: make(map[string]int)}
+	m.status.Started = m.now()
+	return m
+}
+
+func (m *Migration) now() time.Time {
+	if m.cfg.Clock != nil {
+		return m.cfg.Clock()
+	}
+	return time.Now()
+}
+
+// enter locks the migration for an operation, cutting over first if parity
+// is verified and AutoCutover is set. It reports whether the migration has
+// cut over.
+func (m *Migration) enter() bool {
+	m.m.Lock()
+	if !m.status.CutOver && m.cfg.AutoCutover && m.verified() {
+		m.cutover()
+	}
+	return m.status.CutOver
+}
+
+func (m *Migration) Push(i QItem) error {
+	defer m.m.Unlock()
+	if m.enter() {
+		return m.new.Push(i)
+	}
+	if err := m.old.Push(i); err != nil {
+		return err
+	}
+	if m.new.Push(i) == nil {
+		m.mirrored[i.ID]++
+	}
+	return nil
+}
+
+func (m *Migration) Pop() (*QItem, error) {
+	defer m.m.Unlock()
+	if m.enter() {
+		return m.new.Pop()
+	}
+	item, err := m.old.Pop()
+	if err != nil {
+		return item, err
+	}
+	if m.mirrored[item.ID] == 0 {
+		m.status.Unmirrored++
+		return item, nil
+	}
+	m.unmirror(item.ID)
+	m.status.Compared++
+	if other, err := m.new.Peek(); err != nil || other.ID != item.ID {
+		m.status.Mismatches++
+		diff := ShadowDiff{Pop: m.status.Compared, Primary: *item}
+		if err == nil {
+			diff.Shadow = *other
+		}
+		m.diffs = append(m.diffs, diff)
+		if len(m.diffs) > ShadowDiffs {
+			m.diffs = m.diffs[len(m.diffs)-ShadowDiffs:]
+		}
+	}
+	m.new.DeleteItemById(item.ID)
+	return item, nil
+}
+
+func (m *Migration) unmirror(id string) {
+	if m.mirrored[id]--; m.mirrored[id] <= 0 {
+		delete(m.mirrored, id)
+	}
+}
+
+func (m *Migration) Peek() (*QItem, error) {
+	defer m.m.Unlock()
+	if m.enter() {
+		return m.new.Peek()
+	}
+	return m.old.Peek()
+}
+
+func (m *Migration) Len() int {
+	defer m.m.Unlock()
+	if m.enter() {
+		return m.new.Len()
+	}
+	return m.old.Len()
+}
+
+func (m *Migration) Clear() {
+	defer m.m.Unlock()
+	if !m.enter() {
+		m.old.Clear()
+		m.mirrored = make(map[string]int)
+	}
+	m.new.Clear()
+}
+
+func (m *Migration) UpdatePriorityByParentId(parentID string, priority int) int {
+	defer m.m.Unlock()
+	if m.enter() {
+		return m.new.UpdatePriorityByParentId(parentID, priority)
+	}
+	m.new.UpdatePriorityByParentId(parentID, priority)
+	return m.old.UpdatePriorityByParentId(parentID, priority)
+}
+
+func (m *Migration) DeleteItemById(id string) error {
+	defer m.m.Unlock()
+	if m.enter() {
+		return m.new.DeleteItemById(id)
+	}
+	if err := m.old.DeleteItemById(id); err != nil {
+		return err
+	}
+	if m.mirrored[id] > 0 && m.new.DeleteItemById(id) == nil {
+		m.unmirror(id)
+	}
+	return nil
+}
+
+func (m *Migration) DeleteItemsByParentId(parentID string) (int, error) {
+	defer m.m.Unlock()
+	if m.enter() {
+		return m.new.DeleteItemsByParentId(parentID)
+	}
+	m.new.DeleteItemsByParentId(parentID)
+	return m.old.DeleteItemsByParentId(parentID)
+}
+
+// verified reports whether the new backend kept parity as long as required.
+// The migration lock must be held.
+func (m *Migration) verified() bool {
+	return m.now().Sub(m.status.Started) >= m.cfg.Verify &&
+		m.status.Compared >= m.cfg.MinCompared &&
+		m.status.Mismatches <= m.cfg.MaxMismatches
+}
+
+// Cutover moves the items left in the old backend that the new one does
+// not hold yet, and makes the migration serve from the new backend alone.
+// It fails with ErrNotVerified unless parity has been verified or force is
+// set. Cutting over twice does nothing.
+func (m *Migration) Cutover(force bool) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if m.status.CutOver {
+		return nil
+	}
+	if !force && !m.verified() {
+		return ErrNotVerified
+	}
+	return m.cutover()
+}
+
+// cutover drains the old backend into the new one. The migration lock must
+// be held.
+func (m *Migration) cutover() error {
+	for {
+		item, err := m.old.Pop()
+		if err == ErrEmptyQueue {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if m.mirrored[item.ID] > 0 {
+			m.unmirror(item.ID)
+			continue
+		}
+		if err := m.new.Push(*item); err != nil {
+			return err
+		}
+	}
+	m.status.CutOver = true
+	m.mirrored = nil
+	return nil
+}
+
+// Status returns the progress of the migration
+func (m *Migration) Status() MigrationStatus {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return m.status
+}
+
+// Mismatches returns the latest pops for which the new backend disagreed,
+// oldest first, with the item it would have returned as Shadow.
+func (m *Migration) Mismatches() []ShadowDiff {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return append([]ShadowDiff(nil), m.diffs...)
+}
+
+// Capabilities returns those of the backend serving the migration
+func (m *Migration) Capabilities() Capabilities {
+	defer m.m.Unlock()
+	if m.enter() {
+		return CapabilitiesOf(m.new)
+	}
+	return CapabilitiesOf(m.old)
+}
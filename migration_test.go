@@ -0,0 +1,64 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Migration(t *testing.T) {
+	old, new := NewPriorityQueue(), NewSortedQueue()
+	old.Push(QItem{ID: "before", Priority: 100})
+	now := time.Now()
+	m := NewMigration(old, new, MigrationConfig{
+		Verify:      time.Hour,
+		MinCompared: 2,
+		Clock:       func() time.Time { return now },
+	})
+	for n, id := range []string{"a", "b", "c"} {
+		m.Push(QItem{ID: id, Priority: n + 1})
+	}
+	assertEqual(t, m.Len(), 4)
+	assertEqual(t, new.Len(), 3)
+
+	x, _ := m.Pop()
+	assertEqual(t, x.ID, "before")
+	x, _ = m.Pop()
+	assertEqual(t, x.ID, "c")
+	x, _ = m.Pop()
+	assertEqual(t, x.ID, "b")
+	s := m.Status()
+	assertEqual(t, s.Compared, 2)
+	assertEqual(t, s.Mismatches, 0)
+	assertEqual(t, s.Unmirrored, 1)
+
+	assertEqual(t, m.Cutover(false), ErrNotVerified)
+	now = now.Add(time.Hour)
+	old.Push(QItem{ID: "late", Priority: 1})
+	assertEqual(t, m.Cutover(false), nil)
+	assertEqual(t, m.Status().CutOver, true)
+	assertEqual(t, old.Len(), 0)
+	assertEqual(t, m.Len(), 2)
+	x, _ = m.Pop()
+	assertEqual(t, x.ID, "a")
+	x, _ = m.Pop()
+	assertEqual(t, x.ID, "late")
+}
+
+func Test_MigrationMismatches(t *testing.T) {
+	old, new := NewPriorityQueue(), NewPriorityQueue()
+	m := NewMigration(old, new, MigrationConfig{AutoCutover: true, MinCompared: 1})
+	m.Push(QItem{ID: "a", Priority: 1})
+	m.Push(QItem{ID: "b", Priority: 2})
+	new.Push(QItem{ID: "extra", Priority: 5})
+
+	x, _ := m.Pop()
+	assertEqual(t, x.ID, "b")
+	assertEqual(t, m.Status().Mismatches, 1)
+	assertEqual(t, m.Mismatches()[0].Shadow.ID, "extra")
+	// Parity failed, the migration keeps serving the old backend
+	m.Pop()
+	assertEqual(t, m.Status().CutOver, false)
+	assertEqual(t, m.Cutover(true), nil)
+	x, _ = m.Pop()
+	assertEqual(t, x.ID, "extra")
+}
@@ -0,0 +1,79 @@
+package priorityqueue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrObjectNotFound is wrapped by the errors ObjectStore.Get returns for a
+// key holding no object.
+var ErrObjectNotFound = errors.New("object not found")
+
+// An ObjectStore keeps snapshots as named objects, in a bucket of S3, GCS
+// or another object storage service, see the pqs3 package.
+type ObjectStore interface {
+	// Put stores what r reads under key, replacing any object there
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Get returns a reader of the object stored under key, or an error
+	// wrapping ErrObjectNotFound. Reading fails if the object turns out to
+	// be corrupted.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// SnapshotTo writes a Snapshot of the queue to store under key, streaming
+// it so huge queues are not held in memory twice.
+func (pq *PriorityQueue) SnapshotTo(ctx context.Context, store ObjectStore, key string) error {
+	r, w := io.Pipe()
+	go func() {
+		bw := bufio.NewWriter(w)
+		err := pq.Snapshot(bw)
+		if err == nil {
+			err = bw.Flush()
+		}
+		w.CloseWithError(err)
+	}()
+	err := store.Put(ctx, key, r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("storing the snapshot [%s]: %w", key, err)
+	}
+	return nil
+}
+
+// RestoreFrom adds the items of the snapshot stored in store under key,
+// see Restore.
+func (pq *PriorityQueue) RestoreFrom(ctx context.Context, store ObjectStore, key string) error {
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("fetching the snapshot [%s]: %w", key, err)
+	}
+	defer r.Close()
+	if err := pq.Restore(bufio.NewReader(r)); err != nil {
+		return fmt.Errorf("restoring the snapshot [%s]: %w", key, err)
+	}
+	return nil
+}
+
+// WithSnapshotObject restores the queue from the snapshot stored in store
+// under key if there is one, and makes Stop store a snapshot back there, as
+// WithSnapshotFile does with a file.
+func WithSnapshotObject(store ObjectStore, key string) Option {
+	return func(pq *PriorityQueue) error {
+		if store == nil {
+			return fmt.Errorf("object store is nil")
+		}
+		err := pq.RestoreFrom(context.Background(), store, key)
+		if err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return err
+		}
+		pq.snapshotPath = key
+		pq.atStop = append(pq.atStop, func() error {
+			return pq.SnapshotTo(context.Background(), store, key)
+		})
+		return nil
+	}
+}
@@ -0,0 +1,383 @@
+package priorityqueue
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// An Option configures a queue created by New or NewPriorityQueue
+type Option func(pq *PriorityQueue) error
+
+// New returns an empty queue configured by opts, or the error of the first
+// invalid option.
+func New(opts ...Option) (*PriorityQueue, error) {
+	pq := newPriorityQueue()
+	for _, opt := range opts {
+		if err := opt(pq); err != nil {
+			return nil, fmt.Errorf("configuring the queue: %w", err)
+		}
+	}
+	return pq, nil
+}
+
+// WithCapacity allocates room for n items up front
+func WithCapacity(n int) Option {
+	return func(pq *PriorityQueue) error {
+		if n < 0 {
+			return fmt.Errorf("capacity %d is negative", n)
+		}
+		pq.data = append(make(QItems, 0, n), pq.data...)
+		return nil
+	}
+}
+
+// WithClock makes the queue tell the time with now rather than time.Now
+// for delays, expiries and leases, to test or simulate it.
+func WithClock(now func() time.Time) Option {
+	return func(pq *PriorityQueue) error {
+		if now == nil {
+			return fmt.Errorf("clock is nil")
+		}
+		pq.clock = now
+		return nil
+	}
+}
+
+// WithProducerLimit is SetProducerLimit
+func WithProducerLimit(producer string, limit ProducerLimit) Option {
+	return func(pq *PriorityQueue) error {
+		if limit.Rate < 0 || limit.Burst < 0 || limit.Quota < 0 {
+			return fmt.Errorf("limit of producer [%s] has a negative field: %+v", producer, limit)
+		}
+		pq.SetProducerLimit(producer, limit)
+		return nil
+	}
+}
+
+// WithMaxInFlight is SetMaxInFlight
+func WithMaxInFlight(n int) Option {
+	return func(pq *PriorityQueue) error {
+		if n < 0 {
+			return fmt.Errorf("max in flight %d is negative", n)
+		}
+		pq.SetMaxInFlight(n)
+		return nil
+	}
+}
+
+// WithMaxAttempts is SetMaxAttempts
+func WithMaxAttempts(n int) Option {
+	return func(pq *PriorityQueue) error {
+		if n < 0 {
+			return fmt.Errorf("max attempts %d is negative", n)
+		}
+		pq.SetMaxAttempts(n)
+		return nil
+	}
+}
+
+// WithRedeliveryBoost is SetRedeliveryBoost
+func WithRedeliveryBoost(b RedeliveryBoost) Option {
+	return func(pq *PriorityQueue) error {
+		if b.Max < 0 {
+			return fmt.Errorf("redelivery boost cap %d is negative", b.Max)
+		}
+		pq.SetRedeliveryBoost(b)
+		return nil
+	}
+}
+
+// WithDedupeWindow is SetDedupeWindow
+func WithDedupeWindow(d time.Duration) Option {
+	return func(pq *PriorityQueue) error {
+		if d < 0 {
+			return fmt.Errorf("dedupe window %v is negative", d)
+		}
+		pq.SetDedupeWindow(d)
+		return nil
+	}
+}
+
+// WithContentHash is SetContentHash
+func WithContentHash(hash func(value interface{}) string, ttl time.Duration) Option {
+	return func(pq *PriorityQueue) error {
+		if ttl < 0 {
+			return fmt.Errorf("content hash TTL %v is negative", ttl)
+		}
+		pq.SetContentHash(hash, ttl)
+		return nil
+	}
+}
+
+// WithIDGenerator is SetIDGenerator
+func WithIDGenerator(gen func() string) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetIDGenerator(gen)
+		return nil
+	}
+}
+
+// WithPriorityLevels is SetPriorityLevels
+func WithPriorityLevels(levels ...PriorityLevel) Option {
+	return func(pq *PriorityQueue) error {
+		return pq.SetPriorityLevels(levels...)
+	}
+}
+
+// WithTopView is SetTopView
+func WithTopView(k int) Option {
+	return func(pq *PriorityQueue) error {
+		if k < 0 {
+			return fmt.Errorf("top view size %d is negative", k)
+		}
+		pq.SetTopView(k)
+		return nil
+	}
+}
+
+// WithParentCeiling is SetParentCeiling
+func WithParentCeiling(parentID string, ceiling int) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetParentCeiling(parentID, ceiling)
+		return nil
+	}
+}
+
+// WithRecycleWindow is SetRecycleWindow
+func WithRecycleWindow(d time.Duration) Option {
+	return func(pq *PriorityQueue) error {
+		if d < 0 {
+			return fmt.Errorf("recycle window %v is negative", d)
+		}
+		pq.SetRecycleWindow(d)
+		return nil
+	}
+}
+
+// WithCoalesceDelay is SetCoalesceDelay
+func WithCoalesceDelay(d time.Duration) Option {
+	return func(pq *PriorityQueue) error {
+		if d < 0 {
+			return fmt.Errorf("coalesce delay %v is negative", d)
+		}
+		pq.SetCoalesceDelay(d)
+		return nil
+	}
+}
+
+// WithStateRetention is SetStateRetention
+func WithStateRetention(n int) Option {
+	return func(pq *PriorityQueue) error {
+		if n < 0 {
+			return fmt.Errorf("state retention %d is negative", n)
+		}
+		pq.SetStateRetention(n)
+		return nil
+	}
+}
+
+// WithAuthorizer is SetAuthorizer
+func WithAuthorizer(a Authorizer) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetAuthorizer(a)
+		return nil
+	}
+}
+
+// WithAdmissionPolicies is SetAdmissionPolicies
+func WithAdmissionPolicies(policies ...AdmissionPolicy) Option {
+	return func(pq *PriorityQueue) error {
+		for _, p := range policies {
+			if p.Percentile < 0 || p.Percentile > 1 {
+				return fmt.Errorf("admission percentile %v is not between 0 and 1", p.Percentile)
+			}
+		}
+		pq.SetAdmissionPolicies(policies...)
+		return nil
+	}
+}
+
+// WithRestore adds the items of the snapshot read from r, see Restore
+func WithRestore(r io.Reader) Option {
+	return func(pq *PriorityQueue) error {
+		if err := pq.Restore(r); err != nil {
+			return fmt.Errorf("restoring the snapshot: %w", err)
+		}
+		return nil
+	}
+}
+
+// WithRecording records the operations made on the queue to w, see Record.
+// The recording is flushed by Stop.
+func WithRecording(w io.Writer) Option {
+	return func(pq *PriorityQueue) error {
+		if w == nil {
+			return fmt.Errorf("recording writer is nil")
+		}
+		pq.Record(w)
+		return nil
+	}
+}
+
+// WithAuditLog is SetAuditLog
+func WithAuditLog(fn func(AuditEntry)) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetAuditLog(fn)
+		return nil
+	}
+}
+
+// WithWatchdog is SetWatchdog
+func WithWatchdog(w *Watchdog) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetWatchdog(w)
+		return nil
+	}
+}
+
+// WithDepthHistory is RecordDepth
+func WithDepthHistory(interval time.Duration, samples int) Option {
+	return func(pq *PriorityQueue) error {
+		if interval <= 0 || samples <= 0 {
+			return fmt.Errorf("depth history needs a positive interval and sample count, got %v and %d", interval, samples)
+		}
+		pq.RecordDepth(interval, samples)
+		return nil
+	}
+}
+
+// WithPriorityBuckets is SetPriorityBuckets, with bounds in increasing order
+func WithPriorityBuckets(bounds ...int) Option {
+	return func(pq *PriorityQueue) error {
+		for n := 1; n < len(bounds); n++ {
+			if bounds[n] <= bounds[n-1] {
+				return fmt.Errorf("priority bucket bounds are not increasing: %v", bounds)
+			}
+		}
+		pq.SetPriorityBuckets(bounds...)
+		return nil
+	}
+}
+
+// WithSweepInterval is SetSweepInterval
+func WithSweepInterval(interval time.Duration) Option {
+	return func(pq *PriorityQueue) error {
+		if interval < 0 {
+			return fmt.Errorf("sweep interval %v is negative", interval)
+		}
+		pq.SetSweepInterval(interval)
+		return nil
+	}
+}
+
+// WithRetentionPolicy is SetRetentionPolicy
+func WithRetentionPolicy(interval time.Duration, rules ...RetentionRule) Option {
+	return func(pq *PriorityQueue) error {
+		if interval < 0 {
+			return fmt.Errorf("retention interval %v is negative", interval)
+		}
+		return pq.SetRetentionPolicy(interval, rules...)
+	}
+}
+
+// WithEscalationRules is SetEscalationRules
+func WithEscalationRules(interval time.Duration, rules ...EscalationRule) Option {
+	return func(pq *PriorityQueue) error {
+		if interval < 0 {
+			return fmt.Errorf("escalation interval %v is negative", interval)
+		}
+		return pq.SetEscalationRules(interval, rules...)
+	}
+}
+
+// WithWebhooks is SetWebhooks
+func WithWebhooks(cfg WebhookConfig) Option {
+	return func(pq *PriorityQueue) error {
+		return pq.SetWebhooks(cfg)
+	}
+}
+
+// WithSnapshotFile restores the queue from the snapshot at path if the file
+// exists, and makes Stop write a snapshot back to it. The file is replaced
+// atomically so a crash while writing leaves the previous snapshot intact.
+func WithSnapshotFile(path string) Option {
+	return func(pq *PriorityQueue) error {
+		f, err := os.Open(path)
+		switch {
+		case err == nil:
+			err = pq.Restore(bufio.NewReader(f))
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("restoring [%s]: %w", path, err)
+			}
+		case !errors.Is(err, fs.ErrNotExist):
+			return err
+		}
+		pq.snapshotPath = path
+		pq.atStop = append(pq.atStop, func() error {
+			return writeFileAtomic(path, pq.Snapshot)
+		})
+		return nil
+	}
+}
+
+// WithRecordingFile records the operations made on the queue to the file at
+// path, replacing its content, see Record. Stop flushes and closes the file.
+func WithRecordingFile(path string) Option {
+	return func(pq *PriorityQueue) error {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		stop := pq.Record(f)
+		pq.atStop = append(pq.atStop, func() error {
+			err := stop()
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			return err
+		})
+		return nil
+	}
+}
+
+// writeFileAtomic replaces the file at path with what write writes
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// WithChunkSize sets the number of items ForEach, Snapshot and the exports
+// copy per lock acquisition, DefaultChunkSize by default. Smaller chunks
+// hold the lock for less time at the cost of more acquisitions.
+func WithChunkSize(n int) Option {
+	return func(pq *PriorityQueue) error {
+		if n < 1 {
+			return fmt.Errorf("chunk size %d is not positive", n)
+		}
+		pq.chunkSize = n
+		return nil
+	}
+}
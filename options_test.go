@@ -0,0 +1,60 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_New(t *testing.T) {
+	src := NewPriorityQueue()
+	populateQueue(src, 3)
+	var snapshot bytes.Buffer
+	src.Snapshot(&snapshot)
+
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	pq, err := New(
+		WithRestore(&snapshot),
+		WithCapacity(100),
+		WithClock(func() time.Time { return at }),
+		WithMaxInFlight(1),
+		WithProducerLimit("bulk", ProducerLimit{Quota: 1}),
+		WithPriorityBuckets(1, 2),
+	)
+	if err != nil {
+		t.Errorf("Error creating the queue: %v", err)
+		return
+	}
+	assertEqual(t, pq.Len(), 3)
+	assertEqual(t, cap(pq.data), 100)
+	assertEqual(t, pq.now(), at)
+	pq.Lease(time.Minute)
+	if _, _, err := pq.Lease(time.Minute); err != ErrTooManyInFlight {
+		t.Errorf("Error leasing over the configured cap: %v", err)
+	}
+	assertEqual(t, len(pq.Stats().Priorities.Counts), 3)
+}
+
+func Test_NewInvalid(t *testing.T) {
+	for _, opt := range []Option{
+		WithCapacity(-1),
+		WithClock(nil),
+		WithMaxAttempts(-1),
+		WithDepthHistory(0, 10),
+		WithPriorityBuckets(2, 1),
+		WithProducerLimit("bulk", ProducerLimit{Rate: -1}),
+		WithRestore(strings.NewReader("not a snapshot")),
+	} {
+		if _, err := New(opt); err == nil {
+			t.Errorf("Error, an invalid option was accepted")
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Error, NewPriorityQueue accepted an invalid option")
+		}
+	}()
+	NewPriorityQueue(WithCapacity(-1))
+}
@@ -0,0 +1,158 @@
+package priorityqueue
+
+import (
+	"errors"
+	"sync"
+)
+
+// OverflowStats counts the items an Overflow moved between its queues
+type OverflowStats struct {
+	Overflowed int // Pushes sent to the cold queue as the hot one was full
+	Promoted   int // Items moved to the hot queue as space freed or by policy
+	Demoted    int // Items moved to the cold queue by policy, see SetPolicy
+}
+
+// An Overflow is a Queue tiering a bounded hot queue over a larger cold
+// one, such as a persistent implementation: pushes go to the hot queue
+// until it holds limit items, then overflow into the cold queue, and the
+// highest priority cold items are promoted back as pops and deletes free
+// space in the hot queue. Pop and Peek compare the heads of both queues,
+// so the items still come out highest priority first. A TierPolicy moves
+// items between the queues by priority and residency.
+type Overflow struct {
+	m         sync.Mutex
+	hot, cold Queue
+	limit     int
+	stats     OverflowStats
+
+	policy                      TierPolicy
+	residence                   map[string]residence
+	hotResidency, coldResidency TierResidency
+}
+
+var _ Queue = (*Overflow)(nil)
+
+// NewOverflow returns an Overflow keeping at most limit items in hot and
+// the others in cold
+func NewOverflow(hot, cold Queue, limit int) *Overflow {
+	return &Overflow{hot: hot, cold: cold, limit: limit}
+}
+
+func (o *Overflow) Push(i QItem) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+	if o.hot.Len() < o.limit {
+		if err := o.hot.Push(i); err != nil {
+			return err
+		}
+		o.enter(i.ID, false)
+		return nil
+	}
+	if err := o.cold.Push(i); err != nil {
+		return err
+	}
+	o.enter(i.ID, true)
+	o.stats.Overflowed++
+	return nil
+}
+
+// promote moves the best cold items to the hot queue while it has room.
+// The overflow lock must be held.
+func (o *Overflow) promote() {
+	for o.hot.Len() < o.limit {
+		item, err := o.cold.Pop()
+		if err != nil {
+			return
+		}
+		if err := o.hot.Push(*item); err != nil {
+			// Keep the item rather than lose it
+			o.cold.Push(*item)
+			return
+		}
+		o.enter(item.ID, false)
+		o.stats.Promoted++
+	}
+}
+
+// head returns the queue whose head item comes first. The overflow lock
+// must be held.
+func (o *Overflow) head() Queue {
+	hot, err := o.hot.Peek()
+	if err != nil {
+		return o.cold
+	}
+	if cold, err := o.cold.Peek(); err == nil && cold.Priority > hot.Priority {
+		return o.cold
+	}
+	return o.hot
+}
+
+func (o *Overflow) Pop() (*QItem, error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	item, err := o.head().Pop()
+	if err == nil {
+		o.leave(item.ID)
+	}
+	o.promote()
+	return item, err
+}
+
+func (o *Overflow) Peek() (*QItem, error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	return o.head().Peek()
+}
+
+func (o *Overflow) Len() int {
+	o.m.Lock()
+	defer o.m.Unlock()
+	return o.hot.Len() + o.cold.Len()
+}
+
+func (o *Overflow) Clear() {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.hot.Clear()
+	o.cold.Clear()
+	o.residence = nil
+}
+
+func (o *Overflow) UpdatePriorityByParentId(parentID string, priority int) int {
+	o.m.Lock()
+	defer o.m.Unlock()
+	return o.hot.UpdatePriorityByParentId(parentID, priority) + o.cold.UpdatePriorityByParentId(parentID, priority)
+}
+
+func (o *Overflow) DeleteItemById(id string) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+	err := o.hot.DeleteItemById(id)
+	if errors.Is(err, ErrNotFound) {
+		err = o.cold.DeleteItemById(id)
+	}
+	if err == nil {
+		o.leave(id)
+	}
+	o.promote()
+	return err
+}
+
+func (o *Overflow) DeleteItemsByParentId(parentID string) (int, error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	n, err := o.hot.DeleteItemsByParentId(parentID)
+	if err != nil {
+		return n, err
+	}
+	m, err := o.cold.DeleteItemsByParentId(parentID)
+	o.promote()
+	return n + m, err
+}
+
+// Stats returns the number of items overflowed and promoted
+func (o *Overflow) Stats() OverflowStats {
+	o.m.Lock()
+	defer o.m.Unlock()
+	return o.stats
+}
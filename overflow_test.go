@@ -0,0 +1,41 @@
+package priorityqueue
+
+import (
+	"strconv"
+	"testing"
+)
+
+func Test_Overflow(t *testing.T) {
+	hot, cold := NewPriorityQueue(), NewSortedQueue()
+	o := NewOverflow(hot, cold, 3)
+	for n := 1; n <= 6; n++ {
+		o.Push(QItem{ID: strconv.Itoa(n), ParentID: "job", Priority: n})
+	}
+	assertEqual(t, hot.Len(), 3)
+	assertEqual(t, cold.Len(), 3)
+	assertEqual(t, o.Len(), 6)
+	assertEqual(t, o.Stats(), OverflowStats{Overflowed: 3})
+
+	// The overflowed items have the higher priorities
+	item, _ := o.Peek()
+	assertEqual(t, item.ID, "6")
+	for want := 6; want >= 4; want-- {
+		item, _ = o.Pop()
+		assertEqual(t, item.Priority, want)
+	}
+	assertEqual(t, hot.Len(), 3)
+	assertEqual(t, cold.Len(), 0)
+
+	o.Push(QItem{ID: "7", Priority: 7})
+	o.Push(QItem{ID: "8", Priority: 8})
+	assertEqual(t, o.DeleteItemById("1"), nil)
+	assertEqual(t, hot.Len(), 3)
+	assertEqual(t, cold.Len(), 1)
+	assertEqual(t, o.Stats(), OverflowStats{Overflowed: 5, Promoted: 1})
+
+	n, _ := o.DeleteItemsByParentId("job")
+	assertEqual(t, n, 2)
+	assertEqual(t, o.Len(), 2)
+	item, _ = o.Pop()
+	assertEqual(t, item.ID, "8")
+}
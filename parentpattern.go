@@ -0,0 +1,126 @@
+package priorityqueue
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// parentPattern reports whether a ParentID matches a pattern
+type parentPattern func(parentID string) bool
+
+// compileParentPattern compiles a glob in the syntax of path.Match, or a
+// regular expression enclosed in slashes.
+func compileParentPattern(pattern string) (parentPattern, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("parent pattern %q: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("parent pattern %q: %w", pattern, err)
+	}
+	return func(parentID string) bool {
+		ok, _ := path.Match(pattern, parentID)
+		return ok
+	}, nil
+}
+
+// parentsMatching returns the queued ParentIDs matching match. Like
+// parentTree it looks at every distinct ParentID rather than every item.
+// The queue lock must be held.
+func (pq *PriorityQueue) parentsMatching(match parentPattern) []string {
+	var parentIDs []string
+	for parentID := range pq.byParent {
+		if match(parentID) {
+			parentIDs = append(parentIDs, parentID)
+		}
+	}
+	return parentIDs
+}
+
+// UpdatePriorityByParentPattern sets the priority of the items whose
+// ParentID matches pattern, a glob in the syntax of path.Match such as
+// "tenant-42/*", or a regular expression enclosed in slashes such as
+// "/^tenant-4[0-9]$/". A glob's * does not match the ParentSeparator, so
+// "tenant-42/*" matches the children of tenant-42 but not theirs. It fails
+// for a malformed pattern.
+func (pq *PriorityQueue) UpdatePriorityByParentPattern(pattern string, priority int) (int, error) {
+	return pq.UpdatePriorityByParentPatternCtx(context.Background(), pattern, priority)
+}
+
+// UpdatePriorityByParentPatternCtx is UpdatePriorityByParentPattern on
+// behalf of the principal carried by ctx.
+func (pq *PriorityQueue) UpdatePriorityByParentPatternCtx(ctx context.Context, pattern string, priority int) (int, error) {
+	match, err := compileParentPattern(pattern)
+	if err != nil {
+		return 0, err
+	}
+	unlock, err := pq.lockCtx(ctx, OpUpdatePriorityByParentPattern)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	if err := pq.mutable(); err != nil {
+		return 0, err
+	}
+	pq.record(recorded{Op: OpUpdatePriorityByParentPattern, ParentID: pattern, Priority: priority})
+	items := pq.parentItems(pq.parentsMatching(match)...)
+	if err := pq.authorize(ctx, OpUpdatePriorityByParentPattern, items...); err != nil {
+		return 0, err
+	}
+	return pq.updatePriorities(OpUpdatePriorityByParentPattern, items, priority), nil
+}
+
+// DeleteItemsByParentPattern deletes the items whose ParentID matches
+// pattern, see UpdatePriorityByParentPattern.
+func (pq *PriorityQueue) DeleteItemsByParentPattern(pattern string) (int, error) {
+	return pq.DeleteItemsByParentPatternCtx(context.Background(), pattern)
+}
+
+// DeleteItemsByParentPatternCtx is DeleteItemsByParentPattern on behalf of
+// the principal carried by ctx.
+func (pq *PriorityQueue) DeleteItemsByParentPatternCtx(ctx context.Context, pattern string) (int, error) {
+	match, err := compileParentPattern(pattern)
+	if err != nil {
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return pq.deleteChunked(OpDeleteItemsByParentPattern, nil, func() ([]*QItem, error) {
+		pq.record(recorded{Op: OpDeleteItemsByParentPattern, ParentID: pattern})
+		items := pq.parentItems(pq.parentsMatching(match)...)
+		return items, pq.authorize(ctx, OpDeleteItemsByParentPattern, items...)
+	})
+}
+
+// PauseParentPattern is PauseParent for every ParentID matching pattern,
+// see UpdatePriorityByParentPattern, including ParentIDs first pushed
+// later. The items of their descendants are paused too. It fails for a
+// malformed pattern.
+func (pq *PriorityQueue) PauseParentPattern(pattern string) error {
+	match, err := compileParentPattern(pattern)
+	if err != nil {
+		return err
+	}
+	defer pq.lock(OpPauseParent)()
+	if pq.pausedMatch == nil {
+		pq.pausedMatch = make(map[string]parentPattern)
+	}
+	pq.pausedMatch[pattern] = match
+	pq.post(WebhookPayload{Event: EventPaused, ParentID: pattern})
+	return nil
+}
+
+// ResumeParentPattern releases the items paused by
+// PauseParentPattern(pattern), unless they are paused otherwise.
+func (pq *PriorityQueue) ResumeParentPattern(pattern string) {
+	defer pq.lock(OpResumeParent)()
+	delete(pq.pausedMatch, pattern)
+	pq.wake()
+}
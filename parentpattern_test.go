@@ -0,0 +1,48 @@
+package priorityqueue
+
+import "testing"
+
+func Test_ParentPattern(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "1", ParentID: "tenant-42/a", Priority: 1})
+	pq.Push(QItem{ID: "2", ParentID: "tenant-42/b", Priority: 2})
+	pq.Push(QItem{ID: "3", ParentID: "tenant-42/b/c", Priority: 3})
+	pq.Push(QItem{ID: "4", ParentID: "tenant-43", Priority: 4})
+	pq.Push(QItem{ID: "5", ParentID: "tenant-7", Priority: 5})
+
+	n, err := pq.UpdatePriorityByParentPattern("tenant-42/*", 10)
+	assertEqual(t, err, nil)
+	assertEqual(t, n, 2)
+	item, _ := pq.Peek()
+	assertEqual(t, item.Priority, 10)
+	assertEqual(t, item.ParentID != "tenant-42/b/c", true)
+
+	n, _ = pq.DeleteItemsByParentPattern("/^tenant-4[0-9]$/")
+	assertEqual(t, n, 1)
+	assertEqual(t, pq.Len(), 4)
+
+	_, err = pq.DeleteItemsByParentPattern("[")
+	assertEqual(t, err != nil, true)
+	_, err = pq.UpdatePriorityByParentPattern("/(/", 1)
+	assertEqual(t, err != nil, true)
+}
+
+func Test_PauseParentPattern(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "1", ParentID: "tenant-42/a", Priority: 3})
+	pq.Push(QItem{ID: "2", ParentID: "tenant-42/a/x", Priority: 2})
+	pq.Push(QItem{ID: "3", ParentID: "tenant-43", Priority: 1})
+
+	assertEqual(t, pq.PauseParentPattern("tenant-42/*"), nil)
+	pq.Push(QItem{ID: "4", ParentID: "tenant-42/b", Priority: 4})
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "3")
+	assertEqual(t, len(pq.PausedParents()), 1)
+
+	pq.ResumeParentPattern("tenant-42/*")
+	item, _ = pq.Pop()
+	assertEqual(t, item.ID, "4")
+	assertEqual(t, pq.Len(), 2)
+
+	assertEqual(t, pq.PauseParentPattern("[") != nil, true)
+}
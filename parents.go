@@ -0,0 +1,155 @@
+package priorityqueue
+
+import "context"
+
+// SetParentPriority gives parentID a base priority. The Priority of items
+// pushed for the parent afterwards is an offset from the base, so an item
+// pushed with Priority 2 for a parent with base 10 is queued with Priority
+// 12. Changing the base moves the priority of every item of the parent,
+// queued, delayed or in flight, by the difference in one pass, keeping their
+// offsets. It returns the number of items re-prioritized. The base applies
+// to parentID only, not to its descendants. Nothing changes if the
+// Authorizer denies the change of any item of the parent.
+func (pq *PriorityQueue) SetParentPriority(parentID string, base int) int {
+	defer pq.lock(OpSetParentPriority)()
+	if pq.authorize(context.Background(), OpSetParentPriority, pq.parentHeld(parentID)...) != nil {
+		return 0
+	}
+	if pq.parentBase == nil {
+		pq.parentBase = make(map[string]int)
+	}
+	n := pq.shiftParent(parentID, base-pq.parentBase[pq.idKey(parentID)])
+	pq.parentBase[pq.idKey(parentID)] = base
+	return n
+}
+
+// RemoveParentPriority removes the base priority of parentID. Its items keep
+// their offsets, as if the base had been set to zero.
+func (pq *PriorityQueue) RemoveParentPriority(parentID string) int {
+	defer pq.lock(OpSetParentPriority)()
+	if pq.authorize(context.Background(), OpSetParentPriority, pq.parentHeld(parentID)...) != nil {
+		return 0
+	}
+	n := pq.shiftParent(parentID, -pq.parentBase[pq.idKey(parentID)])
+	delete(pq.parentBase, pq.idKey(parentID))
+	return n
+}
+
+// ParentPriority returns the base priority of parentID, if it has one
+func (pq *PriorityQueue) ParentPriority(parentID string) (int, bool) {
+	defer pq.lock(OpStats)()
+	base, ok := pq.parentBase[pq.idKey(parentID)]
+	return base, ok
+}
+
+// SetParentCeiling caps the effective priority of the items of parentID,
+// so that the parent cannot jump to the front of the queue whatever the
+// priority it asks for. Priorities above the ceiling, pushed, set by the
+// priority updates or reached through the base priority of the parent, are
+// clamped to it, and counted in Stats().Clamped. Items queued before the
+// ceiling was set keep their priority. The ceiling applies to parentID
+// only, not to its descendants.
+func (pq *PriorityQueue) SetParentCeiling(parentID string, ceiling int) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.setParentCeiling(parentID, ceiling)
+}
+
+// setParentCeiling is SetParentCeiling; the queue lock must be held
+func (pq *PriorityQueue) setParentCeiling(parentID string, ceiling int) {
+	if pq.parentCeiling == nil {
+		pq.parentCeiling = make(map[string]int)
+	}
+	pq.parentCeiling[pq.idKey(parentID)] = ceiling
+}
+
+// RemoveParentCeiling lifts the priority ceiling of parentID
+func (pq *PriorityQueue) RemoveParentCeiling(parentID string) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	delete(pq.parentCeiling, pq.idKey(parentID))
+}
+
+// ParentCeiling returns the priority ceiling of parentID, if it has one
+func (pq *PriorityQueue) ParentCeiling(parentID string) (int, bool) {
+	defer pq.lock(OpStats)()
+	ceiling, ok := pq.parentCeiling[pq.idKey(parentID)]
+	return ceiling, ok
+}
+
+// capped returns priority clamped to the ceiling of parentID, counting the
+// clamping. The queue lock must be held.
+func (pq *PriorityQueue) capped(parentID string, priority int) int {
+	if ceiling, ok := pq.parentCeiling[pq.idKey(parentID)]; ok && priority > ceiling {
+		pq.clamped++
+		return ceiling
+	}
+	return priority
+}
+
+// inherit turns the priority of an item being pushed from an offset into
+// an absolute priority. The queue lock must be held.
+func (pq *PriorityQueue) inherit(i *QItem) {
+	if base, ok := pq.parentBase[pq.idKey(i.ParentID)]; ok {
+		i.Priority += base
+	}
+	i.Priority = pq.capped(i.ParentID, i.Priority)
+}
+
+// shiftParent adds delta to the priority of every item of parentID. The
+// queue lock must be held.
+func (pq *PriorityQueue) shiftParent(parentID string, delta int) int {
+	if delta == 0 {
+		return 0
+	}
+	items := pq.parentItems(parentID)
+	for _, item := range items {
+		pq.reprioritize(item, pq.capped(parentID, item.Priority+delta))
+		pq.audit(OpSetParentPriority, item)
+	}
+	n := len(items)
+	for k := range pq.delayed {
+		if i := &pq.delayed[k].v; pq.sameID(i.ParentID, parentID) {
+			i.Priority = pq.capped(parentID, i.Priority+delta)
+			n++
+		}
+	}
+	for _, l := range pq.leases {
+		if pq.sameID(l.item.ParentID, parentID) {
+			l.item.Priority = pq.capped(parentID, l.item.Priority+delta)
+			n++
+		}
+	}
+	for _, i := range pq.coalescing {
+		if pq.sameID(i.ParentID, parentID) {
+			i.Priority = pq.capped(parentID, i.Priority+delta)
+			n++
+		}
+	}
+	return n
+}
+
+// parentHeld returns every item of parentID, queued, delayed, in flight or
+// coalescing, for the Authorizer. The queue lock must be held.
+func (pq *PriorityQueue) parentHeld(parentID string) []*QItem {
+	if pq.authorizer == nil {
+		return nil
+	}
+	items := pq.parentItems(parentID)
+	for k := range pq.delayed {
+		if i := &pq.delayed[k].v; pq.sameID(i.ParentID, parentID) {
+			items = append(items, i)
+		}
+	}
+	for _, l := range pq.leases {
+		if pq.sameID(l.item.ParentID, parentID) {
+			items = append(items, &l.item)
+		}
+	}
+	for _, i := range pq.coalescing {
+		if pq.sameID(i.ParentID, parentID) {
+			items = append(items, i)
+		}
+	}
+	return items
+}
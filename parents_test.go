@@ -0,0 +1,71 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParentPriority(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetParentPriority("job", 10)
+	pq.Push(QItem{ID: "1", ParentID: "job", Priority: 1})
+	pq.Push(QItem{ID: "2", ParentID: "job", Priority: 2})
+	pq.Push(QItem{ID: "3", ParentID: "other", Priority: 5})
+
+	item, _ := pq.Peek()
+	assertEqual(t, item.Priority, 12)
+
+	assertEqual(t, pq.SetParentPriority("job", 0), 2)
+	item, _ = pq.Peek()
+	assertEqual(t, item.ID, "3")
+
+	pq.SetParentPriority("job", 20)
+	items, _ := pq.sortedItems(OpStats, false)
+	assertEqual(t, items[0].Priority, 22)
+	assertEqual(t, items[1].Priority, 21)
+
+	assertEqual(t, pq.RemoveParentPriority("job"), 2)
+	_, ok := pq.ParentPriority("job")
+	assertEqual(t, ok, false)
+	item, _ = pq.Peek()
+	assertEqual(t, item.ID, "3")
+}
+
+func Test_ParentPriorityInFlight(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetParentPriority("job", 10)
+	pq.Push(QItem{ID: "1", ParentID: "job", Priority: 1})
+	_, r, _ := pq.Lease(time.Minute)
+
+	assertEqual(t, pq.SetParentPriority("job", 30), 1)
+	pq.Nack(r, 0)
+	item, _ := pq.Peek()
+	assertEqual(t, item.Priority, 31)
+}
+
+func Test_ParentCeiling(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetParentCeiling("tenant", 50)
+	pq.Push(QItem{ID: "1", ParentID: "tenant", Priority: 1000})
+	pq.Push(QItem{ID: "2", ParentID: "tenant", Priority: 10})
+	pq.Push(QItem{ID: "3", ParentID: "other", Priority: 60})
+
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "3")
+	assertEqual(t, pq.Stats().Clamped, 1)
+
+	pq.UpdatePriorityByParentId("tenant", 100)
+	pq.SetParentPriority("tenant", 5)
+	items, _ := pq.sortedItems(OpStats, false)
+	assertEqual(t, items[0].ID, "3")
+	assertEqual(t, items[1].Priority, 50)
+	assertEqual(t, items[2].Priority, 50)
+	assertEqual(t, pq.Stats().Clamped, 5)
+
+	pq.RemoveParentCeiling("tenant")
+	_, ok := pq.ParentCeiling("tenant")
+	assertEqual(t, ok, false)
+	pq.Push(QItem{ID: "4", ParentID: "tenant", Priority: 1000})
+	item, _ = pq.Peek()
+	assertEqual(t, item.Priority, 1005)
+}
@@ -0,0 +1,217 @@
+// Package pqbench generates configurable workloads against a priority queue
+// and reports throughput and latency percentiles, so that queue
+// implementations can be compared under the same load.
+package pqbench
+
+import (
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+// A PriorityDistribution picks the priority of the next pushed item.
+type PriorityDistribution func(r *rand.Rand) int
+
+// Uniform returns priorities uniformly distributed in [0, max)
+func Uniform(max int) PriorityDistribution {
+	return func(r *rand.Rand) int {
+		return r.Intn(max)
+	}
+}
+
+// Zipf returns priorities in [0, max] skewed towards low values, which
+// resembles a backlog of mostly bulk work with occasional urgent items.
+func Zipf(s float64, max int) PriorityDistribution {
+	return func(r *rand.Rand) int {
+		return int(rand.NewZipf(r, s, 1, uint64(max)).Uint64())
+	}
+}
+
+// Bands returns priorities drawn from a fixed set of values, each chosen
+// with the same probability.
+func Bands(priorities ...int) PriorityDistribution {
+	return func(r *rand.Rand) int {
+		return priorities[r.Intn(len(priorities))]
+	}
+}
+
+// A Workload describes the load generated by Run.
+type Workload struct {
+	Producers int // Number of pushing goroutines
+	Consumers int // Number of popping goroutines
+	Items     int // Total number of items pushed across all producers
+	Parents   int // Number of distinct ParentIDs items are spread over
+
+	// Priorities picks the priority of each pushed item, Uniform(100) if nil.
+	Priorities PriorityDistribution
+
+	// Churn is the fraction of pushes followed by a priority update or a
+	// delete of one of the parents, in the range [0, 1].
+	Churn float64
+
+	Seed int64
+}
+
+// Percentiles summarizes a latency distribution.
+type Percentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// A Result reports what happened during a Run.
+type Result struct {
+	Duration   time.Duration
+	Pushes     int
+	Rejected   int // Pushes that returned an error
+	Pops       int
+	Updates    int
+	Deletes    int     // Number of items removed by churn deletes
+	Throughput float64 // Operations per second
+
+	PushLatency  Percentiles
+	PopLatency   Percentiles
+	ChurnLatency Percentiles
+}
+
+// Run applies w to q and blocks until every pushed item has been either
+// popped or deleted.
+func Run(q pq.Queue, w Workload) Result {
+	if w.Producers < 1 {
+		w.Producers = 1
+	}
+	if w.Consumers < 1 {
+		w.Consumers = 1
+	}
+	if w.Parents < 1 {
+		w.Parents = 1
+	}
+	if w.Priorities == nil {
+		w.Priorities = Uniform(100)
+	}
+
+	var (
+		wg            sync.WaitGroup
+		producersDone int32
+		pops          int64
+		m             sync.Mutex
+		result        Result
+		pushLat       []time.Duration
+		popLat        []time.Duration
+		churnLat      []time.Duration
+	)
+
+	start := time.Now()
+
+	for c := 0; c < w.Consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var lat []time.Duration
+			for {
+				t := time.Now()
+				_, err := q.Pop()
+				if err != nil {
+					if atomic.LoadInt32(&producersDone) == 1 && q.Len() == 0 {
+						break
+					}
+					runtime.Gosched()
+					continue
+				}
+				lat = append(lat, time.Since(t))
+				atomic.AddInt64(&pops, 1)
+			}
+			m.Lock()
+			popLat = append(popLat, lat...)
+			m.Unlock()
+		}()
+	}
+
+	var producers sync.WaitGroup
+	for p := 0; p < w.Producers; p++ {
+		n := w.Items / w.Producers
+		if p < w.Items%w.Producers {
+			n++
+		}
+		producers.Add(1)
+		go func(p, n int) {
+			defer producers.Done()
+			r := rand.New(rand.NewSource(w.Seed + int64(p)))
+			var lat, clat []time.Duration
+			pushes, rejected, updates, deletes := 0, 0, 0, 0
+			for i := 0; i < n; i++ {
+				item := pq.QItem{
+					ID:       strconv.Itoa(p) + "-" + strconv.Itoa(i),
+					ParentID: strconv.Itoa(r.Intn(w.Parents)),
+					Priority: w.Priorities(r),
+				}
+				t := time.Now()
+				err := q.Push(item)
+				lat = append(lat, time.Since(t))
+				if err != nil {
+					rejected++
+				} else {
+					pushes++
+				}
+
+				if w.Churn > 0 && r.Float64() < w.Churn {
+					parent := strconv.Itoa(r.Intn(w.Parents))
+					t = time.Now()
+					if r.Intn(2) == 0 {
+						updates += q.UpdatePriorityByParentId(parent, w.Priorities(r))
+					} else {
+						d, _ := q.DeleteItemsByParentId(parent)
+						deletes += d
+					}
+					clat = append(clat, time.Since(t))
+				}
+			}
+			m.Lock()
+			result.Pushes += pushes
+			result.Rejected += rejected
+			result.Updates += updates
+			result.Deletes += deletes
+			pushLat = append(pushLat, lat...)
+			churnLat = append(churnLat, clat...)
+			m.Unlock()
+		}(p, n)
+	}
+
+	producers.Wait()
+	atomic.StoreInt32(&producersDone, 1)
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	result.Pops = int(pops)
+	ops := len(pushLat) + len(popLat) + len(churnLat)
+	if result.Duration > 0 {
+		result.Throughput = float64(ops) / result.Duration.Seconds()
+	}
+	result.PushLatency = percentiles(pushLat)
+	result.PopLatency = percentiles(popLat)
+	result.ChurnLatency = percentiles(churnLat)
+	return result
+}
+
+func percentiles(d []time.Duration) Percentiles {
+	if len(d) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	at := func(p float64) time.Duration {
+		return d[int(p*float64(len(d)-1))]
+	}
+	return Percentiles{
+		P50: at(0.50),
+		P90: at(0.90),
+		P99: at(0.99),
+		Max: d[len(d)-1],
+	}
+}
@@ -0,0 +1,51 @@
+package pqbench
+
+import (
+	"testing"
+
+	pq "PriorityQueue"
+)
+
+func Test_Run(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	r := Run(q, Workload{
+		Producers:  4,
+		Consumers:  2,
+		Items:      1000,
+		Parents:    10,
+		Priorities: Zipf(1.5, 1000),
+		Churn:      0.05,
+		Seed:       1,
+	})
+	if r.Pushes != 1000 {
+		t.Errorf("Pushed %d items, expected 1000", r.Pushes)
+	}
+	if r.Pops+r.Deletes != r.Pushes {
+		t.Errorf("Popped %d and deleted %d items, expected %d in total", r.Pops, r.Deletes, r.Pushes)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Queue is not empty after Run()")
+	}
+	if r.PushLatency.P50 > r.PushLatency.Max {
+		t.Errorf("P50 push latency %v is above the max %v", r.PushLatency.P50, r.PushLatency.Max)
+	}
+}
+
+func Benchmark_Balanced(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Run(pq.NewPriorityQueue(), Workload{Producers: 4, Consumers: 4, Items: 10000, Parents: 100})
+	}
+}
+
+func Benchmark_BalancedSlab(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Run(pq.NewPriorityQueue(pq.WithSlabAllocator(1024)), Workload{Producers: 4, Consumers: 4, Items: 10000, Parents: 100})
+	}
+}
+
+func Benchmark_BalancedCompact(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Run(pq.NewCompactQueue(), Workload{Producers: 4, Consumers: 4, Items: 10000, Parents: 100})
+	}
+}
@@ -0,0 +1,287 @@
+// Package pqgossip shares the depth and rates of the queues of several
+// nodes by gossip, without a central broker, so producers can route new
+// items to the least loaded node.
+//
+// Every interval each node refreshes its own state from its queue and sends
+// its table of node states over UDP to a few random peers, which merge it
+// into theirs: a state replaces an older one of the same node, told apart
+// by its heartbeat counter. A node whose state stopped advancing for
+// DeadAfter is considered gone.
+//
+//	n, err := pqgossip.Start(q, pqgossip.Config{Name: "node-1", Bind: ":7946", Seeds: []string{"node-2:7946"}})
+//	defer n.Stop()
+//	target, ok := n.LeastLoaded()
+package pqgossip
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+// Defaults of the Config fields left zero
+const (
+	DefaultInterval  = time.Second
+	DefaultFanout    = 3
+	DefaultDeadAfter = 10 * time.Second
+)
+
+// maxPacket bounds the size of a gossip packet
+const maxPacket = 64 << 10
+
+// A Source is the queue of a node, such as a *priorityqueue.PriorityQueue
+type Source interface {
+	RoutingHint() pq.RoutingHint
+	StateCounts() map[pq.State]int
+}
+
+// Config configures a node
+type Config struct {
+	// Name identifies the node, unique in the cluster
+	Name string
+
+	// Bind is the UDP address to listen on, such as ":7946"
+	Bind string
+
+	// Advertise is the address the other nodes reach this one at, the
+	// address bound if empty
+	Advertise string
+
+	// Seeds are the addresses of nodes to gossip with first
+	Seeds []string
+
+	Interval  time.Duration // Time between gossip rounds
+	Fanout    int           // Peers gossiped with each round
+	DeadAfter time.Duration // Time after which a silent node is gone
+}
+
+// A NodeState is the load of one node as last heard of
+type NodeState struct {
+	Name string `json:"name"`
+	Addr string `json:"addr"`
+
+	pq.RoutingHint
+
+	PushRate  float64 `json:"push_rate"` // Items pushed per second
+	PopRate   float64 `json:"pop_rate"`  // Items completed per second
+	Heartbeat uint64  `json:"heartbeat"` // Incremented by the node every round
+
+	seen time.Time // When the heartbeat last advanced, locally
+}
+
+// A Node gossips the state of its queue with the other nodes
+type Node struct {
+	cfg  Config
+	src  Source
+	conn net.PacketConn
+
+	m      sync.Mutex
+	states map[string]*NodeState
+	last   struct {
+		at           time.Time
+		total, ended int
+	}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start starts a node gossiping the state of src
+func Start(src Source, cfg Config) (*Node, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("node name is empty")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Fanout <= 0 {
+		cfg.Fanout = DefaultFanout
+	}
+	if cfg.DeadAfter <= 0 {
+		cfg.DeadAfter = DefaultDeadAfter
+	}
+	conn, err := net.ListenPacket("udp", cfg.Bind)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Advertise == "" {
+		cfg.Advertise = conn.LocalAddr().String()
+	}
+	n := &Node{
+		cfg:    cfg,
+		src:    src,
+		conn:   conn,
+		states: make(map[string]*NodeState),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	n.refresh(time.Now())
+	go n.receive()
+	go n.run()
+	return n, nil
+}
+
+// Addr returns the address the node advertises
+func (n *Node) Addr() string {
+	return n.cfg.Advertise
+}
+
+// Stop stops gossiping and closes the socket
+func (n *Node) Stop() {
+	close(n.stop)
+	n.conn.Close()
+	<-n.done
+}
+
+func (n *Node) run() {
+	defer close(n.done)
+	t := time.NewTicker(n.cfg.Interval)
+	defer t.Stop()
+	n.gossip()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case now := <-t.C:
+			n.refresh(now)
+			n.gossip()
+		}
+	}
+}
+
+// refresh updates the state of this node from its queue
+func (n *Node) refresh(now time.Time) {
+	counts := n.src.StateCounts()
+	total, ended := 0, counts[pq.StatePopped]+counts[pq.StateAcked]
+	for _, c := range counts {
+		total += c
+	}
+	hint := n.src.RoutingHint()
+
+	n.m.Lock()
+	defer n.m.Unlock()
+	self := n.states[n.cfg.Name]
+	if self == nil {
+		self = &NodeState{Name: n.cfg.Name, Addr: n.cfg.Advertise}
+		n.states[n.cfg.Name] = self
+	}
+	if elapsed := now.Sub(n.last.at).Seconds(); !n.last.at.IsZero() && elapsed > 0 {
+		self.PushRate = float64(total-n.last.total) / elapsed
+		self.PopRate = float64(ended-n.last.ended) / elapsed
+	}
+	n.last.at, n.last.total, n.last.ended = now, total, ended
+	self.RoutingHint = hint
+	self.Heartbeat++
+	self.seen = now
+}
+
+// gossip sends the state table to a few random peers, and to the seeds
+// until some peer is known
+func (n *Node) gossip() {
+	n.m.Lock()
+	table := make([]NodeState, 0, len(n.states))
+	var peers []string
+	for _, s := range n.states {
+		table = append(table, *s)
+		if s.Name != n.cfg.Name && n.alive(s, time.Now()) {
+			peers = append(peers, s.Addr)
+		}
+	}
+	n.m.Unlock()
+
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > n.cfg.Fanout {
+		peers = peers[:n.cfg.Fanout]
+	}
+	if len(peers) == 0 {
+		peers = n.cfg.Seeds
+	}
+	msg, err := json.Marshal(table)
+	if err != nil || len(msg) > maxPacket {
+		return
+	}
+	for _, peer := range peers {
+		if addr, err := net.ResolveUDPAddr("udp", peer); err == nil {
+			n.conn.WriteTo(msg, addr)
+		}
+	}
+}
+
+func (n *Node) receive() {
+	buf := make([]byte, maxPacket)
+	for {
+		size, _, err := n.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var table []NodeState
+		if json.Unmarshal(buf[:size], &table) == nil {
+			n.merge(table, time.Now())
+		}
+	}
+}
+
+// merge takes in the newer states of a table received from a peer
+func (n *Node) merge(table []NodeState, now time.Time) {
+	n.m.Lock()
+	defer n.m.Unlock()
+	for _, s := range table {
+		if s.Name == n.cfg.Name || s.Name == "" {
+			continue
+		}
+		if old := n.states[s.Name]; old != nil && old.Heartbeat >= s.Heartbeat {
+			continue
+		}
+		s.seen = now
+		n.states[s.Name] = &s
+	}
+}
+
+func (n *Node) alive(s *NodeState, now time.Time) bool {
+	return s.Name == n.cfg.Name || now.Sub(s.seen) < n.cfg.DeadAfter
+}
+
+// Members returns the states of the live nodes, this one included, sorted
+// by name
+func (n *Node) Members() []NodeState {
+	n.m.Lock()
+	defer n.m.Unlock()
+	now := time.Now()
+	members := make([]NodeState, 0, len(n.states))
+	for name, s := range n.states {
+		if !n.alive(s, now) {
+			delete(n.states, name)
+			continue
+		}
+		members = append(members, *s)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members
+}
+
+// LeastLoaded returns the live node with the fewest waiting items, ties
+// broken by the fewest items in flight, then the lowest net inflow, then
+// by name
+func (n *Node) LeastLoaded() (NodeState, bool) {
+	members := n.Members()
+	if len(members) == 0 {
+		return NodeState{}, false
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		if a.InFlight != b.InFlight {
+			return a.InFlight < b.InFlight
+		}
+		return a.PushRate-a.PopRate < b.PushRate-b.PopRate
+	})
+	return members[0], true
+}
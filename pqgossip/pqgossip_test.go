@@ -0,0 +1,65 @@
+package pqgossip
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+func Test_Gossip(t *testing.T) {
+	var nodes []*Node
+	var seed string
+	for i, depth := range []int{5, 1, 3} {
+		q := pq.NewPriorityQueue()
+		for n := 0; n < depth; n++ {
+			q.Push(pq.QItem{ID: strconv.Itoa(n)})
+		}
+		cfg := Config{Name: "node-" + strconv.Itoa(i), Bind: "127.0.0.1:0", Interval: 10 * time.Millisecond}
+		if seed != "" {
+			cfg.Seeds = []string{seed}
+		}
+		n, err := Start(q, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer n.Stop()
+		if seed == "" {
+			seed = n.Addr()
+		}
+		nodes = append(nodes, n)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, n := range nodes {
+		for len(n.Members()) < 3 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s knows %d members", n.cfg.Name, len(n.Members()))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		best, ok := n.LeastLoaded()
+		if !ok || best.Name != "node-1" || best.Depth != 1 || !best.Head {
+			t.Errorf("%s routes to %+v", n.cfg.Name, best)
+		}
+	}
+}
+
+func Test_GossipForgetsDeadNodes(t *testing.T) {
+	n, err := Start(pq.NewPriorityQueue(), Config{Name: "a", Bind: "127.0.0.1:0", Interval: time.Hour, DeadAfter: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Stop()
+	n.merge([]NodeState{{Name: "b", Addr: "127.0.0.1:1", Heartbeat: 1}}, time.Now())
+	if len(n.Members()) != 2 {
+		t.Fatalf("Members %+v", n.Members())
+	}
+	// An older heartbeat does not revive it
+	time.Sleep(60 * time.Millisecond)
+	n.merge([]NodeState{{Name: "b", Heartbeat: 1}}, time.Now())
+	if m := n.Members(); len(m) != 1 || m[0].Name != "a" {
+		t.Errorf("Members %+v after b went silent", m)
+	}
+}
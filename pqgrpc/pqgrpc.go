@@ -0,0 +1,154 @@
+// Package pqgrpc streams the journal of a priority queue, an event for every
+// push, pop, update or delete, to external systems such as search indexes
+// and analytics pipelines, as the gRPC server-streaming method
+//
+//	priorityqueue.v1.Journal/Tail(TailRequest) returns (stream Event)
+//
+// defined in journal.proto, from which clients can be generated. The
+// handler speaks the gRPC protocol itself, without depending on a gRPC
+// runtime, and must be served over HTTP/2: with TLS, http.Server and
+// httptest negotiate it on their own.
+//
+//	srv := &http.Server{Addr: ":8443", Handler: pqgrpc.NewHandler(q, pqgrpc.Options{})}
+//	srv.ListenAndServeTLS(certFile, keyFile)
+//
+// A client falling too far behind is cut off with the UNAVAILABLE status,
+// so it knows it missed events and can tail again after resynchronizing.
+package pqgrpc
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pq "PriorityQueue"
+)
+
+// TailPath is the HTTP path of the Tail method
+const TailPath = "/priorityqueue.v1.Journal/Tail"
+
+// DefaultBuffer is the number of events a client may fall behind by when
+// Options.Buffer is zero.
+const DefaultBuffer = 1024
+
+// maxRequest bounds the size of a TailRequest
+const maxRequest = 64 << 10
+
+// gRPC status codes used by the handler
+const (
+	statusOK               = 0
+	statusInvalidArgument  = 3
+	statusPermissionDenied = 7
+	statusUnimplemented    = 12
+	statusUnavailable      = 14
+)
+
+// Options configure the handler returned by NewHandler
+type Options struct {
+	// Buffer is the number of events a client may fall behind by before it
+	// is cut off, DefaultBuffer if zero.
+	Buffer int
+
+	// Authorize, if set, decides whether a request may tail the journal,
+	// for instance on its "authorization" metadata, an HTTP header.
+	Authorize func(r *http.Request) bool
+}
+
+// NewHandler returns a handler serving the Tail method for q
+func NewHandler(q *pq.PriorityQueue, opts Options) http.Handler {
+	if opts.Buffer == 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	return &handler{q: q, opts: opts}
+}
+
+type handler struct {
+	q    *pq.PriorityQueue
+	opts Options
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	if r.URL.Path != TailPath {
+		status(w.Header(), statusUnimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+	if h.opts.Authorize != nil && !h.opts.Authorize(r) {
+		status(w.Header(), statusPermissionDenied, "not allowed to tail the journal")
+		return
+	}
+	req, err := readRequest(r.Body)
+	if err != nil {
+		status(w.Header(), statusInvalidArgument, err.Error())
+		return
+	}
+
+	events := h.q.Tail(r.Context(), h.opts.Buffer)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for e := range events {
+		if !req.match(e) {
+			continue
+		}
+		if err := writeMessage(w, marshalEvent(e)); err != nil {
+			return
+		}
+		if flusher != nil && len(events) == 0 {
+			flusher.Flush()
+		}
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+	status(w.Header(), statusUnavailable, "journal closed: the client fell behind or the queue was destroyed")
+}
+
+// status sets the gRPC status, as trailers once the body was written or as
+// a trailers-only response otherwise.
+func status(header http.Header, code int, message string) {
+	header.Set("Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		header.Set("Grpc-Message", url.PathEscape(message))
+	}
+}
+
+// readRequest reads the single length-prefixed message of a unary request
+func readRequest(r io.Reader) (tailRequest, error) {
+	var req tailRequest
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return req, err
+	}
+	if prefix[0] != 0 {
+		return req, errCompressed
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxRequest {
+		return req, errTooLarge
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return req, err
+	}
+	err := req.unmarshal(msg)
+	return req, err
+}
+
+// writeMessage writes an uncompressed length-prefixed message
+func writeMessage(w io.Writer, msg []byte) error {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
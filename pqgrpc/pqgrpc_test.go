@@ -0,0 +1,136 @@
+package pqgrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pq "PriorityQueue"
+)
+
+// tail starts a Tail call for req on srv, returning the response
+func tail(t *testing.T, ctx context.Context, srv *httptest.Server, req tailRequest) *http.Response {
+	var msg []byte
+	for _, op := range req.ops {
+		msg = appendString(msg, 1, string(op))
+	}
+	msg = appendString(msg, 2, req.parentID)
+	var body bytes.Buffer
+	writeMessage(&body, msg)
+
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+TailPath, &body)
+	r.Header.Set("Content-Type", "application/grpc")
+	resp, err := srv.Client().Do(r)
+	if err != nil {
+		t.Fatalf("Error calling Tail: %v", err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Error, Tail served over %s", resp.Proto)
+	}
+	return resp
+}
+
+// readEvent reads the next Event message of a Tail response
+func readEvent(t *testing.T, r io.Reader) pq.AuditEntry {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		t.Fatalf("Error reading an event: %v", err)
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	io.ReadFull(r, msg)
+
+	var e pq.AuditEntry
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		if key&7 == wireVarint {
+			v, n := binary.Uvarint(msg)
+			msg = msg[n:]
+			if key>>3 == 6 {
+				e.Priority = int(int64(v))
+			}
+			continue
+		}
+		s, rest, _ := consumeBytes(msg)
+		msg = rest
+		switch key >> 3 {
+		case 2:
+			e.Op = pq.Operation(s)
+		case 3:
+			e.ID = string(s)
+		case 4:
+			e.ParentID = string(s)
+		}
+	}
+	return e
+}
+
+func newServer(q *pq.PriorityQueue, opts Options) *httptest.Server {
+	srv := httptest.NewUnstartedServer(NewHandler(q, opts))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	return srv
+}
+
+func Test_Tail(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	srv := newServer(q, Options{})
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp := tail(t, ctx, srv, tailRequest{ops: []pq.Operation{pq.OpPush, pq.OpPop}, parentID: "a"})
+	defer resp.Body.Close()
+	q.Push(item("1", "a", 5))
+	q.Push(item("2", "b", 7))
+	q.Push(item("3", "a", -2))
+	q.DeleteItemById("3")
+	q.Pop()
+	q.Pop()
+
+	for _, want := range []pq.AuditEntry{
+		{Op: pq.OpPush, ID: "1", ParentID: "a", Priority: 5},
+		{Op: pq.OpPush, ID: "3", ParentID: "a", Priority: -2},
+		{Op: pq.OpPop, ID: "1", ParentID: "a", Priority: 5},
+	} {
+		if got := readEvent(t, resp.Body); got != want {
+			t.Errorf("Error, got event %+v, want %+v", got, want)
+		}
+	}
+}
+
+func Test_TailClosed(t *testing.T) {
+	q := pq.NewPriorityQueue()
+	srv := newServer(q, Options{Authorize: func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == ""
+	}})
+	defer srv.Close()
+
+	resp := tail(t, context.Background(), srv, tailRequest{})
+	q.Push(item("1", "a", 1))
+	readEvent(t, resp.Body)
+	q.Destroy()
+	readEvent(t, resp.Body) // The Destroy entry
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if got := resp.Trailer.Get("Grpc-Status"); got != "14" {
+		t.Errorf("Error, closed journal ended with status %q", got)
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, srv.URL+TailPath, nil)
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("Authorization", "Bearer x")
+	resp, _ = srv.Client().Do(r)
+	resp.Body.Close()
+	if got := resp.Header.Get("Grpc-Status"); got != "7" {
+		t.Errorf("Error, unauthorized request ended with status %q", got)
+	}
+}
+
+func item(id, parentID string, priority int) pq.QItem {
+	return pq.QItem{ID: id, ParentID: parentID, Priority: priority}
+}
@@ -0,0 +1,143 @@
+package pqgrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	pq "PriorityQueue"
+)
+
+// The messages of journal.proto are encoded by hand, they are too small to
+// be worth a dependency on the protobuf runtime.
+
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+var (
+	errTruncated  = errors.New("truncated message")
+	errCompressed = errors.New("compressed messages are not supported")
+	errTooLarge   = errors.New("request too large")
+)
+
+// A tailRequest selects the events streamed by Tail
+type tailRequest struct {
+	ops      []pq.Operation // Operations to stream, every one if empty
+	parentID string         // Only the events of this ParentID, if set
+}
+
+func (r tailRequest) match(e pq.AuditEntry) bool {
+	if r.parentID != "" && e.ParentID != r.parentID {
+		return false
+	}
+	if len(r.ops) == 0 {
+		return true
+	}
+	for _, op := range r.ops {
+		if e.Op == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *tailRequest) unmarshal(b []byte) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		if field, wire := key>>3, key&7; wire == wireBytes && (field == 1 || field == 2) {
+			s, rest, err := consumeBytes(b)
+			if err != nil {
+				return err
+			}
+			if field == 1 {
+				r.ops = append(r.ops, pq.Operation(s))
+			} else {
+				r.parentID = string(s)
+			}
+			b = rest
+			continue
+		}
+		rest, err := skip(b, key&7)
+		if err != nil {
+			return err
+		}
+		b = rest
+	}
+	return nil
+}
+
+// marshalEvent encodes an audit entry as an Event message
+func marshalEvent(e pq.AuditEntry) []byte {
+	var b []byte
+	if !e.Time.IsZero() {
+		b = appendVarint(b, 1, uint64(e.Time.UnixNano()))
+	}
+	b = appendString(b, 2, string(e.Op))
+	b = appendString(b, 3, e.ID)
+	b = appendString(b, 4, e.ParentID)
+	b = appendString(b, 5, e.Tenant)
+	if e.Priority != 0 {
+		b = appendVarint(b, 6, uint64(int64(e.Priority)))
+	}
+	b = appendString(b, 7, e.Producer)
+	b = appendString(b, 8, e.Detail)
+	if e.Position != 0 {
+		b = appendVarint(b, 9, e.Position)
+	}
+	return b
+}
+
+func appendVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendString appends a string field, omitted when empty as proto3 does
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func consumeBytes(b []byte) ([]byte, []byte, error) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < l {
+		return nil, nil, errTruncated
+	}
+	return b[n : n+int(l)], b[n+int(l):], nil
+}
+
+// skip passes over the value of an unknown field
+func skip(b []byte, wire uint64) ([]byte, error) {
+	switch wire {
+	case wireVarint:
+		if _, n := binary.Uvarint(b); n > 0 {
+			return b[n:], nil
+		}
+	case wireI64:
+		if len(b) >= 8 {
+			return b[8:], nil
+		}
+	case wireI32:
+		if len(b) >= 4 {
+			return b[4:], nil
+		}
+	case wireBytes:
+		_, rest, err := consumeBytes(b)
+		return rest, err
+	default:
+		return nil, fmt.Errorf("unsupported wire type %d", wire)
+	}
+	return nil, errTruncated
+}
@@ -0,0 +1,137 @@
+// Package pqmetrics exports priority queue statistics to monitoring systems:
+// an OpenMetrics text endpoint, which Prometheus can scrape, and a StatsD
+// exporter with optional Datadog style tags.
+package pqmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	pq "PriorityQueue"
+)
+
+// A StatsSource is anything reporting queue statistics, such as a
+// *priorityqueue.PriorityQueue.
+type StatsSource interface {
+	Stats() pq.Stats
+}
+
+// A Queue names a statistics source for export
+type Queue struct {
+	Name   string
+	Source StatsSource
+
+	// Watchdog, if set, adds the queue's lock hold time histograms
+	Watchdog *pq.Watchdog
+}
+
+// ContentType is the media type of WriteOpenMetrics output
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Handler returns an http.Handler serving the statistics of queues in the
+// OpenMetrics text format.
+func Handler(queues ...Queue) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		WriteOpenMetrics(w, queues...)
+	})
+}
+
+// metric accumulates the samples of one metric family
+type metric struct {
+	name, kind, help string
+	samples          []string
+}
+
+func (m *metric) add(suffix string, labels []string, value interface{}) {
+	m.samples = append(m.samples, fmt.Sprintf("%s%s{%s} %v", m.name, suffix, strings.Join(labels, ","), value))
+}
+
+func label(name, value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return fmt.Sprintf(`%s="%s"`, name, r.Replace(value))
+}
+
+// WriteOpenMetrics writes the statistics of queues to w in the OpenMetrics
+// text format.
+func WriteOpenMetrics(w io.Writer, queues ...Queue) error {
+	items := &metric{name: "pq_items", kind: "gauge", help: "Number of queued items."}
+	byProducer := &metric{name: "pq_producer_items", kind: "gauge", help: "Number of queued items per producer."}
+	rejected := &metric{name: "pq_producer_rejected", kind: "counter", help: "Pushes refused by producer limits."}
+	held := &metric{name: "pq_lock_hold_seconds", kind: "histogram", help: "Time operations held the queue lock."}
+	priorities := &metric{name: "pq_item_priority", kind: "gaugehistogram", help: "Priorities of the queued items."}
+
+	for _, q := range queues {
+		s := q.Source.Stats()
+		ql := label("queue", q.Name)
+		items.add("", []string{ql}, s.Len)
+		for _, p := range sortedKeys(s.ByProducer) {
+			byProducer.add("", []string{ql, label("producer", p)}, s.ByProducer[p])
+		}
+		producers := make([]string, 0, len(s.Rejected))
+		for p := range s.Rejected {
+			producers = append(producers, p)
+		}
+		sort.Strings(producers)
+		for _, p := range producers {
+			r := s.Rejected[p]
+			rejected.add("_total", []string{ql, label("producer", p), label("reason", "rate_limited")}, r.RateLimited)
+			rejected.add("_total", []string{ql, label("producer", p), label("reason", "quota_exceeded")}, r.QuotaExceeded)
+		}
+		if h := s.Priorities; len(h.Counts) > 0 {
+			cumulative := 0
+			for n, bound := range h.Bounds {
+				cumulative += h.Counts[n]
+				priorities.add("_bucket", []string{ql, label("le", fmt.Sprint(bound))}, cumulative)
+			}
+			priorities.add("_bucket", []string{ql, label("le", "+Inf")}, s.Len)
+			priorities.add("_gcount", []string{ql}, s.Len)
+		}
+		if q.Watchdog != nil {
+			hists := q.Watchdog.Histograms()
+			ops := make([]string, 0, len(hists))
+			for op := range hists {
+				ops = append(ops, string(op))
+			}
+			sort.Strings(ops)
+			for _, op := range ops {
+				h := hists[pq.Operation(op)]
+				labels := []string{ql, label("op", op)}
+				var cumulative uint64
+				for n, bound := range h.Bounds {
+					cumulative += h.Counts[n]
+					held.add("_bucket", append(labels, label("le", fmt.Sprint(bound.Seconds()))), cumulative)
+				}
+				held.add("_bucket", append(labels, label("le", "+Inf")), h.Count)
+				held.add("_count", labels, h.Count)
+				held.add("_sum", labels, h.Sum.Seconds())
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, m := range []*metric{items, byProducer, rejected, held, priorities} {
+		if len(m.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n# HELP %s %s\n", m.name, m.kind, m.name, m.help)
+		for _, s := range m.samples {
+			fmt.Fprintln(bw, s)
+		}
+	}
+	fmt.Fprintln(bw, "# EOF")
+	return bw.Flush()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,97 @@
+package pqmetrics
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+func testQueue() *pq.PriorityQueue {
+	q := pq.NewPriorityQueue()
+	q.SetProducerLimit("bulk", pq.ProducerLimit{Quota: 1})
+	q.Push(pq.QItem{ID: "a", Producer: "bulk"})
+	q.Push(pq.QItem{ID: "b", Producer: "bulk"})
+	q.Push(pq.QItem{ID: "c", Producer: "web"})
+	return q
+}
+
+func Test_WriteOpenMetrics(t *testing.T) {
+	q := testQueue()
+	w := pq.NewWatchdog(0, nil)
+	q.SetWatchdog(w)
+	q.Len()
+
+	var buf bytes.Buffer
+	if err := WriteOpenMetrics(&buf, Queue{Name: "jobs", Source: q, Watchdog: w}); err != nil {
+		t.Fatalf("Error writing metrics: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE pq_items gauge\n",
+		`pq_items{queue="jobs"} 2`,
+		`pq_producer_items{queue="jobs",producer="web"} 1`,
+		`pq_producer_rejected_total{queue="jobs",producer="bulk",reason="quota_exceeded"} 1`,
+		`pq_lock_hold_seconds_bucket{queue="jobs",op="Len",le="+Inf"} 1`,
+		"# TYPE pq_item_priority gaugehistogram\n",
+		`pq_item_priority_bucket{queue="jobs",le="0"} 2`,
+		`pq_item_priority_gcount{queue="jobs"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Metrics are missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Errorf("Metrics do not end with # EOF")
+	}
+}
+
+func Test_StatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on UDP: %v", err)
+	}
+	defer conn.Close()
+
+	s := &StatsD{Addr: conn.LocalAddr().String(), Prefix: "svc.", Datadog: true, Queues: []Queue{{Name: "jobs", Source: testQueue()}}}
+	defer s.Close()
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Error reading packet: %v", err)
+	}
+	packet := string(buf[:n])
+	for _, want := range []string{
+		"svc.pq.items:2|g|#queue:jobs\n",
+		"svc.pq.producer_items:1|g|#queue:jobs,producer:bulk\n",
+		"svc.pq.producer_rejected:1|c|#queue:jobs,producer:bulk\n",
+	} {
+		if !strings.Contains(packet, want) {
+			t.Errorf("Packet is missing %q:\n%s", want, packet)
+		}
+	}
+
+	// Rejections are only sent again when they increase
+	s.Flush()
+	n, _, _ = conn.ReadFrom(buf)
+	if strings.Contains(string(buf[:n]), "rejected") {
+		t.Errorf("Unchanged rejections were sent again")
+	}
+}
+
+func Test_StatsDPlainNames(t *testing.T) {
+	var buf bytes.Buffer
+	s := &StatsD{}
+	s.line(&buf, "producer_items", 3, "g", "my.queue", "web")
+	if buf.String() != "pq.my_queue.producer_items.web:3|g\n" {
+		t.Errorf("Unexpected StatsD line %q", buf.String())
+	}
+}
@@ -0,0 +1,144 @@
+package pqmetrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// StatsD periodically sends queue statistics to a StatsD server over UDP.
+// Depths are sent as gauges and producer rejections as counters of the
+// rejections since the previous flush.
+type StatsD struct {
+	Addr     string        // host:port of the StatsD server
+	Prefix   string        // Prepended to metric names, e.g. "myservice."
+	Interval time.Duration // Time between flushes, 10 seconds if zero
+	Queues   []Queue
+
+	// Datadog sends the queue and producer as DogStatsD tags instead of
+	// encoding them into the metric names.
+	Datadog bool
+
+	conn     net.Conn
+	rejected map[string]int
+}
+
+// Run flushes statistics every Interval until ctx is done
+func (s *StatsD) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	defer s.Close()
+	for {
+		if err := s.Flush(); err != nil {
+			return err
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close releases the connection to the server
+func (s *StatsD) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Flush sends the current statistics once
+func (s *StatsD) Flush() error {
+	if s.conn == nil {
+		conn, err := net.Dial("udp", s.Addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if s.rejected == nil {
+		s.rejected = make(map[string]int)
+	}
+
+	var buf bytes.Buffer
+	for _, q := range s.Queues {
+		st := q.Source.Stats()
+		s.line(&buf, "items", st.Len, "g", q.Name, "")
+		for _, p := range sortedKeys(st.ByProducer) {
+			s.line(&buf, "producer_items", st.ByProducer[p], "g", q.Name, p)
+		}
+		for p, r := range st.Rejected {
+			key := q.Name + "\x00" + p
+			total := r.RateLimited + r.QuotaExceeded
+			if delta := total - s.rejected[key]; delta > 0 {
+				s.line(&buf, "producer_rejected", delta, "c", q.Name, p)
+			}
+			s.rejected[key] = total
+		}
+	}
+	// Keep each datagram within a typical MTU
+	for _, packet := range split(buf.String(), 1400) {
+		if _, err := s.conn.Write([]byte(packet)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// line appends one metric in StatsD or DogStatsD syntax
+func (s *StatsD) line(buf *bytes.Buffer, name string, value int, kind, queue, producer string) {
+	if s.Datadog {
+		fmt.Fprintf(buf, "%spq.%s:%d|%s|#queue:%s", s.Prefix, name, value, kind, sanitize(queue))
+		if producer != "" {
+			fmt.Fprintf(buf, ",producer:%s", sanitize(producer))
+		}
+		buf.WriteByte('\n')
+		return
+	}
+	key := "pq." + sanitize(queue) + "." + name
+	if producer != "" {
+		key += "." + sanitize(producer)
+	}
+	fmt.Fprintf(buf, "%s%s:%d|%s\n", s.Prefix, key, value, kind)
+}
+
+// sanitize replaces characters with a meaning in StatsD syntax
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', '\n', ' ', '.':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// split groups lines into packets of at most max bytes
+func split(lines string, max int) []string {
+	var packets []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(lines, "\n") {
+		if line == "" {
+			continue
+		}
+		if current.Len() > 0 && current.Len()+len(line) > max {
+			packets = append(packets, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		packets = append(packets, current.String())
+	}
+	return packets
+}
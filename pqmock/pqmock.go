@@ -0,0 +1,214 @@
+// Package pqmock provides a fake priorityqueue.Queue for unit testing code
+// that depends on a queue. The fake records every call, can return scripted
+// Pop results and can inject errors into specific calls.
+package pqmock
+
+import (
+	"fmt"
+	"sync"
+
+	pq "PriorityQueue"
+)
+
+// A Call records one method invocation on a Fake.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+type popResult struct {
+	item *pq.QItem
+	err  error
+}
+
+// Fake is an in-memory priorityqueue.Queue that keeps its items in a plain
+// slice ordered by priority. It is safe for concurrent use.
+type Fake struct {
+	m      sync.Mutex
+	items  []pq.QItem
+	calls  []Call
+	counts map[string]int
+	pops   []popResult
+	errs   map[string]map[int]error
+}
+
+var _ pq.Queue = (*Fake)(nil)
+
+// New returns an empty Fake
+func New() *Fake {
+	return &Fake{
+		counts: make(map[string]int),
+		errs:   make(map[string]map[int]error),
+	}
+}
+
+// ScriptPop queues a result for a future Pop call. Scripted results are
+// returned in the order they were added, before any pushed items.
+func (f *Fake) ScriptPop(item *pq.QItem, err error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.pops = append(f.pops, popResult{item: item, err: err})
+}
+
+// FailCall makes the nth (starting at 1) call of method return err. Only
+// methods returning an error can fail: Push, Pop, Peek, DeleteItemById and
+// DeleteItemsByParentId.
+func (f *Fake) FailCall(method string, n int, err error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if f.errs[method] == nil {
+		f.errs[method] = make(map[int]error)
+	}
+	f.errs[method][n] = err
+}
+
+// Calls returns every call made so far, in order
+func (f *Fake) Calls() []Call {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallCount returns how many times method has been called
+func (f *Fake) CallCount(method string) int {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return f.counts[method]
+}
+
+// Reset forgets recorded calls, scripted results, injected errors and items
+func (f *Fake) Reset() {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.items = nil
+	f.calls = nil
+	f.pops = nil
+	f.counts = make(map[string]int)
+	f.errs = make(map[string]map[int]error)
+}
+
+// record logs a call and returns the error injected for it, if any
+func (f *Fake) record(method string, args ...interface{}) error {
+	f.calls = append(f.calls, Call{Method: method, Args: args})
+	f.counts[method]++
+	return f.errs[method][f.counts[method]]
+}
+
+func (f *Fake) Push(i pq.QItem) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if err := f.record("Push", i); err != nil {
+		return err
+	}
+	f.insert(i)
+	return nil
+}
+
+func (f *Fake) Pop() (*pq.QItem, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if err := f.record("Pop"); err != nil {
+		return nil, err
+	}
+	if len(f.pops) > 0 {
+		r := f.pops[0]
+		f.pops = f.pops[1:]
+		return r.item, r.err
+	}
+	if len(f.items) == 0 {
+		return nil, pq.ErrEmptyQueue
+	}
+	item := f.items[0]
+	f.items = f.items[1:]
+	return &item, nil
+}
+
+func (f *Fake) Peek() (*pq.QItem, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if err := f.record("Peek"); err != nil {
+		return nil, err
+	}
+	if len(f.items) == 0 {
+		return nil, pq.ErrEmptyQueue
+	}
+	item := f.items[0]
+	return &item, nil
+}
+
+func (f *Fake) Len() int {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.record("Len")
+	return len(f.items)
+}
+
+func (f *Fake) Clear() {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.record("Clear")
+	f.items = nil
+}
+
+func (f *Fake) UpdatePriorityByParentId(parentID string, priority int) int {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.record("UpdatePriorityByParentId", parentID, priority)
+	var updated, rest []pq.QItem
+	for _, item := range f.items {
+		if item.ParentID == parentID {
+			item.Priority = priority
+			updated = append(updated, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	f.items = rest
+	for _, item := range updated {
+		f.insert(item)
+	}
+	return len(updated)
+}
+
+func (f *Fake) DeleteItemById(id string) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if err := f.record("DeleteItemById", id); err != nil {
+		return err
+	}
+	for n, item := range f.items {
+		if item.ID == id {
+			f.items = append(f.items[:n], f.items[n+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: [%s]", pq.ErrNotFound, id)
+}
+
+func (f *Fake) DeleteItemsByParentId(parentID string) (int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if err := f.record("DeleteItemsByParentId", parentID); err != nil {
+		return 0, err
+	}
+	rest := f.items[:0]
+	for _, item := range f.items {
+		if item.ParentID != parentID {
+			rest = append(rest, item)
+		}
+	}
+	deleted := len(f.items) - len(rest)
+	f.items = rest
+	return deleted, nil
+}
+
+// insert places item after every item of equal or higher priority
+func (f *Fake) insert(i pq.QItem) {
+	n := len(f.items)
+	for n > 0 && f.items[n-1].Priority < i.Priority {
+		n--
+	}
+	f.items = append(f.items, pq.QItem{})
+	copy(f.items[n+1:], f.items[n:])
+	f.items[n] = i
+}
@@ -0,0 +1,72 @@
+package pqmock
+
+import (
+	"errors"
+	"testing"
+
+	pq "PriorityQueue"
+)
+
+func Test_FakeOrdering(t *testing.T) {
+	f := New()
+	f.Push(pq.QItem{ID: "low", Priority: 1})
+	f.Push(pq.QItem{ID: "high", Priority: 10})
+	f.Push(pq.QItem{ID: "high2", Priority: 10})
+
+	for _, id := range []string{"high", "high2", "low"} {
+		x, err := f.Pop()
+		if err != nil {
+			t.Fatalf("Error popping item: %v", err)
+		}
+		if x.ID != id {
+			t.Errorf("Popped %s, expected %s", x.ID, id)
+		}
+	}
+	if _, err := f.Pop(); err != pq.ErrEmptyQueue {
+		t.Errorf("Pop on an empty fake returned %v, expected ErrEmptyQueue", err)
+	}
+}
+
+func Test_FakeScriptedPops(t *testing.T) {
+	f := New()
+	f.Push(pq.QItem{ID: "pushed", Priority: 1})
+	f.ScriptPop(&pq.QItem{ID: "scripted"}, nil)
+	f.ScriptPop(nil, pq.ErrEmptyQueue)
+
+	x, _ := f.Pop()
+	if x.ID != "scripted" {
+		t.Errorf("Popped %s, expected the scripted item", x.ID)
+	}
+	if _, err := f.Pop(); err != pq.ErrEmptyQueue {
+		t.Errorf("Expected the scripted ErrEmptyQueue, got %v", err)
+	}
+	x, _ = f.Pop()
+	if x.ID != "pushed" {
+		t.Errorf("Popped %s, expected the pushed item", x.ID)
+	}
+}
+
+func Test_FakeInjectedErrors(t *testing.T) {
+	f := New()
+	boom := errors.New("boom")
+	f.FailCall("DeleteItemById", 2, boom)
+	f.Push(pq.QItem{ID: "a"})
+	f.Push(pq.QItem{ID: "b"})
+
+	if err := f.DeleteItemById("a"); err != nil {
+		t.Errorf("First delete failed: %v", err)
+	}
+	if err := f.DeleteItemById("b"); err != boom {
+		t.Errorf("Second delete returned %v, expected the injected error", err)
+	}
+	if f.Len() != 1 {
+		t.Errorf("Failed delete should not remove the item")
+	}
+	if f.CallCount("DeleteItemById") != 2 {
+		t.Errorf("Recorded %d deletes, expected 2", f.CallCount("DeleteItemById"))
+	}
+	calls := f.Calls()
+	if calls[len(calls)-1].Method != "Len" {
+		t.Errorf("Last recorded call is %s, expected Len", calls[len(calls)-1].Method)
+	}
+}
@@ -0,0 +1,382 @@
+// Package pqs3 stores priority queue snapshots in S3 compatible object
+// storage: Amazon S3, MinIO, or Google Cloud Storage through its XML API
+// with HMAC keys. A Store is a priorityqueue.BlobStore, keeping snapshots
+// as well as the Values offloaded by SetBlobStore:
+//
+//	store := &pqs3.Store{Endpoint: "https://s3.eu-west-1.amazonaws.com",
+//		Region: "eu-west-1", Bucket: "backups", AccessKey: id, SecretKey: secret}
+//	err := q.SnapshotTo(ctx, store, "jobs/latest")
+//
+// Snapshots larger than a part are uploaded in parts. Every request carries
+// a SHA-256 checksum the service verifies, and downloads are checked
+// against the checksum stored with the object.
+package pqs3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	pq "PriorityQueue"
+)
+
+// DefaultPartSize is the size of the parts of a multipart upload when
+// Store.PartSize is zero
+const DefaultPartSize = 8 << 20
+
+// ErrChecksumMismatch is returned when reading an object whose content does
+// not match the checksum stored with it.
+var ErrChecksumMismatch = errors.New("object checksum mismatch")
+
+// A Store keeps objects in a bucket of an S3 compatible service
+type Store struct {
+	// Endpoint is the URL of the service, such as
+	// "https://s3.us-east-1.amazonaws.com" or "https://storage.googleapis.com".
+	// Objects are addressed as Endpoint/Bucket/key, or as Endpoint/key if
+	// Bucket is empty because the endpoint names the bucket.
+	Endpoint string
+	Region   string // "us-east-1" if empty, "auto" for GCS
+	Bucket   string
+	Prefix   string // Prepended to every key
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // For temporary credentials
+
+	// PartSize is the size of the parts of a multipart upload, used for
+	// objects larger than one part. S3 requires at least 5 MiB.
+	PartSize int
+
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+
+	now func() time.Time
+}
+
+// The metadata recording the part size of a multipart upload, which the
+// checksum of the object depends on
+const partSizeHeader = "X-Amz-Meta-Pq-Part-Size"
+
+// Put stores what r reads under key, in one request or as a multipart upload
+func (s *Store) Put(ctx context.Context, key string, r io.Reader) error {
+	size := s.PartSize
+	if size <= 0 {
+		size = DefaultPartSize
+	}
+	first := make([]byte, size)
+	n, err := io.ReadFull(r, first)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.putObject(ctx, key, first[:n])
+	}
+	if err != nil {
+		return err
+	}
+	return s.putMultipart(ctx, key, first, r)
+}
+
+func (s *Store) putObject(ctx context.Context, key string, body []byte) error {
+	req, err := s.request(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Amz-Checksum-Sha256", checksum(body))
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type completedPart struct {
+	PartNumber     int
+	ETag           string
+	ChecksumSHA256 string
+}
+
+func (s *Store) putMultipart(ctx context.Context, key string, first []byte, r io.Reader) (err error) {
+	uploadID, err := s.createUpload(ctx, key, len(first))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			s.abortUpload(key, uploadID)
+		}
+	}()
+
+	var parts []completedPart
+	buf := first
+	for n := 1; ; n++ {
+		part, err := s.uploadPart(ctx, key, uploadID, n, buf)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+		if len(buf) < len(first) {
+			break
+		}
+		buf = first
+		read, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		buf = buf[:read]
+	}
+	return s.completeUpload(ctx, key, uploadID, parts)
+}
+
+func (s *Store) createUpload(ctx context.Context, key string, partSize int) (string, error) {
+	req, err := s.request(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Amz-Checksum-Algorithm", "SHA256")
+	req.Header.Set(partSizeHeader, strconv.Itoa(partSize))
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("reading the multipart upload of [%s]: %w", key, err)
+	}
+	return result.UploadID, nil
+}
+
+func (s *Store) uploadPart(ctx context.Context, key, uploadID string, n int, body []byte) (completedPart, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+	req, err := s.request(ctx, http.MethodPut, key, query, body)
+	if err != nil {
+		return completedPart{}, err
+	}
+	sum := checksum(body)
+	req.Header.Set("X-Amz-Checksum-Sha256", sum)
+	resp, err := s.do(req)
+	if err != nil {
+		return completedPart{}, err
+	}
+	resp.Body.Close()
+	return completedPart{PartNumber: n, ETag: resp.Header.Get("ETag"), ChecksumSHA256: sum}, nil
+}
+
+func (s *Store) completeUpload(ctx context.Context, key, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// The service reports some failures in the body of a 200 response
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("completing the upload of [%s]: %s: %s", key, result.Code, result.Message)
+	}
+	return nil
+}
+
+// abortUpload discards the parts of a failed upload, on a fresh context as
+// the one of the upload may be what failed it.
+func (s *Store) abortUpload(key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := s.request(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+	if err != nil {
+		return
+	}
+	if resp, err := s.do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// Get returns a reader of the object stored under key. Reading returns
+// ErrChecksumMismatch at the end of an object that does not match its
+// checksum.
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Amz-Checksum-Mode", "ENABLED")
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	want := resp.Header.Get("X-Amz-Checksum-Sha256")
+	if want == "" {
+		return resp.Body, nil
+	}
+	v := &verifier{body: resp.Body, want: want, part: sha256.New()}
+	if sum, count, ok := strings.Cut(want, "-"); ok {
+		// The checksum of a multipart object is the checksum of the
+		// checksums of its parts
+		v.want, v.composite = sum, sha256.New()
+		v.partSize, _ = strconv.Atoi(resp.Header.Get(partSizeHeader))
+		if n, err := strconv.Atoi(count); err != nil || v.partSize <= 0 || n <= 0 {
+			return resp.Body, nil // cannot be verified
+		}
+	}
+	return v, nil
+}
+
+// Delete removes the object stored under key. Deleting a missing object
+// succeeds, as S3 does.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// A verifier checks the checksum of an object as it is read
+type verifier struct {
+	body      io.ReadCloser
+	want      string
+	part      hash.Hash
+	composite hash.Hash // nil unless the object was uploaded in parts
+	partSize  int
+	inPart    int
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.body.Read(p)
+	for b := p[:n]; len(b) > 0; {
+		chunk := len(b)
+		if v.composite != nil && v.inPart+chunk > v.partSize {
+			chunk = v.partSize - v.inPart
+		}
+		v.part.Write(b[:chunk])
+		v.inPart += chunk
+		b = b[chunk:]
+		if v.composite != nil && v.inPart == v.partSize {
+			v.composite.Write(v.part.Sum(nil))
+			v.part.Reset()
+			v.inPart = 0
+		}
+	}
+	if err == io.EOF {
+		sum := v.part
+		if v.composite != nil {
+			if v.inPart > 0 {
+				v.composite.Write(v.part.Sum(nil))
+			}
+			sum = v.composite
+		}
+		if base64.StdEncoding.EncodeToString(sum.Sum(nil)) != v.want {
+			return n, ErrChecksumMismatch
+		}
+	}
+	return n, err
+}
+
+func (v *verifier) Close() error {
+	return v.body.Close()
+}
+
+// request returns a signed request for the object key
+func (s *Store) request(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint [%s]: %w", s.Endpoint, err)
+	}
+	path := s.Prefix + key
+	if s.Bucket != "" {
+		path = s.Bucket + "/" + path
+	}
+	base := strings.TrimSuffix(u.Path, "/")
+	u.Path = base + "/" + path
+	u.RawPath = uriEncode(base, false) + "/" + uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if body != nil {
+		req.Header.Set("Content-Md5", contentMD5(body))
+	}
+	return req, nil
+}
+
+// do signs and sends req, turning error responses into errors. The headers
+// of req must be complete.
+func (s *Store) do(req *http.Request) (*http.Response, error) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	sign(req, s.AccessKey, s.SecretKey, region, now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+		if resp.StatusCode == http.StatusNotFound {
+			err = fmt.Errorf("%w: %v", pq.ErrObjectNotFound, err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// checksum returns the base64 SHA-256 checksum of body
+func checksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -2,8 +2,14 @@ package priorityqueue
 
 import (
 	"container/heap"
+	"context"
+	"errors"
 	"fmt"
+	"math/bits"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // An QItem is something we manage in a Priority queue.
@@ -13,8 +19,41 @@ type QItem struct {
 	Value    interface{} // The value of the item; can hold any type.
 	Priority int         // The Priority of the item in the queue.
 
+	Tenant   string    // Tenant owning the item, see Scoped.
+	Producer string    // Optional label of the service that pushed the item.
+	PushedAt time.Time // When the item was pushed, set by Push when zero.
+
+	ExpiresAt time.Time // When a queued item is dropped unpopped, zero for never.
+	Attempts  int       // Number of times the item has been leased.
+	Cost      int       // Share of a Dispatcher's Budget the item takes, 1 if zero.
+
+	IdempotencyKey string // Identifies retries of the same work, see SetDedupeWindow.
+	Merged         int    // Number of pushes merged into the item, see SetContentHash.
+	SyncToken      string // Version of the item in an external source, see Reconcile.
+
+	// Level names the priority of an item being pushed, see
+	// SetPriorityLevels. Push sets Priority from it, ignoring the Priority
+	// given, and clears it.
+	Level string
+
+	state State // Lifecycle state, see State.
+	phase int   // Barrier phase within the parent, see PushBarrier.
+
 	// The index is needed by update and is maintained by the heap.Interface methods.
 	index int // The index of the item in the heap.
+
+	tombstone Operation // The bulk delete removing the item, see deleteChunked.
+	seq       uint64    // Number of the item in insertion order.
+	round     uint64    // Turn of the item among its parent's, see WithRoundRobin.
+	age       int       // The index of the item in the age index.
+}
+
+// Seq returns the number of the item in the order items were queued,
+// counting from 1: unlike PushedAt, which producers may set, it increases
+// strictly with every item queued. Items queued again, such as nacked
+// ones, get a new number. It is zero for items never queued.
+func (i QItem) Seq() uint64 {
+	return i.seq
 }
 
 // A QItems implements heap.Interface and holds QItems.
@@ -24,88 +63,1013 @@ type PriorityQueue struct {
 	m         sync.Mutex
 	available bool
 	data      QItems
+	watchdog  *Watchdog
+
+	auditLog     func(AuditEntry)
+	auditEntries []AuditEntry
+	tails        map[*tail]struct{}
+	position     uint64 // Number of item changes made, see Position
+
+	// Removed items waiting to be handed to the Archiver, see unlock
+	archiver        Archiver
+	archived        []ArchivedItem
+	archiveFailures int
+
+	// Number of queued items per Producer label and Tenant, the queued
+	// items of each ParentID and the queued items by age, see OldestN
+	byProducer map[string]int
+	byTenant   map[string]int
+	byParent   map[string]itemSet
+	byAge      ageIndex
+
+	depth *depthHistory
+
+	// Closed and cleared when an item is inserted, to wake PopWait
+	pushed chan struct{}
+
+	limits   map[string]*producerLimiter
+	rejected map[string]*Rejections
+
+	authorizer   Authorizer
+	healthChecks map[string]HealthCheck
+
+	// Item lifecycle, see lifecycle.go and lease.go
+	clock           func() time.Time
+	states          map[string]State
+	stateTotals     [numStates]int
+	retired         []string
+	stateRetention  int
+	delayed         timers[QItem]
+	expiries        timers[*QItem]
+	leases          map[uint64]*lease
+	leaseTimers     timers[uint64]
+	leaseSeq        uint64
+	pushSeq         uint64
+	maxAttempts     int
+	redeliveryBoost RedeliveryBoost
+	deadLetters     []DeadLetter
+	history         map[string][]Attempt
+
+	freezeWindows map[string]FreezeWindow
+	freeze        *freezeState
+	priorities    *PriorityHistogram
+	destroyed     bool
+	stopped       bool
+	bgCtx         context.Context
+	bgCancel      context.CancelFunc
+	bg            sync.WaitGroup
+	bgErr         error
+	stopSweep     context.CancelFunc
+	stopRetention context.CancelFunc
+	retention     []RetentionRule
+	atStop        []func() error
+	finalizers    []func() error
+	finalized     bool
+	tieBreak      TieBreak
+	rounds        map[string]uint64 // Last turn given to each parent, see WithRoundRobin
+	servedRound   map[int]uint64    // Turn of the last item served, by priority
+	recycleWindow time.Duration
+	recycled      map[string]*recycled
+	recycleOrder  []*recycled
+	snapshotPath  string
+	chunkSize     int
+	tombstones    int
+	slab          *itemSlab
+	headNotify    chan<- QItem
+	jobs          map[JobID]*JobStatus
+	jobsDone      []JobID
+	jobSeq        JobID
+	byPriority    map[int]int
+	minPriority   int
+	minStale      bool
+	admission     []AdmissionPolicy
+	maxInFlight   int
+
+	stopEscalation context.CancelFunc
+	escalation     []EscalationRule
+	scorer         Scorer
+	transformers   []Transformer
+	importMode     ImportMode
+	blobStore      BlobStore
+	blobThreshold  int
+	valueSizer     func(value interface{}) int
+	usage          map[string]*Usage
+	usageSince     time.Time
+	stopRescore    context.CancelFunc
+	webhooks       *webhooks
+
+	dedupeWindow time.Duration
+	completed    map[string]time.Time
+	completions  []completion
+	deduplicated int
+
+	// Items held back to merge later pushes of their ID, see coalesce.go
+	coalesceDelay  time.Duration
+	coalescing     map[string]*QItem
+	coalesceTimers timers[string]
+	coalesced      int
+
+	// Queued items by the hash of their Value, see contenthash.go
+	contentHash func(value interface{}) string
+	contentTTL  time.Duration
+	hashes      map[string]hashedItem
+	hashesKept  int
+	merged      int
+
+	pausedParents map[string]bool
+	pausedMatch   map[string]parentPattern
+	parentBase    map[string]int
+	parentCeiling map[string]int
+	levels        []PriorityLevel
+	idGen         func() string
+	normalizeID   func(string) string
+	compositeKeys bool
+	clamped       int
+	recorder      *recorder
+
+	groups       map[string]*group
+	barriers     map[string]*barriers
+	onParentDone func(ParentProgress)
+
+	// Items queued, delayed or in flight and who waits for none to be left,
+	// see quiescence.go
+	outstanding int
+	emptied     chan struct{}
+	onEmpty     func()
+
+	// Priority floor of a draining queue, see drain.go
+	drainFloor *drainFloor
+	diverted   int
+
+	// Wait-time objective tracked, see slo.go
+	slo *sloTracker
+
+	// View of the head published for lock-free reads, see topview.go
+	topK    int
+	topView atomic.Pointer[TopView]
+
+	// Faults injected for resilience tests, see chaos.go
+	chaos atomic.Pointer[chaos]
+
+	// Consumers waiting for the lock ahead of producers, see fairness.go
+	consumerPriority atomic.Pointer[consumerPriority]
+
+	// Sampled operations while a profile runs, see profiler.go
+	profiler *profiler
+
+	// Priority updates waiting to be applied together, see fixbatch.go
+	batchWindow time.Duration
+	staged      map[*QItem]stagedPriority
+	stagedDue   time.Time
+
+	// Priority change limits and the changes deferred, see flap.go
+	changeLimit     PriorityChangeLimit
+	flaps           map[*QItem]*flapState
+	flapsKept       int
+	flapTimers      timers[*QItem]
+	changesDeferred int
+
+	// Called after the lock is released, see unlock
+	deferred []func()
+
+	// Scheduling seam for deterministic concurrency tests, see lock
+	sched func(op Operation, point schedPoint)
 }
 
-func NewPriorityQueue() *PriorityQueue {
+// A schedPoint is where an operation reports to the test scheduler
+type schedPoint int
+
+const (
+	schedAcquire schedPoint = iota // Before acquiring the queue lock
+	schedRelease                   // After releasing the queue lock
+)
+
+// ErrEmptyQueue is returned by Pop and Peek when the queue holds no items.
+var ErrEmptyQueue = errors.New("queue is empty, nothing to Pop")
+
+// ErrNotFound is wrapped by the errors returned when no item has the given ID.
+var ErrNotFound = errors.New("ID Not found")
+
+// ErrQueueDestroyed is returned by the methods of a queue after Destroy
+var ErrQueueDestroyed = errors.New("queue is destroyed")
+
+// An Operation names a queue method for instrumentation purposes.
+type Operation string
+
+const (
+	OpPush                     Operation = "Push"
+	OpPop                      Operation = "Pop"
+	OpPeek                     Operation = "Peek"
+	OpLen                      Operation = "Len"
+	OpClear                    Operation = "Clear"
+	OpUpdatePriorityByParentId Operation = "UpdatePriorityByParentId"
+	OpDeleteItemById           Operation = "DeleteItemById"
+	OpDeleteItemsByParentId    Operation = "DeleteItemsByParentId"
+	OpUpdatePriorityByIds      Operation = "UpdatePriorityByIds"
+	OpSnapshot                 Operation = "Snapshot"
+	OpRestore                  Operation = "Restore"
+	OpExport                   Operation = "Export"
+	OpImport                   Operation = "Import"
+	OpStats                    Operation = "Stats"
+	OpHealthy                  Operation = "Healthy"
+	OpState                    Operation = "State"
+	OpPromote                  Operation = "Promote"
+	OpExpire                   Operation = "Expire"
+	OpLease                    Operation = "Lease"
+	OpLeaseExpired             Operation = "LeaseExpired"
+	OpExtendLease              Operation = "ExtendLease"
+	OpAck                      Operation = "Ack"
+	OpNack                     Operation = "Nack"
+	OpDeadLetter               Operation = "DeadLetter"
+	OpRedrive                  Operation = "Redrive"
+	OpReserve                  Operation = "Reserve"
+	OpCommit                   Operation = "Commit"
+	OpRelease                  Operation = "Release"
+	OpDedupe                   Operation = "Dedupe"
+
+	OpUpdatePriorityByParentTree Operation = "UpdatePriorityByParentTree"
+	OpDeleteItemsByParentTree    Operation = "DeleteItemsByParentTree"
+	OpPauseParent                Operation = "PauseParent"
+	OpResumeParent               Operation = "ResumeParent"
+	OpSetParentPriority          Operation = "SetParentPriority"
+	OpPushBarrier                Operation = "PushBarrier"
+	OpDestroy                    Operation = "Destroy"
+	OpSweep                      Operation = "Sweep"
+	OpUpdateConfig               Operation = "UpdateConfig"
+	OpDeleteWhere                Operation = "DeleteWhere"
+	OpFlush                      Operation = "Flush"
+	OpRetention                  Operation = "Retention"
+	OpCoalesce                   Operation = "Coalesce"
+	OpEscalate                   Operation = "Escalate"
+	OpMerge                      Operation = "Merge"
+	OpPurge                      Operation = "Purge"
+
+	OpUpdatePriorityByParentPattern Operation = "UpdatePriorityByParentPattern"
+	OpDeleteItemsByParentPattern    Operation = "DeleteItemsByParentPattern"
+	OpForceRelease                  Operation = "ForceRelease"
+	OpSteal                         Operation = "Steal"
+	OpSeed                          Operation = "Seed"
+	OpReconcile                     Operation = "Reconcile"
+	OpRestoreDeleted                Operation = "RestoreDeleted"
+	OpRescore                       Operation = "Rescore"
+	OpDeferredPriority              Operation = "DeferredPriority"
+	OpSplit                         Operation = "Split"
+	OpReshard                       Operation = "Reshard"
+)
+
+// NewPriorityQueue returns an empty queue configured by opts. It panics if
+// an option is invalid, use New to get the error instead.
+func NewPriorityQueue(opts ...Option) *PriorityQueue {
+	pq, err := New(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return pq
+}
+
+func newPriorityQueue() *PriorityQueue {
 
 	var pq PriorityQueue
 
 	// Initialize our heap backing store
 	pq.data = make(QItems, 0)
-	heap.Init(&pq.data)
+	pq.byProducer = make(map[string]int)
+	pq.byTenant = make(map[string]int)
+	pq.byParent = make(map[string]itemSet)
+	pq.priorities = newPriorityHistogram(DefaultPriorityBounds)
 
 	return &pq
 }
 
-// Destroy clears the queue and destroys the underlying storage
+// Destroy deletes every item the queue holds, queued, delayed, in flight or
+// dead-lettered, and releases its storage, timers and waiters. Afterwards
+// the methods returning an error fail with ErrQueueDestroyed and the others
+// report an empty queue. The goroutines owned by the queue are canceled,
+// use Stop before Destroy to wait for them. The finalizers registered with
+// AddFinalizer run unless Stop ran them already. Destroying a destroyed
+// queue does nothing.
 func (pq *PriorityQueue) Destroy() {
-	pq.Clear()
-	pq.data = nil
+	pq.destroy()
+	pq.finalize()
 }
 
-func (pq *PriorityQueue) Len() int {
-	pq.m.Lock()
-	defer pq.m.Unlock()
-	return pq.data.Len()
+// destroy is Destroy but for the finalizers
+func (pq *PriorityQueue) destroy() {
+	defer pq.lock(OpDestroy)()
+	if pq.destroyed {
+		return
+	}
+	pq.destroyed = true
+	pq.halt()
+	for n := len(pq.data) - 1; n >= 0; n-- {
+		if pq.data[n].tombstone != "" {
+			pq.purge(n)
+		} else {
+			pq.audit(OpDestroy, pq.remove(n, StateDeleted))
+		}
+	}
+	for _, t := range pq.delayed {
+		pq.transition(&t.v, StateDeleted)
+	}
+	for _, i := range pq.coalescing {
+		pq.transition(i, StateDeleted)
+	}
+	for _, l := range pq.leases {
+		pq.transition(&l.item, StateDeleted)
+	}
+	for n := range pq.deadLetters {
+		pq.transition(&pq.deadLetters[n].Item, StateDeleted)
+	}
+	pq.data, pq.delayed, pq.expiries = nil, nil, nil
+	pq.coalescing, pq.coalesceTimers = nil, nil
+	pq.flaps, pq.flapTimers = nil, nil
+	pq.hashes = nil
+	pq.leases, pq.leaseTimers, pq.deadLetters, pq.history = nil, nil, nil, nil
+	pq.byProducer, pq.byTenant, pq.byParent, pq.byAge = nil, nil, nil, nil
+	pq.priorities, pq.byPriority = nil, nil
+	pq.completed, pq.completions = nil, nil
+	pq.pausedParents, pq.parentBase, pq.groups, pq.barriers = nil, nil, nil, nil
+	pq.recycled, pq.recycleOrder = nil, nil
+	pq.recorder = nil
+	if pq.freeze != nil {
+		close(pq.freeze.thawed)
+		pq.freeze = nil
+	}
+	pq.wake()
 }
 
-func (pq *PriorityQueue) Push(i QItem) {
+// lock acquires the queue mutex on behalf of op and returns the function
+// that releases it. Delayed items that fell due, expired items and expired
+// leases are dealt with first. Hooks observing the operation, the Watchdog
+// and the audit log, are called after the mutex has been released.
+func (pq *PriorityQueue) lock(op Operation) func() {
+	unlock, _ := pq.acquire(context.Background(), op)
+	return unlock
+}
 
-	pq.m.Lock()
-	defer pq.m.Unlock()
-	heap.Push(&pq.data, i)
+// lockCtx is lock for an operation made on behalf of ctx. It gives up with
+// the error of ctx once ctx is done, rather than waiting indefinitely for a
+// contended mutex or for a frozen queue to thaw.
+func (pq *PriorityQueue) lockCtx(ctx context.Context, op Operation) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return pq.acquire(ctx, op)
+}
 
+// acquire is lock, giving up once ctx is done
+func (pq *PriorityQueue) acquire(ctx context.Context, op Operation) (func(), error) {
+	if pq.sched != nil {
+		pq.sched(op, schedAcquire)
+	}
+	pq.chaosDelay()
+	acquired, err := pq.yield(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	err = pq.lockMutex(ctx)
+	acquired()
+	if err != nil {
+		return nil, err
+	}
+	for f := pq.freeze; f != nil && f.blocks(op); f = pq.freeze {
+		pq.m.Unlock()
+		select {
+		case <-f.thawed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if err := pq.lockMutex(ctx); err != nil {
+			return nil, err
+		}
+	}
+	pq.applyStagedBefore(op)
+	// Timers do not fire while frozen, the queue must not change under a backup
+	if pq.slab != nil && len(pq.slab.pending) > 0 {
+		pq.slab.recycle()
+	}
+	if pq.freeze == nil && pq.timersPending() {
+		pq.advance(pq.now())
+	}
+	if pq.watchdog == nil && !pq.auditing() && pq.depth == nil && pq.onParentDone == nil && pq.onEmpty == nil && pq.sched == nil && pq.archiver == nil && pq.topK == 0 && pq.webhooks == nil && pq.blobStore == nil && pq.profiler == nil && pq.slo == nil {
+		return pq.m.Unlock, nil
+	}
+	pq.profileBegin()
+	start := time.Now()
+	return func() {
+		pq.unlock(op, time.Since(start))
+	}, nil
 }
 
-func (pq *PriorityQueue) Pop() (*QItem, error) {
-	if pq.data.Len() > 0 {
+// lockMutex acquires the queue mutex. Should ctx be done first, it gives up
+// with the error of ctx and the mutex is released as soon as it is acquired.
+func (pq *PriorityQueue) lockMutex(ctx context.Context) error {
+	if ctx.Done() == nil {
 		pq.m.Lock()
-		defer pq.m.Unlock()
-		r := heap.Pop(&pq.data)
-		return r.(*QItem), nil
+		return nil
+	}
+	if pq.m.TryLock() {
+		return nil
+	}
+	acquired := make(chan struct{})
+	go func() {
+		pq.m.Lock()
+		select {
+		case acquired <- struct{}{}:
+		case <-ctx.Done():
+			pq.m.Unlock()
+		}
+	}()
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return nil, fmt.Errorf("queue is empty, nothing to Pop")
 }
 
-// UpdatePriorityById() updates the priority of an item in the queue
-func (pq *PriorityQueue) UpdatePriorityByParentId(parentID string, priority int) int {
-	pq.m.Lock()
-	defer pq.m.Unlock()
-	index := -1
-	itemsUpdated := 0
-	// Walk every item in the queue
+func (pq *PriorityQueue) unlock(op Operation, held time.Duration) {
+	pq.profileEnd(op, held)
+	if pq.depth != nil {
+		pq.depth.sample(time.Now(), pq.size())
+	}
+	if pq.topK > 0 {
+		pq.refreshTop()
+	}
+	if pq.webhooks != nil {
+		pq.watermark()
+	}
+	if len(pq.tails) > 0 {
+		pq.journal(pq.auditEntries)
+	}
+	w, auditLog, entries, deferred, sched := pq.watchdog, pq.auditLog, pq.auditEntries, pq.deferred, pq.sched
+	archiver, archived := pq.archiver, pq.archived
+	pq.auditEntries, pq.deferred, pq.archived = nil, nil, nil
+	pq.m.Unlock()
+	if sched != nil {
+		sched(op, schedRelease)
+	}
+
+	if w != nil {
+		w.observe(op, held)
+	}
+	if auditLog != nil {
+		for _, e := range entries {
+			auditLog(e)
+		}
+	}
+	if len(archived) > 0 {
+		pq.sendArchived(archiver, archived)
+	}
+	for _, fn := range deferred {
+		fn()
+	}
+}
+
+// stamp prepares an item handed in by a caller: it records the push time of
+// an item that does not carry one yet and forgets the lifecycle state copied
+// along with an item returned earlier.
+func stamp(i *QItem) {
+	i.state = StateUnknown
+	if i.PushedAt.IsZero() {
+		i.PushedAt = time.Now()
+	}
+}
+
+// insert adds an item to the heap and to the queue's bookkeeping and returns
+// the stored item. The queue lock must be held.
+func (pq *PriorityQueue) insert(i QItem) *QItem {
+	n := len(pq.data)
+	item := pq.newItem(i)
+	item.index = n
+	pq.data = append(pq.data, item)
+	pq.enqueued(item)
+	pq.data.fix(n, pq.tieBreak)
+	pq.profileFixup(item)
+	if pq.headNotify != nil && item.index == 0 {
+		pq.newHead(item)
+	}
+	pq.wake()
+	return item
+}
+
+// enqueued records an item just added to the heap. The queue lock must be held.
+func (pq *PriorityQueue) enqueued(item *QItem) {
+	pq.pushSeq++
+	item.seq = pq.pushSeq
+	pq.track(item)
+	pq.takeTurn(item)
+	pq.transition(item, StateQueued)
+	if !item.ExpiresAt.IsZero() {
+		heap.Push(&pq.expiries, timer[*QItem]{at: item.ExpiresAt, v: item})
+	}
+}
+
+// wake releases the goroutines waiting for an item. The queue lock must be held.
+func (pq *PriorityQueue) wake() {
+	if pq.pushed != nil {
+		close(pq.pushed)
+		pq.pushed = nil
+	}
+}
+
+// waitPushed returns a channel closed when the next item is inserted. The
+// queue lock must be held.
+func (pq *PriorityQueue) waitPushed() <-chan struct{} {
+	if pq.pushed == nil {
+		pq.pushed = make(chan struct{})
+	}
+	return pq.pushed
+}
+
+// remove takes the item at index out of the heap and the queue's
+// bookkeeping, moving it to state to. The queue lock must be held.
+func (pq *PriorityQueue) remove(index int, to State) *QItem {
+	item := pq.data.remove(index, pq.tieBreak)
+	pq.profileFixup(item)
+	pq.untrack(item)
+	pq.served(item, to)
+	pq.transition(item, to)
+	if pq.slab != nil {
+		pq.slab.release(item)
+	}
+	return item
+}
+
+func (pq *PriorityQueue) track(item *QItem) {
+	if pq.byProducer == nil {
+		pq.byProducer = make(map[string]int)
+		pq.byTenant = make(map[string]int)
+		pq.byParent = make(map[string]itemSet)
+	}
+	pq.countPriority(item.Priority, 1)
+	pq.byProducer[item.Producer]++
+	pq.byTenant[item.Tenant]++
+	key := pq.idKey(item.ParentID)
+	s, ok := pq.byParent[key]
+	if !ok {
+		s = make(itemSet)
+		pq.byParent[key] = s
+	}
+	s[item] = struct{}{}
+	heap.Push(&pq.byAge, item)
+}
+
+func (pq *PriorityQueue) untrack(item *QItem) {
+	decrement(pq.byProducer, item.Producer)
+	decrement(pq.byTenant, item.Tenant)
+	pq.countPriority(item.Priority, -1)
+	key := pq.idKey(item.ParentID)
+	if s := pq.byParent[key]; s != nil {
+		delete(s, item)
+		if len(s) == 0 {
+			delete(pq.byParent, key)
+		}
+	}
+	if item.age >= 0 && item.age < len(pq.byAge) && pq.byAge[item.age] == item {
+		heap.Remove(&pq.byAge, item.age)
+	}
+}
+
+// holds reports whether item is still queued and not tombstoned. The queue
+// lock must be held.
+func (pq *PriorityQueue) holds(item *QItem) bool {
+	return item.tombstone == "" && pq.inHeap(item)
+}
+
+// inHeap reports whether item is still in the heap. The queue lock must be
+// held.
+func (pq *PriorityQueue) inHeap(item *QItem) bool {
+	return item.index >= 0 && item.index < len(pq.data) && pq.data[item.index] == item
+}
+
+// size is the number of queued items. The queue lock must be held.
+func (pq *PriorityQueue) size() int {
+	return len(pq.data) - pq.tombstones
+}
+
+// An itemSet holds queued items
+type itemSet map[*QItem]struct{}
+
+// parentItems returns the queued items of the given ParentIDs, in heap order.
+// The queue lock must be held.
+func (pq *PriorityQueue) parentItems(parentIDs ...string) []*QItem {
+	var items []*QItem
+	for _, parentID := range parentIDs {
+		for item := range pq.byParent[pq.idKey(parentID)] {
+			if item.tombstone == "" {
+				items = append(items, item)
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].index < items[j].index
+	})
+	return items
+}
+
+func decrement(counts map[string]int, key string) {
+	counts[key]--
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// collect returns the queued items matching pred. The queue lock must be held.
+func (pq *PriorityQueue) collect(pred func(*QItem) bool) []*QItem {
+	var items []*QItem
 	for _, element := range pq.data {
-		if element.ParentID == parentID {
-			index = element.index
-			pq.data.update(pq.data[index], priority)
-			itemsUpdated++
+		if element.tombstone == "" && pred(element) {
+			items = append(items, element)
+		}
+	}
+	return items
+}
+
+// updatePriorities sets the priority of items collected from the queue,
+// staging the updates if they are batched. The queue lock must be held.
+func (pq *PriorityQueue) updatePriorities(op Operation, items []*QItem, priority int) int {
+	for _, item := range items {
+		p := pq.limitChange(item, pq.capped(item.ParentID, priority), pq.now())
+		pq.stage(item, p)
+		audited := *item
+		audited.Priority = p
+		pq.audit(op, &audited)
+	}
+	return len(items)
+}
+
+func (pq *PriorityQueue) Len() int {
+	defer pq.lock(OpLen)()
+	return pq.size()
+}
+
+// LenCtx is Len failing with the error of ctx if ctx is done
+func (pq *PriorityQueue) LenCtx(ctx context.Context) (int, error) {
+	unlock, err := pq.lockCtx(ctx, OpLen)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	return pq.size(), nil
+}
+
+// Push adds an item to the queue. It fails if the producer limits set for
+// the item's Producer label would be exceeded. An item without an ID is
+// given one, see SetIDGenerator; PushInfo returns it.
+func (pq *PriorityQueue) Push(i QItem) error {
+	return pq.PushCtx(context.Background(), i)
+}
+
+// PushCtx is Push on behalf of the principal carried by ctx. Once ctx is
+// done it gives up with the error of ctx, such as context.DeadlineExceeded,
+// rather than waiting for a contended or frozen queue.
+func (pq *PriorityQueue) PushCtx(ctx context.Context, i QItem) error {
+	_, err := pq.PushInfoCtx(ctx, i)
+	return err
+}
+
+// A PushResult describes where a pushed item landed
+type PushResult struct {
+	ID    string // ID generated for an item pushed without one
+	Seq   uint64 // Number of the item in push order, counting from 1
+	Depth int    // Number of queued items, the pushed one included
+	Head  bool   // Whether the item went to the head of the queue, preempting the others
+
+	// Suppressed reports an item dropped as a duplicate, see
+	// SetDedupeWindow. The other fields are zero then.
+	Suppressed bool
+
+	// Coalesced reports an item held back or merged into the held item of
+	// its ID, see SetCoalesceDelay. The other fields are zero then.
+	Coalesced bool
+
+	// Merged reports an item merged into a queued item with the same
+	// content hash, see SetContentHash. The other fields are zero then.
+	Merged bool
+
+	// Diverted reports an item below the priority floor of a draining
+	// queue pushed to another queue, see StartDraining. The other fields
+	// are zero then, but for the ID.
+	Diverted bool
+}
+
+// PushInfo is Push reporting where the item landed, so producers can log it
+// and see when they preempted queued work without a Peek.
+func (pq *PriorityQueue) PushInfo(i QItem) (PushResult, error) {
+	return pq.PushInfoCtx(context.Background(), i)
+}
+
+// PushInfoCtx is PushInfo on behalf of the principal carried by ctx
+func (pq *PriorityQueue) PushInfoCtx(ctx context.Context, i QItem) (PushResult, error) {
+	unlock, err := pq.lockCtx(ctx, OpPush)
+	if err != nil {
+		return PushResult{}, err
+	}
+	defer unlock()
+	if err := pq.mutable(); err != nil {
+		return PushResult{}, err
+	}
+	pq.record(recorded{Op: OpPush, Item: recordItem(&i)})
+	var res PushResult
+	generated := i.ID == ""
+	raw := i.Value
+	size, ok, err := pq.prepare(ctx, &i)
+	if generated {
+		res.ID = i.ID
+	}
+	if !ok {
+		res.Suppressed = err == nil
+		res.Diverted = err == errDiverted
+		return res, undiverted(err)
+	}
+	// Merged pushes are hashed as the producer sent them and never stored
+	coalescing := pq.coalesceDelay > 0 || pq.coalescing[pq.itemKey(&i)] != nil
+	var hash string
+	if !coalescing {
+		var merged bool
+		if hash, merged = pq.mergeContent(&i, raw); merged {
+			pq.meterPush(i.ParentID, size)
+			res.Merged = true
+			return res, nil
+		}
+	}
+	if ok, err := pq.store(&i, size); !ok {
+		return res, err
+	}
+	if coalescing {
+		pq.coalesce(i)
+		res.Coalesced = true
+		return res, nil
+	}
+	item := pq.insert(i)
+	pq.rememberContent(hash, item)
+	pq.audit(OpPush, item)
+	res.Seq, res.Depth, res.Head = item.seq, pq.size(), item.index == 0
+	return res, nil
+}
+
+// admit runs the checks of a push of i and prepares it for insertion. It
+// returns false if the item must not be queued, with the reason unless the
+// item was suppressed as a duplicate. The queue lock must be held.
+func (pq *PriorityQueue) admit(ctx context.Context, i *QItem) (bool, error) {
+	size, ok, err := pq.prepare(ctx, i)
+	if !ok {
+		return false, err
+	}
+	return pq.store(i, size)
+}
+
+// prepare is admit but for the offload of the Value and the metering of the
+// push, left to store, so a push merged into a queued item stores nothing.
+// It returns the size of the Value pushed. The queue lock must be held.
+func (pq *PriorityQueue) prepare(ctx context.Context, i *QItem) (int, bool, error) {
+	if err := pq.authorize(ctx, OpPush, i); err != nil {
+		return 0, false, err
+	}
+	if err := pq.resolveLevel(i); err != nil {
+		return 0, false, err
+	}
+	pq.score(i)
+	pq.assignID(i)
+	if err := pq.admitFloor(i); err != nil {
+		return 0, false, err
+	}
+	size := pq.valueSize(i.Value)
+	if err := pq.transform(i); err != nil {
+		return 0, false, err
+	}
+	if pq.isDuplicate(i) {
+		pq.suppress(i)
+		return 0, false, nil
+	}
+	if err := pq.admitProducer(i.Producer); err != nil {
+		return 0, false, err
+	}
+	stamp(i)
+	pq.inherit(i)
+	pq.assignPhase(i)
+	return size, true, nil
+}
+
+// store offloads the Value of an item prepared for queueing and meters the
+// push of size bytes. The queue lock must be held.
+func (pq *PriorityQueue) store(i *QItem, size int) (bool, error) {
+	if err := pq.offload(i); err != nil {
+		return false, err
+	}
+	pq.meterPush(i.ParentID, size)
+	return true, nil
+}
+
+func (pq *PriorityQueue) Pop() (*QItem, error) {
+	defer pq.lock(OpPop)()
+	if pq.chaosEmpty() {
+		return nil, ErrEmptyQueue
+	}
+	return pq.pop()
+}
+
+// PopCtx is Pop failing with the error of ctx if ctx is done. It does not
+// wait for an item, PopWait does.
+func (pq *PriorityQueue) PopCtx(ctx context.Context) (*QItem, error) {
+	unlock, err := pq.lockCtx(ctx, OpPop)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if pq.chaosEmpty() {
+		return nil, ErrEmptyQueue
+	}
+	return pq.pop()
+}
+
+// pop removes the highest priority item. The queue lock must be held.
+func (pq *PriorityQueue) pop() (*QItem, error) {
+	if err := pq.mutable(); err != nil {
+		return nil, err
+	}
+	pq.dropDuplicates()
+	if n := pq.next(); n != -1 {
+		if pq.frozen(pq.data[n]) {
+			return nil, ErrFrozen
+		}
+		r := pq.remove(n, StatePopped)
+		pq.record(recorded{Op: OpPop})
+		pq.audit(OpPop, r)
+		return pq.untransform(pq.handOut(r))
+	}
+	return nil, ErrEmptyQueue
+}
+
+// PopWait pops the highest priority item, waiting for one to be pushed if
+// the queue is empty or frozen, until ctx is done.
+func (pq *PriorityQueue) PopWait(ctx context.Context) (*QItem, error) {
+	for {
+		unlock := pq.lock(OpPop)
+		item, err := pq.pop()
+		if err != ErrEmptyQueue && err != ErrFrozen {
+			unlock()
+			return item, err
 		}
+		pushed := pq.waitPushed()
+		// A delayed item or an expiring lease may requeue an item before
+		// anything is pushed, and freeze windows end without notice
+		var due *time.Timer
+		if err == ErrFrozen {
+			due = time.NewTimer(freezePoll)
+		} else if next := pq.nextDue(); !next.IsZero() {
+			due = time.NewTimer(next.Sub(pq.now()))
+		} else {
+			due = time.NewTimer(0)
+			due.Stop()
+		}
+		unlock()
+
+		select {
+		case <-pushed:
+		case <-due.C:
+		case <-ctx.Done():
+			due.Stop()
+			return nil, ctx.Err()
+		}
+		due.Stop()
+	}
+}
+
+// Peek returns a copy of the highest priority item without removing it
+func (pq *PriorityQueue) Peek() (*QItem, error) {
+	return pq.PeekCtx(context.Background())
+}
+
+// PeekCtx is Peek failing with the error of ctx if ctx is done
+func (pq *PriorityQueue) PeekCtx(ctx context.Context) (*QItem, error) {
+	unlock, err := pq.lockCtx(ctx, OpPeek)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if pq.destroyed {
+		return nil, ErrQueueDestroyed
+	}
+	if n := pq.next(); n != -1 {
+		item := *pq.data[n]
+		return &item, nil
+	}
+	return nil, ErrEmptyQueue
+}
+
+// UpdatePriorityById() updates the priority of an item in the queue.
+// If an Authorizer denies the update nothing is updated and 0 is returned,
+// use UpdatePriorityByParentIdCtx to see the reason.
+func (pq *PriorityQueue) UpdatePriorityByParentId(parentID string, priority int) int {
+	n, _ := pq.UpdatePriorityByParentIdCtx(context.Background(), parentID, priority)
+	return n
+}
+
+// UpdatePriorityByParentIdCtx is UpdatePriorityByParentId on behalf of the
+// principal carried by ctx. The update is denied as a whole if any of the
+// matching items is denied.
+func (pq *PriorityQueue) UpdatePriorityByParentIdCtx(ctx context.Context, parentID string, priority int) (int, error) {
+	unlock, err := pq.lockCtx(ctx, OpUpdatePriorityByParentId)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	if err := pq.mutable(); err != nil {
+		return 0, err
+	}
+	pq.record(recorded{Op: OpUpdatePriorityByParentId, ParentID: parentID, Priority: priority})
+	// Collect the matching items first, updating reorders the heap
+	itemsToUpdate := pq.parentItems(parentID)
+	if err := pq.authorize(ctx, OpUpdatePriorityByParentId, itemsToUpdate...); err != nil {
+		return 0, err
+	}
+	return pq.updatePriorities(OpUpdatePriorityByParentId, itemsToUpdate, priority), nil
+}
+
+// UpdatePriorityByIds sets the priority of the queued items with the given
+// IDs in one go, and returns how many were found. IDs not queued are
+// ignored.
+func (pq *PriorityQueue) UpdatePriorityByIds(ids []string, priority int) (int, error) {
+	return pq.UpdatePriorityByIdsCtx(context.Background(), ids, priority)
+}
+
+// UpdatePriorityByIdsCtx is UpdatePriorityByIds on behalf of the principal
+// carried by ctx. The update is denied as a whole if any of the matching
+// items is denied.
+func (pq *PriorityQueue) UpdatePriorityByIdsCtx(ctx context.Context, ids []string, priority int) (int, error) {
+	unlock, err := pq.lockCtx(ctx, OpUpdatePriorityByIds)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	if err := pq.mutable(); err != nil {
+		return 0, err
+	}
+	pq.record(recorded{Op: OpUpdatePriorityByIds, IDs: ids, Priority: priority})
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[pq.lookupKey(id)] = true
 	}
-	return itemsUpdated
+	itemsToUpdate := pq.collect(func(item *QItem) bool { return wanted[pq.itemKey(item)] })
+	if err := pq.authorize(ctx, OpUpdatePriorityByIds, itemsToUpdate...); err != nil {
+		return 0, err
+	}
+	// Fixing k items costs k log n, rebuilding the heap n
+	if pq.batchWindow > 0 || pq.changeLimit != (PriorityChangeLimit{}) || len(itemsToUpdate)*bits.Len(uint(len(pq.data))) <= len(pq.data) {
+		return pq.updatePriorities(OpUpdatePriorityByIds, itemsToUpdate, priority), nil
+	}
+	head := pq.head()
+	for _, item := range itemsToUpdate {
+		pq.setPriority(item, pq.capped(item.ParentID, priority))
+		pq.audit(OpUpdatePriorityByIds, item)
+	}
+	pq.data.init(pq.tieBreak)
+	pq.headChanged(head)
+	return len(itemsToUpdate), nil
 }
 
 /* Clear drains all items from the queue */
 func (pq *PriorityQueue) Clear() {
-	pq.m.Lock()
-	defer pq.m.Unlock()
-	for pq.data.Len() > 0 {
-		x := heap.Pop(&pq.data)
+	pq.ClearCtx(context.Background())
+}
+
+// ClearCtx is Clear on behalf of the principal carried by ctx, failing with
+// the error of ctx if ctx is done. Nothing is removed if the Authorizer
+// denies the removal of any item.
+func (pq *PriorityQueue) ClearCtx(ctx context.Context) error {
+	unlock, err := pq.lockCtx(ctx, OpClear)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if pq.authorizer != nil {
+		if err := pq.authorize(ctx, OpClear, pq.collect(func(*QItem) bool { return true })...); err != nil {
+			return err
+		}
+	}
+	pq.record(recorded{Op: OpClear})
+	for pq.purgeHead(); pq.data.Len() > 0; pq.purgeHead() {
+		x := pq.remove(0, StateDeleted)
 		if x != nil {
+			pq.audit(OpClear, x)
 			x = nil
 		}
 	}
+	return nil
 }
 
 func (pq *PriorityQueue) locateItemByID(id string) (int, error) {
 	var index = -1
 	for _, element := range pq.data {
-		if element.ID == id {
+		if pq.isKey(element, id) && element.tombstone == "" {
 			index = element.index
 			break
 		}
 	}
 	if index == -1 {
-		return -1, fmt.Errorf("ID Not found: [%s]", id)
+		return -1, fmt.Errorf("%w: [%s]", ErrNotFound, id)
 	}
 	return index, nil
 }
@@ -113,16 +1077,29 @@ func (pq *PriorityQueue) locateItemByID(id string) (int, error) {
 // DeleteItemById() deletes an item from the queue based on the ID
 
 func (pq *PriorityQueue) DeleteItemById(id string) error {
-	pq.m.Lock()
-	defer pq.m.Unlock()
-	index, err := pq.locateItemByID(id)
+	return pq.DeleteItemByIdCtx(context.Background(), id)
+}
+
+// DeleteItemByIdCtx is DeleteItemById on behalf of the principal carried by ctx
+func (pq *PriorityQueue) DeleteItemByIdCtx(ctx context.Context, id string) error {
+	unlock, err := pq.lockCtx(ctx, OpDeleteItemById)
 	if err != nil {
 		return err
 	}
-	err = pq.data.delete(index)
+	defer unlock()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpDeleteItemById, ID: id})
+	index, err := pq.locateItemByID(id)
 	if err != nil {
 		return err
 	}
+	if err := pq.authorize(ctx, OpDeleteItemById, pq.data[index]); err != nil {
+		return err
+	}
+	pq.recycle(pq.data[index])
+	pq.audit(OpDeleteItemById, pq.remove(index, StateDeleted))
 	return nil
 }
 
@@ -130,30 +1107,28 @@ func (pq *PriorityQueue) DeleteItemById(id string) error {
 // Note deletes can be expensive
 
 func (pq *PriorityQueue) DeleteItemsByParentId(parentID string) (int, error) {
-	pq.m.Lock()
-	defer pq.m.Unlock()
-
-	itemsDeleted := 0
-
-	// A place to collect the indexes for the items we want to delete
-	var indexesToDelete []int
-
-	for _, element := range pq.data {
-		if element.ParentID == parentID {
-			indexesToDelete = append(indexesToDelete, element.index)
-		}
-	}
-
-	for index := range indexesToDelete {
+	return pq.DeleteItemsByParentIdCtx(context.Background(), parentID)
+}
 
-		err := pq.data.delete(index)
-		if err != nil {
-			return itemsDeleted, err
-		}
-		itemsDeleted++
+// DeleteItemsByParentIdCtx is DeleteItemsByParentId on behalf of the
+// principal carried by ctx. The delete is denied as a whole if any of the
+// matching items is denied.
+func (pq *PriorityQueue) DeleteItemsByParentIdCtx(ctx context.Context, parentID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
+	return pq.deleteByParent(ctx, parentID, nil)
+}
 
-	return itemsDeleted, nil
+// deleteByParent deletes the items of parentID, reporting the progress to
+// job unless it is nil.
+func (pq *PriorityQueue) deleteByParent(ctx context.Context, parentID string, job *JobStatus) (int, error) {
+	return pq.deleteChunked(OpDeleteItemsByParentId, job, func() ([]*QItem, error) {
+		pq.record(recorded{Op: OpDeleteItemsByParentId, ParentID: parentID})
+		// A place to collect the items we want to delete
+		itemsToDelete := pq.parentItems(parentID)
+		return itemsToDelete, pq.authorize(ctx, OpDeleteItemsByParentId, itemsToDelete...)
+	})
 }
 
 /* Implement the heap interface methods: Len, Less, Swap, Push, and Pop */
@@ -198,17 +1173,8 @@ func (qData *QItems) Pop() interface{} {
 }
 
 // update modifies the Priority of an QItem in the queue.
-func (qData *QItems) update(item *QItem, priority int) {
+func (qData *QItems) update(item *QItem, priority int, tie TieBreak) {
 
 	item.Priority = priority
-	heap.Fix(qData, item.index)
-}
-
-func (qData *QItems) delete(index int) error {
-	if index < qData.Len() {
-		// Remove the element at index i from a.
-		heap.Remove(qData, index)
-		return nil
-	}
-	return fmt.Errorf("Index out of range")
+	qData.fix(item.index, tie)
 }
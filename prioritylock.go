@@ -0,0 +1,51 @@
+package priorityqueue
+
+import "context"
+
+// A PriorityLock is a mutual exclusion lock granted to the waiting
+// goroutine of highest priority when it is unlocked, such as to arbitrate a
+// resource between the jobs of a scheduler. Escalation rules set by
+// SetEscalationRules keep the waiters of low priority from starving behind
+// a steady stream of urgent ones:
+//
+//	l.SetEscalationRules(EscalationRule{After: Duration(time.Second), Priority: 100})
+//
+// The zero PriorityLock is not usable, use NewPriorityLock.
+type PriorityLock struct {
+	s *PrioritySemaphore
+}
+
+// NewPriorityLock returns an unlocked PriorityLock
+func NewPriorityLock() *PriorityLock {
+	return &PriorityLock{s: NewPrioritySemaphore(1)}
+}
+
+// Lock locks l, waiting until it is granted or ctx is done. It returns the
+// error of ctx if ctx is done before the lock is granted, and does not hold
+// the lock then.
+func (l *PriorityLock) Lock(ctx context.Context, priority int) error {
+	return l.s.Acquire(ctx, priority)
+}
+
+// TryLock locks l if it is unlocked, without waiting. It reports whether
+// it locked l.
+func (l *PriorityLock) TryLock() bool {
+	return l.s.TryAcquire()
+}
+
+// Unlock unlocks l, granting it to the waiting goroutine of highest
+// priority. It panics if l is not locked.
+func (l *PriorityLock) Unlock() {
+	l.s.Release()
+}
+
+// SetEscalationRules replaces the rules raising the priority of the
+// goroutines left waiting, see PrioritySemaphore.SetEscalationRules
+func (l *PriorityLock) SetEscalationRules(rules ...EscalationRule) error {
+	return l.s.SetEscalationRules(rules...)
+}
+
+// Waiting returns the number of goroutines waiting for the lock
+func (l *PriorityLock) Waiting() int {
+	return l.s.Waiting()
+}
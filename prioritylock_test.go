@@ -0,0 +1,65 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_PriorityLock(t *testing.T) {
+	l := NewPriorityLock()
+	ctx := context.Background()
+	l.Lock(ctx, 0)
+	assertEqual(t, l.TryLock(), false)
+
+	granted := make(chan string, 2)
+	wait := func(name string, priority int) {
+		n := l.Waiting()
+		go func() {
+			l.Lock(ctx, priority)
+			granted <- name
+		}()
+		for l.Waiting() == n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	wait("low", 1)
+	wait("high", 2)
+	l.Unlock()
+	assertEqual(t, <-granted, "high")
+	l.Unlock()
+	assertEqual(t, <-granted, "low")
+	l.Unlock()
+	assertEqual(t, l.TryLock(), true)
+}
+
+func Test_PriorityLockAging(t *testing.T) {
+	l := NewPriorityLock()
+	err := l.SetEscalationRules(EscalationRule{After: Duration(20 * time.Millisecond), Priority: 100})
+	if err != nil {
+		t.Fatalf("Error setting the escalation rules: %v", err)
+	}
+	ctx := context.Background()
+	l.Lock(ctx, 0)
+
+	granted := make(chan string, 2)
+	go func() {
+		l.Lock(ctx, 1)
+		granted <- "starving"
+	}()
+	for l.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(30 * time.Millisecond)
+	go func() {
+		l.Lock(ctx, 50)
+		granted <- "urgent"
+	}()
+	for l.Waiting() == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	l.Unlock()
+	assertEqual(t, <-granted, "starving")
+	l.Unlock()
+	assertEqual(t, <-granted, "urgent")
+}
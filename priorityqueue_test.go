@@ -2,9 +2,12 @@ package priorityqueue
 
 import (
 	"container/heap"
+	"context"
+	"io"
 	"reflect"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func assertEqual(t *testing.T, a interface{}, b interface{}) {
@@ -192,6 +195,36 @@ func Test_UpdatePriorityByParentId(t *testing.T) {
 
 }
 
+func Test_UpdatePriorityByIds(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 100)
+
+	// Few IDs are fixed in place, many rebuild the heap
+	var many []string
+	for i := 10; i < 30; i++ {
+		many = append(many, strconv.Itoa(i))
+	}
+	n, err := pq.UpdatePriorityByIds([]string{"3", "7", "missing"}, 500)
+	if err != nil {
+		t.Fatalf("Error updating priorities: %v", err)
+	}
+	assertEqual(t, n, 2)
+	n, _ = pq.UpdatePriorityByIds(many, 500)
+	assertEqual(t, n, 20)
+
+	last := 500
+	for i := 0; pq.Len() > 0; i++ {
+		x, _ := pq.Pop()
+		if x.Priority > last {
+			t.Fatalf("Item %v popped after priority %d", x, last)
+		}
+		last = x.Priority
+		if i < 22 && x.Priority != 500 {
+			t.Errorf("Priority not set for item: %v", x)
+		}
+	}
+}
+
 func Test_DeleteItemById(t *testing.T) {
 	expectedItems := 10
 	pq := NewPriorityQueue()
@@ -273,3 +306,90 @@ func Test_DeleteItemsByParentId(t *testing.T) {
 		}
 	}
 }
+
+func Test_Peek(t *testing.T) {
+	pq := NewPriorityQueue()
+	_, err := pq.Peek()
+	assertEqual(t, err, ErrEmptyQueue)
+
+	populateQueue(pq, 10)
+	x, err := pq.Peek()
+	if err != nil {
+		t.Errorf("Error peeking at queue: %e", err)
+	}
+	assertEqual(t, x.Priority, 10)
+	assertEqual(t, pq.Len(), 10)
+
+	y, _ := pq.Pop()
+	assertEqual(t, y.ID, x.ID)
+}
+
+func Test_Destroy(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 4)
+	pq.PushDelayed(QItem{ID: "later", ParentID: "12345"}, time.Now().Add(time.Hour))
+	pq.Lease(time.Minute)
+	var done ParentProgress
+	pq.OnParentDone(func(p ParentProgress) { done = p })
+
+	empty := NewPriorityQueue()
+	waiting := make(chan error)
+	go func() {
+		_, err := empty.PopWait(context.Background())
+		waiting <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	empty.Destroy()
+	if err := <-waiting; err != ErrQueueDestroyed {
+		t.Errorf("Error waiting on a destroyed queue: %v", err)
+	}
+
+	pq.Destroy()
+	pq.Destroy()
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, pq.State("0"), StateDeleted)
+	assertEqual(t, pq.State("later"), StateDeleted)
+	assertEqual(t, done.Outstanding, 0)
+	assertEqual(t, done.Dropped, 5)
+
+	if err := pq.Push(QItem{ID: "new"}); err != ErrQueueDestroyed {
+		t.Errorf("Error pushing to a destroyed queue: %v", err)
+	}
+	if _, err := pq.Pop(); err != ErrQueueDestroyed {
+		t.Errorf("Error popping a destroyed queue: %v", err)
+	}
+	if _, err := pq.Peek(); err != ErrQueueDestroyed {
+		t.Errorf("Error peeking a destroyed queue: %v", err)
+	}
+	if err := pq.Snapshot(io.Discard); err != ErrQueueDestroyed {
+		t.Errorf("Error taking a snapshot of a destroyed queue: %v", err)
+	}
+	pq.Clear()
+	assertEqual(t, pq.UpdatePriorityByParentId("12345", 1), 0)
+	assertEqual(t, pq.Stats().Len, 0)
+}
+
+func Test_PushInfo(t *testing.T) {
+	pq := NewPriorityQueue()
+	r, err := pq.PushInfo(QItem{ID: "a", Priority: 5})
+	assertEqual(t, err, nil)
+	assertEqual(t, r, PushResult{Seq: 1, Depth: 1, Head: true})
+
+	r, _ = pq.PushInfo(QItem{ID: "b", Priority: 1})
+	assertEqual(t, r, PushResult{Seq: 2, Depth: 2})
+	r, _ = pq.PushInfo(QItem{ID: "c", Priority: 9})
+	assertEqual(t, r, PushResult{Seq: 3, Depth: 3, Head: true})
+
+	pq.Clear()
+	pq.SetDedupeWindow(time.Minute)
+	pq.Push(QItem{ID: "d", IdempotencyKey: "k"})
+	_, receipt, _ := pq.Lease(0)
+	pq.Ack(receipt)
+	r, err = pq.PushInfo(QItem{ID: "e", IdempotencyKey: "k"})
+	assertEqual(t, err, nil)
+	assertEqual(t, r.Suppressed, true)
+
+	pq.Freeze(FreezeReject)
+	_, err = pq.PushInfo(QItem{ID: "f"})
+	assertEqual(t, err, ErrFrozen)
+}
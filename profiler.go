@@ -0,0 +1,167 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A ProfileReport tells which operations and which ParentIDs account for
+// the time the queue lock is held and for the heap fix-ups, the items the
+// heap was reordered for, over the sampled operations, see StartProfile.
+// Multiplying by Every estimates the totals.
+type ProfileReport struct {
+	Start   time.Time
+	End     time.Time
+	Every   int // One operation in Every is sampled
+	Sampled int // Number of operations sampled
+
+	Ops     []OpProfile     // Sorted by hold time, longest first
+	Parents []ParentProfile // Sorted by hold time, longest first
+}
+
+// An OpProfile sums up the sampled runs of an operation
+type OpProfile struct {
+	Op     Operation
+	Count  int
+	Held   time.Duration
+	Fixups int
+}
+
+// A ParentProfile sums up the sampled operations reordering the heap for
+// items of a ParentID. The hold time of an operation is shared among the
+// ParentIDs it reordered the heap for, in proportion to their fix-ups.
+type ParentProfile struct {
+	ParentID string
+	Ops      int
+	Held     time.Duration
+	Fixups   int
+}
+
+// profiler samples operations while a profile runs
+type profiler struct {
+	every   int
+	start   time.Time
+	seen    int
+	sampled int
+	current bool           // Whether the operation holding the lock is sampled
+	touched map[string]int // Fix-ups of the current operation per ParentID
+	ops     map[Operation]*OpProfile
+	parents map[string]*ParentProfile
+}
+
+// StartProfile starts profiling the queue, sampling one operation in every,
+// to find out which tenant's churn is degrading the queue. A profile
+// already running is discarded. Sampling costs a map update per fix-up of
+// the sampled operations.
+func (pq *PriorityQueue) StartProfile(every int) error {
+	if every < 1 {
+		return fmt.Errorf("profile sampling every %d operations", every)
+	}
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.profiler = &profiler{
+		every:   every,
+		start:   time.Now(),
+		touched: make(map[string]int),
+		ops:     make(map[Operation]*OpProfile),
+		parents: make(map[string]*ParentProfile),
+	}
+	return nil
+}
+
+// Profile returns the report of the running profile, up to now. It is
+// empty if no profile runs.
+func (pq *PriorityQueue) Profile() ProfileReport {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	return pq.profiler.report()
+}
+
+// StopProfile stops the running profile and returns its report
+func (pq *PriorityQueue) StopProfile() ProfileReport {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	r := pq.profiler.report()
+	pq.profiler = nil
+	return r
+}
+
+// profileBegin decides whether to sample the operation that acquired the
+// lock. The queue lock must be held.
+func (pq *PriorityQueue) profileBegin() {
+	p := pq.profiler
+	if p == nil {
+		return
+	}
+	p.seen++
+	p.current = p.seen%p.every == 0
+}
+
+// profileFixup counts a fix-up of the heap for item. The queue lock must be
+// held.
+func (pq *PriorityQueue) profileFixup(item *QItem) {
+	if p := pq.profiler; p != nil && p.current {
+		p.touched[item.ParentID]++
+	}
+}
+
+// profileEnd records the sampled operation op, which held the lock for
+// held. The queue lock must be held.
+func (pq *PriorityQueue) profileEnd(op Operation, held time.Duration) {
+	p := pq.profiler
+	if p == nil || !p.current {
+		return
+	}
+	p.current = false
+	p.sampled++
+	fixups := 0
+	for _, n := range p.touched {
+		fixups += n
+	}
+	o, ok := p.ops[op]
+	if !ok {
+		o = &OpProfile{Op: op}
+		p.ops[op] = o
+	}
+	o.Count++
+	o.Held += held
+	o.Fixups += fixups
+	for parentID, n := range p.touched {
+		pp, ok := p.parents[parentID]
+		if !ok {
+			pp = &ParentProfile{ParentID: parentID}
+			p.parents[parentID] = pp
+		}
+		pp.Ops++
+		pp.Held += held * time.Duration(n) / time.Duration(fixups)
+		pp.Fixups += n
+		delete(p.touched, parentID)
+	}
+}
+
+func (p *profiler) report() ProfileReport {
+	if p == nil {
+		return ProfileReport{}
+	}
+	r := ProfileReport{Start: p.start, End: time.Now(), Every: p.every, Sampled: p.sampled}
+	for _, o := range p.ops {
+		r.Ops = append(r.Ops, *o)
+	}
+	sort.Slice(r.Ops, func(a, b int) bool {
+		if r.Ops[a].Held != r.Ops[b].Held {
+			return r.Ops[a].Held > r.Ops[b].Held
+		}
+		return r.Ops[a].Op < r.Ops[b].Op
+	})
+	for _, pp := range p.parents {
+		r.Parents = append(r.Parents, *pp)
+	}
+	sort.Slice(r.Parents, func(a, b int) bool {
+		if r.Parents[a].Held != r.Parents[b].Held {
+			return r.Parents[a].Held > r.Parents[b].Held
+		}
+		return r.Parents[a].ParentID < r.Parents[b].ParentID
+	})
+	return r
+}
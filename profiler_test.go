@@ -0,0 +1,55 @@
+package priorityqueue
+
+import (
+	"strconv"
+	"testing"
+)
+
+func Test_Profile(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, pq.Profile().Sampled, 0)
+	if err := pq.StartProfile(0); err == nil {
+		t.Error("sampling every 0 operations accepted")
+	}
+	pq.StartProfile(1)
+
+	for n := 0; n < 10; n++ {
+		pq.Push(QItem{ID: strconv.Itoa(n), ParentID: "churn", Priority: n})
+	}
+	pq.Push(QItem{ID: "calm", ParentID: "calm"})
+	pq.UpdatePriorityByParentId("churn", 100)
+	pq.Pop()
+
+	r := pq.StopProfile()
+	assertEqual(t, r.Every, 1)
+	assertEqual(t, r.Sampled, 13)
+	parents := make(map[string]ParentProfile)
+	for _, p := range r.Parents {
+		parents[p.ParentID] = p
+	}
+	assertEqual(t, parents["churn"].Fixups, 21)
+	assertEqual(t, parents["churn"].Ops, 12)
+	assertEqual(t, parents["calm"].Fixups, 1)
+	ops := make(map[Operation]OpProfile)
+	for _, o := range r.Ops {
+		ops[o.Op] = o
+	}
+	assertEqual(t, ops[OpPush].Count, 11)
+	assertEqual(t, ops[OpUpdatePriorityByParentId].Fixups, 10)
+	if r.End.Before(r.Start) {
+		t.Errorf("profile ends %v before it starts %v", r.End, r.Start)
+	}
+
+	pq.Push(QItem{ID: "after"})
+	assertEqual(t, pq.Profile().Sampled, 0)
+}
+
+func Test_ProfileSampling(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.StartProfile(4)
+	populateQueue(pq, 20)
+	r := pq.Profile()
+	assertEqual(t, r.Sampled, 5)
+	assertEqual(t, len(r.Parents), 1)
+	assertEqual(t, r.Parents[0].Fixups, 5)
+}
@@ -0,0 +1,107 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+// A PurgeFilter selects the queued items Purge deletes. An item must match
+// every criterion set; zero fields match every item.
+type PurgeFilter struct {
+	// PushedFrom and PushedBefore bound the push time of the items, from
+	// PushedFrom until before PushedBefore.
+	PushedFrom, PushedBefore time.Time
+
+	// MinPriority and MaxPriority bound the priority of the items,
+	// inclusive, when set.
+	MinPriority, MaxPriority *int
+
+	// ParentIDs lists the ParentIDs of the items, as patterns in the syntax
+	// of path.Match. Literal ParentIDs are looked up in the parent index
+	// rather than scanning the queue.
+	ParentIDs []string
+
+	// Match is any other condition, such as a Tenant or Producer, called
+	// with the lock held. It must not use the queue.
+	Match Matcher
+}
+
+// ErrEmptyFilter is returned by Purge for a filter matching every item; use
+// Clear to delete them all.
+var ErrEmptyFilter = errors.New("purge filter matches every item")
+
+func (f PurgeFilter) empty() bool {
+	return f.PushedFrom.IsZero() && f.PushedBefore.IsZero() && f.MinPriority == nil &&
+		f.MaxPriority == nil && len(f.ParentIDs) == 0 && f.Match == nil
+}
+
+// literal reports whether every ParentID of the filter is a plain ParentID
+// rather than a pattern
+func (f PurgeFilter) literal() bool {
+	for _, pattern := range f.ParentIDs {
+		if strings.ContainsAny(pattern, `*?[\`) {
+			return false
+		}
+	}
+	return len(f.ParentIDs) > 0
+}
+
+// matches reports whether item matches the filter, comparing ParentIDs by
+// their key
+func (f PurgeFilter) matches(item *QItem, key func(string) string) bool {
+	switch {
+	case item.PushedAt.Before(f.PushedFrom):
+		return false
+	case !f.PushedBefore.IsZero() && !item.PushedAt.Before(f.PushedBefore):
+		return false
+	case f.MinPriority != nil && item.Priority < *f.MinPriority:
+		return false
+	case f.MaxPriority != nil && item.Priority > *f.MaxPriority:
+		return false
+	case f.Match != nil && !f.Match(item):
+		return false
+	}
+	if len(f.ParentIDs) == 0 {
+		return true
+	}
+	for _, pattern := range f.ParentIDs {
+		if ok, _ := path.Match(key(pattern), key(item.ParentID)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Purge deletes the queued items matched by filter, such as those pushed
+// during an incident, and returns their number. Like the other bulk deletes
+// it releases the lock between chunks of WithChunkSize items. It fails with
+// ErrEmptyFilter for a filter setting no criterion and with
+// path.ErrBadPattern for a malformed ParentID pattern. Purge is not
+// recorded by Record, as its Matcher cannot be replayed.
+func (pq *PriorityQueue) Purge(filter PurgeFilter) (int, error) {
+	if filter.empty() {
+		return 0, ErrEmptyFilter
+	}
+	for _, pattern := range filter.ParentIDs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return 0, err
+		}
+	}
+	return pq.deleteChunked(OpPurge, nil, func() ([]*QItem, error) {
+		var items []*QItem
+		matches := func(item *QItem) bool { return filter.matches(item, pq.idKey) }
+		if filter.literal() {
+			for _, item := range pq.parentItems(filter.ParentIDs...) {
+				if matches(item) {
+					items = append(items, item)
+				}
+			}
+		} else {
+			items = pq.collect(matches)
+		}
+		return items, pq.authorize(context.Background(), OpPurge, items...)
+	})
+}
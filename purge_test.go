@@ -0,0 +1,45 @@
+package priorityqueue
+
+import (
+	"path"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_Purge(t *testing.T) {
+	pq := NewPriorityQueue(WithChunkSize(2))
+	deploy := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for n := 0; n < 10; n++ {
+		parent := "jobs/a"
+		if n%2 == 1 {
+			parent = "jobs/b"
+		}
+		pq.Push(QItem{ID: strconv.Itoa(n), ParentID: parent, Priority: n, PushedAt: deploy.Add(time.Duration(n) * time.Minute)})
+	}
+
+	min, max := 2, 7
+	n, err := pq.Purge(PurgeFilter{ParentIDs: []string{"jobs/a"}, MinPriority: &min, MaxPriority: &max})
+	assertEqual(t, err, nil)
+	assertEqual(t, n, 3) // 2, 4 and 6
+	assertEqual(t, pq.Len(), 7)
+
+	n, _ = pq.Purge(PurgeFilter{
+		PushedFrom:   deploy.Add(3 * time.Minute),
+		PushedBefore: deploy.Add(9 * time.Minute),
+		ParentIDs:    []string{"jobs/*"},
+	})
+	assertEqual(t, n, 4) // 3, 5, 7 and 8
+	assertEqual(t, pq.Len(), 3)
+
+	n, _ = pq.Purge(PurgeFilter{Match: func(item *QItem) bool { return item.ID == "9" }})
+	assertEqual(t, n, 1)
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "1")
+
+	_, err = pq.Purge(PurgeFilter{})
+	assertEqual(t, err, ErrEmptyFilter)
+	_, err = pq.Purge(PurgeFilter{ParentIDs: []string{"["}})
+	assertEqual(t, err, path.ErrBadPattern)
+	assertEqual(t, pq.Len(), 2)
+}
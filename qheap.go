@@ -0,0 +1,79 @@
+package priorityqueue
+
+// The queue keeps its heap with the methods below rather than with
+// container/heap, which goes through heap.Interface and boxes every item
+// popped or removed in an interface{}. They follow container/heap step by
+// step, so items are ordered exactly as before; QItems still implements
+// heap.Interface for callers using it.
+
+// The methods below order items of equal priority with tie, unless it is
+// nil, see WithTieBreak.
+
+// less reports whether the item at index i pops before the one at j
+func (qData QItems) less(i, j int, tie TieBreak) bool {
+	return outranks(qData[i], qData[j], tie)
+}
+
+// init establishes the heap order of every item
+func (qData QItems) init(tie TieBreak) {
+	n := len(qData)
+	for i := n/2 - 1; i >= 0; i-- {
+		qData.down(i, n, tie)
+	}
+}
+
+// fix restores the heap order after the item at index i changed priority
+// or was appended.
+func (qData QItems) fix(i int, tie TieBreak) {
+	if !qData.down(i, len(qData), tie) {
+		qData.up(i, tie)
+	}
+}
+
+// remove takes the item at index i out of the heap
+func (qData *QItems) remove(i int, tie TieBreak) *QItem {
+	h := *qData
+	n := len(h) - 1
+	if n != i {
+		h.Swap(i, n)
+		if !h.down(i, n, tie) {
+			h.up(i, tie)
+		}
+	}
+	item := h[n]
+	h[n] = nil      // avoid memory leak
+	item.index = -1 // for safety
+	*qData = h[:n]
+	return item
+}
+
+func (qData QItems) up(j int, tie TieBreak) {
+	for {
+		i := (j - 1) / 2 // parent
+		if i == j || !qData.less(j, i, tie) {
+			break
+		}
+		qData.Swap(i, j)
+		j = i
+	}
+}
+
+func (qData QItems) down(i0, n int, tie TieBreak) bool {
+	i := i0
+	for {
+		j1 := 2*i + 1
+		if j1 >= n || j1 < 0 { // j1 < 0 after int overflow
+			break
+		}
+		j := j1 // left child
+		if j2 := j1 + 1; j2 < n && qData.less(j2, j1, tie) {
+			j = j2 // right child
+		}
+		if !qData.less(j, i, tie) {
+			break
+		}
+		qData.Swap(i, j)
+		i = j
+	}
+	return i > i0
+}
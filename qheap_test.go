@@ -0,0 +1,50 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"math/rand"
+	"testing"
+)
+
+// Test_SpecializedHeap checks that the specialized heap moves items exactly
+// as container/heap does.
+func Test_SpecializedHeap(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var a, b QItems
+	for n := 0; n < 2000; n++ {
+		switch {
+		case len(a) == 0 || r.Intn(3) > 0:
+			p := r.Intn(50)
+			heap.Push(&a, QItem{ID: "x", Priority: p})
+			b = append(b, &QItem{Priority: p, index: len(b)})
+			b.fix(len(b)-1, nil)
+		case r.Intn(2) == 0:
+			i := r.Intn(len(a))
+			assertEqual(t, heap.Remove(&a, i).(*QItem).Priority, b.remove(i, nil).Priority)
+		default:
+			i, p := r.Intn(len(a)), r.Intn(50)
+			a.update(a[i], p, nil)
+			b[i].Priority = p
+			b.fix(i, nil)
+		}
+		for i := range a {
+			if a[i].Priority != b[i].Priority || b[i].index != i {
+				t.Fatalf("step %d: heaps differ at index %d", n, i)
+			}
+		}
+	}
+	assertEqual(t, len(a), len(b))
+}
+
+func Benchmark_PushPop(b *testing.B) {
+	pq := NewPriorityQueue()
+	for n := 0; n < 1000; n++ {
+		pq.Push(QItem{Priority: n})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		pq.Push(QItem{Priority: n % 1000})
+		pq.Pop()
+	}
+}
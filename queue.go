@@ -0,0 +1,106 @@
+package priorityqueue
+
+import "context"
+
+// Queue is the API shared by every queue implementation in this package, so
+// that application code and tests can be written independently of the
+// backing store.
+type Queue interface {
+	Push(i QItem) error
+	Pop() (*QItem, error)
+	Peek() (*QItem, error)
+	Len() int
+	Clear()
+	UpdatePriorityByParentId(parentID string, priority int) int
+	DeleteItemById(id string) error
+	DeleteItemsByParentId(parentID string) (int, error)
+}
+
+var _ Queue = (*PriorityQueue)(nil)
+
+// QueueCtx is Queue with a context passed to every method, so that
+// implementations backed by storage or a network honor deadlines and
+// cancellation, and PriorityQueue sees the principal of the caller. The
+// methods fail with the error of the context once it is done.
+type QueueCtx interface {
+	PushCtx(ctx context.Context, i QItem) error
+	PopCtx(ctx context.Context) (*QItem, error)
+	PeekCtx(ctx context.Context) (*QItem, error)
+	LenCtx(ctx context.Context) (int, error)
+	ClearCtx(ctx context.Context) error
+	UpdatePriorityByParentIdCtx(ctx context.Context, parentID string, priority int) (int, error)
+	DeleteItemByIdCtx(ctx context.Context, id string) error
+	DeleteItemsByParentIdCtx(ctx context.Context, parentID string) (int, error)
+}
+
+var _ QueueCtx = (*PriorityQueue)(nil)
+
+// AsQueueCtx returns q itself if it implements QueueCtx, or an adapter
+// checking the context before each call of the plain method otherwise.
+func AsQueueCtx(q Queue) QueueCtx {
+	if c, ok := q.(QueueCtx); ok {
+		return c
+	}
+	return ctxAdapter{q}
+}
+
+type ctxAdapter struct {
+	q Queue
+}
+
+func (a ctxAdapter) PushCtx(ctx context.Context, i QItem) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.q.Push(i)
+}
+
+func (a ctxAdapter) PopCtx(ctx context.Context) (*QItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.q.Pop()
+}
+
+func (a ctxAdapter) PeekCtx(ctx context.Context) (*QItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.q.Peek()
+}
+
+func (a ctxAdapter) LenCtx(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.q.Len(), nil
+}
+
+func (a ctxAdapter) ClearCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.q.Clear()
+	return nil
+}
+
+func (a ctxAdapter) UpdatePriorityByParentIdCtx(ctx context.Context, parentID string, priority int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.q.UpdatePriorityByParentId(parentID, priority), nil
+}
+
+func (a ctxAdapter) DeleteItemByIdCtx(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.q.DeleteItemById(id)
+}
+
+func (a ctxAdapter) DeleteItemsByParentIdCtx(ctx context.Context, parentID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.q.DeleteItemsByParentId(parentID)
+}
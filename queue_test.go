@@ -0,0 +1,56 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_QueueCtx(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, q := range []Queue{NewPriorityQueue(), NewSortedQueue(), NewCompactQueue(), NewTwoLevelQueue()} {
+		c := AsQueueCtx(q)
+		ctx := context.Background()
+		assertEqual(t, c.PushCtx(ctx, QItem{ID: "a", ParentID: "p", Priority: 1}), nil)
+		assertEqual(t, c.PushCtx(canceled, QItem{ID: "b"}), context.Canceled)
+		n, _ := c.LenCtx(ctx)
+		assertEqual(t, n, 1)
+
+		_, err := c.PopCtx(canceled)
+		assertEqual(t, err, context.Canceled)
+		_, err = c.PeekCtx(canceled)
+		assertEqual(t, err, context.Canceled)
+		assertEqual(t, c.DeleteItemByIdCtx(canceled, "a"), context.Canceled)
+		_, err = c.DeleteItemsByParentIdCtx(canceled, "p")
+		assertEqual(t, err, context.Canceled)
+		_, err = c.UpdatePriorityByParentIdCtx(canceled, "p", 5)
+		assertEqual(t, err, context.Canceled)
+		assertEqual(t, c.ClearCtx(canceled), context.Canceled)
+
+		item, err := c.PopCtx(ctx)
+		assertEqual(t, err, nil)
+		assertEqual(t, item.ID, "a")
+		assertEqual(t, c.ClearCtx(ctx), nil)
+	}
+}
+
+func Test_PushCtxContention(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.m.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assertEqual(t, pq.PushCtx(ctx, QItem{ID: "a"}), context.DeadlineExceeded)
+	pq.m.Unlock()
+
+	// The abandoned acquisition does not keep the mutex
+	assertEqual(t, pq.Push(QItem{ID: "b"}), nil)
+	assertEqual(t, pq.Len(), 1)
+
+	pq.Freeze(FreezeBlock)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assertEqual(t, pq.PushCtx(ctx, QItem{ID: "c"}), context.DeadlineExceeded)
+	pq.Thaw()
+	assertEqual(t, pq.Len(), 1)
+}
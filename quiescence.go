@@ -0,0 +1,59 @@
+package priorityqueue
+
+import "context"
+
+// quiesce counts the items the queue holds for the transition of an item
+// from one state to another, signalling when the last one leaves. The
+// queue lock must be held.
+func (pq *PriorityQueue) quiesce(from, to State) {
+	if from.live() == to.live() {
+		return
+	}
+	if to.live() {
+		pq.outstanding++
+		return
+	}
+	if pq.outstanding--; pq.outstanding > 0 {
+		return
+	}
+	if pq.emptied != nil {
+		close(pq.emptied)
+		pq.emptied = nil
+	}
+	if fn := pq.onEmpty; fn != nil {
+		pq.deferred = append(pq.deferred, fn)
+	}
+}
+
+// OnEmpty installs fn to be called whenever the queue becomes empty with
+// no items in flight: the last item queued, delayed or in flight was
+// acked, popped or dropped. Batch pipelines use it to learn that a phase
+// of work is complete. It is called after the queue lock has been released,
+// on the goroutine that completed the item. Passing nil removes the
+// current callback.
+func (pq *PriorityQueue) OnEmpty(fn func()) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.onEmpty = fn
+}
+
+// WaitUntilEmpty waits until the queue holds no item queued, delayed or in
+// flight, or ctx is done. It returns at once if the queue holds none.
+func (pq *PriorityQueue) WaitUntilEmpty(ctx context.Context) error {
+	unlock := pq.lock(OpStats)
+	if pq.outstanding == 0 {
+		unlock()
+		return nil
+	}
+	if pq.emptied == nil {
+		pq.emptied = make(chan struct{})
+	}
+	emptied := pq.emptied
+	unlock()
+	select {
+	case <-emptied:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,49 @@
+package priorityqueue
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_OnEmpty(t *testing.T) {
+	now := time.Now()
+	pq, _ := New(WithClock(func() time.Time { return now }))
+	emptied := 0
+	pq.OnEmpty(func() { emptied++ })
+	assertEqual(t, pq.WaitUntilEmpty(context.Background()), nil)
+
+	populateQueue(pq, 3)
+	pq.PushDelayed(QItem{ID: "later"}, now.Add(time.Minute))
+	done := make(chan error)
+	go func() { done <- pq.WaitUntilEmpty(context.Background()) }()
+
+	pq.Pop()
+	_, receipt, _ := pq.Lease(time.Minute)
+	pq.DeleteItemById("0")
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, emptied, 0)
+
+	// In-flight and delayed items keep the queue from being done
+	pq.Ack(receipt)
+	assertEqual(t, emptied, 0)
+	now = now.Add(time.Minute)
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "later")
+	assertEqual(t, emptied, 1)
+	assertEqual(t, <-done, nil)
+
+	// Every time the queue becomes empty
+	pq.ImportNDJSON(strings.NewReader(`{"id":"a","priority":1}` + "\n"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assertEqual(t, pq.WaitUntilEmpty(ctx), context.DeadlineExceeded)
+	pq.Clear()
+	assertEqual(t, emptied, 2)
+
+	pq.OnEmpty(nil)
+	pq.Push(QItem{ID: "b"})
+	pq.Pop()
+	assertEqual(t, emptied, 2)
+}
@@ -0,0 +1,150 @@
+package priorityqueue
+
+import (
+	"context"
+	"sort"
+)
+
+// ReconcileOptions tune Reconcile
+type ReconcileOptions struct {
+	// Scope selects the queued items the desired set describes, such as
+	// those of one ParentID; nil selects every item. Queued items out of
+	// scope are never deleted.
+	Scope func(*QItem) bool
+
+	// KeepExtras keeps the queued items missing from the desired set
+	KeepExtras bool
+
+	// DryRun reports the delta without changing the queue
+	DryRun bool
+
+	// Overwrite updates the queued items conflicting with the desired
+	// ones, see Reconcile, rather than leaving them alone. Either way they
+	// are listed as Conflicts.
+	Overwrite bool
+}
+
+// A ReconcileReport lists, by ID, the changes Reconcile made to the queue,
+// or would have made in a dry run
+type ReconcileReport struct {
+	Pushed  []string // Desired items that were missing
+	Deleted []string // Queued items in scope that were not desired
+	Updated []string // Queued items whose priority drifted from the desired one
+
+	// Held lists the desired items delayed or in flight, which are left
+	// alone, and Unchanged counts the desired items queued as desired.
+	Held      []string
+	Unchanged int
+
+	// Conflicts lists the desired items whose SyncToken differs from the
+	// one of the queued item, which was changed concurrently
+	Conflicts []string
+}
+
+// Reconcile converges the queue to the desired set of items, such as the
+// pending jobs of a database: desired items missing from the queue are
+// pushed, queued items in the scope of opts but not desired are deleted,
+// and queued items whose priority differs from the desired one are
+// updated. Items are matched by ID; of the desired items sharing an ID
+// only the first one counts. It returns the delta, applied under a single
+// lock, or the error of ctx if ctx is done before the queue is locked. The
+// principal carried by ctx must be authorized for every change, or none is
+// applied.
+//
+// When both a desired item and the queued item carry a SyncToken, such as
+// the version of a database row, differing tokens mean the queued item was
+// pushed from another version than the desired set was read from, such as
+// by a concurrent writer: the queued item is left alone unless
+// opts.Overwrite is set. Queued items updated or found unchanged take the
+// SyncToken of their desired item.
+func (pq *PriorityQueue) Reconcile(ctx context.Context, desired []QItem, opts ReconcileOptions) (ReconcileReport, error) {
+	var report ReconcileReport
+	unlock, err := pq.lockCtx(ctx, OpReconcile)
+	if err != nil {
+		return report, err
+	}
+	defer unlock()
+	if err := pq.mutable(); err != nil {
+		return report, err
+	}
+
+	queued, held := pq.heldIDs()
+	seen := make(map[string]bool, len(desired))
+	var push []QItem
+	type update struct {
+		old  *QItem
+		item QItem
+	}
+	var updates []update
+	for _, item := range desired {
+		key := pq.itemKey(&item)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		old, ok := queued[key]
+		switch {
+		case held[key]:
+			report.Held = append(report.Held, item.ID)
+			continue
+		case !ok:
+			report.Pushed = append(report.Pushed, item.ID)
+			push = append(push, item)
+			continue
+		}
+		if old.SyncToken != "" && item.SyncToken != "" && old.SyncToken != item.SyncToken {
+			report.Conflicts = append(report.Conflicts, item.ID)
+			if !opts.Overwrite {
+				continue
+			}
+		}
+		if old.Priority == item.Priority {
+			report.Unchanged++
+		} else {
+			report.Updated = append(report.Updated, item.ID)
+		}
+		updates = append(updates, update{old, item})
+	}
+	var extras []*QItem
+	if !opts.KeepExtras {
+		for key, item := range queued {
+			if seen[key] || (opts.Scope != nil && !opts.Scope(item)) {
+				continue
+			}
+			extras = append(extras, item)
+		}
+		sort.Slice(extras, func(i, j int) bool { return extras[i].seq < extras[j].seq })
+		for _, item := range extras {
+			report.Deleted = append(report.Deleted, item.ID)
+		}
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	// The whole delta is authorized before any of it is applied
+	touched := append([]*QItem(nil), extras...)
+	for n := range push {
+		touched = append(touched, &push[n])
+	}
+	for _, u := range updates {
+		touched = append(touched, u.old)
+	}
+	if err := pq.authorize(ctx, OpReconcile, touched...); err != nil {
+		return ReconcileReport{}, err
+	}
+	for _, u := range updates {
+		if u.item.SyncToken != "" {
+			u.old.SyncToken = u.item.SyncToken
+		}
+		if u.old.Priority != u.item.Priority {
+			pq.reprioritize(u.old, u.item.Priority)
+			pq.audit(OpReconcile, u.old)
+		}
+	}
+	for _, item := range extras {
+		pq.audit(OpReconcile, pq.remove(item.index, StateDeleted))
+	}
+	pq.insertAll(OpReconcile, push)
+	return report, nil
+}
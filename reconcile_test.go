@@ -0,0 +1,89 @@
+package priorityqueue
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Reconcile(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 4) // priorities 1 to 4
+	pq.Push(QItem{ID: "other", ParentID: "web"})
+	pq.Lease(time.Minute) // "3" is in flight
+
+	desired := []QItem{
+		{ID: "0", Priority: 1},
+		{ID: "1", Priority: 9},
+		{ID: "3", Priority: 4},
+		{ID: "new", Priority: 5},
+		{ID: "new", Priority: 6},
+	}
+	opts := ReconcileOptions{
+		Scope:  func(i *QItem) bool { return i.ParentID == "12345" },
+		DryRun: true,
+	}
+	expected := ReconcileReport{
+		Pushed:    []string{"new"},
+		Deleted:   []string{"2"},
+		Updated:   []string{"1"},
+		Held:      []string{"3"},
+		Unchanged: 1,
+	}
+	report, err := pq.Reconcile(context.Background(), desired, opts)
+	if err != nil || !reflect.DeepEqual(report, expected) {
+		t.Errorf("Dry run returned %+v, %v, expected %+v", report, err, expected)
+	}
+	assertEqual(t, pq.Len(), 4)
+
+	opts.DryRun = false
+	report, _ = pq.Reconcile(context.Background(), desired, opts)
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Reconcile returned %+v, expected %+v", report, expected)
+	}
+	var ids []string
+	for pq.Len() > 0 {
+		x, _ := pq.Pop()
+		ids = append(ids, x.ID)
+	}
+	assertEqual(t, strings.Join(ids, " "), "1 new 0 other")
+
+	// Converged, nothing changes
+	pq.Push(QItem{ID: "0", ParentID: "12345", Priority: 1})
+	report, _ = pq.Reconcile(context.Background(), desired[:1], ReconcileOptions{})
+	assertEqual(t, report.Unchanged, 1)
+	assertEqual(t, len(report.Pushed)+len(report.Deleted)+len(report.Updated), 0)
+}
+
+func Test_ReconcileSyncTokens(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.Push(QItem{ID: "a", Priority: 1, SyncToken: "v1"})
+	pq.Push(QItem{ID: "b", Priority: 1, SyncToken: "v2"}) // pushed by a concurrent writer
+	pq.Push(QItem{ID: "c", Priority: 1})
+
+	desired := []QItem{
+		{ID: "a", Priority: 2, SyncToken: "v1"},
+		{ID: "b", Priority: 2, SyncToken: "v1"},
+		{ID: "c", Priority: 1, SyncToken: "v1"},
+	}
+	report, _ := pq.Reconcile(context.Background(), desired, ReconcileOptions{})
+	assertEqual(t, strings.Join(report.Updated, " "), "a")
+	assertEqual(t, strings.Join(report.Conflicts, " "), "b")
+	assertEqual(t, report.Unchanged, 1)
+
+	tokens := make(map[string]string)
+	pq.ForEach(func(i QItem) bool {
+		tokens[i.ID] = i.SyncToken
+		return true
+	})
+	assertEqual(t, tokens["b"], "v2")
+	assertEqual(t, tokens["c"], "v1")
+
+	report, _ = pq.Reconcile(context.Background(), desired, ReconcileOptions{Overwrite: true})
+	assertEqual(t, strings.Join(report.Updated, " "), "b")
+	assertEqual(t, strings.Join(report.Conflicts, " "), "b")
+	x, _ := pq.Peek()
+	assertEqual(t, x.Priority, 2)
+}
@@ -0,0 +1,253 @@
+package priorityqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// recordingMagic starts the header line of every recording
+const recordingMagic = "pqrecording"
+
+// RecordingVersion is the version of the recording format written by Record
+const RecordingVersion = 1
+
+// recorded is one operation of a recording. Leases are identified by the
+// sequence number they had in the recorded queue.
+type recorded struct {
+	Time     time.Time     `json:"t"`
+	Op       Operation     `json:"op"`
+	Item     *itemRecord   `json:"item,omitempty"`
+	ID       string        `json:"id,omitempty"`
+	IDs      []string      `json:"ids,omitempty"`
+	ParentID string        `json:"parent_id,omitempty"`
+	Tenant   string        `json:"tenant,omitempty"`
+	Priority int           `json:"priority,omitempty"`
+	At       *time.Time    `json:"at,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Lease    uint64        `json:"lease,omitempty"`
+	Leases   []uint64      `json:"leases,omitempty"`
+	Reason   string        `json:"reason,omitempty"`
+	Worker   string        `json:"worker,omitempty"`
+	Fraction float64       `json:"fraction,omitempty"`
+	Action   FlushAction   `json:"action,omitempty"`
+}
+
+type recorder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+	err error
+}
+
+// Record starts logging the queue's operations, with their arguments and
+// the time they were made, to w until the returned function is called. The
+// recording can be applied to another queue with Replay to reproduce an
+// ordering issue. Pushes, pops, leases and their settlement, updates and
+// deletes are recorded, including those made through a QueueView's Push
+// and Pop; configuration changes are not.
+//
+// Operations are written under the queue lock, in order, so w should be
+// fast, a file rather than a network connection. The function returned
+// flushes the recording and returns the first write error.
+func (pq *PriorityQueue) Record(w io.Writer) (stop func() error) {
+	r := &recorder{w: bufio.NewWriter(w)}
+	r.enc = json.NewEncoder(r.w)
+	_, r.err = fmt.Fprintf(r.w, "%s v%d\n", recordingMagic, RecordingVersion)
+
+	pq.m.Lock()
+	pq.recorder = r
+	pq.m.Unlock()
+
+	return func() error {
+		pq.m.Lock()
+		if pq.recorder == r {
+			pq.recorder = nil
+		}
+		pq.m.Unlock()
+		if err := r.w.Flush(); r.err == nil {
+			r.err = err
+		}
+		return r.err
+	}
+}
+
+// record logs an operation if the queue is recorded. The queue lock must
+// be held.
+func (pq *PriorityQueue) record(rec recorded) {
+	r := pq.recorder
+	if r == nil || r.err != nil {
+		return
+	}
+	if pq.chaosRecordFailure() {
+		r.err = ErrInjected
+		return
+	}
+	rec.Time = pq.now()
+	r.err = r.enc.Encode(rec)
+}
+
+// recordItem returns the persisted form of an item for a recording
+func recordItem(i *QItem) *itemRecord {
+	rec := toItemRecord(i)
+	return &rec
+}
+
+func leaseSeqs(receipts []Receipt) []uint64 {
+	seqs := make([]uint64, len(receipts))
+	for n, r := range receipts {
+		seqs[n] = r.seq
+	}
+	return seqs
+}
+
+// Replay applies a recording written by Record to a new queue and returns
+// it. See ReplayInto.
+func Replay(r io.Reader, speed float64) (*PriorityQueue, error) {
+	pq := NewPriorityQueue()
+	return pq, ReplayInto(pq, r, speed)
+}
+
+// ReplayInto applies a recording written by Record to pq, which can be set
+// up beforehand like the recorded queue. With a positive speed operations are
+// spaced as they were recorded, scaled by speed, so 2 replays twice as fast;
+// with speed 0 they are applied as fast as possible. Either way the
+// queue's clock follows the recording, so lease timeouts and delays keep
+// their recorded meaning. Results may differ from the recorded ones when pq
+// is configured differently, which is the point of comparing them.
+func ReplayInto(pq *PriorityQueue, r io.Reader, speed float64) error {
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if line != fmt.Sprintf("%s v%d\n", recordingMagic, RecordingVersion) {
+		return fmt.Errorf("not a version %d queue recording", RecordingVersion)
+	}
+
+	var (
+		recordedStart time.Time
+		replayStart   = time.Now()
+		current       time.Time
+	)
+	pq.m.Lock()
+	pq.clock = func() time.Time {
+		if speed <= 0 {
+			return current
+		}
+		return recordedStart.Add(time.Duration(float64(time.Since(replayStart)) * speed))
+	}
+	pq.m.Unlock()
+
+	receipts := make(map[uint64]Receipt)
+	reservations := make(map[uint64]*Reservation)
+	dec := json.NewDecoder(br)
+	for n := 1; ; n++ {
+		var rec recorded
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decoding recorded operation %d: %w", n, err)
+		}
+		if recordedStart.IsZero() {
+			recordedStart = rec.Time
+		}
+		if speed > 0 {
+			due := replayStart.Add(time.Duration(float64(rec.Time.Sub(recordedStart)) / speed))
+			time.Sleep(time.Until(due))
+		}
+		current = rec.Time
+		replayOne(pq, rec, receipts, reservations)
+	}
+}
+
+// replayOne applies one recorded operation. Errors are those the recorded
+// queue returned too, or differences the replay is meant to reveal, so they
+// are ignored.
+func replayOne(pq *PriorityQueue, rec recorded, receipts map[uint64]Receipt, reservations map[uint64]*Reservation) {
+	lookup := func(seqs []uint64) []Receipt {
+		var rs []Receipt
+		for _, seq := range seqs {
+			if r, ok := receipts[seq]; ok {
+				rs = append(rs, r)
+				delete(receipts, seq)
+			}
+		}
+		return rs
+	}
+	switch rec.Op {
+	case OpPush:
+		i := rec.Item.qItem()
+		if rec.At != nil {
+			pq.PushDelayed(i, *rec.At)
+		} else {
+			pq.Push(i)
+		}
+	case OpPop:
+		if rec.Tenant != "" {
+			pq.Scoped(rec.Tenant).Pop()
+		} else {
+			pq.Pop()
+		}
+	case OpLease:
+		if _, r, err := pq.LeaseAs(rec.Worker, rec.Duration); err == nil {
+			receipts[rec.Lease] = r
+		}
+	case OpAck:
+		pq.AckBatch(lookup(rec.Leases))
+	case OpNack:
+		pq.nack(lookup(rec.Leases), rec.Duration, rec.Reason)
+	case OpExtendLease:
+		if r, ok := receipts[rec.Lease]; ok {
+			pq.ExtendLease(r, rec.Duration)
+		}
+	case OpDeadLetter:
+		if rs := lookup([]uint64{rec.Lease}); len(rs) == 1 {
+			pq.DeadLetter(rs[0], rec.Reason)
+		}
+	case OpReserve:
+		if res, err := pq.Reserve(); err == nil {
+			reservations[rec.Lease] = res
+		}
+	case OpCommit, OpRelease:
+		if res, ok := reservations[rec.Lease]; ok {
+			delete(reservations, rec.Lease)
+			if rec.Op == OpCommit {
+				res.Commit()
+			} else {
+				res.Release()
+			}
+		}
+	case OpUpdatePriorityByParentId:
+		pq.UpdatePriorityByParentId(rec.ParentID, rec.Priority)
+	case OpUpdatePriorityByParentTree:
+		pq.UpdatePriorityByParentTree(rec.ParentID, rec.Priority)
+	case OpUpdatePriorityByIds:
+		pq.UpdatePriorityByIds(rec.IDs, rec.Priority)
+	case OpDeleteItemById:
+		pq.DeleteItemById(rec.ID)
+	case OpDeleteItemsByParentId:
+		pq.DeleteItemsByParentId(rec.ParentID)
+	case OpDeleteItemsByParentTree:
+		pq.DeleteItemsByParentTree(rec.ParentID)
+	case OpForceRelease:
+		pq.ForceRelease(rec.ID)
+	case OpRestoreDeleted:
+		pq.RestoreDeleted(rec.ID)
+	case OpSteal:
+		if _, r, err := pq.Steal(rec.ID, rec.Worker); err == nil {
+			receipts[rec.Lease] = r
+		}
+	case OpUpdatePriorityByParentPattern:
+		pq.UpdatePriorityByParentPattern(rec.ParentID, rec.Priority)
+	case OpDeleteItemsByParentPattern:
+		pq.DeleteItemsByParentPattern(rec.ParentID)
+	case OpClear:
+		pq.Clear()
+	case OpFlush:
+		pq.FlushBelowPercentile(rec.Fraction, rec.Action)
+	}
+}
@@ -0,0 +1,82 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_RecordReplay(t *testing.T) {
+	pq := NewPriorityQueue()
+	var buf bytes.Buffer
+	stop := pq.Record(&buf)
+
+	populateQueue(pq, 5)
+	pq.Pop()
+	_, r, _ := pq.Lease(time.Minute)
+	pq.Nack(r, 0)
+	_, r, _ = pq.Lease(time.Minute)
+	pq.Ack(r)
+	pq.UpdatePriorityByParentId("12345", 7)
+	pq.DeleteItemById("1")
+	res, _ := pq.Reserve()
+	res.Release()
+	pq.Scoped("acme").Push(QItem{ID: "t", Priority: 100})
+	pq.PushDelayed(QItem{ID: "d"}, time.Now().Add(time.Hour))
+	if err := stop(); err != nil {
+		t.Errorf("Error recording: %v", err)
+	}
+	pq.Push(QItem{ID: "not recorded"})
+
+	replayed, err := Replay(&buf, 0)
+	if err != nil {
+		t.Errorf("Error replaying: %v", err)
+		return
+	}
+	assertEqual(t, replayed.Len(), 3)
+	assertEqual(t, replayed.State("3"), StateAcked)
+	assertEqual(t, replayed.State("1"), StateDeleted)
+	assertEqual(t, replayed.State("d"), StateDelayed)
+	item, _ := replayed.Peek()
+	assertEqual(t, item.Tenant, "acme")
+}
+
+func Test_ReplayDifferentConfig(t *testing.T) {
+	pq := NewPriorityQueue()
+	var buf bytes.Buffer
+	stop := pq.Record(&buf)
+	pq.Push(QItem{ID: "batch", ParentID: "batch", Priority: 1})
+	pq.Push(QItem{ID: "web", ParentID: "web", Priority: 5})
+	pq.Pop()
+	stop()
+
+	replayed := NewPriorityQueue()
+	replayed.SetParentPriority("batch", 100)
+	if err := ReplayInto(replayed, &buf, 0); err != nil {
+		t.Errorf("Error replaying: %v", err)
+	}
+	assertEqual(t, replayed.State("batch"), StatePopped)
+	assertEqual(t, replayed.State("web"), StateQueued)
+}
+
+func Test_ReplaySpeed(t *testing.T) {
+	start := time.Now()
+	recording := "pqrecording v1\n" +
+		`{"t":"2020-01-01T00:00:00Z","op":"Push","item":{"id":"1","priority":1}}` + "\n" +
+		`{"t":"2020-01-01T00:00:00.1Z","op":"Pop"}` + "\n"
+	pq, err := Replay(strings.NewReader(recording), 10)
+	if err != nil {
+		t.Errorf("Error replaying: %v", err)
+	}
+	assertEqual(t, pq.State("1"), StatePopped)
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Errorf("Error: replay at speed 10 took %v", d)
+	}
+}
+
+func Test_ReplayNotRecording(t *testing.T) {
+	if _, err := Replay(strings.NewReader("pqsnapshot v1\n"), 0); err == nil {
+		t.Errorf("Error: a snapshot was replayed")
+	}
+}
@@ -0,0 +1,100 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// A RecoveryReport describes what Recover restored, so operators can alert
+// on a partial recovery instead of discovering the missing work later.
+type RecoveryReport struct {
+	Restored int // Items added to the queue
+
+	// Skipped counts the items lost with damaged segments, as far as they
+	// could be counted; Corrupted lists the damage.
+	Skipped   int
+	Corrupted []CorruptionError
+
+	// Duplicates counts the items dropped because their ID was already
+	// queued or in flight, or appeared earlier in the snapshot.
+	Duplicates int
+
+	Duration time.Duration // Time taken by the recovery
+}
+
+// Partial reports whether anything of the snapshot was lost
+func (r RecoveryReport) Partial() bool {
+	return len(r.Corrupted) > 0
+}
+
+func (r RecoveryReport) String() string {
+	return fmt.Sprintf("restored %d items in %v, skipped %d in %d damaged segments, dropped %d duplicate IDs",
+		r.Restored, r.Duration, r.Skipped, len(r.Corrupted), r.Duplicates)
+}
+
+// Recover restores a snapshot at startup, as RestoreBestEffort does, and
+// resolves duplicate IDs: of the items sharing an ID only the first one of
+// the snapshot, its highest priority one, is kept, and items whose ID is
+// already queued or in flight are dropped. It returns a report of the
+// recovery, along with an error if the snapshot cannot be read at all.
+func (pq *PriorityQueue) Recover(r io.Reader) (RecoveryReport, error) {
+	start := time.Now()
+	var report RecoveryReport
+	items, err := decodeSnapshot(r, func(e *CorruptionError) bool {
+		report.Corrupted = append(report.Corrupted, *e)
+		report.Skipped += e.Items
+		return true
+	})
+	if err != nil {
+		report.Duration = time.Since(start)
+		return report, err
+	}
+
+	unlock := pq.lock(OpRestore)
+	if err := pq.mutable(); err != nil {
+		unlock()
+		return report, err
+	}
+	seen := make(map[string]bool, len(pq.data)+len(pq.leases)+len(items))
+	for _, item := range pq.data {
+		if item.tombstone == "" {
+			seen[pq.itemKey(item)] = true
+		}
+	}
+	for _, l := range pq.leases {
+		seen[pq.itemKey(&l.item)] = true
+	}
+	unique := items[:0]
+	for _, item := range items {
+		key := pq.itemKey(&item)
+		if seen[key] {
+			report.Duplicates++
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, item)
+	}
+	pq.insertAll(OpRestore, unique)
+	unlock()
+
+	report.Restored = len(unique)
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// WithRecovery recovers the snapshot read from r, see Recover, storing the
+// report in report unless it is nil. Only a snapshot that cannot be read at
+// all fails the option; check the report for a partial recovery.
+func WithRecovery(r io.Reader, report *RecoveryReport) Option {
+	return func(pq *PriorityQueue) error {
+		rep, err := pq.Recover(r)
+		if report != nil {
+			*report = rep
+		}
+		if err != nil {
+			return fmt.Errorf("recovering the snapshot: %w", err)
+		}
+		return nil
+	}
+}
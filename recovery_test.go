@@ -0,0 +1,34 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_Recover(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 2500)
+	pq.Push(QItem{ID: "10", Priority: 0})
+	var buf bytes.Buffer
+	pq.Snapshot(&buf)
+	snapshot := buf.Bytes()
+	second := bytes.Index(snapshot, []byte("segment 1 "))
+	snapshot[second+bytes.IndexByte(snapshot[second:], '\n')+10] ^= 0xff
+
+	var report RecoveryReport
+	restored, err := New(WithRecovery(bytes.NewReader(snapshot), &report))
+	assertEqual(t, err, nil)
+	assertEqual(t, report.Partial(), true)
+	assertEqual(t, report.Skipped, 1000)
+	assertEqual(t, len(report.Corrupted), 1)
+	// The copy of ID 10 pushed with the lowest priority is dropped
+	assertEqual(t, report.Duplicates, 1)
+	assertEqual(t, report.Restored, 1500)
+	assertEqual(t, restored.Len(), 1500)
+
+	report, err = restored.Recover(bytes.NewReader(snapshot))
+	assertEqual(t, err, nil)
+	assertEqual(t, report.Restored, 0)
+	assertEqual(t, report.Duplicates, 1501)
+	assertEqual(t, restored.Len(), 1500)
+}
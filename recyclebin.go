@@ -0,0 +1,101 @@
+package priorityqueue
+
+import (
+	"context"
+	"time"
+)
+
+// recycled is a deleted item kept for RestoreDeleted
+type recycled struct {
+	item QItem
+	at   time.Time // When the item was deleted
+}
+
+// SetRecycleWindow makes DeleteItemById and DeleteWhere keep the items they
+// delete for d, during which RestoreDeleted queues them again, so an
+// operator's mistaken cleanup can be undone. Zero, the default, deletes
+// items for good and empties the recycle area.
+func (pq *PriorityQueue) SetRecycleWindow(d time.Duration) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.recycleWindow = d
+	pq.pruneRecycled(pq.now())
+}
+
+// recycle keeps a copy of item, being deleted, if a recycle window is set.
+// The queue lock must be held.
+func (pq *PriorityQueue) recycle(item *QItem) {
+	if pq.recycleWindow <= 0 {
+		return
+	}
+	now := pq.now()
+	pq.pruneRecycled(now)
+	if pq.recycled == nil {
+		pq.recycled = make(map[string]*recycled)
+	}
+	key := pq.itemKey(item)
+	if old, ok := pq.recycled[key]; ok {
+		pq.dropBlob(old.item.Value)
+	}
+	r := &recycled{item: *item, at: now}
+	r.item.state = StateDeleted
+	pq.recycled[key] = r
+	pq.recycleOrder = append(pq.recycleOrder, r)
+}
+
+// pruneRecycled forgets the items deleted longer than the recycle window
+// ago. The queue lock must be held.
+func (pq *PriorityQueue) pruneRecycled(now time.Time) {
+	n := 0
+	for ; n < len(pq.recycleOrder); n++ {
+		r := pq.recycleOrder[n]
+		if pq.recycleWindow > 0 && now.Sub(r.at) < pq.recycleWindow {
+			break
+		}
+		key := pq.itemKey(&r.item)
+		if pq.recycled[key] == r {
+			delete(pq.recycled, key)
+			pq.dropBlob(r.item.Value)
+		}
+	}
+	pq.recycleOrder = pq.recycleOrder[n:]
+}
+
+// RestoreDeleted queues again the item with the given ID that DeleteItemById
+// or DeleteWhere deleted within the recycle window, see SetRecycleWindow,
+// keeping its priority and PushedAt. Of an ID deleted several times the
+// latest item is restored. It fails with ErrNotFound once the window has
+// passed or if the item was restored already.
+func (pq *PriorityQueue) RestoreDeleted(id string) error {
+	defer pq.lock(OpRestoreDeleted)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpRestoreDeleted, ID: id})
+	pq.pruneRecycled(pq.now())
+	key := pq.lookupKey(id)
+	r, ok := pq.recycled[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := pq.authorize(context.Background(), OpRestoreDeleted, &r.item); err != nil {
+		return err
+	}
+	delete(pq.recycled, key)
+	pq.audit(OpRestoreDeleted, pq.insert(r.item))
+	return nil
+}
+
+// Recycled returns the deleted items RestoreDeleted can still restore,
+// the earliest deleted first
+func (pq *PriorityQueue) Recycled() []QItem {
+	defer pq.lock(OpStats)()
+	pq.pruneRecycled(pq.now())
+	items := make([]QItem, 0, len(pq.recycled))
+	for _, r := range pq.recycleOrder {
+		if pq.recycled[pq.itemKey(&r.item)] == r {
+			items = append(items, r.item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,40 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RestoreDeleted(t *testing.T) {
+	now := time.Now()
+	pq, _ := New(WithClock(func() time.Time { return now }), WithRecycleWindow(time.Minute))
+	populateQueue(pq, 5)
+
+	assertEqual(t, pq.DeleteItemById("3"), nil)
+	n, _ := pq.DeleteWhere(func(item QItem) bool { return item.Priority < 3 })
+	assertEqual(t, n, 2)
+	assertEqual(t, pq.Len(), 2)
+	assertEqual(t, len(pq.Recycled()), 3)
+	assertEqual(t, pq.Recycled()[0].ID, "3")
+
+	assertEqual(t, pq.RestoreDeleted("3"), nil)
+	assertEqual(t, pq.RestoreDeleted("3"), ErrNotFound)
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "4")
+	assertEqual(t, pq.State("3"), StateQueued)
+
+	now = now.Add(time.Minute)
+	assertEqual(t, pq.RestoreDeleted("0"), ErrNotFound)
+	assertEqual(t, len(pq.Recycled()), 0)
+	assertEqual(t, pq.Len(), 3)
+	if err := pq.Healthy(); err != nil {
+		t.Errorf("Error restoring deleted items: %v", err)
+	}
+}
+
+func Test_RestoreDeletedWithoutWindow(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 2)
+	pq.DeleteItemById("1")
+	assertEqual(t, pq.RestoreDeleted("1"), ErrNotFound)
+}
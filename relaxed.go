@@ -0,0 +1,234 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// RelaxedQueue is a Queue trading exact ordering for throughput under
+// contention. Its items are spread round robin over shards, each a heap
+// with its own lock, and the priority at the head of each shard is
+// published so that Pop finds the best shard without taking any lock.
+//
+// The relaxation is bounded by maxSkip, see NewRelaxedQueue: when the
+// shard holding the best head is locked by another goroutine, Pop tries
+// the shards with the next best heads, skipping at most maxSkip busy
+// shards before waiting for a lock. The item returned is thus the head of
+// one of the maxSkip+1 best shards, and only items of the shards skipped
+// can outrank it. A queue used by one goroutine at a time is exact, and
+// with maxSkip zero Pop always waits for the best shard, ordering items
+// as PriorityQueue does but for the pushes and pops racing with it. Items
+// of equal priority come out in no particular order.
+type RelaxedQueue struct {
+	shards  []relaxedShard
+	next    atomic.Uint64 // Shard of the next push, round robin
+	size    atomic.Int64
+	maxSkip int
+}
+
+type relaxedShard struct {
+	m    sync.Mutex
+	data QItems
+	head atomic.Int64 // Priority of the head, emptyShard if there is none
+}
+
+const emptyShard = math.MinInt64
+
+var _ Queue = (*RelaxedQueue)(nil)
+
+// NewRelaxedQueue returns a RelaxedQueue of the given number of shards,
+// GOMAXPROCS if it is not positive, whose Pop skips at most maxSkip busy
+// shards.
+func NewRelaxedQueue(shards, maxSkip int) *RelaxedQueue {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	rq := &RelaxedQueue{shards: make([]relaxedShard, shards), maxSkip: maxSkip}
+	for n := range rq.shards {
+		rq.shards[n].head.Store(emptyShard)
+	}
+	return rq
+}
+
+// publish records the priority at the head of the shard. The shard lock
+// must be held.
+func (s *relaxedShard) publish() {
+	if len(s.data) == 0 {
+		s.head.Store(emptyShard)
+		return
+	}
+	s.head.Store(int64(s.data[0].Priority))
+}
+
+// best returns the shards holding items, by descending head priority
+func (rq *RelaxedQueue) best() []int {
+	order := make([]int, 0, len(rq.shards))
+	heads := make([]int64, 0, len(rq.shards))
+	for n := range rq.shards {
+		head := rq.shards[n].head.Load()
+		if head == emptyShard {
+			continue
+		}
+		k := len(order)
+		order, heads = append(order, n), append(heads, head)
+		for ; k > 0 && heads[k-1] < head; k-- {
+			order[k], heads[k] = order[k-1], heads[k-1]
+		}
+		order[k], heads[k] = n, head
+	}
+	return order
+}
+
+// acquire locks one of the best shards, see RelaxedQueue, returning nil if
+// the queue is empty.
+func (rq *RelaxedQueue) acquire() *relaxedShard {
+	for {
+		order := rq.best()
+		if len(order) == 0 {
+			return nil
+		}
+		if len(order) > rq.maxSkip+1 {
+			order = order[:rq.maxSkip+1]
+		}
+		var s *relaxedShard
+		for k, n := range order {
+			if k == len(order)-1 {
+				s = &rq.shards[n]
+				s.m.Lock()
+				break
+			}
+			if rq.shards[n].m.TryLock() {
+				s = &rq.shards[n]
+				break
+			}
+		}
+		if len(s.data) > 0 {
+			return s
+		}
+		// Emptied since its head was read
+		s.m.Unlock()
+	}
+}
+
+func (rq *RelaxedQueue) Push(i QItem) error {
+	stamp(&i)
+	s := &rq.shards[rq.next.Add(1)%uint64(len(rq.shards))]
+	s.m.Lock()
+	defer s.m.Unlock()
+	i.index = len(s.data)
+	s.data = append(s.data, &i)
+	s.data.fix(i.index, nil)
+	s.publish()
+	rq.size.Add(1)
+	return nil
+}
+
+func (rq *RelaxedQueue) Pop() (*QItem, error) {
+	s := rq.acquire()
+	if s == nil {
+		return nil, ErrEmptyQueue
+	}
+	defer s.m.Unlock()
+	item := s.data.remove(0, nil)
+	s.publish()
+	rq.size.Add(-1)
+	return item, nil
+}
+
+func (rq *RelaxedQueue) Peek() (*QItem, error) {
+	s := rq.acquire()
+	if s == nil {
+		return nil, ErrEmptyQueue
+	}
+	defer s.m.Unlock()
+	item := *s.data[0]
+	return &item, nil
+}
+
+// Len returns the number of items, which may be off by the pushes and
+// pops in progress.
+func (rq *RelaxedQueue) Len() int {
+	return int(rq.size.Load())
+}
+
+func (rq *RelaxedQueue) Clear() {
+	rq.each(func(s *relaxedShard) {
+		rq.size.Add(-int64(len(s.data)))
+		s.data = nil
+	})
+}
+
+// each calls f with every shard locked in turn
+func (rq *RelaxedQueue) each(f func(s *relaxedShard)) {
+	for n := range rq.shards {
+		s := &rq.shards[n]
+		s.m.Lock()
+		f(s)
+		s.publish()
+		s.m.Unlock()
+	}
+}
+
+func (rq *RelaxedQueue) UpdatePriorityByParentId(parentID string, priority int) int {
+	updated := 0
+	rq.each(func(s *relaxedShard) {
+		n := 0
+		for _, item := range s.data {
+			if item.ParentID == parentID {
+				item.Priority = priority
+				n++
+			}
+		}
+		if n > 0 {
+			s.data.init(nil)
+		}
+		updated += n
+	})
+	return updated
+}
+
+func (rq *RelaxedQueue) DeleteItemById(id string) error {
+	found := false
+	rq.each(func(s *relaxedShard) {
+		if found {
+			return
+		}
+		for _, item := range s.data {
+			if item.ID == id {
+				s.data.remove(item.index, nil)
+				rq.size.Add(-1)
+				found = true
+				return
+			}
+		}
+	})
+	if !found {
+		return fmt.Errorf("%w: [%s]", ErrNotFound, id)
+	}
+	return nil
+}
+
+func (rq *RelaxedQueue) DeleteItemsByParentId(parentID string) (int, error) {
+	deleted := 0
+	rq.each(func(s *relaxedShard) {
+		rest := s.data[:0]
+		for _, item := range s.data {
+			if item.ParentID != parentID {
+				item.index = len(rest)
+				rest = append(rest, item)
+			}
+		}
+		n := len(s.data) - len(rest)
+		if n > 0 {
+			clear(s.data[len(rest):])
+			s.data = rest
+			s.data.init(nil)
+			rq.size.Add(-int64(n))
+		}
+		deleted += n
+	})
+	return deleted, nil
+}
@@ -0,0 +1,114 @@
+package priorityqueue
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func Test_RelaxedQueueExactWhenUncontended(t *testing.T) {
+	rq := NewRelaxedQueue(4, 2)
+	for n := 0; n < 50; n++ {
+		rq.Push(QItem{ID: strconv.Itoa(n), Priority: n % 7})
+	}
+	rq.Push(QItem{ID: "top", Priority: 1000})
+	assertEqual(t, rq.Len(), 51)
+
+	top, _ := rq.Peek()
+	assertEqual(t, top.ID, "top")
+	last := 1001
+	for rq.Len() > 0 {
+		item, err := rq.Pop()
+		if err != nil {
+			t.Fatalf("Error popping: %v", err)
+		}
+		if item.Priority > last {
+			t.Fatalf("Item %v popped after priority %d", item, last)
+		}
+		last = item.Priority
+	}
+	if _, err := rq.Pop(); err != ErrEmptyQueue {
+		t.Errorf("Expected ErrEmptyQueue, got %v", err)
+	}
+}
+
+func Test_RelaxedQueueConcurrent(t *testing.T) {
+	rq := NewRelaxedQueue(0, 1)
+	var wg sync.WaitGroup
+	for p := 0; p < 8; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for n := 0; n < 500; n++ {
+				rq.Push(QItem{ID: strconv.Itoa(p*500 + n), Priority: n})
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	var m sync.Mutex
+	seen := make(map[string]bool)
+	for c := 0; c < 8; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, err := rq.Pop()
+				if err == ErrEmptyQueue {
+					return
+				}
+				m.Lock()
+				if seen[item.ID] {
+					t.Errorf("Item %s popped twice", item.ID)
+				}
+				seen[item.ID] = true
+				m.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assertEqual(t, len(seen), 4000)
+	assertEqual(t, rq.Len(), 0)
+}
+
+func Test_RelaxedQueueBulkOperations(t *testing.T) {
+	rq := NewRelaxedQueue(3, 0)
+	for n := 0; n < 10; n++ {
+		rq.Push(QItem{ID: strconv.Itoa(n), ParentID: "12345", Priority: n})
+	}
+	rq.Push(QItem{ID: "other", ParentID: "p", Priority: 1})
+
+	assertEqual(t, rq.UpdatePriorityByParentId("p", 100), 1)
+	top, _ := rq.Peek()
+	assertEqual(t, top.ID, "other")
+
+	if err := rq.DeleteItemById("3"); err != nil {
+		t.Errorf("Error deleting item: %v", err)
+	}
+	n, _ := rq.DeleteItemsByParentId("12345")
+	assertEqual(t, n, 9)
+	assertEqual(t, rq.Len(), 1)
+	rq.Clear()
+	assertEqual(t, rq.Len(), 0)
+}
+
+func Benchmark_RelaxedPushPopParallel(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		q    Queue
+	}{
+		{"PriorityQueue", NewPriorityQueue()},
+		{"RelaxedQueue", NewRelaxedQueue(0, 2)},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				n := 0
+				for pb.Next() {
+					bench.q.Push(QItem{Priority: n % 1000})
+					bench.q.Pop()
+					n++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package priorityqueue
+
+import (
+	"errors"
+	"sync"
+)
+
+// A ReplicationRule selects and transforms the items a Replicator copies to
+// a follower, so the follower can serve another purpose than the primary,
+// such as an urgent-only mirror for a dedicated pool of workers.
+type ReplicationRule struct {
+	// Match selects the items replicated, every item if nil. MatchPriority
+	// replicates the items above a priority.
+	Match Matcher
+
+	// Priority, when not nil, rewrites the priority of the items replicated
+	Priority *int
+
+	// StripValue replicates the items without their Value, for followers
+	// that only need to know about the work
+	StripValue bool
+}
+
+// apply returns the copy of i to replicate, reporting false if the rule
+// does not select it.
+func (r ReplicationRule) apply(i QItem) (QItem, bool) {
+	if r.Match != nil && !r.Match(&i) {
+		return i, false
+	}
+	if r.Priority != nil {
+		i.Priority = *r.Priority
+	}
+	if r.StripValue {
+		i.Value = nil
+	}
+	return i, true
+}
+
+// ReplicationStats counts what a Replicator did for a follower
+type ReplicationStats struct {
+	Replicated int // Items pushed to the follower
+	Filtered   int // Items the rule did not select
+
+	// Failed counts the operations the follower failed, other than those
+	// of items it did not hold
+	Failed int
+}
+
+type follower struct {
+	q     Queue
+	rule  ReplicationRule
+	stats ReplicationStats
+}
+
+// A Replicator is a Queue serving a primary queue and replicating its
+// changes to follower queues, each through its own ReplicationRule. Pushes
+// are replicated once the primary accepted them; pops, deletes and clears
+// remove the items from the followers too. Priority updates are replicated
+// to the followers whose rule keeps the priority, regardless of Match: the
+// rule only applies to items as they are pushed.
+//
+// Followers never affect results: their errors are counted in the stats of
+// the follower and otherwise ignored. A follower may be consumed by its own
+// workers; removing items it no longer holds is not an error.
+type Replicator struct {
+	primary Queue
+
+	m         sync.Mutex
+	followers map[string]*follower
+}
+
+var _ Queue = (*Replicator)(nil)
+
+// NewReplicator returns a Replicator serving primary, without followers
+func NewReplicator(primary Queue) *Replicator {
+	return &Replicator{primary: primary, followers: make(map[string]*follower)}
+}
+
+// Follow starts replicating the changes made from now on to the follower q
+// registered as name, through rule. Following under a name already used
+// replaces that follower.
+func (r *Replicator) Follow(name string, q Queue, rule ReplicationRule) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.followers[name] = &follower{q: q, rule: rule}
+}
+
+// Unfollow stops replicating to the follower registered as name
+func (r *Replicator) Unfollow(name string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	delete(r.followers, name)
+}
+
+// each calls f for every follower, counting the errors it returns. The
+// replicator lock serializes the replication of concurrent operations.
+func (r *Replicator) each(f func(f *follower) error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	for _, fl := range r.followers {
+		if err := f(fl); err != nil && !errors.Is(err, ErrNotFound) {
+			fl.stats.Failed++
+		}
+	}
+}
+
+func (r *Replicator) Push(i QItem) error {
+	if err := r.primary.Push(i); err != nil {
+		return err
+	}
+	r.each(func(f *follower) error {
+		item, ok := f.rule.apply(i)
+		if !ok {
+			f.stats.Filtered++
+			return nil
+		}
+		f.stats.Replicated++
+		return f.q.Push(item)
+	})
+	return nil
+}
+
+func (r *Replicator) Pop() (*QItem, error) {
+	item, err := r.primary.Pop()
+	if err != nil {
+		return item, err
+	}
+	r.remove(item.ID)
+	return item, nil
+}
+
+// remove deletes an item the primary no longer holds from the followers
+func (r *Replicator) remove(id string) {
+	r.each(func(f *follower) error {
+		return f.q.DeleteItemById(id)
+	})
+}
+
+func (r *Replicator) Peek() (*QItem, error) {
+	return r.primary.Peek()
+}
+
+func (r *Replicator) Len() int {
+	return r.primary.Len()
+}
+
+func (r *Replicator) Clear() {
+	r.primary.Clear()
+	r.each(func(f *follower) error {
+		f.q.Clear()
+		return nil
+	})
+}
+
+func (r *Replicator) UpdatePriorityByParentId(parentID string, priority int) int {
+	n := r.primary.UpdatePriorityByParentId(parentID, priority)
+	r.each(func(f *follower) error {
+		if f.rule.Priority == nil {
+			f.q.UpdatePriorityByParentId(parentID, priority)
+		}
+		return nil
+	})
+	return n
+}
+
+func (r *Replicator) DeleteItemById(id string) error {
+	if err := r.primary.DeleteItemById(id); err != nil {
+		return err
+	}
+	r.remove(id)
+	return nil
+}
+
+func (r *Replicator) DeleteItemsByParentId(parentID string) (int, error) {
+	n, err := r.primary.DeleteItemsByParentId(parentID)
+	if err != nil {
+		return n, err
+	}
+	r.each(func(f *follower) error {
+		_, err := f.q.DeleteItemsByParentId(parentID)
+		return err
+	})
+	return n, nil
+}
+
+// Stats returns the replication stats of every follower, by name
+func (r *Replicator) Stats() map[string]ReplicationStats {
+	r.m.Lock()
+	defer r.m.Unlock()
+	stats := make(map[string]ReplicationStats, len(r.followers))
+	for name, f := range r.followers {
+		stats[name] = f.stats
+	}
+	return stats
+}
+
+// Capabilities returns those of the primary queue
+func (r *Replicator) Capabilities() Capabilities {
+	return CapabilitiesOf(r.primary)
+}
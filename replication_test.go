@@ -0,0 +1,60 @@
+package priorityqueue
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_ReplicatorRules(t *testing.T) {
+	primary, urgent, all := NewPriorityQueue(), NewPriorityQueue(), NewPriorityQueue()
+	r := NewReplicator(primary)
+	top := 1000
+	r.Follow("urgent", urgent, ReplicationRule{Match: MatchPriority(900, math.MaxInt), Priority: &top, StripValue: true})
+	r.Follow("all", all, ReplicationRule{})
+
+	r.Push(QItem{ID: "1", ParentID: "a", Value: "payload", Priority: 950})
+	r.Push(QItem{ID: "2", ParentID: "a", Value: "payload", Priority: 10})
+	r.Push(QItem{ID: "3", ParentID: "b", Value: "payload", Priority: 920})
+	assertEqual(t, primary.Len(), 3)
+	assertEqual(t, all.Len(), 3)
+	assertEqual(t, urgent.Len(), 2)
+
+	item, _ := urgent.Peek()
+	assertEqual(t, item.Priority, 1000)
+	assertEqual(t, item.Value, nil)
+
+	// The urgent follower keeps its rewritten priorities
+	r.UpdatePriorityByParentId("a", 5)
+	item, _ = all.Peek()
+	assertEqual(t, item.ID, "3")
+	item, _ = urgent.Peek()
+	assertEqual(t, item.Priority, 1000)
+
+	// Its own workers popping items is no failure
+	urgent.Pop()
+	urgent.Pop()
+	r.Pop()
+	r.DeleteItemById("1")
+	assertEqual(t, all.Len(), 1)
+
+	stats := r.Stats()
+	assertEqual(t, stats["urgent"], ReplicationStats{Replicated: 2, Filtered: 1})
+	assertEqual(t, stats["all"], ReplicationStats{Replicated: 3})
+}
+
+func Test_ReplicatorFollowerFailures(t *testing.T) {
+	follower := NewPriorityQueue()
+	r := NewReplicator(NewPriorityQueue())
+	r.Follow("f", follower, ReplicationRule{})
+	follower.Destroy()
+
+	if err := r.Push(QItem{ID: "1"}); err != nil {
+		t.Errorf("Follower failure returned: %v", err)
+	}
+	assertEqual(t, r.Len(), 1)
+	assertEqual(t, r.Stats()["f"].Failed, 1)
+
+	r.Unfollow("f")
+	r.Push(QItem{ID: "2"})
+	assertEqual(t, len(r.Stats()), 0)
+}
@@ -0,0 +1,64 @@
+package priorityqueue
+
+// A Reservation holds the highest priority item for a consumer that hands
+// it over to another system, see Reserve.
+type Reservation struct {
+	Item *QItem // A copy of the reserved item
+
+	pq      *PriorityQueue
+	receipt Receipt
+}
+
+// Reserve takes the highest priority item out of circulation until the
+// reservation is committed or released. The item is not lost meanwhile: it
+// is reported as StateInFlight and still written by Snapshot, so a consumer
+// can commit the reservation only once the item is stored elsewhere. Unlike
+// a lease a reservation does not expire.
+func (pq *PriorityQueue) Reserve() (*Reservation, error) {
+	defer pq.lock(OpReserve)()
+	if pq.chaosEmpty() {
+		return nil, ErrEmptyQueue
+	}
+	item, r, err := pq.lease(OpReserve, "", 0)
+	if item == nil {
+		return nil, err
+	}
+	return &Reservation{Item: item, pq: pq, receipt: r}, err
+}
+
+// Commit removes the reserved item from the queue for good
+func (r *Reservation) Commit() error {
+	pq := r.pq
+	defer pq.lock(OpCommit)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpCommit, Lease: r.receipt.seq})
+	l, err := pq.takeLease(r.receipt)
+	if err != nil {
+		return err
+	}
+	pq.transition(&l.item, StateAcked)
+	pq.complete(&l.item)
+	pq.audit(OpCommit, &l.item)
+	return nil
+}
+
+// Release puts the reserved item back in the queue as it was, without
+// counting the reservation as an attempt.
+func (r *Reservation) Release() error {
+	pq := r.pq
+	defer pq.lock(OpRelease)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	pq.record(recorded{Op: OpRelease, Lease: r.receipt.seq})
+	l, err := pq.takeLease(r.receipt)
+	if err != nil {
+		return err
+	}
+	l.item.Attempts--
+	pq.unattempt(&l.item)
+	pq.audit(OpRelease, pq.insert(l.item))
+	return nil
+}
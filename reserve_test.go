@@ -0,0 +1,64 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_ReserveCommit(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+
+	r, err := pq.Reserve()
+	if err != nil {
+		t.Errorf("Error reserving item: %v", err)
+		return
+	}
+	assertEqual(t, r.Item.ID, "2")
+	assertEqual(t, pq.Len(), 2)
+	assertEqual(t, pq.State("2"), StateInFlight)
+
+	if err := r.Commit(); err != nil {
+		t.Errorf("Error committing reservation: %v", err)
+	}
+	assertEqual(t, pq.State("2"), StateAcked)
+	if err := r.Release(); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("Error releasing a committed reservation: %v", err)
+	}
+}
+
+func Test_ReserveRelease(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+
+	r, _ := pq.Reserve()
+	if err := r.Release(); err != nil {
+		t.Errorf("Error releasing reservation: %v", err)
+	}
+	assertEqual(t, pq.Len(), 3)
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "2")
+	assertEqual(t, item.Attempts, 0)
+}
+
+func Test_ReservedItemsAreSnapshotted(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	r, _ := pq.Reserve()
+
+	var buf bytes.Buffer
+	if err := pq.Snapshot(&buf); err != nil {
+		t.Errorf("Error writing snapshot: %v", err)
+	}
+	restored := NewPriorityQueue()
+	restored.Restore(&buf)
+	assertEqual(t, restored.Len(), 3)
+
+	r.Commit()
+	buf.Reset()
+	pq.Snapshot(&buf)
+	restored = NewPriorityQueue()
+	restored.Restore(&buf)
+	assertEqual(t, restored.Len(), 2)
+}
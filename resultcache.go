@@ -0,0 +1,117 @@
+package priorityqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A ResultHandler processes one item and returns its result, see
+// ResultCache
+type ResultHandler func(ctx context.Context, item *QItem) (interface{}, error)
+
+// A ResultCache remembers the results of the items handled successfully,
+// for ttl, so an identical item pushed again meanwhile, such as during a
+// retry storm, gets the result without being handled again. Items are
+// identical when their key is: their ID by default, or the hash of their
+// Value with ByContentHash.
+type ResultCache struct {
+	ttl time.Duration
+	key func(*QItem) string
+
+	m       sync.Mutex
+	results map[string]cachedResult
+	stored  []completion // When each key was stored, oldest first
+	hits    int
+}
+
+// cachedResult is a result stored in a ResultCache
+type cachedResult struct {
+	result interface{}
+	at     time.Time
+}
+
+// NewResultCache returns an empty ResultCache keeping results for ttl,
+// keyed by key or by the item ID if key is nil. Items whose key is empty
+// are not cached.
+func NewResultCache(ttl time.Duration, key func(*QItem) string) *ResultCache {
+	if key == nil {
+		key = func(i *QItem) string { return i.ID }
+	}
+	return &ResultCache{ttl: ttl, key: key, results: make(map[string]cachedResult)}
+}
+
+// ByContentHash returns a ResultCache key hashing the Value of the items,
+// such as with the hash given to SetContentHash
+func ByContentHash(hash func(value interface{}) string) func(*QItem) string {
+	return func(i *QItem) string { return hash(i.Value) }
+}
+
+// Handler returns a Handler running fn on the items without a cached
+// result and calling done with the result of every item, cached or not.
+// Only the results of the runs of fn that succeeded are cached.
+func (c *ResultCache) Handler(fn ResultHandler, done func(item *QItem, result interface{}, err error)) Handler {
+	return func(ctx context.Context, item *QItem) error {
+		result, ok := c.Lookup(item)
+		if !ok {
+			var err error
+			if result, err = fn(ctx, item); err != nil {
+				done(item, nil, err)
+				return err
+			}
+			c.Store(item, result)
+		}
+		done(item, result, nil)
+		return nil
+	}
+}
+
+// Lookup returns the cached result of item, reporting whether there is one
+func (c *ResultCache) Lookup(item *QItem) (interface{}, bool) {
+	key := c.key(item)
+	if key == "" {
+		return nil, false
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.expire(time.Now())
+	r, ok := c.results[key]
+	if ok {
+		c.hits++
+	}
+	return r.result, ok
+}
+
+// Store caches the result of item
+func (c *ResultCache) Store(item *QItem, result interface{}) {
+	key := c.key(item)
+	if key == "" {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	now := time.Now()
+	c.expire(now)
+	c.results[key] = cachedResult{result: result, at: now}
+	c.stored = append(c.stored, completion{key: key, at: now})
+}
+
+// Hits returns the number of items that got a cached result
+func (c *ResultCache) Hits() int {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.hits
+}
+
+// expire forgets the results stored ttl ago or earlier. The cache lock
+// must be held.
+func (c *ResultCache) expire(now time.Time) {
+	cutoff := now.Add(-c.ttl)
+	for len(c.stored) > 0 && !c.stored[0].at.After(cutoff) {
+		key := c.stored[0].key
+		c.stored = c.stored[1:]
+		if r, ok := c.results[key]; ok && !r.at.After(cutoff) {
+			delete(c.results, key)
+		}
+	}
+}
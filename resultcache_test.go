@@ -0,0 +1,55 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_ResultCache(t *testing.T) {
+	c := NewResultCache(20*time.Millisecond, nil)
+	runs := 0
+	results := make(map[string]interface{})
+	h := c.Handler(func(ctx context.Context, item *QItem) (interface{}, error) {
+		runs++
+		if item.ID == "bad" {
+			return nil, errors.New("boom")
+		}
+		return fmt.Sprintf("result %d of %s", runs, item.ID), nil
+	}, func(item *QItem, result interface{}, err error) {
+		results[item.ID] = result
+	})
+
+	ctx := context.Background()
+	h(ctx, &QItem{ID: "a"})
+	h(ctx, &QItem{ID: "a"})
+	assertEqual(t, runs, 1)
+	assertEqual(t, results["a"], "result 1 of a")
+	assertEqual(t, c.Hits(), 1)
+
+	// Failures are not cached
+	assertEqual(t, h(ctx, &QItem{ID: "bad"}) != nil, true)
+	h(ctx, &QItem{ID: "bad"})
+	assertEqual(t, runs, 3)
+
+	// Results expire
+	time.Sleep(30 * time.Millisecond)
+	h(ctx, &QItem{ID: "a"})
+	assertEqual(t, runs, 4)
+	assertEqual(t, results["a"], "result 4 of a")
+}
+
+func Test_ResultCacheByContentHash(t *testing.T) {
+	c := NewResultCache(time.Minute, ByContentHash(func(v interface{}) string {
+		s, _ := v.(string)
+		return s
+	}))
+	c.Store(&QItem{ID: "a", Value: "resize img.png"}, "done")
+	r, ok := c.Lookup(&QItem{ID: "b", Value: "resize img.png"})
+	assertEqual(t, ok, true)
+	assertEqual(t, r, "done")
+	_, ok = c.Lookup(&QItem{ID: "c"})
+	assertEqual(t, ok, false)
+}
@@ -0,0 +1,152 @@
+package priorityqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A RetentionRule bounds what the queue keeps. Items beyond any of its
+// limits are removed by ApplyRetention, the lowest priority first and,
+// among items of equal priority, the most recently pushed first, as
+// FlushBelowPercentile does. Zero limits do not apply.
+type RetentionRule struct {
+	MaxAge       Duration `json:"max_age,omitempty"`        // Removes items pushed longer ago
+	MaxPerParent int      `json:"max_per_parent,omitempty"` // Caps the queued items of each ParentID
+	MaxTotal     int      `json:"max_total,omitempty"`      // Caps the queued items
+
+	// Action says whether the removed items are deleted or dead-lettered
+	Action FlushAction `json:"action,omitempty"`
+
+	// Callback, when set, is called with a copy of each item the rule
+	// removes, after the queue lock has been released.
+	Callback func(QItem) `json:"-"`
+}
+
+func (r RetentionRule) validate() error {
+	switch {
+	case r.MaxAge < 0:
+		return fmt.Errorf("retention max age %v is negative", time.Duration(r.MaxAge))
+	case r.MaxPerParent < 0:
+		return fmt.Errorf("retention max per parent %d is negative", r.MaxPerParent)
+	case r.MaxTotal < 0:
+		return fmt.Errorf("retention max total %d is negative", r.MaxTotal)
+	case r.Action != FlushDrop && r.Action != FlushDeadLetter:
+		return fmt.Errorf("unknown retention action %v", r.Action)
+	}
+	return nil
+}
+
+// SetRetentionPolicy replaces the retention rules and makes a goroutine
+// owned by the queue apply them every interval, replacing the cleanup
+// loops run around the queue. A zero interval keeps the rules for
+// ApplyRetention but does not sweep; no rules, the default, retain
+// everything.
+func (pq *PriorityQueue) SetRetentionPolicy(interval time.Duration, rules ...RetentionRule) error {
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.retention = append([]RetentionRule(nil), rules...)
+	if pq.stopRetention != nil {
+		pq.stopRetention()
+		pq.stopRetention = nil
+	}
+	if interval <= 0 || len(rules) == 0 || pq.stopped {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(pq.background())
+	pq.stopRetention = cancel
+	pq.spawn(ctx, func(ctx context.Context) error {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				pq.ApplyRetention()
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	return nil
+}
+
+// ApplyRetention removes the queued items beyond the limits of the
+// retention rules, each rule in turn, and returns the number of items
+// removed. Each removal is recorded in the audit log as an OpRetention
+// entry.
+func (pq *PriorityQueue) ApplyRetention() (int, error) {
+	unlock := pq.lock(OpRetention)
+	if err := pq.mutable(); err != nil {
+		unlock()
+		return 0, err
+	}
+	var callbacks []func()
+	removed := 0
+	now := pq.now()
+	for _, rule := range pq.retention {
+		for _, item := range pq.beyondRetention(rule, now) {
+			item = pq.shed(OpRetention, item, rule.Action, "retention", now)
+			removed++
+			if fn := rule.Callback; fn != nil {
+				i := *item
+				callbacks = append(callbacks, func() { fn(i) })
+			}
+		}
+	}
+	unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
+	return removed, nil
+}
+
+// beyondRetention returns the queued items beyond the limits of rule, each
+// once. The queue lock must be held.
+func (pq *PriorityQueue) beyondRetention(rule RetentionRule, now time.Time) []*QItem {
+	var items []*QItem
+	doomed := make(itemSet)
+	add := func(candidates []*QItem) {
+		for _, item := range candidates {
+			if _, ok := doomed[item]; !ok {
+				doomed[item] = struct{}{}
+				items = append(items, item)
+			}
+		}
+	}
+
+	if rule.MaxAge > 0 {
+		cutoff := now.Add(-time.Duration(rule.MaxAge))
+		add(pq.collect(func(i *QItem) bool { return i.PushedAt.Before(cutoff) }))
+	}
+	if rule.MaxPerParent > 0 {
+		for parentID, s := range pq.byParent {
+			if len(s) <= rule.MaxPerParent {
+				continue
+			}
+			var kept []*QItem
+			for _, item := range pq.parentItems(parentID) {
+				if _, ok := doomed[item]; !ok {
+					kept = append(kept, item)
+				}
+			}
+			lowestFirst(kept)
+			if excess := len(kept) - rule.MaxPerParent; excess > 0 {
+				add(kept[:excess])
+			}
+		}
+	}
+	if rule.MaxTotal > 0 && pq.size()-len(doomed) > rule.MaxTotal {
+		kept := lowestFirst(pq.collect(func(i *QItem) bool {
+			_, ok := doomed[i]
+			return !ok
+		}))
+		add(kept[:len(kept)-rule.MaxTotal])
+	}
+	return items
+}
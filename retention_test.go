@@ -0,0 +1,52 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ApplyRetention(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	pq.Push(QItem{ID: "old", ParentID: "old", Priority: 50, PushedAt: time.Now().Add(-time.Hour)})
+	pq.Push(QItem{ID: "a1", ParentID: "a", Priority: 5})
+	pq.Push(QItem{ID: "a2", ParentID: "a", Priority: 6})
+	pq.Push(QItem{ID: "a3", ParentID: "a", Priority: 7})
+	pq.Push(QItem{ID: "a4", ParentID: "a", Priority: 8})
+
+	var removed []QItem
+	err := pq.SetRetentionPolicy(0,
+		RetentionRule{MaxAge: Duration(time.Minute), Action: FlushDeadLetter},
+		RetentionRule{MaxPerParent: 3, MaxTotal: 5, Callback: func(i QItem) { removed = append(removed, i) }},
+	)
+	assertEqual(t, err, nil)
+	n, err := pq.ApplyRetention()
+	assertEqual(t, err, nil)
+	assertEqual(t, n, 3)
+	assertEqual(t, pq.State("old"), StateDeadLettered)
+	assertEqual(t, len(pq.DeadLetters()), 1)
+	// The lowest item of parent a goes first, then the lowest of the rest
+	assertEqual(t, len(removed), 2)
+	assertEqual(t, removed[0].ID, "a1")
+	assertEqual(t, removed[1].ID, "0")
+	assertEqual(t, pq.Len(), 5)
+	assertEqual(t, pq.Healthy(), nil)
+
+	n, _ = pq.ApplyRetention()
+	assertEqual(t, n, 0)
+
+	assertEqual(t, pq.SetRetentionPolicy(0, RetentionRule{MaxTotal: -1}) != nil, true)
+}
+
+func Test_RetentionSweep(t *testing.T) {
+	pq := NewPriorityQueue(WithRetentionPolicy(5*time.Millisecond, RetentionRule{MaxTotal: 3}))
+	assertEqual(t, pq.Capabilities().Retention, true)
+	populateQueue(pq, 10)
+
+	deadline := time.Now().Add(time.Second)
+	for pq.Len() > 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(t, pq.Len(), 3)
+	pq.Destroy()
+}
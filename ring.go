@@ -0,0 +1,338 @@
+package priorityqueue
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultRingReplicas is the number of points each node has on a Ring
+// unless NewRing says otherwise
+const DefaultRingReplicas = 100
+
+// A Ring maps keys to nodes by consistent hashing: each node owns the arcs
+// ending at its points on the ring, so adding or removing a node moves only
+// the keys of the arcs it gains or loses.
+type Ring struct {
+	replicas int
+	points   []uint64
+	owners   map[uint64]string
+	nodes    map[string]bool
+}
+
+// NewRing returns an empty ring placing replicas points per node, or
+// DefaultRingReplicas if replicas is not positive
+func NewRing(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultRingReplicas
+	}
+	return &Ring{replicas: replicas, owners: make(map[uint64]string), nodes: make(map[string]bool)}
+}
+
+func ringHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// FNV spreads short similar keys poorly, finish with a mix
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	return x
+}
+
+// Add places node on the ring
+func (r *Ring) Add(node string) {
+	if r.nodes[node] {
+		return
+	}
+	r.nodes[node] = true
+	for n := 0; n < r.replicas; n++ {
+		p := ringHash(node + "#" + strconv.Itoa(n))
+		if _, taken := r.owners[p]; taken {
+			continue
+		}
+		r.owners[p] = node
+		r.points = append(r.points, p)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// Remove takes node off the ring
+func (r *Ring) Remove(node string) {
+	if !r.nodes[node] {
+		return
+	}
+	delete(r.nodes, node)
+	points := r.points[:0]
+	for _, p := range r.points {
+		if r.owners[p] == node {
+			delete(r.owners, p)
+			continue
+		}
+		points = append(points, p)
+	}
+	r.points = points
+}
+
+// Owner returns the node owning key, the empty string if the ring is empty
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := ringHash(key)
+	n := sort.Search(len(r.points), func(n int) bool { return r.points[n] >= h })
+	if n == len(r.points) {
+		n = 0
+	}
+	return r.owners[r.points[n]]
+}
+
+// Nodes returns the nodes on the ring, sorted
+func (r *Ring) Nodes() []string {
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// ErrNoPartitions is returned by a Partitioned queue without nodes
+var ErrNoPartitions = errors.New("no partitions")
+
+// A Partitioned is a Queue spreading its items over several queues, such as
+// remote queue servers reached through httppq.Client, by consistent hashing
+// of their ParentID: the items of a parent all live on one node, so the
+// parent updates and deletes go to that node alone. Pop and Peek look at
+// the head of every node to return the highest priority item overall.
+//
+// Adding or removing a node moves the items of the parents changing owner
+// to their new node. Items on nodes that cannot list them, which are the
+// nodes without a ForEach method, stay where they are instead; until such
+// a node is empty the parent updates and deletes reach it too.
+type Partitioned struct {
+	m       sync.Mutex
+	ring    *Ring
+	queues  map[string]Queue
+	unmoved map[string]bool // Nodes that may hold items of parents they do not own
+}
+
+var _ Queue = (*Partitioned)(nil)
+
+// NewPartitioned returns a Partitioned queue without nodes, placing replicas
+// points per node on its ring
+func NewPartitioned(replicas int) *Partitioned {
+	return &Partitioned{ring: NewRing(replicas), queues: make(map[string]Queue), unmoved: make(map[string]bool)}
+}
+
+// AddNode adds the queue q as node name, and returns the number of items
+// moved to it from the other nodes
+func (p *Partitioned) AddNode(name string, q Queue) (int, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if _, ok := p.queues[name]; ok {
+		return 0, fmt.Errorf("partition [%s] already added", name)
+	}
+	p.ring.Add(name)
+	p.queues[name] = q
+	moved := 0
+	for node, from := range p.queues {
+		if node == name {
+			continue
+		}
+		s, ok := from.(scannable)
+		if !ok {
+			if from.Len() > 0 {
+				p.unmoved[node] = true
+			}
+			continue
+		}
+		var items []QItem
+		s.ForEach(func(item QItem) bool {
+			if p.ring.Owner(item.ParentID) == name {
+				items = append(items, item)
+			}
+			return true
+		})
+		for _, item := range items {
+			if from.DeleteItemById(item.ID) != nil {
+				continue
+			}
+			if err := q.Push(item); err != nil {
+				from.Push(item)
+				return moved, fmt.Errorf("moving item [%s] to partition [%s]: %w", item.ID, name, err)
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+// RemoveNode removes node name, popping its items and pushing them to their
+// new nodes, and returns the number of items moved
+func (p *Partitioned) RemoveNode(name string) (int, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	q, ok := p.queues[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: partition [%s]", ErrNotFound, name)
+	}
+	p.ring.Remove(name)
+	delete(p.queues, name)
+	delete(p.unmoved, name)
+	moved := 0
+	for len(p.queues) > 0 {
+		item, err := q.Pop()
+		if err == ErrEmptyQueue {
+			break
+		}
+		if err != nil {
+			return moved, err
+		}
+		if err := p.queues[p.ring.Owner(item.ParentID)].Push(*item); err != nil {
+			q.Push(*item)
+			return moved, fmt.Errorf("moving item [%s] off partition [%s]: %w", item.ID, name, err)
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// Nodes returns the names of the nodes, sorted
+func (p *Partitioned) Nodes() []string {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.ring.Nodes()
+}
+
+// Owner returns the name of the node holding the items of parentID
+func (p *Partitioned) Owner(parentID string) string {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.ring.Owner(parentID)
+}
+
+// parentNodes returns the nodes that may hold items of parentID. The lock
+// must be held.
+func (p *Partitioned) parentNodes(parentID string) []Queue {
+	owner := p.ring.Owner(parentID)
+	if owner == "" {
+		return nil
+	}
+	nodes := []Queue{p.queues[owner]}
+	for node := range p.unmoved {
+		if node == owner {
+			continue
+		}
+		if p.queues[node].Len() == 0 {
+			delete(p.unmoved, node)
+			continue
+		}
+		nodes = append(nodes, p.queues[node])
+	}
+	return nodes
+}
+
+func (p *Partitioned) Push(i QItem) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	owner := p.ring.Owner(i.ParentID)
+	if owner == "" {
+		return ErrNoPartitions
+	}
+	return p.queues[owner].Push(i)
+}
+
+// head returns the node whose head item has the highest priority, nil if
+// every node is empty. The lock must be held.
+func (p *Partitioned) head() Queue {
+	var best Queue
+	var top *QItem
+	for _, q := range p.queues {
+		item, err := q.Peek()
+		if err != nil {
+			continue
+		}
+		if top == nil || item.Priority > top.Priority {
+			best, top = q, item
+		}
+	}
+	return best
+}
+
+func (p *Partitioned) Pop() (*QItem, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	q := p.head()
+	if q == nil {
+		return nil, ErrEmptyQueue
+	}
+	return q.Pop()
+}
+
+func (p *Partitioned) Peek() (*QItem, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	q := p.head()
+	if q == nil {
+		return nil, ErrEmptyQueue
+	}
+	return q.Peek()
+}
+
+func (p *Partitioned) Len() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	n := 0
+	for _, q := range p.queues {
+		n += q.Len()
+	}
+	return n
+}
+
+func (p *Partitioned) Clear() {
+	p.m.Lock()
+	defer p.m.Unlock()
+	for _, q := range p.queues {
+		q.Clear()
+	}
+	p.unmoved = make(map[string]bool)
+}
+
+func (p *Partitioned) UpdatePriorityByParentId(parentID string, priority int) int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	n := 0
+	for _, q := range p.parentNodes(parentID) {
+		n += q.UpdatePriorityByParentId(parentID, priority)
+	}
+	return n
+}
+
+func (p *Partitioned) DeleteItemById(id string) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	for _, q := range p.queues {
+		if err := q.DeleteItemById(id); !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: [%s]", ErrNotFound, id)
+}
+
+func (p *Partitioned) DeleteItemsByParentId(parentID string) (int, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	total := 0
+	for _, q := range p.parentNodes(parentID) {
+		n, err := q.DeleteItemsByParentId(parentID)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,99 @@
+package priorityqueue
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func Test_RingBalanceAndStability(t *testing.T) {
+	r := NewRing(0)
+	for _, node := range []string{"a", "b", "c"} {
+		r.Add(node)
+	}
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+	for n := 0; n < 3000; n++ {
+		key := "parent-" + strconv.Itoa(n)
+		owners[key] = r.Owner(key)
+		counts[owners[key]]++
+	}
+	for node, n := range counts {
+		if n < 600 || n > 1400 {
+			t.Errorf("Node %s owns %d of 3000 keys", node, n)
+		}
+	}
+
+	r.Add("d")
+	moved := 0
+	for key, owner := range owners {
+		if now := r.Owner(key); now != owner {
+			moved++
+			assertEqual(t, now, "d")
+		}
+	}
+	if moved < 400 || moved > 1200 {
+		t.Errorf("Adding a fourth node moved %d of 3000 keys", moved)
+	}
+	r.Remove("d")
+	for key, owner := range owners {
+		assertEqual(t, r.Owner(key), owner)
+	}
+	assertEqual(t, len(r.Nodes()), 3)
+}
+
+func Test_Partitioned(t *testing.T) {
+	p := NewPartitioned(0)
+	assertEqual(t, errors.Is(p.Push(QItem{ID: "x"}), ErrNoPartitions), true)
+
+	a, b := NewPriorityQueue(), NewPriorityQueue()
+	p.AddNode("a", a)
+	for n := 0; n < 100; n++ {
+		p.Push(QItem{ID: strconv.Itoa(n), ParentID: "job-" + strconv.Itoa(n%10), Priority: n})
+	}
+	assertEqual(t, a.Len(), 100)
+
+	moved, err := p.AddNode("b", b)
+	assertEqual(t, err, nil)
+	assertEqual(t, moved, b.Len())
+	assertEqual(t, moved > 0 && moved < 100, true)
+	b.ForEach(func(item QItem) bool {
+		assertEqual(t, p.Owner(item.ParentID), "b")
+		return true
+	})
+
+	item, _ := p.Pop()
+	assertEqual(t, item.Priority, 99)
+	assertEqual(t, p.UpdatePriorityByParentId("job-9", 1000), 9)
+	item, _ = p.Peek()
+	assertEqual(t, item.ParentID, "job-9")
+	n, _ := p.DeleteItemsByParentId("job-0")
+	assertEqual(t, n, 10)
+	assertEqual(t, p.DeleteItemById("55"), nil)
+	assertEqual(t, errors.Is(p.DeleteItemById("55"), ErrNotFound), true)
+	assertEqual(t, p.Len(), 88)
+
+	moved, _ = p.RemoveNode("b")
+	assertEqual(t, a.Len(), 88)
+	assertEqual(t, moved > 0, true)
+}
+
+func Test_PartitionedUnscannable(t *testing.T) {
+	p := NewPartitioned(0)
+	// A Shadow cannot list its items, so its items stay when b joins
+	a := NewShadow(NewPriorityQueue(), NewPriorityQueue())
+	p.AddNode("a", a)
+	for n := 0; n < 20; n++ {
+		p.Push(QItem{ID: strconv.Itoa(n), ParentID: "job-" + strconv.Itoa(n), Priority: n})
+	}
+	moved, _ := p.AddNode("b", NewPriorityQueue())
+	assertEqual(t, moved, 0)
+
+	// Parent deletes still reach them
+	deleted := 0
+	for n := 0; n < 20; n++ {
+		d, _ := p.DeleteItemsByParentId("job-" + strconv.Itoa(n))
+		deleted += d
+	}
+	assertEqual(t, deleted, 20)
+}
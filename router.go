@@ -0,0 +1,85 @@
+package priorityqueue
+
+import (
+	"errors"
+	"path"
+	"sync"
+)
+
+// ErrNoRoute is returned by Publish when no rule matches an item
+var ErrNoRoute = errors.New("no route matches the item")
+
+// A Matcher selects the items a routing rule applies to
+type Matcher func(item *QItem) bool
+
+// MatchParent matches the items whose ParentID matches pattern, in the
+// syntax of path.Match, so "acme/*" matches the children of "acme".
+func MatchParent(pattern string) Matcher {
+	return func(item *QItem) bool {
+		ok, _ := path.Match(pattern, item.ParentID)
+		return ok
+	}
+}
+
+// MatchPriority matches the items with a priority from min to max inclusive
+func MatchPriority(min, max int) Matcher {
+	return func(item *QItem) bool {
+		return item.Priority >= min && item.Priority <= max
+	}
+}
+
+// A Rule routes the items its Matcher selects to the queue called Queue
+type Rule struct {
+	Queue string
+	Match Matcher
+}
+
+// A Router fans published items out to the queues of a Manager according to
+// routing rules. An item is pushed to every queue with a matching rule,
+// queues being created by the Manager on demand.
+type Router struct {
+	mgr *Manager
+
+	m     sync.RWMutex
+	rules []Rule
+}
+
+// NewRouter returns a Router without rules delivering to the queues of mgr
+func NewRouter(mgr *Manager) *Router {
+	return &Router{mgr: mgr}
+}
+
+// AddRule appends a routing rule. Rules can be added while items are
+// published.
+func (r *Router) AddRule(rule Rule) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.rules = append(r.rules, rule)
+}
+
+// Publish pushes a copy of item to every queue with a matching rule, once
+// per queue, and returns the number of queues it was pushed to. It returns
+// ErrNoRoute if no rule matches. Pushing stops at the first queue refusing
+// the item.
+func (r *Router) Publish(item QItem) (int, error) {
+	r.m.RLock()
+	var queues []string
+	seen := make(map[string]bool)
+	for _, rule := range r.rules {
+		if !seen[rule.Queue] && rule.Match(&item) {
+			seen[rule.Queue] = true
+			queues = append(queues, rule.Queue)
+		}
+	}
+	r.m.RUnlock()
+
+	if len(queues) == 0 {
+		return 0, ErrNoRoute
+	}
+	for n, name := range queues {
+		if err := r.mgr.Queue(name).Push(item); err != nil {
+			return n, err
+		}
+	}
+	return len(queues), nil
+}
@@ -0,0 +1,35 @@
+package priorityqueue
+
+import (
+	"testing"
+)
+
+func Test_RouterFanOut(t *testing.T) {
+	mgr := NewManager()
+	r := NewRouter(mgr)
+	r.AddRule(Rule{Queue: "acme", Match: MatchParent("acme/*")})
+	r.AddRule(Rule{Queue: "urgent", Match: MatchPriority(100, 1000)})
+	r.AddRule(Rule{Queue: "audit", Match: func(item *QItem) bool { return item.Producer == "billing" }})
+	r.AddRule(Rule{Queue: "urgent", Match: MatchParent("*")})
+
+	n, err := r.Publish(QItem{ID: "1", ParentID: "acme/web", Priority: 100, Producer: "billing"})
+	if err != nil {
+		t.Errorf("Error publishing item: %v", err)
+	}
+	assertEqual(t, n, 3)
+	assertEqual(t, mgr.Queue("acme").Len(), 1)
+	assertEqual(t, mgr.Queue("urgent").Len(), 1)
+	assertEqual(t, mgr.Queue("audit").Len(), 1)
+
+	n, _ = r.Publish(QItem{ID: "2", ParentID: "globex", Priority: 1})
+	assertEqual(t, n, 1)
+	assertEqual(t, mgr.Queue("urgent").Len(), 2)
+}
+
+func Test_RouterNoRoute(t *testing.T) {
+	r := NewRouter(NewManager())
+	r.AddRule(Rule{Queue: "acme", Match: MatchParent("acme/*")})
+	if _, err := r.Publish(QItem{ID: "1", ParentID: "globex"}); err != ErrNoRoute {
+		t.Errorf("Error publishing an unroutable item: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// interleaving makes goroutines acquire a queue's lock in a scripted order
+// of operations, through the queue's scheduling seam, so concurrent tests
+// are deterministic rather than relying on the race detector's luck.
+type interleaving struct {
+	m     sync.Mutex
+	cond  *sync.Cond
+	order []Operation
+	next  int
+}
+
+// interleave installs a scheduler on pq letting operations take the lock
+// in the given order. Operations not in the order run unscheduled.
+func interleave(pq *PriorityQueue, order ...Operation) *interleaving {
+	s := &interleaving{order: order}
+	s.cond = sync.NewCond(&s.m)
+	pq.sched = s.hook
+	return s
+}
+
+func (s *interleaving) hook(op Operation, point schedPoint) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	scheduled := false
+	for _, o := range s.order[s.next:] {
+		scheduled = scheduled || o == op
+	}
+	if !scheduled {
+		return
+	}
+	switch point {
+	case schedAcquire:
+		for s.order[s.next] != op {
+			s.cond.Wait()
+		}
+	case schedRelease:
+		s.next++
+		s.cond.Broadcast()
+	}
+}
+
+// permutations returns every ordering of ops
+func permutations(ops []Operation) [][]Operation {
+	if len(ops) <= 1 {
+		return [][]Operation{ops}
+	}
+	var all [][]Operation
+	for n := range ops {
+		rest := append(append([]Operation(nil), ops[:n]...), ops[n+1:]...)
+		for _, p := range permutations(rest) {
+			all = append(all, append([]Operation{ops[n]}, p...))
+		}
+	}
+	return all
+}
+
+// Test_InterleavedPushPopDelete runs a Push, a Pop and a Delete from
+// separate goroutines in every possible order and checks each outcome
+// against the same operations applied sequentially to the reference model.
+func Test_InterleavedPushPopDelete(t *testing.T) {
+	ops := []Operation{OpPush, OpPop, OpDeleteItemById}
+	for _, order := range permutations(ops) {
+		for run := 0; run < 20; run++ {
+			pq := NewPriorityQueue()
+			model := NewSortedQueue()
+			for _, q := range []Queue{pq, model} {
+				q.Push(QItem{ID: "a", Priority: 1})
+			}
+			interleave(pq, order...)
+
+			results := make(map[Operation]string)
+			var m sync.Mutex
+			var wg sync.WaitGroup
+			apply := func(q Queue, op Operation) string {
+				switch op {
+				case OpPush:
+					return fmt.Sprint(q.Push(QItem{ID: "b", Priority: 2}))
+				case OpPop:
+					item, err := q.Pop()
+					if err != nil {
+						return err.Error()
+					}
+					return item.ID
+				default:
+					return fmt.Sprint(q.DeleteItemById("a"))
+				}
+			}
+			for _, op := range ops {
+				wg.Add(1)
+				go func(op Operation) {
+					defer wg.Done()
+					r := apply(pq, op)
+					m.Lock()
+					results[op] = r
+					m.Unlock()
+				}(op)
+			}
+			wg.Wait()
+
+			for _, op := range order {
+				if want := apply(model, op); results[op] != want {
+					t.Errorf("Order %v: %s returned %q, expected %q", order, op, results[op], want)
+				}
+			}
+			assertEqual(t, pq.Len(), model.Len())
+		}
+	}
+}
+
+func Test_InterleavingOrder(t *testing.T) {
+	pq := NewPriorityQueue()
+	interleave(pq, OpPop, OpPush)
+
+	popped := make(chan error)
+	go func() {
+		_, err := pq.Pop()
+		popped <- err
+	}()
+	// The Pop goes first even though the Push is started alongside it
+	go pq.Push(QItem{ID: "a"})
+	if err := <-popped; err != ErrEmptyQueue {
+		t.Errorf("Error: Pop did not run first: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package priorityqueue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Scheduler runs functions at their scheduled time, a prioritized timer
+// wheel built on the delayed items of a queue: each function is pushed as a
+// delayed item, and a goroutine owned by the queue pops and runs the items
+// falling due, the highest priority first when several are due at once.
+// The functions run one at a time on that goroutine, so a slow function
+// delays the next ones; hand long work over to another goroutine.
+type Scheduler struct {
+	pq *PriorityQueue
+
+	m       sync.Mutex
+	seq     uint64
+	pending map[string]bool
+}
+
+// NewScheduler returns a running Scheduler whose queue is configured by
+// opts, such as WithClock.
+func NewScheduler(opts ...Option) (*Scheduler, error) {
+	pq, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheduler{pq: pq, pending: make(map[string]bool)}
+	pq.Go(s.run)
+	return s, nil
+}
+
+// Schedule runs fn at at, or right away if at has passed, and returns an
+// ID to Cancel it with.
+func (s *Scheduler) Schedule(at time.Time, fn func()) (string, error) {
+	return s.SchedulePriority(at, 0, fn)
+}
+
+// SchedulePriority is Schedule with fn running before the functions of
+// lower priority due at the same time.
+func (s *Scheduler) SchedulePriority(at time.Time, priority int, fn func()) (string, error) {
+	s.m.Lock()
+	s.seq++
+	id := strconv.FormatUint(s.seq, 10)
+	s.pending[id] = true
+	s.m.Unlock()
+
+	if err := s.pq.PushDelayed(QItem{ID: id, Value: fn, Priority: priority}, at); err != nil {
+		s.m.Lock()
+		delete(s.pending, id)
+		s.m.Unlock()
+		return "", err
+	}
+	return id, nil
+}
+
+// Cancel keeps the function scheduled as id from running. It reports
+// whether the function was still pending.
+func (s *Scheduler) Cancel(id string) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	pending := s.pending[id]
+	delete(s.pending, id)
+	return pending
+}
+
+// Pending returns the number of functions scheduled that have neither run
+// nor been canceled
+func (s *Scheduler) Pending() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.pending)
+}
+
+// Stop stops running functions, waiting until ctx is done for the running
+// one to return. The functions still pending never run.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	return s.pq.Stop(ctx)
+}
+
+// Queue returns the queue holding the scheduled functions, to observe it
+func (s *Scheduler) Queue() *PriorityQueue {
+	return s.pq
+}
+
+func (s *Scheduler) run(ctx context.Context) error {
+	for {
+		item, err := s.pq.PopWait(ctx)
+		if err != nil {
+			return err
+		}
+		s.m.Lock()
+		pending := s.pending[item.ID]
+		delete(s.pending, item.ID)
+		s.m.Unlock()
+		if pending {
+			item.Value.(func())()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_Scheduler(t *testing.T) {
+	s, err := NewScheduler()
+	assertEqual(t, err, nil)
+	ran := make(chan string, 4)
+	at := time.Now().Add(20 * time.Millisecond)
+	for n, name := range []string{"low", "high", "canceled", "mid"} {
+		name := name
+		id, err := s.SchedulePriority(at, []int{1, 3, 5, 2}[n], func() { ran <- name })
+		assertEqual(t, err, nil)
+		if name == "canceled" {
+			assertEqual(t, s.Cancel(id), true)
+		}
+	}
+	assertEqual(t, s.Pending(), 3)
+
+	for _, want := range []string{"high", "mid", "low"} {
+		select {
+		case got := <-ran:
+			assertEqual(t, got, want)
+		case <-time.After(time.Second):
+			t.Fatalf("Error, %s did not run", want)
+		}
+	}
+	assertEqual(t, s.Pending(), 0)
+
+	id, _ := s.Schedule(time.Now().Add(time.Hour), func() { ran <- "later" })
+	assertEqual(t, s.Pending(), 1)
+	assertEqual(t, s.Stop(context.Background()), nil)
+	assertEqual(t, s.Cancel(id), true)
+	assertEqual(t, s.Cancel(id), false)
+}
@@ -0,0 +1,117 @@
+package priorityqueue
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// A Scorer computes the priority of items from their content, such as
+// their Value, ParentID or PushedAt, so scoring logic lives in one place
+// rather than in every producer. Score is called with the queue lock held:
+// it must be quick and must not use the queue.
+type Scorer interface {
+	Score(item QItem) int
+}
+
+// ScorerFunc adapts a function to a Scorer
+type ScorerFunc func(item QItem) int
+
+func (f ScorerFunc) Score(item QItem) int {
+	return f(item)
+}
+
+// A WeightedScorer is one of the scorers combined by Weighted
+type WeightedScorer struct {
+	Scorer Scorer
+	Weight float64
+}
+
+// Weighted returns a Scorer summing the scores of scorers times their
+// weight, rounded to the nearest priority, so scoring plugins such as
+// customer tier, deadline and size can be developed independently.
+func Weighted(scorers ...WeightedScorer) Scorer {
+	return ScorerFunc(func(item QItem) int {
+		var sum float64
+		for _, s := range scorers {
+			sum += s.Weight * float64(s.Scorer.Score(item))
+		}
+		return int(math.Round(sum))
+	})
+}
+
+// SetScorer makes Push set the priority of each item to the score s gives
+// it instead of the Priority given or named by its Level; parent
+// priorities and ceilings still apply to the score. A positive interval
+// makes a goroutine owned by the queue rescore the queued items every
+// interval, see Rescore. A nil Scorer, the default, keeps the priorities
+// given.
+func (pq *PriorityQueue) SetScorer(s Scorer, interval time.Duration) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.scorer = s
+	if pq.stopRescore != nil {
+		pq.stopRescore()
+		pq.stopRescore = nil
+	}
+	if interval <= 0 || s == nil || pq.stopped {
+		return
+	}
+	ctx, cancel := context.WithCancel(pq.background())
+	pq.stopRescore = cancel
+	pq.spawn(ctx, func(ctx context.Context) error {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				pq.Rescore()
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+}
+
+// WithScorer is SetScorer
+func WithScorer(s Scorer, interval time.Duration) Option {
+	return func(pq *PriorityQueue) error {
+		pq.SetScorer(s, interval)
+		return nil
+	}
+}
+
+// score sets the priority of an item being pushed from the Scorer, if any.
+// The queue lock must be held.
+func (pq *PriorityQueue) score(i *QItem) {
+	if pq.scorer != nil {
+		i.Priority = pq.scorer.Score(*i)
+	}
+}
+
+// Rescore scores the queued items again with the Scorer, for scores that
+// change over time such as those counting the time waited, and returns the
+// number of items whose priority changed. Each change is recorded in the
+// audit log as an OpRescore entry. Rescoring replaces the priorities set
+// since the push, escalations included.
+func (pq *PriorityQueue) Rescore() (int, error) {
+	defer pq.lock(OpRescore)()
+	if err := pq.mutable(); err != nil {
+		return 0, err
+	}
+	if pq.scorer == nil {
+		return 0, nil
+	}
+	changed := 0
+	for _, item := range pq.collect(func(*QItem) bool { return true }) {
+		i := *item
+		pq.score(&i)
+		pq.inherit(&i)
+		if i.Priority != item.Priority {
+			pq.reprioritize(item, i.Priority)
+			pq.audit(OpRescore, item)
+			changed++
+		}
+	}
+	return changed, nil
+}
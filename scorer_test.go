@@ -0,0 +1,53 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_Scorer(t *testing.T) {
+	now := time.Now()
+	size := ScorerFunc(func(i QItem) int { return -len(i.Value.(string)) })
+	waited := ScorerFunc(func(i QItem) int { return int(now.Sub(i.PushedAt) / time.Minute) })
+	pq, _ := New(WithClock(func() time.Time { return now }),
+		WithScorer(Weighted(WeightedScorer{size, 1}, WeightedScorer{waited, 2}), 0))
+
+	pq.Push(QItem{ID: "big", Value: "xxxxxxxxxx", Priority: 100, PushedAt: now})
+	pq.Push(QItem{ID: "small", Value: "x", PushedAt: now})
+	item, _ := pq.Peek()
+	assertEqual(t, item.ID, "small")
+	assertEqual(t, item.Priority, -1)
+
+	now = now.Add(5 * time.Minute)
+	n, _ := pq.Rescore()
+	assertEqual(t, n, 2)
+	item, _ = pq.Peek()
+	assertEqual(t, item.Priority, 9)
+	n, _ = pq.Rescore()
+	assertEqual(t, n, 0)
+
+	pq.SetScorer(nil, 0)
+	pq.Push(QItem{ID: "given", Value: "", Priority: 50})
+	item, _ = pq.Peek()
+	assertEqual(t, item.ID, "given")
+}
+
+func Test_ScorerInterval(t *testing.T) {
+	pq := NewPriorityQueue()
+	score := 1
+	pq.SetScorer(ScorerFunc(func(QItem) int { return score }), time.Millisecond)
+	assertEqual(t, pq.Capabilities().Rescore, true)
+	pq.Push(QItem{ID: "a"})
+	pq.m.Lock()
+	score = 7
+	pq.m.Unlock()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if item, _ := pq.Peek(); item.Priority == 7 {
+			break
+		}
+	}
+	item, _ := pq.Peek()
+	assertEqual(t, item.Priority, 7)
+	pq.Stop(context.Background())
+}
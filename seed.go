@@ -0,0 +1,171 @@
+package priorityqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A SeedFunc fetches the items to load into a queue, see Seed
+type SeedFunc func(ctx context.Context) ([]QItem, error)
+
+// A SeedConflict says what Seed does with a fetched item whose ID is
+// already queued, delayed or in flight
+type SeedConflict int
+
+const (
+	SeedSkip    SeedConflict = iota // Keep the item held, drop the fetched one
+	SeedReplace                     // Replace the queued item by the fetched one
+)
+
+// A SeedReport counts what Seed did with the fetched items
+type SeedReport struct {
+	Fetched int
+	Pushed  int
+
+	// Replaced counts the queued items replaced, see SeedReplace. Delayed
+	// and in-flight items are never replaced: the fetched items conflicting
+	// with them are Skipped, as are the repeated IDs of a fetch.
+	Replaced int
+	Skipped  int
+
+	// Diverted counts the items below the floor of a draining queue pushed
+	// to its divert queue, see StartDraining
+	Diverted int
+}
+
+// Seed loads the items returned by fetch, such as the pending jobs of a
+// database at startup, in a single bulk insert as ImportNDJSON does.
+// Fetched items are admitted as pushes are: their level is resolved and the
+// Scorer, the ID generator, the Transformers and the BlobStore apply, and
+// items below the floor of a draining queue are diverted, or Skipped
+// without a queue to divert them to or when they conflict with a held item.
+// Fetched items whose ID is held by the queue are handled as conflict says.
+// Producer limits and deduplication do not apply. It returns the error of
+// fetch, or of ctx if ctx is done before fetch returns, or of admitting an
+// item, without loading anything.
+func (pq *PriorityQueue) Seed(ctx context.Context, fetch SeedFunc, conflict SeedConflict) (SeedReport, error) {
+	items, err := fetch(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+	report := SeedReport{Fetched: len(items)}
+	if err != nil {
+		return report, err
+	}
+
+	defer pq.lock(OpSeed)()
+	if err := pq.mutable(); err != nil {
+		return report, err
+	}
+	queued, held := pq.heldIDs()
+
+	var load, divert []QItem
+	var replaced []*QItem
+	// fail drops the Values offloaded for the items admitted before err
+	fail := func(err error) (SeedReport, error) {
+		pq.dropBlobs(load)
+		return SeedReport{Fetched: len(items)}, err
+	}
+	for _, item := range items {
+		if err := pq.resolveLevel(&item); err != nil {
+			return fail(err)
+		}
+		pq.score(&item)
+		pq.assignID(&item)
+		key := pq.itemKey(&item)
+		if held[key] {
+			report.Skipped++
+			continue
+		}
+		held[key] = true
+		old, ok := queued[key]
+		below := pq.drainFloor != nil && item.Priority < pq.drainFloor.floor
+		if ok && (conflict != SeedReplace || below) {
+			report.Skipped++
+			continue
+		}
+		if below {
+			divert = append(divert, item)
+			continue
+		}
+		if ok {
+			replaced = append(replaced, old)
+		}
+		if err := pq.transform(&item); err != nil {
+			return fail(err)
+		}
+		if err := pq.offload(&item); err != nil {
+			return fail(err)
+		}
+		pq.inherit(&item)
+		load = append(load, item)
+	}
+	if err := pq.authorize(ctx, OpSeed, append(replaced, append(ptrs(load), ptrs(divert)...)...)...); err != nil {
+		return fail(err)
+	}
+	for _, old := range replaced {
+		pq.audit(OpSeed, pq.remove(old.index, StateDeleted))
+	}
+	for _, item := range divert {
+		if pq.admitFloor(&item) == errDiverted {
+			report.Diverted++
+		} else {
+			report.Skipped++
+		}
+	}
+	report.Replaced = len(replaced)
+	pq.insertAll(OpSeed, load)
+	report.Pushed = len(load)
+	return report, nil
+}
+
+// WithSeed seeds the queue with the items returned by fetch, see Seed,
+// failing the option if the seeding fails. With a positive interval a
+// goroutine owned by the queue seeds it again every interval, such as to
+// pick up the jobs written to a database meanwhile; a failed seeding is
+// tried again at the next interval.
+func WithSeed(fetch SeedFunc, conflict SeedConflict, interval time.Duration) Option {
+	return func(pq *PriorityQueue) error {
+		if _, err := pq.Seed(context.Background(), fetch, conflict); err != nil {
+			return fmt.Errorf("seeding the queue: %w", err)
+		}
+		if interval > 0 {
+			pq.Go(func(ctx context.Context) error {
+				t := time.NewTicker(interval)
+				defer t.Stop()
+				for {
+					select {
+					case <-t.C:
+						pq.Seed(ctx, fetch, conflict)
+					case <-ctx.Done():
+						return nil
+					}
+				}
+			})
+		}
+		return nil
+	}
+}
+
+// heldIDs returns the queued items by ID, and the IDs of the items delayed
+// or in flight. The queue lock must be held.
+func (pq *PriorityQueue) heldIDs() (map[string]*QItem, map[string]bool) {
+	queued := make(map[string]*QItem, len(pq.data))
+	for _, item := range pq.data {
+		if item.tombstone == "" {
+			queued[pq.itemKey(item)] = item
+		}
+	}
+	held := make(map[string]bool, len(pq.leases)+len(pq.delayed))
+	for _, l := range pq.leases {
+		held[pq.itemKey(&l.item)] = true
+	}
+	for _, t := range pq.delayed {
+		held[pq.itemKey(&t.v)] = true
+	}
+	for _, i := range pq.coalescing {
+		held[pq.itemKey(i)] = true
+	}
+	return queued, held
+}
@@ -0,0 +1,98 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Seed(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	pq.Lease(time.Minute) // "2" is in flight
+
+	fetch := func(ctx context.Context) ([]QItem, error) {
+		return []QItem{
+			{ID: "0", Priority: 10},
+			{ID: "2", Priority: 10},
+			{ID: "a", Priority: 5},
+			{ID: "a", Priority: 6},
+			{ID: "b", Priority: 4},
+		}, nil
+	}
+	report, err := pq.Seed(context.Background(), fetch, SeedSkip)
+	if err != nil {
+		t.Fatalf("Error seeding: %v", err)
+	}
+	assertEqual(t, report, SeedReport{Fetched: 5, Pushed: 2, Skipped: 3})
+	assertEqual(t, pq.Len(), 4)
+
+	pq.DeleteItemById("a")
+	pq.DeleteItemById("b")
+	report, _ = pq.Seed(context.Background(), fetch, SeedReplace)
+	assertEqual(t, report, SeedReport{Fetched: 5, Pushed: 3, Replaced: 1, Skipped: 2})
+	x, _ := pq.Pop()
+	assertEqual(t, x.ID, "0")
+	assertEqual(t, x.Priority, 10)
+	if err := pq.Healthy(); err != nil {
+		t.Errorf("Unhealthy after seeding: %v", err)
+	}
+
+	failing := func(ctx context.Context) ([]QItem, error) { return nil, errors.New("db down") }
+	if _, err := pq.Seed(context.Background(), failing, SeedSkip); err == nil {
+		t.Errorf("Seeding with a failing fetch returned no error")
+	}
+}
+
+func Test_SeedAdmits(t *testing.T) {
+	pq, _ := New(WithTransformers(Transformer{
+		Name: "redact",
+		Push: func(QItem) (interface{}, error) { return "redacted", nil },
+	}))
+	fetched := []QItem{{ParentID: "p", Value: "secret", Priority: 1}, {ID: "b", Value: "secret", Priority: 5}}
+	report, err := pq.Seed(context.Background(), func(context.Context) ([]QItem, error) {
+		return fetched, nil
+	}, SeedSkip)
+	assertEqual(t, err, nil)
+	assertEqual(t, report.Pushed, 2)
+	for _, item := range pq.SortedView() {
+		assertEqual(t, item.Value, "redacted")
+		assertEqual(t, item.ID == "", false)
+	}
+	// The fetched items are left as they were
+	assertEqual(t, fetched[0].ID, "")
+	assertEqual(t, fetched[0].Value, "secret")
+
+	// Items below the floor of a draining queue are diverted
+	divert := NewPriorityQueue()
+	pq.StartDraining(3, divert)
+	report, _ = pq.Seed(context.Background(), func(context.Context) ([]QItem, error) {
+		return []QItem{{ID: "c", Priority: 1}, {ID: "d", Priority: 4}}, nil
+	}, SeedSkip)
+	assertEqual(t, report, SeedReport{Fetched: 2, Pushed: 1, Diverted: 1})
+	assertEqual(t, divert.Len(), 1)
+}
+
+func Test_WithSeed(t *testing.T) {
+	fetched := make(chan struct{}, 10)
+	n := 0
+	fetch := func(ctx context.Context) ([]QItem, error) {
+		n++
+		fetched <- struct{}{}
+		return []QItem{{ID: "job", Priority: n}}, nil
+	}
+	pq, err := New(WithSeed(fetch, SeedReplace, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error creating the queue: %v", err)
+	}
+	<-fetched
+	<-fetched
+	<-fetched
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pq.Stop(ctx)
+	assertEqual(t, pq.Len(), 1)
+	x, _ := pq.Peek()
+	assertEqual(t, x.Priority > 1, true)
+}
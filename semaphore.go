@@ -0,0 +1,114 @@
+package priorityqueue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// A PrioritySemaphore limits the number of goroutines holding one of its
+// slots, granting the slots freed by Release to the waiting goroutine of
+// highest priority. The waiters are the items of a queue: waiters of equal
+// priority are granted slots in no particular order, as the queue pops
+// items of equal priority, and waiters of low priority may starve unless
+// escalation rules raise their priority, see SetEscalationRules.
+type PrioritySemaphore struct {
+	pq *PriorityQueue
+
+	m       sync.Mutex
+	free    int
+	slots   int
+	seq     uint64
+	waiters map[string]chan struct{}
+}
+
+// NewPrioritySemaphore returns a PrioritySemaphore with the given number of
+// slots, all free.
+func NewPrioritySemaphore(slots int) *PrioritySemaphore {
+	return &PrioritySemaphore{
+		pq:      NewPriorityQueue(),
+		free:    slots,
+		slots:   slots,
+		waiters: make(map[string]chan struct{}),
+	}
+}
+
+// Acquire takes a slot, waiting until one is granted or ctx is done. It
+// returns the error of ctx if ctx is done before a slot is granted, and
+// holds no slot then.
+func (s *PrioritySemaphore) Acquire(ctx context.Context, priority int) error {
+	s.m.Lock()
+	if s.free > 0 {
+		s.free--
+		s.m.Unlock()
+		return nil
+	}
+	s.seq++
+	id := strconv.FormatUint(s.seq, 10)
+	granted := make(chan struct{})
+	s.waiters[id] = granted
+	s.pq.Push(QItem{ID: id, Priority: priority})
+	s.m.Unlock()
+
+	select {
+	case <-granted:
+		return nil
+	case <-ctx.Done():
+	}
+	s.m.Lock()
+	if _, waiting := s.waiters[id]; waiting {
+		delete(s.waiters, id)
+		s.pq.DeleteItemById(id)
+		s.m.Unlock()
+		return ctx.Err()
+	}
+	// Granted meanwhile: hand the slot on
+	s.m.Unlock()
+	s.Release()
+	return ctx.Err()
+}
+
+// SetEscalationRules replaces the rules raising the priority of the
+// goroutines left waiting, applied at every Release as the escalation rules
+// of a queue are by Escalate. Waiters carry no ParentID: rules select them
+// by how long they waited and the priority they have.
+func (s *PrioritySemaphore) SetEscalationRules(rules ...EscalationRule) error {
+	return s.pq.SetEscalationRules(0, rules...)
+}
+
+// TryAcquire takes a slot if one is free, without waiting. It reports
+// whether it took a slot.
+func (s *PrioritySemaphore) TryAcquire() bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.free > 0 {
+		s.free--
+		return true
+	}
+	return false
+}
+
+// Release frees a slot taken by Acquire or TryAcquire, granting it to the
+// waiting goroutine of highest priority. It panics if no slot is taken.
+func (s *PrioritySemaphore) Release() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.pq.Escalate()
+	item, err := s.pq.Pop()
+	if err != nil {
+		if s.free == s.slots {
+			panic("priorityqueue: PrioritySemaphore released more than acquired")
+		}
+		s.free++
+		return
+	}
+	close(s.waiters[item.ID])
+	delete(s.waiters, item.ID)
+}
+
+// Waiting returns the number of goroutines waiting for a slot
+func (s *PrioritySemaphore) Waiting() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.waiters)
+}
@@ -0,0 +1,49 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_PrioritySemaphore(t *testing.T) {
+	s := NewPrioritySemaphore(2)
+	ctx := context.Background()
+	s.Acquire(ctx, 0)
+	assertEqual(t, s.TryAcquire(), true)
+	assertEqual(t, s.TryAcquire(), false)
+
+	granted := make(chan int, 3)
+	for _, p := range []int{1, 3, 2} {
+		go func(p int) {
+			s.Acquire(ctx, p)
+			granted <- p
+		}(p)
+	}
+	for s.Waiting() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	for _, expected := range []int{3, 2, 1} {
+		s.Release()
+		assertEqual(t, <-granted, expected)
+	}
+
+	// A waiter giving up leaves the line
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assertEqual(t, s.Acquire(cctx, 5), context.DeadlineExceeded)
+	assertEqual(t, s.Waiting(), 0)
+
+	s.Release()
+	s.Release()
+	assertEqual(t, s.TryAcquire(), true)
+}
+
+func Test_PrioritySemaphoreOverRelease(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Releasing a free slot did not panic")
+		}
+	}()
+	NewPrioritySemaphore(1).Release()
+}
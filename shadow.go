@@ -0,0 +1,122 @@
+package priorityqueue
+
+import (
+	"sync"
+)
+
+// ShadowDiffs is the number of differences a Shadow keeps, oldest dropped first
+const ShadowDiffs = 1000
+
+// A ShadowDiff records a Pop for which the shadow queue would have returned
+// a different item than the primary queue.
+type ShadowDiff struct {
+	Pop     int   // Number of the Pop, counting from 1
+	Primary QItem // The item popped from the primary queue
+	Shadow  QItem // The item the shadow queue would have returned
+}
+
+// ShadowStats summarizes the pops compared by a Shadow
+type ShadowStats struct {
+	Pops     int // Pops that returned an item
+	Diverged int // Pops for which the shadow queue disagreed
+}
+
+// A Shadow is a Queue serving a primary queue that mirrors every change into
+// a shadow queue configured differently, for example with other parent base
+// priorities or redelivery boosts, or another Queue implementation. Every
+// Pop compares the item the shadow would have returned with the one the
+// primary returned and records differences, so a scheduling change can be
+// evaluated on production traffic. The popped item is then deleted from the
+// shadow too, keeping both queues' contents identical.
+//
+// The shadow never affects results: its errors are ignored. It does double
+// the cost of every operation.
+type Shadow struct {
+	primary Queue
+	shadow  Queue
+
+	m     sync.Mutex
+	stats ShadowStats
+	diffs []ShadowDiff
+}
+
+var _ Queue = (*Shadow)(nil)
+
+// NewShadow returns a Shadow serving primary and mirroring it into shadow
+func NewShadow(primary, shadow Queue) *Shadow {
+	return &Shadow{primary: primary, shadow: shadow}
+}
+
+func (s *Shadow) Push(i QItem) error {
+	if err := s.primary.Push(i); err != nil {
+		return err
+	}
+	s.shadow.Push(i)
+	return nil
+}
+
+func (s *Shadow) Pop() (*QItem, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	item, err := s.primary.Pop()
+	if err != nil {
+		return item, err
+	}
+	s.stats.Pops++
+	if other, err := s.shadow.Peek(); err == nil && other.ID != item.ID {
+		s.stats.Diverged++
+		s.diffs = append(s.diffs, ShadowDiff{Pop: s.stats.Pops, Primary: *item, Shadow: *other})
+		if len(s.diffs) > ShadowDiffs {
+			s.diffs = s.diffs[len(s.diffs)-ShadowDiffs:]
+		}
+	}
+	s.shadow.DeleteItemById(item.ID)
+	return item, nil
+}
+
+func (s *Shadow) Peek() (*QItem, error) {
+	return s.primary.Peek()
+}
+
+func (s *Shadow) Len() int {
+	return s.primary.Len()
+}
+
+func (s *Shadow) Clear() {
+	s.primary.Clear()
+	s.shadow.Clear()
+}
+
+func (s *Shadow) UpdatePriorityByParentId(parentID string, priority int) int {
+	s.shadow.UpdatePriorityByParentId(parentID, priority)
+	return s.primary.UpdatePriorityByParentId(parentID, priority)
+}
+
+func (s *Shadow) DeleteItemById(id string) error {
+	s.shadow.DeleteItemById(id)
+	return s.primary.DeleteItemById(id)
+}
+
+func (s *Shadow) DeleteItemsByParentId(parentID string) (int, error) {
+	s.shadow.DeleteItemsByParentId(parentID)
+	return s.primary.DeleteItemsByParentId(parentID)
+}
+
+// Stats returns the number of pops compared and how many diverged
+func (s *Shadow) Stats() ShadowStats {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.stats
+}
+
+// Diffs returns the latest differences, oldest first
+func (s *Shadow) Diffs() []ShadowDiff {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]ShadowDiff(nil), s.diffs...)
+}
+
+// Capabilities returns those of the primary queue
+func (s *Shadow) Capabilities() Capabilities {
+	return CapabilitiesOf(s.primary)
+}
@@ -0,0 +1,53 @@
+package priorityqueue
+
+import (
+	"testing"
+)
+
+func Test_ShadowAgrees(t *testing.T) {
+	s := NewShadow(NewPriorityQueue(), NewSortedQueue())
+	for i := 0; i < 10; i++ {
+		s.Push(QItem{ID: string(rune('a' + i)), Priority: i})
+	}
+	for s.Len() > 0 {
+		s.Pop()
+	}
+	assertEqual(t, s.Stats(), ShadowStats{Pops: 10})
+	assertEqual(t, len(s.Diffs()), 0)
+}
+
+func Test_ShadowDiverges(t *testing.T) {
+	primary, shadow := NewPriorityQueue(), NewPriorityQueue()
+	shadow.SetParentPriority("batch", 100)
+	s := NewShadow(primary, shadow)
+	s.Push(QItem{ID: "1", ParentID: "batch", Priority: 1})
+	s.Push(QItem{ID: "2", ParentID: "web", Priority: 5})
+
+	item, _ := s.Pop()
+	assertEqual(t, item.ID, "2")
+	item, _ = s.Pop()
+	assertEqual(t, item.ID, "1")
+
+	assertEqual(t, s.Stats(), ShadowStats{Pops: 2, Diverged: 1})
+	diffs := s.Diffs()
+	assertEqual(t, len(diffs), 1)
+	assertEqual(t, diffs[0].Pop, 1)
+	assertEqual(t, diffs[0].Shadow.ID, "1")
+	assertEqual(t, shadow.Len(), 0)
+}
+
+func Test_ShadowMirrorsChanges(t *testing.T) {
+	primary, shadow := NewPriorityQueue(), NewSortedQueue()
+	s := NewShadow(primary, shadow)
+	for i := 0; i < 5; i++ {
+		s.Push(QItem{ID: string(rune('0' + i)), ParentID: "12345", Priority: i + 1})
+	}
+	s.DeleteItemById("0")
+	s.UpdatePriorityByParentId("12345", 7)
+	assertEqual(t, shadow.Len(), 4)
+	item, _ := shadow.Peek()
+	assertEqual(t, item.Priority, 7)
+
+	s.Clear()
+	assertEqual(t, shadow.Len(), 0)
+}
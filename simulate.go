@@ -0,0 +1,196 @@
+package priorityqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// A SimEvent is one step of a workload replayed by Simulate: a push of Item,
+// or a pop or delete, at Time.
+type SimEvent struct {
+	Time time.Time
+	Op   Operation // OpPush, OpPop or OpDeleteItemById
+	Item QItem     // The pushed item, or the ID of the deleted one
+}
+
+// WaitStats summarizes how long items waited between push and pop
+type WaitStats struct {
+	Count int
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func waitStats(d []time.Duration) WaitStats {
+	if len(d) == 0 {
+		return WaitStats{}
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	var sum time.Duration
+	for _, w := range d {
+		sum += w
+	}
+	at := func(p float64) time.Duration {
+		return d[int(p*float64(len(d)-1))]
+	}
+	return WaitStats{
+		Count: len(d),
+		Mean:  sum / time.Duration(len(d)),
+		P50:   at(0.50),
+		P90:   at(0.90),
+		P99:   at(0.99),
+		Max:   d[len(d)-1],
+	}
+}
+
+// A SimReport is the outcome of a simulation
+type SimReport struct {
+	Overall    WaitStats
+	ByPriority map[int]WaitStats    // By the priority items were pushed with
+	ByTenant   map[string]WaitStats // By Tenant
+	Unpopped   int                  // Items still queued at the end
+	EmptyPops  int                  // Pops that found nothing eligible
+}
+
+// Simulate replays a workload against a fresh queue set up by configure,
+// which may be nil, on a simulated clock, and reports how long items waited
+// per priority and per tenant. Pops take whatever the configured queue
+// returns at that time, so comparing the reports of two configurations on
+// the same workload shows the effect of a scheduling change before it is
+// deployed. Events are applied in time order; item values are not kept.
+func Simulate(events []SimEvent, configure func(*PriorityQueue)) SimReport {
+	events = append([]SimEvent(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	pq := NewPriorityQueue()
+	var now time.Time
+	pq.clock = func() time.Time { return now }
+	if configure != nil {
+		configure(pq)
+	}
+
+	var all []time.Duration
+	byPriority := make(map[int][]time.Duration)
+	byTenant := make(map[string][]time.Duration)
+	report := SimReport{}
+
+	for _, e := range events {
+		now = e.Time
+		switch e.Op {
+		case OpPush:
+			i := e.Item
+			i.PushedAt = now
+			i.Value = i.Priority // remembered through priority changes
+			pq.Push(i)
+		case OpPop:
+			item, err := pq.Pop()
+			if err != nil {
+				report.EmptyPops++
+				continue
+			}
+			w := now.Sub(item.PushedAt)
+			all = append(all, w)
+			p := item.Value.(int)
+			byPriority[p] = append(byPriority[p], w)
+			byTenant[item.Tenant] = append(byTenant[item.Tenant], w)
+		case OpDeleteItemById:
+			pq.DeleteItemById(e.Item.ID)
+		}
+	}
+
+	report.Overall = waitStats(all)
+	report.ByPriority = make(map[int]WaitStats, len(byPriority))
+	for p, d := range byPriority {
+		report.ByPriority[p] = waitStats(d)
+	}
+	report.ByTenant = make(map[string]WaitStats, len(byTenant))
+	for tenant, d := range byTenant {
+		report.ByTenant[tenant] = waitStats(d)
+	}
+	report.Unpopped = pq.Len()
+	return report
+}
+
+// AuditNDJSON returns an audit log writing every entry to w as one JSON
+// object per line, for example to record a workload for Simulate:
+//
+//	pq.SetAuditLog(priorityqueue.AuditNDJSON(f))
+//
+// Write errors are ignored.
+func AuditNDJSON(w io.Writer) func(AuditEntry) {
+	enc := json.NewEncoder(w)
+	return func(e AuditEntry) {
+		enc.Encode(e)
+	}
+}
+
+// ReadAuditWorkload reads the audit entries written by AuditNDJSON and turns
+// them into simulation events: pushes, imports and restores become pushes,
+// pops, leases and reservations become pops and deletes become deletes.
+// Other entries are skipped.
+func ReadAuditWorkload(r io.Reader) ([]SimEvent, error) {
+	var events []SimEvent
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var e AuditEntry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, fmt.Errorf("decoding audit entry %d: %w", n, err)
+		}
+		item := QItem{ID: e.ID, ParentID: e.ParentID, Tenant: e.Tenant, Priority: e.Priority, Producer: e.Producer}
+		switch e.Op {
+		case OpPush, OpImport, OpRestore:
+			events = append(events, SimEvent{Time: e.Time, Op: OpPush, Item: item})
+		case OpPop, OpLease, OpReserve:
+			events = append(events, SimEvent{Time: e.Time, Op: OpPop})
+		case OpDeleteItemById, OpDeleteItemsByParentId, OpDeleteItemsByParentTree, OpClear:
+			events = append(events, SimEvent{Time: e.Time, Op: OpDeleteItemById, Item: item})
+		}
+	}
+}
+
+// ReadExportWorkload reads items exported with ExportNDJSON, pushed at their
+// PushedAt, and adds a pop every popInterval from the first push until every
+// item could have been popped, modelling a consumer with a fixed rate.
+func ReadExportWorkload(r io.Reader, popInterval time.Duration) ([]SimEvent, error) {
+	var events []SimEvent
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var rec itemRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding record %d: %w", n, err)
+		}
+		i := rec.qItem()
+		events = append(events, SimEvent{Time: i.PushedAt, Op: OpPush, Item: i})
+	}
+	if len(events) == 0 || popInterval <= 0 {
+		return events, nil
+	}
+	first, last := events[0].Time, events[0].Time
+	for _, e := range events {
+		if e.Time.Before(first) {
+			first = e.Time
+		}
+		if e.Time.After(last) {
+			last = e.Time
+		}
+	}
+	pops := len(events)
+	for n := 1; n <= pops || !first.Add(time.Duration(n)*popInterval).After(last); n++ {
+		// Events are sorted stably, a pop at the time of a push follows it
+		events = append(events, SimEvent{Time: first.Add(time.Duration(n) * popInterval), Op: OpPop})
+	}
+	return events, nil
+}
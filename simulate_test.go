@@ -0,0 +1,71 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_Simulate(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	events := []SimEvent{
+		{Time: at(0), Op: OpPush, Item: QItem{ID: "bulk", ParentID: "batch", Priority: 1, Tenant: "a"}},
+		{Time: at(1), Op: OpPush, Item: QItem{ID: "urgent", ParentID: "web", Priority: 9, Tenant: "b"}},
+		{Time: at(3), Op: OpPop},
+		{Time: at(5), Op: OpPop},
+		{Time: at(6), Op: OpPop},
+	}
+
+	report := Simulate(events, nil)
+	assertEqual(t, report.Overall.Count, 2)
+	assertEqual(t, report.ByPriority[9].Max, 2*time.Second)
+	assertEqual(t, report.ByPriority[1].Max, 5*time.Second)
+	assertEqual(t, report.ByTenant["a"].Count, 1)
+	assertEqual(t, report.EmptyPops, 1)
+
+	// Favouring batch work reverses the waits
+	report = Simulate(events, func(pq *PriorityQueue) {
+		pq.SetParentPriority("batch", 100)
+	})
+	assertEqual(t, report.ByPriority[1].Max, 3*time.Second)
+	assertEqual(t, report.ByPriority[9].Max, 4*time.Second)
+}
+
+func Test_ReadAuditWorkload(t *testing.T) {
+	var buf bytes.Buffer
+	pq := NewPriorityQueue()
+	pq.SetAuditLog(AuditNDJSON(&buf))
+	populateQueue(pq, 3)
+	pq.UpdatePriorityByParentId("12345", 1)
+	pq.Pop()
+	pq.DeleteItemById("0")
+
+	events, err := ReadAuditWorkload(&buf)
+	if err != nil {
+		t.Errorf("Error reading audit workload: %v", err)
+	}
+	assertEqual(t, len(events), 5)
+	report := Simulate(events, nil)
+	assertEqual(t, report.Overall.Count, 1)
+	assertEqual(t, report.Unpopped, 1)
+}
+
+func Test_ReadExportWorkload(t *testing.T) {
+	pq := NewPriorityQueue()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		pq.Push(QItem{ID: string(rune('a' + i)), Priority: i, PushedAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	var buf bytes.Buffer
+	pq.ExportNDJSON(&buf)
+
+	events, err := ReadExportWorkload(&buf, time.Second)
+	if err != nil {
+		t.Errorf("Error reading export workload: %v", err)
+	}
+	assertEqual(t, len(events), 6)
+	report := Simulate(events, nil)
+	assertEqual(t, report.Overall.Count, 3)
+	assertEqual(t, report.Unpopped, 0)
+}
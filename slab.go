@@ -0,0 +1,106 @@
+package priorityqueue
+
+import "fmt"
+
+// SlabStats counts the item storage of a queue created WithSlabAllocator.
+// Allocated against Reused shows how many item allocations the slabs saved.
+type SlabStats struct {
+	Slabs     int    // Slabs allocated
+	InUse     int    // Slots holding an item the queue still references
+	Free      int    // Slots ready to be reused
+	Allocated uint64 // Items stored in a never used slot
+	Reused    uint64 // Items stored in a freed slot
+}
+
+// itemSlab hands out item slots carved from slabs of fixed size. A slot
+// freed when its item leaves the queue is only reused from the next
+// operation on, once the operation that removed the item is done with it.
+type itemSlab struct {
+	size    int
+	slab    []QItem // The unused tail of the current slab
+	free    []*QItem
+	pending []*QItem
+	stats   SlabStats
+}
+
+// WithSlabAllocator stores items in slabs of size slots, reusing the slot
+// of an item that is popped, leased, deleted or expired, rather than
+// allocating each item on its own. For queues with a high churn this cuts
+// the allocations and the objects the garbage collector marks; Stats().Slab
+// shows the effect. Pop then returns a copy of the stored item, and ForEach
+// may visit items pushed after it started into a reused slot.
+func WithSlabAllocator(size int) Option {
+	return func(pq *PriorityQueue) error {
+		if size < 1 {
+			return fmt.Errorf("slab size %d is not positive", size)
+		}
+		if pq.size() > 0 {
+			return fmt.Errorf("slab allocator set on a queue holding %d items", pq.size())
+		}
+		pq.slab = &itemSlab{size: size}
+		return nil
+	}
+}
+
+// alloc stores i in a free slot
+func (s *itemSlab) alloc(i QItem) *QItem {
+	var item *QItem
+	if n := len(s.free); n > 0 {
+		item = s.free[n-1]
+		s.free = s.free[:n-1]
+		s.stats.Reused++
+	} else {
+		if len(s.slab) == 0 {
+			s.slab = make([]QItem, s.size)
+			s.stats.Slabs++
+		}
+		item = &s.slab[0]
+		s.slab = s.slab[1:]
+		s.stats.Allocated++
+	}
+	*item = i
+	return item
+}
+
+// release frees the slot of an item removed from the queue
+func (s *itemSlab) release(item *QItem) {
+	s.pending = append(s.pending, item)
+}
+
+// recycle makes the slots released by earlier operations reusable, clearing
+// them so they no longer hold on to the values of their items.
+func (s *itemSlab) recycle() {
+	for n, item := range s.pending {
+		*item = QItem{index: -1}
+		s.free = append(s.free, item)
+		s.pending[n] = nil
+	}
+	s.pending = s.pending[:0]
+}
+
+func (s *itemSlab) snapshot() SlabStats {
+	st := s.stats
+	st.Free = len(s.free)
+	st.InUse = int(st.Allocated) - st.Free - len(s.pending)
+	return st
+}
+
+// newItem stores i, in a slab slot if there is a slab allocator. The queue
+// lock must be held.
+func (pq *PriorityQueue) newItem(i QItem) *QItem {
+	if pq.slab == nil {
+		return &i
+	}
+	return pq.slab.alloc(i)
+}
+
+// handOut returns the item removed from the queue that the caller receives:
+// the stored item itself, or a copy once its slot is to be reused. The queue
+// lock must be held.
+func (pq *PriorityQueue) handOut(item *QItem) *QItem {
+	if pq.slab == nil {
+		return item
+	}
+	c := *item
+	return &c
+}
@@ -0,0 +1,54 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_SlabAllocator(t *testing.T) {
+	pq := NewPriorityQueue(WithSlabAllocator(4))
+	populateQueue(pq, 6)
+	s := pq.Stats().Slab
+	assertEqual(t, s.Slabs, 2)
+	assertEqual(t, s.Allocated, uint64(6))
+	assertEqual(t, s.InUse, 6)
+
+	first, _ := pq.Pop()
+	second, _ := pq.Pop()
+	// The popped items are copies, untouched when their slots are reused
+	pq.Push(QItem{ID: "new", ParentID: "other", Priority: 100})
+	assertEqual(t, first.ID, "5")
+	assertEqual(t, second.ID, "4")
+
+	s = pq.Stats().Slab
+	assertEqual(t, s.Reused, uint64(1))
+	assertEqual(t, s.Free, 1)
+	assertEqual(t, s.InUse, 5)
+	assertEqual(t, pq.Healthy(), nil)
+
+	item, receipt, _ := pq.Lease(0)
+	assertEqual(t, item.ID, "new")
+	pq.Ack(receipt)
+	n, _ := pq.DeleteItemsByParentId("12345")
+	assertEqual(t, n, 4)
+	assertEqual(t, pq.Stats().Slab.InUse, 0)
+
+	_, err := New(WithSlabAllocator(0))
+	assertEqual(t, err != nil, true)
+}
+
+func Test_SlabExpiry(t *testing.T) {
+	pq := NewPriorityQueue(WithSlabAllocator(8))
+	advance := fakeClock(pq)
+	now := pq.now()
+	pq.Push(QItem{ID: "a", Priority: 1, ExpiresAt: now.Add(time.Minute)})
+	pq.Pop()
+	// b reuses the slot of a, whose expiry must not drop it
+	pq.Push(QItem{ID: "b", Priority: 1, ExpiresAt: now.Add(time.Hour)})
+	assertEqual(t, pq.Stats().Slab.Reused, uint64(1))
+	advance(2 * time.Minute)
+	assertEqual(t, pq.Len(), 1)
+	advance(time.Hour)
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, pq.State("b"), StateExpired)
+}
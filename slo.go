@@ -0,0 +1,202 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultSLOWindows are the rolling windows an SLO is tracked over when it
+// lists none
+var DefaultSLOWindows = []time.Duration{5 * time.Minute, time.Hour, 6 * time.Hour}
+
+// An SLO is a wait-time objective: the fraction Objective of the items must
+// be handed out, popped, leased or reserved, within Target of being pushed.
+// Items expiring unserved miss it. The queue tracks the compliance over
+// rolling windows so alerting can key off the latency users see rather
+// than the depth of the queue.
+type SLO struct {
+	Target    Duration `json:"target"`
+	Objective float64  `json:"objective"` // Such as 0.99
+
+	// Windows are the rolling windows tracked, DefaultSLOWindows if empty
+	Windows []Duration `json:"windows,omitempty"`
+
+	// OnBurn, when set, is called when the burn rate over a window rises
+	// to BurnRate, and OnRecover once it falls below again, whether by
+	// items served in time or by misses leaving the window. They are
+	// called after the queue lock has been released.
+	BurnRate  float64         `json:"burn_rate,omitempty"`
+	OnBurn    func(SLOWindow) `json:"-"`
+	OnRecover func(SLOWindow) `json:"-"`
+}
+
+// An SLOWindow is the compliance of the items served over a rolling window
+type SLOWindow struct {
+	Window time.Duration `json:"window"`
+	Served int           `json:"served"` // Items handed out or expired
+	Met    int           `json:"met"`    // Items handed out within the target
+
+	// Compliance is Met over Served, 1 when no item was served
+	Compliance float64 `json:"compliance"`
+
+	// BurnRate is how fast the window consumes the error budget, the
+	// fraction of the items allowed to miss the target: at 1 the budget
+	// lasts exactly the window, at 10 a tenth of it.
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// An SLOReport is the compliance with an SLO over each of its windows,
+// shortest first
+type SLOReport struct {
+	Target    time.Duration `json:"target"`
+	Objective float64       `json:"objective"`
+	Windows   []SLOWindow   `json:"windows"`
+}
+
+// sloTracker counts the items meeting the SLO in buckets spanning the
+// longest window
+type sloTracker struct {
+	slo     SLO
+	windows []time.Duration // Shortest first
+	width   time.Duration   // Time spanned by a bucket
+	buckets []sloBucket     // Oldest first
+	burning []bool          // Whether each window burns at or above BurnRate
+}
+
+type sloBucket struct {
+	start       time.Time
+	served, met int
+}
+
+// sloBuckets is the number of buckets the shortest window spans
+const sloBuckets = 10
+
+// SetSLO tracks the wait-time objective slo, see SLOReport, replacing the
+// one tracked before and its counts. Nil stops tracking.
+func (pq *PriorityQueue) SetSLO(slo *SLO) error {
+	var t *sloTracker
+	if slo != nil {
+		if slo.Target <= 0 {
+			return fmt.Errorf("SLO target %v is not positive", time.Duration(slo.Target))
+		}
+		if slo.Objective <= 0 || slo.Objective >= 1 {
+			return fmt.Errorf("SLO objective %v is not between 0 and 1", slo.Objective)
+		}
+		t = &sloTracker{slo: *slo}
+		for _, w := range slo.Windows {
+			if w <= 0 {
+				return fmt.Errorf("SLO window %v is not positive", time.Duration(w))
+			}
+			t.windows = append(t.windows, time.Duration(w))
+		}
+		if len(t.windows) == 0 {
+			t.windows = append(t.windows, DefaultSLOWindows...)
+		}
+		sort.Slice(t.windows, func(i, j int) bool { return t.windows[i] < t.windows[j] })
+		t.width = max(t.windows[0]/sloBuckets, time.Second)
+		t.burning = make([]bool, len(t.windows))
+	}
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.slo = t
+	return nil
+}
+
+// WithSLO is SetSLO
+func WithSLO(slo SLO) Option {
+	return func(pq *PriorityQueue) error {
+		return pq.SetSLO(&slo)
+	}
+}
+
+// serve counts the transition of item from one state to another against
+// the SLO: handing it out meets the SLO if it waited less than the target,
+// expiring it misses. The queue lock must be held.
+func (pq *PriorityQueue) serve(item *QItem, from, to State) {
+	t := pq.slo
+	if t == nil || from != StateQueued {
+		return
+	}
+	now := pq.now()
+	met := false
+	switch to {
+	case StateInFlight, StatePopped:
+		met = now.Sub(item.PushedAt) <= time.Duration(t.slo.Target)
+	case StateExpired:
+	default:
+		return
+	}
+	b := t.bucket(now)
+	b.served++
+	if met {
+		b.met++
+	}
+	if (t.slo.OnBurn == nil && t.slo.OnRecover == nil) || t.slo.BurnRate <= 0 {
+		return
+	}
+	for n, w := range t.report(now) {
+		burning := w.BurnRate >= t.slo.BurnRate
+		fn := t.slo.OnRecover
+		if burning {
+			fn = t.slo.OnBurn
+		}
+		if burning != t.burning[n] && fn != nil {
+			pq.deferred = append(pq.deferred, func() { fn(w) })
+		}
+		t.burning[n] = burning
+	}
+}
+
+// bucket returns the bucket counting the items served at now, dropping the
+// buckets older than the longest window
+func (t *sloTracker) bucket(now time.Time) *sloBucket {
+	oldest := now.Add(-t.windows[len(t.windows)-1])
+	drop := 0
+	for drop < len(t.buckets) && !t.buckets[drop].start.Add(t.width).After(oldest) {
+		drop++
+	}
+	t.buckets = t.buckets[drop:]
+	if n := len(t.buckets); n == 0 || !now.Before(t.buckets[n-1].start.Add(t.width)) {
+		t.buckets = append(t.buckets, sloBucket{start: now.Truncate(t.width)})
+	}
+	return &t.buckets[len(t.buckets)-1]
+}
+
+// report sums the buckets of each window at now. A bucket counts in a
+// window if it ends after the window starts, so the windows reach back up
+// to a bucket width further.
+func (t *sloTracker) report(now time.Time) []SLOWindow {
+	windows := make([]SLOWindow, len(t.windows))
+	for n, w := range t.windows {
+		windows[n].Window = w
+		start := now.Add(-w)
+		for _, b := range t.buckets {
+			if b.start.Add(t.width).After(start) {
+				windows[n].Served += b.served
+				windows[n].Met += b.met
+			}
+		}
+		windows[n].Compliance = 1
+		if windows[n].Served > 0 {
+			windows[n].Compliance = float64(windows[n].Met) / float64(windows[n].Served)
+		}
+		windows[n].BurnRate = (1 - windows[n].Compliance) / (1 - t.slo.Objective)
+	}
+	return windows
+}
+
+// SLOReport returns the compliance with the SLO set by SetSLO over each of
+// its windows, the zero report if none is set
+func (pq *PriorityQueue) SLOReport() SLOReport {
+	defer pq.lock(OpStats)()
+	t := pq.slo
+	if t == nil {
+		return SLOReport{}
+	}
+	return SLOReport{
+		Target:    time.Duration(t.slo.Target),
+		Objective: t.slo.Objective,
+		Windows:   t.report(pq.now()),
+	}
+}
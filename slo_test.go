@@ -0,0 +1,89 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_SLO(t *testing.T) {
+	now := time.Now()
+	var burns []SLOWindow
+	pq, err := New(WithClock(func() time.Time { return now }), WithSLO(SLO{
+		Target:    Duration(time.Second),
+		Objective: 0.9,
+		Windows:   []Duration{Duration(time.Hour), Duration(time.Minute)},
+		BurnRate:  1.5,
+		OnBurn:    func(w SLOWindow) { burns = append(burns, w) },
+	}))
+	assertEqual(t, err, nil)
+	assertEqual(t, pq.SetSLO(&SLO{Target: Duration(time.Second), Objective: 1}) == nil, false)
+
+	// Nine items served in time, one late
+	populateQueue(pq, 10)
+	for n := 0; n < 9; n++ {
+		pq.Pop()
+	}
+	now = now.Add(2 * time.Second)
+	pq.Lease(time.Minute)
+	r := pq.SLOReport()
+	assertEqual(t, r.Target, time.Second)
+	assertEqual(t, len(r.Windows), 2)
+	assertEqual(t, r.Windows[0].Window, time.Minute)
+	assertEqual(t, r.Windows[0].Served, 10)
+	assertEqual(t, r.Windows[0].Met, 9)
+	assertEqual(t, r.Windows[0].Compliance, 0.9)
+	assertEqual(t, len(burns), 0)
+
+	// Expired items miss the objective
+	pq.Push(QItem{ID: "stale", ExpiresAt: now.Add(time.Second)})
+	now = now.Add(time.Second)
+	pq.Len()
+	r = pq.SLOReport()
+	assertEqual(t, r.Windows[0].Served, 11)
+	assertEqual(t, r.Windows[0].Met, 9)
+	assertEqual(t, len(burns), 2)
+	assertEqual(t, burns[0].Window, time.Minute)
+
+	// Once the short window has passed only the long one remembers
+	now = now.Add(2 * time.Minute)
+	r = pq.SLOReport()
+	assertEqual(t, r.Windows[0].Served, 0)
+	assertEqual(t, r.Windows[0].Compliance, 1.0)
+	assertEqual(t, r.Windows[1].Served, 11)
+
+	pq.SetSLO(nil)
+	assertEqual(t, len(pq.SLOReport().Windows), 0)
+}
+
+func Test_SLORecovers(t *testing.T) {
+	now := time.Now()
+	var events []string
+	pq, _ := New(WithClock(func() time.Time { return now }), WithSLO(SLO{
+		Target:    Duration(time.Second),
+		Objective: 0.5,
+		Windows:   []Duration{Duration(time.Minute)},
+		BurnRate:  1,
+		OnBurn:    func(SLOWindow) { events = append(events, "burn") },
+		OnRecover: func(SLOWindow) { events = append(events, "recover") },
+	}))
+	late := func() {
+		pq.Push(QItem{ID: "late", PushedAt: now})
+		now = now.Add(2 * time.Second)
+		pq.Pop()
+	}
+	late()
+	assertEqual(t, fmt.Sprint(events), "[burn]")
+
+	// Items served in time bring the window back below the burn rate
+	for i := 0; i < 2; i++ {
+		pq.Push(QItem{ID: "on time", PushedAt: now})
+		pq.Pop()
+	}
+	assertEqual(t, fmt.Sprint(events), "[burn recover]")
+
+	// So the next breach alerts again
+	late()
+	late()
+	assertEqual(t, fmt.Sprint(events), "[burn recover burn]")
+}
@@ -0,0 +1,265 @@
+package priorityqueue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot format written by Snapshot
+// and Migrate.
+const SnapshotVersion = 2
+
+// snapshotMagic starts the header line of every snapshot
+const snapshotMagic = "pqsnapshot"
+
+// ErrNotSnapshot is returned when a stream does not start with a snapshot header
+var ErrNotSnapshot = errors.New("not a priority queue snapshot")
+
+// A VersionError reports a snapshot written in a version this package cannot read
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("unsupported snapshot version %d (newest supported is %d)", e.Version, SnapshotVersion)
+}
+
+// itemRecord is the persisted form of a QItem used by snapshots and
+// exports. Fields may be added in later versions as long as older records
+// still decode.
+type itemRecord struct {
+	ID       string      `json:"id"`
+	ParentID string      `json:"parent_id,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+	Priority int         `json:"priority"`
+	Tenant   string      `json:"tenant,omitempty"`
+	Producer string      `json:"producer,omitempty"`
+	PushedAt *time.Time  `json:"pushed_at,omitempty"`
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Attempts  int        `json:"attempts,omitempty"`
+	Cost      int        `json:"cost,omitempty"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	Merged         int    `json:"merged,omitempty"`
+	SyncToken      string `json:"sync_token,omitempty"`
+
+	// Level names the priority level of the item in exports, for reading
+	// only: imports use the priority.
+	Level string `json:"level,omitempty"`
+}
+
+func toItemRecord(i *QItem) itemRecord {
+	rec := itemRecord{
+		ID:       i.ID,
+		ParentID: i.ParentID,
+		Value:    i.Value,
+		Priority: i.Priority,
+		Tenant:   i.Tenant,
+		Producer: i.Producer,
+		Attempts: i.Attempts,
+		Cost:     i.Cost,
+
+		IdempotencyKey: i.IdempotencyKey,
+		Merged:         i.Merged,
+		SyncToken:      i.SyncToken,
+	}
+	if !i.PushedAt.IsZero() {
+		t := i.PushedAt
+		rec.PushedAt = &t
+	}
+	if !i.ExpiresAt.IsZero() {
+		t := i.ExpiresAt
+		rec.ExpiresAt = &t
+	}
+	return rec
+}
+
+func (s itemRecord) qItem() QItem {
+	i := QItem{
+		ID:       s.ID,
+		ParentID: s.ParentID,
+		Value:    s.Value,
+		Priority: s.Priority,
+		Tenant:   s.Tenant,
+		Producer: s.Producer,
+		Attempts: s.Attempts,
+		Cost:     s.Cost,
+
+		IdempotencyKey: s.IdempotencyKey,
+		Merged:         s.Merged,
+		SyncToken:      s.SyncToken,
+	}
+	if s.PushedAt != nil {
+		i.PushedAt = *s.PushedAt
+	}
+	if s.ExpiresAt != nil {
+		i.ExpiresAt = *s.ExpiresAt
+	}
+	return i
+}
+
+// MarshalJSON encodes the item in the record format used by snapshots and
+// NDJSON exports.
+func (i QItem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toItemRecord(&i))
+}
+
+// UnmarshalJSON decodes an item in the record format used by snapshots and
+// NDJSON exports.
+func (i *QItem) UnmarshalJSON(b []byte) error {
+	var rec itemRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return err
+	}
+	*i = rec.qItem()
+	return nil
+}
+
+// A snapshotDecoder reads the body of a snapshot starting at offset. The
+// corruption it finds is passed to skip, which returns false to fail the
+// decoding with it; skip is nil to fail on any corruption.
+type snapshotDecoder func(r *bufio.Reader, offset int64, skip func(*CorruptionError) bool) ([]QItem, error)
+
+// snapshotDecoders reads the body of each supported snapshot version
+var snapshotDecoders = map[int]snapshotDecoder{
+	1: decodeSnapshotV1,
+	2: decodeSnapshotV2,
+}
+
+// writeSnapshotHeader writes the line identifying a snapshot and its version
+func writeSnapshotHeader(w io.Writer, version int) error {
+	_, err := fmt.Fprintf(w, "%s v%d\n", snapshotMagic, version)
+	return err
+}
+
+// readSnapshotHeader consumes the header line and returns the snapshot
+// version and the length of the line
+func readSnapshotHeader(r *bufio.Reader) (int, int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] != snapshotMagic || !strings.HasPrefix(fields[1], "v") {
+		return 0, 0, ErrNotSnapshot
+	}
+	version, err := strconv.Atoi(fields[1][1:])
+	if err != nil {
+		return 0, 0, ErrNotSnapshot
+	}
+	return version, len(line), nil
+}
+
+// decodeSnapshotV1 reads one JSON encoded item per line. Version 1 has no
+// checksums, a line failing to decode fails the snapshot.
+func decodeSnapshotV1(r *bufio.Reader, _ int64, _ func(*CorruptionError) bool) ([]QItem, error) {
+	var items []QItem
+	dec := json.NewDecoder(r)
+	for {
+		var s itemRecord
+		err := dec.Decode(&s)
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return items, fmt.Errorf("decoding snapshot item %d: %w", len(items)+1, err)
+		}
+		items = append(items, s.qItem())
+	}
+}
+
+// encodeSnapshot writes a current version snapshot of items
+func encodeSnapshot(w io.Writer, items []*QItem) error {
+	bw := bufio.NewWriter(w)
+	if err := writeSnapshotHeader(bw, SnapshotVersion); err != nil {
+		return err
+	}
+	var segment bytes.Buffer
+	enc := json.NewEncoder(&segment)
+	segments := 0
+	for start := 0; start < len(items); start += snapshotSegmentItems {
+		segment.Reset()
+		for _, item := range items[start:min(start+snapshotSegmentItems, len(items))] {
+			if err := enc.Encode(toItemRecord(item)); err != nil {
+				return err
+			}
+		}
+		sum := crc32.Checksum(segment.Bytes(), castagnoli)
+		if _, err := fmt.Fprintf(bw, "segment %d %d %08x\n", segments, segment.Len(), sum); err != nil {
+			return err
+		}
+		if _, err := bw.Write(segment.Bytes()); err != nil {
+			return err
+		}
+		segments++
+	}
+	if _, err := fmt.Fprintf(bw, "end %d\n", segments); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// decodeSnapshot reads a snapshot of any supported version, passing the
+// corruption found to skip, see snapshotDecoder.
+func decodeSnapshot(r io.Reader, skip func(*CorruptionError) bool) ([]QItem, error) {
+	br := bufio.NewReader(r)
+	version, n, err := readSnapshotHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	decode, ok := snapshotDecoders[version]
+	if !ok {
+		return nil, &VersionError{Version: version}
+	}
+	return decode(br, int64(n), skip)
+}
+
+// Snapshot writes every queued item, and every item leased or reserved but
+// not acked yet, to w in the current snapshot format, highest priority first.
+// Item values are encoded as JSON, so after a Restore they hold the
+// generic types produced by encoding/json rather than their original types.
+// The items are copied in chunks, as ForEach does, so the snapshot of a
+// queue that changes meanwhile is not a point-in-time view: use Freeze for
+// one.
+func (pq *PriorityQueue) Snapshot(w io.Writer) error {
+	items, err := pq.sortedItems(OpSnapshot, true)
+	if err != nil {
+		return err
+	}
+	return encodeSnapshot(w, items)
+}
+
+// Restore reads a snapshot written by Snapshot, in any supported version,
+// and adds its items to the queue. Nothing is added if the snapshot cannot
+// be read completely; a damaged snapshot fails with a *CorruptionError,
+// see RestoreBestEffort.
+func (pq *PriorityQueue) Restore(r io.Reader) error {
+	items, err := decodeSnapshot(r, nil)
+	if err != nil {
+		return err
+	}
+	return pq.pushAll(OpRestore, items)
+}
+
+// Migrate rewrites a snapshot of any supported version read from r into the
+// current version on w.
+func Migrate(r io.Reader, w io.Writer) error {
+	items, err := decodeSnapshot(r, nil)
+	if err != nil {
+		return err
+	}
+	ptrs := make([]*QItem, len(items))
+	for n := range items {
+		ptrs[n] = &items[n]
+	}
+	return encodeSnapshot(w, ptrs)
+}
@@ -0,0 +1,93 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SnapshotRestore(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+
+	var buf bytes.Buffer
+	if err := pq.Snapshot(&buf); err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "pqsnapshot v2\n") {
+		t.Errorf("Snapshot is missing its header: %q", buf.String())
+	}
+
+	restored := NewPriorityQueue()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Error restoring snapshot: %v", err)
+	}
+	assertEqual(t, restored.Len(), 10)
+	for pq.Len() > 0 {
+		x, _ := pq.Pop()
+		y, _ := restored.Pop()
+		assertEqual(t, y.ID, x.ID)
+		assertEqual(t, y.ParentID, x.ParentID)
+		assertEqual(t, y.Priority, x.Priority)
+		assertEqual(t, y.Value, x.Value)
+	}
+}
+
+func Test_SnapshotLeasedWhileCopying(t *testing.T) {
+	pq, _ := New(WithChunkSize(2))
+	populateQueue(pq, 4)
+	// Lease the head once the first chunk, holding it, has been copied
+	releases, leased := 0, false
+	pq.sched = func(op Operation, point schedPoint) {
+		if op == OpSnapshot && point == schedRelease {
+			if releases++; releases == 2 {
+				_, _, err := pq.Lease(time.Minute)
+				leased = err == nil
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := pq.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, leased, true)
+	restored := NewPriorityQueue()
+	restored.Restore(&buf)
+	assertEqual(t, restored.Len(), 4)
+}
+
+func Test_RestoreRejectsUnknownVersions(t *testing.T) {
+	pq := NewPriorityQueue()
+
+	err := pq.Restore(strings.NewReader("pqsnapshot v99\n"))
+	var verr *VersionError
+	if !errors.As(err, &verr) || verr.Version != 99 {
+		t.Errorf("Expected a VersionError for version 99, got %v", err)
+	}
+
+	err = pq.Restore(strings.NewReader("{\"id\":\"1\"}\n"))
+	assertEqual(t, err, ErrNotSnapshot)
+
+	err = pq.Restore(strings.NewReader("pqsnapshot v1\n{\"id\":\"1\"}\n{broken"))
+	if err == nil {
+		t.Errorf("Restoring a truncated snapshot should fail")
+	}
+	assertEqual(t, pq.Len(), 0)
+}
+
+func Test_Migrate(t *testing.T) {
+	in := "pqsnapshot v1\n{\"id\":\"a\",\"priority\":3,\"unknown\":true}\n{\"id\":\"b\",\"priority\":5}\n"
+	var out bytes.Buffer
+	if err := Migrate(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("Error migrating snapshot: %v", err)
+	}
+	pq := NewPriorityQueue()
+	if err := pq.Restore(&out); err != nil {
+		t.Fatalf("Error restoring migrated snapshot: %v", err)
+	}
+	x, _ := pq.Pop()
+	assertEqual(t, x.ID, "b")
+	assertEqual(t, pq.Len(), 1)
+}
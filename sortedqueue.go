@@ -0,0 +1,139 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SortedQueue is a deliberately simple Queue that keeps its items in a slice
+// sorted by descending priority, items of equal priority in push order.
+// Push, updates and deletes are O(n) and a bulk update is O(n²), which is
+// fine for tiny queues and makes it a trustworthy oracle in tests.
+type SortedQueue struct {
+	m     sync.Mutex
+	items []QItem
+}
+
+var _ Queue = (*SortedQueue)(nil)
+
+func NewSortedQueue() *SortedQueue {
+	return &SortedQueue{}
+}
+
+// insert places item after every item of equal or higher priority
+func (sq *SortedQueue) insert(i QItem) {
+	n := len(sq.items)
+	for n > 0 && sq.items[n-1].Priority < i.Priority {
+		n--
+	}
+	sq.items = append(sq.items, QItem{})
+	copy(sq.items[n+1:], sq.items[n:])
+	sq.items[n] = i
+}
+
+func (sq *SortedQueue) remove(n int) QItem {
+	item := sq.items[n]
+	sq.items = append(sq.items[:n], sq.items[n+1:]...)
+	return item
+}
+
+func (sq *SortedQueue) Push(i QItem) error {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	i.index = 0
+	stamp(&i)
+	sq.insert(i)
+	return nil
+}
+
+func (sq *SortedQueue) Pop() (*QItem, error) {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	if len(sq.items) == 0 {
+		return nil, ErrEmptyQueue
+	}
+	item := sq.remove(0)
+	item.index = -1
+	return &item, nil
+}
+
+func (sq *SortedQueue) Peek() (*QItem, error) {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	if len(sq.items) == 0 {
+		return nil, ErrEmptyQueue
+	}
+	item := sq.items[0]
+	return &item, nil
+}
+
+func (sq *SortedQueue) Len() int {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	return len(sq.items)
+}
+
+func (sq *SortedQueue) Clear() {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	sq.items = nil
+}
+
+// UpdatePriorityByParentId sets the priority of every item with a matching
+// ParentID, moving each one behind the items already at its new priority.
+func (sq *SortedQueue) UpdatePriorityByParentId(parentID string, priority int) int {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	var updated []QItem
+	for n := 0; n < len(sq.items); {
+		if sq.items[n].ParentID == parentID {
+			updated = append(updated, sq.remove(n))
+			continue
+		}
+		n++
+	}
+	for _, item := range updated {
+		item.Priority = priority
+		sq.insert(item)
+	}
+	return len(updated)
+}
+
+func (sq *SortedQueue) DeleteItemById(id string) error {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	for n, item := range sq.items {
+		if item.ID == id {
+			sq.remove(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: [%s]", ErrNotFound, id)
+}
+
+func (sq *SortedQueue) DeleteItemsByParentId(parentID string) (int, error) {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	rest := sq.items[:0]
+	for _, item := range sq.items {
+		if item.ParentID != parentID {
+			rest = append(rest, item)
+		}
+	}
+	deleted := len(sq.items) - len(rest)
+	sq.items = rest
+	return deleted, nil
+}
+
+// ForEach calls fn with a copy of every item, highest priority first, until
+// fn returns false. fn must not use the queue.
+func (sq *SortedQueue) ForEach(fn func(item QItem) bool) error {
+	sq.m.Lock()
+	defer sq.m.Unlock()
+	for _, item := range sq.items {
+		if !fn(item) {
+			break
+		}
+	}
+	return nil
+}
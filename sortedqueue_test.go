@@ -0,0 +1,47 @@
+package priorityqueue
+
+import (
+	"testing"
+)
+
+func Test_SortedQueueOrdering(t *testing.T) {
+	sq := NewSortedQueue()
+	sq.Push(QItem{ID: "a", Priority: 1})
+	sq.Push(QItem{ID: "b", Priority: 5})
+	sq.Push(QItem{ID: "c", Priority: 5})
+	sq.Push(QItem{ID: "d", Priority: 3})
+
+	x, _ := sq.Peek()
+	assertEqual(t, x.ID, "b")
+	for _, id := range []string{"b", "c", "d", "a"} {
+		x, err := sq.Pop()
+		if err != nil {
+			t.Fatalf("Error popping item: %v", err)
+		}
+		assertEqual(t, x.ID, id)
+	}
+	_, err := sq.Pop()
+	assertEqual(t, err, ErrEmptyQueue)
+}
+
+func Test_SortedQueueParentOperations(t *testing.T) {
+	sq := NewSortedQueue()
+	sq.Push(QItem{ID: "a", ParentID: "p", Priority: 1})
+	sq.Push(QItem{ID: "b", ParentID: "q", Priority: 5})
+	sq.Push(QItem{ID: "c", ParentID: "p", Priority: 2})
+
+	assertEqual(t, sq.UpdatePriorityByParentId("p", 9), 2)
+	x, _ := sq.Peek()
+	assertEqual(t, x.ParentID, "p")
+
+	n, err := sq.DeleteItemsByParentId("p")
+	assertEqual(t, err, nil)
+	assertEqual(t, n, 2)
+	assertEqual(t, sq.Len(), 1)
+
+	if sq.DeleteItemById("a") == nil {
+		t.Errorf("Deleting a missing ID should return an error")
+	}
+	assertEqual(t, sq.DeleteItemById("b"), nil)
+	assertEqual(t, sq.Len(), 0)
+}
@@ -0,0 +1,296 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Split moves the queued items of pq to two new queues, the items matching
+// pred to the first and the others to the second, such as to hand part of
+// the work of a node to another. Each new queue pops its items in the order
+// pq would have, and tells the time and breaks ties of priority as pq does;
+// configure anything else before use. The items are handed out as Pop
+// does, the Values restored by the Transformers and BlobStore of pq; items
+// whose Value cannot be restored stay in pq, as do the delayed and
+// in-flight items, so leases are still acked on pq. Splitting a destroyed
+// queue, or one whose Authorizer denies the split, returns two empty queues.
+func (pq *PriorityQueue) Split(pred func(QItem) bool) (*PriorityQueue, *PriorityQueue) {
+	first, second := pq.sibling(), pq.sibling()
+	items, err := pq.take(OpSplit, func(*QItem) bool { return true })
+	if err != nil {
+		return first, second
+	}
+	var in, out []QItem
+	for _, item := range items {
+		if pred(item) {
+			in = append(in, item)
+		} else {
+			out = append(out, item)
+		}
+	}
+	first.adopt(OpSplit, in)
+	second.adopt(OpSplit, out)
+	return first, second
+}
+
+// sibling returns an empty queue telling the time and breaking ties as pq
+func (pq *PriorityQueue) sibling() *PriorityQueue {
+	pq.m.Lock()
+	tie, clock, rr := pq.tieBreak, pq.clock, pq.rounds != nil
+	pq.m.Unlock()
+	q := newPriorityQueue()
+	q.tieBreak, q.clock = tie, clock
+	if rr {
+		q.rounds, q.servedRound = make(map[string]uint64), make(map[int]uint64)
+	}
+	return q
+}
+
+// take removes the queued items matching pred and returns them in pop
+// order, their Values restored as Pop hands them out. Items whose Value
+// cannot be restored are left queued. Nothing is taken unless the
+// Authorizer allows op on every matched item.
+func (pq *PriorityQueue) take(op Operation, pred func(*QItem) bool) ([]QItem, error) {
+	defer pq.lock(op)()
+	if err := pq.mutable(); err != nil {
+		return nil, err
+	}
+	matched := pq.collect(pred)
+	if err := pq.authorize(context.Background(), op, matched...); err != nil {
+		return nil, err
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return outranks(matched[i], matched[j], pq.tieBreak)
+	})
+	items := make([]QItem, 0, len(matched))
+	for _, item := range matched {
+		c, err := pq.untransform(item)
+		if err != nil {
+			continue
+		}
+		items = append(items, *c)
+		pq.audit(op, pq.remove(item.index, StatePopped))
+	}
+	return items, nil
+}
+
+// ShardName returns the name of shard n of the sharded queue called name in
+// a Manager
+func ShardName(name string, n int) string {
+	return fmt.Sprintf("%s/%d", name, n)
+}
+
+// ShardOf returns which of n shards holds the items of parentID
+func ShardOf(parentID string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return int(ringHash(parentID) % uint64(n))
+}
+
+// Shards returns the number of shards of the queue called name, 1 until
+// Reshard sets it
+func (mgr *Manager) Shards(name string) int {
+	mgr.m.Lock()
+	defer mgr.m.Unlock()
+	return mgr.shardCount(name)
+}
+
+// shardCount is Shards; the lock must be held
+func (mgr *Manager) shardCount(name string) int {
+	if n, ok := mgr.shards[name]; ok {
+		return n
+	}
+	return 1
+}
+
+// Shard returns the shard of the queue called name holding the items of
+// parentID, creating it if needed. Route the items of a sharded queue
+// with it, so every item of a parent lives on one shard and pops in order.
+func (mgr *Manager) Shard(name, parentID string) *PriorityQueue {
+	mgr.m.Lock()
+	n := mgr.shardCount(name)
+	mgr.m.Unlock()
+	return mgr.Queue(ShardName(name, ShardOf(parentID, n)))
+}
+
+// Reshard spreads the queue called name over n shards, moving the queued
+// items of the parents changing shard to their new one, and returns the
+// number of items moved. The items of a parent move together in pop order,
+// so each parent pops its items in the same order as before, and their
+// Values are stored again through the Transformers and BlobStore of their
+// new shard. The new count applies once every item has moved: items pushed
+// through Shard meanwhile go to the shards of the old count and are moved
+// in a second pass. Should a shard fail to take its items, the items not
+// moved yet go back to the shards they came from and the count is left as
+// it was. The shards beyond n are removed from the manager once empty;
+// until their delayed and in-flight items are gone they stay, so leases can
+// be acked, and a later Reshard moves what they queued again.
+func (mgr *Manager) Reshard(name string, n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("shard count %d is not positive", n)
+	}
+	mgr.resharding.Lock()
+	defer mgr.resharding.Unlock()
+
+	mgr.m.Lock()
+	// The shards of an earlier, larger count may still hold items
+	from := mgr.shardCount(name)
+	for queue := range mgr.queues {
+		suffix, ok := strings.CutPrefix(queue, name+"/")
+		if shard, err := strconv.Atoi(suffix); ok && err == nil && shard >= from {
+			from = shard + 1
+		}
+	}
+	var created []string
+	for shard := 0; shard < n; shard++ {
+		if _, ok := mgr.queues[ShardName(name, shard)]; !ok {
+			created = append(created, ShardName(name, shard))
+		}
+	}
+	mgr.m.Unlock()
+
+	dests := make([]*PriorityQueue, n)
+	for shard := range dests {
+		dests[shard] = mgr.Queue(ShardName(name, shard))
+	}
+	moved, err := mgr.moveShards(name, from, dests)
+	if err != nil {
+		for _, shard := range created {
+			if q, ok := mgr.Lookup(shard); ok && q.idle() {
+				mgr.Remove(shard)
+			}
+		}
+		return moved, err
+	}
+	mgr.m.Lock()
+	if mgr.shards == nil {
+		mgr.shards = make(map[string]int)
+	}
+	mgr.shards[name] = n
+	mgr.m.Unlock()
+
+	// Items pushed through Shard during the first pass went by the old count
+	more, err := mgr.moveShards(name, from, dests)
+	moved += more
+	if err != nil {
+		return moved, err
+	}
+	for shard := n; shard < from; shard++ {
+		if q, ok := mgr.Lookup(ShardName(name, shard)); ok && q.idle() {
+			mgr.Remove(ShardName(name, shard))
+		}
+	}
+	return moved, nil
+}
+
+// moveShards takes the queued items of shards 0 to from of the queue called
+// name that belong on another of dests and pushes them there, returning the
+// number of items moved. On failure the items not moved yet are pushed back
+// to the shards they were taken from.
+func (mgr *Manager) moveShards(name string, from int, dests []*PriorityQueue) (int, error) {
+	n := len(dests)
+	type group struct {
+		src   *PriorityQueue
+		items []QItem
+	}
+	// The items taken from each shard, by destination
+	var moves [][]group
+	putBack := func(pending []group, cause error) error {
+		errs := []error{cause}
+		for _, g := range pending {
+			if err := g.src.adopt(OpReshard, g.items); err != nil {
+				errs = append(errs, fmt.Errorf("putting back %d items: %w", len(g.items), err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	for shard := 0; shard < from; shard++ {
+		q, ok := mgr.Lookup(ShardName(name, shard))
+		if !ok {
+			continue
+		}
+		items, err := q.take(OpReshard, func(item *QItem) bool {
+			return ShardOf(item.ParentID, n) != shard
+		})
+		if err != nil {
+			var taken []group
+			for _, m := range moves {
+				taken = append(taken, m...)
+			}
+			return 0, putBack(taken, fmt.Errorf("resharding [%s]: %w", ShardName(name, shard), err))
+		}
+		to := make([][]QItem, n)
+		for _, item := range items {
+			dest := ShardOf(item.ParentID, n)
+			to[dest] = append(to[dest], item)
+		}
+		groups := make([]group, n)
+		for dest := range groups {
+			groups[dest] = group{src: q, items: to[dest]}
+		}
+		moves = append(moves, groups)
+	}
+
+	// Flatten the moves by destination, so a failing shard fails all the
+	// groups bound to it at once
+	var pending []group
+	var bound []int
+	for dest := 0; dest < n; dest++ {
+		for _, groups := range moves {
+			if len(groups[dest].items) > 0 {
+				pending = append(pending, groups[dest])
+				bound = append(bound, dest)
+			}
+		}
+	}
+	moved := 0
+	for k, g := range pending {
+		if err := dests[bound[k]].adopt(OpReshard, g.items); err != nil {
+			return moved, putBack(pending[k:], fmt.Errorf("resharding to [%s]: %w", ShardName(name, bound[k]), err))
+		}
+		moved += len(g.items)
+	}
+	return moved, nil
+}
+
+// adopt adds items handed out by take under a single lock, storing their
+// Values through the Transformers and BlobStore of the queue as a push
+// does. Nothing is added unless the Authorizer allows op on every item and
+// every Value is stored.
+func (pq *PriorityQueue) adopt(op Operation, items []QItem) error {
+	defer pq.lock(op)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	if err := pq.authorize(context.Background(), op, ptrs(items)...); err != nil {
+		return err
+	}
+	stored := make([]QItem, 0, len(items))
+	for _, item := range items {
+		err := pq.transform(&item)
+		if err == nil {
+			err = pq.offload(&item)
+		}
+		if err != nil {
+			for _, s := range stored {
+				pq.dropBlob(s.Value)
+			}
+			return err
+		}
+		stored = append(stored, item)
+	}
+	pq.insertAll(op, stored)
+	return nil
+}
+
+// idle reports whether the queue holds no item, queued, delayed, in flight
+// or coalescing
+func (pq *PriorityQueue) idle() bool {
+	defer pq.lock(OpStats)()
+	return pq.size() == 0 && len(pq.delayed) == 0 && len(pq.leases) == 0 && len(pq.coalescing) == 0
+}
@@ -0,0 +1,128 @@
+package priorityqueue
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Split(t *testing.T) {
+	pq, _ := New(WithTieBreak(FIFO))
+	for i := 0; i < 10; i++ {
+		pq.Push(QItem{ID: fmt.Sprint(i), ParentID: fmt.Sprint(i % 2), Priority: i / 4})
+	}
+	leased, _, _ := pq.Lease(time.Minute)
+	assertEqual(t, leased.ID, "8")
+
+	even, odd := pq.Split(func(item QItem) bool { return item.ParentID == "0" })
+	assertEqual(t, pq.Len(), 0)
+	assertEqual(t, pq.State("4"), StatePopped)
+	assertEqual(t, len(pq.InFlight()), 1)
+	assertEqual(t, even.Len(), 4)
+	assertEqual(t, odd.Len(), 5)
+
+	// Each half pops in the order of the queue split
+	for q, want := range map[*PriorityQueue]string{even: "[4 6 0 2]", odd: "[9 5 7 1 3]"} {
+		var got []string
+		for q.Len() > 0 {
+			item, _ := q.Pop()
+			got = append(got, item.ID)
+		}
+		assertEqual(t, fmt.Sprint(got), want)
+	}
+}
+
+func Test_Reshard(t *testing.T) {
+	mgr := NewManager()
+	for i := 0; i < 100; i++ {
+		parent := fmt.Sprint(i % 10)
+		mgr.Shard("jobs", parent).Push(QItem{ID: fmt.Sprint(i), ParentID: parent, Priority: i})
+	}
+	assertEqual(t, mgr.Shards("jobs"), 1)
+	assertEqual(t, mgr.Queue(ShardName("jobs", 0)).Len(), 100)
+
+	// checkShards verifies every item is on the shard of its parent
+	checkShards := func(n int) int {
+		total := 0
+		for shard := 0; shard < n; shard++ {
+			q, ok := mgr.Lookup(ShardName("jobs", shard))
+			if !ok {
+				continue
+			}
+			for _, item := range q.SortedView() {
+				if ShardOf(item.ParentID, n) != shard {
+					t.Errorf("Item [%s] of parent [%s] is on shard %d", item.ID, item.ParentID, shard)
+				}
+				total++
+			}
+		}
+		return total
+	}
+	moved, err := mgr.Reshard("jobs", 4)
+	assertEqual(t, err, nil)
+	assertEqual(t, mgr.Shards("jobs"), 4)
+	assertEqual(t, checkShards(4), 100)
+	assertEqual(t, moved, 100-mgr.Queue(ShardName("jobs", 0)).Len())
+
+	// Shrinking keeps the shards holding leases until they are acked
+	q := mgr.Queue(ShardName("jobs", 3))
+	_, receipt, _ := q.Lease(time.Minute)
+	_, err = mgr.Reshard("jobs", 1)
+	assertEqual(t, err, nil)
+	assertEqual(t, checkShards(1), 99)
+	assertEqual(t, q.Len(), 0)
+	assertEqual(t, fmt.Sprint(mgr.Names()), "[jobs/0 jobs/3]")
+	q.Ack(receipt)
+	mgr.Reshard("jobs", 1)
+	assertEqual(t, fmt.Sprint(mgr.Names()), "[jobs/0]")
+
+	_, err = mgr.Reshard("jobs", 0)
+	assertEqual(t, err == nil, false)
+}
+
+func Test_ReshardFailure(t *testing.T) {
+	mgr := NewManager()
+	// Each shard seals the Values it stores
+	mgr.New = func(string) *PriorityQueue {
+		pq, _ := New(WithTransformers(Transformer{
+			Name: "seal",
+			Push: func(item QItem) (interface{}, error) { return "sealed:" + item.Value.(string), nil },
+			Pop: func(item QItem) (interface{}, error) {
+				return strings.TrimPrefix(item.Value.(string), "sealed:"), nil
+			},
+		}))
+		return pq
+	}
+	for i := 0; i < 40; i++ {
+		parent := fmt.Sprint(i % 8)
+		mgr.Shard("jobs", parent).Push(QItem{ID: fmt.Sprint(i), ParentID: parent, Value: fmt.Sprint(i)})
+	}
+	total := func() int {
+		n := 0
+		for _, name := range mgr.Names() {
+			n += mgr.Queue(name).Len()
+		}
+		return n
+	}
+
+	// A shard refusing its items fails the reshard without losing any
+	mgr.Queue(ShardName("jobs", 2)).Freeze(FreezeReject)
+	_, err := mgr.Reshard("jobs", 4)
+	assertEqual(t, errors.Is(err, ErrFrozen), true)
+	assertEqual(t, mgr.Shards("jobs"), 1)
+	assertEqual(t, total(), 40)
+
+	mgr.Queue(ShardName("jobs", 2)).Thaw()
+	_, err = mgr.Reshard("jobs", 4)
+	assertEqual(t, err, nil)
+	assertEqual(t, total(), 40)
+	for _, name := range mgr.Names() {
+		for _, item := range mgr.Queue(name).SortedView() {
+			assertEqual(t, item.Value, "sealed:"+item.ID)
+		}
+	}
+	item, _ := mgr.Queue(ShardName("jobs", 1)).Pop()
+	assertEqual(t, item.Value, item.ID)
+}
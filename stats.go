@@ -0,0 +1,242 @@
+package priorityqueue
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Stats describes the contents of a queue at one point in time
+type Stats struct {
+	Len int
+
+	// ByProducer counts the queued items per Producer label. Items pushed
+	// without a label are counted under the empty string.
+	ByProducer map[string]int
+
+	// Rejected counts, per Producer label, the pushes refused since the
+	// queue was created because of producer limits.
+	Rejected map[string]Rejections
+
+	// Deduplicated counts the items suppressed since the queue was created
+	// because an item with the same IdempotencyKey was acked, see
+	// SetDedupeWindow.
+	Deduplicated int
+
+	// Priorities counts the queued items by priority, see
+	// SetPriorityBuckets.
+	Priorities PriorityHistogram
+
+	// ByLevel counts the queued items by priority level, see
+	// SetPriorityLevels. Items below every level are counted under the
+	// empty string.
+	ByLevel map[string]int
+
+	// Slab counts the item storage, see WithSlabAllocator
+	Slab SlabStats
+
+	// Clamped counts the priorities lowered to the ceiling of their parent
+	// since the queue was created, see SetParentCeiling.
+	Clamped int
+
+	// Merged counts the pushes merged into a queued item with the same
+	// content hash since the queue was created, see SetContentHash.
+	Merged int
+
+	// Coalesced counts the pushes merged into a held item of the same ID
+	// since the queue was created, see SetCoalesceDelay.
+	Coalesced int
+
+	// ArchiveFailures counts the removed items the Archiver failed to
+	// store, see SetArchiver.
+	ArchiveFailures int
+
+	// PriorityChangesDeferred counts the priority changes deferred by the
+	// change limit, see SetPriorityChangeLimit.
+	PriorityChangesDeferred int
+
+	// Diverted counts the pushes below the priority floor of a draining
+	// queue sent to another queue, see StartDraining.
+	Diverted int
+}
+
+// A ParentCount is the number of queued items sharing a ParentID
+type ParentCount struct {
+	ParentID string
+	Count    int
+}
+
+// A RoutingHint summarizes the load of a queue for producers choosing
+// where to push, cheap to compute and to send over the network
+type RoutingHint struct {
+	Depth    int `json:"depth"`     // Queued items
+	InFlight int `json:"in_flight"` // Leased items not acked yet
+
+	// HeadPriority is the priority of the item Pop would return, when Head
+	// says there is one: a queue whose head outranks an item will make the
+	// item wait for at least that head.
+	HeadPriority int  `json:"head_priority"`
+	Head         bool `json:"head"`
+}
+
+// RoutingHint returns the current load of the queue
+func (pq *PriorityQueue) RoutingHint() RoutingHint {
+	defer pq.lock(OpStats)()
+	h := RoutingHint{Depth: pq.size(), InFlight: len(pq.leases)}
+	if n := pq.next(); n >= 0 {
+		h.HeadPriority, h.Head = pq.data[n].Priority, true
+	}
+	return h
+}
+
+// TopParents returns the n ParentIDs with the most queued items, largest first
+func (pq *PriorityQueue) TopParents(n int) []ParentCount {
+	unlock := pq.lock(OpStats)
+	counts := make([]ParentCount, 0, len(pq.byParent))
+	for parentID, s := range pq.byParent {
+		counts = append(counts, ParentCount{ParentID: parentID, Count: len(s)})
+	}
+	unlock()
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].ParentID < counts[j].ParentID
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+// A DepthSample records the queue length at one point in time
+type DepthSample struct {
+	Time time.Time
+	Len  int
+}
+
+// depthHistory keeps the most recent depth samples in a ring
+type depthHistory struct {
+	interval time.Duration
+	samples  []DepthSample
+	next     int
+	full     bool
+}
+
+func (d *depthHistory) sample(now time.Time, n int) {
+	last := d.next - 1
+	if last < 0 {
+		last = len(d.samples) - 1
+	}
+	if (d.full || d.next > 0) && now.Sub(d.samples[last].Time) < d.interval {
+		return
+	}
+	d.samples[d.next] = DepthSample{Time: now, Len: n}
+	d.next++
+	if d.next == len(d.samples) {
+		d.next = 0
+		d.full = true
+	}
+}
+
+func (d *depthHistory) history() []DepthSample {
+	if !d.full {
+		return append([]DepthSample(nil), d.samples[:d.next]...)
+	}
+	return append(append([]DepthSample(nil), d.samples[d.next:]...), d.samples[:d.next]...)
+}
+
+// RecordDepth starts sampling the queue length, at most once per interval
+// whenever an operation completes, keeping the most recent samples. Passing
+// a zero count stops sampling and discards the history.
+func (pq *PriorityQueue) RecordDepth(interval time.Duration, samples int) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	if samples <= 0 {
+		pq.depth = nil
+		return
+	}
+	pq.depth = &depthHistory{interval: interval, samples: make([]DepthSample, samples)}
+}
+
+// DepthHistory returns the samples recorded since RecordDepth, oldest first
+func (pq *PriorityQueue) DepthHistory() []DepthSample {
+	defer pq.lock(OpStats)()
+	if pq.depth == nil {
+		return nil
+	}
+	return pq.depth.history()
+}
+
+// Stats returns the current queue statistics
+func (pq *PriorityQueue) Stats() Stats {
+	defer pq.lock(OpStats)()
+	s := Stats{
+		Len:        pq.size(),
+		ByProducer: make(map[string]int),
+		Rejected:   make(map[string]Rejections),
+
+		Deduplicated:    pq.deduplicated,
+		Clamped:         pq.clamped,
+		Merged:          pq.merged,
+		Coalesced:       pq.coalesced,
+		ArchiveFailures: pq.archiveFailures,
+
+		PriorityChangesDeferred: pq.changesDeferred,
+		Diverted:                pq.diverted,
+	}
+	if pq.priorities != nil {
+		s.Priorities = pq.priorities.copy()
+	}
+	if pq.slab != nil {
+		s.Slab = pq.slab.snapshot()
+	}
+	for producer, n := range pq.byProducer {
+		s.ByProducer[producer] = n
+	}
+	for producer, r := range pq.rejected {
+		s.Rejected[producer] = *r
+	}
+	if len(pq.levels) > 0 {
+		s.ByLevel = make(map[string]int)
+		for priority, n := range pq.byPriority {
+			s.ByLevel[pq.levelOf(priority)] += n
+		}
+	}
+	return s
+}
+
+// SubscribeStats sends a snapshot of the queue statistics every interval,
+// from a goroutine owned by the queue, to feed them to a telemetry system.
+// A subscriber that falls behind receives the latest snapshot and misses
+// the ones before it. The channel is closed once the queue is stopped or
+// destroyed, or right away if the queue is not Running or interval is not
+// positive.
+func (pq *PriorityQueue) SubscribeStats(interval time.Duration) <-chan Stats {
+	ch := make(chan Stats, 1)
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	if pq.stopped || interval <= 0 {
+		close(ch)
+		return ch
+	}
+	pq.spawn(pq.background(), func(ctx context.Context) error {
+		defer close(ch)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return nil
+			}
+			s := pq.Stats()
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- s
+		}
+	})
+	return ch
+}
@@ -0,0 +1,68 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_StatsByProducer(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	pq.Push(QItem{ID: "a", Producer: "ingest"})
+	pq.Push(QItem{ID: "b", Producer: "ingest"})
+
+	s := pq.Stats()
+	assertEqual(t, s.Len, 5)
+	assertEqual(t, s.ByProducer["ingest"], 2)
+	assertEqual(t, s.ByProducer[""], 3)
+}
+
+func Test_PushRecordsPushTime(t *testing.T) {
+	pq := NewPriorityQueue()
+	before := time.Now()
+	pq.Push(QItem{ID: "a", Priority: 2})
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	pq.Push(QItem{ID: "b", Priority: 1, PushedAt: at})
+
+	x, _ := pq.Pop()
+	if x.PushedAt.Before(before) {
+		t.Errorf("PushedAt %v was not set by Push", x.PushedAt)
+	}
+	x, _ = pq.Pop()
+	assertEqual(t, x.PushedAt, at)
+}
+
+func Test_RoutingHint(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, pq.RoutingHint(), RoutingHint{})
+
+	populateQueue(pq, 5)
+	pq.Lease(time.Minute)
+	pq.PauseParent("12345")
+	pq.Push(QItem{ID: "other", ParentID: "web", Priority: 2})
+	assertEqual(t, pq.RoutingHint(), RoutingHint{Depth: 5, InFlight: 1, HeadPriority: 2, Head: true})
+}
+
+func Test_SubscribeStats(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	ch := pq.SubscribeStats(time.Millisecond)
+
+	s := <-ch
+	assertEqual(t, s.Len, 3)
+	pq.Pop()
+	time.Sleep(5 * time.Millisecond)
+	s = <-ch
+	assertEqual(t, s.Len, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pq.Stop(ctx)
+	for range ch {
+	}
+
+	// A stopped queue sends nothing
+	_, ok := <-pq.SubscribeStats(time.Millisecond)
+	assertEqual(t, ok, false)
+}
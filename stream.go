@@ -0,0 +1,111 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A Codec encodes single items onto a stream
+type Codec interface {
+	Encode(w io.Writer, item *QItem) error
+}
+
+// NDJSON encodes each item as one line of JSON, in the format read by
+// ImportNDJSON and ItemWriter.
+var NDJSON Codec = ndjsonCodec{}
+
+type ndjsonCodec struct{}
+
+func (ndjsonCodec) Encode(w io.Writer, item *QItem) error {
+	return json.NewEncoder(w).Encode(toItemRecord(item))
+}
+
+// An ItemWriter is an io.WriteCloser that decodes the NDJSON records written
+// to it and pushes each record as soon as its line is complete, so the queue
+// can be the destination of io.Copy or a pipe.
+type ItemWriter struct {
+	pq      *PriorityQueue
+	buf     []byte
+	records int
+}
+
+// NewItemWriter returns an ItemWriter pushing onto pq
+func NewItemWriter(pq *PriorityQueue) *ItemWriter {
+	return &ItemWriter{pq: pq}
+}
+
+// Write pushes every complete record in p, keeping a trailing partial line
+// until the rest of it is written. On an invalid record the returned count
+// stops at the start of that record's line.
+func (iw *ItemWriter) Write(p []byte) (int, error) {
+	pending := len(iw.buf)
+	iw.buf = append(iw.buf, p...)
+	consumed := 0
+	for {
+		n := bytes.IndexByte(iw.buf[consumed:], '\n')
+		if n == -1 {
+			break
+		}
+		if err := iw.push(iw.buf[consumed : consumed+n]); err != nil {
+			written := consumed - pending
+			if written < 0 {
+				written = 0
+			}
+			iw.buf = nil
+			return written, err
+		}
+		consumed += n + 1
+	}
+	iw.buf = append(iw.buf[:0], iw.buf[consumed:]...)
+	return len(p), nil
+}
+
+// Close pushes a final record that was not terminated by a newline
+func (iw *ItemWriter) Close() error {
+	line := iw.buf
+	iw.buf = nil
+	return iw.push(line)
+}
+
+// Records returns the number of items pushed so far
+func (iw *ItemWriter) Records() int {
+	return iw.records
+}
+
+func (iw *ItemWriter) push(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	var rec itemRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return fmt.Errorf("decoding record %d: %w", iw.records+1, err)
+	}
+	if err := iw.pq.Push(rec.qItem()); err != nil {
+		return err
+	}
+	iw.records++
+	return nil
+}
+
+// StreamTo pops items in priority order and encodes them onto w with codec
+// until the queue is empty. It returns the number of items written; an item
+// that fails to encode is pushed back onto the queue.
+func (pq *PriorityQueue) StreamTo(w io.Writer, codec Codec) (int, error) {
+	written := 0
+	for {
+		item, err := pq.Pop()
+		if err == ErrEmptyQueue {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		if err := codec.Encode(w, item); err != nil {
+			pq.pushAll(OpPush, []QItem{*item})
+			return written, err
+		}
+		written++
+	}
+}
@@ -0,0 +1,68 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_ItemWriter(t *testing.T) {
+	pq := NewPriorityQueue()
+	iw := NewItemWriter(pq)
+
+	// Records split across writes are pushed once their line completes
+	iw.Write([]byte("{\"id\":\"a\",\"priority\":1}\n{\"id\":\"b\","))
+	assertEqual(t, pq.Len(), 1)
+	iw.Write([]byte("\"priority\":2}\n{\"id\":\"c\",\"priority\":3}"))
+	assertEqual(t, pq.Len(), 2)
+	if err := iw.Close(); err != nil {
+		t.Errorf("Error closing writer: %v", err)
+	}
+	assertEqual(t, pq.Len(), 3)
+	assertEqual(t, iw.Records(), 3)
+
+	n, err := iw.Write([]byte("{\"id\":\"d\",\"priority\":1}\nbroken\n"))
+	if err == nil {
+		t.Errorf("Writing an invalid record should fail")
+	}
+	assertEqual(t, n, 24)
+	assertEqual(t, pq.Len(), 4)
+}
+
+func Test_StreamTo(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+
+	var buf bytes.Buffer
+	n, err := pq.StreamTo(&buf, NDJSON)
+	if err != nil {
+		t.Fatalf("Error streaming: %v", err)
+	}
+	assertEqual(t, n, 10)
+	assertEqual(t, pq.Len(), 0)
+
+	// Piping the stream back into a queue restores it
+	restored := NewPriorityQueue()
+	io.Copy(NewItemWriter(restored), &buf)
+	assertEqual(t, restored.Len(), 10)
+	x, _ := restored.Pop()
+	assertEqual(t, x.Priority, 10)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func Test_StreamToEncodeError(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 3)
+	_, err := pq.StreamTo(failingWriter{}, NDJSON)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+	assertEqual(t, pq.Len(), 3)
+}
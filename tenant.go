@@ -0,0 +1,164 @@
+package priorityqueue
+
+import (
+	"context"
+	"fmt"
+)
+
+// A QueueView is a handle on the items of a single tenant of a queue. Items
+// pushed through the view belong to its tenant and every other operation
+// only sees that tenant's items, while the queue's own methods still see
+// everything.
+//
+// Note that Pop and Peek scan the whole queue for the tenant's highest
+// priority item, so they are O(n) rather than O(log n).
+type QueueView struct {
+	pq     *PriorityQueue
+	tenant string
+	ctx    context.Context // Carries the principal, see WithContext
+}
+
+var _ Queue = (*QueueView)(nil)
+
+// Scoped returns a view of the queue restricted to tenant
+func (pq *PriorityQueue) Scoped(tenant string) *QueueView {
+	return &QueueView{pq: pq, tenant: tenant, ctx: context.Background()}
+}
+
+// WithContext returns a copy of the view acting on behalf of the principal
+// carried by ctx, see WithPrincipal, for the Authorizer of the queue
+func (v *QueueView) WithContext(ctx context.Context) *QueueView {
+	c := *v
+	c.ctx = ctx
+	return &c
+}
+
+// Tenant returns the tenant the view is scoped to
+func (v *QueueView) Tenant() string {
+	return v.tenant
+}
+
+func (v *QueueView) owns(item *QItem) bool {
+	return item.Tenant == v.tenant && item.tombstone == ""
+}
+
+// top returns the index of the tenant's highest priority item, or -1. The
+// queue lock must be held.
+func (v *QueueView) top() int {
+	best := -1
+	for n, item := range v.pq.data {
+		if v.owns(item) && (best == -1 || item.Priority > v.pq.data[best].Priority) {
+			best = n
+		}
+	}
+	return best
+}
+
+// Push adds an item to the queue on behalf of the view's tenant, replacing
+// any Tenant already set on the item.
+func (v *QueueView) Push(i QItem) error {
+	pq := v.pq
+	defer pq.lock(OpPush)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	i.Tenant = v.tenant
+	pq.record(recorded{Op: OpPush, Item: recordItem(&i)})
+	if ok, err := pq.admit(v.ctx, &i); !ok {
+		return undiverted(err)
+	}
+	pq.audit(OpPush, pq.insert(i))
+	return nil
+}
+
+func (v *QueueView) Pop() (*QItem, error) {
+	pq := v.pq
+	defer pq.lock(OpPop)()
+	if err := pq.mutable(); err != nil {
+		return nil, err
+	}
+	n := v.top()
+	if n == -1 {
+		return nil, ErrEmptyQueue
+	}
+	if pq.frozen(pq.data[n]) {
+		return nil, ErrFrozen
+	}
+	item := pq.remove(n, StatePopped)
+	pq.record(recorded{Op: OpPop, Tenant: v.tenant})
+	pq.audit(OpPop, item)
+	return pq.untransform(pq.handOut(item))
+}
+
+func (v *QueueView) Peek() (*QItem, error) {
+	pq := v.pq
+	defer pq.lock(OpPeek)()
+	if pq.destroyed {
+		return nil, ErrQueueDestroyed
+	}
+	n := v.top()
+	if n == -1 {
+		return nil, ErrEmptyQueue
+	}
+	item := *pq.data[n]
+	return &item, nil
+}
+
+func (v *QueueView) Len() int {
+	pq := v.pq
+	defer pq.lock(OpLen)()
+	return pq.byTenant[v.tenant]
+}
+
+// Clear removes every item of the view's tenant, or none if the Authorizer
+// denies the removal of any of them
+func (v *QueueView) Clear() {
+	pq := v.pq
+	pq.deleteChunked(OpClear, nil, func() ([]*QItem, error) {
+		items := pq.collect(v.owns)
+		return items, pq.authorize(v.ctx, OpClear, items...)
+	})
+}
+
+func (v *QueueView) UpdatePriorityByParentId(parentID string, priority int) int {
+	pq := v.pq
+	defer pq.lock(OpUpdatePriorityByParentId)()
+	if pq.mutable() != nil {
+		return 0
+	}
+	items := pq.collect(func(item *QItem) bool {
+		return v.owns(item) && pq.sameID(item.ParentID, parentID)
+	})
+	if pq.authorize(v.ctx, OpUpdatePriorityByParentId, items...) != nil {
+		return 0
+	}
+	return pq.updatePriorities(OpUpdatePriorityByParentId, items, priority)
+}
+
+func (v *QueueView) DeleteItemById(id string) error {
+	pq := v.pq
+	defer pq.lock(OpDeleteItemById)()
+	if err := pq.mutable(); err != nil {
+		return err
+	}
+	for _, item := range pq.data {
+		if v.owns(item) && pq.isKey(item, id) {
+			if err := pq.authorize(v.ctx, OpDeleteItemById, item); err != nil {
+				return err
+			}
+			pq.audit(OpDeleteItemById, pq.remove(item.index, StateDeleted))
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: [%s]", ErrNotFound, id)
+}
+
+func (v *QueueView) DeleteItemsByParentId(parentID string) (int, error) {
+	pq := v.pq
+	return pq.deleteChunked(OpDeleteItemsByParentId, nil, func() ([]*QItem, error) {
+		items := pq.collect(func(item *QItem) bool {
+			return v.owns(item) && pq.sameID(item.ParentID, parentID)
+		})
+		return items, pq.authorize(v.ctx, OpDeleteItemsByParentId, items...)
+	})
+}
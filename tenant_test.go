@@ -0,0 +1,50 @@
+package priorityqueue
+
+import (
+	"testing"
+)
+
+func Test_ScopedIsolation(t *testing.T) {
+	pq := NewPriorityQueue()
+	a := pq.Scoped("a")
+	b := pq.Scoped("b")
+
+	a.Push(QItem{ID: "a1", ParentID: "p", Priority: 1})
+	a.Push(QItem{ID: "a2", ParentID: "p", Priority: 2})
+	b.Push(QItem{ID: "b1", ParentID: "p", Priority: 9, Tenant: "a"})
+
+	assertEqual(t, a.Len(), 2)
+	assertEqual(t, b.Len(), 1)
+	assertEqual(t, pq.Len(), 3)
+
+	x, _ := a.Peek()
+	assertEqual(t, x.ID, "a2")
+	x, _ = b.Pop()
+	assertEqual(t, x.ID, "b1")
+	assertEqual(t, x.Tenant, "b")
+	_, err := b.Pop()
+	assertEqual(t, err, ErrEmptyQueue)
+
+	if b.DeleteItemById("a1") == nil {
+		t.Errorf("A view should not delete another tenant's item")
+	}
+	assertEqual(t, b.UpdatePriorityByParentId("p", 50), 0)
+	assertEqual(t, a.UpdatePriorityByParentId("p", 50), 2)
+}
+
+func Test_ScopedDeletes(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 5)
+	a := pq.Scoped("a")
+	a.Push(QItem{ID: "a1", ParentID: "12345", Priority: 1})
+	a.Push(QItem{ID: "a2", ParentID: "12345", Priority: 2})
+	a.Push(QItem{ID: "a3", ParentID: "other", Priority: 3})
+
+	n, _ := a.DeleteItemsByParentId("12345")
+	assertEqual(t, n, 2)
+	assertEqual(t, pq.Len(), 6)
+
+	a.Clear()
+	assertEqual(t, a.Len(), 0)
+	assertEqual(t, pq.Len(), 5)
+}
@@ -0,0 +1,118 @@
+package priorityqueue
+
+import "sort"
+
+// A TieBreak orders the items of equal priority, reporting whether a pops
+// before b. It must order items consistently for as long as they are
+// queued, comparing fields they do not change while queued such as their
+// Seq, Cost or ParentID, since the heap is not reordered when its answer
+// changes; a policy depending on what was popped, such as round-robin
+// between parents, cannot be expressed as a TieBreak, see WithRoundRobin
+// instead. For instance, to pop the smallest payloads first:
+//
+//	WithTieBreak(func(a, b *QItem) bool {
+//		return len(a.Value.([]byte)) < len(b.Value.([]byte))
+//	})
+//
+// Items the TieBreak does not order either way pop in no particular order.
+type TieBreak func(a, b *QItem) bool
+
+// FIFO pops the items of equal priority in the order they were queued
+func FIFO(a, b *QItem) bool {
+	return a.seq < b.seq
+}
+
+// LIFO pops the latest queued of the items of equal priority first
+func LIFO(a, b *QItem) bool {
+	return a.seq > b.seq
+}
+
+// WithTieBreak orders the items of equal priority with tie, in the heap
+// and in the views listing items in pop order such as SortedView. Without
+// it items of equal priority pop in no particular order.
+func WithTieBreak(tie TieBreak) Option {
+	return func(pq *PriorityQueue) error {
+		pq.m.Lock()
+		defer pq.m.Unlock()
+		pq.tieBreak, pq.rounds, pq.servedRound = tie, nil, nil
+		pq.data.init(tie)
+		return nil
+	}
+}
+
+// WithRoundRobin makes the items of equal priority pop taking turns between
+// their parents: each item queued gets the turn after the last one given
+// to its parent, or after the turn of the last item of its priority popped
+// or leased if that is later, and items of equal priority pop by turn, then
+// in the order they were queued. A parent queuing a burst thus waits between
+// its items for the other parents of the same priority, and a parent turning
+// up late waits for no one's backlog. Turns count every item of the parent,
+// whatever its priority. It replaces the TieBreak set by WithTieBreak, and
+// WithTieBreak replaces it.
+func WithRoundRobin() Option {
+	return func(pq *PriorityQueue) error {
+		pq.m.Lock()
+		defer pq.m.Unlock()
+		pq.tieBreak, pq.rounds, pq.servedRound = roundRobin, make(map[string]uint64), make(map[int]uint64)
+		items := append([]*QItem(nil), pq.data...)
+		sort.Slice(items, func(i, j int) bool { return items[i].seq < items[j].seq })
+		for _, item := range items {
+			pq.takeTurn(item)
+		}
+		pq.data.init(pq.tieBreak)
+		return nil
+	}
+}
+
+// roundRobin orders items of equal priority by the turns WithRoundRobin
+// gave them
+func roundRobin(a, b *QItem) bool {
+	if a.round != b.round {
+		return a.round < b.round
+	}
+	return a.seq < b.seq
+}
+
+// takeTurn gives an item being queued the next turn of its parent when
+// round-robin is on. The queue lock must be held.
+func (pq *PriorityQueue) takeTurn(item *QItem) {
+	if pq.rounds == nil {
+		return
+	}
+	key := pq.idKey(item.ParentID)
+	item.round = max(pq.rounds[key], pq.servedRound[item.Priority]) + 1
+	pq.rounds[key] = item.round
+}
+
+// served records the turn of an item leaving the heap for state to. The
+// turns are forgotten once no item needs them: those of a parent or of a
+// priority once it has no item queued. The queue lock must be held.
+func (pq *PriorityQueue) served(item *QItem, to State) {
+	if pq.rounds == nil {
+		return
+	}
+	if (to == StatePopped || to == StateInFlight) && item.round > pq.servedRound[item.Priority] {
+		pq.servedRound[item.Priority] = item.round
+	}
+	if key := pq.idKey(item.ParentID); pq.byParent[key] == nil {
+		delete(pq.rounds, key)
+	}
+	pq.forgetTurns(item.Priority)
+}
+
+// forgetTurns forgets the turn served at priority once no item of that
+// priority is queued. The queue lock must be held.
+func (pq *PriorityQueue) forgetTurns(priority int) {
+	if pq.servedRound != nil && pq.byPriority[priority] == 0 {
+		delete(pq.servedRound, priority)
+	}
+}
+
+// outranks reports whether a pops before b, breaking ties of priority with
+// tie unless it is nil
+func outranks(a, b *QItem, tie TieBreak) bool {
+	if a.Priority != b.Priority || tie == nil {
+		return a.Priority > b.Priority
+	}
+	return tie(a, b)
+}
@@ -0,0 +1,109 @@
+package priorityqueue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func popIDs(pq *PriorityQueue) []string {
+	var ids []string
+	for pq.Len() > 0 {
+		item, _ := pq.Pop()
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func Test_TieBreakFIFO(t *testing.T) {
+	pq := NewPriorityQueue(WithTieBreak(FIFO))
+	for _, id := range []string{"a", "b", "c", "d", "e", "f"} {
+		pq.Push(QItem{ID: id, Priority: 1})
+	}
+	pq.Push(QItem{ID: "urgent", Priority: 2})
+	assertEqual(t, pq.SortedView()[1].ID, "a")
+	if ids := popIDs(pq); !reflect.DeepEqual(ids, []string{"urgent", "a", "b", "c", "d", "e", "f"}) {
+		t.Errorf("Error popping equal priorities in FIFO order: %v", ids)
+	}
+
+	pq = NewPriorityQueue(WithTieBreak(LIFO))
+	populateQueue(pq, 3)
+	pq.UpdatePriorityByParentId("12345", 1)
+	if ids := popIDs(pq); !reflect.DeepEqual(ids, []string{"2", "1", "0"}) {
+		t.Errorf("Error popping equal priorities in LIFO order: %v", ids)
+	}
+}
+
+func Test_TieBreakCustom(t *testing.T) {
+	smallest := func(a, b *QItem) bool { return len(a.Value.(string)) < len(b.Value.(string)) }
+	pq := NewPriorityQueue(WithTieBreak(smallest))
+	for id, v := range map[string]string{"long": "xxxxxxxx", "short": "x", "medium": "xxxx"} {
+		pq.Push(QItem{ID: id, Value: v, Priority: 3})
+	}
+	if ids := popIDs(pq); !reflect.DeepEqual(ids, []string{"short", "medium", "long"}) {
+		t.Errorf("Error popping the smallest payloads first: %v", ids)
+	}
+	assertEqual(t, pq.Healthy(), nil)
+}
+
+func Test_TieBreakRoundRobin(t *testing.T) {
+	pq := NewPriorityQueue(WithRoundRobin())
+	for _, id := range []string{"a1", "a2", "a3"} {
+		pq.Push(QItem{ID: id, ParentID: "a", Priority: 1})
+	}
+	pq.Push(QItem{ID: "b1", ParentID: "b", Priority: 1})
+	pq.Push(QItem{ID: "c1", ParentID: "c", Priority: 1})
+	pq.Push(QItem{ID: "urgent", ParentID: "a", Priority: 2})
+	for _, id := range []string{"urgent", "a1", "b1"} {
+		item, _ := pq.Pop()
+		assertEqual(t, item.ID, id)
+	}
+
+	// A parent turning up late takes its turn after the last one served
+	pq.Push(QItem{ID: "d1", ParentID: "d", Priority: 1})
+	if ids := popIDs(pq); !reflect.DeepEqual(ids, []string{"c1", "a2", "d1", "a3"}) {
+		t.Errorf("Error popping equal priorities in turns: %v", ids)
+	}
+	assertEqual(t, len(pq.rounds), 0)
+	assertEqual(t, len(pq.servedRound), 0)
+}
+
+func Test_TieBreakRoundRobinDeleted(t *testing.T) {
+	pq := NewPriorityQueue(WithRoundRobin())
+	for _, id := range []string{"x1", "x2", "x3"} {
+		pq.Push(QItem{ID: id, ParentID: "x", Priority: 1})
+	}
+	pq.DeleteWhere(func(item QItem) bool { return item.ParentID == "x" })
+	assertEqual(t, len(pq.rounds), 0)
+
+	// The deleted turns are not held against the parent
+	pq.Push(QItem{ID: "x4", ParentID: "x", Priority: 1})
+	pq.Push(QItem{ID: "b1", ParentID: "b", Priority: 1})
+	pq.Push(QItem{ID: "b2", ParentID: "b", Priority: 1})
+	if ids := popIDs(pq); !reflect.DeepEqual(ids, []string{"x4", "b1", "b2"}) {
+		t.Errorf("Error popping equal priorities in turns: %v", ids)
+	}
+}
+
+func Test_TieBreakRoundRobinBulkUpdate(t *testing.T) {
+	heads := make(chan QItem, 10)
+	pq := NewPriorityQueue(WithRoundRobin(), WithHeadNotify(heads))
+	for _, id := range []string{"a", "b", "c", "d"} {
+		pq.Push(QItem{ID: id, ParentID: id, Priority: 1})
+	}
+	pq.Pop()
+	for len(heads) > 0 {
+		<-heads
+	}
+
+	// Updating most of the queue rebuilds the heap
+	pq.UpdatePriorityByIds([]string{"c", "d"}, 3)
+	select {
+	case head := <-heads:
+		assertEqual(t, head.ID, "c")
+	default:
+		t.Errorf("Expected the new head to be notified")
+	}
+	pq.UpdatePriorityByIds([]string{"b", "c"}, 3)
+	assertEqual(t, len(pq.servedRound), 0)
+	assertEqual(t, pq.byPriority[3], 3)
+}
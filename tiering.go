@@ -0,0 +1,195 @@
+package priorityqueue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotScannable is returned by Rebalance when a tier of the Overflow
+// cannot list its items
+var ErrNotScannable = errors.New("queue tier cannot list its items")
+
+// A TierPolicy moves items between the tiers of an Overflow on Rebalance,
+// besides the promotions as space frees. Zero fields disable their rule.
+type TierPolicy struct {
+	// PromoteAbove promotes the cold items with a priority above it, even
+	// when the hot queue is full
+	PromoteAbove *int
+
+	// PromoteAfter promotes the items that spent that long in the cold
+	// queue, even when the hot queue is full
+	PromoteAfter time.Duration
+
+	// DemoteAfter demotes the items that spent that long in the hot queue
+	// without being popped, unless PromoteAbove would promote them back
+	DemoteAfter time.Duration
+
+	// Clock tells the time, time.Now if nil
+	Clock func() time.Time
+}
+
+// TierResidency describes the items of one tier of an Overflow
+type TierResidency struct {
+	Items int           // Items in the tier
+	Left  int           // Items that left the tier, popped, deleted or moved
+	Mean  time.Duration // Mean time the items that left spent in the tier
+	total time.Duration
+}
+
+// residence is the tier an item of an Overflow is in, and since when
+type residence struct {
+	cold  bool
+	since time.Time
+}
+
+// scannable is a Queue that can list its items, as Rebalance needs
+type scannable interface {
+	Queue
+	ForEach(fn func(item QItem) bool) error
+}
+
+// SetPolicy sets the policy applied by Rebalance
+func (o *Overflow) SetPolicy(p TierPolicy) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.policy = p
+}
+
+func (o *Overflow) now() time.Time {
+	if o.policy.Clock != nil {
+		return o.policy.Clock()
+	}
+	return time.Now()
+}
+
+// enter records that the item id entered a tier. The overflow lock must be
+// held.
+func (o *Overflow) enter(id string, cold bool) {
+	o.leave(id)
+	if o.residence == nil {
+		o.residence = make(map[string]residence)
+	}
+	o.residence[id] = residence{cold: cold, since: o.now()}
+}
+
+// leave records that the item id left its tier. The overflow lock must be
+// held.
+func (o *Overflow) leave(id string) {
+	r, ok := o.residence[id]
+	if !ok {
+		return
+	}
+	delete(o.residence, id)
+	t := &o.hotResidency
+	if r.cold {
+		t = &o.coldResidency
+	}
+	t.Left++
+	t.total += o.now().Sub(r.since)
+}
+
+// Rebalance applies the policy set by SetPolicy once, and returns the
+// number of items promoted and demoted. Both queues must be able to list
+// their items, as PriorityQueue and SortedQueue can, or it fails with
+// ErrNotScannable.
+func (o *Overflow) Rebalance() (promoted, demoted int, err error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	hot, ok := o.hot.(scannable)
+	cold, ok2 := o.cold.(scannable)
+	if !ok || !ok2 {
+		return 0, 0, ErrNotScannable
+	}
+	p := o.policy
+	now := o.now()
+	since := func(id string, inCold bool) time.Duration {
+		r, ok := o.residence[id]
+		if !ok || r.cold != inCold {
+			// Queued before the overflow saw it: start the clock now
+			o.enter(id, inCold)
+			return 0
+		}
+		return now.Sub(r.since)
+	}
+	promotable := func(item QItem) bool {
+		return p.PromoteAbove != nil && item.Priority > *p.PromoteAbove
+	}
+
+	var up, down []QItem
+	seen := make(map[string]bool, len(o.residence))
+	cold.ForEach(func(item QItem) bool {
+		seen[item.ID] = true
+		if promotable(item) || p.PromoteAfter > 0 && since(item.ID, true) >= p.PromoteAfter {
+			up = append(up, item)
+		}
+		return true
+	})
+	hot.ForEach(func(item QItem) bool {
+		seen[item.ID] = true
+		if p.DemoteAfter > 0 && !promotable(item) && since(item.ID, false) >= p.DemoteAfter {
+			down = append(down, item)
+		}
+		return true
+	})
+	// Forget the items deleted by ParentID
+	for id := range o.residence {
+		if !seen[id] {
+			o.leave(id)
+		}
+	}
+	promoted = o.move(up, o.cold, o.hot, false)
+	demoted = o.move(down, o.hot, o.cold, true)
+	o.stats.Promoted += promoted
+	o.stats.Demoted += demoted
+	return promoted, demoted, nil
+}
+
+// move moves items from one queue to the other, and returns the number
+// moved. The overflow lock must be held.
+func (o *Overflow) move(items []QItem, from, to Queue, cold bool) int {
+	n := 0
+	for _, item := range items {
+		if from.DeleteItemById(item.ID) != nil {
+			continue
+		}
+		if to.Push(item) != nil {
+			// Keep the item rather than lose it
+			from.Push(item)
+			continue
+		}
+		o.enter(item.ID, cold)
+		n++
+	}
+	return n
+}
+
+// Run calls Rebalance every interval until ctx is done
+func (o *Overflow) Run(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if _, _, err := o.Rebalance(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Residency describes the items of the hot and cold queues
+func (o *Overflow) Residency() (hot, cold TierResidency) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	hot, cold = o.hotResidency, o.coldResidency
+	hot.Items, cold.Items = o.hot.Len(), o.cold.Len()
+	for _, t := range []*TierResidency{&hot, &cold} {
+		if t.Left > 0 {
+			t.Mean = t.total / time.Duration(t.Left)
+		}
+	}
+	return hot, cold
+}
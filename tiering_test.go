@@ -0,0 +1,54 @@
+package priorityqueue
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_TierPolicy(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hot, cold := NewPriorityQueue(), NewSortedQueue()
+	o := NewOverflow(hot, cold, 2)
+	urgent := 50
+	o.SetPolicy(TierPolicy{
+		PromoteAbove: &urgent,
+		PromoteAfter: time.Hour,
+		DemoteAfter:  10 * time.Minute,
+		Clock:        func() time.Time { return now },
+	})
+	for n := 1; n <= 4; n++ {
+		o.Push(QItem{ID: strconv.Itoa(n), Priority: n})
+	}
+	o.Push(QItem{ID: "urgent", Priority: 100})
+
+	up, down, err := o.Rebalance()
+	assertEqual(t, err, nil)
+	assertEqual(t, up, 1)
+	assertEqual(t, down, 0)
+	assertEqual(t, hot.Len(), 3)
+
+	now = now.Add(15 * time.Minute)
+	up, down, _ = o.Rebalance()
+	assertEqual(t, up, 0)
+	assertEqual(t, down, 2) // 1 and 2; urgent stays
+	assertEqual(t, cold.Len(), 4)
+
+	now = now.Add(50 * time.Minute)
+	up, _, _ = o.Rebalance()
+	assertEqual(t, up, 2) // 3 and 4 spent an hour in the cold queue
+	assertEqual(t, o.Stats(), OverflowStats{Overflowed: 3, Promoted: 3, Demoted: 2})
+
+	h, c := o.Residency()
+	assertEqual(t, h.Items, 3)
+	assertEqual(t, c.Items, 2)
+	assertEqual(t, h.Left, 2)
+	assertEqual(t, h.Mean, 15*time.Minute)
+	assertEqual(t, c.Left, 3)
+}
+
+func Test_TierPolicyNotScannable(t *testing.T) {
+	o := NewOverflow(NewPriorityQueue(), NewShadow(NewPriorityQueue(), NewPriorityQueue()), 1)
+	_, _, err := o.Rebalance()
+	assertEqual(t, err, ErrNotScannable)
+}
@@ -0,0 +1,108 @@
+package priorityqueue
+
+import "context"
+
+// Bulk deletes mark the items they delete as tombstones under one short lock
+// and remove them from the heap in chunks, releasing the lock in between, so
+// purging half a million items does not stall Pop for seconds. A tombstoned
+// item no longer counts as queued: it is out of the bookkeeping behind Len,
+// Stats and the priority queries, and every lookup skips it. Pop and Peek
+// remove tombstones reaching the head of the heap as they meet them.
+
+// DeleteWhere deletes every queued item for which pred returns true. pred
+// is called with the lock held and must not use the queue. Like the other
+// bulk deletes it returns once every matching item is removed, releasing the
+// lock between chunks of WithChunkSize items. DeleteWhere is not recorded by
+// Record, as its predicate cannot be replayed.
+func (pq *PriorityQueue) DeleteWhere(pred func(item QItem) bool) (int, error) {
+	return pq.deleteChunked(OpDeleteWhere, nil, func() ([]*QItem, error) {
+		items := pq.collect(func(item *QItem) bool { return pred(*item) })
+		return items, pq.authorize(context.Background(), OpDeleteWhere, items...)
+	})
+}
+
+// deleteChunked deletes the items returned by collect, which is called with
+// the lock held once the queue is known to be mutable. The items are
+// tombstoned at once and removed from the heap chunk by chunk, the first
+// chunk under the same lock, so small deletes take the lock only once. The
+// progress is reported to job unless it is nil.
+func (pq *PriorityQueue) deleteChunked(op Operation, job *JobStatus, collect func() ([]*QItem, error)) (int, error) {
+	unlock := pq.lock(op)
+	if err := pq.mutable(); err != nil {
+		unlock()
+		return 0, err
+	}
+	items, err := collect()
+	if err != nil {
+		unlock()
+		return 0, err
+	}
+	for _, item := range items {
+		if op == OpDeleteWhere {
+			pq.recycle(item)
+		}
+		pq.bury(op, item)
+	}
+	if job != nil {
+		job.Total = len(items)
+	}
+	chunk := pq.chunkSize
+	if chunk <= 0 {
+		chunk = DefaultChunkSize
+	}
+
+	for start := 0; start < len(items); start += chunk {
+		end := start + chunk
+		if end > len(items) {
+			end = len(items)
+		}
+		if start > 0 {
+			unlock = pq.lock(op)
+		}
+		for _, item := range items[start:end] {
+			// Pop, Clear or Destroy may have purged it already
+			if item.tombstone != "" && pq.inHeap(item) {
+				pq.purge(item.index)
+			}
+		}
+		if job != nil {
+			job.Deleted = end
+		}
+		unlock()
+	}
+	if len(items) == 0 {
+		unlock()
+	}
+	return len(items), nil
+}
+
+// bury tombstones a queued item on behalf of op. The queue lock must be held.
+func (pq *PriorityQueue) bury(op Operation, item *QItem) {
+	item.tombstone = op
+	pq.tombstones++
+	pq.untrack(item)
+}
+
+// purge removes the tombstone at index from the heap, completing its
+// deletion. The queue lock must be held.
+func (pq *PriorityQueue) purge(index int) {
+	item := pq.data.remove(index, pq.tieBreak)
+	pq.profileFixup(item)
+	op := item.tombstone
+	item.tombstone = ""
+	pq.tombstones--
+	pq.transition(item, StateDeleted)
+	pq.served(item, StateDeleted)
+	pq.audit(op, item)
+	if pq.slab != nil {
+		pq.slab.release(item)
+	}
+}
+
+// purgeHead removes the tombstones at the head of the heap. The queue lock
+// must be held.
+func (pq *PriorityQueue) purgeHead() {
+	for len(pq.data) > 0 && pq.data[0].tombstone != "" {
+		pq.purge(0)
+	}
+}
@@ -0,0 +1,108 @@
+package priorityqueue
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func Test_DeleteWhere(t *testing.T) {
+	pq := NewPriorityQueue(WithChunkSize(3))
+	populateQueue(pq, 10)
+
+	var deleted []string
+	pq.SetAuditLog(func(e AuditEntry) {
+		if e.Op == OpDeleteWhere {
+			deleted = append(deleted, e.ID)
+		}
+	})
+	n, err := pq.DeleteWhere(func(item QItem) bool { return item.Priority%2 == 0 })
+	assertEqual(t, err, nil)
+	assertEqual(t, n, 5)
+	assertEqual(t, len(deleted), 5)
+	assertEqual(t, pq.Len(), 5)
+	assertEqual(t, pq.State("1"), StateDeleted)
+	assertEqual(t, pq.Healthy(), nil)
+
+	for pq.Len() > 0 {
+		item, _ := pq.Pop()
+		if item.Priority%2 == 0 {
+			t.Errorf("popped deleted item %+v", item)
+		}
+	}
+
+	pq.Freeze(FreezeReject)
+	_, err = pq.DeleteWhere(func(QItem) bool { return true })
+	assertEqual(t, err, ErrFrozen)
+}
+
+func Test_Tombstones(t *testing.T) {
+	pq := NewPriorityQueue()
+	populateQueue(pq, 10)
+
+	// Tombstone the head and two more items without purging them, as a bulk
+	// delete does between chunks
+	pq.m.Lock()
+	for _, item := range pq.parentItems("12345") {
+		if item.Priority >= 8 {
+			pq.bury(OpDeleteItemsByParentId, item)
+		}
+	}
+	pq.m.Unlock()
+
+	assertEqual(t, pq.Len(), 7)
+	assertEqual(t, pq.Stats().Len, 7)
+	assertEqual(t, pq.Counts().Queued, 7)
+	assertEqual(t, pq.Healthy(), nil)
+	assertEqual(t, pq.DeleteItemById("9") != nil, true)
+
+	max, _ := pq.MaxPriority()
+	assertEqual(t, max, 7)
+	item, _ := pq.Pop()
+	assertEqual(t, item.ID, "6")
+	assertEqual(t, pq.State("9"), StateDeleted)
+	assertEqual(t, pq.Healthy(), nil)
+
+	pq.Destroy()
+	assertEqual(t, pq.tombstones, 0)
+}
+
+func Test_DeleteChunkedConcurrentPop(t *testing.T) {
+	pq := NewPriorityQueue(WithChunkSize(16))
+	for n := 0; n < 2000; n++ {
+		parent := "keep"
+		if n%2 == 0 {
+			parent = "purge"
+		}
+		pq.Push(QItem{ID: strconv.Itoa(n), ParentID: parent, Priority: n})
+	}
+
+	var wg sync.WaitGroup
+	popped := make(chan *QItem, 2000)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 500; n++ {
+			if item, err := pq.Pop(); err == nil {
+				popped <- item
+			}
+		}
+	}()
+	deleted, err := pq.DeleteItemsByParentId("purge")
+	wg.Wait()
+	close(popped)
+
+	assertEqual(t, err, nil)
+	total, kept := 0, 0
+	for item := range popped {
+		total++
+		if item.ParentID == "keep" {
+			kept++
+		}
+	}
+	// Every item is either deleted, popped or still queued
+	assertEqual(t, deleted+total+pq.Len(), 2000)
+	assertEqual(t, pq.Len(), 1000-kept)
+	assertEqual(t, pq.tombstones, 0)
+	assertEqual(t, pq.Healthy(), nil)
+}
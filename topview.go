@@ -0,0 +1,95 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// A TopView lists the items at the head of the queue, see SetTopView
+type TopView struct {
+	Items []TopItem // Highest priority first
+}
+
+// A TopItem describes an item of a TopView
+type TopItem struct {
+	ID       string
+	ParentID string
+	Priority int
+	PushedAt time.Time
+}
+
+// Age returns how long the item has been waiting at now
+func (t TopItem) Age(now time.Time) time.Duration {
+	return now.Sub(t.PushedAt)
+}
+
+// SetTopView makes the queue maintain a view of its k highest priority
+// items, published on every change so that TopView can be read without
+// taking the queue lock, for dashboards and admission controllers polling
+// what comes next. Maintaining the view costs O(k log k) per operation on
+// the queue. Zero, the default, drops the view.
+func (pq *PriorityQueue) SetTopView(k int) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.topK = k
+	pq.topView.Store(nil)
+	if k > 0 {
+		pq.refreshTop()
+	}
+}
+
+// TopView returns the latest view of the head of the queue, nil unless
+// SetTopView is in effect. It never blocks; the view must not be modified.
+func (pq *PriorityQueue) TopView() *TopView {
+	return pq.topView.Load()
+}
+
+// topCandidates is a max-heap of the heap items whose parents were taken
+// into the view
+type topCandidates []*QItem
+
+func (c topCandidates) Len() int            { return len(c) }
+func (c topCandidates) Less(i, j int) bool  { return c[i].Priority > c[j].Priority }
+func (c topCandidates) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *topCandidates) Push(x interface{}) { *c = append(*c, x.(*QItem)) }
+func (c *topCandidates) Pop() interface{} {
+	old := *c
+	item := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return item
+}
+
+// refreshTop publishes a new view if the head of the queue changed. The
+// queue lock must be held.
+func (pq *PriorityQueue) refreshTop() {
+	top := make([]TopItem, 0, pq.topK)
+	var c topCandidates
+	if len(pq.data) > 0 {
+		c = append(c, pq.data[0])
+	}
+	for len(c) > 0 && len(top) < pq.topK {
+		item := heap.Pop(&c).(*QItem)
+		for child := 2*item.index + 1; child <= 2*item.index+2 && child < len(pq.data); child++ {
+			heap.Push(&c, pq.data[child])
+		}
+		if item.tombstone == "" {
+			top = append(top, TopItem{ID: item.ID, ParentID: item.ParentID, Priority: item.Priority, PushedAt: item.PushedAt})
+		}
+	}
+	if old := pq.topView.Load(); old != nil && sameTop(old.Items, top) {
+		return
+	}
+	pq.topView.Store(&TopView{Items: top})
+}
+
+func sameTop(a, b []TopItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package priorityqueue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_TopView(t *testing.T) {
+	pq := NewPriorityQueue()
+	assertEqual(t, pq.TopView() == nil, true)
+	populateQueue(pq, 10)
+	pq.SetTopView(3)
+
+	ids := func() []string {
+		var ids []string
+		for _, item := range pq.TopView().Items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	assertEqual(t, len(ids()), 3)
+	assertEqual(t, ids()[0], "9")
+	assertEqual(t, ids()[2], "7")
+
+	view := pq.TopView()
+	pq.Len()
+	assertEqual(t, pq.TopView(), view)
+
+	pq.Pop()
+	pq.DeleteItemById("7")
+	pq.Push(QItem{ID: "top", Priority: 100})
+	got := ids()
+	assertEqual(t, got[0], "top")
+	assertEqual(t, got[1], "8")
+	assertEqual(t, got[2], "6")
+
+	item := pq.TopView().Items[0]
+	assertEqual(t, item.Age(item.PushedAt.Add(time.Second)), time.Second)
+
+	pq.SetTopView(0)
+	assertEqual(t, pq.TopView() == nil, true)
+}
+
+func Test_TopViewConcurrentReads(t *testing.T) {
+	pq := NewPriorityQueue()
+	pq.SetTopView(5)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 1000; n++ {
+			pq.Push(QItem{Priority: n})
+		}
+	}()
+	for n := 0; n < 1000; n++ {
+		if view := pq.TopView(); len(view.Items) > 5 {
+			t.Fatalf("View of %d items", len(view.Items))
+		}
+	}
+	wg.Wait()
+	assertEqual(t, pq.TopView().Items[0].Priority, 999)
+}
@@ -0,0 +1,142 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrTransform is wrapped by the errors of a Transformer failing
+var ErrTransform = errors.New("transform failed")
+
+// A Transformer rewrites the Value of items as they enter and leave the
+// queue, so producers stay simple and the stored payloads meet a policy:
+// compressed, with personal data redacted or in a normalized form. Both
+// functions are called with the queue lock held: they must not use the
+// queue.
+type Transformer struct {
+	Name string
+
+	// Push returns the Value to store for an item being pushed
+	Push func(item QItem) (interface{}, error)
+
+	// Pop returns the Value to hand out for an item popped or leased, given
+	// the stored one. It is nil for transformations that cannot be undone,
+	// such as redactions.
+	Pop func(item QItem) (interface{}, error)
+}
+
+// SetTransformers replaces the transformers of the queue. Push runs them in
+// order and fails with an error wrapping ErrTransform if any of them fails.
+// Pop, Lease and Reserve run the Pop function of each in reverse order on
+// the item handed out; if one fails the item is handed out with the Value
+// stored, together with an error wrapping ErrTransform, so it is not lost.
+// Peek, the views and snapshots show the Value stored. Restores, and imports
+// unless SetImportMode is ImportPushed, expect Values already in stored form
+// and do not run the transformers. No transformer, the default, stores
+// Values as they are pushed.
+func (pq *PriorityQueue) SetTransformers(ts ...Transformer) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.transformers = append([]Transformer(nil), ts...)
+}
+
+// WithTransformers is SetTransformers
+func WithTransformers(ts ...Transformer) Option {
+	return func(pq *PriorityQueue) error {
+		for _, t := range ts {
+			if t.Push == nil {
+				return fmt.Errorf("transformer [%s] has no Push function", t.Name)
+			}
+		}
+		pq.SetTransformers(ts...)
+		return nil
+	}
+}
+
+// transform sets the Value of an item being pushed from the transformers.
+// The queue lock must be held.
+func (pq *PriorityQueue) transform(i *QItem) error {
+	for _, t := range pq.transformers {
+		if t.Push == nil {
+			continue
+		}
+		v, err := t.Push(*i)
+		if err != nil {
+			return fmt.Errorf("%w: [%s] by %s: %v", ErrTransform, i.ID, t.Name, err)
+		}
+		i.Value = v
+	}
+	return nil
+}
+
+// untransform returns a copy of item, being handed out, with its Value
+// read back from the blob store and the transformers undone, or item and
+// the error of the step failing. The queue lock must be held.
+func (pq *PriorityQueue) untransform(item *QItem) (*QItem, error) {
+	if len(pq.transformers) == 0 && pq.blobStore == nil {
+		return item, nil
+	}
+	c := *item
+	if err := pq.rehydrate(&c); err != nil {
+		return item, err
+	}
+	for n := len(pq.transformers) - 1; n >= 0; n-- {
+		t := pq.transformers[n]
+		if t.Pop == nil {
+			continue
+		}
+		v, err := t.Pop(c)
+		if err != nil {
+			return item, fmt.Errorf("%w: [%s] by %s: %v", ErrTransform, item.ID, t.Name, err)
+		}
+		c.Value = v
+	}
+	return &c, nil
+}
+
+// Gzip is a Transformer compressing the Values of type []byte or string,
+// which Pop hands out as []byte. Values of other types are stored as they
+// are.
+func Gzip() Transformer {
+	return Transformer{
+		Name: "gzip",
+		Push: func(item QItem) (interface{}, error) {
+			var b []byte
+			switch v := item.Value.(type) {
+			case []byte:
+				b = v
+			case string:
+				b = []byte(v)
+			default:
+				return item.Value, nil
+			}
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			if _, err := w.Write(b); err != nil {
+				return nil, err
+			}
+			if err := w.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+		Pop: func(item QItem) (interface{}, error) {
+			v, ok := item.Value.([]byte)
+			if !ok || !bytes.HasPrefix(v, gzipMagic) {
+				return item.Value, nil
+			}
+			r, err := gzip.NewReader(bytes.NewReader(v))
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(r)
+		},
+	}
+}
+
+// gzipMagic starts the gzip streams, telling the Values Gzip compressed
+// from those queued before it was set
+var gzipMagic = []byte{0x1f, 0x8b}
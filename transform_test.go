@@ -0,0 +1,100 @@
+package priorityqueue
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Transformers(t *testing.T) {
+	redact := Transformer{
+		Name: "redact",
+		Push: func(i QItem) (interface{}, error) {
+			return strings.ReplaceAll(i.Value.(string), "secret", "******"), nil
+		},
+	}
+	upper := Transformer{
+		Name: "upper",
+		Push: func(i QItem) (interface{}, error) { return strings.ToUpper(i.Value.(string)), nil },
+		Pop:  func(i QItem) (interface{}, error) { return strings.ToLower(i.Value.(string)), nil },
+	}
+	pq, err := New(WithTransformers(redact, upper))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pq.Push(QItem{ID: "a", Value: "my secret", Priority: 1})
+	item, _ := pq.Peek()
+	assertEqual(t, item.Value, "MY ******")
+	item, err = pq.Pop()
+	assertEqual(t, err, nil)
+	assertEqual(t, item.Value, "my ******")
+
+	pq.Push(QItem{ID: "b", Value: "leased", Priority: 1})
+	item, r, err := pq.Lease(time.Minute)
+	assertEqual(t, err, nil)
+	assertEqual(t, item.Value, "leased")
+	assertEqual(t, pq.Ack(r), nil)
+
+	_, err = New(WithTransformers(Transformer{Name: "broken"}))
+	if err == nil {
+		t.Error("transformer without Push accepted")
+	}
+}
+
+func Test_TransformerErrors(t *testing.T) {
+	fail := errors.New("boom")
+	check := Transformer{
+		Name: "check",
+		Push: func(i QItem) (interface{}, error) {
+			if i.Value == nil {
+				return nil, fail
+			}
+			return i.Value, nil
+		},
+		Pop: func(i QItem) (interface{}, error) {
+			if i.Value == "bad" {
+				return nil, fail
+			}
+			return i.Value, nil
+		},
+	}
+	pq, _ := New(WithTransformers(check))
+
+	err := pq.Push(QItem{ID: "nil"})
+	if !errors.Is(err, ErrTransform) {
+		t.Errorf("push error %v", err)
+	}
+	assertEqual(t, pq.Len(), 0)
+
+	pq.Push(QItem{ID: "bad", Value: "bad"})
+	item, err := pq.Pop()
+	if !errors.Is(err, ErrTransform) {
+		t.Errorf("pop error %v", err)
+	}
+	// The item is handed out as stored rather than lost
+	assertEqual(t, item.ID, "bad")
+	assertEqual(t, item.Value, "bad")
+}
+
+func Test_Gzip(t *testing.T) {
+	pq, _ := New(WithTransformers(Gzip()))
+	payload := strings.Repeat("compressible ", 100)
+	pq.Push(QItem{ID: "s", Value: payload, Priority: 3})
+	pq.Push(QItem{ID: "b", Value: []byte(payload), Priority: 2})
+	pq.Push(QItem{ID: "n", Value: 42, Priority: 1})
+
+	item, _ := pq.Peek()
+	if stored := item.Value.([]byte); len(stored) >= len(payload) {
+		t.Errorf("stored %d bytes for %d", len(stored), len(payload))
+	}
+	for _, id := range []string{"s", "b"} {
+		item, err := pq.Pop()
+		assertEqual(t, err, nil)
+		assertEqual(t, item.ID, id)
+		assertEqual(t, string(item.Value.([]byte)), payload)
+	}
+	item, _ = pq.Pop()
+	assertEqual(t, item.Value, 42)
+}
@@ -0,0 +1,327 @@
+package priorityqueue
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TwoLevelQueue is a Queue scheduling in two levels: a heap of parents,
+// ordered by the best item of each, over a heap of items per ParentID. Pop
+// takes the best item of the best parent, the item a single heap would pop,
+// in O(log p + log k) for p parents of k items each. Items of equal
+// priority are popped in push order.
+//
+// Operations scoped to a ParentID only touch the heap of that parent and
+// its place among the parents: DeleteItemsByParentId and
+// UpdatePriorityByParentId are O(k + log p) however many items the queue
+// holds, and a parent is paused or resumed in O(log p). Only the Queue
+// methods and the parent policies below are supported.
+type TwoLevelQueue struct {
+	m       sync.Mutex
+	parents twoLevelHeap[*twoLevelParent] // The parents with items, unless paused
+	byID    map[string][]*twoLevelItem
+	by      map[string]*twoLevelParent // Parents with items, by ParentID
+	paused  map[string]bool
+	size    int
+	nextSeq uint64
+}
+
+type twoLevelItem struct {
+	item  QItem
+	seq   uint64
+	index int // Index in the heap of its parent
+}
+
+type twoLevelParent struct {
+	id    string
+	items twoLevelHeap[*twoLevelItem]
+	index int // Index in the heap of parents, -1 when out of it
+}
+
+var _ Queue = (*TwoLevelQueue)(nil)
+
+func NewTwoLevelQueue() *TwoLevelQueue {
+	tq := &TwoLevelQueue{}
+	tq.reset()
+	return tq
+}
+
+func (tq *TwoLevelQueue) reset() {
+	tq.parents = twoLevelHeap[*twoLevelParent]{
+		less: func(a, b *twoLevelParent) bool { return a.items.s[0].before(b.items.s[0]) },
+		set:  func(p *twoLevelParent, i int) { p.index = i },
+	}
+	tq.byID = make(map[string][]*twoLevelItem)
+	tq.by = make(map[string]*twoLevelParent)
+	tq.size = 0
+}
+
+// before reports whether e pops before o
+func (e *twoLevelItem) before(o *twoLevelItem) bool {
+	if e.item.Priority != o.item.Priority {
+		return e.item.Priority > o.item.Priority
+	}
+	return e.seq < o.seq
+}
+
+// parent returns the parent called id, creating it if needed
+func (tq *TwoLevelQueue) parent(id string) *twoLevelParent {
+	p, ok := tq.by[id]
+	if !ok {
+		p = &twoLevelParent{id: id, index: -1}
+		p.items = twoLevelHeap[*twoLevelItem]{
+			less: (*twoLevelItem).before,
+			set:  func(e *twoLevelItem, i int) { e.index = i },
+		}
+		tq.by[id] = p
+	}
+	return p
+}
+
+// reschedule restores the place of p among the parents after its items
+// changed, dropping it once empty
+func (tq *TwoLevelQueue) reschedule(p *twoLevelParent) {
+	switch {
+	case len(p.items.s) == 0:
+		if p.index >= 0 {
+			tq.parents.remove(p.index)
+		}
+		delete(tq.by, p.id)
+	case tq.paused[p.id]:
+	case p.index >= 0:
+		tq.parents.fix(p.index)
+	default:
+		tq.parents.push(p)
+	}
+}
+
+// unindex forgets e in the index by ID
+func (tq *TwoLevelQueue) unindex(e *twoLevelItem) {
+	es := tq.byID[e.item.ID]
+	for n := range es {
+		if es[n] == e {
+			es = append(es[:n], es[n+1:]...)
+			break
+		}
+	}
+	if len(es) == 0 {
+		delete(tq.byID, e.item.ID)
+	} else {
+		tq.byID[e.item.ID] = es
+	}
+}
+
+func (tq *TwoLevelQueue) Push(i QItem) error {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	stamp(&i)
+	i.index = -1
+	tq.nextSeq++
+	e := &twoLevelItem{item: i, seq: tq.nextSeq}
+	p := tq.parent(i.ParentID)
+	p.items.push(e)
+	tq.byID[i.ID] = append(tq.byID[i.ID], e)
+	tq.size++
+	tq.reschedule(p)
+	return nil
+}
+
+func (tq *TwoLevelQueue) Pop() (*QItem, error) {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	if len(tq.parents.s) == 0 {
+		return nil, ErrEmptyQueue
+	}
+	p := tq.parents.s[0]
+	e := p.items.remove(0)
+	tq.unindex(e)
+	tq.size--
+	tq.reschedule(p)
+	return &e.item, nil
+}
+
+func (tq *TwoLevelQueue) Peek() (*QItem, error) {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	if len(tq.parents.s) == 0 {
+		return nil, ErrEmptyQueue
+	}
+	item := tq.parents.s[0].items.s[0].item
+	return &item, nil
+}
+
+// Len returns the number of items queued, those of paused parents included
+func (tq *TwoLevelQueue) Len() int {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	return tq.size
+}
+
+func (tq *TwoLevelQueue) Clear() {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	tq.reset()
+}
+
+// UpdatePriorityByParentId sets the priority of every item with a matching
+// ParentID, keeping them in push order among themselves.
+func (tq *TwoLevelQueue) UpdatePriorityByParentId(parentID string, priority int) int {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	p, ok := tq.by[parentID]
+	if !ok {
+		return 0
+	}
+	for _, e := range p.items.s {
+		e.item.Priority = priority
+	}
+	p.items.init()
+	tq.reschedule(p)
+	return len(p.items.s)
+}
+
+// DeleteItemById deletes the earliest pushed item with the given ID
+func (tq *TwoLevelQueue) DeleteItemById(id string) error {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	es := tq.byID[id]
+	if len(es) == 0 {
+		return fmt.Errorf("%w: [%s]", ErrNotFound, id)
+	}
+	e := es[0]
+	p := tq.by[e.item.ParentID]
+	p.items.remove(e.index)
+	tq.unindex(e)
+	tq.size--
+	tq.reschedule(p)
+	return nil
+}
+
+func (tq *TwoLevelQueue) DeleteItemsByParentId(parentID string) (int, error) {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	p, ok := tq.by[parentID]
+	if !ok {
+		return 0, nil
+	}
+	deleted := len(p.items.s)
+	for _, e := range p.items.s {
+		tq.unindex(e)
+	}
+	p.items.s = nil
+	tq.size -= deleted
+	tq.reschedule(p)
+	return deleted, nil
+}
+
+// PauseParent keeps Pop and Peek from returning the items of parentID, the
+// ones queued and the ones pushed later, until ResumeParent
+func (tq *TwoLevelQueue) PauseParent(parentID string) {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	if tq.paused == nil {
+		tq.paused = make(map[string]bool)
+	}
+	tq.paused[parentID] = true
+	if p, ok := tq.by[parentID]; ok && p.index >= 0 {
+		tq.parents.remove(p.index)
+	}
+}
+
+// ResumeParent undoes PauseParent
+func (tq *TwoLevelQueue) ResumeParent(parentID string) {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	delete(tq.paused, parentID)
+	if p, ok := tq.by[parentID]; ok {
+		tq.reschedule(p)
+	}
+}
+
+// ParentLen returns the number of items queued for parentID
+func (tq *TwoLevelQueue) ParentLen(parentID string) int {
+	tq.m.Lock()
+	defer tq.m.Unlock()
+	if p, ok := tq.by[parentID]; ok {
+		return len(p.items.s)
+	}
+	return 0
+}
+
+// A twoLevelHeap is a binary heap ordered by less, telling each element
+// its index through set
+type twoLevelHeap[T any] struct {
+	s    []T
+	less func(a, b T) bool
+	set  func(x T, i int)
+}
+
+func (h *twoLevelHeap[T]) swap(i, j int) {
+	h.s[i], h.s[j] = h.s[j], h.s[i]
+	h.set(h.s[i], i)
+	h.set(h.s[j], j)
+}
+
+func (h *twoLevelHeap[T]) up(j int) {
+	for j > 0 {
+		i := (j - 1) / 2
+		if !h.less(h.s[j], h.s[i]) {
+			break
+		}
+		h.swap(i, j)
+		j = i
+	}
+}
+
+func (h *twoLevelHeap[T]) down(i0 int) bool {
+	i, n := i0, len(h.s)
+	for {
+		j := 2*i + 1
+		if j >= n {
+			break
+		}
+		if j2 := j + 1; j2 < n && h.less(h.s[j2], h.s[j]) {
+			j = j2
+		}
+		if !h.less(h.s[j], h.s[i]) {
+			break
+		}
+		h.swap(i, j)
+		i = j
+	}
+	return i > i0
+}
+
+func (h *twoLevelHeap[T]) init() {
+	for i := len(h.s)/2 - 1; i >= 0; i-- {
+		h.down(i)
+	}
+}
+
+func (h *twoLevelHeap[T]) fix(i int) {
+	if !h.down(i) {
+		h.up(i)
+	}
+}
+
+func (h *twoLevelHeap[T]) push(x T) {
+	h.s = append(h.s, x)
+	h.set(x, len(h.s)-1)
+	h.up(len(h.s) - 1)
+}
+
+func (h *twoLevelHeap[T]) remove(i int) T {
+	n := len(h.s) - 1
+	if i != n {
+		h.swap(i, n)
+	}
+	x := h.s[n]
+	var zero T
+	h.s[n] = zero
+	h.s = h.s[:n]
+	if i != n {
+		h.fix(i)
+	}
+	h.set(x, -1)
+	return x
+}
@@ -0,0 +1,93 @@
+package priorityqueue
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func Test_TwoLevelQueueOrdering(t *testing.T) {
+	tq := NewTwoLevelQueue()
+	tq.Push(QItem{ID: "a", ParentID: "x", Priority: 1})
+	tq.Push(QItem{ID: "b", ParentID: "y", Priority: 5})
+	tq.Push(QItem{ID: "c", ParentID: "x", Priority: 7})
+	tq.Push(QItem{ID: "d", ParentID: "y", Priority: 5})
+	tq.Push(QItem{ID: "e", ParentID: "z", Priority: 1})
+
+	peeked, _ := tq.Peek()
+	assertEqual(t, peeked.ID, "c")
+	assertEqual(t, tq.ParentLen("y"), 2)
+	var ids string
+	for tq.Len() > 0 {
+		item, _ := tq.Pop()
+		ids += item.ID
+	}
+	assertEqual(t, ids, "cbdae")
+	_, err := tq.Pop()
+	assertEqual(t, err, ErrEmptyQueue)
+}
+
+func Test_TwoLevelQueuePause(t *testing.T) {
+	tq := NewTwoLevelQueue()
+	tq.Push(QItem{ID: "a", ParentID: "x", Priority: 9})
+	tq.PauseParent("x")
+	tq.Push(QItem{ID: "b", ParentID: "x", Priority: 10})
+	tq.Push(QItem{ID: "c", ParentID: "y", Priority: 1})
+
+	item, _ := tq.Pop()
+	assertEqual(t, item.ID, "c")
+	_, err := tq.Peek()
+	assertEqual(t, err, ErrEmptyQueue)
+	assertEqual(t, tq.Len(), 2)
+
+	tq.ResumeParent("x")
+	assertEqual(t, tq.UpdatePriorityByParentId("x", 3), 2)
+	item, _ = tq.Pop()
+	assertEqual(t, item.ID, "a")
+	assertEqual(t, item.Priority, 3)
+}
+
+// Test_TwoLevelQueueModel applies random operations to a TwoLevelQueue and
+// to a SortedQueue and compares the popped priorities and the counts.
+func Test_TwoLevelQueueModel(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tq, ref := NewTwoLevelQueue(), NewSortedQueue()
+	pushed := 0
+	for n := 0; n < 5000; n++ {
+		parentID := strconv.Itoa(r.Intn(8))
+		switch r.Intn(10) {
+		case 0, 1, 2, 3:
+			item := QItem{ID: strconv.Itoa(pushed), ParentID: parentID, Priority: r.Intn(32)}
+			pushed++
+			tq.Push(item)
+			ref.Push(item)
+		case 4, 5:
+			x, err := tq.Pop()
+			y, rerr := ref.Pop()
+			assertEqual(t, err, rerr)
+			if err == nil {
+				assertEqual(t, x.Priority, y.Priority)
+				if x.ID != y.ID {
+					ref.Push(*y)
+					ref.DeleteItemById(x.ID)
+				}
+			}
+		case 6:
+			p := r.Intn(32)
+			assertEqual(t, tq.UpdatePriorityByParentId(parentID, p), ref.UpdatePriorityByParentId(parentID, p))
+		case 7:
+			a, _ := tq.DeleteItemsByParentId(parentID)
+			b, _ := ref.DeleteItemsByParentId(parentID)
+			assertEqual(t, a, b)
+		case 8:
+			id := strconv.Itoa(r.Intn(pushed + 1))
+			assertEqual(t, tq.DeleteItemById(id) == nil, ref.DeleteItemById(id) == nil)
+		case 9:
+			if r.Intn(20) == 0 {
+				tq.Clear()
+				ref.Clear()
+			}
+		}
+		assertEqual(t, tq.Len(), ref.Len())
+	}
+}
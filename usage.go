@@ -0,0 +1,134 @@
+package priorityqueue
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Usage meters the use of a queue by one ParentID, such as a tenant's
+// workflow, for chargeback: the counters add up from Since until they are
+// reset, see ResetUsage.
+type Usage struct {
+	ParentID string `json:"parent_id"`
+
+	// Pushes counts the items pushed, those merged into queued ones
+	// included but not those refused or suppressed as duplicates
+	Pushes int64 `json:"pushes"`
+
+	// Bytes adds up the size of the Values pushed, as given to Push, see
+	// SetValueSizer
+	Bytes int64 `json:"bytes"`
+
+	// Processing adds up the time the items were leased or reserved for,
+	// from the lease until it was acked, nacked, released or expired
+	Processing time.Duration `json:"processing_ns"`
+
+	Since time.Time `json:"since"` // When the counting started
+}
+
+// SetValueSizer sets the function measuring the Values pushed for
+// Usage.Bytes. Nil, the default, measures the Values of type []byte and
+// string by their length and counts the others as empty.
+func (pq *PriorityQueue) SetValueSizer(size func(value interface{}) int) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.valueSizer = size
+}
+
+// valueSize returns the size of v for Usage.Bytes. The queue lock must be
+// held.
+func (pq *PriorityQueue) valueSize(v interface{}) int {
+	if pq.valueSizer != nil {
+		return pq.valueSizer(v)
+	}
+	switch v := v.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	}
+	return 0
+}
+
+// meter returns the usage counters of parentID. The queue lock must be
+// held.
+func (pq *PriorityQueue) meter(parentID string) *Usage {
+	if pq.usage == nil {
+		pq.usage = make(map[string]*Usage)
+	}
+	if pq.usageSince.IsZero() {
+		pq.usageSince = pq.now()
+	}
+	u, ok := pq.usage[parentID]
+	if !ok {
+		u = &Usage{ParentID: parentID, Since: pq.usageSince}
+		pq.usage[parentID] = u
+	}
+	return u
+}
+
+// meterPush counts the push of an item of parentID whose Value is size
+// bytes long. The queue lock must be held.
+func (pq *PriorityQueue) meterPush(parentID string, size int) {
+	u := pq.meter(parentID)
+	u.Pushes++
+	u.Bytes += int64(size)
+}
+
+// meterLease counts the processing time of l, ending at now. The queue lock
+// must be held.
+func (pq *PriorityQueue) meterLease(l *lease, now time.Time) {
+	if d := now.Sub(l.leased); d > 0 {
+		pq.meter(l.item.ParentID).Processing += d
+	}
+}
+
+// Usage returns the usage counters of every ParentID that used the queue,
+// sorted by ParentID
+func (pq *PriorityQueue) Usage() []Usage {
+	defer pq.lock(OpStats)()
+	return pq.usageList()
+}
+
+// ResetUsage returns the usage counters, as Usage does, and restarts them
+// from zero in the same step, so that no use goes unbilled between two
+// billing periods.
+func (pq *PriorityQueue) ResetUsage() []Usage {
+	defer pq.lock(OpStats)()
+	usage := pq.usageList()
+	pq.usage, pq.usageSince = nil, pq.now()
+	return usage
+}
+
+// usageList returns the usage counters sorted by ParentID. The queue lock
+// must be held.
+func (pq *PriorityQueue) usageList() []Usage {
+	usage := make([]Usage, 0, len(pq.usage))
+	for _, u := range pq.usage {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(a, b int) bool { return usage[a].ParentID < usage[b].ParentID })
+	return usage
+}
+
+// WriteUsageCSV writes usage to w as CSV with a header line, the
+// processing time in milliseconds and Since in RFC 3339, for billing
+// systems and spreadsheets.
+func WriteUsageCSV(w io.Writer, usage []Usage) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"parent_id", "pushes", "bytes", "processing_ms", "since"})
+	for _, u := range usage {
+		cw.Write([]string{
+			u.ParentID,
+			strconv.FormatInt(u.Pushes, 10),
+			strconv.FormatInt(u.Bytes, 10),
+			strconv.FormatInt(u.Processing.Milliseconds(), 10),
+			u.Since.Format(time.RFC3339),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,51 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Usage(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	pq, _ := New(WithClock(func() time.Time { return now }))
+
+	pq.Push(QItem{ID: "a", ParentID: "acme", Value: "12345", Priority: 2})
+	pq.Push(QItem{ID: "b", ParentID: "acme", Value: []byte("123"), Priority: 1})
+	pq.Push(QItem{ID: "c", ParentID: "globex", Value: 42})
+	_, r, _ := pq.Lease(time.Minute)
+	now = now.Add(3 * time.Second)
+	pq.Ack(r)
+	pq.Lease(time.Second)
+	now = now.Add(2 * time.Second)
+	pq.Pop() // expires the lease of b, queued again
+
+	want := []Usage{
+		{ParentID: "acme", Pushes: 2, Bytes: 8, Processing: 4 * time.Second, Since: start},
+		{ParentID: "globex", Pushes: 1, Since: start},
+	}
+	if got := pq.Usage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("usage %+v, expected %+v", got, want)
+	}
+
+	if got := pq.ResetUsage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("reset usage %+v, expected %+v", got, want)
+	}
+	assertEqual(t, len(pq.Usage()), 0)
+	pq.SetValueSizer(func(interface{}) int { return 100 })
+	pq.Push(QItem{ID: "d", ParentID: "globex", Value: 42})
+	usage := pq.Usage()
+	assertEqual(t, len(usage), 1)
+	assertEqual(t, usage[0].Bytes, int64(100))
+	assertEqual(t, usage[0].Since, now)
+
+	var buf bytes.Buffer
+	if err := WriteUsageCSV(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, buf.String(), "parent_id,pushes,bytes,processing_ms,since\n"+
+		"acme,2,8,4000,2024-03-01T00:00:00Z\n"+
+		"globex,1,0,0,2024-03-01T00:00:00Z\n")
+}
@@ -0,0 +1,52 @@
+package priorityqueue
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// WaitAny pops the highest priority item of the first of queues holding
+// one, waiting until an item is pushed to any of them if they are all empty
+// or frozen, until ctx is done. It returns the index of the queue the item
+// was popped from. Queues are tried in order, so earlier queues win when
+// several have items.
+func WaitAny(ctx context.Context, queues ...*PriorityQueue) (int, *QItem, error) {
+	for {
+		cases := make([]reflect.SelectCase, 0, len(queues)+2)
+		wait := time.Duration(-1) // until the soonest delayed item, lease or freeze poll
+		for n, pq := range queues {
+			unlock := pq.lock(OpPop)
+			item, err := pq.pop()
+			if err != ErrEmptyQueue && err != ErrFrozen {
+				unlock()
+				return n, item, err
+			}
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(pq.waitPushed())})
+			d := time.Duration(-1)
+			if err == ErrFrozen {
+				d = freezePoll
+			} else if next := pq.nextDue(); !next.IsZero() {
+				d = next.Sub(pq.now())
+			}
+			if d >= 0 && (wait < 0 || d < wait) {
+				wait = d
+			}
+			unlock()
+		}
+
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+		var t *time.Timer
+		if wait >= 0 {
+			t = time.NewTimer(wait)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.C)})
+		}
+		chosen, _, _ := reflect.Select(cases)
+		if t != nil {
+			t.Stop()
+		}
+		if chosen == len(queues) {
+			return -1, nil, ctx.Err()
+		}
+	}
+}
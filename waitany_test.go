@@ -0,0 +1,61 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_WaitAnyReady(t *testing.T) {
+	a, b := NewPriorityQueue(), NewPriorityQueue()
+	b.Push(QItem{ID: "b"})
+
+	n, item, err := WaitAny(context.Background(), a, b)
+	if err != nil {
+		t.Errorf("Error waiting on queues: %v", err)
+		return
+	}
+	assertEqual(t, n, 1)
+	assertEqual(t, item.ID, "b")
+}
+
+func Test_WaitAnyBlocks(t *testing.T) {
+	a, b, c := NewPriorityQueue(), NewPriorityQueue(), NewPriorityQueue()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.Push(QItem{ID: "c"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	n, item, err := WaitAny(ctx, a, b, c)
+	if err != nil {
+		t.Errorf("Error waiting on queues: %v", err)
+		return
+	}
+	assertEqual(t, n, 2)
+	assertEqual(t, item.ID, "c")
+}
+
+func Test_WaitAnyDelayed(t *testing.T) {
+	a, b := NewPriorityQueue(), NewPriorityQueue()
+	b.PushDelayed(QItem{ID: "b"}, time.Now().Add(10*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	n, _, err := WaitAny(ctx, a, b)
+	if err != nil {
+		t.Errorf("Error waiting for a delayed item: %v", err)
+	}
+	assertEqual(t, n, 1)
+}
+
+func Test_WaitAnyCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	n, _, err := WaitAny(ctx, NewPriorityQueue(), NewPriorityQueue())
+	if err != context.DeadlineExceeded {
+		t.Errorf("Error waiting on empty queues: %v", err)
+	}
+	assertEqual(t, n, -1)
+}
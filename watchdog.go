@@ -0,0 +1,131 @@
+package priorityqueue
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultHistogramBounds are the upper bounds of the hold time buckets used
+// by NewWatchdog. Hold times above the last bound fall into an overflow bucket.
+var DefaultHistogramBounds = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// A Histogram summarizes how long an operation held the queue mutex.
+// Counts has one entry per bound plus a final overflow entry.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+func newHistogram(bounds []time.Duration) *Histogram {
+	return &Histogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && d > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+func (h *Histogram) copy() Histogram {
+	c := *h
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return c
+}
+
+// A Watchdog records how long the queue mutex is held by each operation and
+// warns when an operation holds it for longer than Threshold.
+type Watchdog struct {
+	// Threshold is the hold time above which OnSlow is called. A zero
+	// Threshold disables warnings; histograms are still recorded.
+	Threshold time.Duration
+
+	// OnSlow is called, after the mutex has been released, for every
+	// operation that exceeded Threshold. If nil the warning is logged.
+	OnSlow func(op Operation, held time.Duration)
+
+	m          sync.Mutex
+	bounds     []time.Duration
+	histograms map[Operation]*Histogram
+}
+
+// NewWatchdog returns a Watchdog using DefaultHistogramBounds
+func NewWatchdog(threshold time.Duration, onSlow func(op Operation, held time.Duration)) *Watchdog {
+	return &Watchdog{
+		Threshold:  threshold,
+		OnSlow:     onSlow,
+		bounds:     DefaultHistogramBounds,
+		histograms: make(map[Operation]*Histogram),
+	}
+}
+
+func (w *Watchdog) observe(op Operation, held time.Duration) {
+	w.m.Lock()
+	h, ok := w.histograms[op]
+	if !ok {
+		h = newHistogram(w.bounds)
+		w.histograms[op] = h
+	}
+	h.observe(held)
+	w.m.Unlock()
+
+	if w.Threshold > 0 && held > w.Threshold {
+		if w.OnSlow != nil {
+			w.OnSlow(op, held)
+		} else {
+			log.Printf("priorityqueue: %s held the queue lock for %v (threshold %v)", op, held, w.Threshold)
+		}
+	}
+}
+
+// Histogram returns a copy of the hold time histogram for op
+func (w *Watchdog) Histogram(op Operation) Histogram {
+	w.m.Lock()
+	defer w.m.Unlock()
+	h, ok := w.histograms[op]
+	if !ok {
+		return newHistogram(w.bounds).copy()
+	}
+	return h.copy()
+}
+
+// Histograms returns a copy of the hold time histograms of every operation
+// observed so far.
+func (w *Watchdog) Histograms() map[Operation]Histogram {
+	w.m.Lock()
+	defer w.m.Unlock()
+	r := make(map[Operation]Histogram, len(w.histograms))
+	for op, h := range w.histograms {
+		r[op] = h.copy()
+	}
+	return r
+}
+
+// SetWatchdog installs w to observe the queue's lock hold times. Passing nil
+// removes the current watchdog.
+func (pq *PriorityQueue) SetWatchdog(w *Watchdog) {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	pq.watchdog = w
+}
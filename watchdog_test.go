@@ -0,0 +1,46 @@
+package priorityqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_WatchdogHistograms(t *testing.T) {
+	pq := NewPriorityQueue()
+	w := NewWatchdog(0, nil)
+	pq.SetWatchdog(w)
+	populateQueue(pq, 10)
+	pq.Pop()
+	pq.DeleteItemsByParentId("12345")
+
+	assertEqual(t, w.Histogram(OpPush).Count, uint64(10))
+	assertEqual(t, w.Histogram(OpPop).Count, uint64(1))
+	assertEqual(t, w.Histogram(OpDeleteItemsByParentId).Count, uint64(1))
+	assertEqual(t, w.Histogram(OpClear).Count, uint64(0))
+
+	h := w.Histogram(OpPush)
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	assertEqual(t, total, h.Count)
+	assertEqual(t, len(w.Histograms()), 3)
+}
+
+func Test_WatchdogOnSlow(t *testing.T) {
+	pq := NewPriorityQueue()
+	var slow []Operation
+	w := NewWatchdog(time.Nanosecond, func(op Operation, held time.Duration) {
+		slow = append(slow, op)
+	})
+	pq.SetWatchdog(w)
+	populateQueue(pq, 100)
+
+	if len(slow) == 0 {
+		t.Errorf("OnSlow was not called")
+	}
+	pq.SetWatchdog(nil)
+	n := len(slow)
+	pq.Push(QItem{ID: "2", Priority: 1})
+	assertEqual(t, len(slow), n)
+}
@@ -0,0 +1,302 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// A WebhookEvent names an event posted to webhooks
+type WebhookEvent string
+
+const (
+	EventDeadLettered  WebhookEvent = "dead_lettered"  // An item was dead-lettered
+	EventSLABreach     WebhookEvent = "sla_breach"     // An item waited longer than the SLA
+	EventPaused        WebhookEvent = "paused"         // A parent was paused, or the queue frozen
+	EventHighWatermark WebhookEvent = "high_watermark" // The queue grew beyond the high watermark
+)
+
+// A Webhook receives the events it subscribes to as JSON WebhookPayloads
+// POSTed to its URL. With a Secret, each request carries the hex HMAC-SHA256
+// of its body keyed by the secret in the X-Pq-Signature header, as
+// "sha256=<hex>", so the receiver can check where the event comes from.
+type Webhook struct {
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events,omitempty"` // Every event if empty
+	Secret string         `json:"secret,omitempty"`
+}
+
+func (h Webhook) subscribes(event WebhookEvent) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// A WebhookPayload is the body of a webhook request
+type WebhookPayload struct {
+	Event    WebhookEvent `json:"event"`
+	Time     time.Time    `json:"time"`
+	Item     *QItem       `json:"item,omitempty"`      // The item dead-lettered or breaching the SLA
+	Reason   string       `json:"reason,omitempty"`    // Why the item was dead-lettered
+	ParentID string       `json:"parent_id,omitempty"` // The paused parent, empty when the queue was frozen
+	Len      int          `json:"len,omitempty"`       // The queue length beyond the high watermark
+}
+
+// WebhookConfig configures the webhooks of a queue, see SetWebhooks
+type WebhookConfig struct {
+	Hooks []Webhook `json:"hooks"`
+
+	// SLA is how long an item may wait in the queue; each item waiting
+	// longer is reported once. Zero reports nothing.
+	SLA Duration `json:"sla,omitempty"`
+
+	// HighWatermark is the queue length beyond which EventHighWatermark is
+	// posted. It is posted again only after the length fell back to
+	// LowWatermark. Zero reports nothing.
+	HighWatermark int `json:"high_watermark,omitempty"`
+	LowWatermark  int `json:"low_watermark,omitempty"`
+
+	// Interval is how often the SLA is checked, every second if zero
+	Interval Duration `json:"interval,omitempty"`
+
+	// MaxAttempts is the number of times a request is tried, 5 if zero; a
+	// request fails on an error or a status other than 2xx. Backoff is the
+	// wait before the first retry, one second if zero, doubled after each
+	// retry.
+	MaxAttempts int      `json:"max_attempts,omitempty"`
+	Backoff     Duration `json:"backoff,omitempty"`
+
+	// Buffer is the number of events waiting for each hook, 100 if zero;
+	// events finding the buffer full are dropped.
+	Buffer int `json:"buffer,omitempty"`
+
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client `json:"-"`
+}
+
+// WebhookStats counts the events of one webhook
+type WebhookStats struct {
+	Delivered int64
+	Failed    int64 // Events given up after MaxAttempts
+	Dropped   int64 // Events dropped on a full buffer
+}
+
+// webhooks dispatches the events of a queue to its hooks
+type webhooks struct {
+	cfg      WebhookConfig
+	hooks    []*webhook
+	stop     context.CancelFunc
+	breached map[*QItem]uint64 // Items reported breaching the SLA, by seq
+	high     bool              // The high watermark was reported
+}
+
+type webhook struct {
+	Webhook
+	events                     chan []byte
+	delivered, failed, dropped atomic.Int64
+}
+
+// SetWebhooks replaces the webhooks of the queue, posting dead-lettered
+// items, SLA breaches, pauses and high watermarks with retries and backoff
+// on goroutines owned by the queue. Events are not persisted: those still
+// waiting when the webhooks are replaced or the queue stops are lost. No
+// hooks, the default, posts nothing.
+func (pq *PriorityQueue) SetWebhooks(cfg WebhookConfig) error {
+	for _, h := range cfg.Hooks {
+		if h.URL == "" {
+			return fmt.Errorf("webhook without a URL")
+		}
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff == 0 {
+		cfg.Backoff = Duration(time.Second)
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = Duration(time.Second)
+	}
+	if cfg.Buffer == 0 {
+		cfg.Buffer = 100
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	if pq.webhooks != nil {
+		pq.webhooks.stop()
+		pq.webhooks = nil
+	}
+	if len(cfg.Hooks) == 0 || pq.stopped {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(pq.background())
+	w := &webhooks{cfg: cfg, stop: cancel}
+	for _, h := range cfg.Hooks {
+		hook := &webhook{Webhook: h, events: make(chan []byte, cfg.Buffer)}
+		w.hooks = append(w.hooks, hook)
+		pq.spawn(ctx, func(ctx context.Context) error {
+			hook.run(ctx, &w.cfg)
+			return nil
+		})
+	}
+	if cfg.SLA > 0 {
+		pq.spawn(ctx, func(ctx context.Context) error {
+			t := time.NewTicker(time.Duration(cfg.Interval))
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					pq.checkSLA()
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+	}
+	pq.webhooks = w
+	return nil
+}
+
+// WebhookStats returns the stats of the current webhooks, by URL
+func (pq *PriorityQueue) WebhookStats() map[string]WebhookStats {
+	pq.m.Lock()
+	defer pq.m.Unlock()
+	stats := make(map[string]WebhookStats)
+	if pq.webhooks == nil {
+		return stats
+	}
+	for _, h := range pq.webhooks.hooks {
+		stats[h.URL] = WebhookStats{Delivered: h.delivered.Load(), Failed: h.failed.Load(), Dropped: h.dropped.Load()}
+	}
+	return stats
+}
+
+// post queues an event for the hooks subscribing to it. The queue lock
+// must be held.
+func (pq *PriorityQueue) post(p WebhookPayload) {
+	if pq.webhooks == nil {
+		return
+	}
+	p.Time = pq.now()
+	body, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	for _, h := range pq.webhooks.hooks {
+		if !h.subscribes(p.Event) {
+			continue
+		}
+		select {
+		case h.events <- body:
+		default:
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// watermark posts EventHighWatermark when the queue grows beyond the high
+// watermark. The queue lock must be held.
+func (pq *PriorityQueue) watermark() {
+	w := pq.webhooks
+	if w == nil || w.cfg.HighWatermark == 0 {
+		return
+	}
+	switch n := pq.size(); {
+	case !w.high && n > w.cfg.HighWatermark:
+		w.high = true
+		pq.post(WebhookPayload{Event: EventHighWatermark, Len: n})
+	case w.high && n <= w.cfg.LowWatermark:
+		w.high = false
+	}
+}
+
+// checkSLA posts EventSLABreach for the queued items waiting longer than
+// the SLA that were not reported yet.
+func (pq *PriorityQueue) checkSLA() {
+	defer pq.lock(OpStats)()
+	w := pq.webhooks
+	if w == nil {
+		return
+	}
+	cutoff := pq.now().Add(-time.Duration(w.cfg.SLA))
+	breached := make(map[*QItem]uint64)
+	for _, item := range pq.collect(func(i *QItem) bool { return i.PushedAt.Before(cutoff) }) {
+		breached[item] = item.seq
+		if seq, ok := w.breached[item]; !ok || seq != item.seq {
+			i := *item
+			pq.post(WebhookPayload{Event: EventSLABreach, Item: &i})
+		}
+	}
+	w.breached = breached
+}
+
+// run delivers the events of the hook until ctx is done
+func (h *webhook) run(ctx context.Context, cfg *WebhookConfig) {
+	for {
+		select {
+		case body := <-h.events:
+			if h.deliver(ctx, cfg, body) {
+				h.delivered.Add(1)
+			} else if ctx.Err() == nil {
+				h.failed.Add(1)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver posts body, retrying with backoff, and reports whether it was
+// accepted.
+func (h *webhook) deliver(ctx context.Context, cfg *WebhookConfig, body []byte) bool {
+	backoff := time.Duration(cfg.Backoff)
+	for attempt := 1; ; attempt++ {
+		if h.send(ctx, cfg.Client, body) {
+			return true
+		}
+		if attempt == cfg.MaxAttempts {
+			return false
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (h *webhook) send(ctx context.Context, client *http.Client, body []byte) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Pq-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
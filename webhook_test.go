@@ -0,0 +1,124 @@
+package priorityqueue
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the payloads posted to it, failing the first
+// failures requests.
+type webhookReceiver struct {
+	m        sync.Mutex
+	failures int
+	payloads []WebhookPayload
+	got      chan struct{}
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	if req.Header.Get("X-Pq-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var p WebhookPayload
+	json.Unmarshal(body, &p)
+	r.payloads = append(r.payloads, p)
+	r.got <- struct{}{}
+}
+
+func (r *webhookReceiver) wait(t *testing.T) WebhookPayload {
+	select {
+	case <-r.got:
+	case <-time.After(time.Second):
+		t.Fatal("Error, no webhook received")
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.payloads[len(r.payloads)-1]
+}
+
+func Test_Webhooks(t *testing.T) {
+	rcv := &webhookReceiver{failures: 2, got: make(chan struct{}, 10)}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	pq := NewPriorityQueue()
+	defer pq.Destroy()
+	err := pq.SetWebhooks(WebhookConfig{
+		Hooks:         []Webhook{{URL: srv.URL, Secret: "secret"}},
+		HighWatermark: 2,
+		Backoff:       Duration(time.Millisecond),
+	})
+	if err != nil {
+		t.Fatalf("Error setting the webhooks: %v", err)
+	}
+	assertEqual(t, pq.Capabilities().Webhooks, true)
+
+	populateQueue(pq, 3)
+	p := rcv.wait(t)
+	assertEqual(t, p.Event, EventHighWatermark)
+	assertEqual(t, p.Len, 3)
+
+	// Posted once until the queue shrinks to the low watermark
+	pq.Push(QItem{ID: "x"})
+	pq.PauseParent("12345")
+	assertEqual(t, rcv.wait(t).Event, EventPaused)
+	pq.Clear()
+	populateQueue(pq, 3)
+	assertEqual(t, rcv.wait(t).Event, EventHighWatermark)
+
+	pq.SetMaxAttempts(1)
+	pq.ResumeParent("12345")
+	_, r, _ := pq.Lease(time.Minute)
+	pq.Nack(r, 0)
+	p = rcv.wait(t)
+	assertEqual(t, p.Event, EventDeadLettered)
+	assertEqual(t, p.Item.ID, "2")
+
+	// Counted once the response is read
+	deadline := time.Now().Add(time.Second)
+	for pq.WebhookStats()[srv.URL].Delivered < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(t, pq.WebhookStats()[srv.URL], WebhookStats{Delivered: 4})
+}
+
+func Test_WebhookSLA(t *testing.T) {
+	rcv := &webhookReceiver{got: make(chan struct{}, 10)}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	pq := NewPriorityQueue()
+	defer pq.Destroy()
+	pq.SetWebhooks(WebhookConfig{
+		Hooks:    []Webhook{{URL: srv.URL, Secret: "secret", Events: []WebhookEvent{EventSLABreach}}},
+		SLA:      Duration(time.Minute),
+		Interval: Duration(time.Millisecond),
+	})
+	pq.Push(QItem{ID: "old", PushedAt: time.Now().Add(-time.Hour)})
+	pq.Push(QItem{ID: "new"})
+	pq.PauseParent("ignored")
+
+	p := rcv.wait(t)
+	assertEqual(t, p.Event, EventSLABreach)
+	assertEqual(t, p.Item.ID, "old")
+	time.Sleep(10 * time.Millisecond)
+	assertEqual(t, len(rcv.got), 0)
+}